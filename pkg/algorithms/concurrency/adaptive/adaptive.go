@@ -21,6 +21,7 @@ type Limiter struct {
 	minRTT time.Duration
 }
 
+// New creates a Vegas-style limiter starting at minLimit.
 func New(minLimit, maxLimit float64) *Limiter {
 	return &Limiter{
 		limit:    minLimit,
@@ -98,3 +99,23 @@ func (l *Limiter) Limit() float64 {
 	defer l.mu.Unlock()
 	return l.limit
 }
+
+// Drop releases the token for a request that failed from overload (timeout,
+// rejection) and backs the limit off multiplicatively.
+func (l *Limiter) Drop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inflight--
+	if l.inflight < 0 {
+		l.inflight = 0
+	}
+	l.limit = math.Max(l.minLimit, l.limit*0.9)
+}
+
+// Inflight returns the number of acquired, unreleased tokens.
+func (l *Limiter) Inflight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
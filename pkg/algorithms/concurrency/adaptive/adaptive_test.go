@@ -0,0 +1,55 @@
+package adaptive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAIMDGrowsWhenBusyAndBacksOff(t *testing.T) {
+	a := NewAIMD(4, 1, 10, 100*time.Millisecond)
+	for i := 0; i < 4; i++ {
+		if !a.Acquire() {
+			t.Fatalf("acquire %d rejected below limit", i)
+		}
+	}
+	if a.Acquire() {
+		t.Fatal("acquired above limit")
+	}
+	a.Release(time.Millisecond)
+	if a.Limit() != 5 {
+		t.Fatalf("expected additive increase to 5, got %v", a.Limit())
+	}
+	a.Release(time.Second) // slower than timeout: treated as a drop
+	if a.Limit() != 4.5 {
+		t.Fatalf("expected multiplicative decrease to 4.5, got %v", a.Limit())
+	}
+	a.Drop()
+	a.Drop()
+	if a.Inflight() != 0 {
+		t.Fatalf("expected all tokens released, got %d", a.Inflight())
+	}
+}
+
+func TestGradientShrinksOnLatencyIncrease(t *testing.T) {
+	g := NewGradient(20, 1, 100)
+	run := func(n int, rtt time.Duration) {
+		for i := 0; i < n; i++ {
+			var held int
+			for g.Acquire() {
+				held++
+			}
+			for ; held > 0; held-- {
+				g.Release(rtt)
+			}
+		}
+	}
+	run(50, 10*time.Millisecond)
+	steady := g.Limit()
+	if steady <= 20 {
+		t.Fatalf("expected limit to grow at steady latency, got %v", steady)
+	}
+	run(50, 50*time.Millisecond)
+	if g.Limit() >= steady {
+		t.Fatalf("expected limit to shrink when latency rose, got %v >= %v", g.Limit(), steady)
+	}
+}
@@ -0,0 +1,94 @@
+package adaptive
+
+import (
+	"sync"
+	"time"
+)
+
+// AIMD is an additive-increase/multiplicative-decrease limiter. The limit
+// grows by one while the limiter is at least half utilized and shrinks by
+// BackoffRatio on every drop, or when a request exceeds the timeout.
+type AIMD struct {
+	mu       sync.Mutex
+	limit    float64
+	minLimit float64
+	maxLimit float64
+	inflight int
+
+	backoffRatio float64
+	timeout      time.Duration
+}
+
+// NewAIMD creates an AIMD limiter. Requests slower than timeout count as
+// drops (zero disables the latency check).
+func NewAIMD(initial, minLimit, maxLimit float64, timeout time.Duration) *AIMD {
+	return &AIMD{
+		limit:        clamp(initial, minLimit, maxLimit),
+		minLimit:     minLimit,
+		maxLimit:     maxLimit,
+		backoffRatio: 0.9,
+		timeout:      timeout,
+	}
+}
+
+// Acquire tries to acquire a concurrency token.
+func (a *AIMD) Acquire() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if float64(a.inflight) >= a.limit {
+		return false
+	}
+	a.inflight++
+	return true
+}
+
+// Release releases the token and grows the limit when the limiter is busy.
+func (a *AIMD) Release(rtt time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.timeout > 0 && rtt > a.timeout {
+		a.drop()
+		return
+	}
+	// Only grow when the current limit is actually being used; an idle
+	// service would otherwise drift to maxLimit.
+	if float64(a.inflight)*2 >= a.limit {
+		a.limit = clamp(a.limit+1, a.minLimit, a.maxLimit)
+	}
+	a.release()
+}
+
+// Drop releases the token and shrinks the limit.
+func (a *AIMD) Drop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.drop()
+}
+
+func (a *AIMD) drop() {
+	a.limit = clamp(a.limit*a.backoffRatio, a.minLimit, a.maxLimit)
+	a.release()
+}
+
+func (a *AIMD) release() {
+	a.inflight--
+	if a.inflight < 0 {
+		a.inflight = 0
+	}
+}
+
+// Limit returns the current limit.
+func (a *AIMD) Limit() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limit
+}
+
+// Inflight returns the number of acquired, unreleased tokens.
+func (a *AIMD) Inflight() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.inflight
+}
@@ -0,0 +1,34 @@
+package adaptive
+
+import "time"
+
+// Algorithm is the contract shared by the adaptive limiters in this package.
+//
+// Acquire is non-blocking. Every successful Acquire must be paired with
+// exactly one Release (the request completed, with its round-trip time) or
+// Drop (the request failed from overload, e.g. it timed out or was rejected
+// downstream).
+type Algorithm interface {
+	Acquire() bool
+	Release(rtt time.Duration)
+	Drop()
+	Limit() float64
+	Inflight() int
+}
+
+var (
+	_ Algorithm = (*Limiter)(nil)
+	_ Algorithm = (*AIMD)(nil)
+	_ Algorithm = (*Gradient)(nil)
+)
+
+// clamp bounds v to [lo, hi].
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
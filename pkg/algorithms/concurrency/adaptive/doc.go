@@ -1,4 +1,10 @@
 /*
 Package adaptive provides adaptive concurrency control algorithms (e.g., TCP Vegas/BBR inspired).
+
+  - Limiter (New): Vegas-style, grows while estimated queueing is low.
+  - AIMD (NewAIMD): additive increase, multiplicative decrease on drops or timeouts.
+  - Gradient (NewGradient): scales the limit by long/short RTT ratio.
+
+All implement Algorithm; pkg/resilience wraps them as an AdaptiveLimiter.
 */
 package adaptive
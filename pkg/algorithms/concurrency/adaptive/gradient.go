@@ -0,0 +1,108 @@
+package adaptive
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Gradient adjusts the limit by the ratio of a long-term RTT baseline to a
+// short-term RTT average (Netflix Gradient2 style). When recent latency rises
+// above the baseline the gradient drops below one and the limit shrinks;
+// a sqrt(limit) queue allowance lets it probe upwards while latency is flat.
+type Gradient struct {
+	mu       sync.Mutex
+	limit    float64
+	minLimit float64
+	maxLimit float64
+	inflight int
+
+	shortRTT float64 // EMA over ~10 samples
+	longRTT  float64 // EMA over ~600 samples
+	// smoothing damps limit changes (0–1, higher reacts faster).
+	smoothing float64
+}
+
+// NewGradient creates a gradient limiter.
+func NewGradient(initial, minLimit, maxLimit float64) *Gradient {
+	return &Gradient{
+		limit:     clamp(initial, minLimit, maxLimit),
+		minLimit:  minLimit,
+		maxLimit:  maxLimit,
+		smoothing: 0.2,
+	}
+}
+
+// Acquire tries to acquire a concurrency token.
+func (g *Gradient) Acquire() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if float64(g.inflight) >= g.limit {
+		return false
+	}
+	g.inflight++
+	return true
+}
+
+// Release releases the token and feeds rtt into the latency averages.
+func (g *Gradient) Release(rtt time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	inflight := g.inflight
+	g.release()
+	if rtt <= 0 {
+		return
+	}
+
+	sample := float64(rtt)
+	if g.longRTT == 0 {
+		g.shortRTT, g.longRTT = sample, sample
+	} else {
+		g.shortRTT += (sample - g.shortRTT) * 2 / 11
+		g.longRTT += (sample - g.longRTT) * 2 / 601
+	}
+	// Let the baseline recover quickly once latency improves.
+	if g.longRTT/g.shortRTT > 2 {
+		g.longRTT *= 0.95
+	}
+
+	// Don't grow the limit on an underutilized service.
+	if float64(inflight) < g.limit/2 {
+		return
+	}
+
+	gradient := clamp(g.longRTT/g.shortRTT, 0.5, 1)
+	target := g.limit*gradient + math.Sqrt(g.limit)
+	g.limit = clamp(g.limit*(1-g.smoothing)+target*g.smoothing, g.minLimit, g.maxLimit)
+}
+
+// Drop releases the token and shrinks the limit.
+func (g *Gradient) Drop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.release()
+	g.limit = clamp(g.limit*0.9, g.minLimit, g.maxLimit)
+}
+
+func (g *Gradient) release() {
+	g.inflight--
+	if g.inflight < 0 {
+		g.inflight = 0
+	}
+}
+
+// Limit returns the current limit.
+func (g *Gradient) Limit() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limit
+}
+
+// Inflight returns the number of acquired, unreleased tokens.
+func (g *Gradient) Inflight() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.inflight
+}
@@ -15,30 +15,44 @@ type Limiter struct {
 	store cache.Cache
 }
 
+var _ ratelimit.CostLimiter = (*Limiter)(nil)
+
 // New creates a new FixedWindow limiter.
 func New(store cache.Cache) *Limiter {
 	return &Limiter{store: store}
 }
 
 func (l *Limiter) Allow(ctx context.Context, key string, limit int64, period time.Duration) (*ratelimit.Result, error) {
+	return l.AllowN(ctx, key, 1, limit, period)
+}
+
+// AllowN charges n units against the current window. A denied call is
+// rolled back so it consumes nothing.
+func (l *Limiter) AllowN(ctx context.Context, key string, n, limit int64, period time.Duration) (*ratelimit.Result, error) {
 	window := time.Now().Truncate(period).Unix()
 
 	// Optimization: Reduce allocations on hot path.
 	// Use string concatenation and strconv instead of fmt.Sprintf
 	cacheKey := "rl:fixed:" + key + ":" + strconv.FormatInt(window, 10)
 
-	curr, err := l.store.Incr(ctx, cacheKey, 1)
+	curr, err := l.store.Incr(ctx, cacheKey, n)
 	if err != nil {
 		return nil, errors.Wrap(err, "ratelimit error")
 	}
 
-	if curr == 1 {
-		_ = l.store.Set(ctx, cacheKey, int64(1), period*2)
+	if curr == n {
+		_ = l.store.Set(ctx, cacheKey, curr, period*2)
+	}
+	if curr > limit {
+		_, _ = l.store.Incr(ctx, cacheKey, -n)
 	}
 
 	remaining := limit - curr
 	if remaining < 0 {
-		remaining = 0
+		remaining = limit - (curr - n)
+		if remaining < 0 {
+			remaining = 0
+		}
 	}
 
 	resetSeconds := period.Seconds() - float64(time.Now().Unix()%int64(period.Seconds()))
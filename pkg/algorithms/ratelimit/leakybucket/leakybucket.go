@@ -22,12 +22,19 @@ type state struct {
 	mu       *concurrency.SmartMutex
 }
 
+var _ ratelimit.CostLimiter = (*Limiter)(nil)
+
 // New creates a new LeakyBucket limiter.
 func New(store cache.Cache) *Limiter {
 	return &Limiter{store: store}
 }
 
 func (l *Limiter) Allow(ctx context.Context, key string, limit int64, period time.Duration) (*ratelimit.Result, error) {
+	return l.AllowN(ctx, key, 1, limit, period)
+}
+
+// AllowN queues n units when the bucket has room for all of them.
+func (l *Limiter) AllowN(ctx context.Context, key string, n, limit int64, period time.Duration) (*ratelimit.Result, error) {
 	stateKey := "lb:" + key
 	val, ok := l.buckets.Load(stateKey)
 	if !ok {
@@ -54,8 +61,8 @@ func (l *Limiter) Allow(ctx context.Context, key string, limit int64, period tim
 	}
 	s.lastLeak = now
 
-	if s.queue < limit {
-		s.queue++
+	if s.queue+n <= limit {
+		s.queue += n
 		return &ratelimit.Result{
 			Allowed:   true,
 			Remaining: limit - s.queue,
@@ -18,3 +18,9 @@ type Limiter interface {
 	// period is only relevant for window-based strategies.
 	Allow(ctx context.Context, key string, limit int64, period time.Duration) (*Result, error)
 }
+
+// CostLimiter is implemented by limiters that can charge n units in one
+// call, e.g. for weighted requests. A denied call consumes nothing.
+type CostLimiter interface {
+	AllowN(ctx context.Context, key string, n, limit int64, period time.Duration) (*Result, error)
+}
@@ -18,6 +18,8 @@ type Limiter struct {
 	store cache.Cache
 }
 
+var _ ratelimit.CostLimiter = (*Limiter)(nil)
+
 // New creates a new SlidingWindow limiter.
 func New(store cache.Cache) *Limiter {
 	return &Limiter{store: store}
@@ -44,6 +46,11 @@ func (l *Limiter) loadCount(ctx context.Context, key string) (int64, error) {
 }
 
 func (l *Limiter) Allow(ctx context.Context, key string, limit int64, period time.Duration) (*ratelimit.Result, error) {
+	return l.AllowN(ctx, key, 1, limit, period)
+}
+
+// AllowN charges n units when the weighted estimate leaves room for them.
+func (l *Limiter) AllowN(ctx context.Context, key string, n, limit int64, period time.Duration) (*ratelimit.Result, error) {
 	if l.store == nil {
 		return nil, errors.InvalidArgument("slidingwindow Limiter requires a non-nil cache store", nil)
 	}
@@ -80,7 +87,7 @@ func (l *Limiter) Allow(ctx context.Context, key string, limit int64, period tim
 		weight = 1
 	}
 
-	estimate := float64(prev)*weight + float64(curr+1)
+	estimate := float64(prev)*weight + float64(curr+n)
 	reset := time.Until(now.Truncate(period).Add(period))
 
 	if estimate > float64(limit) {
@@ -95,11 +102,11 @@ func (l *Limiter) Allow(ctx context.Context, key string, limit int64, period tim
 		}, nil
 	}
 
-	newCurr, err := l.store.Incr(ctx, currKey, 1)
+	newCurr, err := l.store.Incr(ctx, currKey, n)
 	if err != nil {
 		return nil, errors.Wrap(err, "slidingwindow incr")
 	}
-	if newCurr == 1 {
+	if newCurr == n {
 		_ = l.store.Set(ctx, currKey, newCurr, period*2)
 	}
 
@@ -35,6 +35,29 @@ func TestDistLimiterUsesStore(t *testing.T) {
 	assert.Equal(t, int64(0), res.Remaining)
 }
 
+func TestDistLimiterAllowNConsumesWeight(t *testing.T) {
+	store := memory.New()
+	defer store.Close()
+
+	l := tokenbucket.NewDist(store)
+	ctx := context.Background()
+
+	res, err := l.AllowN(ctx, "weighted", 7, 10, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, int64(3), res.Remaining)
+
+	// A request heavier than the remaining tokens is denied without
+	// consuming them.
+	res, err = l.AllowN(ctx, "weighted", 5, 10, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+
+	res, err = l.AllowN(ctx, "weighted", 3, 10, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
 func BenchmarkDistLimiter_Allow(b *testing.B) {
 	store := memory.New()
 	limiter := tokenbucket.NewDist(store)
@@ -28,6 +28,11 @@ type distBucketState struct {
 	LastRefill int64   `json:"last_refill"` // unix nanoseconds
 }
 
+var (
+	_ ratelimit.CostLimiter = (*DistLimiter)(nil)
+	_ ratelimit.CostLimiter = (*InMemoryLimiter)(nil)
+)
+
 // NewDist creates a new distributed TokenBucket limiter.
 func NewDist(store cache.Cache) *DistLimiter {
 	return &DistLimiter{store: store}
@@ -43,6 +48,11 @@ func (l *DistLimiter) keyMutex(key string) *concurrency.SmartMutex {
 }
 
 func (l *DistLimiter) Allow(ctx context.Context, key string, limit int64, period time.Duration) (*ratelimit.Result, error) {
+	return l.AllowN(ctx, key, 1, limit, period)
+}
+
+// AllowN takes n tokens when the bucket holds at least n.
+func (l *DistLimiter) AllowN(ctx context.Context, key string, n, limit int64, period time.Duration) (*ratelimit.Result, error) {
 	if l.store == nil {
 		return nil, errors.InvalidArgument("DistLimiter requires a non-nil cache store", nil)
 	}
@@ -82,8 +92,8 @@ func (l *DistLimiter) Allow(ctx context.Context, key string, limit int64, period
 	state.LastRefill = now.UnixNano()
 
 	ttl := period * 2
-	if state.Tokens >= 1 {
-		state.Tokens--
+	if state.Tokens >= float64(n) {
+		state.Tokens -= float64(n)
 		if err := l.store.Set(ctx, storeKey, state, ttl); err != nil {
 			return nil, errors.Wrap(err, "tokenbucket dist set")
 		}
@@ -97,7 +107,7 @@ func (l *DistLimiter) Allow(ctx context.Context, key string, limit int64, period
 	if err := l.store.Set(ctx, storeKey, state, ttl); err != nil {
 		return nil, errors.Wrap(err, "tokenbucket dist set")
 	}
-	timeUntilToken := time.Duration((1 - state.Tokens) / refillRate * float64(time.Second))
+	timeUntilToken := time.Duration((float64(n) - state.Tokens) / refillRate * float64(time.Second))
 	return &ratelimit.Result{
 		Allowed:   false,
 		Remaining: 0,
@@ -126,6 +136,11 @@ func NewInMemory(rate float64, burst int64) *InMemoryLimiter {
 }
 
 func (l *InMemoryLimiter) Allow(ctx context.Context, key string, limit int64, period time.Duration) (*ratelimit.Result, error) {
+	return l.AllowN(ctx, key, 1, limit, period)
+}
+
+// AllowN takes n tokens when the bucket holds at least n.
+func (l *InMemoryLimiter) AllowN(ctx context.Context, key string, n, limit int64, period time.Duration) (*ratelimit.Result, error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -144,10 +159,11 @@ func (l *InMemoryLimiter) Allow(ctx context.Context, key string, limit int64, pe
 		l.lastUpdate[key] = now
 	}
 
-	if tokens >= 1 {
-		l.tokens[key] = tokens - 1
-		return &ratelimit.Result{Allowed: true, Remaining: int64(tokens - 1)}, nil
+	if tokens >= float64(n) {
+		l.tokens[key] = tokens - float64(n)
+		return &ratelimit.Result{Allowed: true, Remaining: int64(tokens - float64(n))}, nil
 	}
 
+	l.tokens[key] = tokens
 	return &ratelimit.Result{Allowed: false, Remaining: 0}, nil
 }
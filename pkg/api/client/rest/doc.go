@@ -1,4 +1,23 @@
 /*
 Package rest provides a generic REST client wrapper with Observability and Resilience.
+
+Client.Do retries idempotent requests (GET, HEAD, OPTIONS, PUT, DELETE) on
+transport errors and 408/429/5xx responses with exponential backoff. POST and
+PATCH are retried only when they carry an Idempotency-Key header. Retry-After
+on 429 and 503 responses is honoured up to RetryAfterMax, no retry is started
+that could not finish before the context deadline, and the remaining deadline
+is sent to the server in the X-Request-Timeout header. With HedgeDelay set,
+retryable requests are hedged via resilience.HedgeT; every copy sent counts
+against Retries. NewSimple returns an *http.Client that retries the same way.
+
+The typed helpers encode and decode JSON and turn error responses back into
+pkg/errors codes:
+
+	user, err := rest.GetJSON[User](ctx, client, base+"/users/42")
+	created, err := rest.PostJSON[CreateUser, User](ctx, client, base+"/users", req,
+		rest.WithIdempotencyKey(key))
+	if errors.IsCode(err, errors.CodeConflict) {
+		// ...
+	}
 */
 package rest
@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+// maxErrorBody caps how much of an error response is read for decoding.
+const maxErrorBody = 64 << 10
+
+// RequestOption customises a request built by the JSON helpers.
+type RequestOption func(*http.Request)
+
+// WithHeader sets a request header.
+func WithHeader(key, value string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set(key, value)
+	}
+}
+
+// WithIdempotencyKey sets the Idempotency-Key header, which makes POST and
+// PATCH requests eligible for retries and hedging.
+func WithIdempotencyKey(key string) RequestOption {
+	return WithHeader(IdempotencyKeyHeader, key)
+}
+
+// GetJSON performs a GET and decodes the JSON response into T.
+func GetJSON[T any](ctx context.Context, c *Client, url string, opts ...RequestOption) (T, error) {
+	return DoJSON[T](ctx, c, http.MethodGet, url, nil, opts...)
+}
+
+// PostJSON sends body as JSON and decodes the response into Resp.
+func PostJSON[Req, Resp any](ctx context.Context, c *Client, url string, body Req, opts ...RequestOption) (Resp, error) {
+	return DoJSON[Resp](ctx, c, http.MethodPost, url, body, opts...)
+}
+
+// PutJSON sends body as JSON with PUT and decodes the response into Resp.
+func PutJSON[Req, Resp any](ctx context.Context, c *Client, url string, body Req, opts ...RequestOption) (Resp, error) {
+	return DoJSON[Resp](ctx, c, http.MethodPut, url, body, opts...)
+}
+
+// DeleteJSON performs a DELETE and decodes the JSON response, if any, into T.
+func DeleteJSON[T any](ctx context.Context, c *Client, url string, opts ...RequestOption) (T, error) {
+	return DoJSON[T](ctx, c, http.MethodDelete, url, nil, opts...)
+}
+
+// DoJSON sends body (if non-nil) as JSON and decodes a 2xx response into
+// Resp; empty and 204 responses yield the zero value. Error statuses are
+// decoded by DecodeError.
+func DoJSON[Resp any](ctx context.Context, c *Client, method, url string, body interface{}, opts ...RequestOption) (Resp, error) {
+	var out Resp
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return out, errors.InvalidArgument("failed to encode request body", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := c.NewRequest(ctx, method, url, reader)
+	if err != nil {
+		return out, errors.InvalidArgument("invalid request", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return out, transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return out, DecodeError(resp)
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return out, nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return out, errors.Unavailable("failed to read response body", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return out, nil
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, errors.Internal("failed to decode response body", err)
+	}
+	return out, nil
+}
+
+// StatusError is the cause attached to errors decoded from HTTP responses.
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http status %d", e.StatusCode)
+}
+
+// DecodeError converts an error response into an *errors.AppError. The
+// rest.Server envelope ({"error": ..., "code": ...}) keeps its pkg/errors
+// code; other bodies are mapped from the status with errors.FromHTTP. The
+// returned error unwraps to a *StatusError.
+func DecodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	cause := &StatusError{StatusCode: resp.StatusCode, Body: body}
+
+	var envelope struct {
+		Error string          `json:"error"`
+		Code  json.RawMessage `json:"code"`
+	}
+	var msg string
+	if json.Unmarshal(body, &envelope) == nil {
+		msg = envelope.Error
+		// Framework errors carry the numeric HTTP status as their code.
+		var code string
+		if json.Unmarshal(envelope.Code, &code) == nil && code != "" {
+			if msg == "" {
+				msg = http.StatusText(resp.StatusCode)
+			}
+			return errors.New(code, msg, cause)
+		}
+	}
+
+	appErr := errors.FromHTTP(resp.StatusCode, msg)
+	appErr.Err = cause
+	return appErr
+}
+
+// transportError maps a failed Do to a pkg/errors code.
+func transportError(ctx context.Context, err error) error {
+	var appErr *errors.AppError
+	switch {
+	case errors.As(err, &appErr):
+		return err
+	case ctx.Err() == context.DeadlineExceeded:
+		return errors.DeadlineExceeded("request deadline exceeded", err)
+	case ctx.Err() == context.Canceled:
+		return errors.Canceled("request canceled", err)
+	default:
+		return errors.Unavailable("http request failed", err)
+	}
+}
@@ -2,7 +2,9 @@ package rest
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"crypto/tls"
@@ -12,7 +14,6 @@ import (
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/resilience"
-	"github.com/hashicorp/go-retryablehttp"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
@@ -36,6 +37,17 @@ type Config struct {
 	CircuitBreakerEnabled   bool          `env:"CLIENT_CB_ENABLED" env-default:"true"`
 	CircuitBreakerThreshold int64         `env:"CLIENT_CB_THRESHOLD" env-default:"5"`
 	CircuitBreakerTimeout   time.Duration `env:"CLIENT_CB_TIMEOUT" env-default:"30s"`
+
+	// Retry settings. Only idempotent requests are retried; POST and PATCH
+	// qualify when they carry an Idempotency-Key header.
+	RetryWaitMin  time.Duration `env:"CLIENT_RETRY_WAIT_MIN" env-default:"100ms"`
+	RetryWaitMax  time.Duration `env:"CLIENT_RETRY_WAIT_MAX" env-default:"5s"`
+	RetryAfterMax time.Duration `env:"CLIENT_RETRY_AFTER_MAX" env-default:"30s"`
+
+	// HedgeDelay sends a second copy of an idempotent request when the first
+	// has not answered within the delay. Every copy sent counts against
+	// Retries, so hedging needs Retries of at least 1. Zero disables it.
+	HedgeDelay time.Duration `env:"CLIENT_HEDGE_DELAY" env-default:"0s"`
 }
 
 // Client wraps http.Client with resilience features.
@@ -47,17 +59,11 @@ type Client struct {
 
 // New creates a robust HTTP client with Retries, Circuit Breaker, and OTel Tracing
 func New(cfg Config) (*Client, error) {
-	// 1. Retryable Client
-	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = cfg.Retries
-	retryClient.HTTPClient.Timeout = cfg.Timeout
-	retryClient.Logger = nil
+	// 1. Retry defaults (retries run in Do, see retry.go)
+	cfg = cfg.withRetryDefaults()
 
 	// 2. Configure Transport (TLS -> Logging -> OTel -> Base)
-	baseTransport := retryClient.HTTPClient.Transport
-	if baseTransport == nil {
-		baseTransport = http.DefaultTransport
-	}
+	var baseTransport http.RoundTripper = http.DefaultTransport.(*http.Transport).Clone()
 
 	// Handle TLS
 	if cfg.TLSEnabled {
@@ -101,13 +107,10 @@ func New(cfg Config) (*Client, error) {
 
 	// Add OTel
 	otelTransport := otelhttp.NewTransport(loggingTransport)
-	retryClient.HTTPClient.Transport = otelTransport
 
 	// 3. Create standard client
-	stdClient := retryClient.StandardClient()
-
 	client := &Client{
-		httpClient: stdClient,
+		httpClient: &http.Client{Transport: otelTransport, Timeout: cfg.Timeout},
 		config:     cfg,
 	}
 
@@ -153,17 +156,70 @@ func (t *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	return resp, nil
 }
 
-// Do executes the request with circuit breaker protection.
+// Do executes the request with retries, hedging and circuit breaker
+// protection. HTTP error statuses are returned as responses, not errors.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if req.GetBody != nil && req.Body != nil {
+		// Attempts send copies from GetBody; close the original as
+		// http.Client would.
+		defer req.Body.Close()
+	}
+
+	ctx := req.Context()
+	retryable := isRetryable(req)
+	// budget is the number of sends left, hedged copies included.
+	budget := 1
+	if retryable && c.config.Retries > 0 {
+		budget += c.config.Retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, sent, err := c.attempt(req, retryable && budget > 1)
+		budget -= sent
+		if budget <= 0 || ctx.Err() != nil || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		wait, ok := c.retryWait(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		if deadline, has := ctx.Deadline(); has && time.Until(deadline) <= wait {
+			// The next attempt could not finish in time; report this one.
+			return resp, err
+		}
+		drain(resp)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send performs a single attempt of req under ctx.
+func (c *Client) send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	r := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		r.Header.Set(TimeoutHeader, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+
 	if c.circuitBreaker == nil {
-		return c.httpClient.Do(req)
+		return c.httpClient.Do(r)
 	}
 
 	var resp *http.Response
-	err := c.circuitBreaker.Execute(req.Context(), func(ctx context.Context) error {
+	err := c.circuitBreaker.Execute(ctx, func(ctx context.Context) error {
 		var err error
-		reqWithCtx := req.WithContext(ctx)
-		resp, err = c.httpClient.Do(reqWithCtx)
+		resp, err = c.httpClient.Do(r.WithContext(ctx))
 
 		// Only count server errors (5xx) as failures for circuit breaker
 		if err == nil && resp != nil && resp.StatusCode >= 500 {
@@ -180,9 +236,10 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	return resp, err
 }
 
-// Get performs a GET request with circuit breaker protection.
-func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// NewRequest builds a request carrying the configured auth and User-Agent
+// headers.
+func (c *Client) NewRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -196,7 +253,15 @@ func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
 	if c.config.UserAgent != "" {
 		req.Header.Set("User-Agent", c.config.UserAgent)
 	}
+	return req, nil
+}
 
+// Get performs a GET request with retry and circuit breaker protection.
+func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
 	return c.Do(req)
 }
 
@@ -222,20 +287,29 @@ func (e *serverError) Error() string {
 	return "server error"
 }
 
-// NewSimple creates a simple HTTP client without circuit breaker (backward compatible).
+// NewSimple creates a plain *http.Client without circuit breaker or
+// logging. Requests are retried and hedged exactly as by Client.Do.
 func NewSimple(cfg Config) *http.Client {
-	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = cfg.Retries
-	retryClient.HTTPClient.Timeout = cfg.Timeout
-	retryClient.Logger = nil
-
-	baseTransport := retryClient.HTTPClient.Transport
-	if baseTransport == nil {
-		baseTransport = http.DefaultTransport
+	client := &Client{
+		httpClient: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   cfg.Timeout,
+		},
+		config: cfg.withRetryDefaults(),
 	}
+	return &http.Client{Transport: retryTransport{client: client}}
+}
 
-	otelTransport := otelhttp.NewTransport(baseTransport)
-	retryClient.HTTPClient.Transport = otelTransport
+// retryTransport runs Client.Do behind the http.RoundTripper interface.
+type retryTransport struct {
+	client *Client
+}
 
-	return retryClient.StandardClient()
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.GetBody == nil && req.Body != nil {
+		// Do leaves a body it cannot replay to the single attempt; a
+		// RoundTripper must close it in every case.
+		defer req.Body.Close()
+	}
+	return t.client.Do(req)
 }
@@ -0,0 +1,166 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/resilience"
+)
+
+const (
+	// IdempotencyKeyHeader marks a POST or PATCH as safe to retry; servers
+	// are expected to deduplicate requests sharing a key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// TimeoutHeader carries the milliseconds left before the caller's
+	// context deadline so servers can bound their own work.
+	TimeoutHeader = "X-Request-Timeout"
+
+	// retryJitter spreads exponential backoff waits by ±20%.
+	retryJitter = 0.2
+)
+
+// isRetryable reports whether req may be sent more than once: idempotent
+// methods always, POST and PATCH only with an Idempotency-Key. A body that
+// cannot be replayed rules out retries.
+func isRetryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// withRetryDefaults fills in unset retry waits.
+func (cfg Config) withRetryDefaults() Config {
+	if cfg.RetryWaitMin <= 0 {
+		cfg.RetryWaitMin = 100 * time.Millisecond
+	}
+	if cfg.RetryWaitMax <= 0 {
+		cfg.RetryWaitMax = 5 * time.Second
+	}
+	if cfg.RetryAfterMax <= 0 {
+		cfg.RetryAfterMax = 30 * time.Second
+	}
+	return cfg
+}
+
+// retryableStatus reports whether a response status is worth retrying.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented:
+		return false
+	}
+	return code >= 500
+}
+
+// shouldRetry decides on another attempt after resp/err. An open circuit
+// fails fast rather than retrying.
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, resilience.ErrCircuitOpen) &&
+			!errors.Is(err, context.Canceled)
+	}
+	return retryableStatus(resp.StatusCode)
+}
+
+// retryWait returns how long to wait before the attempt after attempt.
+// A Retry-After header on 429 and 503 responses takes precedence; ok is
+// false when it asks for longer than RetryAfterMax.
+func (c *Client) retryWait(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return wait, wait <= c.config.RetryAfterMax
+		}
+	}
+	return resilience.ExponentialBackoff(attempt, c.config.RetryWaitMin, c.config.RetryWaitMax, retryJitter), true
+}
+
+// parseRetryAfter reads a Retry-After value in delay-seconds or HTTP-date
+// form.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	at, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if wait := at.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
+
+// attempt sends req once, or hedged when enabled and hedge allows it. It
+// returns how many copies were sent.
+func (c *Client) attempt(req *http.Request, hedge bool) (resp *http.Response, sent int, err error) {
+	if !hedge || c.config.HedgeDelay <= 0 {
+		resp, err = c.send(req.Context(), req)
+		return resp, 1, err
+	}
+
+	// Hedged attempts share a context that is cancelled once a winner is
+	// chosen, so each buffers its body before returning. Retryable statuses
+	// count as failures to give the other copy a chance to win.
+	var copies atomic.Int32
+	resp, err = resilience.HedgeT(req.Context(), c.config.HedgeDelay, func(ctx context.Context) (*http.Response, error) {
+		copies.Add(1)
+		resp, err := c.send(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if retryableStatus(resp.StatusCode) {
+			return nil, &statusResponse{resp: resp}
+		}
+		return resp, nil
+	})
+	var sr *statusResponse
+	if errors.As(err, &sr) {
+		return sr.resp, int(copies.Load()), nil
+	}
+	return resp, int(copies.Load()), err
+}
+
+// statusResponse carries a retryable response through resilience.HedgeT.
+type statusResponse struct {
+	resp *http.Response
+}
+
+func (e *statusResponse) Error() string {
+	return "retryable status " + strconv.Itoa(e.resp.StatusCode)
+}
+
+// drain discards a response that is about to be retried so its connection
+// can be reused.
+func drain(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+}
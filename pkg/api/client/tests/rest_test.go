@@ -1,10 +1,21 @@
 package tests
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/client/rest"
+	server "github.com/chris-alexander-pop/go-hyperforge/pkg/api/rest"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/labstack/echo/v4"
 )
 
 func TestNewRESTClient(t *testing.T) {
@@ -21,3 +32,277 @@ func TestNewRESTClient(t *testing.T) {
 		t.Fatal("Expected client, got nil")
 	}
 }
+
+type widget struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func newTestClient(t *testing.T, mutate func(*rest.Config)) *rest.Client {
+	t.Helper()
+	cfg := rest.Config{
+		Timeout:      time.Second,
+		Retries:      2,
+		RetryWaitMin: time.Millisecond,
+		RetryWaitMax: 5 * time.Millisecond,
+	}
+	if mutate != nil {
+		mutate(&cfg)
+	}
+	client, err := rest.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestJSONHelpersDecodeErrorEnvelope(t *testing.T) {
+	srv := server.New(server.Config{Port: "0"})
+	srv.Echo().GET("/widgets/:id", func(c echo.Context) error {
+		if c.Param("id") == "missing" {
+			return errors.NotFound("widget missing not found", nil)
+		}
+		return c.JSON(http.StatusOK, widget{ID: c.Param("id"), Name: "gear"})
+	})
+	srv.Echo().POST("/widgets", func(c echo.Context) error {
+		var w widget
+		if err := c.Bind(&w); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusCreated, w)
+	})
+	ts := httptest.NewServer(srv.Echo())
+	defer ts.Close()
+
+	client := newTestClient(t, nil)
+	ctx := context.Background()
+
+	got, err := rest.GetJSON[widget](ctx, client, ts.URL+"/widgets/7")
+	if err != nil || got.ID != "7" || got.Name != "gear" {
+		t.Fatalf("GetJSON = %+v, %v", got, err)
+	}
+
+	created, err := rest.PostJSON[widget, widget](ctx, client, ts.URL+"/widgets", widget{ID: "9", Name: "cog"})
+	if err != nil || created.Name != "cog" {
+		t.Fatalf("PostJSON = %+v, %v", created, err)
+	}
+
+	_, err = rest.GetJSON[widget](ctx, client, ts.URL+"/widgets/missing")
+	if !errors.IsCode(err, errors.CodeNotFound) {
+		t.Fatalf("expected NOT_FOUND, got %v", err)
+	}
+	var appErr *errors.AppError
+	if !errors.As(err, &appErr) || appErr.Message != "widget missing not found" {
+		t.Fatalf("expected envelope message, got %v", err)
+	}
+	var statusErr *rest.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected StatusError 404, got %v", err)
+	}
+
+	// Framework errors carry a numeric code and fall back to the status.
+	_, err = rest.GetJSON[widget](ctx, client, ts.URL+"/nowhere")
+	if !errors.IsCode(err, errors.CodeNotFound) {
+		t.Fatalf("expected NOT_FOUND for unknown route, got %v", err)
+	}
+}
+
+func TestRetryOnlyIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer ts.Close()
+	client := newTestClient(t, nil)
+	ctx := context.Background()
+
+	if _, err := rest.GetJSON[widget](ctx, client, ts.URL); err != nil {
+		t.Fatalf("GET should succeed after retries: %v", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected 3 GET attempts, got %d", n)
+	}
+
+	calls.Store(0)
+	_, err := rest.PostJSON[widget, widget](ctx, client, ts.URL, widget{})
+	if !errors.IsCode(err, errors.CodeInternal) {
+		t.Fatalf("expected INTERNAL for 502, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("POST without idempotency key must not retry, got %d attempts", n)
+	}
+
+	calls.Store(0)
+	var keys []string
+	var mu sync.Mutex
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		keys = append(keys, r.Header.Get(rest.IdempotencyKeyHeader)+" "+string(body))
+		mu.Unlock()
+		if calls.Add(1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	})
+	created, err := rest.PostJSON[widget, widget](ctx, client, ts.URL, widget{Name: "x"}, rest.WithIdempotencyKey("k-1"))
+	if err != nil || created.Name != "x" {
+		t.Fatalf("keyed POST = %+v, %v", created, err)
+	}
+	if len(keys) != 2 || keys[0] != keys[1] || keys[0] != `k-1 {"id":"","name":"x"}` {
+		t.Fatalf("expected two identical keyed attempts, got %q", keys)
+	}
+}
+
+func TestRetryAfterAndDeadline(t *testing.T) {
+	var calls atomic.Int32
+	var timeout atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout.Store(r.Header.Get(rest.TimeoutHeader))
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	client := newTestClient(t, nil)
+
+	start := time.Now()
+	if _, err := rest.GetJSON[widget](context.Background(), client, ts.URL); err != nil {
+		t.Fatalf("expected success after Retry-After, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("Retry-After not honoured, retried after %v", elapsed)
+	}
+
+	// A Retry-After past the deadline returns the 429 without waiting.
+	calls.Store(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err := rest.GetJSON[widget](ctx, client, ts.URL)
+	if !errors.IsCode(err, errors.CodeResourceExhausted) {
+		t.Fatalf("expected RESOURCE_EXHAUSTED, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("should not wait past the deadline, took %v", elapsed)
+	}
+	ms, _ := strconv.Atoi(timeout.Load().(string))
+	if ms <= 0 || ms > 300 {
+		t.Fatalf("expected remaining deadline in %s, got %q", rest.TimeoutHeader, timeout.Load())
+	}
+
+	// Retry-After beyond RetryAfterMax is not honoured.
+	calls.Store(0)
+	capped := newTestClient(t, func(cfg *rest.Config) { cfg.RetryAfterMax = 500 * time.Millisecond })
+	if _, err := rest.GetJSON[widget](context.Background(), capped, ts.URL); !errors.IsCode(err, errors.CodeResourceExhausted) {
+		t.Fatalf("expected RESOURCE_EXHAUSTED, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected a single attempt, got %d", n)
+	}
+}
+
+func TestHedgedRequests(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"fast"}`))
+	}))
+	defer ts.Close()
+	client := newTestClient(t, func(cfg *rest.Config) {
+		cfg.Timeout = 5 * time.Second
+		cfg.HedgeDelay = 20 * time.Millisecond
+	})
+
+	start := time.Now()
+	got, err := rest.GetJSON[widget](context.Background(), client, ts.URL)
+	if err != nil || got.ID != "fast" {
+		t.Fatalf("hedged GET = %+v, %v", got, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("hedge did not win, took %v", elapsed)
+	}
+
+	// Non-idempotent requests are never hedged.
+	calls.Store(1)
+	if _, err := rest.PostJSON[widget, widget](context.Background(), client, ts.URL, widget{}); err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected one POST attempt, got %d", n-1)
+	}
+}
+
+func TestHedgedCopiesCountAgainstRetries(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	client := newTestClient(t, func(cfg *rest.Config) {
+		cfg.Retries = 2
+		cfg.HedgeDelay = 20 * time.Millisecond
+	})
+
+	resp, err := client.Get(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected 1+Retries sends, got %d", n)
+	}
+}
+
+func TestNewSimpleRetriesLikeDo(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+	client := rest.NewSimple(rest.Config{Timeout: time.Second, Retries: 2, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond})
+
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" || calls.Load() != 2 {
+		t.Fatalf("GET = %q after %d calls", body, calls.Load())
+	}
+
+	// POST without an Idempotency-Key is sent once.
+	calls.Store(0)
+	resp, err = client.Post(ts.URL, "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway || calls.Load() != 1 {
+		t.Fatalf("POST status %d after %d calls", resp.StatusCode, calls.Load())
+	}
+}
@@ -0,0 +1,51 @@
+package graphql
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+)
+
+// DefaultPersistedQueryTTL is applied when HandlerConfig.PersistedQueryTTL
+// is 0.
+const DefaultPersistedQueryTTL = 24 * time.Hour
+
+const persistedQueryPrefix = "apq:"
+
+// persistedQueryCache adapts a cache.Cache to gqlgen's APQ cache so
+// persisted queries are shared by every replica behind a load balancer.
+type persistedQueryCache struct {
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+var _ graphql.Cache[string] = (*persistedQueryCache)(nil)
+
+// NewPersistedQueryCache returns an automatic persisted query store backed
+// by c. Entries expire after ttl; zero uses DefaultPersistedQueryTTL.
+func NewPersistedQueryCache(c cache.Cache, ttl time.Duration) graphql.Cache[string] {
+	if ttl <= 0 {
+		ttl = DefaultPersistedQueryTTL
+	}
+	return &persistedQueryCache{cache: c, ttl: ttl}
+}
+
+// Get implements graphql.Cache. Backend errors read as misses so clients
+// fall back to sending the full query.
+func (p *persistedQueryCache) Get(ctx context.Context, key string) (string, bool) {
+	var query string
+	if err := p.cache.Get(ctx, persistedQueryPrefix+key, &query); err != nil {
+		return "", false
+	}
+	return query, true
+}
+
+// Add implements graphql.Cache.
+func (p *persistedQueryCache) Add(ctx context.Context, key string, value string) {
+	if err := p.cache.Set(ctx, persistedQueryPrefix+key, value, p.ttl); err != nil {
+		logger.L().WarnContext(ctx, "failed to store persisted query", "hash", key, "error", err)
+	}
+}
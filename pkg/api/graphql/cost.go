@@ -0,0 +1,303 @@
+package graphql
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/middleware"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/ratelimit"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Error codes set on cost-analysis errors (extensions.code).
+const (
+	ErrCodeCostLimit    = "COST_LIMIT_EXCEEDED"
+	ErrCodeCostBudget   = "COST_BUDGET_EXHAUSTED"
+	costExtensionName   = "CostLimit"
+	defaultListSize     = 10
+	defaultBudgetPeriod = time.Minute
+)
+
+// DefaultListSizeArgs are the arguments whose value bounds a list field's
+// size, in order of preference.
+var DefaultListSizeArgs = []string{"first", "last", "limit", "pageSize"}
+
+// CostConfig enables field-level cost analysis.
+//
+// An operation's cost is the sum over its fields of the field's own cost
+// plus its children's cost times the list size for list fields. Fragments
+// on different types are all counted, so the result is an upper bound.
+type CostConfig struct {
+	// DefaultCost is the cost of a field without an override (default 1).
+	// __typename is always free.
+	DefaultCost int
+
+	// FieldCosts overrides costs by "Type.field", e.g. "Query.search": 10.
+	// A @cost(weight: Int) directive in the schema is honoured as well;
+	// FieldCosts wins when both are set.
+	FieldCosts map[string]int
+
+	// ListSizeArgs name the arguments bounding list sizes (default
+	// DefaultListSizeArgs). Integer literals and variables are read.
+	ListSizeArgs []string
+
+	// DefaultListSize is the multiplier for list fields without a size
+	// argument (default 10).
+	DefaultListSize int
+
+	// MaxCost rejects single operations costing more. Zero disables it.
+	MaxCost int
+
+	// Budget charges each operation's cost against a per-client allowance.
+	Budget *CostBudget
+}
+
+// CostBudget is a per-client cost allowance enforced with a pkg/api/ratelimit
+// Limiter: every operation consumes its cost in units, Limit units per
+// Period. The Limiter must implement ratelimit.CostLimiter, as every
+// built-in one does.
+type CostBudget struct {
+	Limiter ratelimit.Limiter
+	Limit   int64
+	// Period defaults to one minute.
+	Period time.Duration
+	// Key identifies the client (default middleware.KeyByIP).
+	Key middleware.KeyFunc
+}
+
+// CostStats is recorded on the operation context; see GetCostStats.
+type CostStats struct {
+	Cost int
+	// Remaining is the client's budget after this operation, or -1 without
+	// a budget.
+	Remaining int64
+}
+
+// GetCostStats returns the cost analysis of the current operation, if any.
+func GetCostStats(ctx context.Context) *CostStats {
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx == nil {
+		return nil
+	}
+	s, _ := opCtx.Stats.GetExtension(costExtensionName).(*CostStats)
+	return s
+}
+
+func (c CostConfig) withDefaults() CostConfig {
+	if c.DefaultCost <= 0 {
+		c.DefaultCost = 1
+	}
+	if len(c.ListSizeArgs) == 0 {
+		c.ListSizeArgs = DefaultListSizeArgs
+	}
+	if c.DefaultListSize <= 0 {
+		c.DefaultListSize = defaultListSize
+	}
+	if c.Budget != nil {
+		b := *c.Budget
+		if b.Period <= 0 {
+			b.Period = defaultBudgetPeriod
+		}
+		if b.Key == nil {
+			b.Key = middleware.KeyByIP
+		}
+		c.Budget = &b
+	}
+	return c
+}
+
+// CalculateCost returns the cost of op under cfg. op must come from a
+// validated document so fields carry their schema definitions.
+func CalculateCost(op *ast.OperationDefinition, vars map[string]any, cfg CostConfig) int {
+	cfg = cfg.withDefaults()
+	return cfg.selectionCost(op.SelectionSet, vars)
+}
+
+func (c CostConfig) selectionCost(set ast.SelectionSet, vars map[string]any) int {
+	total := 0
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *ast.Field:
+			total = addCost(total, c.fieldCost(s, vars))
+		case *ast.InlineFragment:
+			total = addCost(total, c.selectionCost(s.SelectionSet, vars))
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				total = addCost(total, c.selectionCost(s.Definition.SelectionSet, vars))
+			}
+		}
+	}
+	return total
+}
+
+func (c CostConfig) fieldCost(f *ast.Field, vars map[string]any) int {
+	if f.Name == "__typename" {
+		return 0
+	}
+	own := c.DefaultCost
+	if f.Definition != nil {
+		if w, ok := directiveWeight(f.Definition.Directives); ok {
+			own = w
+		}
+	}
+	if f.ObjectDefinition != nil {
+		if w, ok := c.FieldCosts[f.ObjectDefinition.Name+"."+f.Name]; ok {
+			own = w
+		}
+	}
+
+	children := c.selectionCost(f.SelectionSet, vars)
+	if f.Definition != nil && f.Definition.Type != nil && f.Definition.Type.Elem != nil {
+		children = mulCost(children, c.listSize(f, vars))
+	}
+	return addCost(own, children)
+}
+
+func (c CostConfig) listSize(f *ast.Field, vars map[string]any) int {
+	for _, name := range c.ListSizeArgs {
+		arg := f.Arguments.ForName(name)
+		if arg == nil || arg.Value == nil {
+			continue
+		}
+		v, err := arg.Value.Value(vars)
+		if err != nil {
+			continue
+		}
+		if n, ok := toInt(v); ok && n >= 0 {
+			return n
+		}
+	}
+	return c.DefaultListSize
+}
+
+func directiveWeight(dirs ast.DirectiveList) (int, bool) {
+	d := dirs.ForName("cost")
+	if d == nil {
+		return 0, false
+	}
+	arg := d.Arguments.ForName("weight")
+	if arg == nil || arg.Value == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(arg.Value.Raw)
+	return n, err == nil
+}
+
+func toInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func addCost(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+func mulCost(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
+
+// costLimit is the gqlgen extension enforcing CostConfig.
+type costLimit struct {
+	cfg CostConfig
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = costLimit{}
+
+func (costLimit) ExtensionName() string {
+	return costExtensionName
+}
+
+// Validate rejects a budget whose limiter cannot charge a cost atomically.
+func (c costLimit) Validate(graphql.ExecutableSchema) error {
+	if b := c.cfg.Budget; b != nil && b.Limiter != nil {
+		if _, ok := b.Limiter.(ratelimit.CostLimiter); !ok {
+			return errors.InvalidArgument("graphql cost budget limiter must implement ratelimit.CostLimiter", nil)
+		}
+	}
+	return nil
+}
+
+func (c costLimit) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	op := opCtx.Doc.Operations.ForName(opCtx.OperationName)
+	if op == nil {
+		return nil
+	}
+	cost := c.cfg.selectionCost(op.SelectionSet, opCtx.Variables)
+	stats := &CostStats{Cost: cost, Remaining: -1}
+	opCtx.Stats.SetExtension(costExtensionName, stats)
+
+	if c.cfg.MaxCost > 0 && cost > c.cfg.MaxCost {
+		err := gqlerror.Errorf("operation has cost %d, which exceeds the limit of %d", cost, c.cfg.MaxCost)
+		errcode.Set(err, ErrCodeCostLimit)
+		err.Extensions["cost"] = cost
+		return err
+	}
+
+	b := c.cfg.Budget
+	if b == nil || b.Limiter == nil || cost == 0 {
+		return nil
+	}
+	key := "graphql-cost:anonymous"
+	if r := requestFromContext(ctx); r != nil {
+		key = "graphql-cost:" + b.Key(r)
+	}
+	res, err := ratelimit.AllowN(ctx, b.Limiter, key, int64(cost), b.Limit, b.Period)
+	if err != nil {
+		// Fail open for limiter backend errors, like the HTTP middleware.
+		logger.L().WarnContext(ctx, "graphql cost budget check failed", "key", key, "error", err)
+		return nil
+	}
+	stats.Remaining = res.Remaining
+	if !res.Allowed {
+		err := gqlerror.Errorf("operation cost %d exceeds the remaining budget of %d", cost, res.Remaining)
+		errcode.Set(err, ErrCodeCostBudget)
+		err.Extensions["cost"] = cost
+		err.Extensions["retryAfter"] = int(math.Ceil(res.Reset.Seconds()))
+		return err
+	}
+	return nil
+}
+
+type requestCtxKey struct{}
+
+// withRequest makes the HTTP request available to extensions such as the
+// cost budget's key function.
+func withRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestCtxKey{}, r)))
+	})
+}
+
+func requestFromContext(ctx context.Context) *http.Request {
+	r, _ := ctx.Value(requestCtxKey{}).(*http.Request)
+	return r
+}
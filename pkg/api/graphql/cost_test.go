@@ -0,0 +1,138 @@
+package graphql_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/algorithms/ratelimit/fixedwindow"
+	apigraphql "github.com/chris-alexander-pop/go-hyperforge/pkg/api/graphql"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/ratelimit"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache/adapters/memory"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var costSchema = gqlparser.MustLoadSchema(&ast.Source{Input: `
+	directive @cost(weight: Int!) on FIELD_DEFINITION
+
+	type Query {
+		name: String!
+		search: String @cost(weight: 5)
+		items(first: Int): [Item!]!
+	}
+
+	type Item {
+		id: ID!
+		tags(limit: Int): [String!]!
+	}
+`})
+
+func costOf(t *testing.T, query string, vars map[string]any, cfg apigraphql.CostConfig) int {
+	t.Helper()
+	doc := gqlparser.MustLoadQuery(costSchema, query)
+	return apigraphql.CalculateCost(doc.Operations[0], vars, cfg)
+}
+
+func TestCalculateCost(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		vars  map[string]any
+		cfg   apigraphql.CostConfig
+		want  int
+	}{
+		{"scalar", `{ name __typename }`, nil, apigraphql.CostConfig{}, 1},
+		{"list literal", `{ items(first: 3) { id tags(limit: 2) } }`, nil, apigraphql.CostConfig{}, 7},
+		{"list variable", `query($n: Int) { items(first: $n) { id tags } }`, map[string]any{"n": 5}, apigraphql.CostConfig{}, 11},
+		{"default list size", `{ items { id tags } }`, nil, apigraphql.CostConfig{}, 21},
+		{"field override", `{ items(first: 3) { id tags } }`, nil, apigraphql.CostConfig{FieldCosts: map[string]int{"Item.id": 4}}, 16},
+		{"directive", `{ search }`, nil, apigraphql.CostConfig{}, 5},
+		{"override beats directive", `{ search }`, nil, apigraphql.CostConfig{FieldCosts: map[string]int{"Query.search": 2}}, 2},
+		{"fragment", `{ items(first: 2) { ...F } } fragment F on Item { id }`, nil, apigraphql.CostConfig{}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := costOf(t, tt.query, tt.vars, tt.cfg); got != tt.want {
+				t.Fatalf("cost=%d want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func costHandler(cfg *apigraphql.CostConfig) http.Handler {
+	es := &graphql.ExecutableSchemaMock{
+		SchemaFunc: func() *ast.Schema { return costSchema },
+		ComplexityFunc: func(ctx context.Context, typeName, fieldName string, childComplexity int, args map[string]any) (int, bool) {
+			return childComplexity + 1, true
+		},
+		ExecFunc: func(ctx context.Context) graphql.ResponseHandler {
+			return graphql.OneShot(&graphql.Response{Data: []byte(`{"items":[]}`)})
+		},
+	}
+	return apigraphql.NewHandlerWithConfig(es, apigraphql.HandlerConfig{
+		ComplexityLimit: -1,
+		DepthLimit:      -1,
+		DisableOTel:     true,
+		Cost:            cfg,
+	})
+}
+
+func postQuery(t *testing.T, h http.Handler, body string) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Body.String()
+}
+
+func TestCostLimitRejectsExpensiveOperation(t *testing.T) {
+	h := costHandler(&apigraphql.CostConfig{MaxCost: 5})
+
+	if body := postQuery(t, h, `{"query":"{ name }"}`); strings.Contains(body, "errors") {
+		t.Fatalf("cheap query rejected: %s", body)
+	}
+	body := postQuery(t, h, `{"query":"{ items(first: 3) { id tags } }"}`)
+	if !strings.Contains(body, apigraphql.ErrCodeCostLimit) {
+		t.Fatalf("expected %s, body=%s", apigraphql.ErrCodeCostLimit, body)
+	}
+}
+
+func TestCostBudgetPerClient(t *testing.T) {
+	h := costHandler(&apigraphql.CostConfig{
+		Budget: &apigraphql.CostBudget{
+			Limiter: fixedwindow.New(memory.New()),
+			Limit:   10,
+		},
+	})
+	query := `{"query":"{ items(first: 3) { id tags } }"}` // cost 7
+
+	if body := postQuery(t, h, query); strings.Contains(body, "errors") {
+		t.Fatalf("first operation rejected: %s", body)
+	}
+	body := postQuery(t, h, query)
+	if !strings.Contains(body, apigraphql.ErrCodeCostBudget) || !strings.Contains(body, "retryAfter") {
+		t.Fatalf("expected %s, body=%s", apigraphql.ErrCodeCostBudget, body)
+	}
+	if body := postQuery(t, h, `{"query":"{ name }"}`); strings.Contains(body, "errors") {
+		t.Fatalf("operation within remaining budget rejected: %s", body)
+	}
+}
+
+func TestCostBudgetRequiresCostLimiter(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a limiter without AllowN to be rejected")
+		}
+	}()
+	costHandler(&apigraphql.CostConfig{
+		Budget: &apigraphql.CostBudget{Limiter: allowOnly{fixedwindow.New(memory.New())}, Limit: 10},
+	})
+}
+
+// allowOnly hides AllowN from the wrapped limiter.
+type allowOnly struct{ ratelimit.Limiter }
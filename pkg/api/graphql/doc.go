@@ -7,6 +7,19 @@
 //   - EnableIntrospection: true (disable in production)
 //   - OTel: operation spans on tracer "pkg/api/graphql"
 //
+// Subscriptions are served over websockets speaking both graphql-ws and
+// graphql-transport-ws. Subscribe turns an events.Bus topic into the channel
+// a subscription resolver returns; HandlerConfig.WebsocketInit authenticates
+// connection_init payloads and AllowedOrigins relaxes the same-origin check.
+//
+// Automatic persisted queries use a per-process LRU unless
+// HandlerConfig.PersistedQueries supplies a shared cache.Cache.
+//
+// HandlerConfig.Cost enables field-level cost analysis: fields cost 1 (or a
+// FieldCosts / @cost override), list fields multiply their children by a
+// first/last/limit argument, MaxCost rejects single operations and Budget
+// charges each client's operations against a pkg/api/ratelimit Limiter.
+//
 // DX helpers:
 //   - SchemaRegistry / LoadSDL / LoadSDLFile for SDL loading
 //   - NewPlaygroundHandlerWithConfig for GraphiQL title/headers/explorer options
@@ -26,6 +39,19 @@
 //		EnableIntrospection: false,
 //	})
 //
+//	// Shared APQ and cost budgets of 1000 units per minute per client:
+//	h = graphql.NewHandlerWithConfig(schema, graphql.HandlerConfig{
+//		PersistedQueries: redisCache,
+//		Cost: &graphql.CostConfig{
+//			MaxCost:    500,
+//			FieldCosts: map[string]int{"Query.search": 10},
+//			Budget: &graphql.CostBudget{
+//				Limiter: ratelimit.New(redisCache, ratelimit.StrategyTokenBucket),
+//				Limit:   1000,
+//			},
+//		},
+//	})
+//
 // Or mount via the unified factory:
 //
 //	api.New(api.Config{Protocol: api.ProtocolGraphQL, GraphQLSchema: schema, Port: "8080"})
@@ -13,9 +13,11 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+	"github.com/gorilla/websocket"
 	"github.com/vektah/gqlparser/v2"
 	"github.com/vektah/gqlparser/v2/ast"
 	"go.opentelemetry.io/otel"
@@ -30,6 +32,8 @@ const DefaultComplexityLimit = 200
 // DefaultDepthLimit is applied when HandlerConfig.DepthLimit is 0.
 const DefaultDepthLimit = 15
 
+const defaultWebsocketKeepAlive = 10 * time.Second
+
 // HandlerConfig tunes GraphQL request guards and observability.
 type HandlerConfig struct {
 	// ComplexityLimit caps query complexity (gqlgen FixedComplexityLimit).
@@ -46,6 +50,30 @@ type HandlerConfig struct {
 	// EnableIntrospection enables GraphQL introspection (__schema / __type).
 	// Defaults to true when unset via DefaultHandlerConfig; set false in production.
 	EnableIntrospection bool
+
+	// WebsocketInit validates the connection_init payload of subscription
+	// websockets (graphql-ws and graphql-transport-ws), e.g. to authenticate
+	// a token. The returned context is used for the connection's operations.
+	WebsocketInit transport.WebsocketInitFunc
+
+	// WebsocketKeepAlive is the keep-alive ping interval for subscription
+	// websockets (default 10s).
+	WebsocketKeepAlive time.Duration
+
+	// AllowedOrigins is the Origin allowlist for subscription websockets.
+	// Use "*" to allow any origin. Empty keeps the same-origin check.
+	AllowedOrigins []string
+
+	// PersistedQueries stores automatic persisted queries in a shared cache
+	// so every replica recognises a hash registered with any of them.
+	// Nil keeps a per-process LRU.
+	PersistedQueries cache.Cache
+
+	// PersistedQueryTTL expires shared persisted queries (default 24h).
+	PersistedQueryTTL time.Duration
+
+	// Cost enables field-level cost analysis and per-client cost budgets.
+	Cost *CostConfig
 }
 
 // DefaultHandlerConfig returns conservative production defaults.
@@ -68,7 +96,15 @@ func NewHandler(schema graphql.ExecutableSchema) http.Handler {
 func NewHandlerWithConfig(schema graphql.ExecutableSchema, cfg HandlerConfig) http.Handler {
 	srv := handler.New(schema)
 
-	srv.AddTransport(transport.Websocket{KeepAlivePingInterval: 10 * time.Second})
+	keepAlive := cfg.WebsocketKeepAlive
+	if keepAlive <= 0 {
+		keepAlive = defaultWebsocketKeepAlive
+	}
+	srv.AddTransport(transport.Websocket{
+		Upgrader:              websocketUpgrader(cfg.AllowedOrigins),
+		InitFunc:              cfg.WebsocketInit,
+		KeepAlivePingInterval: keepAlive,
+	})
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
@@ -78,9 +114,11 @@ func NewHandlerWithConfig(schema graphql.ExecutableSchema, cfg HandlerConfig) ht
 	if cfg.EnableIntrospection {
 		srv.Use(extension.Introspection{})
 	}
-	srv.Use(extension.AutomaticPersistedQuery{
-		Cache: lru.New[string](100),
-	})
+	var apqCache graphql.Cache[string] = lru.New[string](100)
+	if cfg.PersistedQueries != nil {
+		apqCache = NewPersistedQueryCache(cfg.PersistedQueries, cfg.PersistedQueryTTL)
+	}
+	srv.Use(extension.AutomaticPersistedQuery{Cache: apqCache})
 
 	complexityLimit := cfg.ComplexityLimit
 	if complexityLimit == 0 {
@@ -90,6 +128,10 @@ func NewHandlerWithConfig(schema graphql.ExecutableSchema, cfg HandlerConfig) ht
 		srv.Use(extension.FixedComplexityLimit(complexityLimit))
 	}
 
+	if cfg.Cost != nil {
+		srv.Use(costLimit{cfg: cfg.Cost.withDefaults()})
+	}
+
 	depthLimit := cfg.DepthLimit
 	if depthLimit == 0 {
 		depthLimit = DefaultDepthLimit
@@ -135,9 +177,42 @@ func NewHandlerWithConfig(schema graphql.ExecutableSchema, cfg HandlerConfig) ht
 		}
 	})
 
+	if cfg.Cost != nil && cfg.Cost.Budget != nil {
+		return withRequest(srv)
+	}
 	return srv
 }
 
+// websocketUpgrader applies the origin allowlist; an empty list keeps
+// gorilla's same-origin default.
+func websocketUpgrader(origins []string) websocket.Upgrader {
+	if len(origins) == 0 {
+		return websocket.Upgrader{}
+	}
+	allowAll := false
+	allowed := make(map[string]struct{}, len(origins))
+	for _, o := range origins {
+		if o == "*" {
+			allowAll = true
+		}
+		allowed[o] = struct{}{}
+	}
+	return websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			if allowAll {
+				return true
+			}
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				// Non-browser clients typically omit Origin.
+				return true
+			}
+			_, ok := allowed[origin]
+			return ok
+		},
+	}
+}
+
 // fieldDepth counts nested object fields (list indices do not add depth).
 func fieldDepth(fc *graphql.FieldContext) int {
 	depth := 0
@@ -0,0 +1,88 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/events"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+)
+
+// SubscriptionBuffer is the number of events a subscription channel holds
+// before further events are dropped for that subscriber.
+const SubscriptionBuffer = 16
+
+// Subscribe streams events published to topic on bus as a channel suitable
+// for a gqlgen subscription resolver. convert maps each event to the
+// resolver's type; returning false skips the event (filtering by arguments,
+// for instance). The subscription ends and the channel closes when ctx is
+// done, which gqlgen does when the client stops the operation or
+// disconnects.
+//
+// Slow clients do not block publishers: events arriving while the buffer
+// is full are dropped and logged.
+//
+//	func (r *subscriptionResolver) OrderUpdated(ctx context.Context, id string) (<-chan *model.Order, error) {
+//		return graphql.Subscribe(ctx, r.bus, "orders", func(e events.Event) (*model.Order, bool) {
+//			o, err := graphql.DecodePayload[*model.Order](e)
+//			return o, err == nil && o.ID == id
+//		})
+//	}
+func Subscribe[T any](ctx context.Context, bus events.Bus, topic string, convert func(events.Event) (T, bool)) (<-chan T, error) {
+	ch := make(chan T, SubscriptionBuffer)
+	mu := concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "graphql-subscription-" + topic})
+	closed := false
+
+	sub, err := bus.Subscribe(ctx, topic, func(hctx context.Context, event events.Event) error {
+		v, ok := convert(event)
+		if !ok {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return nil
+		}
+		select {
+		case ch <- v:
+		default:
+			logger.L().WarnContext(hctx, "graphql subscription buffer full, dropping event", "topic", topic, "id", event.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to subscribe to "+topic)
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := bus.Unsubscribe(context.Background(), sub); err != nil {
+			logger.L().WarnContext(ctx, "failed to unsubscribe graphql subscription", "topic", topic, "error", err)
+		}
+		mu.Lock()
+		closed = true
+		close(ch)
+		mu.Unlock()
+	}()
+	return ch, nil
+}
+
+// DecodePayload returns an event's payload as T. Payloads published
+// in-process are returned as-is; payloads that went through a durable bus
+// arrive as decoded JSON and are converted with a JSON round trip.
+func DecodePayload[T any](event events.Event) (T, error) {
+	if v, ok := event.Payload.(T); ok {
+		return v, nil
+	}
+	var out T
+	raw, err := json.Marshal(event.Payload)
+	if err != nil {
+		return out, errors.InvalidArgument("failed to encode event payload", err)
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return out, errors.InvalidArgument("event payload does not match subscription type", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package graphql_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	apigraphql "github.com/chris-alexander-pop/go-hyperforge/pkg/api/graphql"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/events"
+	membus "github.com/chris-alexander-pop/go-hyperforge/pkg/events/adapters/memory"
+)
+
+type order struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func TestSubscribeStreamsBusEvents(t *testing.T) {
+	bus := membus.New(events.Config{})
+	defer bus.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch, err := apigraphql.Subscribe(ctx, bus, "orders", func(e events.Event) (order, bool) {
+		o, err := apigraphql.DecodePayload[order](e)
+		return o, err == nil && o.ID == "1"
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	publish := func(payload any) {
+		if err := bus.Publish(ctx, "orders", events.Event{Type: "order.updated", Payload: payload}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	publish(order{ID: "2", Status: "paid"})
+	publish(map[string]any{"id": "1", "status": "shipped"}) // as decoded from a durable bus
+
+	select {
+	case o := <-ch:
+		if o.ID != "1" || o.Status != "shipped" {
+			t.Fatalf("got %+v", o)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event delivered")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("unexpected event after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+}
+
+func TestPersistedQueriesSharedAcrossHandlers(t *testing.T) {
+	cfg := apigraphql.HandlerConfig{
+		ComplexityLimit:  -1,
+		DepthLimit:       -1,
+		DisableOTel:      true,
+		PersistedQueries: memory.New(),
+	}
+	// Two replicas sharing one cache.
+	a := apigraphql.NewHandlerWithConfig(testSchema(1), cfg)
+	b := apigraphql.NewHandlerWithConfig(testSchema(1), cfg)
+
+	sum := sha256.Sum256([]byte("{ name }"))
+	ext := `"extensions":{"persistedQuery":{"version":1,"sha256Hash":"` + hex.EncodeToString(sum[:]) + `"}}`
+
+	if body := postQuery(t, b, `{`+ext+`}`); !strings.Contains(body, "PersistedQueryNotFound") {
+		t.Fatalf("expected miss before registration, body=%s", body)
+	}
+	if body := postQuery(t, a, `{"query":"{ name }",`+ext+`}`); strings.Contains(body, "errors") {
+		t.Fatalf("register: %s", body)
+	}
+	if body := postQuery(t, b, `{`+ext+`}`); strings.Contains(body, "errors") || !strings.Contains(body, "test") {
+		t.Fatalf("expected hit on other handler, body=%s", body)
+	}
+}
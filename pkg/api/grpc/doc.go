@@ -1,7 +1,18 @@
 // Package grpc provides a gRPC server with OpenTelemetry, unary/stream recovery,
 // AppError→gRPC status mapping (via pkg/errors.GRPCStatus) for unary and stream RPCs,
-// AuthInterceptor / StreamAuthInterceptor (bearer metadata), reflection, and
-// standard health checking (grpc.health.v1).
+// AuthInterceptor / StreamAuthInterceptor (bearer metadata), reflection (disable with
+// GRPC_DISABLE_REFLECTION), and standard health checking (grpc.health.v1).
+//
+// Interceptors matching the REST middleware stack are passed to New as server options:
+//   - TelemetryInterceptor: grpc.server.requests / grpc.server.duration metrics and spans
+//   - RateLimitInterceptor: pkg/api/ratelimit limiters keyed by peer, subject or method
+//   - CircuitBreakerInterceptor: pkg/resilience breakers tripped by server-side faults
+//   - ValidationInterceptor: Validate() methods or pkg/validator struct tags
+//   - DeadlineInterceptor: default and maximum deadlines (GRPC_DEFAULT_TIMEOUT, GRPC_MAX_TIMEOUT)
+//
+// Each has a Stream* counterpart. Gateway exposes unary RPCs as JSON routes on a
+// rest.Server, configured explicitly or from google.api.http annotations, over
+// Server.InProcessConn or any client connection.
 //
 // Remaining gaps (not yet provided by this package):
 //   - Per-method authorization / RBAC interceptors
@@ -0,0 +1,427 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/middleware"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/rest"
+	pkgerrors "github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	// MetadataHeaderPrefix marks HTTP headers the Gateway forwards as gRPC
+	// metadata, without the prefix.
+	MetadataHeaderPrefix = "Grpc-Metadata-"
+
+	// timeoutHeader is the REST client's deadline header (milliseconds).
+	timeoutHeader = "X-Request-Timeout"
+
+	// forwardedForKey carries the HTTP client's IP to the RPC.
+	forwardedForKey = "x-forwarded-for"
+)
+
+// forwardedHeaders are passed to the RPC as metadata under their
+// lower-cased names.
+var forwardedHeaders = []string{"Authorization", "X-Request-Id", "X-Api-Key"}
+
+// GatewayRoute maps an HTTP route onto a unary RPC.
+type GatewayRoute struct {
+	// Method is the HTTP method, e.g. http.MethodGet.
+	Method string
+	// Path is an Echo path; ":name" parameters set the request field name
+	// (dotted paths such as ":user.id" reach nested messages).
+	Path string
+	// RPC is the full method name, e.g. "/library.v1.Library/GetBook".
+	RPC string
+	// Body names the request field the JSON body decodes into: "*" for the
+	// whole request, a field name, or empty for none. Query parameters set
+	// the remaining fields unless Body is "*".
+	Body string
+}
+
+// Gateway exposes unary RPCs as JSON endpoints, in the manner of
+// grpc-gateway but driven by registered protobuf descriptors instead of
+// generated code. Calls go through conn, so a conn from
+// Server.InProcessConn runs the server's full interceptor chain and one
+// service implementation serves both protocols.
+type Gateway struct {
+	conn      grpc.ClientConnInterface
+	marshal   protojson.MarshalOptions
+	unmarshal protojson.UnmarshalOptions
+}
+
+// NewGateway creates a gateway invoking RPCs on conn.
+func NewGateway(conn grpc.ClientConnInterface) *Gateway {
+	return &Gateway{
+		conn:      conn,
+		marshal:   protojson.MarshalOptions{},
+		unmarshal: protojson.UnmarshalOptions{DiscardUnknown: true},
+	}
+}
+
+// Register mounts routes on srv.
+func (g *Gateway) Register(srv *rest.Server, routes ...GatewayRoute) error {
+	for _, r := range routes {
+		md, err := findMethod(r.RPC)
+		if err != nil {
+			return err
+		}
+		if md.IsStreamingClient() || md.IsStreamingServer() {
+			return pkgerrors.InvalidArgument("gateway supports unary methods only: "+r.RPC, nil)
+		}
+		srv.Echo().Add(r.Method, r.Path, g.handler(r, md))
+	}
+	return nil
+}
+
+// RegisterService mounts every method of the named service (e.g.
+// "library.v1.Library") that carries a google.api.http annotation.
+func (g *Gateway) RegisterService(srv *rest.Server, service string) error {
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return pkgerrors.NotFound("service "+service+" is not registered", err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return pkgerrors.InvalidArgument(service+" is not a service", nil)
+	}
+	routes, err := RoutesFromAnnotations(sd)
+	if err != nil {
+		return err
+	}
+	return g.Register(srv, routes...)
+}
+
+// RoutesFromAnnotations derives routes from the google.api.http options of
+// sd's methods. Path templates may use {field} and {field=*} variables;
+// multi-segment variables and custom verbs are rejected.
+func RoutesFromAnnotations(sd protoreflect.ServiceDescriptor) ([]GatewayRoute, error) {
+	var routes []GatewayRoute
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		opts := md.Options()
+		if opts == nil || !proto.HasExtension(opts, annotations.E_Http) {
+			continue
+		}
+		rule, _ := proto.GetExtension(opts, annotations.E_Http).(*annotations.HttpRule)
+		rpc := "/" + string(sd.FullName()) + "/" + string(md.Name())
+		for _, r := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+			route, err := routeFromRule(rpc, r)
+			if err != nil {
+				return nil, err
+			}
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+func routeFromRule(rpc string, rule *annotations.HttpRule) (GatewayRoute, error) {
+	var method, tmpl string
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		method, tmpl = http.MethodGet, p.Get
+	case *annotations.HttpRule_Post:
+		method, tmpl = http.MethodPost, p.Post
+	case *annotations.HttpRule_Put:
+		method, tmpl = http.MethodPut, p.Put
+	case *annotations.HttpRule_Patch:
+		method, tmpl = http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Delete:
+		method, tmpl = http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Custom:
+		method, tmpl = p.Custom.GetKind(), p.Custom.GetPath()
+	default:
+		return GatewayRoute{}, pkgerrors.InvalidArgument(rpc+": http rule has no pattern", nil)
+	}
+	path, err := echoPath(tmpl)
+	if err != nil {
+		return GatewayRoute{}, pkgerrors.Wrap(err, rpc)
+	}
+	return GatewayRoute{Method: method, Path: path, RPC: rpc, Body: rule.GetBody()}, nil
+}
+
+// echoPath converts "/v1/{name}/items/{item.id=*}" to "/v1/:name/items/:item.id".
+func echoPath(tmpl string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		switch c {
+		case '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end < 0 {
+				return "", pkgerrors.InvalidArgument(fmt.Sprintf("path template %q: unterminated variable", tmpl), nil)
+			}
+			field, pattern, hasPattern := strings.Cut(tmpl[i+1:i+end], "=")
+			if hasPattern && pattern != "*" {
+				return "", pkgerrors.InvalidArgument(fmt.Sprintf("path template %q: only single-segment variables are supported", tmpl), nil)
+			}
+			b.WriteString(":" + field)
+			i += end
+		case ':':
+			return "", pkgerrors.InvalidArgument(fmt.Sprintf("path template %q: custom verbs are not supported", tmpl), nil)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+func findMethod(rpc string) (protoreflect.MethodDescriptor, error) {
+	name := strings.Replace(strings.TrimPrefix(rpc, "/"), "/", ".", 1)
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, pkgerrors.NotFound("method "+rpc+" is not registered", err)
+	}
+	md, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, pkgerrors.InvalidArgument(rpc+" is not a method", nil)
+	}
+	return md, nil
+}
+
+func newMessage(d protoreflect.MessageDescriptor) proto.Message {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(d.FullName()); err == nil {
+		return mt.New().Interface()
+	}
+	return dynamicpb.NewMessage(d)
+}
+
+func (g *Gateway) handler(route GatewayRoute, md protoreflect.MethodDescriptor) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := newMessage(md.Input())
+		if err := g.bind(c, route, req); err != nil {
+			return err
+		}
+
+		ctx, cancel := gatewayContext(c.Request(), middleware.KeyByIP(c.Request()))
+		defer cancel()
+		var header metadata.MD
+		resp := newMessage(md.Output())
+		if err := g.conn.Invoke(ctx, route.RPC, req, resp, grpc.Header(&header)); err != nil {
+			st := status.Convert(err)
+			return pkgerrors.FromGRPC(st.Code(), st.Message())
+		}
+
+		for _, k := range []string{"x-ratelimit-limit", "x-ratelimit-remaining", "x-ratelimit-reset"} {
+			if v := header.Get(k); len(v) > 0 {
+				c.Response().Header().Set(k, v[0])
+			}
+		}
+		data, err := g.marshal.Marshal(resp)
+		if err != nil {
+			return pkgerrors.Internal("failed to encode response", err)
+		}
+		return c.JSONBlob(http.StatusOK, data)
+	}
+}
+
+// gatewayContext carries forwarded headers and the client IP as outgoing
+// metadata and turns X-Request-Timeout into a deadline. The client IP is
+// the connection's address, as middleware.KeyByIP sees it, not a
+// client-supplied X-Forwarded-For.
+func gatewayContext(r *http.Request, clientIP string) (context.Context, context.CancelFunc) {
+	md := metadata.MD{}
+	for _, h := range forwardedHeaders {
+		if v := r.Header.Get(h); v != "" {
+			md.Set(strings.ToLower(h), v)
+		}
+	}
+	for k, vs := range r.Header {
+		if strings.HasPrefix(k, MetadataHeaderPrefix) {
+			md.Append(strings.ToLower(strings.TrimPrefix(k, MetadataHeaderPrefix)), vs...)
+		}
+	}
+	// Set last so a Grpc-Metadata-X-Forwarded-For header cannot spoof it.
+	md.Set(forwardedForKey, clientIP)
+	ctx := metadata.NewOutgoingContext(r.Context(), md)
+	if ms, err := strconv.ParseInt(r.Header.Get(timeoutHeader), 10, 64); err == nil && ms > 0 {
+		return context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+	}
+	return ctx, func() {}
+}
+
+// bind fills req from the body, path parameters and query string.
+func (g *Gateway) bind(c echo.Context, route GatewayRoute, req proto.Message) error {
+	if route.Body != "" {
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return pkgerrors.InvalidArgument("failed to read request body", err)
+		}
+		if len(body) > 0 {
+			target := req
+			if route.Body != "*" {
+				fd, parent, err := resolveField(req.ProtoReflect(), route.Body)
+				if err != nil {
+					return err
+				}
+				if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+					return pkgerrors.InvalidArgument("body field "+route.Body+" must be a message", nil)
+				}
+				target = parent.Mutable(fd).Message().Interface()
+			}
+			if err := g.unmarshal.Unmarshal(body, target); err != nil {
+				return pkgerrors.InvalidArgument("invalid request body", err)
+			}
+		}
+	}
+
+	for i, name := range c.ParamNames() {
+		if err := setField(req.ProtoReflect(), name, []string{c.ParamValues()[i]}); err != nil {
+			return err
+		}
+	}
+
+	if route.Body != "*" {
+		for key, values := range c.QueryParams() {
+			if err := setField(req.ProtoReflect(), key, values); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveField walks a dotted field path, creating intermediate messages,
+// and returns the last field with the message holding it. Segments match
+// proto or JSON field names.
+func resolveField(msg protoreflect.Message, path string) (protoreflect.FieldDescriptor, protoreflect.Message, error) {
+	segs := strings.Split(path, ".")
+	for i, seg := range segs {
+		fields := msg.Descriptor().Fields()
+		fd := fields.ByName(protoreflect.Name(seg))
+		if fd == nil {
+			fd = fields.ByJSONName(seg)
+		}
+		if fd == nil {
+			return nil, nil, pkgerrors.InvalidArgument(fmt.Sprintf("unknown field %q", path), nil)
+		}
+		if i == len(segs)-1 {
+			return fd, msg, nil
+		}
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return nil, nil, pkgerrors.InvalidArgument(fmt.Sprintf("field %q is not a message", path), nil)
+		}
+		msg = msg.Mutable(fd).Message()
+	}
+	return nil, nil, pkgerrors.InvalidArgument("empty field path", nil)
+}
+
+// setField assigns string values from a path or query parameter.
+func setField(msg protoreflect.Message, path string, values []string) error {
+	fd, parent, err := resolveField(msg, path)
+	if err != nil {
+		return err
+	}
+	if fd.IsMap() || fd.Message() != nil {
+		return pkgerrors.InvalidArgument(fmt.Sprintf("field %q cannot be set from a parameter", path), nil)
+	}
+	if fd.IsList() {
+		list := parent.Mutable(fd).List()
+		for _, v := range values {
+			pv, err := scalarValue(fd, v)
+			if err != nil {
+				return err
+			}
+			list.Append(pv)
+		}
+		return nil
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	pv, err := scalarValue(fd, values[0])
+	if err != nil {
+		return err
+	}
+	parent.Set(fd, pv)
+	return nil
+}
+
+func scalarValue(fd protoreflect.FieldDescriptor, v string) (protoreflect.Value, error) {
+	bad := func(err error) (protoreflect.Value, error) {
+		return protoreflect.Value{}, pkgerrors.InvalidArgument(fmt.Sprintf("invalid value %q for field %s", v, fd.Name()), err)
+	}
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(v), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return bad(err)
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return bad(err)
+		}
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return bad(err)
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return bad(err)
+		}
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return bad(err)
+		}
+		return protoreflect.ValueOfUint64(n), nil
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return bad(err)
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return bad(err)
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			if b, err = base64.URLEncoding.DecodeString(v); err != nil {
+				return bad(err)
+			}
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(v)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return bad(err)
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	}
+	return bad(nil)
+}
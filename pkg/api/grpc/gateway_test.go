@@ -0,0 +1,146 @@
+package grpc_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/algorithms/ratelimit/fixedwindow"
+	apigrpc "github.com/chris-alexander-pop/go-hyperforge/pkg/api/grpc"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/rest"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache/adapters/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestGatewayExposesUnaryRPCsOverREST(t *testing.T) {
+	g := apigrpc.New(apigrpc.Config{Port: "0"},
+		grpc.ChainUnaryInterceptor(apigrpc.RateLimitInterceptor(fixedwindow.New(memory.New()), 100, time.Minute, nil)))
+	g.Health().SetServingStatus("orders", grpc_health_v1.HealthCheckResponse_SERVING)
+	t.Cleanup(g.Stop)
+
+	conn, err := g.InProcessConn()
+	require.NoError(t, err)
+
+	r := rest.New(rest.Config{Port: "0"})
+	gw := apigrpc.NewGateway(conn)
+	require.NoError(t, gw.Register(r,
+		apigrpc.GatewayRoute{Method: http.MethodGet, Path: "/v1/health/:service", RPC: "/grpc.health.v1.Health/Check"},
+		apigrpc.GatewayRoute{Method: http.MethodGet, Path: "/v1/health", RPC: "/grpc.health.v1.Health/Check"},
+		apigrpc.GatewayRoute{Method: http.MethodPost, Path: "/v1/health/check", RPC: "/grpc.health.v1.Health/Check", Body: "*"},
+	))
+	ts := httptest.NewServer(r.Echo())
+	t.Cleanup(ts.Close)
+
+	get := func(path string) (int, map[string]interface{}, http.Header) {
+		res, err := http.Get(ts.URL + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var out map[string]interface{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&out))
+		return res.StatusCode, out, res.Header
+	}
+
+	code, out, header := get("/v1/health/orders")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "SERVING", out["status"])
+	assert.Equal(t, "100", header.Get("X-Ratelimit-Limit"))
+
+	code, out, _ = get("/v1/health?service=orders")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "SERVING", out["status"])
+
+	code, out, _ = get("/v1/health/unknown")
+	assert.Equal(t, http.StatusNotFound, code, "body=%v", out)
+
+	code, _, _ = get("/v1/health?bogus=1")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	res, err := http.Post(ts.URL+"/v1/health/check", "application/json", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestGatewayRejectsStreamingMethods(t *testing.T) {
+	gw := apigrpc.NewGateway(nil)
+	err := gw.Register(rest.New(rest.Config{Port: "0"}),
+		apigrpc.GatewayRoute{Method: http.MethodGet, Path: "/watch", RPC: "/grpc.health.v1.Health/Watch"})
+	assert.Error(t, err)
+}
+
+func TestRoutesFromAnnotations(t *testing.T) {
+	opts := &descriptorpb.MethodOptions{}
+	proto.SetExtension(opts, annotations.E_Http, &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Get{Get: "/v1/books/{book.id=*}"},
+		AdditionalBindings: []*annotations.HttpRule{{
+			Pattern: &annotations.HttpRule_Post{Post: "/v1/books"},
+			Body:    "book",
+		}},
+	})
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("library.proto"),
+		Package:    proto.String("library.v1"),
+		Dependency: []string{"google/protobuf/empty.proto"},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Library"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("GetBook"),
+				InputType:  proto.String(".google.protobuf.Empty"),
+				OutputType: proto.String(".google.protobuf.Empty"),
+				Options:    opts,
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+
+	routes, err := apigrpc.RoutesFromAnnotations(fd.Services().Get(0))
+	require.NoError(t, err)
+	assert.Equal(t, []apigrpc.GatewayRoute{
+		{Method: http.MethodGet, Path: "/v1/books/:book.id", RPC: "/library.v1.Library/GetBook"},
+		{Method: http.MethodPost, Path: "/v1/books", RPC: "/library.v1.Library/GetBook", Body: "book"},
+	}, routes)
+}
+
+func TestGatewayRateLimitsPerHTTPClient(t *testing.T) {
+	g := apigrpc.New(apigrpc.Config{Port: "0"},
+		grpc.ChainUnaryInterceptor(apigrpc.RateLimitInterceptor(fixedwindow.New(memory.New()), 1, time.Minute, nil)))
+	t.Cleanup(g.Stop)
+	conn, err := g.InProcessConn()
+	require.NoError(t, err)
+
+	r := rest.New(rest.Config{Port: "0"})
+	require.NoError(t, apigrpc.NewGateway(conn).Register(r,
+		apigrpc.GatewayRoute{Method: http.MethodGet, Path: "/v1/health", RPC: "/grpc.health.v1.Health/Check"}))
+
+	call := func(remoteAddr string, header http.Header) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+		req.RemoteAddr = remoteAddr
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		r.Echo().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, call("10.0.0.1:1000", nil))
+	assert.Equal(t, http.StatusOK, call("10.0.0.2:1000", nil), "each HTTP client has its own bucket")
+	assert.Equal(t, http.StatusTooManyRequests, call("10.0.0.1:2000", nil))
+	assert.Equal(t, http.StatusTooManyRequests,
+		call("10.0.0.1:3000", http.Header{"Grpc-Metadata-X-Forwarded-For": {"10.9.9.9"}}),
+		"forwarded metadata cannot pick another bucket")
+	assert.Equal(t, http.StatusTooManyRequests,
+		call("10.0.0.1:4000", http.Header{"X-Forwarded-For": {"10.9.9.9"}}),
+		"a client-supplied X-Forwarded-For cannot pick another bucket")
+}
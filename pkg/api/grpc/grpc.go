@@ -7,11 +7,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
 	pkgerrors "github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
@@ -21,36 +23,67 @@ import (
 
 type Config struct {
 	Port string `env:"GRPC_PORT" env-default:"9090"`
+
+	// DisableReflection turns off the server reflection service used by
+	// grpcurl and similar tools.
+	DisableReflection bool `env:"GRPC_DISABLE_REFLECTION" env-default:"false"`
+
+	// DefaultTimeout bounds calls arriving without a deadline and
+	// MaxTimeout caps client deadlines (see DeadlineInterceptor). Zero
+	// disables either bound.
+	DefaultTimeout time.Duration `env:"GRPC_DEFAULT_TIMEOUT" env-default:"0s"`
+	MaxTimeout     time.Duration `env:"GRPC_MAX_TIMEOUT" env-default:"0s"`
 }
 
 type Server struct {
 	srv    *grpc.Server
 	cfg    Config
 	health *health.Server
+
+	mu     *concurrency.SmartMutex
+	client *grpc.ClientConn // see InProcessConn
 }
 
-func New(cfg Config) *Server {
-	opts := []grpc.ServerOption{
-		grpc.StatsHandler(otelgrpc.NewServerHandler()),
-		grpc.ChainUnaryInterceptor(
-			RecoveryInterceptor(),
-			ErrorInterceptor(),
-			LoggingInterceptor(),
-		),
-		grpc.ChainStreamInterceptor(
-			StreamRecoveryInterceptor(),
-			StreamErrorInterceptor(),
-		),
+// New creates a server with recovery, error mapping, logging and, when
+// configured, deadline interceptors. opts are applied after these, so
+// grpc.ChainUnaryInterceptor(...) adds interceptors such as
+// RateLimitInterceptor or ValidationInterceptor behind the built-in ones.
+func New(cfg Config, opts ...grpc.ServerOption) *Server {
+	unary := []grpc.UnaryServerInterceptor{
+		RecoveryInterceptor(),
+		ErrorInterceptor(),
+		LoggingInterceptor(),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		StreamRecoveryInterceptor(),
+		StreamErrorInterceptor(),
 	}
+	if cfg.DefaultTimeout > 0 || cfg.MaxTimeout > 0 {
+		deadlines := DeadlineConfig{Default: cfg.DefaultTimeout, Max: cfg.MaxTimeout}
+		unary = append(unary, DeadlineInterceptor(deadlines))
+		stream = append(stream, StreamDeadlineInterceptor(deadlines))
+	}
+	serverOpts := append([]grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}, opts...)
 
-	srv := grpc.NewServer(opts...)
-	reflection.Register(srv)
+	srv := grpc.NewServer(serverOpts...)
+	if !cfg.DisableReflection {
+		reflection.Register(srv)
+	}
 
 	hs := health.NewServer()
 	healthpb.RegisterHealthServer(srv, hs)
 	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
 
-	return &Server{srv: srv, cfg: cfg, health: hs}
+	return &Server{
+		srv:    srv,
+		cfg:    cfg,
+		health: hs,
+		mu:     concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "grpc-server-local"}),
+	}
 }
 
 func (s *Server) Start() error {
@@ -71,10 +104,49 @@ func (s *Server) Health() *health.Server {
 	return s.health
 }
 
+// InProcessConn returns a client connection served in memory by this
+// server, passing through every interceptor as a network call would. The
+// REST Gateway uses it to expose the server's services over HTTP; its peer
+// address is "in-process", and KeyByPeer keys such calls by the client
+// address the Gateway forwards in x-forwarded-for. Call it
+// after registering services: the server starts serving the in-memory
+// listener on first use.
+func (s *Server) InProcessConn() (*grpc.ClientConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	lis := newPipeListener()
+	conn, err := grpc.NewClient("passthrough:///in-process",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.dial(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return nil, pkgerrors.Internal("failed to create in-process grpc client", err)
+	}
+	go func() {
+		if err := s.srv.Serve(lis); err != nil {
+			logger.L().ErrorContext(context.Background(), "in-process grpc listener stopped", "error", err)
+		}
+	}()
+	s.client = conn
+	return conn, nil
+}
+
 func (s *Server) Stop() {
 	if s.health != nil {
 		s.health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
 	}
+	s.mu.Lock()
+	if s.client != nil {
+		_ = s.client.Close()
+		s.client = nil
+	}
+	s.mu.Unlock()
 	s.srv.GracefulStop()
 }
 
@@ -200,17 +272,10 @@ func StreamAuthInterceptor(verifier TokenVerifier) grpc.StreamServerInterceptor
 		if err != nil {
 			return err
 		}
-		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
 	}
 }
 
-type authenticatedStream struct {
-	grpc.ServerStream
-	ctx context.Context
-}
-
-func (s *authenticatedStream) Context() context.Context { return s.ctx }
-
 func authenticateContext(ctx context.Context, verifier TokenVerifier) (context.Context, error) {
 	if verifier == nil {
 		return ctx, status.Error(codes.Internal, "auth verifier is nil")
@@ -0,0 +1,392 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/ratelimit"
+	pkgerrors "github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/resilience"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/telemetry"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/validator"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const instrumentationName = "pkg/api/grpc"
+
+// wrappedStream overrides the context of a server stream.
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *wrappedStream) Context() context.Context { return s.ctx }
+
+// --- telemetry ---
+
+type rpcMetrics struct {
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newRPCMetrics() rpcMetrics {
+	meter := telemetry.Meter(instrumentationName)
+	requests, _ := meter.Int64Counter("grpc.server.requests",
+		metric.WithDescription("gRPC requests handled, by method and status code"))
+	duration, _ := meter.Float64Histogram("grpc.server.duration",
+		metric.WithDescription("gRPC request latency"), metric.WithUnit("ms"))
+	return rpcMetrics{requests: requests, duration: duration}
+}
+
+func (m rpcMetrics) record(ctx context.Context, method string, start time.Time, err error) {
+	attrs := metric.WithAttributes(
+		attribute.String("rpc.method", method),
+		attribute.String("rpc.grpc.status_code", status.Code(err).String()),
+	)
+	m.requests.Add(ctx, 1, attrs)
+	m.duration.Record(ctx, float64(time.Since(start).Microseconds())/1000, attrs)
+}
+
+// startSpan annotates the span started by the otelgrpc stats handler when
+// there is one, and otherwise starts a span from the incoming trace context.
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span, bool) {
+	if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() && span.IsRecording() {
+		span.SetAttributes(attribute.String("rpc.method", method))
+		return ctx, span, false
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	ctx, span := telemetry.Tracer(instrumentationName).Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.system", "grpc"), attribute.String("rpc.method", method)),
+	)
+	return ctx, span, true
+}
+
+func endSpan(span trace.Span, owned bool, err error) {
+	if err != nil {
+		telemetry.RecordError(span, err)
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", status.Code(err).String()))
+		if code := pkgerrors.Code(err); code != "" {
+			span.SetAttributes(attribute.String("error.code", code))
+		}
+	}
+	if owned {
+		span.End()
+	}
+}
+
+// TelemetryInterceptor records request counts and latency through
+// pkg/telemetry and traces each call. With the otelgrpc stats handler New
+// installs, it annotates that span instead of starting another.
+func TelemetryInterceptor() grpc.UnaryServerInterceptor {
+	m := newRPCMetrics()
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, span, owned := startSpan(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		endSpan(span, owned, err)
+		m.record(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamTelemetryInterceptor is the streaming counterpart of
+// TelemetryInterceptor; latency covers the whole stream.
+func StreamTelemetryInterceptor() grpc.StreamServerInterceptor {
+	m := newRPCMetrics()
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, span, owned := startSpan(ss.Context(), info.FullMethod)
+		err := handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+		endSpan(span, owned, err)
+		m.record(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// metadataCarrier adapts incoming metadata to a propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) { metadata.MD(c).Set(key, value) }
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// --- rate limiting ---
+
+// KeyFunc extracts a rate-limit bucket key from an incoming call.
+type KeyFunc func(ctx context.Context, fullMethod string) string
+
+// KeyByPeer uses the client IP. For calls from the Gateway over
+// Server.InProcessConn it uses the HTTP client's address, which the Gateway
+// forwards as x-forwarded-for. That metadata is ignored on network
+// connections, where any client could set it.
+func KeyByPeer(ctx context.Context, _ string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	if _, inProcess := p.Addr.(inProcessAddr); inProcess {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get(forwardedForKey); len(v) > 0 && v[0] != "" {
+				return v[0]
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// KeyBySubject uses the subject from AuthInterceptor, falling back to the
+// client IP.
+func KeyBySubject(ctx context.Context, method string) string {
+	if sub := GetSubject(ctx); sub != "" {
+		return "user:" + sub
+	}
+	return "ip:" + KeyByPeer(ctx, method)
+}
+
+// KeyByMethod scopes KeyBySubject per RPC so each method has its own
+// allowance.
+func KeyByMethod(ctx context.Context, method string) string {
+	return method + ":" + KeyBySubject(ctx, method)
+}
+
+// RateLimitInterceptor rejects calls over limit per period with
+// ResourceExhausted, keyed by keyFn (default KeyByPeer). Limit headers
+// mirror the HTTP middleware. Limiter errors fail open.
+func RateLimitInterceptor(limiter ratelimit.Limiter, limit int64, period time.Duration, keyFn KeyFunc) grpc.UnaryServerInterceptor {
+	check := rateLimitCheck(limiter, limit, period, keyFn)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamRateLimitInterceptor charges one unit per stream.
+func StreamRateLimitInterceptor(limiter ratelimit.Limiter, limit int64, period time.Duration, keyFn KeyFunc) grpc.StreamServerInterceptor {
+	check := rateLimitCheck(limiter, limit, period, keyFn)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func rateLimitCheck(limiter ratelimit.Limiter, limit int64, period time.Duration, keyFn KeyFunc) func(ctx context.Context, method string) error {
+	if keyFn == nil {
+		keyFn = KeyByPeer
+	}
+	limitStr := strconv.FormatInt(limit, 10)
+	return func(ctx context.Context, method string) error {
+		res, err := limiter.Allow(ctx, keyFn(ctx, method), limit, period)
+		if err != nil {
+			// Fail open for limiter backend errors (availability over strict limiting).
+			logger.L().ErrorContext(ctx, "rate limit check failed", "method", method, "error", err)
+			return nil
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(
+			"x-ratelimit-limit", limitStr,
+			"x-ratelimit-remaining", strconv.FormatInt(res.Remaining, 10),
+			"x-ratelimit-reset", strconv.Itoa(int(res.Reset.Seconds())),
+		))
+		if !res.Allowed {
+			return status.Error(codes.ResourceExhausted, "too many requests")
+		}
+		return nil
+	}
+}
+
+// --- circuit breaking ---
+
+// serverFault reports whether err indicates the service, not the caller,
+// failed; only those count against a circuit breaker.
+func serverFault(err error) bool {
+	if pkgerrors.Code(err) != "" {
+		err = pkgerrors.GRPCStatus(err).Err()
+	}
+	switch status.Code(err) {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// CircuitBreakerInterceptor fails calls fast with Unavailable while cb is
+// open. Server-side failures (Internal, Unavailable, ...) trip the breaker;
+// caller errors such as InvalidArgument do not.
+func CircuitBreakerInterceptor(cb *resilience.CircuitBreaker) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var resp interface{}
+		var callErr error
+		err := cb.Execute(ctx, func(ctx context.Context) error {
+			resp, callErr = handler(ctx, req)
+			if serverFault(callErr) {
+				return callErr
+			}
+			return nil
+		})
+		if callErr == nil && err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		return resp, callErr
+	}
+}
+
+// StreamCircuitBreakerInterceptor is the streaming counterpart of
+// CircuitBreakerInterceptor.
+func StreamCircuitBreakerInterceptor(cb *resilience.CircuitBreaker) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		var callErr error
+		err := cb.Execute(ss.Context(), func(ctx context.Context) error {
+			callErr = handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+			if serverFault(callErr) {
+				return callErr
+			}
+			return nil
+		})
+		if callErr == nil && err != nil {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		return callErr
+	}
+}
+
+// --- validation ---
+
+// selfValidator is implemented by messages generated with validation
+// plugins such as protoc-gen-validate.
+type selfValidator interface {
+	Validate() error
+}
+
+func validateMessage(ctx context.Context, v validator.Validator, msg interface{}) error {
+	var err error
+	if sv, ok := msg.(selfValidator); ok {
+		err = sv.Validate()
+	} else if v != nil {
+		err = v.ValidateStruct(ctx, msg)
+	}
+	if err == nil {
+		return nil
+	}
+	if pkgerrors.Code(err) == "" {
+		err = pkgerrors.InvalidArgument(err.Error(), err)
+	}
+	return pkgerrors.GRPCStatus(err).Err()
+}
+
+// ValidationInterceptor rejects requests failing validation with
+// InvalidArgument. Messages with a Validate() error method validate
+// themselves; others go through v's struct tags (v may be nil).
+func ValidationInterceptor(v validator.Validator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := validateMessage(ctx, v, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamValidationInterceptor validates every message received on a stream.
+func StreamValidationInterceptor(v validator.Validator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ServerStream: ss, v: v})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+	v validator.Validator
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validateMessage(s.Context(), s.v, m)
+}
+
+// --- deadlines ---
+
+// DeadlineConfig bounds how long calls may run.
+type DeadlineConfig struct {
+	// Default applies to calls arriving without a deadline. Zero leaves
+	// them unbounded.
+	Default time.Duration
+	// Max caps client-supplied deadlines. Zero accepts any deadline.
+	Max time.Duration
+}
+
+func (c DeadlineConfig) apply(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	deadline, ok := ctx.Deadline()
+	switch {
+	case ok && time.Until(deadline) <= 0:
+		return ctx, func() {}, status.Error(codes.DeadlineExceeded, "deadline exceeded before the call started")
+	case !ok && c.Default > 0:
+		ctx, cancel := context.WithTimeout(ctx, c.Default)
+		return ctx, cancel, nil
+	case ok && c.Max > 0 && time.Until(deadline) > c.Max:
+		ctx, cancel := context.WithTimeout(ctx, c.Max)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// DeadlineInterceptor applies cfg to each call's context. The deadline
+// gRPC propagates from the client is kept when within bounds, so handlers
+// calling further services pass it on through their contexts.
+func DeadlineInterceptor(cfg DeadlineConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel, err := cfg.apply(ctx)
+		defer cancel()
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamDeadlineInterceptor is the streaming counterpart of
+// DeadlineInterceptor.
+func StreamDeadlineInterceptor(cfg DeadlineConfig) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel, err := cfg.apply(ss.Context())
+		defer cancel()
+		if err != nil {
+			return err
+		}
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
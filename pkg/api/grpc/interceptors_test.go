@@ -0,0 +1,132 @@
+package grpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/algorithms/ratelimit/fixedwindow"
+	apigrpc "github.com/chris-alexander-pop/go-hyperforge/pkg/api/grpc"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/resilience"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var unaryInfo = &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+
+func okHandler(ctx context.Context, req interface{}) (interface{}, error) {
+	return "ok", nil
+}
+
+func TestRateLimitInterceptor(t *testing.T) {
+	interceptor := apigrpc.RateLimitInterceptor(fixedwindow.New(memory.New()), 2, time.Minute, nil)
+	for i := 0; i < 2; i++ {
+		_, err := interceptor(context.Background(), nil, unaryInfo, okHandler)
+		require.NoError(t, err)
+	}
+	_, err := interceptor(context.Background(), nil, unaryInfo, okHandler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestCircuitBreakerInterceptor(t *testing.T) {
+	cb := resilience.NewCircuitBreaker(resilience.CircuitBreakerConfig{
+		Name:             "grpc-test",
+		FailureThreshold: 2,
+		Timeout:          time.Minute,
+	})
+	interceptor := apigrpc.CircuitBreakerInterceptor(cb)
+
+	// Caller errors do not trip the breaker.
+	for i := 0; i < 3; i++ {
+		_, err := interceptor(context.Background(), nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, errors.InvalidArgument("bad", nil)
+		})
+		require.Error(t, err)
+	}
+	_, err := interceptor(context.Background(), nil, unaryInfo, okHandler)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := interceptor(context.Background(), nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.Internal, "boom")
+		})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	}
+	_, err = interceptor(context.Background(), nil, unaryInfo, okHandler)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+type signupRequest struct {
+	Email string `validate:"required,email"`
+}
+
+type selfValidating struct{ ok bool }
+
+func (s selfValidating) Validate() error {
+	if !s.ok {
+		return errors.InvalidArgument("invalid message", nil)
+	}
+	return nil
+}
+
+func TestValidationInterceptor(t *testing.T) {
+	interceptor := apigrpc.ValidationInterceptor(validator.New())
+
+	_, err := interceptor(context.Background(), &signupRequest{Email: "nope"}, unaryInfo, okHandler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = interceptor(context.Background(), &signupRequest{Email: "a@example.com"}, unaryInfo, okHandler)
+	require.NoError(t, err)
+
+	_, err = interceptor(context.Background(), selfValidating{}, unaryInfo, okHandler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = interceptor(context.Background(), selfValidating{ok: true}, unaryInfo, okHandler)
+	require.NoError(t, err)
+}
+
+func TestDeadlineInterceptor(t *testing.T) {
+	interceptor := apigrpc.DeadlineInterceptor(apigrpc.DeadlineConfig{Default: time.Second, Max: 5 * time.Second})
+	remaining := func(ctx context.Context) time.Duration {
+		var left time.Duration
+		_, err := interceptor(ctx, nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+			deadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			left = time.Until(deadline)
+			return nil, nil
+		})
+		require.NoError(t, err)
+		return left
+	}
+
+	assert.LessOrEqual(t, remaining(context.Background()), time.Second)
+
+	long, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	assert.LessOrEqual(t, remaining(long), 5*time.Second)
+
+	short, cancel2 := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel2()
+	assert.Greater(t, remaining(short), time.Second)
+
+	expired, cancel3 := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel3()
+	_, err := interceptor(expired, nil, unaryInfo, okHandler)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestTelemetryInterceptorPassesThrough(t *testing.T) {
+	interceptor := apigrpc.TelemetryInterceptor()
+	resp, err := interceptor(context.Background(), nil, unaryInfo, okHandler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	_, err = interceptor(context.Background(), nil, unaryInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
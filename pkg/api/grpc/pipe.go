@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// inProcessAddr is the address of both ends of an InProcessConn
+// connection. KeyByPeer recognises it to trust the Gateway's forwarded
+// client address.
+type inProcessAddr struct{}
+
+func (inProcessAddr) Network() string { return "pipe" }
+func (inProcessAddr) String() string  { return "in-process" }
+
+// pipeListener is a net.Listener whose connections are in-memory pipes
+// created by dial.
+type pipeListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return inProcessAddr{} }
+
+// dial connects to the listener, waiting for Accept.
+func (l *pipeListener) dial(ctx context.Context) (net.Conn, error) {
+	a, b := newPipeBuffer(), newPipeBuffer()
+	client := &pipeConn{r: a, w: b}
+	server := &pipeConn{r: b, w: a}
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// pipeBuffer carries one direction of a pipeConn. Unlike net.Pipe, writes
+// never wait for the reader: both HTTP/2 peers write their preface before
+// reading, which would deadlock a synchronous pipe. HTTP/2 flow control
+// bounds how much is buffered.
+type pipeBuffer struct {
+	mu     sync.Mutex
+	data   []byte
+	closed bool
+	ready  chan struct{} // signalled after a write or close
+}
+
+func newPipeBuffer() *pipeBuffer {
+	return &pipeBuffer{ready: make(chan struct{}, 1)}
+}
+
+func (p *pipeBuffer) signal() {
+	select {
+	case p.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (p *pipeBuffer) read(b []byte) (int, error) {
+	for {
+		p.mu.Lock()
+		if len(p.data) > 0 {
+			n := copy(b, p.data)
+			p.data = p.data[n:]
+			p.mu.Unlock()
+			return n, nil
+		}
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			return 0, io.EOF
+		}
+		<-p.ready
+	}
+}
+
+func (p *pipeBuffer) write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	p.data = append(p.data, b...)
+	p.signal()
+	return len(b), nil
+}
+
+func (p *pipeBuffer) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.signal()
+}
+
+// pipeConn is one end of an in-memory connection. Deadlines are accepted
+// but not enforced; gRPC closes the connection to unblock it instead.
+type pipeConn struct {
+	r, w *pipeBuffer
+}
+
+func (c *pipeConn) Read(b []byte) (int, error)  { return c.r.read(b) }
+func (c *pipeConn) Write(b []byte) (int, error) { return c.w.write(b) }
+
+func (c *pipeConn) Close() error {
+	c.r.close()
+	c.w.close()
+	return nil
+}
+
+func (c *pipeConn) LocalAddr() net.Addr              { return inProcessAddr{} }
+func (c *pipeConn) RemoteAddr() net.Addr             { return inProcessAddr{} }
+func (c *pipeConn) SetDeadline(time.Time) error      { return nil }
+func (c *pipeConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *pipeConn) SetWriteDeadline(time.Time) error { return nil }
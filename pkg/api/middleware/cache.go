@@ -3,69 +3,528 @@ package middleware
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
 )
 
-type responseWriterWrapper struct {
-	http.ResponseWriter
-	body       *bytes.Buffer
-	statusCode int
+// ResponseCacheConfig configures a ResponseCache.
+type ResponseCacheConfig struct {
+	// Prefix namespaces cache keys (default "resp:").
+	Prefix string
+
+	// DefaultTTL is the freshness lifetime for cacheable responses that carry
+	// no Cache-Control max-age/s-maxage or Expires. Zero stores only
+	// responses with an explicit lifetime.
+	DefaultTTL time.Duration
+
+	// MaxBodySize caps the bytes buffered per response (default 1 MiB).
+	// Larger responses stream through uncached.
+	MaxBodySize int64
+
+	// PerUser caches authenticated requests per user (keyed by UserKey),
+	// including responses marked Cache-Control: private. When false,
+	// authenticated requests only use entries the origin marked public or
+	// s-maxage.
+	PerUser bool
+
+	// UserKey identifies the user for PerUser caching (default GetSubject).
+	UserKey func(r *http.Request) string
+
+	// Tag groups entries for PurgeTag (default: the first path segment, so
+	// /products/42 is tagged "products").
+	Tag func(r *http.Request) string
+
+	// Now overrides the clock (tests).
+	Now func() time.Time
 }
 
-func (w *responseWriterWrapper) Write(b []byte) (int, error) {
-	w.body.Write(b)
-	return w.ResponseWriter.Write(b)
+// ResponseCache is an RFC 9111-style HTTP cache. It stores status, headers
+// and body; honours Cache-Control, Expires and Vary; generates ETags; and
+// answers If-None-Match / If-Modified-Since with 304 Not Modified.
+//
+// Keys are laid out as Prefix + tag + ":" + hash so a tag can be purged
+// with cache.InvalidatePrefix.
+type ResponseCache struct {
+	c   cache.Cache
+	cfg ResponseCacheConfig
 }
 
-func (w *responseWriterWrapper) WriteHeader(statusCode int) {
-	w.statusCode = statusCode
-	w.ResponseWriter.WriteHeader(statusCode)
+// NewResponseCache creates a response cache on top of c.
+func NewResponseCache(c cache.Cache, cfg ResponseCacheConfig) *ResponseCache {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "resp:"
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	if cfg.UserKey == nil {
+		cfg.UserKey = func(r *http.Request) string { return GetSubject(r.Context()) }
+	}
+	if cfg.Tag == nil {
+		cfg.Tag = pathTag
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &ResponseCache{c: c, cfg: cfg}
 }
 
+// CacheMiddleware caches GET responses, using ttl for responses that do not
+// declare their own lifetime. See ResponseCache for the full behaviour.
 func CacheMiddleware(c cache.Cache, ttl time.Duration) func(http.Handler) http.Handler {
+	return NewResponseCache(c, ResponseCacheConfig{DefaultTTL: ttl}).Middleware()
+}
+
+// PurgeTag removes every cached response tagged tag, across all users.
+func (rc *ResponseCache) PurgeTag(ctx context.Context, tag string) (int64, error) {
+	return cache.InvalidatePrefix(ctx, rc.c, rc.cfg.Prefix+tag+":")
+}
+
+// Purge removes every cached response.
+func (rc *ResponseCache) Purge(ctx context.Context) (int64, error) {
+	return cache.InvalidatePrefix(ctx, rc.c, rc.cfg.Prefix)
+}
+
+// cachedResponse is a stored response.
+type cachedResponse struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt int64       `json:"stored_at"` // unix nanos
+	Age      int64       `json:"age"`       // Age header on arrival, seconds
+}
+
+// cachedVariants records the Vary header names for a resource so lookups
+// can find the variant matching the request.
+type cachedVariants struct {
+	Vary []string `json:"vary"`
+}
+
+// Middleware returns the caching middleware.
+func (rc *ResponseCache) Middleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Only cache GET
-			if r.Method != http.MethodGet {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead:
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+				rec := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+				next.ServeHTTP(rec, r)
+				if rec.statusCode < http.StatusBadRequest {
+					rc.invalidate(r)
+				}
+				return
+			default:
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			key := "resp:" + r.RequestURI
-
-			// Try Get
-			var cachedBody []byte
-			if err := c.Get(r.Context(), key, &cachedBody); err == nil {
-				w.Header().Set("X-Cache", "HIT")
-				w.Header().Set("Content-Type", "application/json") // Assumption!
-				if _, writeErr := w.Write(cachedBody); writeErr != nil {
-					logger.L().Error("failed to write cached response", "error", writeErr)
-				}
+			reqCC := parseCacheControl(r.Header.Get("Cache-Control"))
+			if _, ok := reqCC["no-store"]; ok {
+				next.ServeHTTP(w, r)
 				return
 			}
 
-			// Miss - Record Response
-			wrapper := &responseWriterWrapper{
-				ResponseWriter: w,
-				body:           &bytes.Buffer{},
-				statusCode:     http.StatusOK, // Default
+			user := ""
+			if rc.cfg.PerUser {
+				user = rc.cfg.UserKey(r)
 			}
+			key := rc.key(r, user)
 
-			next.ServeHTTP(wrapper, r)
+			_, noCache := reqCC["no-cache"]
+			if !noCache && rc.serve(w, r, key, reqCC) {
+				return
+			}
+			if r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-			// Cache if 200 OK
-			if wrapper.statusCode == http.StatusOK {
-				// Fire and forget set
-				go func() {
-					if err := c.Set(context.Background(), key, wrapper.body.Bytes(), ttl); err != nil {
-						logger.L().Warn("failed to cache response", "key", key, "error", err)
-					}
-				}()
+			rec := &cacheRecorder{w: w, status: http.StatusOK, limit: rc.cfg.MaxBodySize}
+			next.ServeHTTP(rec, r)
+			if rec.passthrough {
+				return
 			}
+			rc.finish(w, r, rec, key, user)
 		})
 	}
 }
+
+// serve answers r from the cache, reporting whether it did.
+func (rc *ResponseCache) serve(w http.ResponseWriter, r *http.Request, key string, reqCC map[string]string) bool {
+	ctx := r.Context()
+	var variants cachedVariants
+	if err := rc.c.Get(ctx, key, &variants); err != nil {
+		return false
+	}
+	var entry cachedResponse
+	if err := rc.c.Get(ctx, variantKey(key, variants.Vary, r), &entry); err != nil {
+		return false
+	}
+
+	age := entry.Age + int64(rc.cfg.Now().Sub(time.Unix(0, entry.StoredAt))/time.Second)
+	if v, ok := reqCC["max-age"]; ok {
+		if maxAge, err := strconv.ParseInt(v, 10, 64); err == nil && age > maxAge {
+			return false
+		}
+	}
+
+	h := w.Header()
+	for k, vs := range entry.Header {
+		h[k] = append([]string(nil), vs...)
+	}
+	h.Set("Age", strconv.FormatInt(age, 10))
+	h.Set("X-Cache", "HIT")
+	if entry.Status == http.StatusOK && notModified(r, h) {
+		writeNotModified(w)
+		return true
+	}
+	w.WriteHeader(entry.Status)
+	if r.Method != http.MethodHead {
+		if _, err := w.Write(entry.Body); err != nil {
+			logger.L().ErrorContext(ctx, "failed to write cached response", "error", err)
+		}
+	}
+	return true
+}
+
+// finish stores the buffered response when cacheable, then writes it to the
+// client (as a 304 when the request's validators match).
+func (rc *ResponseCache) finish(w http.ResponseWriter, r *http.Request, rec *cacheRecorder, key, user string) {
+	ctx := r.Context()
+	h := w.Header()
+	body := rec.body.Bytes()
+	now := rc.cfg.Now()
+
+	if rec.status == http.StatusOK && h.Get("ETag") == "" {
+		sum := sha256.Sum256(body)
+		h.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	}
+	if h.Get("Date") == "" {
+		h.Set("Date", now.UTC().Format(http.TimeFormat))
+	}
+
+	if ttl, vary, ok := rc.storable(r, rec.status, h, user); ok {
+		entry := cachedResponse{
+			Status:   rec.status,
+			Header:   storedHeader(h),
+			Body:     body,
+			StoredAt: now.UnixNano(),
+			Age:      headerAge(h),
+		}
+		// Stored synchronously so the next request is guaranteed to hit.
+		if err := rc.c.Set(ctx, variantKey(key, vary, r), entry, ttl); err != nil {
+			logger.L().WarnContext(ctx, "failed to cache response", "key", key, "error", err)
+		} else if err := rc.c.Set(ctx, key, cachedVariants{Vary: vary}, ttl); err != nil {
+			logger.L().WarnContext(ctx, "failed to cache response", "key", key, "error", err)
+		}
+	}
+
+	h.Set("X-Cache", "MISS")
+	if rec.status == http.StatusOK && notModified(r, h) {
+		writeNotModified(w)
+		return
+	}
+	w.WriteHeader(rec.status)
+	if _, err := w.Write(body); err != nil {
+		logger.L().ErrorContext(ctx, "failed to write response", "error", err)
+	}
+}
+
+// storable decides whether a response may be stored and for how long.
+func (rc *ResponseCache) storable(r *http.Request, status int, h http.Header, user string) (time.Duration, []string, bool) {
+	if !cacheableStatus[status] {
+		return 0, nil, false
+	}
+	reqCC := parseCacheControl(r.Header.Get("Cache-Control"))
+	cc := parseCacheControl(h.Get("Cache-Control"))
+	for _, d := range []string{"no-store", "no-cache"} {
+		if _, ok := cc[d]; ok {
+			return 0, nil, false
+		}
+	}
+	if _, ok := reqCC["no-store"]; ok {
+		return 0, nil, false
+	}
+
+	vary := varyHeaders(h)
+	for _, v := range vary {
+		if v == "*" {
+			return 0, nil, false
+		}
+	}
+
+	shared := user == ""
+	_, private := cc["private"]
+	_, public := cc["public"]
+	_, sMaxAge := cc["s-maxage"]
+	if shared && private {
+		return 0, nil, false
+	}
+	authenticated := r.Header.Get("Authorization") != "" || GetSubject(r.Context()) != ""
+	if shared && authenticated && !public && !sMaxAge {
+		return 0, nil, false
+	}
+
+	ttl, ok := rc.lifetime(cc, h, shared)
+	if !ok {
+		return 0, nil, false
+	}
+	ttl -= time.Duration(headerAge(h)) * time.Second
+	if ttl <= 0 {
+		return 0, nil, false
+	}
+	return ttl, vary, true
+}
+
+// lifetime returns the freshness lifetime from s-maxage (shared entries),
+// max-age, Expires, or DefaultTTL, in that order.
+func (rc *ResponseCache) lifetime(cc map[string]string, h http.Header, shared bool) (time.Duration, bool) {
+	directives := []string{"max-age"}
+	if shared {
+		directives = []string{"s-maxage", "max-age"}
+	}
+	for _, d := range directives {
+		if v, ok := cc[d]; ok {
+			secs, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0, false // invalid Expires means already expired
+		}
+		date, err := http.ParseTime(h.Get("Date"))
+		if err != nil {
+			date = rc.cfg.Now()
+		}
+		return expires.Sub(date), true
+	}
+	return rc.cfg.DefaultTTL, rc.cfg.DefaultTTL > 0
+}
+
+// invalidate drops the shared and per-user entries for the request target
+// after a successful unsafe method.
+func (rc *ResponseCache) invalidate(r *http.Request) {
+	keys := []string{rc.key(r, "")}
+	if rc.cfg.PerUser {
+		if user := rc.cfg.UserKey(r); user != "" {
+			keys = append(keys, rc.key(r, user))
+		}
+	}
+	for _, key := range keys {
+		if err := rc.c.Delete(r.Context(), key); err != nil {
+			logger.L().WarnContext(r.Context(), "failed to invalidate cached response", "key", key, "error", err)
+		}
+	}
+}
+
+// key returns the variants key for a request target and user.
+func (rc *ResponseCache) key(r *http.Request, user string) string {
+	sum := sha256.Sum256([]byte(user + "\x00" + r.Host + "\x00" + r.URL.RequestURI()))
+	return rc.cfg.Prefix + rc.cfg.Tag(r) + ":" + hex.EncodeToString(sum[:])
+}
+
+// variantKey extends key with the request's values for the Vary headers.
+func variantKey(key string, vary []string, r *http.Request) string {
+	if len(vary) == 0 {
+		return key + ":-"
+	}
+	var b strings.Builder
+	for _, name := range vary {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+		b.WriteByte('\n')
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return key + ":" + hex.EncodeToString(sum[:8])
+}
+
+// pathTag is the default ResponseCacheConfig.Tag.
+func pathTag(r *http.Request) string {
+	seg, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if seg == "" {
+		return "_"
+	}
+	return seg
+}
+
+// cacheableStatus lists the status codes that are cacheable by default
+// (RFC 9110 §15.1).
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// unstoredHeaders are never written to the cache.
+var unstoredHeaders = []string{"Set-Cookie", "Connection", "Keep-Alive", "Transfer-Encoding", "Age", "X-Cache"}
+
+func storedHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, k := range unstoredHeaders {
+		out.Del(k)
+	}
+	return out
+}
+
+// notModifiedHeaders are the headers kept on a 304 (RFC 9110 §15.4.5).
+var notModifiedHeaders = map[string]bool{
+	"Cache-Control": true, "Content-Location": true, "Date": true, "Etag": true,
+	"Expires": true, "Vary": true, "Last-Modified": true, "Age": true, "X-Cache": true,
+}
+
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	for k := range h {
+		if !notModifiedHeaders[k] {
+			h.Del(k)
+		}
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since
+// only when no If-None-Match is sent (RFC 9110 §13.2.2).
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lm.After(ims)
+}
+
+// parseCacheControl splits a Cache-Control header into lower-cased
+// directives and their unquoted values.
+func parseCacheControl(v string) map[string]string {
+	out := make(map[string]string)
+	for _, part := range strings.Split(v, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+		out[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+	return out
+}
+
+// varyHeaders returns the canonical, sorted header names listed in Vary.
+func varyHeaders(h http.Header) []string {
+	var out []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				out = append(out, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func headerAge(h http.Header) int64 {
+	age, _ := strconv.ParseInt(h.Get("Age"), 10, 64)
+	if age < 0 {
+		return 0
+	}
+	return age
+}
+
+// cacheRecorder buffers a response so it can be stored, given an ETag and
+// answered with 304 before anything reaches the client. Past limit bytes,
+// or when the handler flushes, it falls back to streaming.
+type cacheRecorder struct {
+	w           http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	limit       int64
+	passthrough bool
+}
+
+func (c *cacheRecorder) Header() http.Header {
+	return c.w.Header()
+}
+
+func (c *cacheRecorder) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.status = status
+	c.wroteHeader = true
+}
+
+func (c *cacheRecorder) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if !c.passthrough && int64(c.body.Len()+len(b)) > c.limit {
+		if err := c.startPassthrough(); err != nil {
+			return 0, err
+		}
+	}
+	if c.passthrough {
+		return c.w.Write(b)
+	}
+	return c.body.Write(b)
+}
+
+// Flush switches to streaming; a flushed response is never cached.
+func (c *cacheRecorder) Flush() {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if !c.passthrough {
+		if err := c.startPassthrough(); err != nil {
+			return
+		}
+	}
+	if f, ok := c.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *cacheRecorder) startPassthrough() error {
+	c.passthrough = true
+	c.w.WriteHeader(c.status)
+	_, err := c.w.Write(c.body.Bytes())
+	c.body.Reset()
+	return err
+}
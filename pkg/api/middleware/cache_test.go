@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cachememory "github.com/chris-alexander-pop/go-hyperforge/pkg/cache/adapters/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingHandler serves fn and counts how often the origin was reached.
+type countingHandler struct {
+	calls int
+	fn    http.HandlerFunc
+}
+
+func (c *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.calls++
+	c.fn(w, r)
+}
+
+func doRequest(h http.Handler, method, target string, header http.Header, subject string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	if subject != "" {
+		req = req.WithContext(context.WithValue(req.Context(), ContextKeySubject, subject))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestResponseCache_StoresStatusHeadersAndBody(t *testing.T) {
+	origin := &countingHandler{fn: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusNonAuthoritativeInfo)
+		_, _ = w.Write([]byte("sku,price\n1,9.99\n"))
+	}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rc := NewResponseCache(cachememory.New(), ResponseCacheConfig{Now: func() time.Time { return now }})
+	h := rc.Middleware()(origin)
+
+	miss := doRequest(h, http.MethodGet, "/products/export", nil, "")
+	assert.Equal(t, "MISS", miss.Header().Get("X-Cache"))
+	assert.NotEmpty(t, miss.Header().Get("Set-Cookie"), "the origin's own response is untouched")
+
+	now = now.Add(5 * time.Second)
+	hit := doRequest(h, http.MethodGet, "/products/export", nil, "")
+	assert.Equal(t, 1, origin.calls)
+	assert.Equal(t, "HIT", hit.Header().Get("X-Cache"))
+	assert.Equal(t, http.StatusNonAuthoritativeInfo, hit.Code)
+	assert.Equal(t, "text/csv", hit.Header().Get("Content-Type"))
+	assert.Equal(t, "sku,price\n1,9.99\n", hit.Body.String())
+	assert.Equal(t, "5", hit.Header().Get("Age"))
+	assert.Empty(t, hit.Header().Get("Set-Cookie"), "cookies are never replayed")
+
+	head := doRequest(h, http.MethodHead, "/products/export", nil, "")
+	assert.Equal(t, "HIT", head.Header().Get("X-Cache"))
+	assert.Empty(t, head.Body.String())
+
+	fresh := doRequest(h, http.MethodGet, "/products/export", http.Header{"Cache-Control": {"max-age=1"}}, "")
+	assert.Equal(t, "MISS", fresh.Header().Get("X-Cache"), "request max-age rejects older entries")
+	assert.Equal(t, 2, origin.calls)
+}
+
+func TestResponseCache_RespectsCacheControlAndExpires(t *testing.T) {
+	cases := map[string]struct {
+		header http.Header
+		stored bool
+	}{
+		"no-store":        {http.Header{"Cache-Control": {"no-store"}}, false},
+		"no-cache":        {http.Header{"Cache-Control": {"no-cache"}}, false},
+		"private":         {http.Header{"Cache-Control": {"private, max-age=60"}}, false},
+		"no lifetime":     {http.Header{}, false},
+		"vary star":       {http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}}, false},
+		"expired expires": {http.Header{"Expires": {"Mon, 01 Jan 2001 00:00:00 GMT"}}, false},
+		"future expires":  {http.Header{"Expires": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}, true},
+		"s-maxage":        {http.Header{"Cache-Control": {"s-maxage=30"}}, true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			origin := &countingHandler{fn: func(w http.ResponseWriter, r *http.Request) {
+				for k, vs := range tc.header {
+					w.Header()[k] = vs
+				}
+				_, _ = w.Write([]byte("ok"))
+			}}
+			h := NewResponseCache(cachememory.New(), ResponseCacheConfig{}).Middleware()(origin)
+			doRequest(h, http.MethodGet, "/a", nil, "")
+			doRequest(h, http.MethodGet, "/a", nil, "")
+			if tc.stored {
+				assert.Equal(t, 1, origin.calls)
+			} else {
+				assert.Equal(t, 2, origin.calls)
+			}
+		})
+	}
+}
+
+func TestResponseCache_Vary(t *testing.T) {
+	origin := &countingHandler{fn: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte("hello " + r.Header.Get("Accept-Language")))
+	}}
+	h := NewResponseCache(cachememory.New(), ResponseCacheConfig{}).Middleware()(origin)
+
+	en := http.Header{"Accept-Language": {"en"}}
+	fr := http.Header{"Accept-Language": {"fr"}}
+	doRequest(h, http.MethodGet, "/greeting", en, "")
+	doRequest(h, http.MethodGet, "/greeting", fr, "")
+	assert.Equal(t, "hello en", doRequest(h, http.MethodGet, "/greeting", en, "").Body.String())
+	assert.Equal(t, "hello fr", doRequest(h, http.MethodGet, "/greeting", fr, "").Body.String())
+	assert.Equal(t, 2, origin.calls)
+}
+
+func TestResponseCache_ConditionalRequests(t *testing.T) {
+	lastModified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+	origin := &countingHandler{fn: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Last-Modified", lastModified)
+		_, _ = w.Write([]byte(`{"id":1}`))
+	}}
+	h := NewResponseCache(cachememory.New(), ResponseCacheConfig{}).Middleware()(origin)
+
+	first := doRequest(h, http.MethodGet, "/products/1", nil, "")
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag, "an ETag is generated")
+
+	hit := doRequest(h, http.MethodGet, "/products/1", http.Header{"If-None-Match": {`"other", ` + etag}}, "")
+	assert.Equal(t, http.StatusNotModified, hit.Code)
+	assert.Empty(t, hit.Body.String())
+	assert.Equal(t, etag, hit.Header().Get("ETag"))
+	assert.Empty(t, hit.Header().Get("Content-Type"))
+
+	weak := doRequest(h, http.MethodGet, "/products/1", http.Header{"If-None-Match": {"W/" + etag}}, "")
+	assert.Equal(t, http.StatusNotModified, weak.Code, "If-None-Match uses weak comparison")
+
+	ims := doRequest(h, http.MethodGet, "/products/1", http.Header{"If-Modified-Since": {lastModified}}, "")
+	assert.Equal(t, http.StatusNotModified, ims.Code)
+	stale := doRequest(h, http.MethodGet, "/products/1", http.Header{"If-None-Match": {`"stale"`}, "If-Modified-Since": {lastModified}}, "")
+	assert.Equal(t, http.StatusOK, stale.Code, "If-Modified-Since is ignored when If-None-Match is sent")
+
+	// Validators are also honoured on a miss.
+	miss := doRequest(h, http.MethodGet, "/products/2", http.Header{"If-None-Match": {etag}}, "")
+	assert.Equal(t, "MISS", miss.Header().Get("X-Cache"))
+	assert.Equal(t, http.StatusNotModified, miss.Code)
+	assert.Equal(t, 2, origin.calls)
+}
+
+func TestResponseCache_PerUser(t *testing.T) {
+	origin := &countingHandler{fn: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "private, max-age=60")
+		_, _ = w.Write([]byte("cart of " + GetSubject(r.Context())))
+	}}
+	h := NewResponseCache(cachememory.New(), ResponseCacheConfig{PerUser: true}).Middleware()(origin)
+
+	assert.Equal(t, "cart of alice", doRequest(h, http.MethodGet, "/cart", nil, "alice").Body.String())
+	assert.Equal(t, "cart of bob", doRequest(h, http.MethodGet, "/cart", nil, "bob").Body.String())
+	hit := doRequest(h, http.MethodGet, "/cart", nil, "alice")
+	assert.Equal(t, "HIT", hit.Header().Get("X-Cache"))
+	assert.Equal(t, "cart of alice", hit.Body.String())
+	assert.Equal(t, 2, origin.calls)
+
+	// Without PerUser, authenticated responses are only shared when public.
+	shared := &countingHandler{fn: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("cart of " + GetSubject(r.Context())))
+	}}
+	h = NewResponseCache(cachememory.New(), ResponseCacheConfig{}).Middleware()(shared)
+	doRequest(h, http.MethodGet, "/cart", nil, "alice")
+	assert.Equal(t, "cart of bob", doRequest(h, http.MethodGet, "/cart", nil, "bob").Body.String())
+}
+
+func TestResponseCache_PurgeAndInvalidate(t *testing.T) {
+	origin := &countingHandler{fn: func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		_, _ = w.Write([]byte(r.URL.Path))
+	}}
+	rc := NewResponseCache(cachememory.New(), ResponseCacheConfig{})
+	h := rc.Middleware()(origin)
+
+	for _, p := range []string{"/products/1", "/products/2", "/users/1"} {
+		doRequest(h, http.MethodGet, p, nil, "")
+	}
+	n, err := rc.PurgeTag(context.Background(), "products")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n, "two variant indexes and two variants")
+	assert.Equal(t, "MISS", doRequest(h, http.MethodGet, "/products/1", nil, "").Header().Get("X-Cache"))
+	assert.Equal(t, "HIT", doRequest(h, http.MethodGet, "/users/1", nil, "").Header().Get("X-Cache"))
+
+	doRequest(h, http.MethodPut, "/users/1", nil, "")
+	assert.Equal(t, "MISS", doRequest(h, http.MethodGet, "/users/1", nil, "").Header().Get("X-Cache"), "unsafe methods invalidate")
+}
+
+func TestResponseCache_LargeAndStreamedBodiesPassThrough(t *testing.T) {
+	big := strings.Repeat("x", 64)
+	origin := &countingHandler{fn: func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/stream" {
+			_, _ = w.Write([]byte("a"))
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte("b"))
+			return
+		}
+		_, _ = w.Write([]byte(big[:32]))
+		_, _ = w.Write([]byte(big[32:]))
+	}}
+	h := NewResponseCache(cachememory.New(), ResponseCacheConfig{MaxBodySize: 40}).Middleware()(origin)
+
+	assert.Equal(t, big, doRequest(h, http.MethodGet, "/big", nil, "").Body.String())
+	assert.Equal(t, big, doRequest(h, http.MethodGet, "/big", nil, "").Body.String())
+	assert.Equal(t, "ab", doRequest(h, http.MethodGet, "/stream", nil, "").Body.String())
+	assert.Equal(t, "ab", doRequest(h, http.MethodGet, "/stream", nil, "").Body.String())
+	assert.Equal(t, 4, origin.calls)
+}
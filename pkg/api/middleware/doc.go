@@ -1,7 +1,17 @@
 /*
 Package middleware provides HTTP middleware for auth context, RBAC RequirePermission,
 rate limiting (IP / user / API-key keys), security headers, CORS, CSRF, circuit breaker,
-cache, and audit.
+load shedding, cache, and audit.
+
+The load shedder (NewLoadShedder, or LoadShedMiddleware) bounds in-flight requests and
+queues the excess by Priority, taken from route prefixes or the X-Priority header. Queue
+delay is tracked CoDel-style; shed requests get 503 with Retry-After, lowest priority
+first. With Coalesce set, identical concurrent GETs share one handler call.
+
+The response cache (NewResponseCache, or CacheMiddleware for the simple case) follows
+RFC 9111: it stores status, headers and body; honours Cache-Control, Expires and Vary;
+generates ETags and answers If-None-Match / If-Modified-Since with 304; can key
+authenticated routes per user; and purges by tag via cache.InvalidatePrefix.
 
 gRPC interceptors live under pkg/api/grpc, not here. For Echo↔stdlib bridging, use
 pkg/api/openapi (EchoMiddleware, EchoHandler, StdHandler, MountStd, ChainStd).
@@ -0,0 +1,450 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+)
+
+// Priority ranks requests for load shedding; lower priorities are shed
+// first. The zero value is PriorityNormal.
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+	PriorityCritical
+)
+
+const numPriorities = int(PriorityCritical-PriorityLow) + 1
+
+var priorityNames = [numPriorities]string{"low", "normal", "high", "critical"}
+
+func (p Priority) String() string {
+	if p < PriorityLow || p > PriorityCritical {
+		return "Priority(" + strconv.Itoa(int(p)) + ")"
+	}
+	return priorityNames[p.index()]
+}
+
+func (p Priority) index() int {
+	return int(p - PriorityLow)
+}
+
+// ParsePriority parses "low", "normal", "high" or "critical", ignoring case.
+func ParsePriority(s string) (Priority, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range priorityNames {
+		if s == name {
+			return PriorityLow + Priority(i), true
+		}
+	}
+	return PriorityNormal, false
+}
+
+// LoadShedConfig configures a LoadShedder.
+type LoadShedConfig struct {
+	// MaxInFlight caps the requests served concurrently (default 256).
+	MaxInFlight int
+
+	// MaxQueue caps the requests waiting for a slot (default MaxInFlight).
+	// When the queue is full an arrival displaces the newest waiter of a
+	// lower priority, or is shed.
+	MaxQueue int
+
+	// Target is the CoDel target queue delay (default 5ms). When the
+	// shortest delay seen over an Interval exceeds it the server counts as
+	// overloaded and queued requests below PriorityCritical wait at most
+	// Target instead of Interval.
+	Target time.Duration
+
+	// Interval is the CoDel measurement window and the queue timeout while
+	// not overloaded (default 100ms).
+	Interval time.Duration
+
+	// MaxWait bounds how long PriorityCritical requests queue (default 1s).
+	MaxWait time.Duration
+
+	// RetryAfter is advertised on shed responses, rounded up to whole
+	// seconds (default 1s).
+	RetryAfter time.Duration
+
+	// Routes assigns priorities by path prefix, optionally preceded by a
+	// method: "/healthz", "POST /orders". The longest prefix wins.
+	Routes map[string]Priority
+
+	// PriorityHeader names the header clients use to state a priority for
+	// routes not listed in Routes (default "X-Priority", "-" disables).
+	// Header values are capped at PriorityHigh; only Routes can grant
+	// PriorityCritical.
+	PriorityHeader string
+
+	// Classify replaces Routes and PriorityHeader when set.
+	Classify func(r *http.Request) Priority
+
+	// Coalesce merges identical concurrent GET requests into one handler
+	// call whose buffered response is copied to every caller. The shared
+	// call runs detached from the first caller's cancellation so the others
+	// are not failed by it. Do not enable it for streaming endpoints.
+	Coalesce bool
+
+	// CoalesceKey identifies identical requests (default: host, URI and the
+	// Authorization, Cookie, Accept and Accept-Encoding headers).
+	CoalesceKey func(r *http.Request) string
+}
+
+// LoadShedStats is a snapshot of a LoadShedder.
+type LoadShedStats struct {
+	InFlight   int
+	Queued     int
+	Overloaded bool
+	Shed       uint64
+	Coalesced  uint64
+}
+
+// LoadShedder protects a saturated server. It bounds in-flight requests,
+// queues the excess by priority, and sheds the lowest priorities first
+// with 503 and Retry-After. Queue delay is tracked CoDel-style: once the
+// minimum delay over an interval exceeds the target, queue timeouts shrink
+// so standing queues drain instead of growing.
+type LoadShedder struct {
+	cfg        LoadShedConfig
+	routes     []routePriority
+	retryAfter string
+
+	mu          *concurrency.SmartMutex
+	inFlight    int
+	queued      int
+	queues      [numPriorities]*list.List
+	overloaded  bool
+	windowStart time.Time
+	minDelay    time.Duration
+	samples     int
+
+	shed      atomic.Uint64
+	coalesced atomic.Uint64
+	group     concurrency.Group
+}
+
+type routePriority struct {
+	method   string
+	prefix   string
+	priority Priority
+}
+
+// waiter is a queued request. ready receives true when it is given a slot
+// and false when a higher-priority arrival displaces it.
+type waiter struct {
+	enqueued time.Time
+	ready    chan bool
+	elem     *list.Element
+}
+
+// NewLoadShedder creates a load shedder.
+func NewLoadShedder(cfg LoadShedConfig) *LoadShedder {
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 256
+	}
+	if cfg.MaxQueue <= 0 {
+		cfg.MaxQueue = cfg.MaxInFlight
+	}
+	if cfg.Target <= 0 {
+		cfg.Target = 5 * time.Millisecond
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 100 * time.Millisecond
+	}
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = time.Second
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = time.Second
+	}
+	if cfg.PriorityHeader == "" {
+		cfg.PriorityHeader = "X-Priority"
+	}
+	if cfg.CoalesceKey == nil {
+		cfg.CoalesceKey = coalesceKey
+	}
+	s := &LoadShedder{
+		cfg:         cfg,
+		retryAfter:  strconv.FormatInt(int64(math.Ceil(cfg.RetryAfter.Seconds())), 10),
+		mu:          concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "LoadShedder"}),
+		windowStart: time.Now(),
+	}
+	for i := range s.queues {
+		s.queues[i] = list.New()
+	}
+	for pattern, p := range cfg.Routes {
+		method, prefix, ok := strings.Cut(strings.TrimSpace(pattern), " ")
+		if !ok {
+			method, prefix = "", method
+		}
+		s.routes = append(s.routes, routePriority{
+			method:   strings.ToUpper(method),
+			prefix:   strings.TrimSpace(prefix),
+			priority: p,
+		})
+	}
+	sort.Slice(s.routes, func(i, j int) bool {
+		a, b := s.routes[i], s.routes[j]
+		if len(a.prefix) != len(b.prefix) {
+			return len(a.prefix) > len(b.prefix)
+		}
+		return a.method > b.method
+	})
+	return s
+}
+
+// LoadShedMiddleware sheds load with a LoadShedder built from cfg.
+func LoadShedMiddleware(cfg LoadShedConfig) func(http.Handler) http.Handler {
+	return NewLoadShedder(cfg).Middleware()
+}
+
+// Stats returns a snapshot of the shedder's state.
+func (s *LoadShedder) Stats() LoadShedStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return LoadShedStats{
+		InFlight:   s.inFlight,
+		Queued:     s.queued,
+		Overloaded: s.overloaded,
+		Shed:       s.shed.Load(),
+		Coalesced:  s.coalesced.Load(),
+	}
+}
+
+// Middleware returns the load shedding middleware.
+func (s *LoadShedder) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		serve := func(w http.ResponseWriter, r *http.Request) {
+			if !s.acquire(r.Context(), s.priority(r)) {
+				w.Header().Set("Retry-After", s.retryAfter)
+				http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			defer s.release()
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.cfg.Coalesce && r.Method == http.MethodGet {
+				s.coalesce(w, r, serve)
+				return
+			}
+			serve(w, r)
+		})
+	}
+}
+
+// priority classifies r.
+func (s *LoadShedder) priority(r *http.Request) Priority {
+	p := PriorityNormal
+	if s.cfg.Classify != nil {
+		p = s.cfg.Classify(r)
+	} else if rp, ok := s.routePriority(r); ok {
+		p = rp
+	} else if s.cfg.PriorityHeader != "-" {
+		if hp, ok := ParsePriority(r.Header.Get(s.cfg.PriorityHeader)); ok {
+			p = min(hp, PriorityHigh)
+		}
+	}
+	return max(PriorityLow, min(p, PriorityCritical))
+}
+
+func (s *LoadShedder) routePriority(r *http.Request) (Priority, bool) {
+	for _, rt := range s.routes {
+		if rt.method != "" && rt.method != r.Method {
+			continue
+		}
+		if r.URL.Path == rt.prefix || strings.HasPrefix(r.URL.Path, strings.TrimSuffix(rt.prefix, "/")+"/") {
+			return rt.priority, true
+		}
+	}
+	return PriorityNormal, false
+}
+
+// acquire takes an in-flight slot, queueing when none is free. It reports
+// false when the request is shed.
+func (s *LoadShedder) acquire(ctx context.Context, p Priority) bool {
+	now := time.Now()
+	s.mu.Lock()
+	if s.inFlight < s.cfg.MaxInFlight && s.queued == 0 {
+		s.inFlight++
+		s.observe(now, 0)
+		s.mu.Unlock()
+		return true
+	}
+	if s.queued >= s.cfg.MaxQueue && !s.displace(p) {
+		s.mu.Unlock()
+		s.shed.Add(1)
+		return false
+	}
+	w := &waiter{enqueued: now, ready: make(chan bool, 1)}
+	w.elem = s.queues[p.index()].PushBack(w)
+	s.queued++
+	timeout := s.cfg.Interval
+	switch {
+	case p == PriorityCritical:
+		timeout = s.cfg.MaxWait
+	case s.overloaded:
+		timeout = s.cfg.Target
+	}
+	s.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ok := <-w.ready:
+		return ok
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	if w.elem != nil {
+		s.queues[p.index()].Remove(w.elem)
+		w.elem = nil
+		s.queued--
+		s.observe(time.Now(), time.Since(w.enqueued))
+		s.mu.Unlock()
+		s.shed.Add(1)
+		return false
+	}
+	s.mu.Unlock()
+	// Granted or displaced while timing out.
+	return <-w.ready
+}
+
+// displace sheds the newest waiter below priority p to make room for it.
+// Callers hold s.mu.
+func (s *LoadShedder) displace(p Priority) bool {
+	for i := 0; i < p.index(); i++ {
+		if e := s.queues[i].Back(); e != nil {
+			w := s.queues[i].Remove(e).(*waiter)
+			w.elem = nil
+			s.queued--
+			s.shed.Add(1)
+			w.ready <- false
+			return true
+		}
+	}
+	return false
+}
+
+// release frees a slot, handing it to the oldest waiter of the highest
+// queued priority.
+func (s *LoadShedder) release() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight--
+	for i := numPriorities - 1; i >= 0; i-- {
+		if e := s.queues[i].Front(); e != nil {
+			w := s.queues[i].Remove(e).(*waiter)
+			w.elem = nil
+			s.queued--
+			s.inFlight++
+			s.observe(now, now.Sub(w.enqueued))
+			w.ready <- true
+			return
+		}
+	}
+}
+
+// observe records a queue delay. At the end of each interval the server is
+// overloaded if even the shortest delay exceeded the target. A window left
+// idle for longer than an interval says nothing about current load. Callers
+// hold s.mu.
+func (s *LoadShedder) observe(now time.Time, delay time.Duration) {
+	if elapsed := now.Sub(s.windowStart); elapsed >= s.cfg.Interval {
+		s.overloaded = elapsed < 2*s.cfg.Interval && s.samples > 0 && s.minDelay > s.cfg.Target
+		s.windowStart = now
+		s.samples = 0
+	}
+	if s.samples == 0 || delay < s.minDelay {
+		s.minDelay = delay
+	}
+	s.samples++
+}
+
+// coalesce serves r through a call shared with identical in-flight requests.
+func (s *LoadShedder) coalesce(w http.ResponseWriter, r *http.Request, serve http.HandlerFunc) {
+	var panicked interface{}
+	v, _, shared := s.group.Do(s.cfg.CoalesceKey(r), func() (v interface{}, err error) {
+		rec := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+		// A panic must not leave the other callers waiting; they get a 500
+		// and the panic continues in the caller that ran the handler.
+		defer func() {
+			if p := recover(); p != nil {
+				panicked = p
+				v = &bufferedResponse{header: make(http.Header), status: http.StatusInternalServerError}
+			}
+		}()
+		serve(rec, r.WithContext(context.WithoutCancel(r.Context())))
+		return rec, nil
+	})
+	if panicked != nil {
+		panic(panicked)
+	}
+	if shared {
+		s.coalesced.Add(1)
+	}
+	res := v.(*bufferedResponse)
+	h := w.Header()
+	for k, vs := range res.header {
+		h[k] = append([]string(nil), vs...)
+	}
+	w.WriteHeader(res.status)
+	if _, err := w.Write(res.body.Bytes()); err != nil {
+		logger.L().ErrorContext(r.Context(), "failed to write coalesced response", "error", err)
+	}
+}
+
+// coalesceKey is the default LoadShedConfig.CoalesceKey.
+func coalesceKey(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Host)
+	b.WriteByte(0)
+	b.WriteString(r.URL.RequestURI())
+	for _, name := range []string{"Authorization", "Cookie", "Accept", "Accept-Encoding"} {
+		b.WriteByte(0)
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// bufferedResponse captures a response shared by coalesced requests.
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.wroteHeader {
+		return
+	}
+	b.status = status
+	b.wroteHeader = true
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingHandler holds every request until release is closed.
+func blockingHandler(entered chan<- string, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- r.URL.Path
+		<-release
+		_, _ = w.Write([]byte("ok " + r.URL.Path))
+	})
+}
+
+func waitQueued(t *testing.T, s *LoadShedder, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return s.Stats().Queued == n }, time.Second, time.Millisecond)
+}
+
+func TestLoadShedderShedsLowPriorityFirst(t *testing.T) {
+	s := NewLoadShedder(LoadShedConfig{
+		MaxInFlight: 1,
+		MaxQueue:    1,
+		Interval:    time.Second,
+		MaxWait:     time.Second,
+		Routes:      map[string]Priority{"/batch": PriorityLow, "POST /orders": PriorityCritical},
+	})
+	entered := make(chan string, 4)
+	release := make(chan struct{})
+	h := s.Middleware()(blockingHandler(entered, release))
+
+	do := func(method, path string) <-chan *httptest.ResponseRecorder {
+		out := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+			out <- rec
+		}()
+		return out
+	}
+
+	first := do(http.MethodGet, "/items")
+	assert.Equal(t, "/items", <-entered)
+
+	batch := do(http.MethodGet, "/batch/export")
+	waitQueued(t, s, 1)
+
+	// A critical arrival displaces the queued low-priority request.
+	order := do(http.MethodPost, "/orders")
+	res := <-batch
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+	assert.Equal(t, "1", res.Header().Get("Retry-After"))
+	waitQueued(t, s, 1)
+
+	// Nothing queued ranks below a normal request, so it is shed outright.
+	res = <-do(http.MethodGet, "/items")
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-first).Code)
+	assert.Equal(t, http.StatusOK, (<-order).Code)
+	assert.Equal(t, "/orders", <-entered)
+	assert.Equal(t, uint64(2), s.Stats().Shed)
+	assert.Equal(t, 0, s.Stats().InFlight)
+}
+
+func TestLoadShedderGrantsHighestPriorityWaiter(t *testing.T) {
+	s := NewLoadShedder(LoadShedConfig{MaxInFlight: 1, MaxQueue: 4, Interval: time.Second})
+	entered := make(chan string, 4)
+	release := make(chan struct{}, 4)
+	h := s.Middleware()(blockingHandler(entered, release))
+
+	var wg sync.WaitGroup
+	serve := func(path, priority string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("X-Priority", priority)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+	}
+
+	serve("/first", "normal")
+	assert.Equal(t, "/first", <-entered)
+	serve("/low", "low")
+	waitQueued(t, s, 1)
+	serve("/high", "high")
+	waitQueued(t, s, 2)
+
+	release <- struct{}{}
+	assert.Equal(t, "/high", <-entered)
+	release <- struct{}{}
+	assert.Equal(t, "/low", <-entered)
+	release <- struct{}{}
+	wg.Wait()
+}
+
+func TestLoadShedderQueueTimeout(t *testing.T) {
+	s := NewLoadShedder(LoadShedConfig{MaxInFlight: 1, Target: time.Millisecond, Interval: 20 * time.Millisecond})
+	entered := make(chan string, 2)
+	release := make(chan struct{})
+	h := s.Middleware()(blockingHandler(entered, release))
+
+	go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-entered
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/queued", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, 0, s.Stats().Queued)
+	close(release)
+}
+
+func TestLoadShedderCoDelState(t *testing.T) {
+	s := NewLoadShedder(LoadShedConfig{Target: 5 * time.Millisecond, Interval: 100 * time.Millisecond})
+	start := s.windowStart
+
+	// Every delay in the window exceeds the target.
+	s.observe(start.Add(10*time.Millisecond), 20*time.Millisecond)
+	s.observe(start.Add(50*time.Millisecond), 8*time.Millisecond)
+	s.observe(start.Add(100*time.Millisecond), 0)
+	assert.True(t, s.overloaded)
+
+	// One short delay in the next window is enough to recover.
+	s.observe(start.Add(150*time.Millisecond), 30*time.Millisecond)
+	s.observe(start.Add(200*time.Millisecond), 0)
+	assert.False(t, s.overloaded)
+
+	// A slow window followed by an idle gap is stale, not overload.
+	s.observe(start.Add(300*time.Millisecond), 30*time.Millisecond)
+	s.observe(start.Add(350*time.Millisecond), 40*time.Millisecond)
+	s.observe(start.Add(time.Second), 0)
+	assert.False(t, s.overloaded)
+}
+
+func TestLoadShedderPriority(t *testing.T) {
+	s := NewLoadShedder(LoadShedConfig{Routes: map[string]Priority{
+		"/admin":        PriorityHigh,
+		"/admin/export": PriorityLow,
+		"POST /pay":     PriorityCritical,
+	}})
+	tests := []struct {
+		method, path, header string
+		want                 Priority
+	}{
+		{http.MethodGet, "/admin/users", "", PriorityHigh},
+		{http.MethodGet, "/admin/export/csv", "", PriorityLow},
+		{http.MethodGet, "/administrators", "", PriorityNormal},
+		{http.MethodPost, "/pay", "", PriorityCritical},
+		{http.MethodGet, "/pay", "", PriorityNormal},
+		{http.MethodGet, "/items", "low", PriorityLow},
+		{http.MethodGet, "/items", "critical", PriorityHigh},
+		{http.MethodGet, "/admin/export", "high", PriorityLow},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set("X-Priority", tt.header)
+		}
+		assert.Equal(t, tt.want, s.priority(req), "%s %s", tt.method, tt.path)
+	}
+}
+
+func TestLoadShedderCoalescesIdenticalGets(t *testing.T) {
+	var calls atomic.Int32
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	s := NewLoadShedder(LoadShedConfig{Coalesce: true})
+	h := s.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		entered <- struct{}{}
+		<-release
+		w.Header().Set("X-Backend", "1")
+		_, _ = w.Write([]byte("report"))
+	}))
+
+	const n = 5
+	recs := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := range recs {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rec *httptest.ResponseRecorder) {
+			defer wg.Done()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report", nil))
+		}(recs[i])
+		if i == 0 {
+			<-entered
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, uint64(n-1), s.Stats().Coalesced)
+	for _, rec := range recs {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "report", rec.Body.String())
+		assert.Equal(t, "1", rec.Header().Get("X-Backend"))
+	}
+
+	// Other users' requests are not merged.
+	req := httptest.NewRequest(http.MethodGet, "/report", nil)
+	req.Header.Set("Authorization", "Bearer other")
+	assert.NotEqual(t, coalesceKey(httptest.NewRequest(http.MethodGet, "/report", nil)), coalesceKey(req))
+}
+
+func TestLoadShedderCoalescedPanic(t *testing.T) {
+	s := NewLoadShedder(LoadShedConfig{Coalesce: true})
+	h := s.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	assert.PanicsWithValue(t, "boom", func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+	assert.Equal(t, 0, s.Stats().InFlight)
+}
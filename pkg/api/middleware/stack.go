@@ -33,6 +33,10 @@ type Config struct {
 	CircuitBreakerEnabled bool
 	CircuitBreakerConfig  resilience.CircuitBreakerConfig
 
+	// Load Shedding
+	LoadShedEnabled bool
+	LoadShedConfig  LoadShedConfig
+
 	// Audit Logging
 	AuditConfig audit.Config
 }
@@ -82,6 +86,12 @@ func SecurityStack(cfg Config) func(http.Handler) http.Handler {
 			h = CSRFProtection(cfg.CSRFConfig)(h)
 		}
 
+		// Load shedding: before any per-request work, inside CORS so shed
+		// responses stay readable cross-origin
+		if cfg.LoadShedEnabled {
+			h = LoadShedMiddleware(cfg.LoadShedConfig)(h)
+		}
+
 		// CORS
 		if cfg.CORSEnabled {
 			h = CORS(cfg.CORSConfig)(h)
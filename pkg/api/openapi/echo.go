@@ -0,0 +1,193 @@
+package openapi
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorSchemaName is the component describing the rest.Server error
+// envelope ({"error": ..., "code": ...}); FromEcho adds it as every
+// operation's default response.
+const ErrorSchemaName = "Error"
+
+// RouteSpec annotates an Echo route with the Go types FromEcho reflects.
+// All fields are optional; an unannotated route still gets its path
+// parameters and a default response.
+type RouteSpec struct {
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+
+	// Params is a struct whose `param` tags name the path parameters.
+	Params interface{}
+	// Query is a struct whose `query` tags name the query parameters.
+	Query interface{}
+	// Request is the JSON request body prototype, e.g. CreateUserRequest{}.
+	Request interface{}
+	// Responses maps status codes to JSON body prototypes; a nil value
+	// means no body. Defaults to 200 with no body.
+	Responses map[int]interface{}
+
+	Security []map[string][]string
+}
+
+// EchoOptions configures FromEcho.
+type EchoOptions struct {
+	Title       string
+	Version     string
+	Description string
+
+	// Routes annotates routes keyed by "METHOD /path" in Echo syntax,
+	// e.g. "GET /users/:id".
+	Routes map[string]RouteSpec
+
+	// Skip excludes routes from the document (Echo path syntax).
+	Skip func(method, path string) bool
+}
+
+// FromEcho builds an OpenAPI document from the routes registered on e,
+// reflecting request/response schemas from opts.Routes.
+func FromEcho(e *echo.Echo, opts EchoOptions) (*Document, error) {
+	doc := NewDocument(opts.Title, opts.Version)
+	doc.Info.Description = opts.Description
+	ref := NewReflector(doc)
+	ref.doc.Components.Schemas[ErrorSchemaName] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"error": {Type: "string"},
+			"code":  {Description: "pkg/errors code, or the HTTP status for framework errors"},
+		},
+		Required: []string{"error", "code"},
+	}
+
+	routes := e.Routes()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	seenTags := make(map[string]struct{})
+	for _, route := range routes {
+		if !documentedMethods[route.Method] || (opts.Skip != nil && opts.Skip(route.Method, route.Path)) {
+			continue
+		}
+		spec := opts.Routes[route.Method+" "+route.Path]
+		path := PathFromEcho(route.Path)
+
+		op := Operation{
+			OperationID: spec.OperationID,
+			Summary:     spec.Summary,
+			Description: spec.Description,
+			Tags:        spec.Tags,
+			Security:    spec.Security,
+			Responses:   make(map[string]*Response),
+		}
+		if op.OperationID == "" {
+			op.OperationID = operationID(route.Method, route.Path)
+		}
+
+		op.Parameters = ref.Parameters(spec.Params, "path")
+		if len(op.Parameters) == 0 {
+			op.Parameters = pathParamsFromPath(path)
+		}
+		op.Parameters = append(op.Parameters, ref.Parameters(spec.Query, "query")...)
+
+		if spec.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{echo.MIMEApplicationJSON: {Schema: ref.Schema(spec.Request)}},
+			}
+		}
+
+		responses := spec.Responses
+		if len(responses) == 0 {
+			responses = map[int]interface{}{http.StatusOK: nil}
+		}
+		for status, body := range responses {
+			resp := &Response{Description: http.StatusText(status)}
+			if body != nil {
+				resp.Content = map[string]MediaType{echo.MIMEApplicationJSON: {Schema: ref.Schema(body)}}
+			}
+			op.Responses[strconv.Itoa(status)] = resp
+		}
+		op.Responses["default"] = &Response{
+			Description: "Error",
+			Content: map[string]MediaType{echo.MIMEApplicationJSON: {
+				Schema: &Schema{Ref: "#/components/schemas/" + ErrorSchemaName},
+			}},
+		}
+
+		if err := doc.AddOperation(path, route.Method, op); err != nil {
+			return nil, err
+		}
+		for _, tag := range spec.Tags {
+			if _, ok := seenTags[tag]; !ok {
+				seenTags[tag] = struct{}{}
+				doc.Tags = append(doc.Tags, Tag{Name: tag})
+			}
+		}
+	}
+	return doc, nil
+}
+
+// ServeDocument returns an Echo handler serving doc as JSON.
+func ServeDocument(doc *Document) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, doc)
+	}
+}
+
+// documentedMethods are the methods a PathItem can hold; Echo's internal
+// route kinds (e.g. RouteNotFound) are skipped.
+var documentedMethods = map[string]bool{
+	http.MethodGet: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodHead: true, http.MethodOptions: true,
+}
+
+// PathFromEcho converts an Echo route path to OpenAPI syntax:
+// "/users/:id/*" becomes "/users/{id}/{*}".
+func PathFromEcho(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, ":"):
+			parts[i] = "{" + part[1:] + "}"
+		case part == "*":
+			parts[i] = "{*}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// operationID derives an ID such as "getUsersById" from a route.
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.Split(path, "/") {
+		by := strings.HasPrefix(part, ":")
+		if by {
+			b.WriteString("By")
+		}
+		upper := true
+		for _, r := range part {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				upper = true
+				continue
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,270 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/openapi"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/rest"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/validator"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Audit struct {
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type Address struct {
+	City    string `json:"city" validate:"required"`
+	Country string `json:"country" validate:"required,len=2"`
+}
+
+type CreateUserRequest struct {
+	Name     string            `json:"name" validate:"required,min=2,max=64" description:"display name"`
+	Email    string            `json:"email" validate:"required,email"`
+	Handle   string            `json:"handle" validate:"omitempty,slug"`
+	Age      int               `json:"age" validate:"gte=18,lt=130"`
+	Role     string            `json:"role" validate:"oneof=admin member"`
+	Tags     []string          `json:"tags" validate:"max=3,dive,min=1"`
+	Address  *Address          `json:"address"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Password string            `json:"-"`
+	internal string
+}
+
+type User struct {
+	ID string `json:"id" validate:"uuid"`
+	CreateUserRequest
+	Audit
+	Manager *User `json:"manager,omitempty"`
+}
+
+type UserParams struct {
+	ID string `param:"id" validate:"uuid"`
+}
+
+type ListQuery struct {
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor string `query:"cursor"`
+	Active bool   `query:"active" validate:"required"`
+	IDs    []int  `query:"id" validate:"omitempty,max=3"`
+	From   string `query:"from" validate:"omitempty,ip"`
+}
+
+func newUserAPI(t *testing.T) (*rest.Server, *openapi.Document) {
+	t.Helper()
+	srv := rest.New(rest.Config{Port: "0"})
+	e := srv.Echo()
+	v := validator.New()
+
+	e.POST("/users", func(c echo.Context) error {
+		var req CreateUserRequest
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		if err := v.ValidateStruct(c.Request().Context(), req); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusCreated, User{ID: "00000000-0000-0000-0000-000000000001", CreateUserRequest: req})
+	})
+	e.GET("/users/:id", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.GET("/users", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.RouteNotFound("/*", func(c echo.Context) error { return c.NoContent(http.StatusNotFound) })
+
+	doc, err := srv.OpenAPI("", openapi.EchoOptions{
+		Title:   "Users",
+		Version: "1.2.0",
+		Routes: map[string]openapi.RouteSpec{
+			"POST /users": {
+				OperationID: "createUser",
+				Tags:        []string{"users"},
+				Request:     CreateUserRequest{},
+				Responses:   map[int]interface{}{http.StatusCreated: User{}},
+			},
+			"GET /users/:id": {
+				Tags:      []string{"users"},
+				Params:    UserParams{},
+				Responses: map[int]interface{}{http.StatusOK: (*User)(nil), http.StatusNotFound: nil},
+			},
+			"GET /users": {
+				Query:     ListQuery{},
+				Responses: map[int]interface{}{http.StatusOK: []User{}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	e.Use(openapi.ValidateRequests(doc))
+	return srv, doc
+}
+
+func schemaOf(t *testing.T, doc *openapi.Document, name string) *openapi.Schema {
+	t.Helper()
+	s, ok := doc.Components.Schemas[name].(*openapi.Schema)
+	require.True(t, ok, "component %s", name)
+	return s
+}
+
+func TestFromEchoReflectsTypes(t *testing.T) {
+	_, doc := newUserAPI(t)
+
+	require.Len(t, doc.Paths, 2)
+	create := doc.Paths["/users"].Post
+	require.NotNil(t, create)
+	assert.Equal(t, "createUser", create.OperationID)
+	assert.Equal(t, "#/components/schemas/CreateUserRequest", create.RequestBody.Content["application/json"].Schema.Ref)
+	assert.Equal(t, "#/components/schemas/User", create.Responses["201"].Content["application/json"].Schema.Ref)
+	assert.Equal(t, "#/components/schemas/Error", create.Responses["default"].Content["application/json"].Schema.Ref)
+
+	get := doc.Paths["/users/{id}"].Get
+	require.NotNil(t, get)
+	assert.Equal(t, "getUsersById", get.OperationID)
+	require.Len(t, get.Parameters, 1)
+	assert.Equal(t, "id", get.Parameters[0].Name)
+	assert.Equal(t, "uuid", get.Parameters[0].Schema.Format)
+	assert.Nil(t, get.Responses["404"].Content)
+
+	list := doc.Paths["/users"].Get
+	require.Len(t, list.Parameters, 5)
+	assert.Equal(t, "limit", list.Parameters[0].Name)
+	assert.Equal(t, "query", list.Parameters[0].In)
+	assert.Equal(t, float64(100), *list.Parameters[0].Schema.Maximum)
+	assert.True(t, list.Parameters[2].Required)
+	assert.Equal(t, "from", list.Parameters[4].Name)
+	assert.Empty(t, list.Parameters[4].Schema.Format, "ip accepts both families, which no OpenAPI format covers")
+	assert.Equal(t, "array", list.Responses["200"].Content["application/json"].Schema.Type)
+
+	req := schemaOf(t, doc, "CreateUserRequest")
+	assert.ElementsMatch(t, []string{"name", "email"}, req.Required)
+	assert.NotContains(t, req.Properties, "Password")
+	assert.NotContains(t, req.Properties, "internal")
+	assert.Equal(t, int64(2), *req.Properties["name"].MinLength)
+	assert.Equal(t, int64(64), *req.Properties["name"].MaxLength)
+	assert.Equal(t, "display name", req.Properties["name"].Description)
+	assert.Equal(t, "email", req.Properties["email"].Format)
+	assert.Equal(t, validator.SlugPattern, req.Properties["handle"].Pattern)
+	assert.Equal(t, float64(18), *req.Properties["age"].Minimum)
+	assert.True(t, req.Properties["age"].ExclusiveMaximum)
+	assert.Equal(t, []interface{}{"admin", "member"}, req.Properties["role"].Enum)
+	assert.Equal(t, int64(3), *req.Properties["tags"].MaxItems)
+	assert.Equal(t, int64(1), *req.Properties["tags"].Items.MinLength)
+	assert.Equal(t, "#/components/schemas/Address", req.Properties["address"].Ref)
+	assert.Equal(t, "string", req.Properties["labels"].AdditionalProperties.Type)
+
+	user := schemaOf(t, doc, "User")
+	assert.Contains(t, user.Properties, "name", "embedded structs are flattened")
+	assert.Equal(t, "date-time", user.Properties["created_at"].Format)
+	assert.True(t, user.Properties["deleted_at"].Nullable)
+	assert.Equal(t, "#/components/schemas/User", user.Properties["manager"].Ref, "recursive types use $ref")
+}
+
+func TestServerServesDocument(t *testing.T) {
+	srv, _ := newUserAPI(t)
+	rec := httptest.NewRecorder()
+	srv.Echo().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "3.0.3", got["openapi"])
+	assert.NotContains(t, got["paths"], "/openapi.json")
+	schemas := got["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.Contains(t, schemas, "CreateUserRequest")
+}
+
+func TestValidateRequests(t *testing.T) {
+	srv, _ := newUserAPI(t)
+	do := func(method, target, contentType, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set(echo.HeaderContentType, contentType)
+		}
+		rec := httptest.NewRecorder()
+		srv.Echo().ServeHTTP(rec, req)
+		var envelope map[string]interface{}
+		_ = json.Unmarshal(rec.Body.Bytes(), &envelope)
+		return rec, envelope
+	}
+
+	rec, _ := do(http.MethodPost, "/users", echo.MIMEApplicationJSON,
+		`{"name":"Ada","email":"ada@example.com","age":36,"role":"admin","tags":["x"],"address":null}`)
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec, envelope := do(http.MethodPost, "/users", echo.MIMEApplicationJSON,
+		`{"name":"A","email":"nope","age":12.5,"role":"root","tags":["a","",""],"address":{"country":"GBR"}}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, errors.CodeInvalidArgument, envelope["code"])
+	msg := envelope["error"].(string)
+	for _, want := range []string{
+		"body.name: must be at least 2 characters",
+		"body.email: must be a valid email",
+		"body.age: must be a integer",
+		"body.role: must be one of [admin member]",
+		"body.tags[1]: must be at least 1 characters",
+		"body.address.city: is required",
+		"body.address.country: must be at most 2 characters",
+	} {
+		assert.Contains(t, msg, want)
+	}
+
+	rec, envelope = do(http.MethodPost, "/users", "text/plain", `{"name":"Ada"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, envelope["error"], "content type must be application/json")
+
+	rec, envelope = do(http.MethodPost, "/users", echo.MIMEApplicationJSON, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, envelope["error"], "body: is required")
+
+	rec, envelope = do(http.MethodGet, "/users/not-a-uuid", "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, envelope["error"], "path.id: must be a valid uuid")
+
+	rec, envelope = do(http.MethodGet, "/users?limit=500", "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, envelope["error"], "query.limit: must be <= 100")
+	assert.Contains(t, envelope["error"], "query.active: is required")
+
+	rec, envelope = do(http.MethodGet, "/users?limit=ten&active=true", "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, envelope["error"], "query.limit: must be of type integer")
+
+	rec, _ = do(http.MethodGet, "/users?limit=10&active=true", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec, _ = do(http.MethodGet, "/users?active=true&id=1&id=2,3&from=2001:db8::1", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code, "repeated array params and IPv6 addresses are accepted")
+
+	rec, envelope = do(http.MethodGet, "/users?active=true&id=1&id=2&id=3&id=4", "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, envelope["error"], "query.id: must have at most 3 items")
+
+	rec, envelope = do(http.MethodGet, "/users?active=true&id=1&id=x", "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, envelope["error"], "query.id: must be of type array")
+
+	rec, _ = do(http.MethodGet, "/unknown", "", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code, "undocumented routes pass through")
+}
+
+func TestValidateRequestsLimitsBodySize(t *testing.T) {
+	_, doc := newUserAPI(t)
+	srv := rest.New(rest.Config{Port: "0"})
+	srv.Echo().POST("/users", func(c echo.Context) error { return c.NoContent(http.StatusCreated) })
+	srv.Echo().Use(openapi.ValidateRequestsWithConfig(doc, openapi.ValidateConfig{MaxBodyBytes: 64}))
+
+	body := `{"name":"` + strings.Repeat("a", 100) + `","email":"ada@example.com"}`
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	srv.Echo().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "body: must be at most 64 bytes")
+}
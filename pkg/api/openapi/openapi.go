@@ -1,5 +1,7 @@
-// Package openapi provides OpenAPI 3.x document helpers generated from route metadata,
-// plus Echo↔net/http adapter bridge utilities.
+// Package openapi provides OpenAPI 3.x document helpers generated from route metadata
+// (FromRoutes) or from registered Echo routes with reflected Go request/response
+// types (FromEcho, Reflector), request validation against a document
+// (ValidateRequests), plus Echo↔net/http adapter bridge utilities.
 package openapi
 
 import (
@@ -51,10 +53,31 @@ type Parameter struct {
 	Schema      *Schema `json:"schema,omitempty"`
 }
 
-// Schema is a minimal OpenAPI schema object.
+// Schema is an OpenAPI 3.0 schema object (the JSON Schema subset produced by
+// Reflector and checked by ValidateRequests).
 type Schema struct {
-	Type   string `json:"type,omitempty"`
-	Format string `json:"format,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+	Nullable    bool   `json:"nullable,omitempty"`
+
+	Enum             []interface{} `json:"enum,omitempty"`
+	Minimum          *float64      `json:"minimum,omitempty"`
+	Maximum          *float64      `json:"maximum,omitempty"`
+	ExclusiveMinimum bool          `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum bool          `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int64        `json:"minLength,omitempty"`
+	MaxLength        *int64        `json:"maxLength,omitempty"`
+	Pattern          string        `json:"pattern,omitempty"`
+
+	Items    *Schema `json:"items,omitempty"`
+	MinItems *int64  `json:"minItems,omitempty"`
+	MaxItems *int64  `json:"maxItems,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
 }
 
 // Operation describes a single HTTP operation.
@@ -64,16 +87,31 @@ type Operation struct {
 	Description string                `json:"description,omitempty"`
 	Tags        []string              `json:"tags,omitempty"`
 	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
 	Responses   map[string]*Response  `json:"responses,omitempty"`
 	Security    []map[string][]string `json:"security,omitempty"`
 }
 
+// RequestBody describes an operation's request payload.
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType holds the schema for one content type.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
 // Response is a minimal response object.
 type Response struct {
-	Description string `json:"description"`
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
 }
 
 // Components holds reusable schemas and security schemes.
+// Schemas registered by Reflector are *Schema values.
 type Components struct {
 	Schemas         map[string]interface{} `json:"schemas,omitempty"`
 	SecuritySchemes map[string]interface{} `json:"securitySchemes,omitempty"`
@@ -0,0 +1,352 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/validator"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	componentNameRe = regexp.MustCompile(`[^A-Za-z0-9_.]+`)
+)
+
+// Reflector derives schemas from Go types. Named structs become components
+// of the document (referenced by $ref); json tags name properties and
+// pkg/validator `validate` tags become constraints.
+type Reflector struct {
+	doc   *Document
+	names map[reflect.Type]string
+	taken map[string]reflect.Type
+}
+
+// NewReflector creates a Reflector registering components on doc.
+func NewReflector(doc *Document) *Reflector {
+	if doc.Components == nil {
+		doc.Components = &Components{}
+	}
+	if doc.Components.Schemas == nil {
+		doc.Components.Schemas = make(map[string]interface{})
+	}
+	return &Reflector{
+		doc:   doc,
+		names: make(map[reflect.Type]string),
+		taken: make(map[string]reflect.Type),
+	}
+}
+
+// Schema returns the schema for v's type (a $ref for named structs).
+// v is a prototype value, e.g. CreateUserRequest{} or (*User)(nil).
+func (r *Reflector) Schema(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return r.typeSchema(reflect.TypeOf(v))
+}
+
+// Parameters returns one parameter per field of the struct v, for in
+// "path", "query" or "header". Fields are named by the Echo binding tag for
+// that location (param, query, header), falling back to json.
+func (r *Reflector) Parameters(v interface{}, in string) []Parameter {
+	if v == nil {
+		return nil
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	tag := map[string]string{"path": "param", "query": "query", "header": "header"}[in]
+
+	var params []Parameter
+	for _, f := range fields(t) {
+		name, _ := tagName(f.field, tag)
+		if name == "" {
+			name = f.name
+		}
+		if name == "" {
+			continue
+		}
+		s := r.typeSchema(f.field.Type)
+		required := applyRules(s, f.field.Tag.Get("validate"), f.field.Type)
+		params = append(params, Parameter{
+			Name:     name,
+			In:       in,
+			Required: required || in == "path",
+			Schema:   s,
+		})
+	}
+	return params
+}
+
+// Resolve follows a $ref to its component schema.
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		if d.Components == nil {
+			return nil
+		}
+		next, _ := d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")].(*Schema)
+		s = next
+	}
+	return s
+}
+
+func (r *Reflector) typeSchema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		s := r.typeSchema(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return &Schema{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.typeSchema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + r.component(t)}
+	}
+	return &Schema{}
+}
+
+// component registers t as a named component and returns its name. The
+// name is reserved before the properties are built so recursive types
+// resolve to a $ref.
+func (r *Reflector) component(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+	name := componentNameRe.ReplaceAllString(t.Name(), "_")
+	if other, ok := r.taken[name]; ok && other != t {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	r.names[t] = name
+	r.taken[name] = t
+	r.doc.Components.Schemas[name] = r.structSchema(t)
+	return name
+}
+
+func (r *Reflector) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range fields(t) {
+		prop := r.typeSchema(f.field.Type)
+		if applyRules(prop, f.field.Tag.Get("validate"), f.field.Type) {
+			s.Required = append(s.Required, f.name)
+		}
+		if desc := f.field.Tag.Get("description"); desc != "" && prop.Ref == "" {
+			prop.Description = desc
+		}
+		s.Properties[f.name] = prop
+	}
+	return s
+}
+
+// field is an exported struct field with its JSON property name.
+type field struct {
+	name  string
+	field reflect.StructField
+}
+
+// fields lists t's JSON-visible fields, flattening embedded structs the way
+// encoding/json does.
+func fields(t reflect.Type) []field {
+	var out []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := tagName(f, "json")
+		if name == "-" && !ok {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				out = append(out, fields(ft)...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		out = append(out, field{name: name, field: f})
+	}
+	return out
+}
+
+// tagName returns the name part of a struct tag; ok reports a bare trailing
+// comma, so json:"-," names a field "-" while json:"-" skips it.
+func tagName(f reflect.StructField, key string) (string, bool) {
+	name, opts, ok := strings.Cut(f.Tag.Get(key), ",")
+	return name, ok && opts == ""
+}
+
+// applyRules maps pkg/validator rules onto s and reports whether the field
+// is required. Rules after "dive" apply to array items.
+func applyRules(s *Schema, tag string, t reflect.Type) bool {
+	if tag == "" || s.Ref != "" {
+		return strings.Contains(","+tag+",", ",required,")
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	required := false
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "dive":
+			if s.Items != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+				applyRules(s.Items, strings.Join(rules[i+1:], ","), t.Elem())
+			}
+			return required
+		case "min", "gte":
+			setBound(s, t, param, true, false)
+		case "max", "lte":
+			setBound(s, t, param, false, false)
+		case "gt":
+			setBound(s, t, param, true, true)
+		case "lt":
+			setBound(s, t, param, false, true)
+		case "len":
+			setBound(s, t, param, true, false)
+			setBound(s, t, param, false, false)
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				if s.Type == "integer" || s.Type == "number" {
+					if n, err := strconv.ParseFloat(v, 64); err == nil {
+						s.Enum = append(s.Enum, n)
+						continue
+					}
+				}
+				s.Enum = append(s.Enum, v)
+			}
+		case "email":
+			s.Format = "email"
+		case "url", "uri", "http_url":
+			s.Format = "uri"
+		case "uuid", "uuid3", "uuid4", "uuid5":
+			s.Format = "uuid"
+		case "ipv4", "ipv6", "hostname":
+			s.Format = name
+		case "ip":
+			// OpenAPI has no format for either family; ipv4 would reject
+			// IPv6 addresses the validator accepts.
+		case "datetime":
+			if param == time.RFC3339 || param == "" {
+				s.Format = "date-time"
+			}
+		case "alpha":
+			s.Pattern = `^[a-zA-Z]+$`
+		case "alphanum":
+			s.Pattern = `^[a-zA-Z0-9]+$`
+		case "numeric":
+			s.Pattern = `^[-+]?[0-9]+(?:\.[0-9]+)?$`
+		case "slug":
+			s.Pattern = validator.SlugPattern
+		case "phone_e164":
+			s.Pattern = validator.PhonePattern
+		case "password_strong":
+			s.Format = "password"
+			setBound(s, t, "8", true, false)
+		}
+	}
+	return required
+}
+
+// setBound applies a min/max rule: a length for strings, an item count for
+// arrays, a value for numbers.
+func setBound(s *Schema, t reflect.Type, param string, lower, exclusive bool) {
+	switch t.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Interface:
+		return
+	case reflect.String, reflect.Slice, reflect.Array:
+		n, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return
+		}
+		if exclusive {
+			if lower {
+				n++
+			} else {
+				n--
+			}
+		}
+		switch {
+		case t.Kind() == reflect.String && lower:
+			s.MinLength = &n
+		case t.Kind() == reflect.String:
+			s.MaxLength = &n
+		case lower:
+			s.MinItems = &n
+		default:
+			s.MaxItems = &n
+		}
+	default:
+		f, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		if lower {
+			s.Minimum, s.ExclusiveMinimum = &f, exclusive
+		} else {
+			s.Maximum, s.ExclusiveMaximum = &f, exclusive
+		}
+	}
+}
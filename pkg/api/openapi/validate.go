@@ -0,0 +1,397 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// maxValidationIssues caps how many problems one rejection reports.
+const maxValidationIssues = 10
+
+// ValidateConfig configures ValidateRequestsWithConfig.
+type ValidateConfig struct {
+	// MaxBodyBytes caps the request body read for validation (default
+	// 1 MiB). Larger bodies are rejected.
+	MaxBodyBytes int64
+}
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidateRequests returns Echo middleware that checks path and query
+// parameters and JSON bodies against doc. Non-conforming requests fail with
+// errors.InvalidArgument, which rest.Server renders in the standard error
+// envelope. Routes missing from doc pass through unchecked.
+func ValidateRequests(doc *Document) echo.MiddlewareFunc {
+	return ValidateRequestsWithConfig(doc, ValidateConfig{})
+}
+
+// ValidateRequestsWithConfig is ValidateRequests with a body size limit.
+func ValidateRequestsWithConfig(doc *Document, cfg ValidateConfig) echo.MiddlewareFunc {
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 1 << 20
+	}
+	v := &requestValidator{doc: doc, cfg: cfg, ops: make(map[string]*Operation)}
+	for path, item := range doc.Paths {
+		for method, op := range item.operations() {
+			v.ops[method+" "+path] = op
+		}
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			op := v.ops[c.Request().Method+" "+PathFromEcho(c.Path())]
+			if op == nil {
+				return next(c)
+			}
+			if err := v.validate(c, op); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// operations lists the item's operations by HTTP method.
+func (p *PathItem) operations() map[string]*Operation {
+	out := make(map[string]*Operation)
+	for method, op := range map[string]*Operation{
+		"GET": p.Get, "POST": p.Post, "PUT": p.Put, "PATCH": p.Patch,
+		"DELETE": p.Delete, "HEAD": p.Head, "OPTIONS": p.Options,
+	} {
+		if op != nil {
+			out[method] = op
+		}
+	}
+	return out
+}
+
+type requestValidator struct {
+	doc *Document
+	cfg ValidateConfig
+	ops map[string]*Operation
+
+	patterns sync.Map // pattern string → *regexp.Regexp
+}
+
+// issues collects validation problems up to maxValidationIssues.
+type issues []string
+
+func (is *issues) add(path, format string, args ...interface{}) {
+	if len(*is) < maxValidationIssues {
+		*is = append(*is, path+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *requestValidator) validate(c echo.Context, op *Operation) error {
+	var found issues
+	for _, p := range op.Parameters {
+		var raw string
+		var present bool
+		switch p.In {
+		case "path":
+			raw = c.Param(p.Name)
+			present = raw != ""
+		case "query":
+			values := c.QueryParams()[p.Name]
+			present = len(values) > 0
+			if s := v.doc.Resolve(p.Schema); s != nil && s.Type == "array" {
+				// Both ?id=1&id=2 and ?id=1,2 carry every item.
+				raw = strings.Join(values, ",")
+			} else if present {
+				raw = values[0]
+			}
+		case "header":
+			raw = c.Request().Header.Get(p.Name)
+			present = raw != ""
+		default:
+			continue
+		}
+		where := p.In + "." + p.Name
+		if !present {
+			if p.Required {
+				found.add(where, "is required")
+			}
+			continue
+		}
+		if p.Schema == nil {
+			continue
+		}
+		if value, ok := v.coerce(raw, v.doc.Resolve(p.Schema)); ok {
+			v.check(&found, where, p.Schema, value)
+		} else {
+			found.add(where, "must be of type %s", p.Schema.Type)
+		}
+	}
+
+	if op.RequestBody != nil {
+		v.checkBody(c, op.RequestBody, &found)
+	}
+
+	if len(found) > 0 {
+		return errors.InvalidArgument("request validation failed: "+strings.Join(found, "; "), nil)
+	}
+	return nil
+}
+
+func (v *requestValidator) checkBody(c echo.Context, body *RequestBody, found *issues) {
+	req := c.Request()
+	var raw []byte
+	if req.Body != nil {
+		var err error
+		raw, err = io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, v.cfg.MaxBodyBytes))
+		var tooLarge *http.MaxBytesError
+		if stderrors.As(err, &tooLarge) {
+			found.add("body", "must be at most %d bytes", v.cfg.MaxBodyBytes)
+			return
+		}
+		if err != nil {
+			found.add("body", "could not be read")
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(raw))
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		if body.Required {
+			found.add("body", "is required")
+		}
+		return
+	}
+
+	media, ok := body.Content[echo.MIMEApplicationJSON]
+	if !ok {
+		return
+	}
+	if ct, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType)); ct != echo.MIMEApplicationJSON {
+		found.add("body", "content type must be %s", echo.MIMEApplicationJSON)
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		found.add("body", "is not valid JSON")
+		return
+	}
+	if media.Schema != nil {
+		v.check(found, "body", media.Schema, value)
+	}
+}
+
+// coerce converts a raw parameter string to the JSON value its schema
+// expects.
+func (v *requestValidator) coerce(raw string, s *Schema) (interface{}, bool) {
+	if s == nil {
+		return raw, true
+	}
+	switch s.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, false
+		}
+		return json.Number(raw), true
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		return b, err == nil
+	case "array":
+		var out []interface{}
+		for _, part := range strings.Split(raw, ",") {
+			item, ok := v.coerce(part, v.doc.Resolve(s.Items))
+			if !ok {
+				return nil, false
+			}
+			out = append(out, item)
+		}
+		return out, true
+	}
+	return raw, true
+}
+
+// check validates a decoded JSON value against s.
+func (v *requestValidator) check(found *issues, path string, s *Schema, value interface{}) {
+	isRef := s.Ref != ""
+	s = v.doc.Resolve(s)
+	if s == nil {
+		return
+	}
+	if value == nil {
+		// $ref schemas cannot carry nullable in 3.0; they come from
+		// (usually pointer) struct fields, so null is accepted.
+		if !s.Nullable && !isRef && s.Type != "" {
+			found.add(path, "must not be null")
+		}
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		found.add(path, "must be one of %v", s.Enum)
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			found.add(path, "must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				found.add(path+"."+name, "is required")
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := obj[name]
+			if prop, ok := s.Properties[name]; ok {
+				v.check(found, path+"."+name, prop, child)
+			} else if s.AdditionalProperties != nil {
+				v.check(found, path+"."+name, s.AdditionalProperties, child)
+			}
+		}
+
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			found.add(path, "must be an array")
+			return
+		}
+		if s.MinItems != nil && int64(len(arr)) < *s.MinItems {
+			found.add(path, "must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && int64(len(arr)) > *s.MaxItems {
+			found.add(path, "must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				v.check(found, path+"["+strconv.Itoa(i)+"]", s.Items, item)
+			}
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			found.add(path, "must be a string")
+			return
+		}
+		n := int64(utf8.RuneCountInString(str))
+		if s.MinLength != nil && n < *s.MinLength {
+			found.add(path, "must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			found.add(path, "must be at most %d characters", *s.MaxLength)
+		}
+		if s.Pattern != "" && !v.pattern(s.Pattern).MatchString(str) {
+			found.add(path, "must match %s", s.Pattern)
+		}
+		if !validFormat(s.Format, str) {
+			found.add(path, "must be a valid %s", s.Format)
+		}
+
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			found.add(path, "must be a %s", s.Type)
+			return
+		}
+		f, err := num.Float64()
+		if err != nil || (s.Type == "integer" && f != float64(int64(f))) {
+			found.add(path, "must be a %s", s.Type)
+			return
+		}
+		if s.Minimum != nil && (f < *s.Minimum || (s.ExclusiveMinimum && f == *s.Minimum)) {
+			found.add(path, "must be %s %v", cmp(">=", ">", s.ExclusiveMinimum), *s.Minimum)
+		}
+		if s.Maximum != nil && (f > *s.Maximum || (s.ExclusiveMaximum && f == *s.Maximum)) {
+			found.add(path, "must be %s %v", cmp("<=", "<", s.ExclusiveMaximum), *s.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			found.add(path, "must be a boolean")
+		}
+	}
+}
+
+func (v *requestValidator) pattern(p string) *regexp.Regexp {
+	if re, ok := v.patterns.Load(p); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		re = regexp.MustCompile(".*")
+	}
+	v.patterns.Store(p, re)
+	return re
+}
+
+func cmp(inclusive, exclusive string, isExclusive bool) string {
+	if isExclusive {
+		return exclusive
+	}
+	return inclusive
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if num, ok := value.(json.Number); ok {
+			if f, err := num.Float64(); err == nil && e == f {
+				return true
+			}
+			continue
+		}
+		if e == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validFormat checks the string formats Reflector emits; unknown formats pass.
+func validFormat(format, s string) bool {
+	var err error
+	switch format {
+	case "email":
+		_, err = mail.ParseAddress(s)
+	case "uuid":
+		return uuidRe.MatchString(s)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, s)
+	case "date":
+		_, err = time.Parse(time.DateOnly, s)
+	case "uri":
+		var u *url.URL
+		u, err = url.ParseRequestURI(s)
+		if err == nil && u.Scheme == "" {
+			return false
+		}
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil
+	case "ipv6":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() == nil
+	case "byte":
+		_, err = base64.StdEncoding.DecodeString(s)
+	}
+	return err == nil
+}
@@ -1,12 +1,16 @@
 package ratelimit
 
 import (
+	"context"
+	"time"
+
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/algorithms/ratelimit"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/algorithms/ratelimit/fixedwindow"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/algorithms/ratelimit/leakybucket"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/algorithms/ratelimit/slidingwindow"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/algorithms/ratelimit/tokenbucket"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 )
 
 // Strategy defines the rate limiting algorithm.
@@ -22,6 +26,18 @@ const (
 // Re-export types for backward compatibility
 type Result = ratelimit.Result
 type Limiter = ratelimit.Limiter
+type CostLimiter = ratelimit.CostLimiter
+
+// AllowN charges n units against key in one atomic step. The limiter must
+// implement CostLimiter; asking a plain Limiter n times could not be undone
+// on a partial denial, so it fails with InvalidArgument instead.
+func AllowN(ctx context.Context, l Limiter, key string, n, limit int64, period time.Duration) (*Result, error) {
+	cl, ok := l.(CostLimiter)
+	if !ok {
+		return nil, errors.InvalidArgument("limiter does not implement ratelimit.CostLimiter", nil)
+	}
+	return cl.AllowN(ctx, key, n, limit, period)
+}
 
 // Factory creates a limiter based on strategy
 // Delegates to algorithms package
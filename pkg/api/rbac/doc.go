@@ -3,6 +3,8 @@ Package rbac provides an in-memory Role-Based Access Control enforcer with wildc
 permissions and SmartRWMutex-guarded policy updates.
 
 Pair with middleware.RequirePermission and AuthMiddleware (or pkg/auth.MiddlewareVerifier)
-for HTTP enforcement. This is not a Casbin/OPA replacement.
+for HTTP enforcement. This is not a Casbin/OPA replacement; for role hierarchies,
+resource patterns, tenants, deny rules and attribute conditions use the policy
+subpackage, whose Engine implements the same Enforcer interface.
 */
 package rbac
@@ -0,0 +1,51 @@
+/*
+Package policy is a policy-based authorization engine implementing
+rbac.Enforcer, for cases SimpleEnforcer's flat role→permission map cannot
+express.
+
+A PolicySet holds roles with inheritance and allow/deny policies. Each
+policy names roles or subject IDs, resource patterns, actions, an optional
+tenant and an optional ABAC condition over subject, resource and environment
+attributes (see Condition). Decisions are deny-overrides: a matching deny
+wins over any allow, and requests no policy matches are denied. Explain
+returns the same decision with a per-policy trace of why each matched or not.
+
+Resource patterns are slash-separated: "*" matches one segment, a trailing
+"**" any number of segments, "{name}" one segment captured as
+resource.params.name, and other segments are path.Match globs. A pattern of
+"*" alone matches every resource.
+
+Policy sets are JSON and load from a FileSource or an AppConfigSource; Watch
+hot-reloads them, keeping the current policies when a new document is
+invalid.
+
+Usage:
+
+	engine, err := policy.NewFromSource(ctx, policy.FileSource{Path: "policies.json"})
+	engine.Watch(ctx, policy.FileSource{Path: "policies.json"}, 30*time.Second)
+
+	// As an rbac.Enforcer behind AuthMiddleware:
+	mux.Handle("/docs/", middleware.RequirePermission(engine, "documents", "read")(h))
+
+	// Or with attributes and a trace:
+	d, err := engine.Explain(ctx, policy.Request{
+		Subject:  policy.Subject{ID: "u1", Roles: []string{"editor"}, Tenant: "acme"},
+		Resource: "documents/42",
+		Action:   "update",
+		ResourceAttributes: map[string]any{"owner": "u1"},
+	})
+
+A policy document:
+
+	{
+	  "roles": [{"name": "admin", "inherits": ["editor"]}, {"name": "editor", "inherits": ["viewer"]}],
+	  "policies": [
+	    {"id": "read-docs", "effect": "allow", "roles": ["viewer"], "resources": ["documents/**"], "actions": ["read"]},
+	    {"id": "edit-own", "effect": "allow", "roles": ["editor"], "resources": ["documents/{id}"],
+	     "actions": ["update", "delete"], "condition": "resource.owner == subject.id"},
+	    {"id": "freeze", "effect": "deny", "roles": ["*"], "resources": ["documents/**"],
+	     "actions": ["update", "delete"], "condition": "env.hour < 6"}
+	  ]
+	}
+*/
+package policy
@@ -0,0 +1,603 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+// Condition is a compiled ABAC expression.
+//
+// Grammar:
+//
+//	expr    = or
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "in" ) operand ]
+//	operand = literal | path | call | "(" expr ")" | "[" [ expr { "," expr } ] "]"
+//	path    = ( "subject" | "resource" | "env" | "action" ) { "." ident }
+//	call    = ident "(" [ expr { "," expr } ] ")"
+//
+// Literals are double- or single-quoted strings, numbers, true, false and
+// null. Missing attributes evaluate to null. Functions: startsWith(s, p),
+// endsWith(s, p), contains(s, sub) and matches(s, pattern), where pattern
+// uses the resource pattern syntax.
+//
+//	subject.department == resource.department && env.hour >= 9
+//	"auditor" in subject.groups || resource.params.owner == subject.id
+type Condition struct {
+	src  string
+	root node
+}
+
+// Compile parses src into a Condition.
+func Compile(src string) (*Condition, error) {
+	p := &parser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	return &Condition{src: src, root: root}, nil
+}
+
+// String returns the source of the condition.
+func (c *Condition) String() string {
+	return c.src
+}
+
+// Eval evaluates the condition against vars, keyed by the path roots
+// subject, resource, env and action. A non-boolean result is an error.
+func (c *Condition) Eval(vars map[string]any) (bool, error) {
+	v, err := c.root.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, errors.InvalidArgument(fmt.Sprintf("condition %q evaluated to %T, not bool", c.src, v), nil)
+	}
+	return b, nil
+}
+
+// --- lexer ---
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	i      int
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	return errors.InvalidArgument(fmt.Sprintf("condition %q: %s at offset %d", p.src, msg, t.pos), nil)
+}
+
+var twoCharOps = []string{"==", "!=", "<=", ">=", "&&", "||"}
+
+func (p *parser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			var b strings.Builder
+			for ; j < len(s) && rune(s[j]) != c; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return p.errorf(token{pos: i}, "unterminated string")
+			}
+			p.tokens = append(p.tokens, token{kind: tokString, text: b.String(), pos: i})
+			i = j + 1
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(s) && unicode.IsDigit(rune(s[i+1])) && p.expectsOperand()):
+			j := i + 1
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.') {
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind: tokNumber, text: s[i:j], pos: i})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind: tokIdent, text: s[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			for _, o := range twoCharOps {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" && strings.ContainsRune("!<>().,[]", c) {
+				op = string(c)
+			}
+			if op == "" {
+				return p.errorf(token{pos: i}, "unexpected character %q", c)
+			}
+			p.tokens = append(p.tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	p.tokens = append(p.tokens, token{kind: tokEOF, pos: len(s)})
+	return nil
+}
+
+// expectsOperand reports whether a '-' at this point starts a negative
+// number rather than following an operand.
+func (p *parser) expectsOperand() bool {
+	if len(p.tokens) == 0 {
+		return true
+	}
+	last := p.tokens[len(p.tokens)-1]
+	return last.kind == tokOp && last.text != ")" && last.text != "]" ||
+		last.kind == tokIdent && last.text == "in"
+}
+
+// --- parser ---
+
+func (p *parser) peek() token { return p.tokens[p.i] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return p.errorf(t, "expected %q, got %q", op, t.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseCompare()
+}
+
+var compareOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if (t.kind == tokOp && compareOps[t.text]) || (t.kind == tokIdent && t.text == "in") {
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{op: t.text, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+var pathRoots = map[string]bool{"subject": true, "resource": true, "env": true, "action": true}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literal{t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %q", t.text)
+		}
+		return literal{f}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{items: items}, nil
+		}
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		if p.accept("(") {
+			fn, ok := functions[t.text]
+			if !ok {
+				return nil, p.errorf(t, "unknown function %q", t.text)
+			}
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			if len(args) != fn.arity {
+				return nil, p.errorf(t, "%s takes %d arguments, got %d", t.text, fn.arity, len(args))
+			}
+			return &callNode{name: t.text, fn: fn.call, args: args}, nil
+		}
+		if !pathRoots[t.text] {
+			return nil, p.errorf(t, "unknown identifier %q (paths start with subject, resource, env or action)", t.text)
+		}
+		path := []string{t.text}
+		for p.accept(".") {
+			seg := p.next()
+			if seg.kind != tokIdent {
+				return nil, p.errorf(seg, "expected attribute name after '.'")
+			}
+			path = append(path, seg.text)
+		}
+		return pathNode(path), nil
+	}
+	return nil, p.errorf(t, "unexpected %q", t.text)
+}
+
+func (p *parser) parseList(end string) ([]node, error) {
+	var items []node
+	if p.accept(end) {
+		return items, nil
+	}
+	for {
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, n)
+		if p.accept(end) {
+			return items, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// --- evaluation ---
+
+type node interface {
+	eval(vars map[string]any) (any, error)
+}
+
+type literal struct{ v any }
+
+func (l literal) eval(map[string]any) (any, error) { return l.v, nil }
+
+type pathNode []string
+
+func (p pathNode) eval(vars map[string]any) (any, error) {
+	var cur any = vars
+	for _, seg := range p {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		cur = m[seg]
+	}
+	return normalize(cur), nil
+}
+
+type listNode struct{ items []node }
+
+func (l *listNode) eval(vars map[string]any) (any, error) {
+	out := make([]any, len(l.items))
+	for i, item := range l.items {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+type logicalNode struct {
+	or          bool
+	left, right node
+}
+
+func (n *logicalNode) eval(vars map[string]any) (any, error) {
+	l, err := evalBool(n.left, vars)
+	if err != nil {
+		return nil, err
+	}
+	if l == n.or {
+		return l, nil
+	}
+	return evalBool(n.right, vars)
+}
+
+type notNode struct{ operand node }
+
+func (n *notNode) eval(vars map[string]any) (any, error) {
+	b, err := evalBool(n.operand, vars)
+	return !b, err
+}
+
+func evalBool(n node, vars map[string]any) (bool, error) {
+	v, err := n.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case nil:
+		return false, nil
+	}
+	return false, errors.InvalidArgument(fmt.Sprintf("expected bool, got %T", v), nil)
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(vars map[string]any) (any, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		return contains(r, l), nil
+	}
+	c, ok := order(l, r)
+	if !ok {
+		// Ordering mismatched or missing values is never true.
+		return false, nil
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+type callNode struct {
+	name string
+	fn   func(args []any) (any, error)
+	args []node
+}
+
+func (n *callNode) eval(vars map[string]any) (any, error) {
+	args := make([]any, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return n.fn(args)
+}
+
+var functions = map[string]struct {
+	arity int
+	call  func(args []any) (any, error)
+}{
+	"startsWith": {2, stringFunc(strings.HasPrefix)},
+	"endsWith":   {2, stringFunc(strings.HasSuffix)},
+	"contains":   {2, stringFunc(strings.Contains)},
+	"matches": {2, func(args []any) (any, error) {
+		s, ok1 := args[0].(string)
+		pattern, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return false, nil
+		}
+		pat, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		_, ok := pat.match(s)
+		return ok, nil
+	}},
+}
+
+func stringFunc(f func(s, t string) bool) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		s, ok1 := args[0].(string)
+		t, ok2 := args[1].(string)
+		return ok1 && ok2 && f(s, t), nil
+	}
+}
+
+// normalize maps attribute values onto the evaluator's types: numbers
+// become float64 and slices []any.
+func normalize(v any) any {
+	switch x := v.(type) {
+	case nil, bool, string, float64, []any, map[string]any:
+		return v
+	case int:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case []string:
+		out := make([]any, len(x))
+		for i, s := range x {
+			out[i] = s
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]any, len(x))
+		for k, s := range x {
+			out[k] = s
+		}
+		return out
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice {
+		out := make([]any, rv.Len())
+		for i := range out {
+			out[i] = normalize(rv.Index(i).Interface())
+		}
+		return out
+	}
+	return v
+}
+
+func equal(a, b any) bool {
+	a, b = normalize(a), normalize(b)
+	switch a.(type) {
+	case []any, map[string]any:
+		return reflect.DeepEqual(a, b)
+	}
+	switch b.(type) {
+	case []any, map[string]any:
+		return false
+	}
+	return a == b
+}
+
+func contains(container, v any) bool {
+	switch c := normalize(container).(type) {
+	case []any:
+		for _, item := range c {
+			if equal(item, v) {
+				return true
+			}
+		}
+	case map[string]any:
+		if k, ok := v.(string); ok {
+			_, found := c[k]
+			return found
+		}
+	}
+	return false
+}
+
+func order(a, b any) (int, bool) {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	}
+	return 0, false
+}
@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+// pattern matches slash-separated resource names:
+//
+//	documents         exactly "documents"
+//	documents/*       one segment below documents
+//	documents/**      anything below documents, including documents itself
+//	documents/{id}    one segment, captured as resource.params.id
+//	reports/*.pdf     path.Match globs within a segment
+//	*                 any resource
+type pattern struct {
+	src      string
+	any      bool
+	segments []string
+}
+
+func compilePattern(src string) (*pattern, error) {
+	src = strings.Trim(strings.TrimSpace(src), "/")
+	if src == "" {
+		return nil, errors.InvalidArgument("empty resource pattern", nil)
+	}
+	if src == "*" || src == "**" {
+		return &pattern{src: src, any: true}, nil
+	}
+	segs := strings.Split(src, "/")
+	for i, seg := range segs {
+		if seg == "**" && i != len(segs)-1 {
+			return nil, errors.InvalidArgument(fmt.Sprintf("resource pattern %q: ** is only allowed as the last segment", src), nil)
+		}
+		if isParam(seg) {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return nil, errors.InvalidArgument(fmt.Sprintf("resource pattern %q", src), err)
+		}
+	}
+	return &pattern{src: src, segments: segs}, nil
+}
+
+func isParam(seg string) bool {
+	return len(seg) > 2 && seg[0] == '{' && seg[len(seg)-1] == '}'
+}
+
+// match reports whether resource matches, returning captured parameters.
+func (p *pattern) match(resource string) (map[string]string, bool) {
+	if p.any {
+		return nil, true
+	}
+	segs := strings.Split(strings.Trim(resource, "/"), "/")
+	var params map[string]string
+	for i, want := range p.segments {
+		if want == "**" {
+			return params, true
+		}
+		if i >= len(segs) {
+			return nil, false
+		}
+		got := segs[i]
+		switch {
+		case isParam(want):
+			if got == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[want[1:len(want)-1]] = got
+		case want == got:
+		default:
+			if ok, _ := path.Match(want, got); !ok {
+				return nil, false
+			}
+		}
+	}
+	if len(segs) != len(p.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// matchAction reports whether action matches a policy action, which may be
+// "*" or a glob such as "read*".
+func matchAction(want, action string) bool {
+	if want == "*" || want == action {
+		return true
+	}
+	ok, _ := path.Match(want, action)
+	return ok
+}
@@ -0,0 +1,504 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/middleware"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/rbac"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+// Effect is the outcome a policy grants when it matches.
+type Effect string
+
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+)
+
+// PolicySet is the document loaded from files or the appconfig service.
+type PolicySet struct {
+	Roles    []Role   `json:"roles,omitempty"`
+	Policies []Policy `json:"policies"`
+}
+
+// Role declares inheritance: a subject with Name also holds every role in
+// Inherits, transitively.
+type Role struct {
+	Name     string   `json:"name"`
+	Inherits []string `json:"inherits,omitempty"`
+}
+
+// Policy grants or denies Actions on Resources to Roles or Subjects.
+type Policy struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Effect      Effect `json:"effect"`
+
+	// Roles the policy applies to; "*" matches any subject. A policy with
+	// neither Roles nor Subjects applies to nobody.
+	Roles []string `json:"roles,omitempty"`
+	// Subjects lists subject IDs the policy applies to regardless of role.
+	Subjects []string `json:"subjects,omitempty"`
+
+	// Resources are resource patterns; see the package documentation.
+	Resources []string `json:"resources"`
+	// Actions may contain "*" or globs such as "read*".
+	Actions []string `json:"actions"`
+
+	// Tenant limits the policy to subjects of one tenant. Empty applies to
+	// all tenants.
+	Tenant string `json:"tenant,omitempty"`
+
+	// Condition is an optional expression that must evaluate to true; see
+	// Condition.
+	Condition string `json:"condition,omitempty"`
+}
+
+// Subject is the principal of a Request.
+type Subject struct {
+	ID         string
+	Roles      []string
+	Tenant     string
+	Attributes map[string]any
+}
+
+// Request is an authorization question.
+type Request struct {
+	Subject  Subject
+	Resource string
+	// ResourceAttributes are exposed to conditions under resource.*.
+	ResourceAttributes map[string]any
+	Action             string
+	// Env is exposed under env.*; env.hour and env.weekday default to the
+	// engine's clock.
+	Env map[string]any
+}
+
+// Decision is the engine's answer.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Effect  Effect `json:"effect"`
+	// Policy is the ID of the deciding policy, empty for the default deny.
+	Policy string `json:"policy,omitempty"`
+	Reason string `json:"reason"`
+	// Trace lists every policy considered, in order; only Explain fills it.
+	Trace []Step `json:"trace,omitempty"`
+}
+
+// Step records how one policy evaluated.
+type Step struct {
+	Policy  string `json:"policy"`
+	Effect  Effect `json:"effect"`
+	Matched bool   `json:"matched"`
+	Reason  string `json:"reason"`
+}
+
+// compiled is an immutable, ready-to-evaluate PolicySet.
+type compiled struct {
+	// inherits maps each role to every role it implies, itself included.
+	inherits map[string][]string
+	policies []*compiledPolicy
+}
+
+type compiledPolicy struct {
+	Policy
+	resources []*pattern
+	condition *Condition
+}
+
+// Engine evaluates a PolicySet with deny-overrides: any matching deny wins,
+// otherwise any matching allow, otherwise the request is denied.
+type Engine struct {
+	mu    *concurrency.SmartRWMutex
+	set   *compiled
+	base  PolicySet
+	added []Policy
+	now   func() time.Time
+}
+
+var _ rbac.Enforcer = (*Engine)(nil)
+
+// New compiles set into an Engine.
+func New(set PolicySet) (*Engine, error) {
+	c, err := compile(set)
+	if err != nil {
+		return nil, err
+	}
+	return &Engine{
+		mu:   concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "rbac-policy-engine"}),
+		set:  c,
+		base: set,
+		now:  time.Now,
+	}, nil
+}
+
+// Load replaces the engine's policy set. The engine keeps its previous set
+// when set does not compile. Policies added with AddPolicy are kept.
+func (e *Engine) Load(set PolicySet) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, err := compile(withPolicies(set, e.added))
+	if err != nil {
+		return err
+	}
+	e.set = c
+	e.base = set
+	return nil
+}
+
+// AddPolicy implements rbac.Enforcer by adding an allow policy for role. An
+// invalid resource pattern is returned and leaves the engine unchanged.
+func (e *Engine) AddPolicy(role string, resource string, action string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	p := Policy{
+		ID:        "added-" + strconv.Itoa(len(e.added)+1),
+		Effect:    Allow,
+		Roles:     []string{role},
+		Resources: []string{resource},
+		Actions:   []string{action},
+	}
+	c, err := compile(withPolicies(e.base, append(e.added, p)))
+	if err != nil {
+		return err
+	}
+	e.added = append(e.added, p)
+	e.set = c
+	return nil
+}
+
+// Enforce implements rbac.Enforcer. The subject ID, tenant and attributes
+// come from WithSubject when present, else the ID from middleware
+// authentication; role replaces the subject's roles. Resource attributes
+// and env come from WithResourceAttributes and WithEnv.
+func (e *Engine) Enforce(ctx context.Context, role string, resource string, action string) (bool, error) {
+	sub, ok := SubjectFromContext(ctx)
+	if !ok {
+		sub.ID = middleware.GetSubject(ctx)
+	}
+	sub.Roles = []string{role}
+	d, err := e.Evaluate(ctx, Request{
+		Subject:            sub,
+		Resource:           resource,
+		ResourceAttributes: resourceAttributesFromContext(ctx),
+		Action:             action,
+		Env:                envFromContext(ctx),
+	})
+	return d.Allowed, err
+}
+
+// Evaluate decides req.
+func (e *Engine) Evaluate(ctx context.Context, req Request) (Decision, error) {
+	return e.evaluate(req, false)
+}
+
+// Explain decides req and records how every policy evaluated.
+func (e *Engine) Explain(ctx context.Context, req Request) (Decision, error) {
+	return e.evaluate(req, true)
+}
+
+func (e *Engine) evaluate(req Request, trace bool) (Decision, error) {
+	if req.Action == "" {
+		return Decision{}, errors.InvalidArgument("action is required", nil)
+	}
+	e.mu.RLock()
+	set := e.set
+	e.mu.RUnlock()
+
+	roles := set.effectiveRoles(req.Subject.Roles)
+	vars := e.vars(req, roles)
+
+	var allow, deny *compiledPolicy
+	var steps []Step
+	for _, p := range set.policies {
+		matched, reason := p.matches(req, roles, vars)
+		if trace {
+			steps = append(steps, Step{Policy: p.ID, Effect: p.Effect, Matched: matched, Reason: reason})
+		}
+		if !matched {
+			continue
+		}
+		if p.Effect == Deny && deny == nil {
+			deny = p
+			if !trace {
+				break
+			}
+		}
+		if p.Effect == Allow && allow == nil {
+			allow = p
+		}
+	}
+
+	d := Decision{Effect: Deny, Reason: "no policy matched", Trace: steps}
+	switch {
+	case deny != nil:
+		d.Policy = deny.ID
+		d.Reason = "denied by policy " + deny.ID
+	case allow != nil:
+		d.Allowed = true
+		d.Effect = Allow
+		d.Policy = allow.ID
+		d.Reason = "allowed by policy " + allow.ID
+	}
+	return d, nil
+}
+
+func (e *Engine) vars(req Request, roles []string) map[string]any {
+	subject := make(map[string]any, len(req.Subject.Attributes)+3)
+	for k, v := range req.Subject.Attributes {
+		subject[k] = v
+	}
+	subject["id"] = req.Subject.ID
+	subject["tenant"] = req.Subject.Tenant
+	subject["roles"] = roles
+
+	resource := make(map[string]any, len(req.ResourceAttributes)+2)
+	for k, v := range req.ResourceAttributes {
+		resource[k] = v
+	}
+	resource["path"] = req.Resource
+
+	env := make(map[string]any, len(req.Env)+2)
+	now := e.now()
+	env["hour"] = now.Hour()
+	env["weekday"] = now.Weekday().String()
+	for k, v := range req.Env {
+		env[k] = v
+	}
+	return map[string]any{"subject": subject, "resource": resource, "env": env, "action": req.Action}
+}
+
+// matches reports whether p applies to req, with the reason for the trace.
+// A condition that fails to evaluate matches deny policies and not allow
+// policies, so errors never widen access.
+func (p *compiledPolicy) matches(req Request, roles []string, vars map[string]any) (bool, string) {
+	if p.Tenant != "" && p.Tenant != req.Subject.Tenant {
+		return false, "tenant mismatch"
+	}
+	if !p.appliesTo(req.Subject.ID, roles) {
+		return false, "subject has none of the policy's roles"
+	}
+	actionOK := false
+	for _, a := range p.Actions {
+		if matchAction(a, req.Action) {
+			actionOK = true
+			break
+		}
+	}
+	if !actionOK {
+		return false, "action not covered"
+	}
+	var params map[string]string
+	resourceOK := false
+	for _, pat := range p.resources {
+		if params, resourceOK = pat.match(req.Resource); resourceOK {
+			break
+		}
+	}
+	if !resourceOK {
+		return false, "resource not covered"
+	}
+	if p.condition == nil {
+		return true, "matched"
+	}
+
+	resource := vars["resource"].(map[string]any)
+	resource["params"] = normalize(params)
+	ok, err := p.condition.Eval(vars)
+	delete(resource, "params")
+	if err != nil {
+		return p.Effect == Deny, "condition error: " + err.Error()
+	}
+	if !ok {
+		return false, "condition false: " + p.Condition
+	}
+	return true, "matched with condition: " + p.Condition
+}
+
+func (p *compiledPolicy) appliesTo(subject string, roles []string) bool {
+	for _, s := range p.Subjects {
+		if subject != "" && s == subject {
+			return true
+		}
+	}
+	for _, want := range p.Roles {
+		if want == "*" {
+			return true
+		}
+		for _, r := range roles {
+			if r == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// effectiveRoles expands roles through the hierarchy.
+func (c *compiled) effectiveRoles(roles []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, r := range roles {
+		implied, ok := c.inherits[r]
+		if !ok {
+			implied = []string{r}
+		}
+		for _, ir := range implied {
+			if !seen[ir] {
+				seen[ir] = true
+				out = append(out, ir)
+			}
+		}
+	}
+	return out
+}
+
+func withPolicies(set PolicySet, extra []Policy) PolicySet {
+	if len(extra) == 0 {
+		return set
+	}
+	policies := make([]Policy, 0, len(set.Policies)+len(extra))
+	policies = append(policies, set.Policies...)
+	set.Policies = append(policies, extra...)
+	return set
+}
+
+func compile(set PolicySet) (*compiled, error) {
+	inherits, err := compileRoles(set.Roles)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiled{inherits: inherits}
+	ids := make(map[string]bool, len(set.Policies))
+	for i, p := range set.Policies {
+		if p.ID == "" {
+			p.ID = "policy-" + strconv.Itoa(i+1)
+		}
+		if ids[p.ID] {
+			return nil, errors.InvalidArgument(fmt.Sprintf("duplicate policy id %q", p.ID), nil)
+		}
+		ids[p.ID] = true
+
+		p.Effect = Effect(strings.ToLower(string(p.Effect)))
+		if p.Effect != Allow && p.Effect != Deny {
+			return nil, errors.InvalidArgument(fmt.Sprintf("policy %q: effect must be allow or deny", p.ID), nil)
+		}
+		if len(p.Resources) == 0 || len(p.Actions) == 0 {
+			return nil, errors.InvalidArgument(fmt.Sprintf("policy %q: resources and actions are required", p.ID), nil)
+		}
+		cp := &compiledPolicy{Policy: p}
+		for _, r := range p.Resources {
+			pat, err := compilePattern(r)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("policy %q", p.ID))
+			}
+			cp.resources = append(cp.resources, pat)
+		}
+		if strings.TrimSpace(p.Condition) != "" {
+			cond, err := Compile(p.Condition)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("policy %q", p.ID))
+			}
+			cp.condition = cond
+		}
+		c.policies = append(c.policies, cp)
+	}
+	return c, nil
+}
+
+// compileRoles resolves the role hierarchy, rejecting cycles.
+func compileRoles(roles []Role) (map[string][]string, error) {
+	parents := make(map[string][]string, len(roles))
+	for _, r := range roles {
+		if r.Name == "" {
+			return nil, errors.InvalidArgument("role name is required", nil)
+		}
+		parents[r.Name] = append(parents[r.Name], r.Inherits...)
+	}
+
+	out := make(map[string][]string, len(parents))
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var visit func(role string, chain []string) error
+	visit = func(role string, chain []string) error {
+		switch state[role] {
+		case visiting:
+			return errors.InvalidArgument("role inheritance cycle: "+strings.Join(append(chain, role), " -> "), nil)
+		case done:
+			return nil
+		}
+		state[role] = visiting
+		implied := []string{role}
+		seen := map[string]bool{role: true}
+		for _, parent := range parents[role] {
+			if err := visit(parent, append(chain, role)); err != nil {
+				return err
+			}
+			pImplied, ok := out[parent]
+			if !ok {
+				pImplied = []string{parent}
+			}
+			for _, r := range pImplied {
+				if !seen[r] {
+					seen[r] = true
+					implied = append(implied, r)
+				}
+			}
+		}
+		out[role] = implied
+		state[role] = done
+		return nil
+	}
+	for _, r := range roles {
+		if err := visit(r.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+type (
+	subjectCtxKey  struct{}
+	resourceCtxKey struct{}
+	envCtxKey      struct{}
+)
+
+// WithSubject attaches the subject Enforce evaluates for.
+func WithSubject(ctx context.Context, s Subject) context.Context {
+	return context.WithValue(ctx, subjectCtxKey{}, s)
+}
+
+// SubjectFromContext returns the subject attached by WithSubject.
+func SubjectFromContext(ctx context.Context) (Subject, bool) {
+	s, ok := ctx.Value(subjectCtxKey{}).(Subject)
+	return s, ok
+}
+
+// WithResourceAttributes attaches resource attributes for Enforce.
+func WithResourceAttributes(ctx context.Context, attrs map[string]any) context.Context {
+	return context.WithValue(ctx, resourceCtxKey{}, attrs)
+}
+
+func resourceAttributesFromContext(ctx context.Context) map[string]any {
+	m, _ := ctx.Value(resourceCtxKey{}).(map[string]any)
+	return m
+}
+
+// WithEnv attaches environment attributes for Enforce.
+func WithEnv(ctx context.Context, env map[string]any) context.Context {
+	return context.WithValue(ctx, envCtxKey{}, env)
+}
+
+func envFromContext(ctx context.Context) map[string]any {
+	m, _ := ctx.Value(envCtxKey{}).(map[string]any)
+	return m
+}
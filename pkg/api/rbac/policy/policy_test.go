@@ -0,0 +1,228 @@
+package policy_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/rbac/policy"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+const testPolicies = `{
+  "roles": [
+    {"name": "admin", "inherits": ["editor"]},
+    {"name": "editor", "inherits": ["viewer"]}
+  ],
+  "policies": [
+    {"id": "read-docs", "effect": "allow", "roles": ["viewer"], "resources": ["documents/**"], "actions": ["read"]},
+    {"id": "edit-own", "effect": "allow", "roles": ["editor"], "resources": ["documents/{id}"],
+     "actions": ["update", "delete"], "condition": "resource.owner == subject.id || resource.params.id == 'scratch'"},
+    {"id": "admin-all", "effect": "allow", "roles": ["admin"], "resources": ["*"], "actions": ["*"]},
+    {"id": "acme-reports", "effect": "allow", "roles": ["*"], "tenant": "acme", "resources": ["reports/*.pdf"], "actions": ["read*"]},
+    {"id": "no-archive-delete", "effect": "deny", "roles": ["*"], "resources": ["documents/archive/**"], "actions": ["delete"]},
+    {"id": "office-hours", "effect": "deny", "roles": ["*"], "resources": ["billing/**"], "actions": ["*"],
+     "condition": "env.hour < 9 || env.hour >= 17 || !('finance' in subject.groups)"}
+  ]
+}`
+
+func newEngine(t *testing.T) *policy.Engine {
+	t.Helper()
+	set, err := policy.Parse([]byte(testPolicies))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	e, err := policy.New(set)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return e
+}
+
+func TestEngineDecisions(t *testing.T) {
+	e := newEngine(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		req      policy.Request
+		allowed  bool
+		deciding string
+	}{
+		{"viewer reads", policy.Request{Subject: policy.Subject{Roles: []string{"viewer"}}, Resource: "documents/1", Action: "read"}, true, "read-docs"},
+		{"viewer cannot update", policy.Request{Subject: policy.Subject{Roles: []string{"viewer"}}, Resource: "documents/1", Action: "update"}, false, ""},
+		{"admin inherits viewer", policy.Request{Subject: policy.Subject{Roles: []string{"admin"}}, Resource: "documents/a/b", Action: "read"}, true, "read-docs"},
+		{"editor updates own", policy.Request{Subject: policy.Subject{ID: "u1", Roles: []string{"editor"}}, Resource: "documents/7",
+			ResourceAttributes: map[string]any{"owner": "u1"}, Action: "update"}, true, "edit-own"},
+		{"editor cannot update others", policy.Request{Subject: policy.Subject{ID: "u1", Roles: []string{"editor"}}, Resource: "documents/7",
+			ResourceAttributes: map[string]any{"owner": "u2"}, Action: "update"}, false, ""},
+		{"path parameter", policy.Request{Subject: policy.Subject{ID: "u1", Roles: []string{"editor"}}, Resource: "documents/scratch", Action: "delete"}, true, "edit-own"},
+		{"deny overrides admin", policy.Request{Subject: policy.Subject{Roles: []string{"admin"}}, Resource: "documents/archive/2020", Action: "delete"}, false, "no-archive-delete"},
+		{"tenant scoped", policy.Request{Subject: policy.Subject{Tenant: "acme"}, Resource: "reports/q1.pdf", Action: "read"}, true, "acme-reports"},
+		{"other tenant", policy.Request{Subject: policy.Subject{Tenant: "globex"}, Resource: "reports/q1.pdf", Action: "read"}, false, ""},
+		{"glob mismatch", policy.Request{Subject: policy.Subject{Tenant: "acme"}, Resource: "reports/q1.csv", Action: "read"}, false, ""},
+		{"env condition allows", policy.Request{Subject: policy.Subject{Roles: []string{"admin"}, Attributes: map[string]any{"groups": []string{"finance"}}},
+			Resource: "billing/invoices", Action: "read", Env: map[string]any{"hour": 10}}, true, "admin-all"},
+		{"env condition denies", policy.Request{Subject: policy.Subject{Roles: []string{"admin"}, Attributes: map[string]any{"groups": []string{"finance"}}},
+			Resource: "billing/invoices", Action: "read", Env: map[string]any{"hour": 20}}, false, "office-hours"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := e.Evaluate(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if d.Allowed != tt.allowed || d.Policy != tt.deciding {
+				t.Fatalf("got allowed=%v policy=%q (%s), want allowed=%v policy=%q", d.Allowed, d.Policy, d.Reason, tt.allowed, tt.deciding)
+			}
+		})
+	}
+}
+
+func TestExplainTracesEveryPolicy(t *testing.T) {
+	e := newEngine(t)
+	d, err := e.Explain(context.Background(), policy.Request{
+		Subject:  policy.Subject{Roles: []string{"admin"}},
+		Resource: "documents/archive/2020",
+		Action:   "delete",
+	})
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+	if len(d.Trace) != 6 {
+		t.Fatalf("trace has %d steps, want 6", len(d.Trace))
+	}
+	byID := make(map[string]policy.Step)
+	for _, s := range d.Trace {
+		byID[s.Policy] = s
+	}
+	if !byID["admin-all"].Matched || !byID["no-archive-delete"].Matched || d.Allowed {
+		t.Fatalf("expected admin-all and no-archive-delete to match with deny winning: %+v", d)
+	}
+	if byID["read-docs"].Reason != "action not covered" {
+		t.Fatalf("read-docs reason=%q", byID["read-docs"].Reason)
+	}
+}
+
+func TestEnforcerInterface(t *testing.T) {
+	e := newEngine(t)
+	ctx := policy.WithSubject(context.Background(), policy.Subject{ID: "u1"})
+	ctx = policy.WithResourceAttributes(ctx, map[string]any{"owner": "u1"})
+
+	ok, err := e.Enforce(ctx, "editor", "documents/9", "update")
+	if err != nil || !ok {
+		t.Fatalf("Enforce=%v err=%v", ok, err)
+	}
+	if ok, _ := e.Enforce(ctx, "guest", "queues/q1", "publish"); ok {
+		t.Fatal("guest allowed before AddPolicy")
+	}
+	if err := e.AddPolicy("guest", "queues/*", "publish"); err != nil {
+		t.Fatalf("AddPolicy: %v", err)
+	}
+	if ok, _ := e.Enforce(ctx, "guest", "queues/q1", "publish"); !ok {
+		t.Fatal("guest denied after AddPolicy")
+	}
+	if err := e.AddPolicy("guest", "queues/**/x", "publish"); !errors.IsCode(err, errors.CodeInvalidArgument) {
+		t.Fatalf("AddPolicy with an invalid pattern: %v", err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := map[string]string{
+		"cycle":       `{"roles":[{"name":"a","inherits":["b"]},{"name":"b","inherits":["a"]}],"policies":[]}`,
+		"effect":      `{"policies":[{"id":"p","effect":"maybe","roles":["*"],"resources":["*"],"actions":["*"]}]}`,
+		"condition":   `{"policies":[{"id":"p","effect":"allow","roles":["*"],"resources":["*"],"actions":["*"],"condition":"user.id == 1"}]}`,
+		"pattern":     `{"policies":[{"id":"p","effect":"allow","roles":["*"],"resources":["a/**/b"],"actions":["*"]}]}`,
+		"duplicate":   `{"policies":[{"id":"p","effect":"allow","roles":["*"],"resources":["*"],"actions":["*"]},{"id":"p","effect":"deny","roles":["*"],"resources":["*"],"actions":["*"]}]}`,
+		"unknown key": `{"policies":[],"rules":[]}`,
+	}
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			set, err := policy.Parse([]byte(doc))
+			if err == nil {
+				_, err = policy.New(set)
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestConditionLanguage(t *testing.T) {
+	vars := map[string]any{
+		"subject":  map[string]any{"id": "u1", "level": 3, "tags": []string{"a", "b"}},
+		"resource": map[string]any{"path": "docs/x", "size": 1.5},
+		"action":   "read",
+	}
+	tests := map[string]bool{
+		`subject.level >= 3 && subject.level < 4`:                            true,
+		`resource.size > -1 && resource.size != 1.5`:                         false,
+		`"b" in subject.tags && !("c" in subject.tags)`:                      true,
+		`action in ["read", "list"]`:                                         true,
+		`subject.missing == null`:                                            true,
+		`startsWith(resource.path, "docs/") && endsWith(resource.path, "x")`: true,
+		`matches(resource.path, "docs/*") && contains(subject.id, "1")`:      true,
+		`subject.id < 5`: false,
+	}
+	for src, want := range tests {
+		c, err := policy.Compile(src)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", src, err)
+		}
+		got, err := c.Eval(vars)
+		if err != nil || got != want {
+			t.Fatalf("Eval(%q)=%v err=%v, want %v", src, got, err, want)
+		}
+	}
+	for _, bad := range []string{`subject.id ==`, `foo.bar`, `"unterminated`, `nope(1)`, `(subject.id`} {
+		if _, err := policy.Compile(bad); err == nil {
+			t.Fatalf("Compile(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestWatchReloadsAndKeepsPoliciesOnBadDocument(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	write := func(doc string) {
+		if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"policies":[{"id":"p","effect":"allow","roles":["r"],"resources":["a"],"actions":["read"]}]}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := policy.FileSource{Path: path}
+	e, err := policy.NewFromSource(ctx, src)
+	if err != nil {
+		t.Fatalf("NewFromSource: %v", err)
+	}
+	e.Watch(ctx, src, 10*time.Millisecond)
+
+	allowed := func(resource string) bool {
+		ok, _ := e.Enforce(ctx, "r", resource, "read")
+		return ok
+	}
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for reload")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	write(`{"policies":[{"id":"p","effect":"allow","roles":["r"],"resources":["b"],"actions":["read"]}]}`)
+	waitFor(func() bool { return allowed("b") && !allowed("a") })
+
+	write(`{"policies":[{"id":"p","effect":"allow","roles":["r"],"resources":["c"],"actions":["read"],"condition":"("}]}`)
+	time.Sleep(50 * time.Millisecond)
+	if !allowed("b") || allowed("c") {
+		t.Fatal("invalid document replaced the current policies")
+	}
+}
@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/client/rest"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+)
+
+// DefaultReloadInterval is applied when Watch is given a zero interval.
+const DefaultReloadInterval = 30 * time.Second
+
+// Source loads a policy set as a JSON document.
+type Source interface {
+	Load(ctx context.Context) ([]byte, error)
+}
+
+// FileSource reads a JSON policy file.
+type FileSource struct {
+	Path string
+}
+
+var _ Source = FileSource{}
+
+// Load implements Source.
+func (f FileSource) Load(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, errors.Internal("failed to read policy file "+f.Path, err)
+	}
+	return data, nil
+}
+
+// AppConfigSource reads the policy set stored under Key in the appconfig
+// service, whose entries hold the document as their JSON value.
+type AppConfigSource struct {
+	Client  *rest.Client
+	BaseURL string
+	Key     string
+}
+
+var _ Source = AppConfigSource{}
+
+type appConfigEntry struct {
+	Value json.RawMessage `json:"value"`
+}
+
+// Load implements Source.
+func (a AppConfigSource) Load(ctx context.Context) ([]byte, error) {
+	u := strings.TrimRight(a.BaseURL, "/") + "/v1/configs/" + url.PathEscape(a.Key)
+	entry, err := rest.GetJSON[appConfigEntry](ctx, a.Client, u)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch policy set "+a.Key)
+	}
+	// A value set as a JSON string holds the document encoded once more.
+	var s string
+	if json.Unmarshal(entry.Value, &s) == nil {
+		return []byte(s), nil
+	}
+	return entry.Value, nil
+}
+
+// Parse decodes a JSON policy set, rejecting unknown fields.
+func Parse(data []byte) (PolicySet, error) {
+	var set PolicySet
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&set); err != nil {
+		return PolicySet{}, errors.InvalidArgument("invalid policy set", err)
+	}
+	return set, nil
+}
+
+// NewFromSource loads and compiles the policy set from src.
+func NewFromSource(ctx context.Context, src Source) (*Engine, error) {
+	data, err := src.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	set, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return New(set)
+}
+
+// Watch reloads the engine from src every interval until ctx is done. A
+// document that fails to load, parse or compile is logged and the engine
+// keeps serving its current policies until the document changes again.
+func (e *Engine) Watch(ctx context.Context, src Source, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	go func() {
+		var last []byte
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			data, err := src.Load(ctx)
+			if err != nil {
+				logger.L().WarnContext(ctx, "policy reload failed", "error", err)
+				continue
+			}
+			if bytes.Equal(data, last) {
+				continue
+			}
+			// Remember rejected documents too so each is reported once.
+			last = data
+			set, err := Parse(data)
+			if err == nil {
+				err = e.Load(set)
+			}
+			if err != nil {
+				logger.L().WarnContext(ctx, "policy reload rejected, keeping current policies", "error", err)
+				continue
+			}
+			logger.L().InfoContext(ctx, "policy set reloaded", "policies", len(set.Policies))
+		}
+	}()
+}
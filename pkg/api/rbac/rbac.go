@@ -17,8 +17,9 @@ type Enforcer interface {
 	// Enforce checks if the subject (role) has permission to perform action on resource.
 	Enforce(ctx context.Context, role string, resource string, action string) (bool, error)
 
-	// AddPolicy adds a permission to a role.
-	AddPolicy(role string, resource string, action string)
+	// AddPolicy adds a permission to a role. It fails when the enforcer
+	// cannot represent the permission (e.g. an invalid resource pattern).
+	AddPolicy(role string, resource string, action string) error
 }
 
 // SimpleEnforcer is an in-memory Enforcer guarded by SmartRWMutex.
@@ -34,13 +35,14 @@ func New() *SimpleEnforcer {
 	}
 }
 
-func (e *SimpleEnforcer) AddPolicy(role string, resource string, action string) {
+func (e *SimpleEnforcer) AddPolicy(role string, resource string, action string) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	if _, ok := e.policies[role]; !ok {
 		e.policies[role] = make(map[Permission]bool)
 	}
 	e.policies[role][Permission{Resource: resource, Action: action}] = true
+	return nil
 }
 
 func (e *SimpleEnforcer) Enforce(ctx context.Context, role string, resource string, action string) (bool, error) {
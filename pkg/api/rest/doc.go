@@ -2,4 +2,6 @@
 // and centralized error handling via pkg/errors.HTTPStatus.
 //
 // It does not provide a full router DSL (use Echo directly via Server.Echo()).
+// Server.OpenAPI derives an OpenAPI document from the registered Echo routes
+// and serves it (default /openapi.json); pair it with openapi.ValidateRequests.
 package rest
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/openapi"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
 	"github.com/labstack/echo/v4"
@@ -88,6 +89,21 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return s.echo.Shutdown(ctx)
 }
 
+// OpenAPI generates a document from the routes registered so far, serves it
+// at path (default "/openapi.json") and returns it, e.g. for
+// openapi.ValidateRequests. Call it after registering the service's routes.
+func (s *Server) OpenAPI(path string, opts openapi.EchoOptions) (*openapi.Document, error) {
+	if path == "" {
+		path = "/openapi.json"
+	}
+	doc, err := openapi.FromEcho(s.echo, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.echo.GET(path, openapi.ServeDocument(doc))
+	return doc, nil
+}
+
 // genericErrorHandler maps errors (including pkg/errors.AppError) to HTTP responses
 // using the full errors.HTTPStatus code map.
 func genericErrorHandler(err error, c echo.Context) {
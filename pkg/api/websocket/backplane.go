@@ -0,0 +1,234 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/events"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging"
+	"github.com/google/uuid"
+)
+
+// Envelope kinds exchanged between hubs over a Backplane.
+const (
+	KindRoom      = "room"      // BroadcastToRoom on another node
+	KindBroadcast = "broadcast" // hub-wide broadcast on another node
+	KindPresence  = "presence"  // periodic presence snapshot of a node
+	KindLeave     = "leave"     // node shut down; drop its presence
+)
+
+// Envelope is the unit hubs exchange over a Backplane.
+type Envelope struct {
+	Node     string        `json:"node"`
+	Kind     string        `json:"kind"`
+	Room     string        `json:"room,omitempty"`
+	Data     []byte        `json:"data,omitempty"`
+	Presence *NodePresence `json:"presence,omitempty"`
+	Sent     time.Time     `json:"sent"`
+}
+
+// NodePresence is one node's view of its connected users.
+type NodePresence struct {
+	// Rooms maps room → user ID → connection count.
+	Rooms map[string]map[string]int `json:"rooms,omitempty"`
+	// Users maps user ID → connection count across all rooms.
+	Users map[string]int `json:"users,omitempty"`
+}
+
+// EnvelopeHandler receives envelopes from a Backplane.
+type EnvelopeHandler func(ctx context.Context, env Envelope)
+
+// Backplane fans hub traffic out to every node running the same hub.
+// Implementations deliver each envelope to all subscribed nodes, including
+// the publisher; hubs skip their own envelopes.
+type Backplane interface {
+	// Publish sends env to all nodes.
+	Publish(ctx context.Context, env Envelope) error
+
+	// Subscribe starts delivering envelopes to handler until ctx is
+	// cancelled. It returns once the subscription is established.
+	Subscribe(ctx context.Context, handler EnvelopeHandler) error
+
+	// Close releases the backplane's resources.
+	Close() error
+}
+
+// BackplaneConfig configures the broker and bus backplanes.
+type BackplaneConfig struct {
+	// Topic carries the envelopes (default "websocket-backplane").
+	Topic string
+
+	// NodeID names this node's consumer group (default: the hostname).
+	// It must be unique per node and stable across restarts, so a restarted
+	// node reuses its group instead of leaving one behind on brokers that
+	// persist groups, such as Redis Streams. Hubs sharing a process need
+	// distinct NodeIDs.
+	NodeID string
+
+	// Group is this node's consumer group on a messaging.Broker. Every node
+	// needs its own group so each receives every envelope; the default is
+	// Topic + "-" + NodeID.
+	Group string
+
+	// MaxAge drops envelopes older than this, e.g. history replayed to a new
+	// consumer group on Redis Streams (default 30s).
+	MaxAge time.Duration
+}
+
+func (c *BackplaneConfig) defaults() {
+	if c.Topic == "" {
+		c.Topic = "websocket-backplane"
+	}
+	if c.NodeID == "" {
+		c.NodeID, _ = os.Hostname()
+	}
+	if c.NodeID == "" {
+		c.NodeID = uuid.New().String()
+	}
+	if c.Group == "" {
+		c.Group = c.Topic + "-" + c.NodeID
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = 30 * time.Second
+	}
+}
+
+// stale reports whether env is too old to act on.
+func (c *BackplaneConfig) stale(env Envelope) bool {
+	return !env.Sent.IsZero() && time.Since(env.Sent) > c.MaxAge
+}
+
+// BrokerBackplane is a Backplane over a messaging.Broker (Redis Streams,
+// NATS, the memory adapter, ...).
+type BrokerBackplane struct {
+	broker   messaging.Broker
+	producer messaging.Producer
+	config   BackplaneConfig
+}
+
+var _ Backplane = (*BrokerBackplane)(nil)
+
+// NewBrokerBackplane creates a backplane publishing to cfg.Topic on broker.
+func NewBrokerBackplane(broker messaging.Broker, cfg BackplaneConfig) (*BrokerBackplane, error) {
+	cfg.defaults()
+	producer, err := broker.Producer(cfg.Topic)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create backplane producer")
+	}
+	return &BrokerBackplane{broker: broker, producer: producer, config: cfg}, nil
+}
+
+// Publish implements Backplane.
+func (b *BrokerBackplane) Publish(ctx context.Context, env Envelope) error {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return errors.Internal("failed to encode backplane envelope", err)
+	}
+	return b.producer.Publish(ctx, &messaging.Message{Topic: b.config.Topic, Payload: payload})
+}
+
+// Subscribe implements Backplane.
+func (b *BrokerBackplane) Subscribe(ctx context.Context, handler EnvelopeHandler) error {
+	consumer, err := b.broker.Consumer(b.config.Topic, b.config.Group)
+	if err != nil {
+		return errors.Wrap(err, "failed to create backplane consumer")
+	}
+	go func() {
+		defer consumer.Close()
+		err := consumer.Consume(ctx, func(ctx context.Context, msg *messaging.Message) error {
+			var env Envelope
+			if err := json.Unmarshal(msg.Payload, &env); err != nil {
+				logger.L().WarnContext(ctx, "dropping malformed backplane envelope", "error", err)
+				return nil
+			}
+			if !b.config.stale(env) {
+				handler(ctx, env)
+			}
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			logger.L().ErrorContext(ctx, "websocket backplane consumer stopped", "topic", b.config.Topic, "error", err)
+		}
+	}()
+	return nil
+}
+
+// Close implements Backplane. The broker itself stays open.
+func (b *BrokerBackplane) Close() error {
+	return b.producer.Close()
+}
+
+// BusBackplane is a Backplane over an events.Bus.
+type BusBackplane struct {
+	bus    events.Bus
+	config BackplaneConfig
+}
+
+var _ Backplane = (*BusBackplane)(nil)
+
+// NewBusBackplane creates a backplane publishing to cfg.Topic on bus.
+func NewBusBackplane(bus events.Bus, cfg BackplaneConfig) *BusBackplane {
+	cfg.defaults()
+	return &BusBackplane{bus: bus, config: cfg}
+}
+
+// Publish implements Backplane.
+func (b *BusBackplane) Publish(ctx context.Context, env Envelope) error {
+	return b.bus.Publish(ctx, b.config.Topic, events.Event{
+		ID:        uuid.New().String(),
+		Type:      "websocket." + env.Kind,
+		Source:    env.Node,
+		Timestamp: env.Sent,
+		Payload:   env,
+	})
+}
+
+// Subscribe implements Backplane.
+func (b *BusBackplane) Subscribe(ctx context.Context, handler EnvelopeHandler) error {
+	sub, err := b.bus.Subscribe(ctx, b.config.Topic, func(ctx context.Context, event events.Event) error {
+		env, err := envelopeFromPayload(event.Payload)
+		if err != nil {
+			logger.L().WarnContext(ctx, "dropping malformed backplane event", "id", event.ID, "error", err)
+			return nil
+		}
+		if !b.config.stale(env) {
+			handler(ctx, env)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to subscribe to backplane topic")
+	}
+	go func() {
+		<-ctx.Done()
+		_ = b.bus.Unsubscribe(context.Background(), sub)
+	}()
+	return nil
+}
+
+// Close implements Backplane. The bus itself stays open.
+func (b *BusBackplane) Close() error {
+	return nil
+}
+
+// envelopeFromPayload accepts an Envelope as published in-process or after
+// a JSON round trip through a durable bus.
+func envelopeFromPayload(payload interface{}) (Envelope, error) {
+	switch p := payload.(type) {
+	case Envelope:
+		return p, nil
+	case *Envelope:
+		return *p, nil
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+	var env Envelope
+	err = json.Unmarshal(raw, &env)
+	return env, err
+}
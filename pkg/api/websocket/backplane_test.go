@@ -0,0 +1,256 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/events"
+	eventsmemory "github.com/chris-alexander-pop/go-hyperforge/pkg/events/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging/adapters/memory"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startHub(t *testing.T, cfg Config) *Hub {
+	t.Helper()
+	hub := NewHubWithConfig(cfg)
+	go hub.Run()
+	t.Cleanup(hub.Shutdown)
+	return hub
+}
+
+func joinFake(t *testing.T, hub *Hub, user string, rooms ...string) *Client {
+	t.Helper()
+	c := &Client{hub: hub, send: make(chan []byte, 8), rooms: make(map[string]bool), user: user}
+	hub.register <- c
+	for _, room := range rooms {
+		hub.JoinRoom(c, room)
+	}
+	return c
+}
+
+func receive(t *testing.T, c *Client) string {
+	t.Helper()
+	select {
+	case msg := <-c.send:
+		return string(msg)
+	case <-time.After(time.Second):
+		t.Fatal("client did not receive a message")
+		return ""
+	}
+}
+
+func TestBrokerBackplane_RoomBroadcastAcrossNodes(t *testing.T) {
+	broker := memory.New(memory.Config{})
+	defer broker.Close()
+
+	newNode := func(node string) *Hub {
+		bp, err := NewBrokerBackplane(broker, BackplaneConfig{Topic: "ws", NodeID: node})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = bp.Close() })
+		return startHub(t, Config{Backplane: bp})
+	}
+	a, b := newNode("a"), newNode("b")
+	require.NotEqual(t, a.NodeID(), b.NodeID())
+
+	onA := joinFake(t, a, "alice", "lobby")
+	onB := joinFake(t, b, "bob", "lobby")
+	elsewhere := joinFake(t, b, "carol", "other")
+
+	a.BroadcastToRoom("lobby", []byte("hi"))
+	assert.Equal(t, "hi", receive(t, onA))
+	assert.Equal(t, "hi", receive(t, onB), "remote room member should receive the broadcast")
+
+	a.Broadcast <- []byte("all")
+	assert.Equal(t, "all", receive(t, onA))
+	assert.Equal(t, "all", receive(t, onB))
+	assert.Equal(t, "all", receive(t, elsewhere))
+
+	select {
+	case msg := <-onA.send:
+		t.Fatalf("envelopes from this node must not be delivered twice, got %q", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, 1, a.RoomSize("lobby"))
+}
+
+func TestBackplaneConfig_StableGroup(t *testing.T) {
+	cfg := BackplaneConfig{Topic: "ws"}
+	cfg.defaults()
+	host, _ := os.Hostname()
+	if host != "" {
+		assert.Equal(t, host, cfg.NodeID)
+	}
+	assert.Equal(t, "ws-"+cfg.NodeID, cfg.Group)
+
+	restarted := BackplaneConfig{Topic: "ws"}
+	restarted.defaults()
+	assert.Equal(t, cfg.Group, restarted.Group, "a restarted node should reuse its consumer group")
+}
+
+// blockingBackplane holds every Publish until its context ends.
+type blockingBackplane struct{}
+
+func (blockingBackplane) Publish(ctx context.Context, _ Envelope) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (blockingBackplane) Subscribe(context.Context, EnvelopeHandler) error { return nil }
+func (blockingBackplane) Close() error                                     { return nil }
+
+func TestHub_SlowBackplaneDoesNotBlockLocalDelivery(t *testing.T) {
+	hub := startHub(t, Config{Backplane: blockingBackplane{}})
+	c := joinFake(t, hub, "alice", "lobby")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		hub.BroadcastToRoom("lobby", []byte("one"))
+		hub.Broadcast <- []byte("two")
+		hub.JoinRoom(c, "ops")
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("broadcasts waited on the backplane")
+	}
+	assert.Equal(t, "one", receive(t, c))
+	assert.Equal(t, "two", receive(t, c))
+	assert.Equal(t, 1, hub.RoomSize("ops"))
+}
+
+func TestBusBackplane_ClusterPresence(t *testing.T) {
+	bus := eventsmemory.New(events.Config{})
+	defer bus.Close()
+
+	cfg := func() Config {
+		return Config{Backplane: NewBusBackplane(bus, BackplaneConfig{}), PresenceInterval: 20 * time.Millisecond}
+	}
+	a := startHub(t, cfg())
+	b := NewHubWithConfig(cfg())
+	go b.Run()
+
+	joinFake(t, a, "alice", "lobby")
+	joinFake(t, b, "bob", "lobby")
+	joinFake(t, b, "alice", "lobby", "ops")
+
+	require.Eventually(t, func() bool {
+		return a.ClusterRoomSize("lobby") == 3
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, a.RoomSize("lobby"))
+	assert.Equal(t, []string{"alice", "bob"}, a.Presence("lobby"))
+	assert.Equal(t, []string{"alice"}, a.Presence("ops"))
+	assert.True(t, a.IsOnline("bob"))
+	assert.False(t, a.IsOnline("dave"))
+
+	b.Shutdown()
+	require.Eventually(t, func() bool {
+		return !a.IsOnline("bob")
+	}, time.Second, 10*time.Millisecond, "a node leaving should drop its presence")
+	assert.Equal(t, 1, a.ClusterRoomSize("lobby"))
+}
+
+func TestEnvelopeFromPayload_JSONRoundTrip(t *testing.T) {
+	env := Envelope{Node: "n1", Kind: KindRoom, Room: "lobby", Data: []byte("hi"), Sent: time.Now().UTC()}
+	raw, err := json.Marshal(env)
+	require.NoError(t, err)
+	var decoded interface{}
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+
+	got, err := envelopeFromPayload(decoded)
+	require.NoError(t, err)
+	assert.Equal(t, env.Room, got.Room)
+	assert.Equal(t, env.Data, got.Data)
+	assert.True(t, env.Sent.Equal(got.Sent))
+}
+
+func TestReliable_AckAndResume(t *testing.T) {
+	hub := startHub(t, Config{
+		Reliable:  true,
+		Identify:  func(context.Context) string { return "alice" },
+		OnConnect: func(c *Client) { c.hub.JoinRoom(c, "chat") },
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, w, r)
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	dial := func(query string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(url+query, nil)
+		require.NoError(t, err)
+		return conn
+	}
+	read := func(conn *websocket.Conn) Frame {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		var f Frame
+		require.NoError(t, conn.ReadJSON(&f))
+		return f
+	}
+
+	conn := dial("")
+	hello := read(conn)
+	require.Equal(t, FrameSession, hello.Type)
+	require.NotEmpty(t, hello.Session)
+	assert.False(t, hello.Resumed)
+
+	hub.BroadcastToRoom("chat", []byte(`{"n":1}`))
+	f := read(conn)
+	assert.Equal(t, uint64(1), f.Seq)
+	assert.Equal(t, "chat", f.Room)
+	assert.JSONEq(t, `{"n":1}`, string(f.Data))
+	require.NoError(t, conn.WriteJSON(Frame{Type: FrameAck, Seq: 1}))
+	require.NoError(t, conn.Close())
+
+	require.Eventually(t, func() bool {
+		return hub.RoomSize("chat") == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// Sent while disconnected: buffered for the session.
+	hub.BroadcastToRoom("chat", []byte(`{"n":2}`))
+	hub.BroadcastToRoom("chat", []byte("plain text"))
+
+	conn = dial("?session=" + hello.Session + "&last_seq=1")
+	defer conn.Close()
+	resumed := read(conn)
+	assert.Equal(t, hello.Session, resumed.Session)
+	assert.True(t, resumed.Resumed)
+	assert.Equal(t, uint64(3), resumed.Seq)
+
+	f = read(conn)
+	assert.Equal(t, uint64(2), f.Seq)
+	assert.JSONEq(t, `{"n":2}`, string(f.Data))
+	f = read(conn)
+	assert.Equal(t, uint64(3), f.Seq)
+	assert.Equal(t, `"plain text"`, string(f.Data))
+	assert.Equal(t, 1, hub.RoomSize("chat"), "resumed session should rejoin its rooms")
+
+	// An attached session cannot be taken over; a fresh one is issued.
+	other := dial("?session=" + hello.Session + "&last_seq=3")
+	defer other.Close()
+	fresh := read(other)
+	assert.NotEqual(t, hello.Session, fresh.Session)
+	assert.False(t, fresh.Resumed)
+}
+
+func TestSession_ResumeGap(t *testing.T) {
+	s := newSession("alice", 2)
+	for i := 0; i < 4; i++ {
+		s.deliver("r", []byte("x"))
+	}
+	assert.False(t, s.canResume(1), "frame 2 was evicted from the buffer")
+	assert.True(t, s.canResume(2))
+	assert.True(t, s.canResume(4))
+	assert.False(t, s.canResume(5))
+
+	s.ack(3)
+	assert.Len(t, s.buf, 1)
+}
@@ -3,5 +3,41 @@ Package websocket implements a Hub/Client WebSocket fan-out with origin allowlis
 graceful Shutdown, concurrency-safe broadcast (SmartRWMutex), named rooms
 (JoinRoom / LeaveRoom / BroadcastToRoom), and optional upgrade-time Authenticate hook
 on Config.
+
+# Scale-out
+
+Set Config.Backplane to run the hub on several replicas. Room and hub-wide
+broadcasts are published to the backplane and delivered by every node to its
+local clients. NewBrokerBackplane works over any messaging.Broker (Redis
+Streams, NATS, memory) and NewBusBackplane over an events.Bus:
+
+	bp, err := websocket.NewBrokerBackplane(broker, websocket.BackplaneConfig{Topic: "chat"})
+	hub := websocket.NewHubWithConfig(websocket.Config{
+		Backplane: bp,
+		Identify:  func(ctx context.Context) string { return userIDFrom(ctx) },
+		OnConnect: func(c *websocket.Client) { hub.JoinRoom(c, roomFrom(c.Context())) },
+	})
+
+Publishing happens off the hub loop through a queue of Config.PublishQueue
+envelopes, so a slow backplane delays remote delivery but never local
+clients. Each broker backplane consumes in its own group, named after
+BackplaneConfig.NodeID (the hostname by default); keep it stable across
+restarts so brokers that persist groups, like Redis Streams, do not collect
+orphans.
+
+Nodes also exchange presence snapshots every PresenceInterval, so
+ClusterRoomSize, Presence and IsOnline answer for the whole cluster;
+RoomSize stays node-local.
+
+# Reliable delivery
+
+With Config.Reliable, outgoing messages are wrapped in sequenced JSON frames
+({"type":"message","seq":N,"room":...,"data":...}) after an initial
+{"type":"session","session":ID,"seq":N} frame. Clients acknowledge with
+{"type":"ack","seq":N}. Unacknowledged frames, including messages for the
+client's rooms sent while it is disconnected, are buffered for ResumeTTL; a
+client reconnecting with ?session=ID&last_seq=N to the same node receives
+them and rejoins its rooms. If the buffer no longer covers last_seq, a new
+session is issued ("resumed": false).
 */
 package websocket
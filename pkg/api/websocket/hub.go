@@ -8,6 +8,7 @@ import (
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -26,8 +27,51 @@ type Config struct {
 	// Authenticate is an optional upgrade-time auth hook. When set, ServeWs
 	// invokes it before upgrading; failure returns 401 Unauthorized.
 	Authenticate AuthFunc
+
+	// Identify returns the user ID presence reports for a client, typically
+	// from the Authenticate context. Defaults to the client ID.
+	Identify func(ctx context.Context) string
+
+	// OnConnect is called once a client is registered (or resumed), e.g. to
+	// JoinRoom based on its Context.
+	OnConnect func(c *Client)
+
+	// Backplane fans room broadcasts and presence out to the other nodes
+	// running this hub. Nil keeps the hub node-local. The hub does not
+	// close it.
+	Backplane Backplane
+
+	// NodeID identifies this node on the backplane (default: random UUID).
+	NodeID string
+
+	// PublishQueue is how many envelopes can wait for the backplane
+	// (default 1024). Publishing happens off the hub loop; envelopes are
+	// dropped with a warning when the queue is full.
+	PublishQueue int
+
+	// PresenceInterval is how often the node publishes its presence
+	// snapshot (default 5s). Nodes silent for three intervals are dropped.
+	PresenceInterval time.Duration
+
+	// Reliable switches the wire format to sequenced JSON frames (see Frame)
+	// with client acks and resume-on-reconnect via the session and last_seq
+	// query parameters. Resume needs the client to reconnect to the same
+	// node, e.g. with load-balancer affinity.
+	Reliable bool
+
+	// ResumeBuffer is how many unacknowledged frames a session keeps
+	// (default 256).
+	ResumeBuffer int
+
+	// ResumeTTL is how long a disconnected session can be resumed
+	// (default 2m).
+	ResumeTTL time.Duration
 }
 
+// publishTimeout bounds a single backplane publish, and the flush of the
+// queue on Shutdown.
+const publishTimeout = 5 * time.Second
+
 // Hub maintains the set of active clients, rooms, and broadcasts messages.
 type Hub struct {
 	clients map[*Client]bool
@@ -44,6 +88,17 @@ type Hub struct {
 	done   chan struct{}
 	once   sync.Once
 	config Config
+
+	nodeID     string
+	outbox     chan Envelope
+	remote     map[string]*remotePresence
+	presenceMu *concurrency.SmartRWMutex
+
+	// sessions holds reliable sessions by ID; parked indexes detached
+	// sessions by room ("" for all) so they keep buffering. Both are
+	// guarded by mu.
+	sessions map[string]*session
+	parked   map[string]map[*session]bool
 }
 
 type roomOp struct {
@@ -57,6 +112,21 @@ func NewHub() *Hub {
 }
 
 func NewHubWithConfig(cfg Config) *Hub {
+	if cfg.NodeID == "" {
+		cfg.NodeID = uuid.New().String()
+	}
+	if cfg.PresenceInterval <= 0 {
+		cfg.PresenceInterval = 5 * time.Second
+	}
+	if cfg.PublishQueue <= 0 {
+		cfg.PublishQueue = 1024
+	}
+	if cfg.ResumeBuffer <= 0 {
+		cfg.ResumeBuffer = 256
+	}
+	if cfg.ResumeTTL <= 0 {
+		cfg.ResumeTTL = 2 * time.Minute
+	}
 	return &Hub{
 		Broadcast:  make(chan []byte),
 		register:   make(chan *Client),
@@ -68,19 +138,51 @@ func NewHubWithConfig(cfg Config) *Hub {
 		mu:         concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "websocket-hub"}),
 		done:       make(chan struct{}),
 		config:     cfg,
+		nodeID:     cfg.NodeID,
+		outbox:     make(chan Envelope, cfg.PublishQueue),
+		remote:     make(map[string]*remotePresence),
+		presenceMu: concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "websocket-hub-presence"}),
+		sessions:   make(map[string]*session),
+		parked:     make(map[string]map[*session]bool),
 	}
 }
 
+// NodeID returns this hub's backplane node ID.
+func (h *Hub) NodeID() string {
+	return h.nodeID
+}
+
 func (h *Hub) Run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop, flushed := make(chan struct{}), make(chan struct{})
+	if h.config.Backplane != nil {
+		go h.publishLoop(stop, flushed)
+		if err := h.config.Backplane.Subscribe(ctx, h.handleEnvelope); err != nil {
+			logger.L().ErrorContext(ctx, "websocket backplane subscribe failed", "node", h.nodeID, "error", err)
+		}
+		h.publishPresence()
+	}
+
+	ticker := time.NewTicker(h.config.PresenceInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-h.done:
 			h.closeAllClients()
+			h.publish(Envelope{Kind: KindLeave})
+			close(stop)
+			if h.config.Backplane != nil {
+				<-flushed
+			}
 			return
+		case now := <-ticker.C:
+			h.expirePresence(now)
+			h.expireSessions(now)
+			h.publishPresence()
 		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client] = true
-			h.mu.Unlock()
+			h.addClient(client)
 		case client := <-h.unregister:
 			h.removeClient(client)
 		case op := <-h.join:
@@ -141,30 +243,60 @@ func (h *Hub) LeaveRoom(client *Client, room string) {
 	}
 }
 
-// BroadcastToRoom sends a message to all clients in a room.
+// BroadcastToRoom sends a message to all clients in a room, on every node
+// when a Backplane is configured.
 func (h *Hub) BroadcastToRoom(room string, message []byte) {
+	h.deliver(room, message)
+	h.publish(Envelope{Kind: KindRoom, Room: room, Data: message})
+}
+
+// deliver sends message to this node's clients in room, or to all clients
+// when room is empty. Detached reliable sessions buffer it for resume.
+func (h *Hub) deliver(room string, message []byte) {
 	h.mu.RLock()
-	members := h.rooms[room]
-	clients := make([]*Client, 0, len(members))
-	for c := range members {
-		clients = append(clients, c)
+	var clients []*Client
+	if room == "" {
+		clients = make([]*Client, 0, len(h.clients))
+		for c := range h.clients {
+			clients = append(clients, c)
+		}
+	} else {
+		members := h.rooms[room]
+		clients = make([]*Client, 0, len(members))
+		for c := range members {
+			clients = append(clients, c)
+		}
+	}
+	parked := make([]*session, 0, len(h.parked[room]))
+	for s := range h.parked[room] {
+		parked = append(parked, s)
 	}
 	h.mu.RUnlock()
 
 	var stale []*Client
 	for _, client := range clients {
+		if client.session != nil {
+			if !client.session.deliver(room, message) {
+				stale = append(stale, client)
+			}
+			continue
+		}
 		select {
 		case client.send <- message:
 		default:
 			stale = append(stale, client)
 		}
 	}
+	for _, s := range parked {
+		s.deliver(room, message)
+	}
 	for _, c := range stale {
 		h.removeClient(c)
 	}
 }
 
-// RoomSize returns the number of clients in a room.
+// RoomSize returns the number of clients in a room on this node. See
+// ClusterRoomSize for the cluster-wide count.
 func (h *Hub) RoomSize(room string) int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -183,10 +315,148 @@ func (h *Hub) closeAllClients() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	for client := range h.clients {
+		if client.session != nil {
+			client.session.detach(client, nil)
+		}
 		close(client.send)
 		delete(h.clients, client)
 	}
 	h.rooms = make(map[string]map[*Client]bool)
+	h.sessions = make(map[string]*session)
+	h.parked = make(map[string]map[*session]bool)
+}
+
+// addClient registers client, restoring the rooms of a resumed session.
+func (h *Hub) addClient(client *Client) {
+	s := client.session
+	h.mu.Lock()
+	h.clients[client] = true
+	if s != nil {
+		h.sessions[s.id] = s
+		h.unpark(s)
+		for _, room := range s.roomList() {
+			members := h.rooms[room]
+			if members == nil {
+				members = make(map[*Client]bool)
+				h.rooms[room] = members
+			}
+			members[client] = true
+			client.rooms[room] = true
+		}
+	}
+	h.mu.Unlock()
+
+	if s != nil {
+		s.attach(client, client.lastSeq, client.resumed)
+	}
+}
+
+// unpark removes s from the detached index. Callers hold mu.
+func (h *Hub) unpark(s *session) {
+	for room, set := range h.parked {
+		delete(set, s)
+		if len(set) == 0 {
+			delete(h.parked, room)
+		}
+	}
+}
+
+// expireSessions drops detached sessions older than ResumeTTL.
+func (h *Hub) expireSessions(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, s := range h.sessions {
+		if s.expired(now, h.config.ResumeTTL) {
+			delete(h.sessions, id)
+			h.unpark(s)
+		}
+	}
+}
+
+// bindSession gives client the session named in the query when it can be
+// resumed from last_seq, and a fresh session otherwise.
+func (h *Hub) bindSession(client *Client, id string, lastSeq uint64) {
+	h.mu.RLock()
+	s := h.sessions[id]
+	h.mu.RUnlock()
+
+	if s != nil && s.user == client.user && s.canResume(lastSeq) {
+		client.session = s
+		client.lastSeq = lastSeq
+		client.resumed = true
+		return
+	}
+	client.session = newSession(client.user, h.config.ResumeBuffer)
+}
+
+// publish queues env for the other nodes without blocking; publishLoop
+// sends it. A full queue drops env with a warning.
+func (h *Hub) publish(env Envelope) {
+	if h.config.Backplane == nil {
+		return
+	}
+	env.Node = h.nodeID
+	env.Sent = time.Now()
+	select {
+	case h.outbox <- env:
+	default:
+		logger.L().Warn("websocket backplane queue full, dropping envelope", "node", h.nodeID, "kind", env.Kind)
+	}
+}
+
+// publishLoop sends queued envelopes to the backplane until stop is
+// closed, then flushes the queue within publishTimeout and closes flushed.
+func (h *Hub) publishLoop(stop <-chan struct{}, flushed chan<- struct{}) {
+	defer close(flushed)
+	for {
+		select {
+		case env := <-h.outbox:
+			ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+			h.send(ctx, env)
+			cancel()
+		case <-stop:
+			ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+			defer cancel()
+			for {
+				select {
+				case env := <-h.outbox:
+					h.send(ctx, env)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// send publishes env on the backplane; failures are logged.
+func (h *Hub) send(ctx context.Context, env Envelope) {
+	if err := h.config.Backplane.Publish(ctx, env); err != nil {
+		logger.L().WarnContext(ctx, "websocket backplane publish failed", "node", h.nodeID, "kind", env.Kind, "error", err)
+	}
+}
+
+func (h *Hub) publishPresence() {
+	if h.config.Backplane == nil {
+		return
+	}
+	p := h.localPresence()
+	h.publish(Envelope{Kind: KindPresence, Presence: &p})
+}
+
+// handleEnvelope applies traffic from other nodes.
+func (h *Hub) handleEnvelope(_ context.Context, env Envelope) {
+	if env.Node == h.nodeID {
+		return
+	}
+	switch env.Kind {
+	case KindRoom:
+		h.deliver(env.Room, env.Data)
+	case KindBroadcast:
+		h.deliver("", env.Data)
+	case KindPresence, KindLeave:
+		h.storePresence(env)
+	}
 }
 
 func (h *Hub) removeClient(client *Client) {
@@ -204,36 +474,41 @@ func (h *Hub) removeClient(client *Client) {
 			}
 		}
 	}
+	if s := client.session; s != nil {
+		// Detach before closing send so deliveries buffer instead.
+		s.detach(client, client.rooms)
+		h.park(s, client.rooms)
+	}
 	client.rooms = nil
 	close(client.send)
 }
 
-// broadcast sends to all clients. Map mutation for stale clients uses a write lock
-// (never mutates under RLock).
-func (h *Hub) broadcast(message []byte) {
-	h.mu.RLock()
-	clients := make([]*Client, 0, len(h.clients))
-	for client := range h.clients {
-		clients = append(clients, client)
-	}
-	h.mu.RUnlock()
-
-	var stale []*Client
-	for _, client := range clients {
-		select {
-		case client.send <- message:
-		default:
-			stale = append(stale, client)
+// park indexes a detached session under its rooms. Callers hold mu.
+func (h *Hub) park(s *session, rooms map[string]bool) {
+	for _, room := range append([]string{""}, keys(rooms)...) {
+		set := h.parked[room]
+		if set == nil {
+			set = make(map[*session]bool)
+			h.parked[room] = set
 		}
+		set[s] = true
 	}
+}
 
-	if len(stale) == 0 {
-		return
+func keys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
 	}
+	return out
+}
 
-	for _, client := range stale {
-		h.removeClient(client)
-	}
+// broadcast sends to all clients, on every node when a Backplane is
+// configured. Map mutation for stale clients uses a write lock (never
+// mutates under RLock).
+func (h *Hub) broadcast(message []byte) {
+	h.deliver("", message)
+	h.publish(Envelope{Kind: KindBroadcast, Data: message})
 }
 
 // Client is a middleman between the websocket connection and the hub.
@@ -243,6 +518,31 @@ type Client struct {
 	send  chan []byte
 	ctx   context.Context
 	rooms map[string]bool
+
+	id      string
+	user    string
+	session *session
+	lastSeq uint64
+	resumed bool
+}
+
+// ID returns the connection's unique ID.
+func (c *Client) ID() string {
+	return c.id
+}
+
+// UserID returns the user presence reports for this client.
+func (c *Client) UserID() string {
+	return c.user
+}
+
+// SessionID returns the reliable session ID, or "" when Config.Reliable is
+// off.
+func (c *Client) SessionID() string {
+	if c.session == nil {
+		return ""
+	}
+	return c.session.id
 }
 
 // Context returns the authenticated/upgrade context for this client.
@@ -305,11 +605,25 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	client := &Client{
 		hub:   hub,
 		conn:  conn,
-		send:  make(chan []byte, 256),
+		send:  make(chan []byte, max(256, hub.config.ResumeBuffer+1)),
 		ctx:   ctx,
 		rooms: make(map[string]bool),
+		id:    uuid.New().String(),
+	}
+	client.user = client.id
+	if hub.config.Identify != nil {
+		if user := hub.config.Identify(ctx); user != "" {
+			client.user = user
+		}
+	}
+	if hub.config.Reliable {
+		q := r.URL.Query()
+		hub.bindSession(client, q.Get(SessionParam), parseLastSeq(q.Get(LastSeqParam)))
 	}
 	client.hub.register <- client
+	if hub.config.OnConnect != nil {
+		hub.config.OnConnect(client)
+	}
 
 	go client.writePump()
 	go client.readPump()
@@ -335,6 +649,12 @@ func (c *Client) readPump() {
 		if err != nil {
 			break
 		}
+		if c.session != nil {
+			if seq, ok := parseAck(message); ok {
+				c.session.ack(seq)
+				continue
+			}
+		}
 		select {
 		case c.hub.Broadcast <- message:
 		case <-c.hub.done:
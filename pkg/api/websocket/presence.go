@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+)
+
+// remotePresence is the last snapshot received from another node.
+type remotePresence struct {
+	presence NodePresence
+	seen     time.Time
+}
+
+// localPresence snapshots the users connected to this node.
+func (h *Hub) localPresence() NodePresence {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	p := NodePresence{
+		Rooms: make(map[string]map[string]int, len(h.rooms)),
+		Users: make(map[string]int, len(h.clients)),
+	}
+	for c := range h.clients {
+		p.Users[c.user]++
+	}
+	for room, members := range h.rooms {
+		users := make(map[string]int, len(members))
+		for c := range members {
+			users[c.user]++
+		}
+		p.Rooms[room] = users
+	}
+	return p
+}
+
+// clusterPresence returns this node's snapshot followed by every live
+// remote snapshot.
+func (h *Hub) clusterPresence() []NodePresence {
+	out := []NodePresence{h.localPresence()}
+
+	h.presenceMu.RLock()
+	defer h.presenceMu.RUnlock()
+	for _, rp := range h.remote {
+		out = append(out, rp.presence)
+	}
+	return out
+}
+
+func (h *Hub) storePresence(env Envelope) {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+	if env.Kind == KindLeave || env.Presence == nil {
+		delete(h.remote, env.Node)
+		return
+	}
+	h.remote[env.Node] = &remotePresence{presence: *env.Presence, seen: time.Now()}
+}
+
+// expirePresence forgets nodes that stopped sending snapshots.
+func (h *Hub) expirePresence(now time.Time) {
+	ttl := 3 * h.config.PresenceInterval
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+	for node, rp := range h.remote {
+		if now.Sub(rp.seen) > ttl {
+			delete(h.remote, node)
+		}
+	}
+}
+
+// ClusterRoomSize returns the number of connections in a room across every
+// node on the backplane. Remote counts lag by up to Config.PresenceInterval.
+func (h *Hub) ClusterRoomSize(room string) int {
+	n := 0
+	for _, p := range h.clusterPresence() {
+		for _, count := range p.Rooms[room] {
+			n += count
+		}
+	}
+	return n
+}
+
+// Presence returns the sorted IDs of users connected anywhere in the
+// cluster, limited to room when it is non-empty.
+func (h *Hub) Presence(room string) []string {
+	seen := make(map[string]struct{})
+	for _, p := range h.clusterPresence() {
+		users := p.Users
+		if room != "" {
+			users = p.Rooms[room]
+		}
+		for user := range users {
+			seen[user] = struct{}{}
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for user := range seen {
+		out = append(out, user)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// IsOnline reports whether user has a connection on any node.
+func (h *Hub) IsOnline(user string) bool {
+	for _, p := range h.clusterPresence() {
+		if p.Users[user] > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/google/uuid"
+)
+
+// Query parameters a reliable client sends when reconnecting.
+const (
+	SessionParam = "session"
+	LastSeqParam = "last_seq"
+)
+
+// Frame types used when Config.Reliable is set.
+const (
+	FrameSession = "session" // server → client after connect
+	FrameMessage = "message" // server → client, sequenced payload
+	FrameAck     = "ack"     // client → server, acknowledges up to Seq
+)
+
+// Frame is the JSON wire format of reliable mode. Data holds the payload
+// as-is when it is valid JSON and as a JSON string otherwise.
+type Frame struct {
+	Type    string          `json:"type"`
+	Seq     uint64          `json:"seq,omitempty"`
+	Room    string          `json:"room,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Session string          `json:"session,omitempty"`
+	Resumed bool            `json:"resumed,omitempty"`
+}
+
+// session tracks sequenced delivery for one logical client across
+// reconnects. Unacknowledged frames stay buffered so a client reconnecting
+// with last_seq gets what it missed, including messages sent to its rooms
+// while it was away.
+type session struct {
+	id   string
+	user string
+
+	mu       *concurrency.SmartMutex
+	seq      uint64
+	buf      []bufferedFrame // unacknowledged frames, oldest first
+	limit    int
+	rooms    map[string]bool
+	client   *Client
+	detached time.Time
+}
+
+type bufferedFrame struct {
+	seq  uint64
+	data []byte
+}
+
+func newSession(user string, limit int) *session {
+	id := uuid.New().String()
+	return &session{
+		id:    id,
+		user:  user,
+		mu:    concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "websocket-session-" + id}),
+		limit: limit,
+		rooms: make(map[string]bool),
+	}
+}
+
+// deliver sequences message, buffers it and, when attached, queues it on
+// the client. It reports false when the client's send buffer is full.
+func (s *session) deliver(room string, message []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	frame := encodeFrame(Frame{Type: FrameMessage, Seq: s.seq, Room: room, Data: frameData(message)})
+	s.buf = append(s.buf, bufferedFrame{seq: s.seq, data: frame})
+	if len(s.buf) > s.limit {
+		s.buf = s.buf[len(s.buf)-s.limit:]
+	}
+	if s.client == nil {
+		return true
+	}
+	select {
+	case s.client.send <- frame:
+		return true
+	default:
+		return false
+	}
+}
+
+// ack drops buffered frames up to and including seq.
+func (s *session) ack(seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trim(seq)
+}
+
+func (s *session) trim(seq uint64) {
+	i := 0
+	for i < len(s.buf) && s.buf[i].seq <= seq {
+		i++
+	}
+	s.buf = s.buf[i:]
+}
+
+// canResume reports whether the session is detached and every frame after
+// lastSeq is still buffered.
+func (s *session) canResume(lastSeq uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil || lastSeq > s.seq {
+		return false
+	}
+	if len(s.buf) == 0 {
+		return lastSeq == s.seq
+	}
+	return s.buf[0].seq <= lastSeq+1
+}
+
+// attach binds c to the session and queues the session frame followed by
+// every buffered frame after lastSeq, before any new delivery can interleave.
+func (s *session) attach(c *Client, lastSeq uint64, resumed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trim(lastSeq)
+	s.client = c
+	s.detached = time.Time{}
+	c.send <- encodeFrame(Frame{Type: FrameSession, Session: s.id, Seq: s.seq, Resumed: resumed})
+	for _, f := range s.buf {
+		c.send <- f.data
+	}
+}
+
+// detach unbinds c, keeping its rooms so messages keep buffering.
+func (s *session) detach(c *Client, rooms map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != c {
+		return
+	}
+	s.client = nil
+	s.detached = time.Now()
+	s.rooms = make(map[string]bool, len(rooms))
+	for room := range rooms {
+		s.rooms[room] = true
+	}
+}
+
+// expired reports whether the session has been detached longer than ttl.
+func (s *session) expired(now time.Time, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.client == nil && !s.detached.IsZero() && now.Sub(s.detached) > ttl
+}
+
+func (s *session) roomList() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rooms := make([]string, 0, len(s.rooms))
+	for room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// parseAck returns the sequence number of an ack frame.
+func parseAck(message []byte) (uint64, bool) {
+	if len(message) == 0 || message[0] != '{' {
+		return 0, false
+	}
+	var f Frame
+	if err := json.Unmarshal(message, &f); err != nil || f.Type != FrameAck {
+		return 0, false
+	}
+	return f.Seq, true
+}
+
+func parseLastSeq(v string) uint64 {
+	n, _ := strconv.ParseUint(v, 10, 64)
+	return n
+}
+
+func frameData(message []byte) json.RawMessage {
+	if json.Valid(message) {
+		return message
+	}
+	data, _ := json.Marshal(string(message))
+	return data
+}
+
+func encodeFrame(f Frame) []byte {
+	data, _ := json.Marshal(f)
+	return data
+}
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/audit"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+// Ensure compile-time interface compliance.
+var _ audit.CheckpointStore = (*CheckpointStore)(nil)
+
+// CheckpointStore is an in-memory audit.CheckpointStore for tests.
+type CheckpointStore struct {
+	checkpoints []audit.Checkpoint
+	mu          *concurrency.SmartRWMutex
+}
+
+// NewCheckpointStore creates an empty in-memory checkpoint store.
+func NewCheckpointStore() *CheckpointStore {
+	return &CheckpointStore{
+		mu: concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "audit-memory-checkpoints"}),
+	}
+}
+
+// SaveCheckpoint stores cp. An existing sequence is a Conflict.
+func (s *CheckpointStore) SaveCheckpoint(ctx context.Context, cp audit.Checkpoint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if cp.Sequence < 1 {
+		return audit.ErrInvalidArgument("checkpoint sequence must be >= 1", nil)
+	}
+	cp.Signature = append([]byte(nil), cp.Signature...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := 0
+	for i < len(s.checkpoints) && s.checkpoints[i].Sequence < cp.Sequence {
+		i++
+	}
+	if i < len(s.checkpoints) && s.checkpoints[i].Sequence == cp.Sequence {
+		return errors.Conflict("checkpoint sequence already exists", nil)
+	}
+	s.checkpoints = append(s.checkpoints, audit.Checkpoint{})
+	copy(s.checkpoints[i+1:], s.checkpoints[i:])
+	s.checkpoints[i] = cp
+	return nil
+}
+
+// ListCheckpoints returns all checkpoints ordered by sequence.
+func (s *CheckpointStore) ListCheckpoints(ctx context.Context) ([]audit.Checkpoint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]audit.Checkpoint(nil), s.checkpoints...), nil
+}
+
+// LatestCheckpoint returns the checkpoint with the highest sequence.
+func (s *CheckpointStore) LatestCheckpoint(ctx context.Context) (audit.Checkpoint, error) {
+	if err := ctx.Err(); err != nil {
+		return audit.Checkpoint{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.checkpoints) == 0 {
+		return audit.Checkpoint{}, errors.NotFound("no audit checkpoint", nil)
+	}
+	return s.checkpoints[len(s.checkpoints)-1], nil
+}
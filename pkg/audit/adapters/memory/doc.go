@@ -7,5 +7,7 @@ Events are retained only for the process lifetime.
 Optional hash chaining (NewChainedStore / WithHashChain) stamps ID, Hash, and
 PrevHash for tamper-evident append-only logs. Implements LifecycleStore for
 retention purge and GDPR Export/Erase by actor ID.
+
+NewCheckpointStore provides an in-memory audit.CheckpointStore for tests.
 */
 package memory
@@ -112,15 +112,23 @@ func (s *Store) Query(ctx context.Context, filter audit.QueryFilter) ([]audit.Ev
 	if filter.Limit < 0 {
 		return nil, audit.ErrInvalidArgument("limit must be >= 0", nil)
 	}
+	if filter.Offset < 0 {
+		return nil, audit.ErrInvalidArgument("offset must be >= 0", nil)
+	}
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	out := make([]audit.Event, 0)
+	skip := filter.Offset
 	for _, e := range s.events {
 		if !matchFilter(e, filter) {
 			continue
 		}
+		if skip > 0 {
+			skip--
+			continue
+		}
 		out = append(out, e)
 		if filter.Limit > 0 && len(out) >= filter.Limit {
 			break
@@ -25,3 +25,9 @@ func New(db *sql.DB, cfg Config) (*auditsql.Store, error) {
 		HashChain: cfg.HashChain,
 	})
 }
+
+// NewCheckpointStore returns an auditsql.CheckpointStore configured for
+// PostgreSQL.
+func NewCheckpointStore(db *sql.DB) (*auditsql.CheckpointStore, error) {
+	return auditsql.NewCheckpointStore(db, auditsql.Config{Dialect: auditsql.DialectPostgres})
+}
@@ -0,0 +1,190 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	stderrors "errors"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/audit"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/resilience"
+)
+
+// Ensure compile-time interface compliance.
+var _ audit.CheckpointStore = (*CheckpointStore)(nil)
+
+var checkpointSchemaStatements = []string{
+	`CREATE TABLE IF NOT EXISTS audit_checkpoints (
+	sequence BIGINT PRIMARY KEY,
+	event_id TEXT NOT NULL,
+	head TEXT NOT NULL,
+	head_timestamp TIMESTAMP NOT NULL,
+	event_count BIGINT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	key_id TEXT NOT NULL,
+	signature TEXT NOT NULL
+)`,
+}
+
+const checkpointColumns = `sequence, event_id, head, head_timestamp, event_count, created_at, key_id, signature`
+
+// CheckpointStore persists signed audit checkpoints. Point it at a different
+// database (or at least different credentials) than the event Store.
+type CheckpointStore struct {
+	db      *sql.DB
+	dialect Dialect
+	retrier resilience.Retrier
+}
+
+// NewCheckpointStore wraps an existing *sql.DB. Call Migrate before use.
+// Config.HashChain is ignored.
+func NewCheckpointStore(db *sql.DB, cfg Config) (*CheckpointStore, error) {
+	if db == nil {
+		return nil, audit.ErrInvalidArgument("db is required", nil)
+	}
+	retrier := cfg.Retrier
+	if retrier == nil {
+		retrier = resilience.NewRetrier(resilience.DefaultRetryConfig())
+	}
+	return &CheckpointStore{db: db, dialect: cfg.Dialect, retrier: retrier}, nil
+}
+
+// Migrate creates the audit_checkpoints table if missing.
+func (s *CheckpointStore) Migrate(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.retrier.Execute(ctx, func(ctx context.Context) error {
+		for _, stmt := range checkpointSchemaStatements {
+			if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+				return audit.ErrCheckpointFailed("migrate audit_checkpoints failed", err)
+			}
+		}
+		return nil
+	})
+}
+
+// SaveCheckpoint inserts cp. An existing sequence is a Conflict.
+func (s *CheckpointStore) SaveCheckpoint(ctx context.Context, cp audit.Checkpoint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if cp.Sequence < 1 {
+		return audit.ErrInvalidArgument("checkpoint sequence must be >= 1", nil)
+	}
+	var exists bool
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		var one int
+		err := s.db.QueryRowContext(ctx, rewrite(s.dialect, `SELECT 1 FROM audit_checkpoints WHERE sequence = ?`), cp.Sequence).Scan(&one)
+		switch {
+		case err == nil:
+			exists = true
+			return nil
+		case !stderrors.Is(err, sql.ErrNoRows):
+			return audit.ErrCheckpointFailed("read checkpoint failed", err)
+		}
+		_, err = s.db.ExecContext(ctx, rewrite(s.dialect, `INSERT INTO audit_checkpoints (`+checkpointColumns+`)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+			cp.Sequence, cp.EventID, cp.Head, cp.HeadTimestamp.UTC(), cp.Count,
+			cp.CreatedAt.UTC(), cp.KeyID, encodeSignature(cp.Signature))
+		if err != nil {
+			return audit.ErrCheckpointFailed("insert checkpoint failed", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.Conflict("checkpoint sequence already exists", nil)
+	}
+	return nil
+}
+
+// ListCheckpoints returns all checkpoints ordered by sequence.
+func (s *CheckpointStore) ListCheckpoints(ctx context.Context) ([]audit.Checkpoint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var out []audit.Checkpoint
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		rows, err := s.db.QueryContext(ctx, `SELECT `+checkpointColumns+` FROM audit_checkpoints ORDER BY sequence ASC`)
+		if err != nil {
+			return audit.ErrCheckpointFailed("list checkpoints failed", err)
+		}
+		defer rows.Close()
+		out = out[:0]
+		for rows.Next() {
+			cp, err := scanCheckpoint(rows)
+			if err != nil {
+				return err
+			}
+			out = append(out, cp)
+		}
+		if err := rows.Err(); err != nil {
+			return audit.ErrCheckpointFailed("list checkpoints failed", err)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// LatestCheckpoint returns the checkpoint with the highest sequence.
+func (s *CheckpointStore) LatestCheckpoint(ctx context.Context) (audit.Checkpoint, error) {
+	if err := ctx.Err(); err != nil {
+		return audit.Checkpoint{}, err
+	}
+	var (
+		cp    audit.Checkpoint
+		found bool
+	)
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		row := s.db.QueryRowContext(ctx, `SELECT `+checkpointColumns+` FROM audit_checkpoints ORDER BY sequence DESC LIMIT 1`)
+		var err error
+		cp, err = scanCheckpoint(row)
+		if stderrors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		found = err == nil
+		return err
+	})
+	if err != nil {
+		return audit.Checkpoint{}, err
+	}
+	if !found {
+		return audit.Checkpoint{}, errors.NotFound("no audit checkpoint", nil)
+	}
+	return cp, nil
+}
+
+func scanCheckpoint(row rowScanner) (audit.Checkpoint, error) {
+	var (
+		cp      audit.Checkpoint
+		headTS  time.Time
+		created time.Time
+		sig     string
+	)
+	err := row.Scan(&cp.Sequence, &cp.EventID, &cp.Head, &headTS, &cp.Count, &created, &cp.KeyID, &sig)
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return audit.Checkpoint{}, err
+	}
+	if err != nil {
+		return audit.Checkpoint{}, audit.ErrCheckpointFailed("scan checkpoint failed", err)
+	}
+	cp.HeadTimestamp = headTS.UTC()
+	cp.CreatedAt = created.UTC()
+	if cp.Signature, err = decodeSignature(sig); err != nil {
+		return audit.Checkpoint{}, audit.ErrCheckpointFailed("decode checkpoint signature failed", err)
+	}
+	return cp, nil
+}
+
+func encodeSignature(sig []byte) string {
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func decodeSignature(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(s)
+}
@@ -5,5 +5,9 @@ Works with PostgreSQL and SQLite (and other dialects that accept the shared
 DDL). Call Migrate once at startup. Optional hash chaining stamps prev_hash /
 hash columns for tamper evidence. Implements LifecycleStore for retention
 purge and GDPR Export/Erase by actor ID.
+
+NewCheckpointStore persists signed chain checkpoints in audit_checkpoints.
+Give it a separate database or credentials from the event store so one
+administrator cannot rewrite both.
 */
 package sql
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -97,7 +98,12 @@ func New(db *sql.DB, cfg Config) (*Store, error) {
 }
 
 func (s *Store) rewrite(query string) string {
-	if s.dialect != DialectPostgres {
+	return rewrite(s.dialect, query)
+}
+
+// rewrite converts ? placeholders to $n for Postgres.
+func rewrite(dialect Dialect, query string) string {
+	if dialect != DialectPostgres {
 		return query
 	}
 	var b strings.Builder
@@ -205,6 +211,9 @@ func (s *Store) Query(ctx context.Context, filter audit.QueryFilter) ([]audit.Ev
 	if filter.Limit < 0 {
 		return nil, audit.ErrInvalidArgument("limit must be >= 0", nil)
 	}
+	if filter.Offset < 0 {
+		return nil, audit.ErrInvalidArgument("offset must be >= 0", nil)
+	}
 
 	var out []audit.Event
 	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
@@ -230,9 +239,19 @@ func (s *Store) Query(ctx context.Context, filter audit.QueryFilter) ([]audit.Ev
 			args = append(args, filter.Until.UTC())
 		}
 		q += ` ORDER BY timestamp ASC, id ASC`
-		if filter.Limit > 0 {
+		if filter.Limit > 0 || filter.Offset > 0 {
+			// SQLite and MySQL only accept OFFSET after a LIMIT; the
+			// largest BIGINT means no limit in every dialect.
+			limit := int64(math.MaxInt64)
+			if filter.Limit > 0 {
+				limit = int64(filter.Limit)
+			}
 			q += ` LIMIT ?`
-			args = append(args, filter.Limit)
+			args = append(args, limit)
+		}
+		if filter.Offset > 0 {
+			q += ` OFFSET ?`
+			args = append(args, filter.Offset)
 		}
 
 		rows, err := s.db.QueryContext(ctx, s.rewrite(q), args...)
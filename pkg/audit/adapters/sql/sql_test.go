@@ -3,11 +3,13 @@ package sql_test
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/audit"
 	auditsql "github.com/chris-alexander-pop/go-hyperforge/pkg/audit/adapters/sql"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/test"
 	_ "modernc.org/sqlite"
 )
@@ -99,6 +101,80 @@ func (s *SQLStoreSuite) TestCanceledContext() {
 	s.Require().Error(err)
 }
 
+func (s *SQLStoreSuite) TestOffsetPaging() {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		s.Require().NoError(s.store.Append(s.Ctx, audit.Event{
+			EventType: audit.EventTypeDataRead,
+			ActorID:   fmt.Sprintf("actor-%d", i),
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+		}))
+	}
+
+	page, err := s.store.Query(s.Ctx, audit.QueryFilter{Offset: 1, Limit: 2})
+	s.Require().NoError(err)
+	s.Require().Len(page, 2)
+	s.Equal("actor-1", page[0].ActorID)
+	s.Equal("actor-2", page[1].ActorID)
+
+	rest, err := s.store.Query(s.Ctx, audit.QueryFilter{Offset: 3})
+	s.Require().NoError(err)
+	s.Require().Len(rest, 2)
+	s.Equal("actor-4", rest[1].ActorID)
+
+	_, err = s.store.Query(s.Ctx, audit.QueryFilter{Offset: -1})
+	s.Require().Error(err)
+}
+
+func (s *SQLStoreSuite) TestCheckpointStore() {
+	cps, err := auditsql.NewCheckpointStore(s.db, auditsql.Config{Dialect: auditsql.DialectSQLite})
+	s.Require().NoError(err)
+	s.Require().NoError(cps.Migrate(s.Ctx))
+
+	_, err = cps.LatestCheckpoint(s.Ctx)
+	s.True(errors.IsCode(err, errors.CodeNotFound))
+
+	for i := 0; i < 3; i++ {
+		s.Require().NoError(s.store.Append(s.Ctx, audit.Event{EventType: audit.EventTypeLogin, ActorID: "alice"}))
+	}
+	verifier := audit.NewChainVerifier(s.store, audit.VerifierConfig{PageSize: 2})
+	report, err := verifier.VerifyChain(s.Ctx)
+	s.Require().NoError(err)
+	s.Require().True(report.Intact())
+	s.Equal(int64(3), report.Events)
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cp := audit.Checkpoint{
+		Sequence:      1,
+		EventID:       report.HeadEventID,
+		Head:          report.Head,
+		HeadTimestamp: report.HeadTimestamp,
+		Count:         report.Events,
+		CreatedAt:     created,
+		KeyID:         "k1",
+		Signature:     []byte{0, 1, 2, 255},
+	}
+	s.Require().NoError(cps.SaveCheckpoint(s.Ctx, cp))
+	s.True(errors.IsCode(cps.SaveCheckpoint(s.Ctx, cp), errors.CodeConflict))
+
+	cp2 := cp
+	cp2.Sequence = 2
+	s.Require().NoError(cps.SaveCheckpoint(s.Ctx, cp2))
+
+	latest, err := cps.LatestCheckpoint(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(int64(2), latest.Sequence)
+	s.Equal(cp.Signature, latest.Signature)
+	s.True(cp.HeadTimestamp.Equal(latest.HeadTimestamp))
+	latest.Sequence = 1
+	s.Equal(cp.SigningBytes(), latest.SigningBytes(), "round trip preserves the signed payload")
+
+	all, err := cps.ListCheckpoints(s.Ctx)
+	s.Require().NoError(err)
+	s.Require().Len(all, 2)
+	s.Equal(int64(1), all[0].Sequence)
+}
+
 func TestSQLStoreSuite(t *testing.T) {
 	test.Run(t, new(SQLStoreSuite))
 }
@@ -30,6 +30,10 @@ type QueryFilter struct {
 
 	// Limit caps the number of results. Zero means no limit.
 	Limit int
+
+	// Offset skips this many matching events (in store order) before
+	// returning results; used with Limit to page through a store.
+	Offset int
 }
 
 // Store persists and queries audit events.
@@ -0,0 +1,231 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+)
+
+// Checkpoint is a signed anchor of the chain head. Checkpoints are kept in a
+// CheckpointStore separate from the events, so rewriting history and
+// recomputing every hash still fails verification against a signed head.
+type Checkpoint struct {
+	// Sequence numbers checkpoints from 1 without gaps.
+	Sequence int64 `json:"sequence"`
+
+	// EventID and Head identify the last event covered and its hash.
+	EventID string `json:"event_id"`
+	Head    string `json:"head"`
+
+	// HeadTimestamp is the covered event's Timestamp.
+	HeadTimestamp time.Time `json:"head_timestamp"`
+
+	// Count is the number of chained events up to and including Head.
+	Count int64 `json:"count"`
+
+	// CreatedAt is when the checkpoint was signed.
+	CreatedAt time.Time `json:"created_at"`
+
+	// KeyID names the signing key (see CheckpointKeys).
+	KeyID string `json:"key_id"`
+
+	// Signature is over SigningBytes.
+	Signature []byte `json:"signature"`
+}
+
+// SigningBytes returns the canonical bytes that are signed: every field
+// except Signature.
+func (c Checkpoint) SigningBytes() []byte {
+	payload := struct {
+		Version       string `json:"v"`
+		Sequence      int64  `json:"sequence"`
+		EventID       string `json:"event_id"`
+		Head          string `json:"head"`
+		HeadTimestamp string `json:"head_timestamp"`
+		Count         int64  `json:"count"`
+		CreatedAt     string `json:"created_at"`
+		KeyID         string `json:"key_id"`
+	}{
+		Version:       "audit-checkpoint/1",
+		Sequence:      c.Sequence,
+		EventID:       c.EventID,
+		Head:          c.Head,
+		HeadTimestamp: c.HeadTimestamp.UTC().Format(time.RFC3339Nano),
+		Count:         c.Count,
+		CreatedAt:     c.CreatedAt.UTC().Format(time.RFC3339Nano),
+		KeyID:         c.KeyID,
+	}
+	raw, _ := json.Marshal(payload) // strings and ints only; cannot fail
+	return raw
+}
+
+// CheckpointStore persists signed checkpoints. Keep it apart from the event
+// store (another database, account or WORM bucket) so one administrator
+// cannot rewrite both.
+type CheckpointStore interface {
+	// SaveCheckpoint stores cp. Saving an existing Sequence is a Conflict.
+	SaveCheckpoint(ctx context.Context, cp Checkpoint) error
+
+	// ListCheckpoints returns all checkpoints ordered by Sequence.
+	ListCheckpoints(ctx context.Context) ([]Checkpoint, error)
+
+	// LatestCheckpoint returns the highest Sequence, or NotFound when empty.
+	LatestCheckpoint(ctx context.Context) (Checkpoint, error)
+}
+
+// Signer produces detached signatures. pqc.Signer from
+// pkg/security/crypto/pqc (ML-DSA) satisfies it.
+type Signer interface {
+	Sign(privateKey, message []byte) (signature []byte, err error)
+}
+
+// SignatureVerifier checks detached signatures. pqc.Verifier from
+// pkg/security/crypto/pqc satisfies it.
+type SignatureVerifier interface {
+	Verify(publicKey, message, signature []byte) (bool, error)
+}
+
+// CheckpointSigner signs checkpoints with a private key.
+type CheckpointSigner struct {
+	// KeyID is recorded on each checkpoint so verifiers pick the right key.
+	KeyID string
+
+	// Signer is typically pqc.NewDilithiumSigner(pqc.DilithiumLevel3).
+	Signer Signer
+
+	// PrivateKey is the packed private key for Signer.
+	PrivateKey []byte
+}
+
+// CheckpointKeys verifies checkpoint signatures. Keys maps KeyID to a packed
+// public key, so rotated keys keep verifying older checkpoints.
+type CheckpointKeys struct {
+	Verifier SignatureVerifier
+	Keys     map[string][]byte
+}
+
+// verify reports whether cp carries a valid signature from a known key.
+func (k CheckpointKeys) verify(cp Checkpoint) (bool, error) {
+	if k.Verifier == nil {
+		return false, ErrInvalidArgument("checkpoint verifier is required", nil)
+	}
+	pub, ok := k.Keys[cp.KeyID]
+	if !ok {
+		return false, nil
+	}
+	return k.Verifier.Verify(pub, cp.SigningBytes(), cp.Signature)
+}
+
+// CheckpointConfig configures a Checkpointer.
+type CheckpointConfig struct {
+	// Interval is how often Run signs the chain head.
+	Interval time.Duration `env:"AUDIT_CHECKPOINT_INTERVAL" env-default:"1h"`
+
+	// Verify configures the verification run before each checkpoint.
+	Verify VerifierConfig
+}
+
+// Checkpointer periodically verifies the events appended since the last
+// checkpoint and signs the new chain head.
+type Checkpointer struct {
+	checkpoints CheckpointStore
+	signer      CheckpointSigner
+	verifier    *ChainVerifier
+	cfg         CheckpointConfig
+	now         func() time.Time
+}
+
+// NewCheckpointer creates a Checkpointer for store. keys must include the
+// signer's public key so the previous checkpoint can be checked before it is
+// extended.
+func NewCheckpointer(store Store, checkpoints CheckpointStore, signer CheckpointSigner, keys CheckpointKeys, cfg CheckpointConfig) (*Checkpointer, error) {
+	if store == nil || checkpoints == nil {
+		return nil, ErrInvalidArgument("audit store and checkpoint store are required", nil)
+	}
+	if signer.Signer == nil || len(signer.PrivateKey) == 0 || signer.KeyID == "" {
+		return nil, ErrInvalidArgument("checkpoint signer, private key and key id are required", nil)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	return &Checkpointer{
+		checkpoints: checkpoints,
+		signer:      signer,
+		verifier:    NewChainVerifier(store, cfg.Verify, WithCheckpoints(checkpoints, keys)),
+		cfg:         cfg,
+		now:         time.Now,
+	}, nil
+}
+
+// SetNowFunc overrides the clock (tests).
+func (c *Checkpointer) SetNowFunc(fn func() time.Time) {
+	if fn != nil {
+		c.now = fn
+	}
+}
+
+// Checkpoint verifies the chain from the latest checkpoint and, if new events
+// were appended, signs and stores a checkpoint for the new head. It returns
+// the latest checkpoint (which may be the existing one, or zero when the
+// chain is empty). A broken chain is never checkpointed: it returns an
+// ErrChainBroken error.
+func (c *Checkpointer) Checkpoint(ctx context.Context) (Checkpoint, error) {
+	var from *Checkpoint
+	latest, err := c.checkpoints.LatestCheckpoint(ctx)
+	switch {
+	case err == nil:
+		from = &latest
+	case !errors.IsCode(err, errors.CodeNotFound):
+		return Checkpoint{}, ErrCheckpointFailed("failed to load latest checkpoint", err)
+	}
+
+	report, err := c.verifier.verify(ctx, from)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	if report.Break != nil {
+		return Checkpoint{}, report.Break.Err()
+	}
+	if report.Events == 0 || (from != nil && report.HeadEventID == from.EventID) {
+		return latest, nil
+	}
+
+	cp := Checkpoint{
+		Sequence:      latest.Sequence + 1,
+		EventID:       report.HeadEventID,
+		Head:          report.Head,
+		HeadTimestamp: report.HeadTimestamp,
+		Count:         report.Events,
+		CreatedAt:     c.now().UTC(),
+		KeyID:         c.signer.KeyID,
+	}
+	sig, err := c.signer.Signer.Sign(c.signer.PrivateKey, cp.SigningBytes())
+	if err != nil {
+		return Checkpoint{}, ErrCheckpointFailed("failed to sign checkpoint", err)
+	}
+	cp.Signature = sig
+	if err := c.checkpoints.SaveCheckpoint(ctx, cp); err != nil {
+		return Checkpoint{}, ErrCheckpointFailed("failed to save checkpoint", err)
+	}
+	return cp, nil
+}
+
+// Run checkpoints every Interval until ctx is cancelled. Failures (including
+// a broken chain) are logged and retried on the next tick.
+func (c *Checkpointer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := c.Checkpoint(ctx); err != nil && ctx.Err() == nil {
+			logger.L().ErrorContext(ctx, "audit checkpoint failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -7,6 +7,10 @@ This package includes:
   - PII redaction utilities (pattern-based and sensitive field-name matching)
   - Store adapters: memory, stdout logger, durable SQL/Postgres, messaging fanout
   - Optional tamper-evident hash chaining (Hash / PrevHash)
+  - Streaming chain verification (ChainVerifier) reporting modified, missing
+    or reordered events
+  - Signed checkpoints of the chain head (Checkpointer) kept in a separate
+    CheckpointStore, so rewriting history and recomputing hashes is detected
   - Retention purge and GDPR Export/Erase-by-actor on LifecycleStore adapters
 
 Usage:
@@ -25,5 +29,20 @@ Usage:
 		CorrelationID("corr-1").
 		Outcome(audit.OutcomeSuccess).
 		Send()
+
+Verifying the chain and anchoring its head (any pkg/security/crypto/pqc
+signer works, e.g. pqc.NewDilithiumSigner(pqc.DilithiumLevel3)):
+
+	keys := audit.CheckpointKeys{Verifier: signer, Keys: map[string][]byte{"k1": pub}}
+	cp, _ := audit.NewCheckpointer(store, checkpointStore,
+		audit.CheckpointSigner{KeyID: "k1", Signer: signer, PrivateKey: priv}, keys,
+		audit.CheckpointConfig{Interval: time.Hour})
+	go cp.Run(ctx)
+
+	report, err := audit.NewChainVerifier(store, audit.VerifierConfig{},
+		audit.WithCheckpoints(checkpointStore, keys)).VerifyChain(ctx)
+	if err == nil && !report.Intact() {
+		// report.Break.Kind is modified, gap, reordered or checkpoint
+	}
 */
 package audit
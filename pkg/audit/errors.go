@@ -16,6 +16,7 @@ const (
 	CodeChainBroken     = "AUDIT_CHAIN_BROKEN"
 	CodePurgeFailed     = "AUDIT_PURGE_FAILED"
 	CodeEraseFailed     = "AUDIT_ERASE_FAILED"
+	CodeCheckpoint      = "AUDIT_CHECKPOINT_FAILED"
 )
 
 // ErrNotSupported is returned when an adapter does not support an operation
@@ -77,3 +78,12 @@ func ErrEraseFailed(msg string, err error) *errors.AppError {
 	}
 	return errors.New(CodeEraseFailed, msg, err)
 }
+
+// ErrCheckpointFailed wraps a failure while creating, signing or storing a
+// chain checkpoint.
+func ErrCheckpointFailed(msg string, err error) *errors.AppError {
+	if msg == "" {
+		msg = "failed to checkpoint audit chain"
+	}
+	return errors.New(CodeCheckpoint, msg, err)
+}
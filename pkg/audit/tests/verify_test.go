@@ -0,0 +1,292 @@
+package audit_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/audit"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/audit/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/test"
+)
+
+// sliceStore serves a fixed slice of events so tests can tamper with them.
+type sliceStore struct {
+	events []audit.Event
+}
+
+func (s *sliceStore) Append(ctx context.Context, e audit.Event) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *sliceStore) Query(ctx context.Context, f audit.QueryFilter) ([]audit.Event, error) {
+	if f.Offset >= len(s.events) {
+		return nil, nil
+	}
+	out := s.events[f.Offset:]
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return append([]audit.Event(nil), out...), nil
+}
+
+// ed25519Signer satisfies audit.Signer and audit.SignatureVerifier.
+type ed25519Signer struct{}
+
+func (ed25519Signer) Sign(priv, msg []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(priv), msg), nil
+}
+
+func (ed25519Signer) Verify(pub, msg, sig []byte) (bool, error) {
+	return ed25519.Verify(ed25519.PublicKey(pub), msg, sig), nil
+}
+
+type VerifySuite struct {
+	test.Suite
+	signer audit.CheckpointSigner
+	keys   audit.CheckpointKeys
+}
+
+func TestVerifySuite(t *testing.T) {
+	test.Run(t, new(VerifySuite))
+}
+
+func (s *VerifySuite) SetupTest() {
+	s.Suite.SetupTest()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	s.Require().NoError(err)
+	s.signer = audit.CheckpointSigner{KeyID: "k1", Signer: ed25519Signer{}, PrivateKey: priv}
+	s.keys = audit.CheckpointKeys{Verifier: ed25519Signer{}, Keys: map[string][]byte{"k1": pub}}
+}
+
+// chain appends n chained events starting at start, one minute apart.
+func (s *VerifySuite) chain(store audit.Store, start time.Time, n int) {
+	for i := 0; i < n; i++ {
+		s.Require().NoError(store.Append(s.Ctx, audit.Event{
+			EventType: audit.EventTypeDataRead,
+			ActorID:   fmt.Sprintf("actor-%d", i),
+			Outcome:   audit.OutcomeSuccess,
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+}
+
+func (s *VerifySuite) events(n int) []audit.Event {
+	store := memory.NewChainedStore()
+	s.chain(store, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), n)
+	events, err := store.Query(s.Ctx, audit.QueryFilter{})
+	s.Require().NoError(err)
+	return events
+}
+
+// rehash recomputes every link from index i, as an attacker with write
+// access would.
+func (s *VerifySuite) rehash(events []audit.Event, i int) {
+	prev := "GENESIS"
+	if i > 0 {
+		prev = events[i-1].Hash
+	}
+	for j := i; j < len(events); j++ {
+		events[j].PrevHash = prev
+		h, err := audit.HashEvent(events[j])
+		s.Require().NoError(err)
+		events[j].Hash = h
+		prev = h
+	}
+}
+
+func (s *VerifySuite) verify(store audit.Store, cfg audit.VerifierConfig, opts ...audit.VerifierOption) audit.ChainReport {
+	if cfg.PageSize == 0 {
+		cfg.PageSize = 7
+	}
+	report, err := audit.NewChainVerifier(store, cfg, opts...).VerifyChain(s.Ctx)
+	s.Require().NoError(err)
+	return report
+}
+
+func (s *VerifySuite) TestIntactAcrossPages() {
+	events := s.events(25)
+	report := s.verify(&sliceStore{events: events}, audit.VerifierConfig{})
+	s.True(report.Intact())
+	s.Equal(int64(25), report.Events)
+	s.Equal(events[24].Hash, report.Head)
+	s.Equal(events[24].ID, report.HeadEventID)
+
+	empty := s.verify(&sliceStore{}, audit.VerifierConfig{})
+	s.True(empty.Intact())
+	s.Zero(empty.Events)
+}
+
+func (s *VerifySuite) TestDetectsModified() {
+	events := s.events(25)
+	events[10].Action = "tampered"
+	report := s.verify(&sliceStore{events: events}, audit.VerifierConfig{})
+	s.Require().NotNil(report.Break)
+	s.Equal(audit.ChainBreakModified, report.Break.Kind)
+	s.Equal(int64(10), report.Break.Index)
+	s.Equal(events[10].ID, report.Break.EventID)
+	s.True(errors.IsCode(report.Break.Err(), audit.CodeChainBroken))
+}
+
+func (s *VerifySuite) TestDetectsGap() {
+	events := s.events(25)
+	events = append(events[:10], events[11:]...)
+	report := s.verify(&sliceStore{events: events}, audit.VerifierConfig{})
+	s.Require().NotNil(report.Break)
+	s.Equal(audit.ChainBreakGap, report.Break.Kind)
+	s.Equal(int64(10), report.Break.Index)
+	s.Equal(int64(10), report.Events, "events before the break are reported")
+}
+
+func (s *VerifySuite) TestDetectsReordering() {
+	events := s.events(25)
+	events[13], events[14] = events[14], events[13]
+	report := s.verify(&sliceStore{events: events}, audit.VerifierConfig{})
+	s.Require().NotNil(report.Break)
+	s.Equal(audit.ChainBreakReordered, report.Break.Kind)
+	s.Equal(int64(13), report.Break.Index)
+}
+
+func (s *VerifySuite) TestTruncatedChain() {
+	store := memory.NewChainedStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.chain(store, start, 20)
+	_, err := store.Purge(s.Ctx, start.Add(5*time.Minute))
+	s.Require().NoError(err)
+
+	strict := s.verify(store, audit.VerifierConfig{})
+	s.Require().NotNil(strict.Break)
+	s.Equal(int64(0), strict.Break.Index)
+
+	lenient := s.verify(store, audit.VerifierConfig{AllowTruncated: true})
+	s.True(lenient.Intact())
+	s.True(lenient.Truncated)
+	s.Equal(int64(15), lenient.Events)
+}
+
+func (s *VerifySuite) newCheckpointer(store audit.Store, cps audit.CheckpointStore) *audit.Checkpointer {
+	c, err := audit.NewCheckpointer(store, cps, s.signer, s.keys, audit.CheckpointConfig{
+		Verify: audit.VerifierConfig{PageSize: 4},
+	})
+	s.Require().NoError(err)
+	return c
+}
+
+func (s *VerifySuite) TestCheckpointer() {
+	store := &sliceStore{events: s.events(10)}
+	cps := memory.NewCheckpointStore()
+	c := s.newCheckpointer(store, cps)
+
+	first, err := c.Checkpoint(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(int64(1), first.Sequence)
+	s.Equal(int64(10), first.Count)
+	s.Equal(store.events[9].Hash, first.Head)
+	s.NotEmpty(first.Signature)
+
+	unchanged, err := c.Checkpoint(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(int64(1), unchanged.Sequence, "no new events, no new checkpoint")
+
+	more := s.events(15)
+	store.events = append(store.events, more[10:]...)
+	second, err := c.Checkpoint(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(int64(2), second.Sequence)
+	s.Equal(int64(15), second.Count)
+
+	report := s.verify(store, audit.VerifierConfig{}, audit.WithCheckpoints(cps, s.keys))
+	s.True(report.Intact())
+	s.Equal(2, report.Checkpoints)
+}
+
+func (s *VerifySuite) TestCheckpointCatchesRehashedRewrite() {
+	store := &sliceStore{events: s.events(20)}
+	cps := memory.NewCheckpointStore()
+	c := s.newCheckpointer(store, cps)
+	_, err := c.Checkpoint(s.Ctx)
+	s.Require().NoError(err)
+
+	// Rewrite an old event and recompute every hash after it: the chain
+	// itself verifies, only the signed checkpoint exposes it.
+	store.events[3].Action = "rewritten"
+	s.rehash(store.events, 3)
+	s.True(s.verify(store, audit.VerifierConfig{}).Intact())
+
+	report := s.verify(store, audit.VerifierConfig{}, audit.WithCheckpoints(cps, s.keys))
+	s.Require().NotNil(report.Break)
+	s.Equal(audit.ChainBreakCheckpoint, report.Break.Kind)
+	s.Equal(int64(1), report.Break.Checkpoint)
+
+	_, err = c.Checkpoint(s.Ctx)
+	s.Require().Error(err)
+	s.True(errors.IsCode(err, audit.CodeChainBroken), "a broken chain is never signed")
+}
+
+func (s *VerifySuite) TestCheckpointCatchesTailDeletion() {
+	store := &sliceStore{events: s.events(20)}
+	cps := memory.NewCheckpointStore()
+	_, err := s.newCheckpointer(store, cps).Checkpoint(s.Ctx)
+	s.Require().NoError(err)
+
+	store.events = store.events[:17]
+	report := s.verify(store, audit.VerifierConfig{}, audit.WithCheckpoints(cps, s.keys))
+	s.Require().NotNil(report.Break)
+	s.Equal(audit.ChainBreakCheckpoint, report.Break.Kind)
+	s.Equal(int64(19), report.Break.Index)
+}
+
+func (s *VerifySuite) TestForgedCheckpointRejected() {
+	store := &sliceStore{events: s.events(5)}
+	cps := memory.NewCheckpointStore()
+	s.Require().NoError(cps.SaveCheckpoint(s.Ctx, audit.Checkpoint{
+		Sequence:  1,
+		EventID:   store.events[4].ID,
+		Head:      store.events[4].Hash,
+		Count:     5,
+		KeyID:     "k1",
+		Signature: []byte("forged"),
+	}))
+	report := s.verify(store, audit.VerifierConfig{}, audit.WithCheckpoints(cps, s.keys))
+	s.Require().NotNil(report.Break)
+	s.Equal(audit.ChainBreakCheckpoint, report.Break.Kind)
+	s.Contains(report.Break.Detail, "signature")
+
+	err := cps.SaveCheckpoint(s.Ctx, audit.Checkpoint{Sequence: 1})
+	s.True(errors.IsCode(err, errors.CodeConflict))
+}
+
+func (s *VerifySuite) TestCheckpointsSurvivePurge() {
+	store := memory.NewChainedStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.chain(store, start, 10)
+	cps := memory.NewCheckpointStore()
+	c := s.newCheckpointer(store, cps)
+	_, err := c.Checkpoint(s.Ctx)
+	s.Require().NoError(err)
+	s.chain(store, start.Add(time.Hour), 10)
+	_, err = c.Checkpoint(s.Ctx)
+	s.Require().NoError(err)
+
+	_, err = store.Purge(s.Ctx, start.Add(30*time.Minute))
+	s.Require().NoError(err)
+
+	report := s.verify(store, audit.VerifierConfig{AllowTruncated: true}, audit.WithCheckpoints(cps, s.keys))
+	s.True(report.Intact(), "%+v", report.Break)
+	s.Equal(1, report.Checkpoints)
+	s.Equal(int64(20), report.Events, "the surviving checkpoint pins absolute positions")
+
+	s.chain(store, start.Add(2*time.Hour), 3)
+	cp, err := audit.NewCheckpointer(store, cps, s.signer, s.keys, audit.CheckpointConfig{
+		Verify: audit.VerifierConfig{AllowTruncated: true},
+	})
+	s.Require().NoError(err)
+	third, err := cp.Checkpoint(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(int64(3), third.Sequence)
+	s.Equal(int64(23), third.Count)
+}
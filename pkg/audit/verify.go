@@ -0,0 +1,397 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ChainBreakKind classifies how a hash chain is broken.
+type ChainBreakKind string
+
+const (
+	// ChainBreakModified means an event's content no longer matches its Hash.
+	ChainBreakModified ChainBreakKind = "modified"
+
+	// ChainBreakGap means an event's PrevHash points at an event that is not
+	// in the store: events were deleted or inserted.
+	ChainBreakGap ChainBreakKind = "gap"
+
+	// ChainBreakReordered means the linked event exists nearby but in a
+	// different position.
+	ChainBreakReordered ChainBreakKind = "reordered"
+
+	// ChainBreakCheckpoint means the chain disagrees with a signed checkpoint,
+	// or a checkpoint itself is invalid. This catches rewrites that recompute
+	// every hash.
+	ChainBreakCheckpoint ChainBreakKind = "checkpoint"
+)
+
+// ChainBreak describes the first point where verification failed.
+type ChainBreak struct {
+	// Index is the event's position in the chain (0-based, store order).
+	Index int64
+
+	// EventID is the offending event's ID, when there is one.
+	EventID string
+
+	Kind   ChainBreakKind
+	Detail string
+
+	// Checkpoint is the Sequence of the checkpoint involved, or 0.
+	Checkpoint int64
+}
+
+// Err returns the break as an ErrChainBroken error.
+func (b *ChainBreak) Err() error {
+	return ErrChainBroken(int(b.Index), fmt.Sprintf("%s: %s", b.Kind, b.Detail))
+}
+
+// ChainReport is the result of a streaming chain verification.
+type ChainReport struct {
+	// Events is the number of chained events up to and including Head. For an
+	// incremental run it still counts from the start of the chain.
+	Events int64
+
+	// Head, HeadEventID and HeadTimestamp describe the last verified event.
+	Head          string
+	HeadEventID   string
+	HeadTimestamp time.Time
+
+	// Checkpoints is the number of signed checkpoints matched against events.
+	Checkpoints int
+
+	// Truncated is set when the chain does not start at genesis and
+	// VerifierConfig.AllowTruncated accepted it.
+	Truncated bool
+
+	// Break is the first failure, or nil when the chain is intact.
+	Break *ChainBreak
+}
+
+// Intact reports whether verification found no break.
+func (r ChainReport) Intact() bool {
+	return r.Break == nil
+}
+
+// VerifierConfig configures a ChainVerifier.
+type VerifierConfig struct {
+	// PageSize is the number of events read per Query.
+	PageSize int `env:"AUDIT_VERIFY_PAGE_SIZE" env-default:"500"`
+
+	// AllowTruncated accepts a chain whose first event does not link to
+	// genesis, as left behind by a retention Purge.
+	AllowTruncated bool `env:"AUDIT_VERIFY_ALLOW_TRUNCATED" env-default:"false"`
+
+	// ReorderWindow is how many events around a broken link are searched to
+	// tell a reordering from a gap.
+	ReorderWindow int `env:"AUDIT_VERIFY_REORDER_WINDOW" env-default:"1024"`
+}
+
+// VerifierOption configures a ChainVerifier.
+type VerifierOption func(*ChainVerifier)
+
+// WithCheckpoints also verifies the chain against signed checkpoints.
+func WithCheckpoints(store CheckpointStore, keys CheckpointKeys) VerifierOption {
+	return func(v *ChainVerifier) {
+		v.checkpoints = store
+		v.keys = keys
+	}
+}
+
+// ChainVerifier verifies the hash chain of a Store by streaming through
+// Query pages, so it runs in constant memory on large stores.
+type ChainVerifier struct {
+	store       Store
+	cfg         VerifierConfig
+	checkpoints CheckpointStore
+	keys        CheckpointKeys
+}
+
+// NewChainVerifier creates a verifier for store. The store's Query must
+// return events in chain order and support QueryFilter.Offset.
+func NewChainVerifier(store Store, cfg VerifierConfig, opts ...VerifierOption) *ChainVerifier {
+	if cfg.PageSize <= 0 {
+		cfg.PageSize = 500
+	}
+	if cfg.ReorderWindow <= 0 {
+		cfg.ReorderWindow = 1024
+	}
+	v := &ChainVerifier{store: store, cfg: cfg}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// VerifyChain verifies the whole chain. A broken chain is reported in
+// ChainReport.Break; the error is only for failures to read the stores.
+func (v *ChainVerifier) VerifyChain(ctx context.Context) (ChainReport, error) {
+	return v.verify(ctx, nil)
+}
+
+// chainWalk is the state of one verification pass.
+type chainWalk struct {
+	report ChainReport
+
+	// base is the absolute index of the first event in the store; it is only
+	// unknown for a truncated chain until a checkpoint pins it.
+	base      int64
+	baseKnown bool
+	pos       int64 // store position of the next event
+	first     time.Time
+
+	prev string
+
+	byEvent map[string]Checkpoint
+	matched map[int64]bool
+
+	// recent maps the hashes of the last ReorderWindow events to positions.
+	recent map[string]int64
+	ring   []string
+}
+
+// verify walks the chain. With from set it first tries to resume at the
+// checkpointed event and only verifies what follows; if that event is no
+// longer where the checkpoint says, it falls back to a full walk.
+func (v *ChainVerifier) verify(ctx context.Context, from *Checkpoint) (ChainReport, error) {
+	if v.store == nil {
+		return ChainReport{}, ErrInvalidArgument("audit store is required", nil)
+	}
+	if from != nil {
+		if v.checkpoints != nil {
+			if b := v.checkCheckpoint(*from); b != nil {
+				return ChainReport{Break: b}, nil
+			}
+		}
+		report, ok, err := v.resume(ctx, *from)
+		if err != nil || ok {
+			return report, err
+		}
+	}
+
+	w := v.newWalk(true)
+	if v.checkpoints != nil {
+		cps, err := v.checkpoints.ListCheckpoints(ctx)
+		if err != nil {
+			return ChainReport{}, ErrCheckpointFailed("failed to list checkpoints", err)
+		}
+		for i, cp := range cps {
+			if cp.Sequence != int64(i+1) {
+				return ChainReport{Break: &ChainBreak{
+					Index:      cp.Count - 1,
+					EventID:    cp.EventID,
+					Kind:       ChainBreakCheckpoint,
+					Detail:     fmt.Sprintf("checkpoint sequence %d follows %d", cp.Sequence, i),
+					Checkpoint: cp.Sequence,
+				}}, nil
+			}
+			if b := v.checkCheckpoint(cp); b != nil {
+				return ChainReport{Break: b}, nil
+			}
+			w.byEvent[cp.EventID] = cp
+		}
+	}
+	if err := v.walk(ctx, w, 0); err != nil {
+		return ChainReport{}, err
+	}
+	if w.report.Break == nil {
+		w.report.Break = v.unmatched(w)
+	}
+	return w.report, nil
+}
+
+// resume verifies from the checkpointed event onwards. ok is false when the
+// event is not at the checkpointed position (e.g. after a Purge).
+func (v *ChainVerifier) resume(ctx context.Context, from Checkpoint) (ChainReport, bool, error) {
+	if from.Count < 1 {
+		return ChainReport{}, false, nil
+	}
+	first, err := v.store.Query(ctx, QueryFilter{Offset: int(from.Count - 1), Limit: 1})
+	if err != nil {
+		return ChainReport{}, false, ErrQueryFailed("failed to read checkpointed event", err)
+	}
+	if len(first) != 1 || first[0].ID != from.EventID {
+		return ChainReport{}, false, nil
+	}
+
+	w := v.newWalk(false)
+	w.base, w.baseKnown = 0, true
+	w.pos = from.Count - 1
+	w.prev = first[0].PrevHash
+	w.byEvent[from.EventID] = from
+	if err := v.walk(ctx, w, int(from.Count-1)); err != nil {
+		return ChainReport{}, false, err
+	}
+	return w.report, true, nil
+}
+
+func (v *ChainVerifier) newWalk(fromGenesis bool) *chainWalk {
+	w := &chainWalk{
+		baseKnown: true,
+		byEvent:   make(map[string]Checkpoint),
+		matched:   make(map[int64]bool),
+		recent:    make(map[string]int64),
+	}
+	if fromGenesis {
+		w.prev = genesisPrevHash
+	}
+	return w
+}
+
+// walk streams events from offset and checks each one until a break.
+func (v *ChainVerifier) walk(ctx context.Context, w *chainWalk, offset int) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		page, err := v.store.Query(ctx, QueryFilter{Offset: offset, Limit: v.cfg.PageSize})
+		if err != nil {
+			return ErrQueryFailed("failed to read audit events", err)
+		}
+		for i, e := range page {
+			if b := v.check(w, e); b != nil {
+				if b.Kind == ChainBreakGap {
+					kind, err := v.classify(ctx, w, e, offset+i+1)
+					if err != nil {
+						return err
+					}
+					b.Kind = kind
+				}
+				w.report.Break = b
+				return nil
+			}
+		}
+		offset += len(page)
+		if len(page) < v.cfg.PageSize {
+			return nil
+		}
+	}
+}
+
+// check verifies one event and advances the walk.
+func (v *ChainVerifier) check(w *chainWalk, e Event) *ChainBreak {
+	index := w.base + w.pos
+	want, err := HashEvent(e)
+	if err != nil || e.Hash != want {
+		return &ChainBreak{Index: index, EventID: e.ID, Kind: ChainBreakModified, Detail: "hash does not match event content"}
+	}
+	if e.PrevHash != w.prev {
+		if w.pos == 0 && w.prev == genesisPrevHash && v.cfg.AllowTruncated {
+			// A purged chain starts mid-way; its absolute position is
+			// unknown until a checkpoint pins it.
+			w.report.Truncated = true
+			w.baseKnown = false
+			w.first = e.Timestamp
+		} else {
+			return &ChainBreak{Index: index, EventID: e.ID, Kind: ChainBreakGap, Detail: "prev_hash does not link to the preceding event"}
+		}
+	}
+
+	if cp, ok := w.byEvent[e.ID]; ok {
+		if e.Hash != cp.Head {
+			return &ChainBreak{Index: index, EventID: e.ID, Kind: ChainBreakCheckpoint, Checkpoint: cp.Sequence,
+				Detail: "event hash differs from the signed checkpoint head"}
+		}
+		if !w.baseKnown {
+			w.base = cp.Count - 1 - w.pos
+			w.baseKnown = true
+			index = w.base + w.pos
+		}
+		if cp.Count != index+1 {
+			return &ChainBreak{Index: index, EventID: e.ID, Kind: ChainBreakCheckpoint, Checkpoint: cp.Sequence,
+				Detail: fmt.Sprintf("checkpoint covers %d events but the chain has %d", cp.Count, index+1)}
+		}
+		w.matched[cp.Sequence] = true
+		w.report.Checkpoints++
+	}
+
+	w.remember(e.Hash, w.pos, v.cfg.ReorderWindow)
+	w.prev = e.Hash
+	w.pos++
+	w.report.Events = index + 1
+	w.report.Head = e.Hash
+	w.report.HeadEventID = e.ID
+	w.report.HeadTimestamp = e.Timestamp
+	return nil
+}
+
+// remember keeps the hashes of the last n events.
+func (w *chainWalk) remember(hash string, pos int64, n int) {
+	if len(w.ring) >= n {
+		delete(w.recent, w.ring[0])
+		w.ring = w.ring[1:]
+	}
+	w.ring = append(w.ring, hash)
+	w.recent[hash] = pos
+}
+
+// classify decides whether a broken link is a reordering: the linked event
+// was seen recently, or appears within ReorderWindow events ahead.
+func (v *ChainVerifier) classify(ctx context.Context, w *chainWalk, e Event, next int) (ChainBreakKind, error) {
+	if _, ok := w.recent[e.PrevHash]; ok {
+		return ChainBreakReordered, nil
+	}
+	if e.PrevHash == genesisPrevHash {
+		return ChainBreakReordered, nil
+	}
+	ahead, err := v.store.Query(ctx, QueryFilter{Offset: next, Limit: v.cfg.ReorderWindow})
+	if err != nil {
+		return "", ErrQueryFailed("failed to read audit events", err)
+	}
+	for _, a := range ahead {
+		if a.Hash == e.PrevHash {
+			return ChainBreakReordered, nil
+		}
+	}
+	return ChainBreakGap, nil
+}
+
+// checkCheckpoint verifies a checkpoint's signature.
+func (v *ChainVerifier) checkCheckpoint(cp Checkpoint) *ChainBreak {
+	ok, err := v.keys.verify(cp)
+	if err != nil || !ok {
+		detail := "invalid checkpoint signature"
+		if err != nil {
+			detail = fmt.Sprintf("%s: %v", detail, err)
+		}
+		return &ChainBreak{Index: cp.Count - 1, EventID: cp.EventID, Kind: ChainBreakCheckpoint, Detail: detail, Checkpoint: cp.Sequence}
+	}
+	return nil
+}
+
+// unmatched reports the first checkpoint whose event was not found.
+func (v *ChainVerifier) unmatched(w *chainWalk) *ChainBreak {
+	var missing *Checkpoint
+	for _, cp := range w.byEvent {
+		if w.matched[cp.Sequence] || w.purged(cp) {
+			continue
+		}
+		if missing == nil || cp.Sequence < missing.Sequence {
+			c := cp
+			missing = &c
+		}
+	}
+	if missing == nil {
+		return nil
+	}
+	return &ChainBreak{
+		Index:      missing.Count - 1,
+		EventID:    missing.EventID,
+		Kind:       ChainBreakCheckpoint,
+		Detail:     "checkpointed event is missing from the chain",
+		Checkpoint: missing.Sequence,
+	}
+}
+
+// purged reports whether a truncated chain legitimately lost cp's event:
+// it precedes the first remaining event.
+func (w *chainWalk) purged(cp Checkpoint) bool {
+	if !w.report.Truncated {
+		return false
+	}
+	if w.baseKnown {
+		return cp.Count <= w.base
+	}
+	return cp.HeadTimestamp.Before(w.first)
+}
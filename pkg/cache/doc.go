@@ -5,11 +5,24 @@ Supported backends:
   - Memory: In-memory cache for testing
   - Redis: Distributed cache (standalone or Cluster via Config.Cluster / Addrs)
   - Bloom: Local bloom filter wrapper
+  - Tiered: In-process LRU (L1) in front of a shared Cache (L2)
 
 NewFromConfig constructs drivers registered via RegisterDriver (import
 adapters/memory and/or adapters/redis). Extended APIs: Exists, MGet, MSet,
 Expire, GetTTL. InvalidatePrefix deletes keys by prefix on supporting backends.
 
+NewTieredCache broadcasts invalidations on a pkg/messaging topic after
+every write (Set, Delete, MSet, Expire, Incr, InvalidatePrefix). Each node
+consumes the topic with its own consumer group, so all nodes drop stale L1
+entries. Wrap it with NewInstrumentedCache to export L1/L2 hit
+ratios as the cache.l1.hit_ratio and cache.l2.hit_ratio gauges, labelled
+with TieredConfig.Name as cache.name.
+
+NewLoader adds read-through loading on top of any Cache: concurrent misses
+share one load (concurrency.Group), stale values are served during a grace
+window while one background refresh runs, and XFetch refreshes hot keys
+probabilistically before they expire.
+
 Redis Cluster: set Config.Cluster=true and Config.Addrs (seed nodes). DB is
 ignored in cluster mode; multi-key ops require same-slot keys.
 */
@@ -10,21 +10,85 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // InstrumentedCache wraps a Cache to add logging and tracing.
+// When the wrapped cache (or one it wraps) is a TierReporter such as
+// TieredCache, L1/L2 hit ratios are exported as the cache.l1.hit_ratio and
+// cache.l2.hit_ratio gauges, labelled with cache.name, and each lookup span
+// gets a cache.tier attribute. Close unregisters the gauges.
 type InstrumentedCache struct {
-	next   Cache
-	tracer trace.Tracer
+	next    Cache
+	tracer  trace.Tracer
+	tiers   TierReporter
+	metrics metric.Registration
 }
 
 // NewInstrumentedCache creates a new instrumented cache wrapper.
 func NewInstrumentedCache(next Cache) *InstrumentedCache {
-	return &InstrumentedCache{
+	c := &InstrumentedCache{
 		next:   next,
 		tracer: otel.Tracer("pkg/cache"),
 	}
+	if tr, ok := findTierReporter(next); ok {
+		c.tiers = tr
+		c.metrics = registerTierMetrics(tr)
+	}
+	return c
+}
+
+func findTierReporter(c Cache) (TierReporter, bool) {
+	for c != nil {
+		if tr, ok := c.(TierReporter); ok {
+			return tr, true
+		}
+		u, ok := c.(interface{ Unwrap() Cache })
+		if !ok || u.Unwrap() == c {
+			return nil, false
+		}
+		c = u.Unwrap()
+	}
+	return nil, false
+}
+
+// registerTierMetrics observes tr's hit ratios until the returned
+// registration is unregistered. It returns nil if the gauges are unavailable.
+func registerTierMetrics(tr TierReporter) metric.Registration {
+	meter := otel.Meter("pkg/cache")
+	l1, err := meter.Float64ObservableGauge("cache.l1.hit_ratio",
+		metric.WithDescription("Fraction of lookups served by the in-process L1"))
+	if err != nil {
+		logger.L().Warn("cache l1 hit ratio gauge unavailable", "error", err)
+		return nil
+	}
+	l2, err := meter.Float64ObservableGauge("cache.l2.hit_ratio",
+		metric.WithDescription("Fraction of L1 misses served by the shared L2"))
+	if err != nil {
+		logger.L().Warn("cache l2 hit ratio gauge unavailable", "error", err)
+		return nil
+	}
+	attrs := metric.WithAttributes(attribute.String("cache.name", tr.CacheName()))
+	reg, err := meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		s := tr.TierStats()
+		o.ObserveFloat64(l1, s.L1HitRatio(), attrs)
+		o.ObserveFloat64(l2, s.L2HitRatio(), attrs)
+		return nil
+	}, l1, l2)
+	if err != nil {
+		logger.L().Warn("cache tier metrics callback unavailable", "error", err)
+		return nil
+	}
+	return reg
+}
+
+// TierStats returns the wrapped cache's tier statistics, if it reports them.
+func (c *InstrumentedCache) TierStats() (TierStats, bool) {
+	if c.tiers == nil {
+		return TierStats{}, false
+	}
+	return c.tiers.TierStats(), true
 }
 
 // keyFingerprint returns a non-reversible short hash so logs never contain
@@ -213,5 +277,11 @@ func (c *InstrumentedCache) Unwrap() Cache {
 }
 
 func (c *InstrumentedCache) Close() error {
+	if c.metrics != nil {
+		if err := c.metrics.Unregister(); err != nil {
+			logger.L().Warn("cache tier metrics unregister failed", "error", err)
+		}
+		c.metrics = nil
+	}
 	return c.next.Close()
 }
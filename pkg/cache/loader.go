@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+)
+
+// LoadFunc computes the value for a key on a cache miss or refresh.
+type LoadFunc func(ctx context.Context) (interface{}, error)
+
+// LoaderConfig configures a Loader.
+type LoaderConfig struct {
+	// TTL is how long a loaded value is fresh. 0 means it never goes stale.
+	TTL time.Duration `env:"CACHE_LOADER_TTL" env-default:"5m"`
+
+	// Grace is how long after TTL a stale value is still served while one
+	// background refresh runs. The entry is dropped from the cache at TTL+Grace.
+	Grace time.Duration `env:"CACHE_LOADER_GRACE" env-default:"1m"`
+
+	// Beta tunes XFetch probabilistic early expiration: each read refreshes
+	// early with a probability that grows as expiry nears and with how long the
+	// last load took. 1 is the usual value; 0 disables early refresh.
+	Beta float64 `env:"CACHE_LOADER_BETA" env-default:"1"`
+
+	// RefreshTimeout bounds background refreshes.
+	RefreshTimeout time.Duration `env:"CACHE_LOADER_REFRESH_TIMEOUT" env-default:"30s"`
+
+	// LoadTimeout bounds loads on a miss. The load is shared by every caller
+	// waiting on the key, so it does not stop when one of them cancels.
+	LoadTimeout time.Duration `env:"CACHE_LOADER_LOAD_TIMEOUT" env-default:"30s"`
+}
+
+// DefaultLoaderConfig returns LoaderConfig with package defaults applied.
+func DefaultLoaderConfig() LoaderConfig {
+	return LoaderConfig{
+		TTL:            5 * time.Minute,
+		Grace:          time.Minute,
+		Beta:           1,
+		RefreshTimeout: 30 * time.Second,
+		LoadTimeout:    30 * time.Second,
+	}
+}
+
+// LoaderStats contains Loader counters.
+type LoaderStats struct {
+	Hits          uint64 // fresh values served
+	StaleServed   uint64 // stale values served within Grace
+	Loads         uint64 // LoadFunc calls (misses and refreshes)
+	Coalesced     uint64 // misses that waited on another caller's load
+	EarlyRefresh  uint64 // refreshes triggered by XFetch before TTL
+	RefreshErrors uint64 // failed background refreshes
+}
+
+// loaderEntry is what Loader stores in the Cache: the value plus the
+// metadata XFetch and stale-while-revalidate need, so any driver works.
+type loaderEntry struct {
+	Value      json.RawMessage `json:"v"`
+	SoftExpiry int64           `json:"e,omitempty"` // unix nanos; 0 = never stale
+	Delta      int64           `json:"d,omitempty"` // last load duration in nanos
+}
+
+// Loader is a read-through front for a Cache. Concurrent misses for a key are
+// coalesced with concurrency.Group so the backing store sees one load; stale
+// values are served during Grace while a single background refresh runs; and
+// XFetch spreads refreshes of hot keys out before they expire.
+//
+// Keys written through a Loader hold an envelope, so read them through the
+// Loader too. Delete on the underlying Cache invalidates as usual.
+type Loader struct {
+	cache Cache
+	cfg   LoaderConfig
+	group concurrency.Group
+
+	refreshing sync.Map // key -> struct{}: background refresh in flight
+	now        func() time.Time
+	rand       func() float64
+
+	hits, stale, loads, coalesced, early, refreshErrors atomic.Uint64
+}
+
+// NewLoader creates a Loader over c.
+func NewLoader(c Cache, cfg LoaderConfig) *Loader {
+	if cfg.TTL < 0 {
+		cfg.TTL = 0
+	}
+	if cfg.Grace < 0 {
+		cfg.Grace = 0
+	}
+	if cfg.Beta < 0 {
+		cfg.Beta = 0
+	}
+	if cfg.RefreshTimeout <= 0 {
+		cfg.RefreshTimeout = 30 * time.Second
+	}
+	if cfg.LoadTimeout <= 0 {
+		cfg.LoadTimeout = 30 * time.Second
+	}
+	return &Loader{
+		cache: c,
+		cfg:   cfg,
+		now:   time.Now,
+		rand:  rand.Float64,
+	}
+}
+
+// SetNowFunc overrides the clock (tests).
+func (l *Loader) SetNowFunc(fn func() time.Time) {
+	if fn != nil {
+		l.now = fn
+	}
+}
+
+// Get unmarshals the value for key into dest, calling load on a miss.
+//
+// A miss blocks until the (possibly shared) load finishes or ctx ends. The
+// load keeps ctx's values but not its cancellation, so one caller giving up
+// does not fail the others; LoadTimeout bounds it instead. Stale and early-expired values are returned
+// immediately while the refresh runs in the background. Cache read errors
+// other than not-found are logged and treated as misses.
+func (l *Loader) Get(ctx context.Context, key string, dest interface{}, load LoadFunc) error {
+	if load == nil {
+		return errors.InvalidArgument("load func is required", nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var ent loaderEntry
+	err := l.cache.Get(ctx, key, &ent)
+	if err != nil && !IsNotFound(err) {
+		logger.L().WarnContext(ctx, "cache loader read failed", "key_fp", keyFingerprint(key), "error", err)
+	}
+	if err == nil && ent.Value != nil {
+		now := l.now()
+		switch {
+		case ent.SoftExpiry == 0:
+			l.hits.Add(1)
+		case now.UnixNano() < ent.SoftExpiry:
+			l.hits.Add(1)
+			if l.expiresEarly(now, ent) {
+				l.early.Add(1)
+				l.refresh(ctx, key, load)
+			}
+		case now.UnixNano() < ent.SoftExpiry+int64(l.cfg.Grace):
+			l.stale.Add(1)
+			l.refresh(ctx, key, load)
+		default:
+			// Past TTL+Grace but the driver has not evicted it yet: reload.
+			return l.loadInto(ctx, key, dest, load)
+		}
+		return json.Unmarshal(ent.Value, dest)
+	}
+	return l.loadInto(ctx, key, dest, load)
+}
+
+// expiresEarly implements XFetch: refresh when
+// now - delta*beta*ln(rand) >= expiry.
+func (l *Loader) expiresEarly(now time.Time, ent loaderEntry) bool {
+	if l.cfg.Beta == 0 || ent.Delta <= 0 {
+		return false
+	}
+	r := l.rand()
+	if r <= 0 {
+		return true
+	}
+	gap := -float64(ent.Delta) * l.cfg.Beta * math.Log(r)
+	return float64(now.UnixNano())+gap >= float64(ent.SoftExpiry)
+}
+
+// loadResult carries a shared load back to a waiting caller.
+type loadResult struct {
+	v      interface{}
+	err    error
+	shared bool
+}
+
+func (l *Loader) loadInto(ctx context.Context, key string, dest interface{}, load LoadFunc) error {
+	done := make(chan loadResult, 1)
+	go func() {
+		v, err, shared := l.group.Do(key, func() (interface{}, error) {
+			// A load that finished between our miss and Do has stored a
+			// fresh value; don't load it again.
+			var ent loaderEntry
+			if err := l.cache.Get(ctx, key, &ent); err == nil && ent.Value != nil &&
+				(ent.SoftExpiry == 0 || l.now().UnixNano() < ent.SoftExpiry) {
+				return ent.Value, nil
+			}
+			lctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.cfg.LoadTimeout)
+			defer cancel()
+			return l.loadAndStore(lctx, key, load)
+		})
+		done <- loadResult{v: v, err: err, shared: shared}
+	}()
+
+	var res loadResult
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if res.shared {
+		l.coalesced.Add(1)
+	}
+	if res.err != nil {
+		return res.err
+	}
+	return json.Unmarshal(res.v.(json.RawMessage), dest)
+}
+
+// refresh starts one background reload of key unless one is already running.
+func (l *Loader) refresh(ctx context.Context, key string, load LoadFunc) {
+	if _, busy := l.refreshing.LoadOrStore(key, struct{}{}); busy {
+		return
+	}
+	go func() {
+		defer l.refreshing.Delete(key)
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), l.cfg.RefreshTimeout)
+		defer cancel()
+		_, err, _ := l.group.Do(key, func() (interface{}, error) {
+			return l.loadAndStore(rctx, key, load)
+		})
+		if err != nil {
+			l.refreshErrors.Add(1)
+			logger.L().WarnContext(rctx, "cache loader refresh failed", "key_fp", keyFingerprint(key), "error", err)
+		}
+	}()
+}
+
+// loadAndStore calls load and writes the result with its XFetch metadata.
+// A failed cache write is logged; the loaded value is still returned.
+func (l *Loader) loadAndStore(ctx context.Context, key string, load LoadFunc) (interface{}, error) {
+	l.loads.Add(1)
+	start := l.now()
+	v, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal loaded value")
+	}
+	done := l.now()
+	ent := loaderEntry{Value: raw, Delta: int64(done.Sub(start))}
+	var ttl time.Duration
+	if l.cfg.TTL > 0 {
+		ent.SoftExpiry = done.Add(l.cfg.TTL).UnixNano()
+		ttl = l.cfg.TTL + l.cfg.Grace
+	}
+	if err := l.cache.Set(ctx, key, ent, ttl); err != nil {
+		logger.L().WarnContext(ctx, "cache loader write failed", "key_fp", keyFingerprint(key), "error", err)
+	}
+	return json.RawMessage(raw), nil
+}
+
+// Stats returns a snapshot of the Loader counters.
+func (l *Loader) Stats() LoaderStats {
+	return LoaderStats{
+		Hits:          l.hits.Load(),
+		StaleServed:   l.stale.Load(),
+		Loads:         l.loads.Load(),
+		Coalesced:     l.coalesced.Load(),
+		EarlyRefresh:  l.early.Load(),
+		RefreshErrors: l.refreshErrors.Load(),
+	}
+}
@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache/adapters/memory"
+	cacheredis "github.com/chris-alexander-pop/go-hyperforge/pkg/cache/adapters/redis"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/test"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// fakeClock is a settable clock for Loader.SetNowFunc.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type LoaderSuite struct {
+	test.Suite
+	backend cache.Cache
+	clock   *fakeClock
+}
+
+func (s *LoaderSuite) SetupTest() {
+	s.Suite.SetupTest()
+	s.backend = memory.New()
+	s.clock = &fakeClock{now: time.Now()}
+}
+
+func (s *LoaderSuite) TearDownTest() {
+	_ = s.backend.Close()
+}
+
+func (s *LoaderSuite) newLoader(cfg cache.LoaderConfig) *cache.Loader {
+	l := cache.NewLoader(s.backend, cfg)
+	l.SetNowFunc(s.clock.Now)
+	return l
+}
+
+func (s *LoaderSuite) TestConcurrentMissesLoadOnce() {
+	l := s.newLoader(cache.LoaderConfig{TTL: time.Minute})
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (interface{}, error) {
+		loads.Add(1)
+		<-release
+		return "value", nil
+	}
+
+	const callers = 20
+	var wg sync.WaitGroup
+	results := make(chan string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var v string
+			if err := l.Get(s.Ctx, "hot", &v, load); err == nil {
+				results <- v
+			}
+		}()
+	}
+	// Late callers either join the in-flight load or read what it stored.
+	s.Eventually(func() bool { return loads.Load() == 1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	s.Equal(int32(1), loads.Load())
+	n := 0
+	for v := range results {
+		s.Equal("value", v)
+		n++
+	}
+	s.Equal(callers, n)
+	s.Equal(uint64(1), l.Stats().Loads)
+}
+
+func (s *LoaderSuite) TestServesStaleWithinGraceAndRefreshesOnce() {
+	l := s.newLoader(cache.LoaderConfig{TTL: time.Minute, Grace: time.Minute})
+	var version atomic.Int32
+	refreshed := make(chan struct{}, 4)
+	load := func(ctx context.Context) (interface{}, error) {
+		n := version.Add(1)
+		if n > 1 {
+			refreshed <- struct{}{}
+		}
+		return n, nil
+	}
+
+	var v int
+	s.Require().NoError(l.Get(s.Ctx, "k", &v, load))
+	s.Equal(1, v)
+
+	s.clock.Advance(90 * time.Second) // stale, inside grace
+	for i := 0; i < 5; i++ {
+		s.Require().NoError(l.Get(s.Ctx, "k", &v, load))
+		s.Equal(1, v, "stale value served immediately")
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(2 * time.Second):
+		s.FailNow("background refresh did not run")
+	}
+	s.Eventually(func() bool {
+		var got int
+		return l.Get(s.Ctx, "k", &got, load) == nil && got == 2
+	}, time.Second, time.Millisecond)
+	s.Equal(int32(2), version.Load(), "one refresh for many stale reads")
+	s.GreaterOrEqual(l.Stats().StaleServed, uint64(5))
+}
+
+func (s *LoaderSuite) TestPastGraceLoadsSynchronously() {
+	l := s.newLoader(cache.LoaderConfig{TTL: time.Minute, Grace: time.Second})
+	var version atomic.Int32
+	load := func(ctx context.Context) (interface{}, error) { return version.Add(1), nil }
+
+	var v int
+	s.Require().NoError(l.Get(s.Ctx, "k", &v, load))
+	s.clock.Advance(2 * time.Minute)
+	s.Require().NoError(l.Get(s.Ctx, "k", &v, load))
+	s.Equal(2, v)
+}
+
+func (s *LoaderSuite) TestXFetchRefreshesEarly() {
+	l := s.newLoader(cache.LoaderConfig{TTL: time.Hour, Beta: 1e9})
+	var version atomic.Int32
+	load := func(ctx context.Context) (interface{}, error) {
+		s.clock.Advance(time.Second) // recorded as the load duration
+		return version.Add(1), nil
+	}
+
+	var v int
+	s.Require().NoError(l.Get(s.Ctx, "k", &v, load))
+	s.Require().NoError(l.Get(s.Ctx, "k", &v, load))
+	s.Equal(1, v, "fresh value served while refreshing early")
+	s.Eventually(func() bool { return version.Load() == 2 }, time.Second, time.Millisecond)
+	s.Equal(uint64(1), l.Stats().EarlyRefresh)
+}
+
+func (s *LoaderSuite) TestXFetchDisabled() {
+	l := s.newLoader(cache.LoaderConfig{TTL: time.Hour, Beta: 0})
+	var version atomic.Int32
+	load := func(ctx context.Context) (interface{}, error) {
+		s.clock.Advance(time.Second)
+		return version.Add(1), nil
+	}
+	var v int
+	for i := 0; i < 10; i++ {
+		s.Require().NoError(l.Get(s.Ctx, "k", &v, load))
+	}
+	s.Equal(int32(1), version.Load())
+	s.Equal(uint64(9), l.Stats().Hits)
+}
+
+func (s *LoaderSuite) TestLoadErrorIsNotCached() {
+	l := s.newLoader(cache.LoaderConfig{TTL: time.Minute})
+	boom := errors.New("db down")
+	err := l.Get(s.Ctx, "k", new(string), func(ctx context.Context) (interface{}, error) { return nil, boom })
+	s.ErrorIs(err, boom)
+
+	ok, err := s.backend.Exists(s.Ctx, "k")
+	s.Require().NoError(err)
+	s.False(ok)
+
+	var v string
+	s.Require().NoError(l.Get(s.Ctx, "k", &v, func(ctx context.Context) (interface{}, error) { return "ok", nil }))
+	s.Equal("ok", v)
+}
+
+func (s *LoaderSuite) TestCancelledCallerDoesNotFailSharedLoad() {
+	l := s.newLoader(cache.LoaderConfig{TTL: time.Minute})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		select {
+		case <-release:
+			return "value", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	firstCtx, cancel := context.WithCancel(s.Ctx)
+	first := make(chan error, 1)
+	go func() { first <- l.Get(firstCtx, "k", new(string), load) }()
+	<-started
+
+	second := make(chan string, 1)
+	go func() {
+		var v string
+		if err := l.Get(s.Ctx, "k", &v, load); err == nil {
+			second <- v
+		}
+		close(second)
+	}()
+
+	// The load outlives the caller that started it.
+	cancel()
+	s.ErrorIs(<-first, context.Canceled)
+	close(release)
+	s.Equal("value", <-second)
+	s.Equal(uint64(1), l.Stats().Loads)
+}
+
+func TestLoaderSuite(t *testing.T) {
+	test.Run(t, new(LoaderSuite))
+}
+
+func TestLoaderWithRedisDriver(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	l := cache.NewLoader(cacheredis.NewWithClient(client), cache.DefaultLoaderConfig())
+	type user struct{ Name string }
+	var loads int
+	load := func(ctx context.Context) (interface{}, error) {
+		loads++
+		return user{Name: "ada"}, nil
+	}
+	for i := 0; i < 3; i++ {
+		var u user
+		if err := l.Get(context.Background(), "user:1", &u, load); err != nil {
+			t.Fatal(err)
+		}
+		if u.Name != "ada" {
+			t.Fatalf("name=%q", u.Name)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("loads=%d", loads)
+	}
+	if ttl := mr.TTL("user:1"); ttl != 6*time.Minute {
+		t.Fatalf("ttl=%v, want TTL+Grace", ttl)
+	}
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging"
+	msgmem "github.com/chris-alexander-pop/go-hyperforge/pkg/messaging/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/test"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestTieredCacheConformance(t *testing.T) {
+	c, err := cache.NewTieredCache(context.Background(), memory.New(), nil, cache.TieredConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	runCacheConformance(t, c)
+}
+
+// nopCloser shares one L2 between nodes without letting a node close it.
+type nopCloser struct{ cache.Cache }
+
+func (nopCloser) Close() error { return nil }
+
+func (c nopCloser) Unwrap() cache.Cache { return c.Cache }
+
+type TieredCacheSuite struct {
+	test.Suite
+	l2     cache.Cache
+	broker messaging.Broker
+	nodeA  *cache.TieredCache
+	nodeB  *cache.TieredCache
+}
+
+func (s *TieredCacheSuite) SetupTest() {
+	s.Suite.SetupTest()
+	s.l2 = memory.New()
+	// Each node consumes the topic with its own group, as separate
+	// processes would on a shared broker.
+	s.broker = msgmem.New(msgmem.Config{})
+	var err error
+	s.nodeA, err = cache.NewTieredCache(s.Ctx, nopCloser{s.l2}, s.broker, cache.TieredConfig{Name: "users", NodeID: "a", L1TTL: time.Hour})
+	s.Require().NoError(err)
+	s.nodeB, err = cache.NewTieredCache(s.Ctx, nopCloser{s.l2}, s.broker, cache.TieredConfig{Name: "sessions", NodeID: "b", L1TTL: time.Hour})
+	s.Require().NoError(err)
+}
+
+func (s *TieredCacheSuite) TearDownTest() {
+	_ = s.nodeA.Close()
+	_ = s.nodeB.Close()
+	_ = s.broker.Close()
+	_ = s.l2.Close()
+}
+
+func (s *TieredCacheSuite) get(c cache.Cache, key string) string {
+	var v string
+	s.Require().NoError(c.Get(s.Ctx, key, &v))
+	return v
+}
+
+// eventually waits for an invalidation to reach another node's consumer.
+func (s *TieredCacheSuite) eventually(cond func() bool) {
+	s.Eventually(cond, time.Second, 5*time.Millisecond)
+}
+
+func (s *TieredCacheSuite) TestSetOnOneNodeInvalidatesOthers() {
+	s.Require().NoError(s.nodeA.Set(s.Ctx, "user:1", "v1", time.Minute))
+	s.Equal("v1", s.get(s.nodeB, "user:1")) // fills B's L1
+
+	s.Require().NoError(s.nodeA.Set(s.Ctx, "user:1", "v2", time.Minute))
+	s.eventually(func() bool { return s.get(s.nodeB, "user:1") == "v2" })
+
+	s.Require().NoError(s.nodeB.Delete(s.Ctx, "user:1"))
+	s.eventually(func() bool {
+		return cache.IsNotFound(s.nodeA.Get(s.Ctx, "user:1", new(string)))
+	})
+}
+
+func (s *TieredCacheSuite) TestL1ServesWithoutL2() {
+	s.Require().NoError(s.nodeA.Set(s.Ctx, "k", "v", time.Minute))
+	// Remove from L2 directly: no invalidation is broadcast.
+	s.Require().NoError(s.l2.Delete(s.Ctx, "k"))
+	s.Equal("v", s.get(s.nodeA, "k"))
+
+	st := s.nodeA.TierStats()
+	s.Equal(uint64(1), st.L1Hits)
+	s.Equal(1.0, st.L1HitRatio())
+}
+
+func (s *TieredCacheSuite) TestInvalidatePrefixReachesEveryNode() {
+	s.Require().NoError(s.nodeA.Set(s.Ctx, "user:1", "a", time.Minute))
+	s.Require().NoError(s.nodeA.Set(s.Ctx, "user:2", "b", time.Minute))
+	s.Require().NoError(s.nodeA.Set(s.Ctx, "other:1", "c", time.Minute))
+	s.Equal("a", s.get(s.nodeB, "user:1"))
+	s.Equal("b", s.get(s.nodeB, "user:2"))
+
+	n, err := cache.InvalidatePrefix(s.Ctx, cache.NewInstrumentedCache(s.nodeA), "user:")
+	s.Require().NoError(err)
+	s.Equal(int64(2), n)
+
+	for _, node := range []*cache.TieredCache{s.nodeA, s.nodeB} {
+		s.eventually(func() bool {
+			ok, err := node.Exists(s.Ctx, "user:1")
+			return err == nil && !ok
+		})
+		s.Equal("c", s.get(node, "other:1"))
+	}
+	s.GreaterOrEqual(s.nodeB.TierStats().Invalidations, uint64(1))
+}
+
+func (s *TieredCacheSuite) TestHitRatios() {
+	s.Require().NoError(s.l2.Set(s.Ctx, "k", "v", time.Minute))
+	s.get(s.nodeA, "k") // L1 miss, L2 hit
+	s.get(s.nodeA, "k") // L1 hit
+	_ = s.nodeA.Get(s.Ctx, "missing", new(string))
+
+	st := s.nodeA.TierStats()
+	s.Equal(uint64(1), st.L1Hits)
+	s.Equal(uint64(2), st.L1Misses)
+	s.Equal(uint64(1), st.L2Hits)
+	s.Equal(uint64(1), st.L2Misses)
+	s.InDelta(1.0/3.0, st.L1HitRatio(), 1e-9)
+	s.InDelta(0.5, st.L2HitRatio(), 1e-9)
+}
+
+// hitRatios collects the gauges by metric name and cache.name attribute.
+func (s *TieredCacheSuite) hitRatios(reader *sdkmetric.ManualReader) map[string]float64 {
+	var rm metricdata.ResourceMetrics
+	s.Require().NoError(reader.Collect(s.Ctx, &rm))
+	got := map[string]float64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			g, ok := m.Data.(metricdata.Gauge[float64])
+			if !ok {
+				continue
+			}
+			for _, dp := range g.DataPoints {
+				name, _ := dp.Attributes.Value("cache.name")
+				got[m.Name+"/"+name.AsString()] = dp.Value
+			}
+		}
+	}
+	return got
+}
+
+func (s *TieredCacheSuite) TestInstrumentedExportsHitRatios() {
+	reader := sdkmetric.NewManualReader()
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	defer otel.SetMeterProvider(prev)
+
+	a := cache.NewInstrumentedCache(s.nodeA)
+	b := cache.NewInstrumentedCache(s.nodeB)
+	s.Require().NoError(a.Set(s.Ctx, "k", "v", time.Minute))
+	s.get(a, "k")
+	_ = b.Get(s.Ctx, "missing", new(string))
+
+	st, ok := a.TierStats()
+	s.Require().True(ok)
+	s.Equal(uint64(1), st.L1Hits)
+
+	got := s.hitRatios(reader)
+	s.Equal(1.0, got["cache.l1.hit_ratio/users"])
+	s.Contains(got, "cache.l2.hit_ratio/users")
+	s.Equal(0.0, got["cache.l1.hit_ratio/sessions"])
+
+	// Closing a cache unregisters its series.
+	s.Require().NoError(b.Close())
+	got = s.hitRatios(reader)
+	s.Contains(got, "cache.l1.hit_ratio/users")
+	s.NotContains(got, "cache.l1.hit_ratio/sessions")
+}
+
+func TestTieredCacheSuite(t *testing.T) {
+	test.Run(t, new(TieredCacheSuite))
+}
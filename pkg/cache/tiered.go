@@ -0,0 +1,418 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/datastructures/lru"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Ensure TieredCache implements Cache and PrefixDeleter at compile time.
+var (
+	_ Cache         = (*TieredCache)(nil)
+	_ PrefixDeleter = (*TieredCache)(nil)
+	_ TierReporter  = (*TieredCache)(nil)
+)
+
+// TopicCacheInvalidation is the default messaging topic for L1 invalidations.
+const TopicCacheInvalidation = "cache.invalidation"
+
+// InvalidationPayload tells other nodes which L1 entries to drop. It is sent
+// JSON-encoded as the message payload.
+type InvalidationPayload struct {
+	Node   string   `json:"node"`
+	Keys   []string `json:"keys,omitempty"`
+	Prefix string   `json:"prefix,omitempty"`
+}
+
+// TieredConfig configures a TieredCache.
+type TieredConfig struct {
+	// Name labels this cache's hit-ratio metrics (the cache.name attribute).
+	Name string `env:"CACHE_NAME" env-default:"default"`
+
+	// L1Capacity is the maximum number of entries kept in process.
+	L1Capacity int `env:"CACHE_L1_CAPACITY" env-default:"10000"`
+
+	// L1TTL caps how long an entry lives in L1. It bounds staleness when an
+	// invalidation is lost, and when an entry filled from L2 expires there first.
+	L1TTL time.Duration `env:"CACHE_L1_TTL" env-default:"1m"`
+
+	// Topic is the messaging topic invalidations are broadcast on.
+	Topic string `env:"CACHE_INVALIDATION_TOPIC" env-default:"cache.invalidation"`
+
+	// NodeID identifies this process in invalidations and names its
+	// consumer group, so every node receives every invalidation. Set it to
+	// something stable (e.g. the pod name) on brokers with durable groups;
+	// defaults to a UUID.
+	NodeID string `env:"CACHE_NODE_ID"`
+}
+
+// TierStats are TieredCache counters. Get and Exists count toward hit ratios.
+type TierStats struct {
+	L1Hits             uint64
+	L1Misses           uint64
+	L2Hits             uint64
+	L2Misses           uint64
+	Invalidations      uint64 // L1 invalidations applied, local and remote
+	InvalidationErrors uint64 // broadcasts that failed to publish
+}
+
+// L1HitRatio is L1 hits over all lookups, or 0 before the first lookup.
+func (s TierStats) L1HitRatio() float64 {
+	return ratio(s.L1Hits, s.L1Hits+s.L1Misses)
+}
+
+// L2HitRatio is L2 hits over lookups that missed L1, or 0 if none did.
+func (s TierStats) L2HitRatio() float64 {
+	return ratio(s.L2Hits, s.L2Hits+s.L2Misses)
+}
+
+func ratio(n, d uint64) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// TierReporter is implemented by caches that report per-tier statistics.
+// NewInstrumentedCache exports them as metrics labelled with CacheName.
+type TierReporter interface {
+	TierStats() TierStats
+	CacheName() string
+}
+
+type l1Entry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// TieredCache layers an in-process LRU (L1) in front of a shared Cache (L2).
+//
+// Writes go to L2 first, then L1 is updated or dropped locally and an
+// invalidation is published on a messaging topic that every node consumes
+// with its own group, so every other node drops the same L1 entries. Broadcast failures are logged and counted; L1TTL bounds
+// how long other nodes may serve the stale value. MGet, GetTTL and Incr go
+// straight to L2.
+type TieredCache struct {
+	l1       *lru.Cache[string, l1Entry]
+	l2       Cache
+	producer messaging.Producer
+	consumer messaging.Consumer
+	cancel   context.CancelFunc
+	done     chan struct{}
+	name     string
+	node     string
+	ttl      time.Duration
+
+	// epoch increments on every invalidation. A Get only fills L1 when no
+	// invalidation ran while it was reading L2, so a concurrent write on
+	// another node cannot be overwritten by the older value.
+	epoch atomic.Uint64
+
+	l1Hits, l1Misses, l2Hits, l2Misses atomic.Uint64
+	invalidations, invalidationErrors  atomic.Uint64
+}
+
+// NewTieredCache wraps l2 with an L1 and consumes invalidations from broker
+// until Close (or ctx is cancelled). If broker is nil, invalidations stay
+// local (single-node use).
+func NewTieredCache(ctx context.Context, l2 Cache, broker messaging.Broker, cfg TieredConfig) (*TieredCache, error) {
+	if l2 == nil {
+		return nil, errors.InvalidArgument("l2 cache is required", nil)
+	}
+	if cfg.L1Capacity <= 0 {
+		cfg.L1Capacity = 10000
+	}
+	if cfg.L1TTL <= 0 {
+		cfg.L1TTL = time.Minute
+	}
+	if cfg.Topic == "" {
+		cfg.Topic = TopicCacheInvalidation
+	}
+	if cfg.NodeID == "" {
+		cfg.NodeID = uuid.NewString()
+	}
+	if cfg.Name == "" {
+		cfg.Name = "default"
+	}
+	c := &TieredCache{
+		l1:   lru.New[string, l1Entry](cfg.L1Capacity),
+		l2:   l2,
+		name: cfg.Name,
+		node: cfg.NodeID,
+		ttl:  cfg.L1TTL,
+	}
+	if broker == nil {
+		return c, nil
+	}
+
+	producer, err := broker.Producer(cfg.Topic)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cache invalidation producer")
+	}
+	consumer, err := broker.Consumer(cfg.Topic, "cache-l1-"+cfg.NodeID)
+	if err != nil {
+		_ = producer.Close()
+		return nil, errors.Wrap(err, "failed to subscribe to cache invalidations")
+	}
+	c.producer, c.consumer = producer, consumer
+
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		if err := consumer.Consume(ctx, c.handleInvalidation); err != nil && ctx.Err() == nil {
+			logger.L().ErrorContext(ctx, "cache invalidation consumer stopped", "error", err)
+		}
+	}()
+	return c, nil
+}
+
+func (c *TieredCache) handleInvalidation(ctx context.Context, msg *messaging.Message) error {
+	var p InvalidationPayload
+	if err := json.Unmarshal(msg.Payload, &p); err != nil {
+		return errors.InvalidArgument("invalid cache invalidation payload", err)
+	}
+	if p.Node == c.node {
+		return nil
+	}
+	if p.Prefix != "" {
+		c.dropPrefix(p.Prefix)
+	}
+	c.drop(p.Keys...)
+	return nil
+}
+
+func (c *TieredCache) drop(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	c.epoch.Add(1)
+	for _, k := range keys {
+		c.l1.Delete(k)
+	}
+	c.invalidations.Add(1)
+}
+
+func (c *TieredCache) dropPrefix(prefix string) {
+	c.epoch.Add(1)
+	for _, k := range c.l1.Keys() {
+		if strings.HasPrefix(k, prefix) {
+			c.l1.Delete(k)
+		}
+	}
+	c.invalidations.Add(1)
+}
+
+// broadcast tells other nodes to drop keys or prefix from their L1.
+func (c *TieredCache) broadcast(ctx context.Context, keys []string, prefix string) {
+	if c.producer == nil {
+		return
+	}
+	data, err := json.Marshal(InvalidationPayload{Node: c.node, Keys: keys, Prefix: prefix})
+	if err == nil {
+		err = c.producer.Publish(ctx, &messaging.Message{Payload: data})
+	}
+	if err != nil {
+		c.invalidationErrors.Add(1)
+		logger.L().WarnContext(ctx, "cache invalidation broadcast failed",
+			"keys", len(keys), "prefix_fp", keyFingerprint(prefix), "error", err)
+	}
+}
+
+func (c *TieredCache) fill(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 || ttl > c.ttl {
+		ttl = c.ttl
+	}
+	c.l1.Set(key, l1Entry{value: value, expiresAt: time.Now().Add(ttl)})
+}
+
+func (c *TieredCache) lookup(key string) ([]byte, bool) {
+	ent, ok := c.l1.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(ent.expiresAt) {
+		c.l1.Delete(key)
+		return nil, false
+	}
+	return ent.value, true
+}
+
+// recordTier annotates the caller's span (e.g. InstrumentedCache's) with the
+// tier that served a lookup.
+func recordTier(ctx context.Context, tier string) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("cache.tier", tier))
+}
+
+// Get serves from L1 when possible, otherwise reads L2 and fills L1.
+func (c *TieredCache) Get(ctx context.Context, key string, dest interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if raw, ok := c.lookup(key); ok {
+		c.l1Hits.Add(1)
+		recordTier(ctx, "l1")
+		return json.Unmarshal(raw, dest)
+	}
+	c.l1Misses.Add(1)
+
+	epoch := c.epoch.Load()
+	var raw json.RawMessage
+	if err := c.l2.Get(ctx, key, &raw); err != nil {
+		if IsNotFound(err) {
+			c.l2Misses.Add(1)
+			recordTier(ctx, "miss")
+		}
+		return err
+	}
+	c.l2Hits.Add(1)
+	recordTier(ctx, "l2")
+	if c.epoch.Load() == epoch {
+		c.fill(key, raw, 0)
+	}
+	return json.Unmarshal(raw, dest)
+}
+
+// Set writes L2, refreshes the local L1 entry and broadcasts an invalidation.
+func (c *TieredCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal")
+	}
+	if err := c.l2.Set(ctx, key, json.RawMessage(data), ttl); err != nil {
+		return err
+	}
+	c.drop(key)
+	c.fill(key, data, ttl)
+	c.broadcast(ctx, []string{key}, "")
+	return nil
+}
+
+// Delete removes key from L2 and every node's L1.
+func (c *TieredCache) Delete(ctx context.Context, key string) error {
+	if err := c.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+	c.drop(key)
+	c.broadcast(ctx, []string{key}, "")
+	return nil
+}
+
+// Exists checks L1, then L2.
+func (c *TieredCache) Exists(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if _, ok := c.lookup(key); ok {
+		c.l1Hits.Add(1)
+		recordTier(ctx, "l1")
+		return true, nil
+	}
+	c.l1Misses.Add(1)
+	ok, err := c.l2.Exists(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		c.l2Hits.Add(1)
+		recordTier(ctx, "l2")
+	} else {
+		c.l2Misses.Add(1)
+		recordTier(ctx, "miss")
+	}
+	return ok, nil
+}
+
+// MGet reads from L2.
+func (c *TieredCache) MGet(ctx context.Context, keys []string, dest interface{}) error {
+	return c.l2.MGet(ctx, keys, dest)
+}
+
+// MSet writes L2 and invalidates the keys on every node.
+func (c *TieredCache) MSet(ctx context.Context, items map[string]interface{}, ttl time.Duration) error {
+	if err := c.l2.MSet(ctx, items, ttl); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	c.drop(keys...)
+	c.broadcast(ctx, keys, "")
+	return nil
+}
+
+// Expire changes the L2 TTL and invalidates the key on every node.
+func (c *TieredCache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if err := c.l2.Expire(ctx, key, ttl); err != nil {
+		return err
+	}
+	c.drop(key)
+	c.broadcast(ctx, []string{key}, "")
+	return nil
+}
+
+// GetTTL reads the L2 TTL.
+func (c *TieredCache) GetTTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.l2.GetTTL(ctx, key)
+}
+
+// Incr increments in L2 and invalidates the key on every node.
+func (c *TieredCache) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	n, err := c.l2.Incr(ctx, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	c.drop(key)
+	c.broadcast(ctx, []string{key}, "")
+	return n, nil
+}
+
+// DeletePrefix deletes prefix from L2 (via InvalidatePrefix) and every node's L1.
+func (c *TieredCache) DeletePrefix(ctx context.Context, prefix string) (int64, error) {
+	n, err := InvalidatePrefix(ctx, c.l2, prefix)
+	if err != nil {
+		return 0, err
+	}
+	c.dropPrefix(prefix)
+	c.broadcast(ctx, nil, prefix)
+	return n, nil
+}
+
+// TierStats returns a snapshot of the tier counters.
+func (c *TieredCache) TierStats() TierStats {
+	return TierStats{
+		L1Hits:             c.l1Hits.Load(),
+		L1Misses:           c.l1Misses.Load(),
+		L2Hits:             c.l2Hits.Load(),
+		L2Misses:           c.l2Misses.Load(),
+		Invalidations:      c.invalidations.Load(),
+		InvalidationErrors: c.invalidationErrors.Load(),
+	}
+}
+
+// CacheName returns TieredConfig.Name.
+func (c *TieredCache) CacheName() string { return c.name }
+
+// Close stops consuming invalidations and closes L2. The broker itself is
+// left open for its owner to close.
+func (c *TieredCache) Close() error {
+	if c.consumer != nil {
+		c.cancel()
+		_ = c.consumer.Close()
+		<-c.done
+		_ = c.producer.Close()
+	}
+	c.l1.Clear()
+	return c.l2.Close()
+}
@@ -3,6 +3,7 @@ package ops
 import (
 	"context"
 
+	dbsql "github.com/chris-alexander-pop/go-hyperforge/pkg/database/sql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -30,7 +31,11 @@ func ExecuteRaw(ctx context.Context, db *gorm.DB, sql string, values ...interfac
 	return db.WithContext(ctx).Exec(sql, values...).Error
 }
 
-// Transaction executes a function within a transaction.
-func Transaction(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
-	return db.WithContext(ctx).Transaction(fn)
+// Transaction executes a function within a transaction on db's primary
+// (sql.Primary), never a read replica. The transaction's context is also
+// pinned with sql.WithPrimary, so connections taken from a sql.Replicated
+// with tx.Statement.Context stay on the primary too.
+func Transaction(ctx context.Context, db dbsql.SQL, fn func(tx *gorm.DB) error) error {
+	ctx = dbsql.WithPrimary(ctx)
+	return dbsql.Primary(ctx, db).WithContext(ctx).Transaction(fn)
 }
@@ -11,6 +11,19 @@ This package supports multiple SQL backends through a common interface:
 Single-instance adapters implement GetShard as a stub (ignore key / return primary).
 For real multi-shard routing, use NewSharded with a sharding.Strategy.
 Optional retries and circuit breaking: NewResilientSQL (Execute is the main entry).
+For read replicas, NewReplicated routes reads hinted with PreferReplica across
+healthy replicas and everything else to the primary:
+
+	db, err := sql.NewReplicated(primary, map[string]sql.SQL{"r1": r1, "r2": r2}, sql.ReplicaConfig{})
+	ctx = sql.WithSession(ctx)     // read-your-writes for this request
+	db.Get(sql.PreferReplica(ctx)) // replica, unless ctx just wrote
+
+ops.Transaction always runs on the primary. Reads shortly after the session
+wrote stay on the primary too; raw statements count as writes unless they
+start with a read-only keyword such as SELECT. Replicas lagging more than MaxLag leave the rotation until a later
+health check passes.
+
+Versioned schema migrations with locking, dry-run plans and drift detection: sql/migrate.
 
 Basic usage:
 
@@ -0,0 +1,37 @@
+/*
+Package migrate applies versioned schema migrations to the databases behind
+pkg/database/sql: PostgreSQL, MySQL, SQLite and SQL Server.
+
+Migrations are ordered by Version and written as SQL, usually embedded with
+FromFS, or as Go functions receiving the *gorm.DB. Each runs in its own
+transaction together with its row in the schema_migrations table, unless it
+sets NoTransaction. MySQL commits DDL implicitly, so keep one DDL change per
+migration there.
+
+	//go:embed migrations/*.sql
+	var files embed.FS
+
+	migrations, err := migrate.FromFS(files, "migrations")
+	m, err := migrate.New(db, migrate.Config{Locker: redisLocker}, migrations...)
+	plan, err := m.Up(ctx)
+
+With a distlock.Locker only one replica migrates; the others wait for the
+lock and then find nothing pending. DryRun returns the Plan without touching
+the schema, and Plan.String renders it as SQL for review. Applied migrations
+carry a checksum: editing or deleting one after it ran is reported as drift
+and blocks further runs until fixed or AllowDrift is set. SQL migrations are
+checksummed by content; Go migrations by their Revision, which must be
+changed by hand whenever the Go code changes.
+
+Zero-downtime changes split into two releases. Expand adds the new column
+and backfills it in batches; the application is then deployed to write both
+columns and read the new one; Contract, shipped once nothing reads the old
+column, drops it:
+
+	migrate.Expand(20240101000000, "add_display_name", migrate.ExpandColumn{
+		Table: "users", Column: "display_name", Definition: "VARCHAR(255)",
+		Backfill: "full_name",
+	})
+	migrate.Contract(20240201000000, "drop_full_name", "users", "full_name")
+*/
+package migrate
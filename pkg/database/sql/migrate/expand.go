@@ -0,0 +1,164 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultBackfillBatch is the number of rows Expand updates per statement.
+const DefaultBackfillBatch = 1000
+
+// ExpandColumn describes a column added by Expand.
+type ExpandColumn struct {
+	Table string
+
+	// Column is created with Definition, which must allow NULL so existing
+	// writers keep working, e.g. "VARCHAR(255)" or "BIGINT".
+	Column     string
+	Definition string
+
+	// Backfill is a SQL expression over the existing row, such as an old
+	// column name, computing the new column's value. Empty skips the backfill.
+	Backfill string
+
+	// Key is the table's ordered unique key used to walk it in batches
+	// (default "id").
+	Key string
+
+	// BatchSize is the number of rows updated per statement
+	// (default DefaultBackfillBatch).
+	BatchSize int
+}
+
+// Expand returns a migration adding c.Column and backfilling it in batches,
+// each committed on its own so no long-running lock is held. It runs
+// outside a transaction and is safe to re-run. Down drops the column.
+func Expand(version int64, name string, c ExpandColumn) Migration {
+	if c.Key == "" {
+		c.Key = "id"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultBackfillBatch
+	}
+	return Migration{
+		Version:       version,
+		Name:          name,
+		NoTransaction: true,
+		Revision:      fmt.Sprintf("expand %s.%s %s backfill=%q key=%s", c.Table, c.Column, c.Definition, c.Backfill, c.Key),
+		Up: func(ctx context.Context, db *gorm.DB) error {
+			if err := AddColumn(c.Table, c.Column, c.Definition)(ctx, db); err != nil {
+				return err
+			}
+			if c.Backfill == "" {
+				return nil
+			}
+			return Backfill(c.Table, c.Column, c.Backfill, c.Key, c.BatchSize)(ctx, db)
+		},
+		Down: DropColumns(c.Table, c.Column),
+	}
+}
+
+// Contract returns an irreversible migration dropping columns once no
+// deployed code reads them.
+func Contract(version int64, name, table string, columns ...string) Migration {
+	return Migration{
+		Version:  version,
+		Name:     name,
+		Revision: fmt.Sprintf("contract %s %s", table, strings.Join(columns, ",")),
+		Up:       DropColumns(table, columns...),
+	}
+}
+
+// AddColumn adds a column unless it already exists. definition is passed
+// through verbatim, so it may contain ? (e.g. in a DEFAULT).
+func AddColumn(table, column, definition string) Func {
+	return func(ctx context.Context, db *gorm.DB) error {
+		if db.Migrator().HasColumn(table, column) {
+			return nil
+		}
+		// "ADD <column> <type>" without COLUMN is accepted by every supported
+		// dialect, including SQL Server.
+		err := db.Exec("ALTER TABLE ? ADD ? ?",
+			clause.Table{Name: table}, clause.Column{Name: column}, clause.Expr{SQL: definition}).Error
+		if err != nil {
+			return errors.Internal(fmt.Sprintf("failed to add column %s.%s", table, column), err)
+		}
+		return nil
+	}
+}
+
+// DropColumns drops the columns that exist.
+func DropColumns(table string, columns ...string) Func {
+	return func(ctx context.Context, db *gorm.DB) error {
+		for _, column := range columns {
+			if !db.Migrator().HasColumn(table, column) {
+				continue
+			}
+			var err error
+			if db.Dialector.Name() == "sqlite" {
+				// The sqlite migrator rebuilds the table from a model schema,
+				// which a bare table name lacks; SQLite 3.35+ drops natively.
+				err = db.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: column}).Error
+			} else {
+				err = db.Migrator().DropColumn(table, column)
+			}
+			if err != nil {
+				return errors.Internal(fmt.Sprintf("failed to drop column %s.%s", table, column), err)
+			}
+		}
+		return nil
+	}
+}
+
+// RenameColumn renames a column in place. It is not zero-downtime: code
+// reading the old name fails from the moment it runs. Prefer Expand and
+// Contract on live tables.
+func RenameColumn(table, from, to string) Func {
+	return func(ctx context.Context, db *gorm.DB) error {
+		if err := db.Migrator().RenameColumn(table, from, to); err != nil {
+			return errors.Internal(fmt.Sprintf("failed to rename column %s.%s", table, from), err)
+		}
+		return nil
+	}
+}
+
+// Backfill sets column to expr on rows where it is NULL, batchSize rows at
+// a time in key order. Rows whose expr is NULL are passed over rather than
+// revisited. expr is passed through verbatim, so it may contain ? (such as
+// the PostgreSQL jsonb operator).
+func Backfill(table, column, expr, key string, batchSize int) Func {
+	return func(ctx context.Context, db *gorm.DB) error {
+		var last interface{}
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			q := db.Table(table).Where("? IS NULL", clause.Column{Name: column})
+			if last != nil {
+				q = q.Where("? > ?", clause.Column{Name: key}, last)
+			}
+			var keys []interface{}
+			err := q.Order(clause.OrderByColumn{Column: clause.Column{Name: key}}).
+				Limit(batchSize).Pluck(key, &keys).Error
+			if err != nil {
+				return errors.Internal("failed to select backfill batch from "+table, err)
+			}
+			if len(keys) == 0 {
+				return nil
+			}
+			// A clause.Expr without vars is written as is, so ? in expr is
+			// not taken for a placeholder.
+			err = db.Exec("UPDATE ? SET ? = ? WHERE ? IN ?",
+				clause.Table{Name: table}, clause.Column{Name: column}, clause.Expr{SQL: expr}, clause.Column{Name: key}, keys).Error
+			if err != nil {
+				return errors.Internal("failed to backfill "+table+"."+column, err)
+			}
+			last = keys[len(keys)-1]
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency/distlock"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/sql"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// DefaultTable records applied migrations.
+const DefaultTable = "schema_migrations"
+
+// Latest targets the newest known migration in UpTo.
+const Latest int64 = 1<<63 - 1
+
+// Func is a migration step written in Go. It runs inside the migration's
+// transaction unless the migration sets NoTransaction.
+type Func func(ctx context.Context, db *gorm.DB) error
+
+// Migration is one versioned schema change. Each direction is either SQL
+// or a Go Func; a migration without a down step cannot be rolled back.
+type Migration struct {
+	// Version orders migrations; timestamps such as 20240131120000 keep
+	// branches from colliding.
+	Version int64
+	Name    string
+
+	UpSQL   string
+	DownSQL string
+	Up      Func
+	Down    Func
+
+	// Revision identifies what a Go Up step does, since Go code cannot be
+	// checksummed: change it whenever Up changes so the drift check notices.
+	// It is required for Go migrations and ignored for SQL ones.
+	Revision string
+
+	// NoTransaction runs the steps outside a transaction. A failure part way
+	// leaves the migration unrecorded, so its steps should be idempotent.
+	NoTransaction bool
+}
+
+// Checksum identifies the migration's up step for drift detection: the
+// normalised SQL for SQL migrations, the name and Revision for Go ones.
+func (m Migration) Checksum() string {
+	src := "go:" + m.Name + ":" + m.Revision
+	if m.UpSQL != "" {
+		src = strings.TrimSpace(strings.ReplaceAll(m.UpSQL, "\r\n", "\n"))
+	}
+	sum := sha256.Sum256([]byte(src))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m Migration) label() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+// Record is a row in the migrations table.
+type Record struct {
+	Version    int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name       string    `gorm:"size:255;not null"`
+	Checksum   string    `gorm:"size:64;not null"`
+	AppliedAt  time.Time `gorm:"not null"`
+	DurationMS int64     `gorm:"not null"`
+}
+
+// Config configures a Migrator.
+type Config struct {
+	// Table records applied migrations (default DefaultTable).
+	Table string
+
+	// Locker serialises migrations across replicas. The lock is held for
+	// the whole run and extended in the background; a replica that waited
+	// re-reads the table and finds nothing left to do. Without a Locker
+	// concurrent runs are not coordinated.
+	Locker distlock.Locker
+
+	// Lock controls acquisition (default TTL 1m, retried every second for
+	// up to five minutes).
+	Lock distlock.LockConfig
+
+	// DryRun plans without executing: Up and Down return the steps they
+	// would run.
+	DryRun bool
+
+	// AllowOutOfOrder applies pending migrations older than the newest
+	// applied one instead of failing.
+	AllowOutOfOrder bool
+
+	// AllowDrift runs even when applied migrations no longer match their
+	// source.
+	AllowDrift bool
+}
+
+// Direction is the way a Step runs.
+type Direction string
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// Step is one migration in a Plan.
+type Step struct {
+	Version       int64
+	Name          string
+	Direction     Direction
+	Statements    []string // SQL statements; empty for Go steps
+	Go            bool
+	NoTransaction bool
+}
+
+// Plan is the ordered list of steps a run executes, or would execute when
+// DryRun is set.
+type Plan struct {
+	Steps  []Step
+	DryRun bool
+}
+
+// String renders the plan as an annotated SQL script.
+func (p *Plan) String() string {
+	if len(p.Steps) == 0 {
+		return "-- nothing to migrate\n"
+	}
+	var b strings.Builder
+	for _, s := range p.Steps {
+		fmt.Fprintf(&b, "-- %d_%s (%s", s.Version, s.Name, s.Direction)
+		if s.Go {
+			b.WriteString(", go")
+		}
+		if s.NoTransaction {
+			b.WriteString(", no transaction")
+		}
+		b.WriteString(")\n")
+		for _, stmt := range s.Statements {
+			b.WriteString(stmt)
+			b.WriteString(";\n")
+		}
+	}
+	return b.String()
+}
+
+// Status describes a migration known to the source, the table, or both.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+
+	// Drifted is set when the applied checksum no longer matches the source.
+	Drifted bool
+
+	// Missing is set for applied migrations absent from the source.
+	Missing bool
+}
+
+// Migrator applies versioned migrations to a database.
+type Migrator struct {
+	db         sql.SQL
+	cfg        Config
+	migrations []Migration
+}
+
+// New creates a Migrator for db. Migrations may be given in any order but
+// versions must be positive and unique.
+func New(db sql.SQL, cfg Config, migrations ...Migration) (*Migrator, error) {
+	if cfg.Table == "" {
+		cfg.Table = DefaultTable
+	}
+	if cfg.Lock.TTL <= 0 {
+		cfg.Lock.TTL = time.Minute
+	}
+	if cfg.Lock.RetryDelay <= 0 {
+		cfg.Lock.RetryDelay = time.Second
+		if cfg.Lock.RetryCount == 0 {
+			cfg.Lock.RetryCount = 300
+		}
+	}
+
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		switch {
+		case m.Version <= 0:
+			return nil, errors.InvalidArgument(fmt.Sprintf("migration %q: version must be positive", m.Name), nil)
+		case m.Name == "":
+			return nil, errors.InvalidArgument(fmt.Sprintf("migration %d: name is required", m.Version), nil)
+		case m.UpSQL == "" && m.Up == nil:
+			return nil, errors.InvalidArgument(fmt.Sprintf("migration %s has no up step", m.label()), nil)
+		case m.UpSQL != "" && m.Up != nil, m.DownSQL != "" && m.Down != nil:
+			return nil, errors.InvalidArgument(fmt.Sprintf("migration %s mixes SQL and Go in one direction", m.label()), nil)
+		case m.Up != nil && m.Revision == "":
+			return nil, errors.InvalidArgument(fmt.Sprintf("migration %s: Go migrations need a Revision for drift detection", m.label()), nil)
+		case i > 0 && sorted[i-1].Version == m.Version:
+			return nil, errors.InvalidArgument(fmt.Sprintf("duplicate migration version %d", m.Version), nil)
+		}
+	}
+	return &Migrator{db: db, cfg: cfg, migrations: sorted}, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) (*Plan, error) {
+	return m.UpTo(ctx, Latest)
+}
+
+// UpTo applies pending migrations with versions up to and including target.
+func (m *Migrator) UpTo(ctx context.Context, target int64) (*Plan, error) {
+	var plan *Plan
+	err := m.run(ctx, func(ctx context.Context, applied map[int64]Record) error {
+		var err error
+		plan, err = m.planUp(applied, target)
+		if err != nil || m.cfg.DryRun {
+			return err
+		}
+		return m.execute(ctx, plan)
+	})
+	return plan, err
+}
+
+// Down rolls back the newest steps applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) (*Plan, error) {
+	var plan *Plan
+	err := m.run(ctx, func(ctx context.Context, applied map[int64]Record) error {
+		var err error
+		plan, err = m.planDown(applied, steps)
+		if err != nil || m.cfg.DryRun {
+			return err
+		}
+		return m.execute(ctx, plan)
+	})
+	return plan, err
+}
+
+// Status lists every migration in the source and the table, by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []Status
+	for _, mig := range m.migrations {
+		st := Status{Version: mig.Version, Name: mig.Name}
+		if rec, ok := applied[mig.Version]; ok {
+			st.Applied, st.AppliedAt = true, rec.AppliedAt
+			st.Drifted = rec.Checksum != mig.Checksum()
+			delete(applied, mig.Version)
+		}
+		out = append(out, st)
+	}
+	for _, rec := range applied {
+		out = append(out, Status{Version: rec.Version, Name: rec.Name, Applied: true, AppliedAt: rec.AppliedAt, Missing: true})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Verify reports drift: applied migrations whose source changed or
+// disappeared.
+func (m *Migrator) Verify(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return drift(statuses)
+}
+
+func drift(statuses []Status) error {
+	var problems []string
+	for _, st := range statuses {
+		switch {
+		case st.Drifted:
+			problems = append(problems, fmt.Sprintf("%d_%s changed after it was applied", st.Version, st.Name))
+		case st.Missing:
+			problems = append(problems, fmt.Sprintf("%d_%s is applied but missing from the source", st.Version, st.Name))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.FailedPrecondition("migration drift: "+strings.Join(problems, "; "), nil)
+	}
+	return nil
+}
+
+// run holds the migration lock while fn works against the applied set. The
+// table is created under the lock so replicas starting together do not race
+// to create it.
+func (m *Migrator) run(ctx context.Context, fn func(ctx context.Context, applied map[int64]Record) error) error {
+	if m.cfg.Locker != nil && !m.cfg.DryRun {
+		unlock, lockCtx, err := m.lock(ctx)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		ctx = lockCtx
+	}
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+	if !m.cfg.AllowDrift {
+		if err := m.Verify(ctx); err != nil {
+			return err
+		}
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(ctx, applied)
+}
+
+// lock acquires the migration lock and keeps extending it. The returned
+// context is cancelled if the lock is lost, aborting the run rather than
+// racing another replica.
+func (m *Migrator) lock(ctx context.Context) (func(), context.Context, error) {
+	l := m.cfg.Locker.NewLock("migrate:"+m.cfg.Table, m.cfg.Lock.TTL)
+	ok, err := distlock.AcquireWithRetry(ctx, l, m.cfg.Lock)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to acquire migration lock")
+	}
+	if !ok {
+		return nil, nil, errors.Aborted("migration lock is held by another process", nil)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(m.cfg.Lock.TTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := l.Extend(ctx, m.cfg.Lock.TTL); err != nil {
+					logger.L().ErrorContext(ctx, "lost migration lock, aborting", "error", err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	unlock := func() {
+		close(done)
+		cancel()
+		if err := l.Release(context.WithoutCancel(ctx)); err != nil {
+			logger.L().WarnContext(ctx, "failed to release migration lock", "error", err)
+		}
+	}
+	return unlock, ctx, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	if err := m.db.Get(ctx).Table(m.cfg.Table).AutoMigrate(&Record{}); err != nil {
+		return errors.Internal("failed to create migrations table "+m.cfg.Table, err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]Record, error) {
+	var records []Record
+	if err := m.db.Get(ctx).Table(m.cfg.Table).Find(&records).Error; err != nil {
+		return nil, errors.Internal("failed to read "+m.cfg.Table, err)
+	}
+	out := make(map[int64]Record, len(records))
+	for _, r := range records {
+		out[r.Version] = r
+	}
+	return out, nil
+}
+
+func (m *Migrator) planUp(applied map[int64]Record, target int64) (*Plan, error) {
+	var newest int64
+	for v := range applied {
+		newest = max(newest, v)
+	}
+	plan := &Plan{DryRun: m.cfg.DryRun}
+	for _, mig := range m.migrations {
+		if mig.Version > target {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if mig.Version < newest && !m.cfg.AllowOutOfOrder {
+			return nil, errors.FailedPrecondition(fmt.Sprintf("migration %s is older than applied version %d", mig.label(), newest), nil)
+		}
+		plan.Steps = append(plan.Steps, step(mig, DirectionUp))
+	}
+	return plan, nil
+}
+
+func (m *Migrator) planDown(applied map[int64]Record, steps int) (*Plan, error) {
+	plan := &Plan{DryRun: m.cfg.DryRun}
+	for i := len(m.migrations) - 1; i >= 0 && len(plan.Steps) < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if mig.DownSQL == "" && mig.Down == nil {
+			return nil, errors.FailedPrecondition(fmt.Sprintf("migration %s cannot be rolled back", mig.label()), nil)
+		}
+		plan.Steps = append(plan.Steps, step(mig, DirectionDown))
+	}
+	return plan, nil
+}
+
+func step(mig Migration, dir Direction) Step {
+	s := Step{Version: mig.Version, Name: mig.Name, Direction: dir, NoTransaction: mig.NoTransaction}
+	script, fn := mig.UpSQL, mig.Up
+	if dir == DirectionDown {
+		script, fn = mig.DownSQL, mig.Down
+	}
+	if fn != nil {
+		s.Go = true
+	} else {
+		s.Statements = statements(script)
+	}
+	return s
+}
+
+func (m *Migrator) execute(ctx context.Context, plan *Plan) error {
+	byVersion := make(map[int64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		byVersion[mig.Version] = mig
+	}
+	for _, s := range plan.Steps {
+		if err := m.apply(ctx, byVersion[s.Version], s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply runs one step and records it, in one transaction unless the
+// migration opts out.
+func (m *Migrator) apply(ctx context.Context, mig Migration, s Step) error {
+	start := time.Now()
+	work := func(db *gorm.DB) error {
+		if s.Go {
+			fn := mig.Up
+			if s.Direction == DirectionDown {
+				fn = mig.Down
+			}
+			if err := fn(ctx, db); err != nil {
+				return err
+			}
+		}
+		for _, stmt := range s.Statements {
+			if err := db.Exec(stmt).Error; err != nil {
+				return errors.Wrap(err, "statement failed: "+stmt)
+			}
+		}
+		if s.Direction == DirectionDown {
+			return db.Table(m.cfg.Table).Where("version = ?", mig.Version).Delete(&Record{}).Error
+		}
+		return db.Table(m.cfg.Table).Create(&Record{
+			Version:    mig.Version,
+			Name:       mig.Name,
+			Checksum:   mig.Checksum(),
+			AppliedAt:  time.Now().UTC(),
+			DurationMS: time.Since(start).Milliseconds(),
+		}).Error
+	}
+
+	db := m.db.Get(ctx)
+	var err error
+	if mig.NoTransaction {
+		err = work(db)
+	} else {
+		err = db.Transaction(work)
+	}
+	if err != nil {
+		return errors.Internal(fmt.Sprintf("migration %s (%s) failed", mig.label(), s.Direction), err)
+	}
+	logger.L().InfoContext(ctx, "migration applied", "version", mig.Version, "name", mig.Name, "direction", s.Direction, "duration", time.Since(start))
+	return nil
+}
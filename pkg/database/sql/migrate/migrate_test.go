@@ -0,0 +1,307 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency/distlock"
+	distmemory "github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency/distlock/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/sql"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/sql/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newDB(t *testing.T) sql.SQL {
+	t.Helper()
+	db, err := memory.NewWithConfig(sql.Config{Name: t.Name()})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+var files = fstest.MapFS{
+	"migrations/001_create_users.up.sql": {Data: []byte(`
+CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL);
+-- seed; with a semicolon in the comment
+INSERT INTO users (id, email) VALUES (1, 'a;b@example.com');
+`)},
+	"migrations/001_create_users.down.sql": {Data: []byte(`DROP TABLE users;`)},
+	"migrations/002_add_index.up.sql":      {Data: []byte("-- migrate:no-transaction\nCREATE INDEX idx_users_email ON users (email);")},
+	"migrations/002_add_index.down.sql":    {Data: []byte(`DROP INDEX idx_users_email;`)},
+	"migrations/README.md":                 {Data: []byte("ignored")},
+}
+
+func loadFiles(t *testing.T) []Migration {
+	t.Helper()
+	migrations, err := FromFS(files, "migrations")
+	require.NoError(t, err)
+	return migrations
+}
+
+func countUsers(t *testing.T, db sql.SQL) int64 {
+	t.Helper()
+	var n int64
+	require.NoError(t, db.Get(context.Background()).Table("users").Count(&n).Error)
+	return n
+}
+
+func TestFromFS(t *testing.T) {
+	migrations := loadFiles(t)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "create_users", migrations[0].Name)
+	assert.False(t, migrations[0].NoTransaction)
+	assert.True(t, migrations[1].NoTransaction)
+
+	_, err := FromFS(fstest.MapFS{"m/3_x.down.sql": {Data: []byte("SELECT 1")}}, "m")
+	assert.True(t, errors.IsCode(err, errors.CodeInvalidArgument))
+}
+
+func TestUpDownAndStatus(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	goStep := Migration{
+		Version:  3,
+		Name:     "seed_admin",
+		Revision: "1",
+		Up: func(ctx context.Context, db *gorm.DB) error {
+			return db.Exec("INSERT INTO users (id, email) VALUES (2, 'admin@example.com')").Error
+		},
+		Down: func(ctx context.Context, db *gorm.DB) error {
+			return db.Exec("DELETE FROM users WHERE id = 2").Error
+		},
+	}
+	m, err := New(db, Config{}, append(loadFiles(t), goStep)...)
+	require.NoError(t, err)
+
+	plan, err := m.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, plan.Steps, 3)
+	assert.Len(t, plan.Steps[0].Statements, 2)
+	assert.Equal(t, int64(2), countUsers(t, db))
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	for _, st := range statuses {
+		assert.True(t, st.Applied, st.Name)
+		assert.False(t, st.Drifted, st.Name)
+	}
+
+	// Nothing left to do.
+	plan, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Steps)
+
+	plan, err = m.Down(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, plan.Steps, 2)
+	assert.Equal(t, int64(3), plan.Steps[0].Version)
+	assert.Equal(t, int64(2), plan.Steps[1].Version)
+	assert.Equal(t, int64(1), countUsers(t, db))
+	assert.False(t, db.Get(ctx).Migrator().HasIndex("users", "idx_users_email"))
+
+	plan, err = m.UpTo(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, plan.Steps, 1)
+	assert.Equal(t, int64(2), plan.Steps[0].Version)
+}
+
+func TestDryRunPlansWithoutExecuting(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	m, err := New(db, Config{DryRun: true}, loadFiles(t)...)
+	require.NoError(t, err)
+
+	plan, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.True(t, plan.DryRun)
+	assert.Len(t, plan.Steps, 2)
+	assert.Contains(t, plan.String(), "-- 1_create_users (up)\nCREATE TABLE users")
+	assert.Contains(t, plan.String(), "-- 2_add_index (up, no transaction)")
+	assert.False(t, db.Get(ctx).Migrator().HasTable("users"))
+}
+
+func TestDriftDetection(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	migrations := loadFiles(t)
+	m, err := New(db, Config{}, migrations...)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	edited := append([]Migration(nil), migrations...)
+	edited[0].UpSQL += "\nCREATE TABLE extra (id INTEGER);"
+	m, err = New(db, Config{}, edited...)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	assert.True(t, errors.IsCode(err, errors.CodeFailedPrecondition), "%v", err)
+	assert.Contains(t, err.Error(), "1_create_users changed")
+
+	m, err = New(db, Config{}, migrations[0])
+	require.NoError(t, err)
+	err = m.Verify(ctx)
+	assert.Contains(t, err.Error(), "2_add_index is applied but missing")
+
+	m, err = New(db, Config{AllowDrift: true}, edited...)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	assert.NoError(t, err)
+}
+
+func TestOutOfOrder(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	migrations := loadFiles(t)
+	m, err := New(db, Config{}, migrations[0])
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	late := Migration{Version: 5, Name: "late", UpSQL: "CREATE TABLE late (id INTEGER)"}
+	m, err = New(db, Config{}, migrations[0], late)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	m, err = New(db, Config{}, append(migrations, late)...)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	assert.True(t, errors.IsCode(err, errors.CodeFailedPrecondition), "%v", err)
+
+	m, err = New(db, Config{AllowOutOfOrder: true}, append(migrations, late)...)
+	require.NoError(t, err)
+	plan, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, plan.Steps, 1)
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	m, err := New(db, Config{}, Migration{
+		Version: 1,
+		Name:    "broken",
+		UpSQL:   "CREATE TABLE half (id INTEGER); SELECT * FROM missing_table",
+	})
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.Error(t, err)
+	assert.False(t, db.Get(ctx).Migrator().HasTable("half"))
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, statuses[0].Applied)
+}
+
+func TestLockHeldElsewhere(t *testing.T) {
+	ctx := context.Background()
+	locker := distmemory.New()
+	other := locker.NewLock("migrate:"+DefaultTable, time.Minute)
+	ok, err := other.Acquire(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	db := newDB(t)
+	m, err := New(db, Config{
+		Locker: locker,
+		Lock:   distlock.LockConfig{TTL: time.Minute, RetryDelay: time.Millisecond, RetryCount: 2},
+	}, loadFiles(t)...)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	assert.True(t, errors.IsCode(err, errors.CodeAborted), "%v", err)
+	assert.False(t, db.Get(ctx).Migrator().HasTable(DefaultTable), "the table is only created under the lock")
+
+	require.NoError(t, other.Release(ctx))
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	// The lock is released after the run.
+	ok, err = locker.NewLock("migrate:"+DefaultTable, time.Minute).Acquire(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestNewValidates(t *testing.T) {
+	db := newDB(t)
+	_, err := New(db, Config{}, Migration{Version: 1, Name: "a", UpSQL: "x"}, Migration{Version: 1, Name: "b", UpSQL: "y"})
+	assert.Error(t, err)
+	_, err = New(db, Config{}, Migration{Version: 1, Name: "a"})
+	assert.Error(t, err)
+	_, err = New(db, Config{}, Migration{Version: 0, Name: "a", UpSQL: "x"})
+	assert.Error(t, err)
+	_, err = New(db, Config{}, Migration{Version: 1, Name: "a", Up: func(context.Context, *gorm.DB) error { return nil }})
+	assert.True(t, errors.IsCode(err, errors.CodeInvalidArgument), "Go migrations need a Revision")
+}
+
+func TestGoMigrationDrift(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	noop := func(context.Context, *gorm.DB) error { return nil }
+	m, err := New(db, Config{}, Migration{Version: 1, Name: "seed", Revision: "v1", Up: noop})
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	m, err = New(db, Config{}, Migration{Version: 1, Name: "seed", Revision: "v2", Up: noop})
+	require.NoError(t, err)
+	err = m.Verify(ctx)
+	assert.True(t, errors.IsCode(err, errors.CodeFailedPrecondition), "%v", err)
+	assert.Contains(t, err.Error(), "1_seed changed")
+}
+
+func TestExpandContract(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	g := db.Get(ctx)
+	require.NoError(t, g.Exec("CREATE TABLE people (id INTEGER PRIMARY KEY, first TEXT, last TEXT)").Error)
+	for i, name := range [][2]string{{"Ada", "Lovelace"}, {"Alan", "Turing"}, {"Grace", "Hopper"}, {"Edsger", "Dijkstra"}, {"Barbara", "Liskov"}} {
+		require.NoError(t, g.Exec("INSERT INTO people (id, first, last) VALUES (?, ?, ?)", i+1, name[0], name[1]).Error)
+	}
+	require.NoError(t, g.Exec("INSERT INTO people (id, first, last) VALUES (6, NULL, NULL)").Error)
+
+	m, err := New(db, Config{},
+		Expand(1, "add_full_name", ExpandColumn{
+			// ? inside the SQL fragments must not be taken for placeholders.
+			Table: "people", Column: "full_name", Definition: "TEXT DEFAULT NULL CHECK (full_name <> '?')",
+			Backfill: "first || ' ' || last || CASE WHEN id = 5 THEN '?' ELSE '' END", BatchSize: 2,
+		}),
+		Contract(2, "drop_first_last", "people", "first", "last"),
+	)
+	require.NoError(t, err)
+
+	_, err = m.UpTo(ctx, 1)
+	require.NoError(t, err)
+	var names []string
+	require.NoError(t, g.Table("people").Where("full_name IS NOT NULL").Order("id").Pluck("full_name", &names).Error)
+	assert.Equal(t, []string{"Ada Lovelace", "Alan Turing", "Grace Hopper", "Edsger Dijkstra", "Barbara Liskov?"}, names)
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.False(t, g.Migrator().HasColumn("people", "first"))
+	assert.True(t, g.Migrator().HasColumn("people", "full_name"))
+
+	_, err = m.Down(ctx, 1)
+	assert.True(t, errors.IsCode(err, errors.CodeFailedPrecondition), "%v", err)
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `
+-- leading comment;
+CREATE FUNCTION f() RETURNS trigger AS $body$ BEGIN NEW.a := 'x;y'; RETURN NEW; END $body$ LANGUAGE plpgsql;
+INSERT INTO t VALUES ('it''s; fine', "col;name", $1); /* block; comment */
+UPDATE t SET a = 1
+`
+	got := splitStatements(script)
+	require.Len(t, got, 3)
+	assert.Contains(t, got[0], "$body$ LANGUAGE plpgsql")
+	assert.Contains(t, got[1], `'it''s; fine', "col;name", $1)`)
+	assert.Contains(t, got[2], "UPDATE t SET a = 1")
+
+	assert.Equal(t, []string{"-- migrate:no-split\nA; B"}, statements("-- migrate:no-split\nA; B"))
+}
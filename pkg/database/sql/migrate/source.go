@@ -0,0 +1,181 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+// Directives recognised in the leading comment lines of a SQL script.
+const (
+	// DirectiveNoTransaction runs the migration outside a transaction, for
+	// statements such as CREATE INDEX CONCURRENTLY.
+	DirectiveNoTransaction = "-- migrate:no-transaction"
+
+	// DirectiveNoSplit sends the script to the database as one statement,
+	// for bodies the splitter cannot see through (triggers, procedures).
+	DirectiveNoSplit = "-- migrate:no-split"
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// FromFS loads SQL migrations from dir in fsys, typically an embed.FS. Files
+// are named <version>_<name>.up.sql and <version>_<name>.down.sql; the down
+// script is optional. Other files are ignored.
+func FromFS(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.InvalidArgument("failed to read migrations from "+dir, err)
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, errors.InvalidArgument("invalid migration version in "+e.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, errors.InvalidArgument("failed to read migration "+e.Name(), err)
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, errors.InvalidArgument(fmt.Sprintf("migration %d has mismatched names %q and %q", version, mig.Name, m[2]), nil)
+		}
+		if m[3] == "up" {
+			mig.UpSQL = string(data)
+		} else {
+			mig.DownSQL = string(data)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.UpSQL == "" {
+			return nil, errors.InvalidArgument(fmt.Sprintf("migration %d_%s has no up script", mig.Version, mig.Name), nil)
+		}
+		mig.NoTransaction = hasDirective(mig.UpSQL, DirectiveNoTransaction)
+		out = append(out, *mig)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// hasDirective reports whether directive appears among the comment lines
+// that open script.
+func hasDirective(script, directive string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			return false
+		}
+		if line == directive {
+			return true
+		}
+	}
+	return false
+}
+
+// statements splits script into the statements to execute, one at a time so
+// that drivers without multi-statement support (MySQL by default) work.
+func statements(script string) []string {
+	if hasDirective(script, DirectiveNoSplit) {
+		return []string{strings.TrimSpace(script)}
+	}
+	return splitStatements(script)
+}
+
+// splitStatements splits on semicolons outside quotes, comments and
+// PostgreSQL dollar-quoted bodies, dropping empty and comment-only pieces.
+func splitStatements(script string) []string {
+	var (
+		out   []string
+		start int
+	)
+	flush := func(end int) {
+		if stmt := strings.TrimSpace(script[start:end]); !commentOnly(stmt) {
+			out = append(out, stmt)
+		}
+		start = end + 1
+	}
+	for i := 0; i < len(script); i++ {
+		switch c := script[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			// Doubled quotes escape themselves, so scanning to the next
+			// quote and resuming there handles them.
+			if j := strings.IndexByte(script[i+1:], c); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(script)
+			}
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			if j := strings.IndexByte(script[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(script)
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			if j := strings.Index(script[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(script)
+			}
+		case c == '$':
+			if tag := dollarTag(script[i:]); tag != "" {
+				if j := strings.Index(script[i+len(tag):], tag); j >= 0 {
+					i += len(tag) + j + len(tag) - 1
+				} else {
+					i = len(script)
+				}
+			}
+		case c == ';':
+			flush(i)
+		}
+	}
+	if start < len(script) {
+		flush(len(script))
+	}
+	return out
+}
+
+// dollarTag returns the $tag$ opening s, or "" when s does not start one.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// commentOnly reports whether stmt holds nothing but whitespace and comments.
+func commentOnly(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
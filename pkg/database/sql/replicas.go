@@ -0,0 +1,461 @@
+package sql
+
+import (
+	"context"
+	stdsql "database/sql"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/algorithms/loadbalancing"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/algorithms/loadbalancing/roundrobin"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// ReplicaConfig configures read-replica routing.
+type ReplicaConfig struct {
+	// MaxLag drops replicas further behind the primary than this.
+	MaxLag time.Duration `env:"SQL_REPLICA_MAX_LAG" env-default:"5s"`
+
+	// CheckInterval is how often replicas are probed for health and lag.
+	CheckInterval time.Duration `env:"SQL_REPLICA_CHECK_INTERVAL" env-default:"5s"`
+
+	// CheckTimeout bounds each probe.
+	CheckTimeout time.Duration `env:"SQL_REPLICA_CHECK_TIMEOUT" env-default:"2s"`
+
+	// ReadYourWritesWindow keeps a session's reads on the primary for this
+	// long after it writes (default MaxLag, which no serving replica exceeds).
+	ReadYourWritesWindow time.Duration `env:"SQL_READ_YOUR_WRITES_WINDOW"`
+}
+
+// LagProbe measures how far db, a replica, is behind its primary.
+type LagProbe func(ctx context.Context, db *gorm.DB) (time.Duration, error)
+
+// ReplicaOption configures a Replicated.
+type ReplicaOption func(*Replicated)
+
+// WithBalancer spreads reads with b instead of round robin. b must start
+// empty; healthy replicas are added and removed as probes report.
+func WithBalancer(b loadbalancing.Balancer) ReplicaOption {
+	return func(r *Replicated) { r.balancer = b }
+}
+
+// WithLagProbe replaces DefaultLagProbe.
+func WithLagProbe(p LagProbe) ReplicaOption {
+	return func(r *Replicated) { r.probe = p }
+}
+
+// ReplicaStatus is the last probe result for a replica.
+type ReplicaStatus struct {
+	Name      string
+	Healthy   bool
+	Lag       time.Duration
+	CheckedAt time.Time
+	Err       error
+}
+
+// Replicated routes hinted reads across read replicas and everything else
+// to the primary. Get returns the primary unless the context carries
+// PreferReplica, is inside ops.Transaction (WithPrimary), or belongs to a
+// session (WithSession) that wrote within ReadYourWritesWindow. Replicas
+// that fail a probe or lag more than MaxLag stop receiving reads until a
+// later probe passes; with none healthy, reads fall back to the primary.
+type Replicated struct {
+	primary  SQL
+	replicas map[string]SQL
+	cfg      ReplicaConfig
+	balancer loadbalancing.Balancer
+	probe    LagProbe
+
+	mu     *concurrency.SmartRWMutex
+	status map[string]ReplicaStatus
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Ensure Replicated implements SQL.
+var _ SQL = (*Replicated)(nil)
+
+// NewReplicated wraps primary and its read replicas, keyed by a name used
+// in logs and status. Replicas are probed once before it returns and then
+// every CheckInterval until Close.
+func NewReplicated(primary SQL, replicas map[string]SQL, cfg ReplicaConfig, opts ...ReplicaOption) (*Replicated, error) {
+	if primary == nil {
+		return nil, errors.InvalidArgument("primary is required", nil)
+	}
+	if cfg.MaxLag <= 0 {
+		cfg.MaxLag = 5 * time.Second
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 5 * time.Second
+	}
+	if cfg.CheckTimeout <= 0 {
+		cfg.CheckTimeout = 2 * time.Second
+	}
+	if cfg.ReadYourWritesWindow <= 0 {
+		cfg.ReadYourWritesWindow = cfg.MaxLag
+	}
+
+	copied := make(map[string]SQL, len(replicas))
+	for name, db := range replicas {
+		if db == nil {
+			return nil, errors.InvalidArgument("nil replica: "+name, nil)
+		}
+		copied[name] = db
+	}
+
+	r := &Replicated{
+		primary:  primary,
+		replicas: copied,
+		cfg:      cfg,
+		probe:    DefaultLagProbe,
+		mu:       concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "sql-replicated"}),
+		status:   make(map[string]ReplicaStatus, len(copied)),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.balancer == nil {
+		r.balancer = roundrobin.New()
+	}
+
+	if err := registerWriteTracking(primary.Get(context.Background())); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.checkAll(ctx)
+	go r.loop(ctx)
+	return r, nil
+}
+
+// Get returns a replica for reads hinted with PreferReplica, otherwise the
+// primary.
+func (r *Replicated) Get(ctx context.Context) *gorm.DB {
+	if db := r.replica(ctx); db != nil {
+		return db.Get(ctx)
+	}
+	return r.primary.Get(ctx)
+}
+
+// GetShard delegates to the primary.
+func (r *Replicated) GetShard(ctx context.Context, key string) (*gorm.DB, error) {
+	return r.primary.GetShard(ctx, key)
+}
+
+// Primary returns the primary regardless of hints.
+func (r *Replicated) Primary(ctx context.Context) *gorm.DB {
+	return r.primary.Get(ctx)
+}
+
+// Primary returns db's primary connection: Replicated.Primary for a
+// Replicated, otherwise db.Get under WithPrimary.
+func Primary(ctx context.Context, db SQL) *gorm.DB {
+	if p, ok := db.(interface {
+		Primary(ctx context.Context) *gorm.DB
+	}); ok {
+		return p.Primary(ctx)
+	}
+	return db.Get(WithPrimary(ctx))
+}
+
+// Replicas returns the last probe result for each replica, by name.
+func (r *Replicated) Replicas() []ReplicaStatus {
+	r.mu.RLock()
+	out := make([]ReplicaStatus, 0, len(r.status))
+	for _, st := range r.status {
+		out = append(out, st)
+	}
+	r.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Close stops probing and closes the replicas and the primary.
+func (r *Replicated) Close() error {
+	r.cancel()
+	<-r.done
+	var first error
+	for name, db := range r.replicas {
+		if err := db.Close(); err != nil && first == nil {
+			first = errors.Wrap(err, "failed to close replica "+name)
+		}
+	}
+	if err := r.primary.Close(); err != nil && first == nil {
+		first = err
+	}
+	return first
+}
+
+func (r *Replicated) replica(ctx context.Context) SQL {
+	if routeHint(ctx) != hintReplica || len(r.replicas) == 0 {
+		return nil
+	}
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok && s.wroteWithin(r.cfg.ReadYourWritesWindow) {
+		return nil
+	}
+	name, err := r.balancer.Next(ctx)
+	if err != nil {
+		return nil
+	}
+	return r.replicas[name]
+}
+
+func (r *Replicated) loop(ctx context.Context) {
+	defer close(r.done)
+	ticker := time.NewTicker(r.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.checkAll(ctx)
+		}
+	}
+}
+
+func (r *Replicated) checkAll(ctx context.Context) {
+	for name, db := range r.replicas {
+		r.check(ctx, name, db)
+	}
+}
+
+// check probes one replica and moves it in or out of the balancer when its
+// health changes.
+func (r *Replicated) check(ctx context.Context, name string, db SQL) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.CheckTimeout)
+	defer cancel()
+
+	st := ReplicaStatus{Name: name, CheckedAt: time.Now()}
+	st.Lag, st.Err = r.probe(ctx, db.Get(ctx))
+	if st.Err == nil && st.Lag > r.cfg.MaxLag {
+		st.Err = errors.Unavailable("replica lag "+st.Lag.String()+" exceeds "+r.cfg.MaxLag.String(), nil)
+	}
+	st.Healthy = st.Err == nil
+
+	r.mu.Lock()
+	prev, seen := r.status[name]
+	r.status[name] = st
+	r.mu.Unlock()
+
+	switch {
+	case st.Healthy && (!seen || !prev.Healthy):
+		r.balancer.Add(name, 1)
+		if seen {
+			logger.L().InfoContext(ctx, "sql replica restored", "replica", name, "lag", st.Lag)
+		}
+	case !st.Healthy && (!seen || prev.Healthy):
+		if seen {
+			r.balancer.Remove(name)
+		}
+		logger.L().WarnContext(ctx, "sql replica removed from rotation", "replica", name, "error", st.Err)
+	}
+}
+
+// DefaultLagProbe pings db and measures replication lag on PostgreSQL
+// (replay delay, zero once caught up) and MySQL (Seconds_Behind_Source).
+// Other dialects are only pinged; pass WithLagProbe to measure their lag.
+func DefaultLagProbe(ctx context.Context, db *gorm.DB) (time.Duration, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get sql.DB")
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return 0, errors.Unavailable("replica ping failed", err)
+	}
+
+	switch db.Dialector.Name() {
+	case "postgres":
+		var secs float64
+		err := db.Raw(`SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`).Scan(&secs).Error
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to measure replica lag")
+		}
+		return time.Duration(secs * float64(time.Second)), nil
+	case "mysql":
+		return mysqlLag(ctx, db)
+	}
+	return 0, nil
+}
+
+// mysqlLag reads Seconds_Behind_Source (Seconds_Behind_Master before
+// MySQL 8.0.22). A NULL value means replication is stopped.
+func mysqlLag(ctx context.Context, db *gorm.DB) (time.Duration, error) {
+	rows, err := db.Raw("SHOW REPLICA STATUS").Rows()
+	if err != nil {
+		rows, err = db.Raw("SHOW SLAVE STATUS").Rows()
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read replica status")
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		// Not a replica.
+		return 0, rows.Err()
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read replica status")
+	}
+	values := make([]stdsql.RawBytes, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, errors.Wrap(err, "failed to read replica status")
+	}
+	for i, col := range cols {
+		if col != "Seconds_Behind_Source" && col != "Seconds_Behind_Master" {
+			continue
+		}
+		if values[i] == nil {
+			return 0, errors.Unavailable("replication is not running", nil)
+		}
+		secs, err := strconv.ParseInt(string(values[i]), 10, 64)
+		if err != nil {
+			return 0, errors.Wrap(err, "invalid replica lag")
+		}
+		return time.Duration(secs) * time.Second, nil
+	}
+	return 0, nil
+}
+
+type routeHintKey struct{}
+
+type sessionKey struct{}
+
+const (
+	hintNone = iota
+	hintReplica
+	hintPrimary
+)
+
+// PreferReplica marks reads under ctx as safe to serve from a replica that
+// may be up to ReplicaConfig.MaxLag behind. It has no effect inside
+// WithPrimary.
+func PreferReplica(ctx context.Context) context.Context {
+	if routeHint(ctx) == hintPrimary {
+		return ctx
+	}
+	return context.WithValue(ctx, routeHintKey{}, hintReplica)
+}
+
+// WithPrimary pins everything under ctx to the primary, overriding
+// PreferReplica. ops.Transaction applies it.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routeHintKey{}, hintPrimary)
+}
+
+func routeHint(ctx context.Context) int {
+	h, _ := ctx.Value(routeHintKey{}).(int)
+	return h
+}
+
+// WithSession starts a read-your-writes session, typically per request:
+// after a write through a Replicated primary under ctx, replica-hinted
+// reads under ctx go to the primary for ReadYourWritesWindow.
+func WithSession(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(sessionKey{}).(*session); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// MarkWrite records a write in ctx's session, for writes made outside GORM.
+func MarkWrite(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.lastWrite.Store(time.Now().UnixNano())
+	}
+}
+
+type session struct {
+	lastWrite atomic.Int64
+}
+
+func (s *session) wroteWithin(window time.Duration) bool {
+	last := s.lastWrite.Load()
+	return last != 0 && time.Since(time.Unix(0, last)) < window
+}
+
+// registerWriteTracking marks the session after every write statement on
+// db. Raw statements count as writes unless they start with a read-only
+// keyword (see readOnlyStatement).
+func registerWriteTracking(db *gorm.DB) error {
+	const name = "replicas:mark_write"
+	mark := func(db *gorm.DB) {
+		if db.Error == nil && db.Statement.Context != nil {
+			MarkWrite(db.Statement.Context)
+		}
+	}
+	markRaw := func(db *gorm.DB) {
+		if !readOnlyStatement(db.Statement.SQL.String()) {
+			mark(db)
+		}
+	}
+	cb := db.Callback()
+	if cb.Create().Get(name) != nil {
+		return nil
+	}
+	for _, err := range []error{
+		cb.Create().After("gorm:create").Register(name, mark),
+		cb.Update().After("gorm:update").Register(name, mark),
+		cb.Delete().After("gorm:delete").Register(name, mark),
+		cb.Raw().After("gorm:raw").Register(name, markRaw),
+	} {
+		if err != nil {
+			return errors.Internal("failed to register write tracking callback", err)
+		}
+	}
+	return nil
+}
+
+// readKeywords start statements that never write. WITH is absent because a
+// CTE may wrap an INSERT, UPDATE or DELETE.
+var readKeywords = map[string]bool{
+	"SELECT": true, "SHOW": true, "EXPLAIN": true, "DESCRIBE": true,
+	"DESC": true, "VALUES": true, "TABLE": true,
+}
+
+// readOnlyStatement reports whether query's leading keyword, after
+// whitespace, comments and opening parentheses, is in readKeywords.
+func readOnlyStatement(query string) bool {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(query, "--"):
+			i := strings.IndexByte(query, '\n')
+			if i < 0 {
+				return false
+			}
+			query = query[i+1:]
+		case strings.HasPrefix(query, "/*"):
+			i := strings.Index(query, "*/")
+			if i < 0 {
+				return false
+			}
+			query = query[i+2:]
+		default:
+			end := strings.IndexFunc(query, func(r rune) bool {
+				return !unicode.IsLetter(r)
+			})
+			if end < 0 {
+				end = len(query)
+			}
+			return readKeywords[strings.ToUpper(query[:end])]
+		}
+	}
+}
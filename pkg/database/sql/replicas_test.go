@@ -0,0 +1,175 @@
+package sql_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/ops"
+	dbsql "github.com/chris-alexander-pop/go-hyperforge/pkg/database/sql"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/sql/adapters/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeLag reports a configurable lag per database, identified by name.
+type fakeLag struct {
+	mu  sync.Mutex
+	lag map[string]time.Duration
+	err map[string]error
+}
+
+func (f *fakeLag) set(name string, lag time.Duration, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lag[name], f.err[name] = lag, err
+}
+
+func (f *fakeLag) probe(ctx context.Context, db *gorm.DB) (time.Duration, error) {
+	name, err := dbName(db)
+	if err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lag[name], f.err[name]
+}
+
+func dbName(db *gorm.DB) (string, error) {
+	var name string
+	err := db.Raw("SELECT name FROM whoami").Scan(&name).Error
+	return name, err
+}
+
+func whoami(t *testing.T, db *gorm.DB) string {
+	name, err := dbName(db)
+	if err != nil {
+		t.Errorf("whoami: %v", err)
+	}
+	return name
+}
+
+func newNamedDB(t *testing.T, name string) dbsql.SQL {
+	t.Helper()
+	db, err := memory.NewWithConfig(dbsql.Config{Name: t.Name() + "_" + name})
+	require.NoError(t, err)
+	g := db.Get(context.Background())
+	require.NoError(t, g.Exec("CREATE TABLE whoami (name TEXT)").Error)
+	require.NoError(t, g.Exec("INSERT INTO whoami (name) VALUES (?)", name).Error)
+	require.NoError(t, g.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)").Error)
+	return db
+}
+
+func newReplicated(t *testing.T, cfg dbsql.ReplicaConfig) (*dbsql.Replicated, *fakeLag) {
+	t.Helper()
+	lag := &fakeLag{lag: map[string]time.Duration{}, err: map[string]error{}}
+	r, err := dbsql.NewReplicated(newNamedDB(t, "primary"), map[string]dbsql.SQL{
+		"r1": newNamedDB(t, "r1"),
+		"r2": newNamedDB(t, "r2"),
+	}, cfg, dbsql.WithLagProbe(lag.probe))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.Close() })
+	return r, lag
+}
+
+func TestReplicatedRoutesHintedReads(t *testing.T) {
+	r, _ := newReplicated(t, dbsql.ReplicaConfig{CheckInterval: time.Hour})
+	ctx := context.Background()
+
+	assert.Equal(t, "primary", whoami(t, r.Get(ctx)))
+
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		seen[whoami(t, r.Get(dbsql.PreferReplica(ctx)))]++
+	}
+	assert.Equal(t, map[string]int{"r1": 2, "r2": 2}, seen)
+
+	pinned := dbsql.PreferReplica(dbsql.WithPrimary(ctx))
+	assert.Equal(t, "primary", whoami(t, r.Get(pinned)))
+	assert.Equal(t, "primary", whoami(t, r.Get(dbsql.WithPrimary(dbsql.PreferReplica(ctx)))))
+
+	err := ops.Transaction(dbsql.PreferReplica(ctx), r, func(tx *gorm.DB) error {
+		assert.Equal(t, "primary", whoami(t, tx))
+		assert.Equal(t, "primary", whoami(t, r.Get(dbsql.PreferReplica(tx.Statement.Context))))
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestReplicatedReadYourWrites(t *testing.T) {
+	r, _ := newReplicated(t, dbsql.ReplicaConfig{CheckInterval: time.Hour, ReadYourWritesWindow: 50 * time.Millisecond})
+	ctx := dbsql.WithSession(context.Background())
+
+	assert.NotEqual(t, "primary", whoami(t, r.Get(dbsql.PreferReplica(ctx))))
+
+	require.NoError(t, r.Get(ctx).Exec("INSERT INTO items (id, name) VALUES (1, 'a')").Error)
+	assert.Equal(t, "primary", whoami(t, r.Get(dbsql.PreferReplica(ctx))))
+
+	// Other sessions are unaffected.
+	other := dbsql.WithSession(context.Background())
+	assert.NotEqual(t, "primary", whoami(t, r.Get(dbsql.PreferReplica(other))))
+
+	time.Sleep(60 * time.Millisecond)
+	assert.NotEqual(t, "primary", whoami(t, r.Get(dbsql.PreferReplica(ctx))))
+}
+
+func TestReplicatedRawReadsKeepReplicas(t *testing.T) {
+	r, _ := newReplicated(t, dbsql.ReplicaConfig{CheckInterval: time.Hour, ReadYourWritesWindow: time.Hour})
+	ctx := dbsql.WithSession(context.Background())
+
+	for _, query := range []string{
+		"SELECT name FROM whoami",
+		"  /* report */ SELECT 1",
+		"-- count\nselect count(*) FROM items",
+	} {
+		require.NoError(t, r.Get(ctx).Exec(query).Error)
+		assert.NotEqual(t, "primary", whoami(t, r.Get(dbsql.PreferReplica(ctx))), query)
+	}
+
+	require.NoError(t, r.Get(ctx).Exec("/* seed */ INSERT INTO items (id, name) VALUES (1, 'a')").Error)
+	assert.Equal(t, "primary", whoami(t, r.Get(dbsql.PreferReplica(ctx))))
+}
+
+func TestReplicatedDropsLaggingReplicas(t *testing.T) {
+	r, lag := newReplicated(t, dbsql.ReplicaConfig{MaxLag: time.Second, CheckInterval: 10 * time.Millisecond})
+	ctx := dbsql.PreferReplica(context.Background())
+
+	lag.set("r1", 5*time.Second, nil)
+	require.Eventually(t, func() bool {
+		for _, st := range r.Replicas() {
+			if st.Name == "r1" {
+				return !st.Healthy
+			}
+		}
+		return false
+	}, time.Second, 5*time.Millisecond)
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, "r2", whoami(t, r.Get(ctx)))
+	}
+
+	lag.set("r2", 0, errors.New("connection refused"))
+	require.Eventually(t, func() bool { return whoami(t, r.Get(ctx)) == "primary" }, time.Second, 5*time.Millisecond)
+
+	lag.set("r1", 0, nil)
+	require.Eventually(t, func() bool { return whoami(t, r.Get(ctx)) == "r1" }, time.Second, 5*time.Millisecond)
+	statuses := r.Replicas()
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Healthy)
+	assert.False(t, statuses[1].Healthy)
+	assert.EqualError(t, statuses[1].Err, "connection refused")
+}
+
+func TestReplicaConfigs(t *testing.T) {
+	cfg := dbsql.Config{Driver: "postgres", Host: "primary", Port: "5432", User: "app", Replicas: []string{"replica-a", "replica-b:6432"}}
+	replicas := cfg.ReplicaConfigs()
+	require.Len(t, replicas, 2)
+	assert.Equal(t, "replica-a", replicas[0].Host)
+	assert.Equal(t, "5432", replicas[0].Port)
+	assert.Equal(t, "replica-b", replicas[1].Host)
+	assert.Equal(t, "6432", replicas[1].Port)
+	assert.Equal(t, "app", replicas[1].User)
+	assert.Nil(t, replicas[1].Replicas)
+}
@@ -11,6 +11,7 @@
 //   - Connection pooling and lifecycle management
 //   - Multi-database sharding support
 //   - Instrumented wrapper for logging and tracing
+//   - Read-replica routing with lag-aware failover (NewReplicated)
 //
 // Usage:
 //
@@ -22,6 +23,7 @@ package sql
 
 import (
 	"context"
+	"net"
 	"time"
 
 	"gorm.io/gorm"
@@ -54,12 +56,33 @@ type Config struct {
 	SSLRootCert string `env:"SQL_SSL_ROOT_CERT"`                  // Path to CA cert
 	AuthToken   string `env:"SQL_AUTH_TOKEN"`                     // For IAM authentication (e.g. AWS RDS)
 
+	// Replicas lists read replica addresses as host or host:port. Build a
+	// connection per entry of ReplicaConfigs and combine them with the
+	// primary using NewReplicated.
+	Replicas []string `env:"SQL_REPLICAS"`
+
 	// Connection Pooling
 	MaxOpenConns    int           `env:"SQL_MAX_OPEN_CONNS" env-default:"10"`
 	MaxIdleConns    int           `env:"SQL_MAX_IDLE_CONNS" env-default:"5"`
 	ConnMaxLifetime time.Duration `env:"SQL_CONN_MAX_LIFETIME" env-default:"5m"`
 }
 
+// ReplicaConfigs returns one Config per entry in Replicas, identical to c
+// except for Host, Port (when given) and an empty Replicas.
+func (c Config) ReplicaConfigs() []Config {
+	out := make([]Config, 0, len(c.Replicas))
+	for _, addr := range c.Replicas {
+		rc := c
+		rc.Replicas = nil
+		rc.Host = addr
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			rc.Host, rc.Port = host, port
+		}
+		out = append(out, rc)
+	}
+	return out
+}
+
 // SQL defines the interface for SQL database operations.
 type SQL interface {
 	// Get returns the primary database connection.
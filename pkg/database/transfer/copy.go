@@ -59,6 +59,7 @@ func CopyTable(ctx context.Context, src *gorm.DB, dst *gorm.DB, model interface{
 }
 
 // MigrateSchema copies the schema structure from source model to destination.
+// It only adds; use pkg/database/sql/migrate for versioned, reversible changes.
 func MigrateSchema(ctx context.Context, dst *gorm.DB, models ...interface{}) error {
 	return dst.WithContext(ctx).AutoMigrate(models...)
 }
@@ -2,10 +2,12 @@ package memory_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -36,3 +38,38 @@ func TestSearchWithOpts_MetadataFilter(t *testing.T) {
 	require.Len(t, results, 1)
 	assert.Equal(t, "c", results[0].ID)
 }
+
+func TestSearchWithOpts_FilterExpr(t *testing.T) {
+	ctx := context.Background()
+	for _, threshold := range []int{0, -1} {
+		store := memory.NewWithConfig(memory.Config{ExactThreshold: threshold})
+		for i := 0; i < 50; i++ {
+			tags := []interface{}{"all"}
+			if i%5 == 0 {
+				tags = append(tags, "five")
+			}
+			require.NoError(t, store.Upsert(ctx, fmt.Sprint(i), []float32{1, float32(i) / 50}, map[string]interface{}{
+				"price": i,
+				"lang":  []string{"en", "fr"}[i%2],
+				"tags":  tags,
+			}))
+		}
+
+		results, err := store.SearchWithOpts(ctx, []float32{1, 0}, vector.SearchOpts{
+			Limit:  50,
+			Filter: map[string]interface{}{"lang": "en"},
+			Where: vector.And(
+				vector.Gte("price", 10),
+				vector.Or(vector.Contains("tags", "five"), vector.Lt("price", 14)),
+			),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"10", "12", "20", "30", "40"}, ids(results), "threshold %d", threshold)
+
+		_, err = store.SearchWithOpts(ctx, []float32{1, 0}, vector.SearchOpts{
+			Limit:  5,
+			Filter: map[string]interface{}{"price": map[string]interface{}{"$gt": []int{1}}},
+		})
+		assert.True(t, errors.IsCode(err, errors.CodeInvalidArgument))
+	}
+}
@@ -0,0 +1,311 @@
+package memory
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Metric selects the similarity used for scoring and graph construction.
+type Metric string
+
+const (
+	// MetricCosine scores by cosine similarity in [-1, 1].
+	MetricCosine Metric = "cosine"
+	// MetricDot scores by inner product.
+	MetricDot Metric = "dot"
+	// MetricL2 scores by 1/(1+d) for Euclidean distance d, so higher is
+	// still closer.
+	MetricL2 Metric = "l2"
+)
+
+// distance returns a value where smaller is closer. Cosine vectors are
+// normalised on insert, so cosine distance is 1 - dot.
+func (m Metric) distance(a, b []float32) float32 {
+	switch m {
+	case MetricL2:
+		var sum float32
+		for i := range a {
+			d := a[i] - b[i]
+			sum += d * d
+		}
+		return sum
+	case MetricDot:
+		return -dot(a, b)
+	default:
+		return 1 - dot(a, b)
+	}
+}
+
+// score converts a distance back into a similarity for results.
+func (m Metric) score(d float32) float32 {
+	switch m {
+	case MetricL2:
+		return float32(1 / (1 + math.Sqrt(float64(d))))
+	case MetricDot:
+		return -d
+	default:
+		return 1 - d
+	}
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	out := make([]float32, len(v))
+	if norm == 0 {
+		return out
+	}
+	inv := float32(1 / math.Sqrt(norm))
+	for i, x := range v {
+		out[i] = x * inv
+	}
+	return out
+}
+
+// node is a vector in the graph. Deleted nodes stay in place as tombstones
+// so the graph stays connected; they are never returned and are dropped on
+// compaction.
+type node struct {
+	id       string
+	vector   []float32
+	metadata map[string]interface{}
+	level    int
+	friends  [][]uint32 // neighbours per layer, 0 is the densest
+	deleted  bool
+}
+
+// graph is a Hierarchical Navigable Small World index (Malkov & Yashunin,
+// 2016). It is not safe for concurrent use; Store serialises access.
+type graph struct {
+	metric         Metric
+	m              int // links per node above layer 0
+	m0             int // links per node on layer 0
+	efConstruction int
+	levelMult      float64
+	rng            *rand.Rand
+
+	nodes    []*node
+	entry    int // -1 when empty
+	maxLevel int
+}
+
+func newGraph(metric Metric, m, efConstruction int, seed int64) *graph {
+	return &graph{
+		metric:         metric,
+		m:              m,
+		m0:             2 * m,
+		efConstruction: efConstruction,
+		levelMult:      1 / math.Log(float64(m)),
+		rng:            rand.New(rand.NewSource(seed)),
+		entry:          -1,
+	}
+}
+
+func (g *graph) dist(q []float32, i uint32) float32 {
+	return g.metric.distance(q, g.nodes[i].vector)
+}
+
+func (g *graph) randomLevel() int {
+	return int(-math.Log(1-g.rng.Float64()) * g.levelMult)
+}
+
+// insert adds n and links it into every layer up to its level.
+func (g *graph) insert(n *node) uint32 {
+	idx := uint32(len(g.nodes))
+	n.level = g.randomLevel()
+	n.friends = make([][]uint32, n.level+1)
+	g.nodes = append(g.nodes, n)
+	if g.entry < 0 {
+		g.entry, g.maxLevel = int(idx), n.level
+		return idx
+	}
+
+	ep := uint32(g.entry)
+	for l := g.maxLevel; l > n.level; l-- {
+		ep = g.greedy(n.vector, ep, l)
+	}
+	for l := min(n.level, g.maxLevel); l >= 0; l-- {
+		candidates := g.searchLayer(n.vector, ep, g.efConstruction, l, nil)
+		maxConn := g.m
+		if l == 0 {
+			maxConn = g.m0
+		}
+		n.friends[l] = g.selectNeighbors(candidates, maxConn)
+		for _, nb := range n.friends[l] {
+			g.link(nb, idx, l, maxConn)
+		}
+		ep = candidates[0].id
+	}
+	if n.level > g.maxLevel {
+		g.entry, g.maxLevel = int(idx), n.level
+	}
+	return idx
+}
+
+// link adds a back-edge from nb to idx, re-pruning nb's list when full.
+func (g *graph) link(nb, idx uint32, layer, maxConn int) {
+	friends := append(g.nodes[nb].friends[layer], idx)
+	if len(friends) > maxConn {
+		candidates := make([]candidate, len(friends))
+		for i, f := range friends {
+			candidates[i] = candidate{id: f, dist: g.metric.distance(g.nodes[nb].vector, g.nodes[f].vector)}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+		friends = g.selectNeighbors(candidates, maxConn)
+	}
+	g.nodes[nb].friends[layer] = friends
+}
+
+// selectNeighbors applies the HNSW heuristic to candidates sorted by
+// distance: keep a candidate only if it is closer to the base than to any
+// neighbour already kept, which spreads links across directions. Pruned
+// candidates fill any remaining slots.
+func (g *graph) selectNeighbors(candidates []candidate, m int) []uint32 {
+	out := make([]uint32, 0, m)
+	var pruned []uint32
+	for _, c := range candidates {
+		if len(out) >= m {
+			break
+		}
+		keep := true
+		for _, r := range out {
+			if g.metric.distance(g.nodes[c.id].vector, g.nodes[r].vector) < c.dist {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out = append(out, c.id)
+		} else {
+			pruned = append(pruned, c.id)
+		}
+	}
+	for _, p := range pruned {
+		if len(out) >= m {
+			break
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// greedy walks layer towards q from ep and returns the closest node found.
+func (g *graph) greedy(q []float32, ep uint32, layer int) uint32 {
+	best, bestDist := ep, g.dist(q, ep)
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range g.nodes[best].friends[layer] {
+			if d := g.dist(q, nb); d < bestDist {
+				best, bestDist, changed = nb, d, true
+			}
+		}
+	}
+	return best
+}
+
+// searchLayer is the HNSW beam search: it returns up to ef accepted nodes
+// closest to q, nearest first. Every node is traversed, but only those
+// accepted (nil accepts all) enter the results, so filtered and deleted
+// nodes still route the search without crowding out matches.
+func (g *graph) searchLayer(q []float32, ep uint32, ef, layer int, accept func(uint32) bool) []candidate {
+	visited := make([]uint64, (len(g.nodes)+63)/64)
+	visit := func(i uint32) bool {
+		w, b := i/64, uint64(1)<<(i%64)
+		if visited[w]&b != 0 {
+			return false
+		}
+		visited[w] |= b
+		return true
+	}
+
+	d := g.dist(q, ep)
+	visit(ep)
+	toVisit := &minHeap{{id: ep, dist: d}}
+	found := &maxHeap{}
+	if accept == nil || accept(ep) {
+		heap.Push(found, candidate{id: ep, dist: d})
+	}
+
+	for toVisit.Len() > 0 {
+		c := heap.Pop(toVisit).(candidate)
+		if found.Len() >= ef && c.dist > (*found)[0].dist {
+			break
+		}
+		for _, nb := range g.nodes[c.id].friends[layer] {
+			if !visit(nb) {
+				continue
+			}
+			d := g.dist(q, nb)
+			if found.Len() < ef || d < (*found)[0].dist {
+				heap.Push(toVisit, candidate{id: nb, dist: d})
+				if accept == nil || accept(nb) {
+					heap.Push(found, candidate{id: nb, dist: d})
+					if found.Len() > ef {
+						heap.Pop(found)
+					}
+				}
+			}
+		}
+	}
+
+	out := make([]candidate, found.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(found).(candidate)
+	}
+	return out
+}
+
+// search returns up to ef accepted nodes nearest to q.
+func (g *graph) search(q []float32, ef int, accept func(uint32) bool) []candidate {
+	if g.entry < 0 {
+		return nil
+	}
+	ep := uint32(g.entry)
+	for l := g.maxLevel; l > 0; l-- {
+		ep = g.greedy(q, ep, l)
+	}
+	return g.searchLayer(q, ep, ef, 0, accept)
+}
+
+type candidate struct {
+	id   uint32
+	dist float32
+}
+
+type minHeap []candidate
+
+func (h minHeap) Len() int            { return len(h) }
+func (h minHeap) Less(i, j int) bool  { return h[i].dist < h[j].dist }
+func (h minHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *minHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type maxHeap []candidate
+
+func (h maxHeap) Len() int            { return len(h) }
+func (h maxHeap) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h maxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *maxHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
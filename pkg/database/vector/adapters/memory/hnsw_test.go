@@ -0,0 +1,248 @@
+package memory_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomVectors(n, dim int) [][]float32 {
+	rng := rand.New(rand.NewSource(42))
+	out := make([][]float32, n)
+	for i := range out {
+		out[i] = make([]float32, dim)
+		for j := range out[i] {
+			out[i][j] = rng.Float32()*2 - 1
+		}
+	}
+	return out
+}
+
+func ids(results []vector.Result) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = r.ID
+	}
+	return out
+}
+
+// recall compares the graph's top-k against an exact store's.
+func recall(t *testing.T, approx, exact *memory.Store, queries [][]float32, k int, filter map[string]interface{}) float64 {
+	t.Helper()
+	ctx := context.Background()
+	hits, total := 0, 0
+	for _, q := range queries {
+		want, err := exact.SearchWithOpts(ctx, q, vector.SearchOpts{Limit: k, Filter: filter})
+		require.NoError(t, err)
+		got, err := approx.SearchWithOpts(ctx, q, vector.SearchOpts{Limit: k, Filter: filter})
+		require.NoError(t, err)
+		seen := map[string]bool{}
+		for _, id := range ids(got) {
+			seen[id] = true
+		}
+		for _, id := range ids(want) {
+			if seen[id] {
+				hits++
+			}
+		}
+		total += len(want)
+	}
+	return float64(hits) / float64(total)
+}
+
+func buildPair(t *testing.T, metric memory.Metric, vecs [][]float32) (*memory.Store, *memory.Store) {
+	t.Helper()
+	ctx := context.Background()
+	approx := memory.NewWithConfig(memory.Config{Metric: metric, M: 8, EfConstruction: 64, EfSearch: 40, ExactThreshold: -1})
+	exact := memory.NewWithConfig(memory.Config{Metric: metric, ExactThreshold: len(vecs)})
+	for i, v := range vecs {
+		meta := map[string]interface{}{"shard": i % 4}
+		require.NoError(t, approx.Upsert(ctx, fmt.Sprint(i), v, meta))
+		require.NoError(t, exact.Upsert(ctx, fmt.Sprint(i), v, meta))
+	}
+	return approx, exact
+}
+
+func TestHNSWRecall(t *testing.T) {
+	vecs := randomVectors(1000, 16)
+	queries := randomVectors(50, 16)
+	for _, metric := range []memory.Metric{memory.MetricCosine, memory.MetricDot, memory.MetricL2} {
+		t.Run(string(metric), func(t *testing.T) {
+			approx, exact := buildPair(t, metric, vecs)
+			assert.GreaterOrEqual(t, recall(t, approx, exact, queries, 10, nil), 0.9)
+			assert.GreaterOrEqual(t, recall(t, approx, exact, queries, 10, map[string]interface{}{"shard": 1}), 0.9)
+		})
+	}
+}
+
+func TestHNSWScores(t *testing.T) {
+	ctx := context.Background()
+	l2 := memory.NewWithConfig(memory.Config{Metric: memory.MetricL2})
+	require.NoError(t, l2.Upsert(ctx, "a", []float32{3, 4}, nil))
+	res, err := l2.Search(ctx, []float32{0, 0}, 1)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.0/6, res[0].Score, 1e-6)
+
+	dot := memory.NewWithConfig(memory.Config{Metric: memory.MetricDot})
+	require.NoError(t, dot.Upsert(ctx, "a", []float32{3, 4}, nil))
+	res, err = dot.Search(ctx, []float32{1, 2}, 1)
+	require.NoError(t, err)
+	assert.InDelta(t, 11, res[0].Score, 1e-6)
+
+	_, err = dot.Search(ctx, []float32{1, 2, 3}, 1)
+	assert.True(t, errors.IsCode(err, errors.CodeInvalidArgument))
+	err = dot.Upsert(ctx, "b", []float32{1}, nil)
+	assert.True(t, errors.IsCode(err, errors.CodeInvalidArgument))
+}
+
+func TestHNSWTombstones(t *testing.T) {
+	ctx := context.Background()
+	vecs := randomVectors(300, 8)
+	store := memory.NewWithConfig(memory.Config{ExactThreshold: -1})
+	for i, v := range vecs {
+		require.NoError(t, store.Upsert(ctx, fmt.Sprint(i), v, map[string]interface{}{"even": i%2 == 0}))
+	}
+	// Delete every even vector; the odd half must still be reachable.
+	for i := 0; i < len(vecs); i += 2 {
+		require.NoError(t, store.Delete(ctx, fmt.Sprint(i)))
+	}
+	assert.Equal(t, 150, store.Len())
+
+	res, err := store.Search(ctx, vecs[1], 5)
+	require.NoError(t, err)
+	require.Len(t, res, 5)
+	assert.Equal(t, "1", res[0].ID)
+	for _, r := range res {
+		assert.Equal(t, false, r.Metadata["even"])
+	}
+	res, err = store.SearchWithOpts(ctx, vecs[1], vector.SearchOpts{Limit: 5, Filter: map[string]interface{}{"even": true}})
+	require.NoError(t, err)
+	assert.Empty(t, res)
+
+	// Overwrites tombstone the old node and the newest vector wins.
+	require.NoError(t, store.Upsert(ctx, "1", vecs[3], nil))
+	res, err = store.Search(ctx, vecs[3], 2)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1", "3"}, ids(res))
+
+	// Deleting enough triggers compaction without losing live vectors.
+	for i := 5; i < len(vecs); i += 2 {
+		require.NoError(t, store.Delete(ctx, fmt.Sprint(i)))
+	}
+	assert.Equal(t, 2, store.Len())
+	res, err = store.Search(ctx, vecs[3], 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1", "3"}, ids(res))
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	vecs := randomVectors(500, 8)
+	store := memory.NewWithConfig(memory.Config{Metric: memory.MetricL2, ExactThreshold: -1})
+	for i, v := range vecs {
+		require.NoError(t, store.Upsert(ctx, fmt.Sprint(i), v, map[string]interface{}{
+			"n":    i,
+			"tags": []interface{}{"a", "b"},
+		}))
+	}
+	require.NoError(t, store.Delete(ctx, "7"))
+
+	var buf bytes.Buffer
+	require.NoError(t, store.Snapshot(&buf))
+
+	restored := memory.NewWithConfig(memory.Config{ExactThreshold: -1})
+	require.NoError(t, restored.Restore(&buf))
+	assert.Equal(t, store.Len(), restored.Len())
+	for _, q := range vecs[:20] {
+		want, err := store.SearchWithOpts(ctx, q, vector.SearchOpts{Limit: 5, Filter: map[string]interface{}{"n": 8}})
+		require.NoError(t, err)
+		got, err := restored.SearchWithOpts(ctx, q, vector.SearchOpts{Limit: 5, Filter: map[string]interface{}{"n": 8}})
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		want, err = store.Search(ctx, q, 5)
+		require.NoError(t, err)
+		got, err = restored.Search(ctx, q, 5)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	path := filepath.Join(t.TempDir(), "index.gob")
+	require.NoError(t, store.SaveFile(path))
+	fromFile := memory.New()
+	require.NoError(t, fromFile.LoadFile(path))
+	res, err := fromFile.Search(ctx, vecs[9], 1)
+	require.NoError(t, err)
+	assert.Equal(t, "9", res[0].ID)
+	assert.Equal(t, 9, res[0].Metadata["n"])
+
+	err = fromFile.LoadFile(filepath.Join(t.TempDir(), "missing.gob"))
+	assert.True(t, errors.IsCode(err, errors.CodeNotFound))
+	err = fromFile.Restore(bytes.NewReader([]byte("not a snapshot")))
+	assert.True(t, errors.IsCode(err, errors.CodeInvalidArgument))
+}
+
+// rawSnapshot mirrors the snapshot layout so tests can encode corrupt ones.
+type rawSnapshot struct {
+	Version        int
+	Metric         string
+	M              int
+	EfConstruction int
+	Dim            int
+	Entry          int
+	MaxLevel       int
+	Nodes          []rawSnapshotNode
+}
+
+type rawSnapshotNode struct {
+	ID      string
+	Vector  []float32
+	Level   int
+	Friends [][]uint32
+}
+
+func TestRestoreRejectsCorruptSnapshots(t *testing.T) {
+	valid := func() rawSnapshot {
+		return rawSnapshot{
+			Version: 1, Metric: "l2", M: 16, EfConstruction: 200, Dim: 1, Entry: 1, MaxLevel: 1,
+			Nodes: []rawSnapshotNode{
+				{ID: "a", Vector: []float32{0}, Friends: [][]uint32{{1}}},
+				{ID: "b", Vector: []float32{1}, Level: 1, Friends: [][]uint32{{0}, {}}},
+			},
+		}
+	}
+	encode := func(snap rawSnapshot) *bytes.Buffer {
+		var buf bytes.Buffer
+		require.NoError(t, gob.NewEncoder(&buf).Encode(snap))
+		return &buf
+	}
+	require.NoError(t, memory.New().Restore(encode(valid())))
+
+	cases := map[string]func(*rawSnapshot){
+		"M below 2":           func(s *rawSnapshot) { s.M = 1 },
+		"unknown metric":      func(s *rawSnapshot) { s.Metric = "manhattan" },
+		"entry below top":     func(s *rawSnapshot) { s.Entry = 0 },
+		"negative level":      func(s *rawSnapshot) { s.Nodes[0].Level = -1 },
+		"link above level":    func(s *rawSnapshot) { s.Nodes[1].Friends[1] = []uint32{0} },
+		"link out of range":   func(s *rawSnapshot) { s.Nodes[0].Friends[0] = []uint32{2} },
+		"entry with no nodes": func(s *rawSnapshot) { s.Nodes = nil },
+	}
+	for name, corrupt := range cases {
+		t.Run(name, func(t *testing.T) {
+			snap := valid()
+			corrupt(&snap)
+			err := memory.New().Restore(encode(snap))
+			assert.True(t, errors.IsCode(err, errors.CodeInvalidArgument), "got %v", err)
+		})
+	}
+}
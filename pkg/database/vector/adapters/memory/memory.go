@@ -2,7 +2,7 @@ package memory
 
 import (
 	"context"
-	"math"
+	"fmt"
 	"sort"
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
@@ -10,100 +10,263 @@ import (
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 )
 
-type entry struct {
-	id       string
-	vector   []float32
-	metadata map[string]interface{}
+// Config tunes the in-memory HNSW index.
+type Config struct {
+	// Metric is the similarity used for scoring: "cosine", "dot" or "l2".
+	Metric Metric `env:"VECTOR_MEMORY_METRIC" env-default:"cosine"`
+
+	// M is the number of links per node per layer (twice that on the bottom
+	// layer). Higher improves recall at the cost of memory and insert time.
+	M int `env:"VECTOR_MEMORY_M" env-default:"16"`
+
+	// EfConstruction is the candidate list size used when linking a new node.
+	EfConstruction int `env:"VECTOR_MEMORY_EF_CONSTRUCTION" env-default:"200"`
+
+	// EfSearch is the candidate list size used at query time; it is raised to
+	// the requested limit when smaller.
+	EfSearch int `env:"VECTOR_MEMORY_EF_SEARCH" env-default:"64"`
+
+	// ExactThreshold is the candidate count at or below which a search scans
+	// exactly instead of walking the graph. Small stores and selective
+	// filters are both faster and exact this way; negative always uses the
+	// graph.
+	ExactThreshold int `env:"VECTOR_MEMORY_EXACT_THRESHOLD" env-default:"1000"`
+
+	// Seed seeds level assignment so builds are reproducible.
+	Seed int64 `env:"VECTOR_MEMORY_SEED" env-default:"1"`
 }
 
-// Store implements vector.Store with an in-memory store.
+// Store implements vector.Store with an in-memory HNSW index.
+//
+// Deletes and overwrites tombstone the old node, which keeps routing
+// searches until tombstones outnumber live vectors and the graph is rebuilt.
+// Metadata is indexed by value so filtered searches only consider matching
+// vectors; filters beyond equality are evaluated natively.
 type Store struct {
-	entries []entry
-	mu      *concurrency.SmartRWMutex
+	cfg Config
+	mu  *concurrency.SmartRWMutex
+
+	graph    *graph
+	ids      map[string]uint32
+	postings map[string]map[string]map[uint32]struct{} // key -> value -> nodes
+	dim      int
+	deleted  int
 }
 
-// New creates a new in-memory vector store.
+// New creates a new in-memory vector store with default settings.
 func New() *Store {
-	return &Store{
-		entries: make([]entry, 0),
-		mu:      concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "memory-vector"}),
+	return NewWithConfig(Config{})
+}
+
+// NewWithConfig creates a new in-memory vector store. Zero fields take
+// their defaults and an unknown Metric falls back to cosine.
+func NewWithConfig(cfg Config) *Store {
+	switch cfg.Metric {
+	case MetricDot, MetricL2:
+	default:
+		cfg.Metric = MetricCosine
+	}
+	if cfg.M < 2 {
+		cfg.M = 16
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = 200
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = 64
+	}
+	if cfg.ExactThreshold == 0 {
+		cfg.ExactThreshold = 1000
 	}
+	if cfg.Seed == 0 {
+		cfg.Seed = 1
+	}
+	s := &Store{
+		cfg: cfg,
+		mu:  concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "memory-vector"}),
+	}
+	s.reset()
+	return s
+}
+
+func (s *Store) reset() {
+	s.graph = newGraph(s.cfg.Metric, s.cfg.M, s.cfg.EfConstruction, s.cfg.Seed)
+	s.ids = make(map[string]uint32)
+	s.postings = make(map[string]map[string]map[uint32]struct{})
+	s.dim = 0
+	s.deleted = 0
 }
 
-// Search finds the nearest neighbors using cosine similarity.
+// Search finds the nearest neighbors to queryVector.
 func (s *Store) Search(ctx context.Context, queryVector []float32, limit int) ([]vector.Result, error) {
 	return s.SearchWithOpts(ctx, queryVector, vector.SearchOpts{Limit: limit})
 }
 
-// SearchWithOpts finds nearest neighbors with optional metadata equality filter.
+// SearchWithOpts finds nearest neighbors with optional metadata filter.
 func (s *Store) SearchWithOpts(ctx context.Context, queryVector []float32, opts vector.SearchOpts) ([]vector.Result, error) {
+	expr, err := opts.Expr()
+	if err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if len(s.entries) == 0 {
+	if len(s.ids) == 0 {
 		return []vector.Result{}, nil
 	}
+	if len(queryVector) != s.dim {
+		return nil, errors.InvalidArgument(fmt.Sprintf("query vector has dimension %d, store has %d", len(queryVector), s.dim), nil)
+	}
 
 	limit := opts.Limit
 	if limit <= 0 {
 		limit = 10
 	}
+	q := s.prepare(queryVector)
+
+	var found []candidate
+	if expr == nil {
+		found = s.searchAll(q, limit)
+	} else {
+		found = s.searchFiltered(q, limit, expr)
+	}
+
+	if limit > len(found) {
+		limit = len(found)
+	}
+	output := make([]vector.Result, limit)
+	for i := 0; i < limit; i++ {
+		n := s.graph.nodes[found[i].id]
+		output[i] = vector.Result{
+			ID:       n.id,
+			Score:    s.cfg.Metric.score(found[i].dist),
+			Metadata: cloneMetadata(n.metadata),
+		}
+	}
+	return output, nil
+}
 
-	type scored struct {
-		entry entry
-		score float32
+func (s *Store) searchAll(q []float32, limit int) []candidate {
+	live := len(s.ids)
+	if live <= s.cfg.ExactThreshold {
+		nodes := make([]uint32, 0, live)
+		for _, idx := range s.ids {
+			nodes = append(nodes, idx)
+		}
+		return s.exact(q, nodes)
 	}
+	// Tombstones occupy beam slots on the way, so widen by their share.
+	ef := max(s.cfg.EfSearch, limit) * len(s.graph.nodes) / live
+	return s.graph.search(q, ef, func(i uint32) bool { return !s.graph.nodes[i].deleted })
+}
 
-	results := make([]scored, 0, len(s.entries))
-	for _, e := range s.entries {
-		if !vector.MatchFilter(e.metadata, opts.Filter) {
-			continue
+// searchFiltered pre-filters with the metadata postings: equality
+// conditions narrow the candidates, which are then checked against the full
+// expression. Selective filters are scanned exactly; broad ones walk the
+// graph accepting only matches, with the beam widened by the inverse
+// selectivity so recall holds.
+func (s *Store) searchFiltered(q []float32, limit int, expr *vector.FilterExpr) []candidate {
+	match := make(map[uint32]struct{})
+	for idx := range s.candidates(expr.Equalities()) {
+		if expr.Match(s.graph.nodes[idx].metadata) {
+			match[idx] = struct{}{}
 		}
-		score := cosineSimilarity(queryVector, e.vector)
-		results = append(results, scored{entry: e, score: score})
+	}
+	if len(match) == 0 {
+		return nil
 	}
 
-	// Sort by score descending
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].score > results[j].score
+	if len(match) <= s.cfg.ExactThreshold {
+		nodes := make([]uint32, 0, len(match))
+		for idx := range match {
+			nodes = append(nodes, idx)
+		}
+		return s.exact(q, nodes)
+	}
+	ef := min(max(s.cfg.EfSearch, limit)*len(s.graph.nodes)/len(match), len(s.graph.nodes))
+	return s.graph.search(q, ef, func(i uint32) bool {
+		_, ok := match[i]
+		return ok
 	})
+}
 
-	if limit > len(results) {
-		limit = len(results)
+// candidates returns the live nodes holding every value in eq, or all live
+// nodes when eq is empty.
+func (s *Store) candidates(eq map[string]interface{}) map[uint32]struct{} {
+	if len(eq) == 0 {
+		all := make(map[uint32]struct{}, len(s.ids))
+		for _, idx := range s.ids {
+			all[idx] = struct{}{}
+		}
+		return all
+	}
+	sets := make([]map[uint32]struct{}, 0, len(eq))
+	for k, want := range eq {
+		set := s.postings[k][fmt.Sprint(want)]
+		if len(set) == 0 {
+			return nil
+		}
+		sets = append(sets, set)
 	}
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
 
-	output := make([]vector.Result, limit)
-	for i := 0; i < limit; i++ {
-		output[i] = vector.Result{
-			ID:       results[i].entry.id,
-			Score:    results[i].score,
-			Metadata: cloneMetadata(results[i].entry.metadata),
+	out := make(map[uint32]struct{}, len(sets[0]))
+	for idx := range sets[0] {
+		ok := true
+		for _, set := range sets[1:] {
+			if _, ok = set[idx]; !ok {
+				break
+			}
+		}
+		if ok {
+			out[idx] = struct{}{}
 		}
 	}
+	return out
+}
 
-	return output, nil
+// exact scores nodes by brute force, nearest first, ties in insertion order.
+func (s *Store) exact(q []float32, nodes []uint32) []candidate {
+	out := make([]candidate, len(nodes))
+	for i, idx := range nodes {
+		out[i] = candidate{id: idx, dist: s.graph.dist(q, idx)}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].dist != out[j].dist {
+			return out[i].dist < out[j].dist
+		}
+		return out[i].id < out[j].id
+	})
+	return out
+}
+
+// prepare copies v, normalising it for cosine so distance is 1 - dot.
+func (s *Store) prepare(v []float32) []float32 {
+	if s.cfg.Metric == MetricCosine {
+		return normalize(v)
+	}
+	return cloneVector(v)
 }
 
-// Upsert inserts or updates a vector.
+// Upsert inserts or updates a vector. All vectors in a store must share a
+// dimension.
 func (s *Store) Upsert(ctx context.Context, id string, vec []float32, metadata map[string]interface{}) error {
+	if len(vec) == 0 {
+		return errors.InvalidArgument("vector is required", nil)
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Check if ID exists, update if so
-	for i, e := range s.entries {
-		if e.id == id {
-			s.entries[i].vector = cloneVector(vec)
-			s.entries[i].metadata = cloneMetadata(metadata)
-			return nil
-		}
+	if len(s.ids) > 0 && len(vec) != s.dim {
+		return errors.InvalidArgument(fmt.Sprintf("vector has dimension %d, store has %d", len(vec), s.dim), nil)
 	}
-
-	// Insert new
-	s.entries = append(s.entries, entry{
-		id:       id,
-		vector:   cloneVector(vec),
-		metadata: cloneMetadata(metadata),
-	})
+	if old, ok := s.ids[id]; ok {
+		s.tombstone(old)
+	}
+	s.dim = len(vec)
+	s.add(&node{id: id, vector: s.prepare(vec), metadata: cloneMetadata(metadata)})
+	s.maybeCompact()
 	return nil
 }
 
@@ -112,41 +275,123 @@ func (s *Store) Delete(ctx context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for i, e := range s.entries {
-		if e.id == id {
-			s.entries = append(s.entries[:i], s.entries[i+1:]...)
-			return nil
+	idx, ok := s.ids[id]
+	if !ok {
+		return errors.NotFound("vector not found", nil)
+	}
+	s.tombstone(idx)
+	s.maybeCompact()
+	return nil
+}
+
+// Scan calls fn for every live vector's ID and metadata, in ID order. fn
+// runs without the store locked and gets its own copy of the metadata.
+func (s *Store) Scan(ctx context.Context, fn func(id string, metadata map[string]interface{}) error) error {
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.ids))
+	metadata := make(map[string]map[string]interface{}, len(s.ids))
+	for id, idx := range s.ids {
+		ids = append(ids, id)
+		metadata[id] = cloneMetadata(s.graph.nodes[idx].metadata)
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(id, metadata[id]); err != nil {
+			return err
 		}
 	}
-	return errors.NotFound("vector not found", nil)
+	return nil
+}
+
+// Compact rebuilds the graph without tombstones. It runs automatically
+// once tombstones outnumber live vectors.
+func (s *Store) Compact() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compact()
+}
+
+// Len returns the number of live vectors.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.ids)
 }
 
 // Close clears the in-memory store.
 func (s *Store) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.entries = nil
+	s.reset()
 	return nil
 }
 
-// cosineSimilarity calculates the cosine similarity between two vectors.
-func cosineSimilarity(a, b []float32) float32 {
-	if len(a) != len(b) {
-		return 0
+func (s *Store) add(n *node) {
+	idx := s.graph.insert(n)
+	s.ids[n.id] = idx
+	s.indexMetadata(idx, n.metadata)
+}
+
+func (s *Store) indexMetadata(idx uint32, metadata map[string]interface{}) {
+	for k, v := range metadata {
+		values, ok := s.postings[k]
+		if !ok {
+			values = make(map[string]map[uint32]struct{})
+			s.postings[k] = values
+		}
+		key := fmt.Sprint(v)
+		set, ok := values[key]
+		if !ok {
+			set = make(map[uint32]struct{})
+			values[key] = set
+		}
+		set[idx] = struct{}{}
 	}
+}
 
-	var dotProduct, normA, normB float64
-	for i := range a {
-		dotProduct += float64(a[i]) * float64(b[i])
-		normA += float64(a[i]) * float64(a[i])
-		normB += float64(b[i]) * float64(b[i])
+func (s *Store) tombstone(idx uint32) {
+	n := s.graph.nodes[idx]
+	n.deleted = true
+	delete(s.ids, n.id)
+	for k, v := range n.metadata {
+		key := fmt.Sprint(v)
+		delete(s.postings[k][key], idx)
+		if len(s.postings[k][key]) == 0 {
+			delete(s.postings[k], key)
+		}
+		if len(s.postings[k]) == 0 {
+			delete(s.postings, k)
+		}
 	}
+	s.deleted++
+}
 
-	if normA == 0 || normB == 0 {
-		return 0
+func (s *Store) maybeCompact() {
+	if s.deleted > len(s.ids) {
+		s.compact()
 	}
+}
 
-	return float32(dotProduct / (math.Sqrt(normA) * math.Sqrt(normB)))
+func (s *Store) compact() {
+	if s.deleted == 0 {
+		return
+	}
+	nodes := s.graph.nodes
+	dim := s.dim
+	s.reset()
+	for _, n := range nodes {
+		if !n.deleted {
+			s.add(&node{id: n.id, vector: n.vector, metadata: n.metadata})
+		}
+	}
+	if len(s.ids) > 0 {
+		s.dim = dim
+	}
 }
 
 func cloneVector(v []float32) []float32 {
@@ -166,5 +411,8 @@ func cloneMetadata(m map[string]interface{}) map[string]interface{} {
 	return c
 }
 
-// Ensure Store implements vector.Store
-var _ vector.Store = (*Store)(nil)
+// Ensure Store implements vector.Store and vector.Scanner
+var (
+	_ vector.Store   = (*Store)(nil)
+	_ vector.Scanner = (*Store)(nil)
+)
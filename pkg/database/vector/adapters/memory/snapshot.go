@@ -0,0 +1,195 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+// snapshotVersion is bumped when the snapshot layout changes.
+const snapshotVersion = 1
+
+func init() {
+	// Metadata values are interfaces; gob needs the composite types that
+	// JSON-shaped metadata commonly holds registered up front.
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+type snapshot struct {
+	Version        int
+	Metric         Metric
+	M              int
+	EfConstruction int
+	Dim            int
+	Entry          int
+	MaxLevel       int
+	Nodes          []snapshotNode
+}
+
+type snapshotNode struct {
+	ID       string
+	Vector   []float32
+	Metadata map[string]interface{}
+	Level    int
+	Friends  [][]uint32
+	Deleted  bool
+}
+
+// Snapshot writes the index, graph links included, to w so Restore can
+// load it without rebuilding. Metadata values must be gob-encodable; types
+// other than basic values, map[string]interface{} and []interface{} need
+// gob.Register.
+func (s *Store) Snapshot(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := snapshot{
+		Version:        snapshotVersion,
+		Metric:         s.cfg.Metric,
+		M:              s.cfg.M,
+		EfConstruction: s.cfg.EfConstruction,
+		Dim:            s.dim,
+		Entry:          s.graph.entry,
+		MaxLevel:       s.graph.maxLevel,
+		Nodes:          make([]snapshotNode, len(s.graph.nodes)),
+	}
+	for i, n := range s.graph.nodes {
+		snap.Nodes[i] = snapshotNode{
+			ID:       n.id,
+			Vector:   n.vector,
+			Metadata: n.metadata,
+			Level:    n.level,
+			Friends:  n.friends,
+			Deleted:  n.deleted,
+		}
+	}
+	if err := gob.NewEncoder(w).Encode(&snap); err != nil {
+		return errors.Internal("failed to encode vector snapshot", err)
+	}
+	return nil
+}
+
+// Restore replaces the store's contents with a snapshot read from r. The
+// snapshot's Metric, M and EfConstruction replace the store's, since the
+// graph was built with them; search settings are kept.
+func (s *Store) Restore(r io.Reader) error {
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return errors.InvalidArgument("failed to decode vector snapshot", err)
+	}
+	if snap.Version != snapshotVersion {
+		return errors.InvalidArgument(fmt.Sprintf("unsupported vector snapshot version %d", snap.Version), nil)
+	}
+	if err := snap.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg.Metric, s.cfg.M, s.cfg.EfConstruction = snap.Metric, snap.M, snap.EfConstruction
+	s.reset()
+	s.dim = snap.Dim
+	s.graph.entry, s.graph.maxLevel = snap.Entry, snap.MaxLevel
+	s.graph.nodes = make([]*node, len(snap.Nodes))
+	for i, sn := range snap.Nodes {
+		n := &node{
+			id:       sn.ID,
+			vector:   sn.Vector,
+			metadata: sn.Metadata,
+			level:    sn.Level,
+			friends:  sn.Friends,
+			deleted:  sn.Deleted,
+		}
+		s.graph.nodes[i] = n
+		if n.deleted {
+			s.deleted++
+			continue
+		}
+		s.ids[n.id] = uint32(i)
+		s.indexMetadata(uint32(i), n.metadata)
+	}
+	return nil
+}
+
+// validate checks that the snapshot describes a graph the store can search
+// without indexing out of range, and build settings New would accept.
+func (snap *snapshot) validate() error {
+	switch snap.Metric {
+	case MetricCosine, MetricDot, MetricL2:
+	default:
+		return errors.InvalidArgument(fmt.Sprintf("corrupt vector snapshot: unknown metric %q", snap.Metric), nil)
+	}
+	if snap.M < 2 || snap.EfConstruction <= 0 {
+		return errors.InvalidArgument("corrupt vector snapshot: M must be at least 2 and EfConstruction positive", nil)
+	}
+	if snap.Entry < -1 || snap.Entry >= len(snap.Nodes) || (snap.Entry == -1) != (len(snap.Nodes) == 0) {
+		return errors.InvalidArgument("corrupt vector snapshot: entry point out of range", nil)
+	}
+	if snap.Entry >= 0 && snap.Nodes[snap.Entry].Level != snap.MaxLevel {
+		return errors.InvalidArgument("corrupt vector snapshot: entry point is not on the top layer", nil)
+	}
+	for i, n := range snap.Nodes {
+		if n.Level < 0 || len(n.Vector) != snap.Dim || len(n.Friends) != n.Level+1 {
+			return errors.InvalidArgument("corrupt vector snapshot: node "+n.ID, nil)
+		}
+		for layer, friends := range n.Friends {
+			for _, f := range friends {
+				// A link on a layer must lead to a node present on it.
+				if int(f) >= len(snap.Nodes) || int(f) == i || snap.Nodes[f].Level < layer {
+					return errors.InvalidArgument("corrupt vector snapshot: bad link from node "+n.ID, nil)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// SaveFile writes a snapshot to path, replacing it atomically.
+func (s *Store) SaveFile(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return errors.Internal("failed to create vector snapshot", err)
+	}
+	w := bufio.NewWriter(f)
+	if err := s.Snapshot(w); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return errors.Internal("failed to write vector snapshot", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return errors.Internal("failed to commit vector snapshot", err)
+	}
+	return nil
+}
+
+// LoadFile restores a snapshot written by SaveFile.
+func (s *Store) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.NotFound("vector snapshot not found: "+path, err)
+		}
+		return errors.Internal("failed to open vector snapshot", err)
+	}
+	defer f.Close()
+	return s.Restore(bufio.NewReader(f))
+}
@@ -0,0 +1,111 @@
+package milvus
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+var fieldName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var comparisons = map[vector.Op]string{
+	vector.OpEq:  "==",
+	vector.OpNe:  "!=",
+	vector.OpGt:  ">",
+	vector.OpGte: ">=",
+	vector.OpLt:  "<",
+	vector.OpLte: "<=",
+}
+
+// translateFilter renders e as a Milvus boolean expression. Milvus has no
+// test for a missing dynamic field, so $exists is rejected.
+func translateFilter(e *vector.FilterExpr) (string, error) {
+	switch e.Op {
+	case vector.OpAnd, vector.OpOr:
+		join := " && "
+		if e.Op == vector.OpOr {
+			join = " || "
+		}
+		parts := make([]string, len(e.Exprs))
+		for i, sub := range e.Exprs {
+			p, err := translateFilter(sub)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = p
+		}
+		if len(parts) == 1 {
+			return parts[0], nil
+		}
+		return "(" + strings.Join(parts, join) + ")", nil
+	case vector.OpExists:
+		return "", vector.UnsupportedFilter("milvus", e, "dynamic fields cannot be tested for presence")
+	}
+
+	if !fieldName.MatchString(e.Field) {
+		return "", errors.InvalidArgument(fmt.Sprintf("milvus filter field %q is not a valid identifier", e.Field), nil)
+	}
+	switch e.Op {
+	case vector.OpIn, vector.OpNin:
+		items, _ := e.Value.([]interface{})
+		lits := make([]string, len(items))
+		for i, item := range items {
+			lit, err := literal(e, item)
+			if err != nil {
+				return "", err
+			}
+			lits[i] = lit
+		}
+		op := "in"
+		if e.Op == vector.OpNin {
+			op = "not in"
+		}
+		return fmt.Sprintf("%s %s [%s]", e.Field, op, strings.Join(lits, ", ")), nil
+	case vector.OpContains:
+		lit, err := literal(e, e.Value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("array_contains(%s, %s)", e.Field, lit), nil
+	}
+	cmp, ok := comparisons[e.Op]
+	if !ok {
+		return "", vector.UnsupportedFilter("milvus", e, "")
+	}
+	lit, err := literal(e, e.Value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", e.Field, cmp, lit), nil
+}
+
+// literal renders a scalar operand. Times become RFC 3339 strings, which
+// order correctly when stored that way.
+func literal(e *vector.FilterExpr, v interface{}) (string, error) {
+	switch x := v.(type) {
+	case string:
+		return strconv.Quote(x), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case time.Time:
+		return strconv.Quote(x.UTC().Format(time.RFC3339Nano)), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.IsValid() {
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return strconv.FormatInt(rv.Int(), 10), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return strconv.FormatUint(rv.Uint(), 10), nil
+		case reflect.Float32, reflect.Float64:
+			return strconv.FormatFloat(rv.Float(), 'g', -1, 64), nil
+		}
+	}
+	return "", vector.UnsupportedFilter("milvus", e, fmt.Sprintf("operand of type %T", v))
+}
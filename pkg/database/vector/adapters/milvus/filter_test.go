@@ -0,0 +1,46 @@
+package milvus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+func TestTranslateFilter(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		expr *vector.FilterExpr
+		want string
+	}{
+		{vector.Eq("source", `say "hi"`), `source == "say \"hi\""`},
+		{vector.Gt("price", 9.5), `price > 9.5`},
+		{vector.Lte("published", day), `published <= "2024-03-01T00:00:00Z"`},
+		{vector.In("tier", 1, 2), `tier in [1, 2]`},
+		{vector.Nin("lang", "fr"), `lang not in ["fr"]`},
+		{vector.Contains("tags", "go"), `array_contains(tags, "go")`},
+		{
+			vector.And(vector.Eq("lang", "en"), vector.Or(vector.Ne("draft", true), vector.Gte("stock", 1))),
+			`(lang == "en" && (draft != true || stock >= 1))`,
+		},
+	}
+	for _, tc := range cases {
+		got, err := translateFilter(tc.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.expr, got, tc.want)
+		}
+	}
+
+	_, err := translateFilter(vector.Exists("tags", true))
+	if !errors.Is(err, vector.ErrUnsupportedFilter) {
+		t.Errorf("expected unsupported filter, got %v", err)
+	}
+	_, err = translateFilter(vector.Eq("id == id ||", 1))
+	if !errors.IsCode(err, errors.CodeInvalidArgument) {
+		t.Errorf("expected invalid field, got %v", err)
+	}
+}
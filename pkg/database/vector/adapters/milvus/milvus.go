@@ -55,7 +55,8 @@ func (s *Store) Search(ctx context.Context, queryVector []float32, limit int) ([
 	return s.SearchWithOpts(ctx, queryVector, vector.SearchOpts{Limit: limit})
 }
 
-// SearchWithOpts finds nearest neighbors with optional metadata filter.
+// SearchWithOpts finds nearest neighbors with optional metadata filter,
+// translated to a Milvus boolean expression.
 func (s *Store) SearchWithOpts(ctx context.Context, queryVector []float32, opts vector.SearchOpts) ([]vector.Result, error) {
 	limit := opts.Limit
 	if limit <= 0 {
@@ -68,12 +69,16 @@ func (s *Store) SearchWithOpts(ctx context.Context, queryVector []float32, opts
 		"limit":          limit,
 		"outputFields":   []string{"*"},
 	}
-	if len(opts.Filter) > 0 {
-		parts := make([]string, 0, len(opts.Filter))
-		for k, v := range opts.Filter {
-			parts = append(parts, fmt.Sprintf(`%s == %q`, k, fmt.Sprint(v)))
+	expr, err := opts.Expr()
+	if err != nil {
+		return nil, err
+	}
+	if expr != nil {
+		filter, err := translateFilter(expr)
+		if err != nil {
+			return nil, err
 		}
-		body["filter"] = strings.Join(parts, " && ")
+		body["filter"] = filter
 	}
 
 	resp, err := s.doRequest(ctx, "POST", s.baseURL+"/v2/vectordb/entities/search", body)
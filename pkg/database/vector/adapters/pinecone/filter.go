@@ -0,0 +1,92 @@
+package pinecone
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector"
+)
+
+// translateFilter renders e in Pinecone's metadata filter language, which
+// is MongoDB-style already. Pinecone ranges only accept numbers, so dates
+// must be stored as Unix timestamps to be range-filtered; list membership
+// ($contains) maps to $in on the list field.
+func translateFilter(e *vector.FilterExpr) (map[string]interface{}, error) {
+	switch e.Op {
+	case vector.OpAnd, vector.OpOr:
+		subs := make([]interface{}, len(e.Exprs))
+		for i, sub := range e.Exprs {
+			t, err := translateFilter(sub)
+			if err != nil {
+				return nil, err
+			}
+			subs[i] = t
+		}
+		return map[string]interface{}{string(e.Op): subs}, nil
+	case vector.OpExists:
+		return field(e, string(e.Op), e.Value), nil
+	case vector.OpContains:
+		v, err := scalar(e, e.Value)
+		if err != nil {
+			return nil, err
+		}
+		return field(e, string(vector.OpIn), []interface{}{v}), nil
+	case vector.OpIn, vector.OpNin:
+		items, _ := e.Value.([]interface{})
+		vs := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := scalar(e, item)
+			if err != nil {
+				return nil, err
+			}
+			vs[i] = v
+		}
+		return field(e, string(e.Op), vs), nil
+	case vector.OpGt, vector.OpGte, vector.OpLt, vector.OpLte:
+		if !isNumber(e.Value) {
+			return nil, vector.UnsupportedFilter("pinecone", e, fmt.Sprintf("ranges need a number, got %T", e.Value))
+		}
+		return field(e, string(e.Op), e.Value), nil
+	case vector.OpEq, vector.OpNe:
+		v, err := scalar(e, e.Value)
+		if err != nil {
+			return nil, err
+		}
+		return field(e, string(e.Op), v), nil
+	}
+	return nil, vector.UnsupportedFilter("pinecone", e, "")
+}
+
+func field(e *vector.FilterExpr, op string, v interface{}) map[string]interface{} {
+	return map[string]interface{}{e.Field: map[string]interface{}{op: v}}
+}
+
+// scalar checks v is a type Pinecone metadata can hold. Times are sent as
+// RFC 3339 strings, which is enough for equality.
+func scalar(e *vector.FilterExpr, v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case string, bool:
+		return x, nil
+	case time.Time:
+		return x.UTC().Format(time.RFC3339Nano), nil
+	}
+	if isNumber(v) {
+		return v, nil
+	}
+	return nil, vector.UnsupportedFilter("pinecone", e, fmt.Sprintf("operand of type %T", v))
+}
+
+func isNumber(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return false
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
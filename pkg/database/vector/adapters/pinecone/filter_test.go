@@ -0,0 +1,37 @@
+package pinecone
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+func TestTranslateFilter(t *testing.T) {
+	expr := vector.And(
+		vector.Eq("lang", "en"),
+		vector.Or(vector.Contains("tags", "go"), vector.Exists("draft", false)),
+		vector.Gte("price", 10),
+		vector.Nin("tier", 3, 4),
+	)
+	got, err := translateFilter(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(got)
+	want := `{"$and":[{"lang":{"$eq":"en"}},{"$or":[{"tags":{"$in":["go"]}},{"draft":{"$exists":false}}]},{"price":{"$gte":10}},{"tier":{"$nin":[3,4]}}]}`
+	if string(b) != want {
+		t.Errorf("got %s\nwant %s", b, want)
+	}
+
+	_, err = translateFilter(vector.Gt("published", time.Now()))
+	if !errors.Is(err, vector.ErrUnsupportedFilter) {
+		t.Errorf("expected unsupported filter for date range, got %v", err)
+	}
+	_, err = translateFilter(vector.Eq("attrs", map[string]interface{}{"a": 1}))
+	if !errors.Is(err, vector.ErrUnsupportedFilter) {
+		t.Errorf("expected unsupported filter for object operand, got %v", err)
+	}
+}
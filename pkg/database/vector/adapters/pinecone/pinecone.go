@@ -57,8 +57,16 @@ func (s *Store) SearchWithOpts(ctx context.Context, queryVector []float32, opts
 		"topK":            limit,
 		"includeMetadata": true,
 	}
-	if len(opts.Filter) > 0 {
-		reqBody["filter"] = opts.Filter
+	expr, err := opts.Expr()
+	if err != nil {
+		return nil, err
+	}
+	if expr != nil {
+		filter, err := translateFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		reqBody["filter"] = filter
 	}
 
 	resp, err := s.doRequest(ctx, "POST", url, reqBody)
@@ -0,0 +1,114 @@
+package weaviate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+var propertyName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var operators = map[vector.Op]string{
+	vector.OpEq:  "Equal",
+	vector.OpNe:  "NotEqual",
+	vector.OpGt:  "GreaterThan",
+	vector.OpGte: "GreaterThanEqual",
+	vector.OpLt:  "LessThan",
+	vector.OpLte: "LessThanEqual",
+}
+
+// translateFilter renders e as a GraphQL where filter. $in and $nin expand
+// to Or/And over single comparisons, $contains maps to ContainsAny and
+// $exists to IsNull, which needs indexNullState on the class.
+func translateFilter(e *vector.FilterExpr) (string, error) {
+	switch e.Op {
+	case vector.OpAnd, vector.OpOr:
+		if len(e.Exprs) == 1 {
+			return translateFilter(e.Exprs[0])
+		}
+		parts := make([]string, len(e.Exprs))
+		for i, sub := range e.Exprs {
+			p, err := translateFilter(sub)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = p
+		}
+		op := "And"
+		if e.Op == vector.OpOr {
+			op = "Or"
+		}
+		return "{operator:" + op + " operands:[" + strings.Join(parts, ",") + "]}", nil
+	case vector.OpIn, vector.OpNin:
+		items, _ := e.Value.([]interface{})
+		subs := make([]*vector.FilterExpr, len(items))
+		for i, item := range items {
+			if e.Op == vector.OpIn {
+				subs[i] = vector.Eq(e.Field, item)
+			} else {
+				subs[i] = vector.Ne(e.Field, item)
+			}
+		}
+		if e.Op == vector.OpIn {
+			return translateFilter(vector.Or(subs...))
+		}
+		return translateFilter(vector.And(subs...))
+	}
+
+	if !propertyName.MatchString(e.Field) {
+		return "", errors.InvalidArgument(fmt.Sprintf("weaviate filter property %q is not a valid name", e.Field), nil)
+	}
+	path := strconv.Quote(e.Field)
+	switch e.Op {
+	case vector.OpExists:
+		exists, _ := e.Value.(bool)
+		return fmt.Sprintf("{path:[%s] operator:IsNull valueBoolean:%t}", path, !exists), nil
+	case vector.OpContains:
+		kind, lit, err := value(e, e.Value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("{path:[%s] operator:ContainsAny %s:[%s]}", path, kind, lit), nil
+	}
+	op, ok := operators[e.Op]
+	if !ok {
+		return "", vector.UnsupportedFilter("weaviate", e, "")
+	}
+	kind, lit, err := value(e, e.Value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("{path:[%s] operator:%s %s:%s}", path, op, kind, lit), nil
+}
+
+// value returns the typed value field and GraphQL literal for v.
+func value(e *vector.FilterExpr, v interface{}) (string, string, error) {
+	switch x := v.(type) {
+	case string:
+		b, _ := json.Marshal(x)
+		return "valueText", string(b), nil
+	case bool:
+		return "valueBoolean", strconv.FormatBool(x), nil
+	case time.Time:
+		return "valueDate", strconv.Quote(x.UTC().Format(time.RFC3339Nano)), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.IsValid() {
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return "valueInt", strconv.FormatInt(rv.Int(), 10), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return "valueInt", strconv.FormatUint(rv.Uint(), 10), nil
+		case reflect.Float32, reflect.Float64:
+			return "valueNumber", strconv.FormatFloat(rv.Float(), 'g', -1, 64), nil
+		}
+	}
+	return "", "", vector.UnsupportedFilter("weaviate", e, fmt.Sprintf("operand of type %T", v))
+}
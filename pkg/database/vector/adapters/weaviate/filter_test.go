@@ -0,0 +1,49 @@
+package weaviate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+func TestTranslateFilter(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		expr *vector.FilterExpr
+		want string
+	}{
+		{vector.Eq("source", "docs"), `{path:["source"] operator:Equal valueText:"docs"}`},
+		{vector.Gt("price", 9.5), `{path:["price"] operator:GreaterThan valueNumber:9.5}`},
+		{vector.Lt("published", day), `{path:["published"] operator:LessThan valueDate:"2024-03-01T00:00:00Z"}`},
+		{vector.Exists("draft", true), `{path:["draft"] operator:IsNull valueBoolean:false}`},
+		{vector.Contains("tags", "go"), `{path:["tags"] operator:ContainsAny valueText:["go"]}`},
+		{
+			vector.In("tier", 1, 2),
+			`{operator:Or operands:[{path:["tier"] operator:Equal valueInt:1},{path:["tier"] operator:Equal valueInt:2}]}`,
+		},
+		{
+			vector.And(vector.Ne("lang", "fr"), vector.Eq("draft", false)),
+			`{operator:And operands:[{path:["lang"] operator:NotEqual valueText:"fr"},{path:["draft"] operator:Equal valueBoolean:false}]}`,
+		},
+	}
+	for _, tc := range cases {
+		got, err := translateFilter(tc.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tc.expr, got, tc.want)
+		}
+	}
+
+	_, err := translateFilter(vector.Eq("tags", []string{"a"}))
+	if !errors.Is(err, vector.ErrUnsupportedFilter) {
+		t.Errorf("expected unsupported filter, got %v", err)
+	}
+	_, err = translateFilter(vector.Eq(`x"] operator`, 1))
+	if !errors.IsCode(err, errors.CodeInvalidArgument) {
+		t.Errorf("expected invalid property, got %v", err)
+	}
+}
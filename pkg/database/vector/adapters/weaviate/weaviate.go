@@ -56,17 +56,17 @@ func (s *Store) SearchWithOpts(ctx context.Context, queryVector []float32, opts
 		limit = 10
 	}
 
+	expr, err := opts.Expr()
+	if err != nil {
+		return nil, err
+	}
 	whereClause := ""
-	if len(opts.Filter) > 0 {
-		parts := make([]string, 0, len(opts.Filter))
-		for k, v := range opts.Filter {
-			parts = append(parts, fmt.Sprintf(`{path:["%s"] operator:Equal valueText:%q}`, k, fmt.Sprint(v)))
-		}
-		if len(parts) == 1 {
-			whereClause = "where: " + parts[0]
-		} else {
-			whereClause = "where: {operator:And operands:[" + strings.Join(parts, ",") + "]}"
+	if expr != nil {
+		where, err := translateFilter(expr)
+		if err != nil {
+			return nil, err
 		}
+		whereClause = "where: " + where
 	}
 
 	// GraphQL nearVector query. Properties include metadata fields plus _additional.
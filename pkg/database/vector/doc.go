@@ -1,7 +1,27 @@
 /*
 Package vector provides vector database capabilities and search utilities.
 
-Stores implement Search and SearchWithOpts (metadata equality filter).
-Adapters: memory, pinecone, weaviate.
+Stores implement Search and SearchWithOpts. Metadata filters are a portable
+FilterExpr tree ($eq, $ne, $gt/$gte/$lt/$lte, $in/$nin, $exists, $contains
+on lists, nested $and/$or), built with Eq, Gt, And, ... or parsed from a
+MongoDB-style map with ParseFilter. The memory adapter evaluates filters
+natively; remote adapters translate them and return ErrUnsupportedFilter
+for operators their backend cannot express.
+
+HybridSearch runs a vector search and a BM25 keyword search independently
+and fuses them by weighted normalised scores or reciprocal-rank fusion.
+Wrap a store with NewKeywordStore to keep a KeywordIndex in sync so that
+keyword-only matches are found. The KeywordIndex lives in memory: after a restart,
+call KeywordStore.Rebuild (for stores implementing Scanner, such as the
+memory adapter) or backfill it with Index before serving, or keyword search
+misses everything written earlier.
+
+Adapters: memory, milvus, pinecone, weaviate.
+
+The memory adapter indexes vectors in an HNSW graph (cosine, dot or L2)
+with tunable M, EfConstruction and EfSearch. Small stores and selective
+metadata filters are scanned exactly; deletes are tombstoned until the
+graph is compacted, and Snapshot/Restore or SaveFile/LoadFile persist the
+index without rebuilding it.
 */
 package vector
@@ -15,4 +15,8 @@ var (
 
 	// ErrInvalidDimension is returned when vector dimensions don't match.
 	ErrInvalidDimension = errors.New(errors.CodeInvalidArgument, "invalid vector dimension", nil)
+
+	// ErrUnsupportedFilter is wrapped by errors for filter operators a backend
+	// cannot express.
+	ErrUnsupportedFilter = errors.New(errors.CodeUnimplemented, "unsupported vector filter", nil)
 )
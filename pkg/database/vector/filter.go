@@ -0,0 +1,507 @@
+package vector
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+// Op is a metadata filter operator. The names follow the MongoDB-style
+// syntax accepted by ParseFilter.
+type Op string
+
+const (
+	OpEq       Op = "$eq"
+	OpNe       Op = "$ne"
+	OpGt       Op = "$gt"
+	OpGte      Op = "$gte"
+	OpLt       Op = "$lt"
+	OpLte      Op = "$lte"
+	OpIn       Op = "$in"
+	OpNin      Op = "$nin"
+	OpExists   Op = "$exists"
+	OpContains Op = "$contains"
+	OpAnd      Op = "$and"
+	OpOr       Op = "$or"
+)
+
+// FilterExpr is a node in a portable metadata filter. Comparison nodes set
+// Field and Value; $and/$or set Exprs. Build them with Eq, Gt, In, And and
+// friends, or from a JSON-shaped map with ParseFilter.
+//
+// Values compare by their string form for equality ($eq, $ne, $in, $nin,
+// $contains), so 2 matches 2.0 and "2". Ranges compare numbers
+// numerically, time.Time values (or RFC 3339 strings compared against
+// them) chronologically, and other strings lexically; mismatched kinds
+// never match.
+type FilterExpr struct {
+	Op    Op
+	Field string
+
+	// Value is the operand: a scalar, a []interface{} for $in/$nin, or a
+	// bool for $exists.
+	Value interface{}
+
+	Exprs []*FilterExpr
+}
+
+// Eq matches metadata where field equals v.
+func Eq(field string, v interface{}) *FilterExpr {
+	return &FilterExpr{Op: OpEq, Field: field, Value: v}
+}
+
+// Ne matches metadata where field is missing or differs from v.
+func Ne(field string, v interface{}) *FilterExpr {
+	return &FilterExpr{Op: OpNe, Field: field, Value: v}
+}
+
+// Gt matches metadata where field is greater than v.
+func Gt(field string, v interface{}) *FilterExpr {
+	return &FilterExpr{Op: OpGt, Field: field, Value: v}
+}
+
+// Gte matches metadata where field is greater than or equal to v.
+func Gte(field string, v interface{}) *FilterExpr {
+	return &FilterExpr{Op: OpGte, Field: field, Value: v}
+}
+
+// Lt matches metadata where field is less than v.
+func Lt(field string, v interface{}) *FilterExpr {
+	return &FilterExpr{Op: OpLt, Field: field, Value: v}
+}
+
+// Lte matches metadata where field is less than or equal to v.
+func Lte(field string, v interface{}) *FilterExpr {
+	return &FilterExpr{Op: OpLte, Field: field, Value: v}
+}
+
+// In matches metadata where field equals any of vs.
+func In(field string, vs ...interface{}) *FilterExpr {
+	return &FilterExpr{Op: OpIn, Field: field, Value: vs}
+}
+
+// Nin matches metadata where field is missing or equals none of vs.
+func Nin(field string, vs ...interface{}) *FilterExpr {
+	return &FilterExpr{Op: OpNin, Field: field, Value: vs}
+}
+
+// Exists matches metadata where field is present (or absent when exists
+// is false).
+func Exists(field string, exists bool) *FilterExpr {
+	return &FilterExpr{Op: OpExists, Field: field, Value: exists}
+}
+
+// Contains matches metadata where field is a list holding v.
+func Contains(field string, v interface{}) *FilterExpr {
+	return &FilterExpr{Op: OpContains, Field: field, Value: v}
+}
+
+// And matches when every expression matches. Nil expressions are skipped.
+func And(exprs ...*FilterExpr) *FilterExpr { return &FilterExpr{Op: OpAnd, Exprs: compact(exprs)} }
+
+// Or matches when any expression matches. Nil expressions are skipped.
+func Or(exprs ...*FilterExpr) *FilterExpr { return &FilterExpr{Op: OpOr, Exprs: compact(exprs)} }
+
+func compact(exprs []*FilterExpr) []*FilterExpr {
+	out := make([]*FilterExpr, 0, len(exprs))
+	for _, e := range exprs {
+		if e != nil {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Validate checks that e is well formed. A nil expression is valid and
+// matches everything.
+func (e *FilterExpr) Validate() error {
+	if e == nil {
+		return nil
+	}
+	switch e.Op {
+	case OpAnd, OpOr:
+		if len(e.Exprs) == 0 {
+			return errors.InvalidArgument(fmt.Sprintf("filter %s needs at least one expression", e.Op), nil)
+		}
+		for _, sub := range e.Exprs {
+			if sub == nil {
+				return errors.InvalidArgument(fmt.Sprintf("filter %s has a nil expression", e.Op), nil)
+			}
+			if err := sub.Validate(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpIn, OpNin, OpExists, OpContains:
+	default:
+		return errors.InvalidArgument(fmt.Sprintf("unknown filter operator %q", e.Op), nil)
+	}
+	if e.Field == "" {
+		return errors.InvalidArgument(fmt.Sprintf("filter %s needs a field", e.Op), nil)
+	}
+	switch e.Op {
+	case OpIn, OpNin:
+		if list, ok := e.Value.([]interface{}); !ok || len(list) == 0 {
+			return errors.InvalidArgument(fmt.Sprintf("filter %s on %s needs a non-empty list", e.Op, e.Field), nil)
+		}
+	case OpExists:
+		if _, ok := e.Value.(bool); !ok {
+			return errors.InvalidArgument(fmt.Sprintf("filter $exists on %s needs a bool", e.Field), nil)
+		}
+	case OpGt, OpGte, OpLt, OpLte:
+		if _, ok := rangeValue(e.Value); !ok {
+			return errors.InvalidArgument(fmt.Sprintf("filter %s on %s needs a number, string or time", e.Op, e.Field), nil)
+		}
+	}
+	return nil
+}
+
+// Match reports whether metadata satisfies e. A nil expression matches
+// everything.
+func (e *FilterExpr) Match(metadata map[string]interface{}) bool {
+	if e == nil {
+		return true
+	}
+	switch e.Op {
+	case OpAnd:
+		for _, sub := range e.Exprs {
+			if !sub.Match(metadata) {
+				return false
+			}
+		}
+		return true
+	case OpOr:
+		for _, sub := range e.Exprs {
+			if sub.Match(metadata) {
+				return true
+			}
+		}
+		return false
+	}
+
+	got, ok := metadata[e.Field]
+	switch e.Op {
+	case OpExists:
+		want, _ := e.Value.(bool)
+		return ok == want
+	case OpNe:
+		return !ok || !valuesEqual(got, e.Value)
+	case OpNin:
+		return !ok || !containsValue(e.Value, got)
+	}
+	if !ok {
+		return false
+	}
+	switch e.Op {
+	case OpEq:
+		return valuesEqual(got, e.Value)
+	case OpIn:
+		return containsValue(e.Value, got)
+	case OpContains:
+		return containsValue(got, e.Value)
+	case OpGt, OpGte, OpLt, OpLte:
+		c, ok := compareValues(got, e.Value)
+		if !ok {
+			return false
+		}
+		switch e.Op {
+		case OpGt:
+			return c > 0
+		case OpGte:
+			return c >= 0
+		case OpLt:
+			return c < 0
+		default:
+			return c <= 0
+		}
+	}
+	return false
+}
+
+// Equalities returns the field/value pairs e requires to be equal, looking
+// through nested $and. Indexes use it to narrow candidates before Match.
+func (e *FilterExpr) Equalities() map[string]interface{} {
+	out := map[string]interface{}{}
+	var walk func(*FilterExpr)
+	walk = func(e *FilterExpr) {
+		switch {
+		case e == nil:
+		case e.Op == OpEq:
+			out[e.Field] = e.Value
+		case e.Op == OpAnd:
+			for _, sub := range e.Exprs {
+				walk(sub)
+			}
+		}
+	}
+	walk(e)
+	return out
+}
+
+// String renders e in the ParseFilter syntax, for logs and traces.
+func (e *FilterExpr) String() string {
+	if e == nil {
+		return "{}"
+	}
+	switch e.Op {
+	case OpAnd, OpOr:
+		parts := make([]string, len(e.Exprs))
+		for i, sub := range e.Exprs {
+			parts[i] = sub.String()
+		}
+		return fmt.Sprintf("{%s: [%s]}", e.Op, strings.Join(parts, ", "))
+	}
+	return fmt.Sprintf("{%s: {%s: %v}}", e.Field, e.Op, e.Value)
+}
+
+// ParseFilter converts a MongoDB-style filter, as decoded from JSON, into a
+// FilterExpr. Plain values mean equality, so the exact-match maps accepted
+// before operators existed parse unchanged:
+//
+//	{"lang": "en", "price": {"$lt": 10}, "$or": [{"tags": {"$contains": "go"}}, {"draft": {"$exists": false}}]}
+//
+// Sibling keys are ANDed. An empty filter returns nil.
+func ParseFilter(filter map[string]interface{}) (*FilterExpr, error) {
+	if len(filter) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(filter))
+	for k := range filter {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	exprs := make([]*FilterExpr, 0, len(keys))
+	for _, k := range keys {
+		v := filter[k]
+		switch Op(k) {
+		case OpAnd, OpOr:
+			list, ok := v.([]interface{})
+			if !ok {
+				if maps, isMaps := v.([]map[string]interface{}); isMaps {
+					list = make([]interface{}, len(maps))
+					for i, m := range maps {
+						list[i] = m
+					}
+					ok = true
+				}
+			}
+			if !ok || len(list) == 0 {
+				return nil, errors.InvalidArgument(fmt.Sprintf("filter %s needs a non-empty list", k), nil)
+			}
+			subs := make([]*FilterExpr, 0, len(list))
+			for _, item := range list {
+				m, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, errors.InvalidArgument(fmt.Sprintf("filter %s entries must be objects", k), nil)
+				}
+				sub, err := ParseFilter(m)
+				if err != nil {
+					return nil, err
+				}
+				if sub != nil {
+					subs = append(subs, sub)
+				}
+			}
+			exprs = append(exprs, &FilterExpr{Op: Op(k), Exprs: subs})
+			continue
+		}
+		if strings.HasPrefix(k, "$") {
+			return nil, errors.InvalidArgument(fmt.Sprintf("unknown filter operator %q", k), nil)
+		}
+
+		ops, ok := v.(map[string]interface{})
+		if !ok || !isOperatorMap(ops) {
+			exprs = append(exprs, Eq(k, v))
+			continue
+		}
+		opKeys := make([]string, 0, len(ops))
+		for op := range ops {
+			opKeys = append(opKeys, op)
+		}
+		sort.Strings(opKeys)
+		for _, op := range opKeys {
+			operand := ops[op]
+			if Op(op) == OpIn || Op(op) == OpNin {
+				operand = toList(operand)
+			}
+			exprs = append(exprs, &FilterExpr{Op: Op(op), Field: k, Value: operand})
+		}
+	}
+
+	var out *FilterExpr
+	if len(exprs) == 1 {
+		out = exprs[0]
+	} else {
+		out = And(exprs...)
+	}
+	if err := out.Validate(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// isOperatorMap reports whether every key of m is an operator, so that a
+// nested object value without them still means equality.
+func isOperatorMap(m map[string]interface{}) bool {
+	if len(m) == 0 {
+		return false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// toList normalises any slice to []interface{}, leaving other values for
+// Validate to reject.
+func toList(v interface{}) interface{} {
+	if list, ok := v.([]interface{}); ok {
+		return list
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return v
+	}
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out
+}
+
+// Expr returns the effective filter: Filter parsed with ParseFilter, ANDed
+// with Where.
+func (o SearchOpts) Expr() (*FilterExpr, error) {
+	parsed, err := ParseFilter(o.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Where.Validate(); err != nil {
+		return nil, err
+	}
+	switch {
+	case parsed == nil:
+		return o.Where, nil
+	case o.Where == nil:
+		return parsed, nil
+	}
+	return And(parsed, o.Where), nil
+}
+
+// UnsupportedFilter returns the error adapters report for a filter their
+// backend cannot express. It wraps ErrUnsupportedFilter.
+func UnsupportedFilter(backend string, e *FilterExpr, reason string) error {
+	msg := fmt.Sprintf("%s cannot filter with %s", backend, e.Op)
+	if e.Field != "" {
+		msg += " on " + e.Field
+	}
+	if reason != "" {
+		msg += ": " + reason
+	}
+	return errors.New(errors.CodeUnimplemented, msg, ErrUnsupportedFilter)
+}
+
+// containsValue reports whether list (any slice) holds v.
+func containsValue(list, v interface{}) bool {
+	rv := reflect.ValueOf(list)
+	if !rv.IsValid() || rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if valuesEqual(rv.Index(i).Interface(), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeValue converts v to a float64, time.Time or string for ordering.
+func rangeValue(v interface{}) (interface{}, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		return x, true
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, false
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		return f, !math.IsNaN(f)
+	}
+	return nil, false
+}
+
+// compareValues orders a against b, reporting false when they are not
+// comparable. A string compared with a time.Time is parsed as RFC 3339.
+func compareValues(a, b interface{}) (int, bool) {
+	av, ok := rangeValue(a)
+	if !ok {
+		return 0, false
+	}
+	bv, ok := rangeValue(b)
+	if !ok {
+		return 0, false
+	}
+	if at, ok := av.(time.Time); ok {
+		bt, ok := asTime(bv)
+		if !ok {
+			return 0, false
+		}
+		return at.Compare(bt), true
+	}
+	if bt, ok := bv.(time.Time); ok {
+		at, ok := asTime(av)
+		if !ok {
+			return 0, false
+		}
+		return at.Compare(bt), true
+	}
+	switch x := av.(type) {
+	case float64:
+		y, ok := bv.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		y, ok := bv.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	}
+	return 0, false
+}
+
+func asTime(v interface{}) (time.Time, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, x)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
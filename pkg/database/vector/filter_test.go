@@ -0,0 +1,126 @@
+package vector_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterExprMatch(t *testing.T) {
+	published := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	meta := map[string]interface{}{
+		"lang":      "en",
+		"price":     12.5,
+		"stock":     3,
+		"tags":      []interface{}{"go", "db"},
+		"labels":    []string{"new"},
+		"published": published,
+		"updated":   "2024-05-01T00:00:00Z",
+	}
+
+	cases := []struct {
+		name string
+		expr *vector.FilterExpr
+		want bool
+	}{
+		{"eq", vector.Eq("lang", "en"), true},
+		{"eq numeric string form", vector.Eq("stock", 3.0), true},
+		{"ne", vector.Ne("lang", "fr"), true},
+		{"ne missing", vector.Ne("missing", "x"), true},
+		{"gt", vector.Gt("price", 10), true},
+		{"lte", vector.Lte("stock", 2), false},
+		{"gte int float", vector.Gte("stock", 3.0), true},
+		{"range on string never matches number", vector.Gt("price", "10"), false},
+		{"date range", vector.And(vector.Gte("published", published), vector.Lt("published", published.Add(time.Hour))), true},
+		{"rfc3339 string against time", vector.Gt("updated", published), true},
+		{"in", vector.In("lang", "fr", "en"), true},
+		{"nin", vector.Nin("lang", "fr", "de"), true},
+		{"nin missing", vector.Nin("missing", "x"), true},
+		{"exists", vector.Exists("tags", true), true},
+		{"not exists", vector.Exists("missing", false), true},
+		{"contains", vector.Contains("tags", "db"), true},
+		{"contains typed slice", vector.Contains("labels", "new"), true},
+		{"contains scalar field", vector.Contains("lang", "en"), false},
+		{"or", vector.Or(vector.Eq("lang", "fr"), vector.Lt("price", 20)), true},
+		{"nested", vector.And(vector.Eq("lang", "en"), vector.Or(vector.Contains("tags", "rust"), vector.Gt("stock", 5))), false},
+		{"nil", nil, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.expr.Validate())
+			assert.Equal(t, tc.want, tc.expr.Match(meta))
+		})
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"lang": "en",
+		"price": {"$gte": 10, "$lt": 20},
+		"$or": [{"tags": {"$contains": "go"}}, {"draft": {"$exists": false}}],
+		"tier": {"$in": [1, 2]},
+		"attrs": {"color": "red"}
+	}`), &raw))
+
+	expr, err := vector.ParseFilter(raw)
+	require.NoError(t, err)
+	assert.Equal(t, vector.OpAnd, expr.Op)
+	assert.Equal(t, map[string]interface{}{"lang": "en", "attrs": map[string]interface{}{"color": "red"}}, expr.Equalities())
+
+	meta := map[string]interface{}{"lang": "en", "price": 15, "tags": []string{"go"}, "tier": 2, "attrs": map[string]interface{}{"color": "red"}}
+	assert.True(t, expr.Match(meta))
+	meta["price"] = 20
+	assert.False(t, expr.Match(meta))
+	assert.False(t, vector.MatchFilter(meta, raw))
+
+	// Legacy exact-match maps are unchanged.
+	assert.True(t, vector.MatchFilter(map[string]interface{}{"tier": 1}, map[string]interface{}{"tier": "1"}))
+	assert.False(t, vector.MatchFilter(nil, map[string]interface{}{"tier": 1}))
+
+	nilExpr, err := vector.ParseFilter(nil)
+	require.NoError(t, err)
+	assert.Nil(t, nilExpr)
+
+	for _, bad := range []map[string]interface{}{
+		{"$not": map[string]interface{}{"a": 1}},
+		{"a": map[string]interface{}{"$regex": "x"}},
+		{"a": map[string]interface{}{"$in": "x"}},
+		{"a": map[string]interface{}{"$in": []interface{}{}}},
+		{"a": map[string]interface{}{"$gt": []int{1}}},
+		{"a": map[string]interface{}{"$exists": "yes"}},
+		{"$or": []interface{}{}},
+		{"$and": []interface{}{"a"}},
+	} {
+		_, err := vector.ParseFilter(bad)
+		assert.True(t, errors.IsCode(err, errors.CodeInvalidArgument), "%v: %v", bad, err)
+	}
+}
+
+func TestSearchOptsExpr(t *testing.T) {
+	expr, err := vector.SearchOpts{}.Expr()
+	require.NoError(t, err)
+	assert.Nil(t, expr)
+
+	where := vector.Gt("price", 5)
+	expr, err = vector.SearchOpts{Where: where}.Expr()
+	require.NoError(t, err)
+	assert.Same(t, where, expr)
+
+	expr, err = vector.SearchOpts{Filter: map[string]interface{}{"lang": "en"}, Where: where}.Expr()
+	require.NoError(t, err)
+	assert.Equal(t, "{$and: [{lang: {$eq: en}}, {price: {$gt: 5}}]}", expr.String())
+
+	_, err = vector.SearchOpts{Where: &vector.FilterExpr{Op: "$near", Field: "x"}}.Expr()
+	assert.True(t, errors.IsCode(err, errors.CodeInvalidArgument))
+
+	err = vector.UnsupportedFilter("backend", vector.Exists("x", true), "no presence test")
+	assert.True(t, errors.Is(err, vector.ErrUnsupportedFilter))
+	assert.True(t, errors.IsCode(err, errors.CodeUnimplemented))
+	assert.Contains(t, err.Error(), "backend cannot filter with $exists on x: no presence test")
+}
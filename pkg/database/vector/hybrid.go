@@ -2,32 +2,61 @@ package vector
 
 import (
 	"context"
-	"math"
 	"sort"
 	"strings"
 
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/datastructures/text/bm25"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 )
 
+// Fusion selects how HybridSearch combines the keyword and vector rankings.
+type Fusion string
+
+const (
+	// FusionWeighted sums the normalised scores, weighted.
+	FusionWeighted Fusion = "weighted"
+	// FusionRRF sums weighted reciprocal ranks, ignoring score scales.
+	FusionRRF Fusion = "rrf"
+)
+
+// DefaultRRFK is the reciprocal-rank-fusion constant from Cormack et al.
+const DefaultRRFK = 60
+
 // HybridOpts configures hybrid keyword + vector search.
 type HybridOpts struct {
 	// Limit is the maximum number of hybrid results (default 10).
 	Limit int
 
-	// KeywordQuery is matched against metadata string values (case-insensitive substring).
-	// Empty skips keyword scoring (vector-only with optional filter).
+	// KeywordQuery is scored with BM25 over metadata text (stemmed,
+	// stopwords removed). Empty skips keyword scoring (vector-only with
+	// optional filter).
 	KeywordQuery string
 
-	// Filter is an exact-match metadata filter applied before scoring (same as SearchWithOpts).
+	// Keywords runs the keyword side of the search. When nil, store is used
+	// if it implements KeywordSearcher (see NewKeywordStore); otherwise the
+	// vector candidates are BM25-scored among themselves, which cannot find
+	// keyword-only matches.
+	Keywords KeywordSearcher
+
+	// Filter is a metadata filter applied to both sides (same as SearchWithOpts).
 	Filter map[string]interface{}
 
-	// KeywordWeight multiplies the keyword score (default 0.3).
+	// Where is a typed filter ANDed with Filter (same as SearchWithOpts).
+	Where *FilterExpr
+
+	// Fusion combines the two rankings (default FusionWeighted).
+	Fusion Fusion
+
+	// RRFK is the rank constant for FusionRRF (default DefaultRRFK).
+	RRFK int
+
+	// KeywordWeight multiplies the keyword contribution (default 0.3).
 	KeywordWeight float32
 
-	// VectorWeight multiplies the vector similarity score (default 0.7).
+	// VectorWeight multiplies the vector contribution (default 0.7).
 	VectorWeight float32
 
-	// CandidateLimit is how many vector neighbors to fetch before hybrid re-rank
+	// CandidateLimit is how many hits to fetch from each side before fusion
 	// (default max(Limit*5, 50)).
 	CandidateLimit int
 }
@@ -41,14 +70,20 @@ type HybridResult struct {
 	HybridScore  float32                `json:"hybrid_score"`
 }
 
-// HybridSearch combines vector similarity with keyword metadata scoring.
+// HybridSearch queries the vector store and the keyword index independently
+// and fuses the two rankings, so documents found by only one side still
+// surface.
 //
-// KeywordScore is 1.0 when KeywordQuery is empty (neutral), otherwise the fraction
-// of metadata string fields that contain the query (0..1), boosted to 1.0 if any
-// field equals the query exactly (case-insensitive).
+// KeywordScore is the BM25 score divided by the best keyword hit's (0..1),
+// or 1.0 when KeywordQuery is empty (neutral; the vector side is still
+// fused as below). VectorScore is the store's
+// similarity; both are 0 for a document the side did not return.
 //
-// HybridScore = KeywordWeight*KeywordScore + VectorWeight*VectorScore
-// (weights are normalized to sum to 1 when both are positive).
+// With FusionWeighted, HybridScore = KeywordWeight*KeywordScore +
+// VectorWeight*v, where v is VectorScore min-max normalised over the vector
+// hits. With FusionRRF, HybridScore = KeywordWeight/(RRFK+keyword rank) +
+// VectorWeight/(RRFK+vector rank), counting ranks from 1. Weights are
+// normalised to sum to 1 when both are positive.
 func HybridSearch(ctx context.Context, store Store, query []float32, opts HybridOpts) ([]HybridResult, error) {
 	if store == nil {
 		return nil, errors.InvalidArgument("vector store is required", nil)
@@ -73,72 +108,138 @@ func HybridSearch(ctx context.Context, store Store, query []float32, opts Hybrid
 			cand = 50
 		}
 	}
+	searchOpts := SearchOpts{Limit: cand, Filter: opts.Filter, Where: opts.Where}
 
-	vectorHits, err := store.SearchWithOpts(ctx, query, SearchOpts{
-		Limit:  cand,
-		Filter: opts.Filter,
-	})
+	vectorHits, err := store.SearchWithOpts(ctx, query, searchOpts)
 	if err != nil {
 		return nil, err
 	}
 
-	q := strings.TrimSpace(strings.ToLower(opts.KeywordQuery))
-	out := make([]HybridResult, 0, len(vectorHits))
-	for _, hit := range vectorHits {
-		kw := float32(1)
-		if q != "" {
-			kw = keywordScore(hit.Metadata, q)
+	k := float32(opts.RRFK)
+	if k <= 0 {
+		k = DefaultRRFK
+	}
+	lo, hi := scoreRange(vectorHits)
+	// vectorPart is hit i's contribution to the hybrid score.
+	vectorPart := func(i int, hit Result) float32 {
+		if opts.Fusion == FusionRRF {
+			return vecW / (k + float32(i+1))
+		}
+		norm := float32(1)
+		if hi > lo {
+			norm = (hit.Score - lo) / (hi - lo)
 		}
-		hybrid := kwW*kw + vecW*hit.Score
-		out = append(out, HybridResult{
-			ID:           hit.ID,
-			Metadata:     hit.Metadata,
-			KeywordScore: kw,
-			VectorScore:  hit.Score,
-			HybridScore:  hybrid,
-		})
+		return vecW * norm
 	}
 
-	sort.SliceStable(out, func(i, j int) bool {
-		if out[i].HybridScore == out[j].HybridScore {
-			return out[i].ID < out[j].ID
+	q := strings.TrimSpace(opts.KeywordQuery)
+	if q == "" {
+		// Every hit gets the neutral keyword score, so only the vector side
+		// orders them.
+		out := make([]HybridResult, 0, len(vectorHits))
+		for i, hit := range vectorHits {
+			out = append(out, HybridResult{
+				ID:           hit.ID,
+				Metadata:     hit.Metadata,
+				KeywordScore: 1,
+				VectorScore:  hit.Score,
+				HybridScore:  kwW + vectorPart(i, hit),
+			})
 		}
-		return out[i].HybridScore > out[j].HybridScore
-	})
-	if len(out) > limit {
-		out = out[:limit]
+		return rankHybrid(out, limit), nil
 	}
-	return out, nil
-}
 
-func keywordScore(metadata map[string]interface{}, queryLower string) float32 {
-	if len(metadata) == 0 {
-		return 0
+	keywords := opts.Keywords
+	if keywords == nil {
+		keywords, _ = store.(KeywordSearcher)
+	}
+	var keywordHits []Result
+	if keywords != nil {
+		keywordHits, err = keywords.KeywordSearch(ctx, q, searchOpts)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		keywordHits = rescoreKeywords(vectorHits, q, cand)
 	}
-	var matched, total float32
-	exact := false
-	for _, v := range metadata {
-		s, ok := v.(string)
+
+	results := make(map[string]*HybridResult, len(vectorHits)+len(keywordHits))
+	get := func(hit Result) *HybridResult {
+		r, ok := results[hit.ID]
 		if !ok {
-			continue
+			r = &HybridResult{ID: hit.ID, Metadata: hit.Metadata}
+			results[hit.ID] = r
 		}
-		total++
-		lower := strings.ToLower(s)
-		if lower == queryLower {
-			exact = true
-			matched++
-			continue
+		return r
+	}
+
+	var maxKeyword float32
+	if len(keywordHits) > 0 {
+		maxKeyword = keywordHits[0].Score
+	}
+	for i, hit := range keywordHits {
+		r := get(hit)
+		if maxKeyword > 0 {
+			r.KeywordScore = hit.Score / maxKeyword
 		}
-		if strings.Contains(lower, queryLower) {
-			matched++
+		if opts.Fusion == FusionRRF {
+			r.HybridScore += kwW / (k + float32(i+1))
+		} else {
+			r.HybridScore += kwW * r.KeywordScore
 		}
 	}
-	if total == 0 {
-		return 0
+
+	for i, hit := range vectorHits {
+		r := get(hit)
+		r.VectorScore = hit.Score
+		r.HybridScore += vectorPart(i, hit)
+	}
+
+	out := make([]HybridResult, 0, len(results))
+	for _, r := range results {
+		out = append(out, *r)
+	}
+	return rankHybrid(out, limit), nil
+}
+
+// rescoreKeywords ranks the vector hits by BM25 over their own metadata,
+// for stores without a keyword index.
+func rescoreKeywords(hits []Result, query string, limit int) []Result {
+	idx := bm25.New()
+	byID := make(map[string]Result, len(hits))
+	for _, hit := range hits {
+		idx.Add(hit.ID, metadataText(hit.Metadata, nil))
+		byID[hit.ID] = hit
+	}
+	scored := idx.Search(query, limit, nil)
+	out := make([]Result, len(scored))
+	for i, s := range scored {
+		out[i] = Result{ID: s.ID, Score: float32(s.Score), Metadata: byID[s.ID].Metadata}
 	}
-	if exact {
-		return 1
+	return out
+}
+
+func scoreRange(hits []Result) (lo, hi float32) {
+	for i, hit := range hits {
+		if i == 0 || hit.Score < lo {
+			lo = hit.Score
+		}
+		if i == 0 || hit.Score > hi {
+			hi = hit.Score
+		}
+	}
+	return lo, hi
+}
+
+func rankHybrid(out []HybridResult, limit int) []HybridResult {
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].HybridScore == out[j].HybridScore {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].HybridScore > out[j].HybridScore
+	})
+	if len(out) > limit {
+		out = out[:limit]
 	}
-	score := matched / total
-	return float32(math.Min(1, float64(score)))
+	return out
 }
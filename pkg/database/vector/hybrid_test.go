@@ -6,6 +6,7 @@ import (
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/database/vector/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -67,3 +68,131 @@ func TestHybridSearch_FilterAndEmptyQuery(t *testing.T) {
 	assert.Equal(t, "x", results[0].ID)
 	assert.Equal(t, float32(1), results[0].KeywordScore) // neutral when no keyword
 }
+
+func TestHybridSearch_EmptyQueryNormalisesVectorScores(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	require.NoError(t, store.Upsert(ctx, "best", []float32{1, 0}, nil))
+	require.NoError(t, store.Upsert(ctx, "mid", []float32{0.6, 0.8}, nil))
+	require.NoError(t, store.Upsert(ctx, "worst", []float32{-1, 0}, nil))
+
+	results, err := vector.HybridSearch(ctx, store, []float32{1, 0}, vector.HybridOpts{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, []string{"best", "mid", "worst"}, []string{results[0].ID, results[1].ID, results[2].ID})
+	// 0.3 neutral keyword + 0.7 * min-max normalised similarity.
+	assert.InDelta(t, 1.0, results[0].HybridScore, 1e-5)
+	assert.InDelta(t, 0.3, results[2].HybridScore, 1e-5, "negative similarities must not pull scores below the keyword share")
+	assert.Equal(t, float32(-1), results[2].VectorScore)
+}
+
+func newKeywordStore(t *testing.T) *vector.KeywordStore {
+	t.Helper()
+	ctx := context.Background()
+	store := vector.NewKeywordStore(memory.New(), vector.NewKeywordIndex("title", "tags"))
+	docs := []struct {
+		id   string
+		vec  []float32
+		meta map[string]interface{}
+	}{
+		{"near", []float32{1, 0}, map[string]interface{}{"title": "introduction to databases", "lang": "en"}},
+		{"mid", []float32{0.7, 0.3}, map[string]interface{}{"title": "indexing strategies", "lang": "en"}},
+		{"far", []float32{0, 1}, map[string]interface{}{"title": "connecting pipelines", "tags": []string{"streaming"}, "lang": "fr"}},
+		{"farther", []float32{-1, 0.1}, map[string]interface{}{"title": "pipeline connections for streaming", "lang": "en"}},
+	}
+	for _, d := range docs {
+		require.NoError(t, store.Upsert(ctx, d.id, d.vec, d.meta))
+	}
+	return store
+}
+
+func TestKeywordStore_RebuildAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	store := vector.NewKeywordStore(backend, vector.NewKeywordIndex("title"))
+	require.NoError(t, store.Upsert(ctx, "a", []float32{1, 0}, map[string]interface{}{"title": "streaming pipelines"}))
+	require.NoError(t, store.Upsert(ctx, "b", []float32{0, 1}, map[string]interface{}{"title": "vector databases"}))
+
+	// A new process over the same persisted backend starts with an empty index.
+	restarted := vector.NewKeywordStore(backend, vector.NewKeywordIndex("title"))
+	hits, err := restarted.KeywordSearch(ctx, "streaming", vector.SearchOpts{})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+
+	require.NoError(t, restarted.Rebuild(ctx))
+	hits, err = restarted.KeywordSearch(ctx, "streaming", vector.SearchOpts{})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "a", hits[0].ID)
+	assert.Equal(t, "streaming pipelines", hits[0].Metadata["title"])
+}
+
+func TestKeywordStore_RebuildNeedsScanner(t *testing.T) {
+	store := vector.NewKeywordStore(unscannable{memory.New()}, vector.NewKeywordIndex())
+	err := store.Rebuild(context.Background())
+	assert.True(t, errors.IsCode(err, errors.CodeFailedPrecondition), "got %v", err)
+}
+
+// unscannable hides the memory store's Scan method.
+type unscannable struct{ vector.Store }
+
+func TestHybridSearch_KeywordOnlyMatches(t *testing.T) {
+	ctx := context.Background()
+	store := newKeywordStore(t)
+
+	// "farther" is outside the vector candidates but matches the keywords.
+	results, err := vector.HybridSearch(ctx, store, []float32{1, 0}, vector.HybridOpts{
+		Limit:          4,
+		KeywordQuery:   "streaming pipeline connection",
+		CandidateLimit: 2,
+	})
+	require.NoError(t, err)
+	byID := map[string]vector.HybridResult{}
+	for _, r := range results {
+		byID[r.ID] = r
+	}
+	require.Contains(t, byID, "farther")
+	require.Contains(t, byID, "far")
+	assert.Equal(t, float32(0), byID["farther"].VectorScore)
+	assert.Equal(t, float32(1), byID["farther"].KeywordScore)
+	assert.Equal(t, "pipeline connections for streaming", byID["farther"].Metadata["title"])
+	assert.Equal(t, float32(0), byID["near"].KeywordScore)
+	assert.Equal(t, float32(1), byID["near"].VectorScore)
+
+	// Filters apply to the keyword side too.
+	results, err = vector.HybridSearch(ctx, store, []float32{1, 0}, vector.HybridOpts{
+		KeywordQuery: "streaming",
+		Where:        vector.Eq("lang", "en"),
+	})
+	require.NoError(t, err)
+	for _, r := range results {
+		assert.NotEqual(t, "far", r.ID)
+	}
+
+	require.NoError(t, store.Delete(ctx, "farther"))
+	hits, err := store.KeywordSearch(ctx, "streaming", vector.SearchOpts{})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "far", hits[0].ID)
+}
+
+func TestHybridSearch_RRF(t *testing.T) {
+	ctx := context.Background()
+	store := newKeywordStore(t)
+
+	results, err := vector.HybridSearch(ctx, store, []float32{1, 0}, vector.HybridOpts{
+		Limit:        4,
+		KeywordQuery: "indexing databases",
+		Fusion:       vector.FusionRRF,
+		RRFK:         1,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	// Both titles match one equally rare term, so keyword ties break by ID:
+	// mid is 1st by keyword and 2nd by vector, near the reverse.
+	assert.Equal(t, "near", results[0].ID)
+	assert.InDelta(t, 0.3/3+0.7/2, results[0].HybridScore, 1e-6)
+	assert.Equal(t, "mid", results[1].ID)
+	assert.InDelta(t, 0.3/2+0.7/3, results[1].HybridScore, 1e-6)
+	assert.Equal(t, float32(1), results[1].KeywordScore)
+}
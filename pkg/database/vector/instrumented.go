@@ -62,6 +62,7 @@ func (s *InstrumentedStore) SearchWithOpts(ctx context.Context, vector []float32
 		attribute.Int("vector.dimension", len(vector)),
 		attribute.Int("vector.limit", opts.Limit),
 		attribute.Int("vector.filter_keys", len(opts.Filter)),
+		attribute.Bool("vector.filter_where", opts.Where != nil),
 	))
 	defer span.End()
 
@@ -0,0 +1,211 @@
+package vector
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/datastructures/text/bm25"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+// KeywordSearcher finds documents by text relevance, the keyword side of
+// HybridSearch. Results come best first; scores are raw relevance, higher
+// is better.
+type KeywordSearcher interface {
+	KeywordSearch(ctx context.Context, query string, opts SearchOpts) ([]Result, error)
+}
+
+// Scanner is implemented by stores that can list their documents, so
+// derived indexes such as a KeywordIndex can be rebuilt from them.
+type Scanner interface {
+	// Scan calls fn for every stored document; an error from fn stops the
+	// scan and is returned.
+	Scan(ctx context.Context, fn func(id string, metadata map[string]interface{}) error) error
+}
+
+// KeywordIndex is an in-memory BM25 index over the text in vector
+// metadata. String values and lists of strings are indexed; Fields limits
+// which keys. It is not persisted: after a restart it is empty until
+// Rebuild runs.
+type KeywordIndex struct {
+	fields   []string
+	index    *bm25.Index
+	metadata map[string]map[string]interface{}
+	mu       *concurrency.SmartRWMutex
+}
+
+// NewKeywordIndex creates a keyword index over the given metadata fields,
+// or every field when none are given.
+func NewKeywordIndex(fields ...string) *KeywordIndex {
+	return &KeywordIndex{
+		fields:   fields,
+		index:    bm25.New(),
+		metadata: make(map[string]map[string]interface{}),
+		mu:       concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "vector-keyword-index"}),
+	}
+}
+
+// Index adds or replaces the document for id.
+func (k *KeywordIndex) Index(id string, metadata map[string]interface{}) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.index.Add(id, metadataText(metadata, k.fields))
+	k.metadata[id] = metadata
+}
+
+// Remove drops id from the index.
+func (k *KeywordIndex) Remove(id string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.index.Remove(id)
+	delete(k.metadata, id)
+}
+
+// Rebuild replaces the index with every document src yields. Documents
+// indexed or removed while it runs may be lost, so run it before the store
+// takes writes, typically at startup.
+func (k *KeywordIndex) Rebuild(ctx context.Context, src Scanner) error {
+	index := bm25.New()
+	metadata := make(map[string]map[string]interface{})
+	err := src.Scan(ctx, func(id string, md map[string]interface{}) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		index.Add(id, metadataText(md, k.fields))
+		metadata[id] = cloneMap(md)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.index, k.metadata = index, metadata
+	return nil
+}
+
+// KeywordSearch returns documents matching query by BM25, restricted to
+// those satisfying the filter in opts.
+func (k *KeywordIndex) KeywordSearch(ctx context.Context, query string, opts SearchOpts) ([]Result, error) {
+	expr, err := opts.Expr()
+	if err != nil {
+		return nil, err
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	var accept func(string) bool
+	if expr != nil {
+		accept = func(id string) bool { return expr.Match(k.metadata[id]) }
+	}
+	hits := k.index.Search(query, limit, accept)
+	out := make([]Result, len(hits))
+	for i, h := range hits {
+		out[i] = Result{ID: h.ID, Score: float32(h.Score), Metadata: cloneMap(k.metadata[h.ID])}
+	}
+	return out, nil
+}
+
+// KeywordStore is a Store that mirrors writes into a KeywordIndex, so
+// HybridSearch can query the keyword side independently of the vectors.
+type KeywordStore struct {
+	Store
+	keywords *KeywordIndex
+}
+
+// NewKeywordStore wraps next so upserts and deletes also update keywords.
+// keywords starts empty; for a store that outlives the process, call
+// Rebuild before serving, or backfill keywords with Index when next cannot
+// Scan. Otherwise keyword search misses every document written before the
+// restart.
+func NewKeywordStore(next Store, keywords *KeywordIndex) *KeywordStore {
+	return &KeywordStore{Store: next, keywords: keywords}
+}
+
+// Upsert writes the vector, then indexes its metadata text.
+func (s *KeywordStore) Upsert(ctx context.Context, id string, vector []float32, metadata map[string]interface{}) error {
+	if err := s.Store.Upsert(ctx, id, vector, metadata); err != nil {
+		return err
+	}
+	s.keywords.Index(id, cloneMap(metadata))
+	return nil
+}
+
+// Delete removes the vector and its keyword entry.
+func (s *KeywordStore) Delete(ctx context.Context, id string) error {
+	if err := s.Store.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.keywords.Remove(id)
+	return nil
+}
+
+// Rebuild reloads the keyword index from the wrapped store. It fails with
+// FailedPrecondition when the store does not implement Scanner.
+func (s *KeywordStore) Rebuild(ctx context.Context) error {
+	src, ok := s.Store.(Scanner)
+	if !ok {
+		return errors.FailedPrecondition("vector store cannot be scanned; backfill the keyword index with Index", nil)
+	}
+	return s.keywords.Rebuild(ctx, src)
+}
+
+// KeywordSearch searches the keyword index.
+func (s *KeywordStore) KeywordSearch(ctx context.Context, query string, opts SearchOpts) ([]Result, error) {
+	return s.keywords.KeywordSearch(ctx, query, opts)
+}
+
+// metadataText joins the indexable text in metadata, in key order.
+func metadataText(metadata map[string]interface{}, fields []string) string {
+	if len(fields) == 0 {
+		fields = make([]string, 0, len(metadata))
+		for k := range metadata {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+	}
+	var b strings.Builder
+	for _, f := range fields {
+		switch v := metadata[f].(type) {
+		case string:
+			b.WriteString(v)
+			b.WriteByte(' ')
+		case []string:
+			for _, s := range v {
+				b.WriteString(s)
+				b.WriteByte(' ')
+			}
+		case []interface{}:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					b.WriteString(s)
+					b.WriteByte(' ')
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+func cloneMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+var (
+	_ Store           = (*KeywordStore)(nil)
+	_ KeywordSearcher = (*KeywordStore)(nil)
+	_ KeywordSearcher = (*KeywordIndex)(nil)
+)
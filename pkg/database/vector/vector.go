@@ -41,9 +41,14 @@ type SearchOpts struct {
 	// Limit is the maximum number of results (required; treated as top-K).
 	Limit int
 
-	// Filter is an exact-match metadata filter. All keys must equal stored values.
-	// Nil or empty means no metadata filtering.
+	// Filter is a metadata filter in the syntax accepted by ParseFilter. A
+	// plain map of values is an exact match on every key. Nil or empty means
+	// no metadata filtering.
 	Filter map[string]interface{}
+
+	// Where is a typed filter ANDed with Filter. Adapters that cannot express
+	// an operator return an error wrapping ErrUnsupportedFilter.
+	Where *FilterExpr
 }
 
 // Store defines the interface for vector operations.
@@ -64,21 +69,14 @@ type Store interface {
 	Close() error
 }
 
-// MatchFilter reports whether metadata satisfies all exact-match filter keys.
+// MatchFilter reports whether metadata satisfies filter, in the syntax
+// accepted by ParseFilter. A malformed filter matches nothing.
 func MatchFilter(metadata, filter map[string]interface{}) bool {
-	if len(filter) == 0 {
-		return true
-	}
-	if metadata == nil {
+	expr, err := ParseFilter(filter)
+	if err != nil {
 		return false
 	}
-	for k, want := range filter {
-		got, ok := metadata[k]
-		if !ok || !valuesEqual(got, want) {
-			return false
-		}
-	}
-	return true
+	return expr.Match(metadata)
 }
 
 func valuesEqual(a, b interface{}) bool {
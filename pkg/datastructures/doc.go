@@ -8,7 +8,8 @@ This package includes implementations for:
   - Distributed: CRDT (G-Counter, PN-Counter, G-Set, LWW-Register), VectorClock, Merkle
   - Caching: LRU, LFU, ARC
   - Graph: directed adjacency-list graphs and DAG with topological sort
-  - Other: SkipList, Heap, Set/Union-Find, ConcurrentMap, Timer wheel, Suffix array
+  - Other: SkipList, Heap, Set/Union-Find, ConcurrentMap, Timer wheel, Suffix array,
+    BM25 inverted index
 
 Preferred reuse (do not reinvent local copies elsewhere in pkg/):
 
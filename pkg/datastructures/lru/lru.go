@@ -66,6 +66,32 @@ func (c *Cache[K, V]) Set(key K, value V) {
 	}
 }
 
+// Delete removes key from the cache and reports whether it was present.
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ent, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	c.remove(ent)
+	delete(c.items, key)
+	return true
+}
+
+// Keys returns the cached keys from most to least recently used.
+func (c *Cache[K, V]) Keys() []K {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]K, 0, c.size)
+	for ent := c.head; ent != nil; ent = ent.next {
+		keys = append(keys, ent.key)
+	}
+	return keys
+}
+
 // removeOldest removes the oldest item from the cache.
 func (c *Cache[K, V]) removeOldest() {
 	if c.tail != nil {
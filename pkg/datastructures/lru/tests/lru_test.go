@@ -24,3 +24,29 @@ func TestLRU(t *testing.T) {
 		t.Error("Expected three to be present")
 	}
 }
+
+func TestLRUDeleteAndKeys(t *testing.T) {
+	c := lru.New[string, int](3)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+
+	if !c.Delete("b") {
+		t.Fatal("Expected b to be deleted")
+	}
+	if c.Delete("b") {
+		t.Fatal("Expected second delete of b to report false")
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("Expected b to be gone")
+	}
+	if c.Len() != 2 {
+		t.Fatalf("Expected len 2, got %d", c.Len())
+	}
+
+	c.Get("a")
+	keys := c.Keys()
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "c" {
+		t.Fatalf("Expected [a c], got %v", keys)
+	}
+}
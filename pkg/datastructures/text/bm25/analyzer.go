@@ -0,0 +1,33 @@
+package bm25
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopwords are common English words dropped by Analyze; they match almost
+// every document and only add noise to scores.
+var stopwords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "but": {},
+	"by": {}, "for": {}, "if": {}, "in": {}, "into": {}, "is": {}, "it": {}, "no": {},
+	"not": {}, "of": {}, "on": {}, "or": {}, "such": {}, "that": {}, "the": {},
+	"their": {}, "then": {}, "there": {}, "these": {}, "they": {}, "this": {},
+	"to": {}, "was": {}, "will": {}, "with": {},
+}
+
+// Analyze splits text into index terms: it lower-cases, splits on anything
+// that is not a letter or digit, drops English stopwords and stems what is
+// left with Stem.
+func Analyze(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := fields[:0]
+	for _, f := range fields {
+		if _, stop := stopwords[f]; stop {
+			continue
+		}
+		terms = append(terms, Stem(f))
+	}
+	return terms
+}
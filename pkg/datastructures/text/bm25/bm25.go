@@ -0,0 +1,176 @@
+package bm25
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Default Okapi BM25 parameters.
+const (
+	DefaultK1 = 1.2
+	DefaultB  = 0.75
+)
+
+// Config tunes BM25 scoring.
+type Config struct {
+	// K1 controls term-frequency saturation (default 1.2).
+	K1 float64
+
+	// B controls document-length normalisation, from 0 (none) to 1 (full)
+	// (default 0.75).
+	B float64
+}
+
+// Hit is a scored search result.
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Index is a thread-safe inverted index scored with Okapi BM25.
+type Index struct {
+	k1, b float64
+
+	docs     map[string]map[string]int // id -> term -> frequency
+	lengths  map[string]int
+	postings map[string]map[string]int // term -> id -> frequency
+	totalLen int
+	mu       sync.RWMutex
+}
+
+// New creates an index with the default parameters.
+func New() *Index {
+	return NewWithConfig(Config{K1: DefaultK1, B: DefaultB})
+}
+
+// NewWithConfig creates an index. A zero K1 takes the default; B may be 0.
+func NewWithConfig(cfg Config) *Index {
+	if cfg.K1 <= 0 {
+		cfg.K1 = DefaultK1
+	}
+	if cfg.B < 0 || cfg.B > 1 {
+		cfg.B = DefaultB
+	}
+	return &Index{
+		k1:       cfg.K1,
+		b:        cfg.B,
+		docs:     make(map[string]map[string]int),
+		lengths:  make(map[string]int),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+// Add indexes text under id, replacing any previous text for id.
+func (x *Index) Add(id, text string) {
+	terms := Analyze(text)
+	freqs := make(map[string]int, len(terms))
+	for _, t := range terms {
+		freqs[t]++
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.removeLocked(id)
+	x.docs[id] = freqs
+	x.lengths[id] = len(terms)
+	x.totalLen += len(terms)
+	for t, f := range freqs {
+		p, ok := x.postings[t]
+		if !ok {
+			p = make(map[string]int)
+			x.postings[t] = p
+		}
+		p[id] = f
+	}
+}
+
+// Remove drops id from the index, reporting whether it was present.
+func (x *Index) Remove(id string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.removeLocked(id)
+}
+
+func (x *Index) removeLocked(id string) bool {
+	freqs, ok := x.docs[id]
+	if !ok {
+		return false
+	}
+	for t := range freqs {
+		delete(x.postings[t], id)
+		if len(x.postings[t]) == 0 {
+			delete(x.postings, t)
+		}
+	}
+	x.totalLen -= x.lengths[id]
+	delete(x.docs, id)
+	delete(x.lengths, id)
+	return true
+}
+
+// Len returns the number of indexed documents.
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// Search returns documents matching any query term, best first, ties by
+// ID. Only documents accepted by accept (nil accepts all) are returned; a
+// limit of zero or less returns every match.
+//
+// Each term contributes idf * tf*(k1+1) / (tf + k1*(1-b+b*len/avglen)),
+// with the non-negative idf ln(1 + (N-n+0.5)/(n+0.5)) so terms present in
+// most documents still count a little.
+func (x *Index) Search(query string, limit int, accept func(id string) bool) []Hit {
+	terms := Analyze(query)
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if len(x.docs) == 0 || len(terms) == 0 {
+		return nil
+	}
+
+	n := float64(len(x.docs))
+	avgLen := float64(x.totalLen) / n
+	if avgLen == 0 {
+		avgLen = 1
+	}
+	scores := make(map[string]float64)
+	seen := make(map[string]struct{}, len(terms))
+	for _, t := range terms {
+		if _, dup := seen[t]; dup {
+			continue
+		}
+		seen[t] = struct{}{}
+		p := x.postings[t]
+		if len(p) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-float64(len(p))+0.5)/(float64(len(p))+0.5))
+		for id, f := range p {
+			if accept != nil && !accept(id) {
+				continue
+			}
+			tf := float64(f)
+			norm := x.k1 * (1 - x.b + x.b*float64(x.lengths[id])/avgLen)
+			scores[id] += idf * tf * (x.k1 + 1) / (tf + norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, s := range scores {
+		hits = append(hits, Hit{ID: id, Score: s})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
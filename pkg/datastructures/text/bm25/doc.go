@@ -0,0 +1,5 @@
+/*
+Package bm25 provides an in-memory inverted index scored with Okapi BM25,
+with an English analyzer (stopwords and Porter stemming).
+*/
+package bm25
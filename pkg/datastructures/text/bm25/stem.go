@@ -0,0 +1,232 @@
+package bm25
+
+// Stem reduces an English word to its stem with the Porter (1980)
+// algorithm, so "connected", "connecting" and "connection" all become
+// "connect". The word must be lower case; words of two letters or fewer,
+// or with characters outside a-z, are returned unchanged.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	z := &stemmer{b: []byte(word), k: len(word) - 1}
+	z.step1ab()
+	if z.k > 0 {
+		z.step1c()
+		z.step2()
+		z.step3()
+		z.step4()
+		z.step5()
+	}
+	return string(z.b[:z.k+1])
+}
+
+// stemmer holds the word being stemmed in b[0:k+1]; j marks the end of the
+// stem left by the last successful ends call.
+type stemmer struct {
+	b    []byte
+	k, j int
+}
+
+// cons reports whether b[i] is a consonant.
+func (z *stemmer) cons(i int) bool {
+	switch z.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !z.cons(i-1)
+	}
+	return true
+}
+
+// m counts the vowel-consonant sequences in b[0:j+1].
+func (z *stemmer) m() int {
+	n, i := 0, 0
+	for ; i <= z.j && z.cons(i); i++ {
+	}
+	for {
+		for ; i <= z.j && !z.cons(i); i++ {
+		}
+		if i > z.j {
+			return n
+		}
+		n++
+		for ; i <= z.j && z.cons(i); i++ {
+		}
+		if i > z.j {
+			return n
+		}
+	}
+}
+
+func (z *stemmer) vowelInStem() bool {
+	for i := 0; i <= z.j; i++ {
+		if !z.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doublec reports whether b[j-1:j+1] is a double consonant.
+func (z *stemmer) doublec(j int) bool {
+	return j >= 1 && z.b[j] == z.b[j-1] && z.cons(j)
+}
+
+// cvc reports whether b[i-2:i+1] is consonant-vowel-consonant with the last
+// not w, x or y, as in "hop" but not "snow".
+func (z *stemmer) cvc(i int) bool {
+	if i < 2 || !z.cons(i) || z.cons(i-1) || !z.cons(i-2) {
+		return false
+	}
+	switch z.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// ends reports whether b[0:k+1] ends with s, setting j to the stem end.
+func (z *stemmer) ends(s string) bool {
+	l := len(s)
+	if l > z.k+1 || string(z.b[z.k-l+1:z.k+1]) != s {
+		return false
+	}
+	z.j = z.k - l
+	return true
+}
+
+// setto replaces b[j+1:k+1] with s.
+func (z *stemmer) setto(s string) {
+	z.b = append(z.b[:z.j+1], s...)
+	z.k = z.j + len(s)
+}
+
+func (z *stemmer) r(s string) {
+	if z.m() > 0 {
+		z.setto(s)
+	}
+}
+
+// step1ab removes plurals and -ed or -ing.
+func (z *stemmer) step1ab() {
+	if z.b[z.k] == 's' {
+		switch {
+		case z.ends("sses"):
+			z.k -= 2
+		case z.ends("ies"):
+			z.setto("i")
+		case z.b[z.k-1] != 's':
+			z.k--
+		}
+	}
+	if z.ends("eed") {
+		if z.m() > 0 {
+			z.k--
+		}
+		return
+	}
+	if (z.ends("ed") || z.ends("ing")) && z.vowelInStem() {
+		z.k = z.j
+		switch {
+		case z.ends("at"):
+			z.setto("ate")
+		case z.ends("bl"):
+			z.setto("ble")
+		case z.ends("iz"):
+			z.setto("ize")
+		case z.doublec(z.k):
+			switch z.b[z.k] {
+			case 'l', 's', 'z':
+			default:
+				z.k--
+			}
+		default:
+			z.j = z.k
+			if z.m() == 1 && z.cvc(z.k) {
+				z.setto("e")
+			}
+		}
+	}
+}
+
+// step1c turns a terminal y into i when there is another vowel in the stem.
+func (z *stemmer) step1c() {
+	if z.ends("y") && z.vowelInStem() {
+		z.b[z.k] = 'i'
+	}
+}
+
+type rule struct{ suffix, replacement string }
+
+var step2Rules = []rule{
+	{"ational", "ate"}, {"tional", "tion"},
+	{"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"},
+	{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"},
+	{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"},
+	{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"},
+	{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+	{"logi", "log"},
+}
+
+var step3Rules = []rule{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"},
+	{"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""},
+	{"ness", ""},
+}
+
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+// step2 maps double suffixes to single ones, e.g. -ization to -ize.
+func (z *stemmer) step2() { z.applyRules(step2Rules) }
+
+// step3 handles -ic-, -full, -ness and similar.
+func (z *stemmer) step3() { z.applyRules(step3Rules) }
+
+func (z *stemmer) applyRules(rules []rule) {
+	for _, r := range rules {
+		if z.ends(r.suffix) {
+			z.r(r.replacement)
+			return
+		}
+	}
+}
+
+// step4 removes -ant, -ence and similar when the stem is long enough.
+func (z *stemmer) step4() {
+	for _, s := range step4Suffixes {
+		if !z.ends(s) {
+			continue
+		}
+		if s == "ion" && (z.j < 0 || z.b[z.j] != 's' && z.b[z.j] != 't') {
+			continue
+		}
+		if z.m() > 1 {
+			z.k = z.j
+		}
+		return
+	}
+}
+
+// step5 removes a final -e and reduces -ll to -l on long stems.
+func (z *stemmer) step5() {
+	z.j = z.k
+	if z.b[z.k] == 'e' {
+		a := z.m()
+		if a > 1 || a == 1 && !z.cvc(z.k-1) {
+			z.k--
+		}
+	}
+	if z.b[z.k] == 'l' && z.doublec(z.k) && z.m() > 1 {
+		z.k--
+	}
+}
@@ -0,0 +1,97 @@
+package bm25_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/datastructures/text/bm25"
+)
+
+func TestStem(t *testing.T) {
+	cases := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"ties":           "ti",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"conflated":      "conflat",
+		"troubled":       "troubl",
+		"sized":          "size",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"hissing":        "hiss",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"conditional":    "condit",
+		"generalization": "gener",
+		"running":        "run",
+		"connection":     "connect",
+		"connecting":     "connect",
+		"adjustment":     "adjust",
+		"electricity":    "electr",
+		"go":             "go",
+		"café":           "café",
+	}
+	for word, want := range cases {
+		if got := bm25.Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	got := bm25.Analyze("The Running of the Bulls, in 2024!")
+	want := []string{"run", "bull", "2024"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Analyze = %v, want %v", got, want)
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	idx := bm25.New()
+	idx.Add("a", "red apple fruit")
+	idx.Add("b", "blue car vehicle")
+	idx.Add("c", "apple pie recipe with apples and baked apple slices")
+	idx.Add("d", "connecting the pipeline")
+
+	hits := idx.Search("apples", 0, nil)
+	if len(hits) != 2 || hits[0].ID != "c" || hits[1].ID != "a" {
+		t.Fatalf("unexpected hits %+v", hits)
+	}
+	if hits := idx.Search("connection", 0, nil); len(hits) != 1 || hits[0].ID != "d" {
+		t.Errorf("stemmed query should match d: %+v", hits)
+	}
+
+	// Rarer terms weigh more.
+	hits = idx.Search("apple car", 0, nil)
+	if hits[0].ID != "b" {
+		t.Errorf("expected rare term car to rank b first: %+v", hits)
+	}
+
+	hits = idx.Search("apple", 1, func(id string) bool { return id != "c" })
+	if len(hits) != 1 || hits[0].ID != "a" {
+		t.Errorf("accept/limit not applied: %+v", hits)
+	}
+
+	idx.Add("c", "banana")
+	if hits := idx.Search("apple", 0, nil); len(hits) != 1 {
+		t.Errorf("re-adding should replace text: %+v", hits)
+	}
+	if !idx.Remove("a") || idx.Remove("a") {
+		t.Error("Remove should report presence")
+	}
+	if hits := idx.Search("apple", 0, nil); len(hits) != 0 {
+		t.Errorf("removed document still matches: %+v", hits)
+	}
+	if idx.Len() != 3 {
+		t.Errorf("Len = %d, want 3", idx.Len())
+	}
+	if hits := idx.Search("the", 0, nil); hits != nil {
+		t.Errorf("stopword-only query should match nothing: %+v", hits)
+	}
+}
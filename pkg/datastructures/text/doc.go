@@ -1,4 +1,5 @@
 /*
-Package text provides text processing data structures (Suffix Tree, Suffix Array).
+Package text provides text processing data structures (Suffix Tree, Suffix Array,
+BM25 inverted index).
 */
 package text
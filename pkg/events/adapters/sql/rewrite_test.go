@@ -0,0 +1,38 @@
+package sql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewritePostgresPlaceholders(t *testing.T) {
+	s := &Store{dialect: DialectPostgres, table: "outbox"}
+	got := s.rewrite(`UPDATE {table} SET a = ? WHERE b = ? AND c IN (?, ?)`)
+	want := `UPDATE outbox SET a = $1 WHERE b = $2 AND c IN ($3, $4)`
+	if got != want {
+		t.Fatalf("rewrite = %q, want %q", got, want)
+	}
+}
+
+func TestRewriteKeepsQuestionMarksForMySQL(t *testing.T) {
+	s := &Store{dialect: DialectMySQL, table: "outbox"}
+	if got := s.rewrite(`SELECT 1 FROM {table} WHERE a = ?`); got != `SELECT 1 FROM outbox WHERE a = ?` {
+		t.Fatalf("rewrite = %q", got)
+	}
+}
+
+func TestCreateTableDialects(t *testing.T) {
+	cases := map[Dialect][]string{
+		DialectSQLite:   {"INTEGER PRIMARY KEY AUTOINCREMENT", "next_attempt_at TIMESTAMP"},
+		DialectPostgres: {"BIGSERIAL PRIMARY KEY", "lease_until TIMESTAMP"},
+		DialectMySQL:    {"BIGINT AUTO_INCREMENT PRIMARY KEY", "lease_until DATETIME(6)"},
+	}
+	for dialect, fragments := range cases {
+		ddl := (&Store{dialect: dialect, table: "outbox"}).createTable()
+		for _, f := range fragments {
+			if !strings.Contains(ddl, f) {
+				t.Fatalf("dialect %d DDL missing %q:\n%s", dialect, f, ddl)
+			}
+		}
+	}
+}
@@ -0,0 +1,449 @@
+// Package sql provides a transactional events.OutboxStore using database/sql.
+//
+// Enqueue writes the event envelope through the caller's transaction, so the
+// record commits or rolls back together with the domain writes. An
+// events.OutboxRelay then publishes committed records to pkg/messaging.
+//
+// Usage with pkg/database/sql (GORM):
+//
+//	store, _ := eventsql.New(sqlDB, eventsql.Config{Dialect: eventsql.DialectPostgres})
+//	_ = store.Migrate(ctx)
+//
+//	err := db.Get(ctx).Transaction(func(tx *gorm.DB) error {
+//	    if err := tx.Create(&order).Error; err != nil {
+//	        return err
+//	    }
+//	    return store.Enqueue(ctx, tx.Statement.ConnPool, "orders", order.ID, events.Event{
+//	        Type:    "order.placed",
+//	        Payload: order,
+//	    })
+//	})
+//
+//	relay := events.NewOutboxRelay(store, producer, events.DefaultRelayConfig())
+//	_ = relay.Start(ctx)
+//
+// Several relays (one per replica) may share a table: Claim leases rows with
+// an atomic conditional UPDATE, so each row is published by one relay at a time.
+//
+// MySQL DSNs must set parseTime=true so DATETIME columns scan into time.Time.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/events"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/resilience"
+)
+
+// Ensure compile-time interface compliance.
+var _ events.OutboxStore = (*Store)(nil)
+
+// Dialect selects SQL placeholder style and column types.
+type Dialect int
+
+const (
+	// DialectSQLite uses ? placeholders.
+	DialectSQLite Dialect = iota
+	// DialectPostgres uses $1, $2, ... placeholders.
+	DialectPostgres
+	// DialectMySQL uses ? placeholders and AUTO_INCREMENT sequences.
+	// The DSN must set parseTime=true.
+	DialectMySQL
+)
+
+const defaultTable = "events_outbox"
+
+// Execer is satisfied by *sql.Tx, *sql.DB and GORM's tx.Statement.ConnPool.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Config configures the SQL outbox store.
+type Config struct {
+	// Dialect selects placeholder style and DDL. MySQL requires parseTime=true in the DSN.
+	Dialect Dialect
+
+	// Table is the outbox table name. Defaults to "events_outbox".
+	Table string
+
+	// Retrier wraps relay-side DB I/O; nil uses resilience.DefaultRetryConfig.
+	Retrier resilience.Retrier
+}
+
+// Store is a durable outbox store using database/sql.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+	retrier resilience.Retrier
+}
+
+// New wraps an existing *sql.DB. Call Migrate before use.
+func New(db *sql.DB, cfg Config) (*Store, error) {
+	if db == nil {
+		return nil, errors.InvalidArgument("db is required", nil)
+	}
+	table := cfg.Table
+	if table == "" {
+		table = defaultTable
+	}
+	retrier := cfg.Retrier
+	if retrier == nil {
+		retrier = resilience.NewRetrier(resilience.DefaultRetryConfig())
+	}
+	return &Store{
+		db:      db,
+		dialect: cfg.Dialect,
+		table:   table,
+		retrier: retrier,
+	}, nil
+}
+
+func (s *Store) rewrite(query string) string {
+	query = strings.ReplaceAll(query, "{table}", s.table)
+	if s.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteByte(query[i])
+	}
+	return b.String()
+}
+
+func (s *Store) createTable() string {
+	seq, ts := "seq INTEGER PRIMARY KEY AUTOINCREMENT", "TIMESTAMP"
+	switch s.dialect {
+	case DialectPostgres:
+		seq = "seq BIGSERIAL PRIMARY KEY"
+	case DialectMySQL:
+		seq, ts = "seq BIGINT AUTO_INCREMENT PRIMARY KEY", "DATETIME(6)"
+	}
+	return s.rewrite(`CREATE TABLE IF NOT EXISTS {table} (
+	` + seq + `,
+	id VARCHAR(64) NOT NULL,
+	aggregate_id VARCHAR(255) NOT NULL DEFAULT '',
+	topic VARCHAR(255) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	next_attempt_at ` + ts + ` NOT NULL,
+	created_at ` + ts + ` NOT NULL,
+	dispatched_at ` + ts + ` NULL,
+	claimed_by VARCHAR(64) NULL,
+	lease_until ` + ts + ` NULL
+)`)
+}
+
+// indexes maps index name suffix to its column list.
+var indexes = []struct{ name, columns string }{
+	{"status_seq", "status, seq"},
+	{"aggregate_seq", "aggregate_id, seq"},
+}
+
+// Migrate creates the outbox table and indexes if missing.
+func (s *Store) Migrate(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.retrier.Execute(ctx, func(ctx context.Context) error {
+		if _, err := s.db.ExecContext(ctx, s.createTable()); err != nil {
+			return errors.Internal("migrate outbox table failed", err)
+		}
+		for _, idx := range indexes {
+			name := "idx_" + s.table + "_" + idx.name
+			if s.dialect == DialectMySQL {
+				// MySQL has no CREATE INDEX IF NOT EXISTS.
+				var n int
+				q := `SELECT COUNT(*) FROM information_schema.statistics
+WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?`
+				if err := s.db.QueryRowContext(ctx, q, s.table, name).Scan(&n); err != nil {
+					return errors.Internal("inspect outbox indexes failed", err)
+				}
+				if n > 0 {
+					continue
+				}
+				stmt := s.rewrite(`CREATE INDEX ` + name + ` ON {table}(` + idx.columns + `)`)
+				if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+					return errors.Internal("migrate outbox index failed", err)
+				}
+				continue
+			}
+			stmt := s.rewrite(`CREATE INDEX IF NOT EXISTS ` + name + ` ON {table}(` + idx.columns + `)`)
+			if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+				return errors.Internal("migrate outbox index failed", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Enqueue writes event through tx so it is published only if tx commits.
+// aggregateID groups records that must be relayed in order (may be empty).
+func (s *Store) Enqueue(ctx context.Context, tx Execer, topic, aggregateID string, event events.Event) error {
+	if tx == nil {
+		return errors.InvalidArgument("transaction is required", nil)
+	}
+	payload, err := events.NewOutboxPayload(topic, event)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Internal("failed to marshal outbox envelope", err)
+	}
+
+	now := time.Now().UTC()
+	q := s.rewrite(`INSERT INTO {table} (id, aggregate_id, topic, payload, status, attempts, next_attempt_at, created_at)
+VALUES (?, ?, ?, ?, ?, 0, ?, ?)`)
+	if _, err := tx.ExecContext(ctx, q, payload.ID, aggregateID, topic, string(raw),
+		string(events.OutboxStatusPending), now, now); err != nil {
+		return errors.Internal("insert outbox record failed", err)
+	}
+	return nil
+}
+
+const selectColumns = `seq, aggregate_id, payload, status, attempts, last_error, next_attempt_at, created_at, dispatched_at`
+
+// Claim leases the oldest publishable record of up to limit aggregates to owner.
+func (s *Store) Claim(ctx context.Context, owner string, limit int, now time.Time, lease time.Duration) ([]events.OutboxRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if owner == "" {
+		return nil, errors.InvalidArgument("owner is required", nil)
+	}
+	if limit <= 0 {
+		return nil, errors.InvalidArgument("limit must be > 0", nil)
+	}
+	// Postgres and MySQL keep microseconds; truncate so the lease token
+	// read back below compares equal to what was written.
+	now = now.UTC().Truncate(time.Microsecond)
+	leaseUntil := now.Add(lease).Truncate(time.Microsecond)
+	pending := string(events.OutboxStatusPending)
+
+	var out []events.OutboxRecord
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		// A row is eligible when it is due, not leased by another relay, and is
+		// the oldest pending row of its aggregate.
+		q := s.rewrite(`SELECT o.seq FROM {table} o
+WHERE o.status = ? AND o.next_attempt_at <= ?
+	AND (o.lease_until IS NULL OR o.lease_until <= ? OR o.claimed_by = ?)
+	AND NOT EXISTS (
+		SELECT 1 FROM {table} p
+		WHERE p.aggregate_id = o.aggregate_id AND p.status = ? AND p.seq < o.seq
+	)
+ORDER BY o.seq ASC LIMIT ?`)
+		candidates, err := s.querySequences(ctx, q, pending, now, now, owner, pending, limit)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			out = nil
+			return nil
+		}
+
+		// The conditional UPDATE is the claim: a concurrent relay that selected
+		// the same rows matches zero of them once this commits.
+		args := []interface{}{owner, leaseUntil, pending, now, owner}
+		for _, seq := range candidates {
+			args = append(args, seq)
+		}
+		upd := s.rewrite(`UPDATE {table} SET claimed_by = ?, lease_until = ?
+WHERE status = ? AND (lease_until IS NULL OR lease_until <= ? OR claimed_by = ?)
+	AND seq IN (?` + strings.Repeat(", ?", len(candidates)-1) + `)`)
+		if _, err := s.db.ExecContext(ctx, upd, args...); err != nil {
+			return errors.Internal("claim outbox records failed", err)
+		}
+
+		sel := s.rewrite(`SELECT ` + selectColumns + ` FROM {table}
+WHERE claimed_by = ? AND lease_until = ? AND status = ? ORDER BY seq ASC`)
+		records, err := s.queryRecords(ctx, sel, owner, leaseUntil, pending)
+		if err != nil {
+			return err
+		}
+		out = records
+		return nil
+	})
+	return out, err
+}
+
+// Failed returns records that exhausted their attempts.
+func (s *Store) Failed(ctx context.Context, limit int) ([]events.OutboxRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, errors.InvalidArgument("limit must be > 0", nil)
+	}
+	var out []events.OutboxRecord
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		q := s.rewrite(`SELECT ` + selectColumns + ` FROM {table} WHERE status = ? ORDER BY seq ASC LIMIT ?`)
+		records, err := s.queryRecords(ctx, q, string(events.OutboxStatusFailed), limit)
+		if err != nil {
+			return err
+		}
+		out = records
+		return nil
+	})
+	return out, err
+}
+
+func (s *Store) querySequences(ctx context.Context, q string, args ...interface{}) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Internal("query outbox failed", err)
+	}
+	defer rows.Close()
+
+	var seqs []int64
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, errors.Internal("scan outbox sequence failed", err)
+		}
+		seqs = append(seqs, seq)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Internal("query outbox failed", err)
+	}
+	return seqs, nil
+}
+
+func (s *Store) queryRecords(ctx context.Context, q string, args ...interface{}) ([]events.OutboxRecord, error) {
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Internal("query outbox failed", err)
+	}
+	defer rows.Close()
+
+	records := make([]events.OutboxRecord, 0)
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Internal("query outbox failed", err)
+	}
+	return records, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRecord(row rowScanner) (events.OutboxRecord, error) {
+	var (
+		rec        events.OutboxRecord
+		raw        string
+		status     string
+		lastErr    sql.NullString
+		dispatched sql.NullTime
+	)
+	err := row.Scan(&rec.Sequence, &rec.AggregateID, &raw, &status, &rec.Attempts, &lastErr,
+		&rec.NextAttemptAt, &rec.CreatedAt, &dispatched)
+	if err != nil {
+		return events.OutboxRecord{}, errors.Internal("scan outbox record failed", err)
+	}
+	if err := json.Unmarshal([]byte(raw), &rec.Payload); err != nil {
+		return events.OutboxRecord{}, errors.Internal("decode outbox envelope failed", err)
+	}
+	rec.Status = events.OutboxStatus(status)
+	rec.LastError = lastErr.String
+	rec.NextAttemptAt = rec.NextAttemptAt.UTC()
+	rec.CreatedAt = rec.CreatedAt.UTC()
+	if dispatched.Valid {
+		rec.DispatchedAt = dispatched.Time.UTC()
+	}
+	return rec, nil
+}
+
+func (s *Store) exec(ctx context.Context, op, q string, args ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.retrier.Execute(ctx, func(ctx context.Context) error {
+		if _, err := s.db.ExecContext(ctx, q, args...); err != nil {
+			return errors.Internal(op+" failed", err)
+		}
+		return nil
+	})
+}
+
+func sequenceArgs(head []interface{}, sequences []int64) ([]interface{}, string) {
+	args := append(head, make([]interface{}, 0, len(sequences))...)
+	for _, seq := range sequences {
+		args = append(args, seq)
+	}
+	return args, "(?" + strings.Repeat(", ?", len(sequences)-1) + ")"
+}
+
+// MarkDispatched flags records as dispatched and releases their lease.
+func (s *Store) MarkDispatched(ctx context.Context, sequences []int64, at time.Time) error {
+	if len(sequences) == 0 {
+		return nil
+	}
+	args, in := sequenceArgs([]interface{}{string(events.OutboxStatusDispatched), at.UTC()}, sequences)
+	q := s.rewrite(`UPDATE {table} SET status = ?, dispatched_at = ?, claimed_by = NULL, lease_until = NULL WHERE seq IN ` + in)
+	return s.exec(ctx, "mark outbox dispatched", q, args...)
+}
+
+// MarkFailed records a failed publish attempt and releases the lease.
+func (s *Store) MarkFailed(ctx context.Context, sequence int64, status events.OutboxStatus, attempts int, next time.Time, cause string) error {
+	q := s.rewrite(`UPDATE {table} SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?,
+	claimed_by = NULL, lease_until = NULL WHERE seq = ?`)
+	return s.exec(ctx, "record outbox failure", q, string(status), attempts, next.UTC(), cause, sequence)
+}
+
+// Requeue resets failed records to pending so the relay retries them.
+func (s *Store) Requeue(ctx context.Context, sequences []int64) error {
+	if len(sequences) == 0 {
+		return nil
+	}
+	args, in := sequenceArgs([]interface{}{
+		string(events.OutboxStatusPending), time.Now().UTC(), string(events.OutboxStatusFailed),
+	}, sequences)
+	q := s.rewrite(`UPDATE {table} SET status = ?, attempts = 0, next_attempt_at = ?, claimed_by = NULL, lease_until = NULL
+WHERE status = ? AND seq IN ` + in)
+	return s.exec(ctx, "requeue outbox records", q, args...)
+}
+
+// Purge deletes dispatched records dispatched before olderThan and failed
+// records created before olderThan.
+func (s *Store) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	var n int64
+	q := s.rewrite(`DELETE FROM {table} WHERE (status = ? AND dispatched_at < ?) OR (status = ? AND created_at < ?)`)
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		res, err := s.db.ExecContext(ctx, q,
+			string(events.OutboxStatusDispatched), olderThan.UTC(),
+			string(events.OutboxStatusFailed), olderThan.UTC())
+		if err != nil {
+			return errors.Internal("purge outbox failed", err)
+		}
+		n, err = res.RowsAffected()
+		if err != nil {
+			return errors.Internal("purge outbox rows affected", err)
+		}
+		return nil
+	})
+	return n, err
+}
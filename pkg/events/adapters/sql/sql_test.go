@@ -0,0 +1,277 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/events"
+	eventsql "github.com/chris-alexander-pop/go-hyperforge/pkg/events/adapters/sql"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/test"
+	_ "modernc.org/sqlite"
+)
+
+type recordingProducer struct {
+	mu       sync.Mutex
+	failures map[string]int
+	got      []*messaging.Message
+}
+
+func (p *recordingProducer) Publish(ctx context.Context, msg *messaging.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures[msg.ID] > 0 {
+		p.failures[msg.ID]--
+		return stderrors.New("broker unavailable")
+	}
+	p.got = append(p.got, msg)
+	return nil
+}
+
+func (p *recordingProducer) PublishBatch(ctx context.Context, msgs []*messaging.Message) error {
+	for _, m := range msgs {
+		if err := p.Publish(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *recordingProducer) Close() error { return nil }
+
+func (p *recordingProducer) ids() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]string, len(p.got))
+	for i, m := range p.got {
+		out[i] = m.ID
+	}
+	return out
+}
+
+type OutboxStoreSuite struct {
+	test.Suite
+	db    *sql.DB
+	store *eventsql.Store
+}
+
+func (s *OutboxStoreSuite) SetupTest() {
+	s.Suite.SetupTest()
+	db, err := sql.Open("sqlite", "file:outbox_test?mode=memory&cache=shared")
+	s.Require().NoError(err)
+	db.SetMaxOpenConns(1)
+	s.db = db
+	store, err := eventsql.New(db, eventsql.Config{Dialect: eventsql.DialectSQLite})
+	s.Require().NoError(err)
+	s.Require().NoError(store.Migrate(s.Ctx))
+	s.Require().NoError(store.Migrate(s.Ctx))
+	s.store = store
+}
+
+func (s *OutboxStoreSuite) TearDownTest() {
+	if s.db != nil {
+		_, _ = s.db.Exec(`DROP TABLE IF EXISTS events_outbox`)
+		_ = s.db.Close()
+	}
+}
+
+func (s *OutboxStoreSuite) enqueue(aggregate, id string, commit bool) {
+	tx, err := s.db.BeginTx(s.Ctx, nil)
+	s.Require().NoError(err)
+	s.Require().NoError(s.store.Enqueue(s.Ctx, tx, "orders", aggregate, events.Event{
+		ID:      id,
+		Type:    "order.placed",
+		Payload: map[string]string{"id": id},
+	}))
+	if commit {
+		s.Require().NoError(tx.Commit())
+	} else {
+		s.Require().NoError(tx.Rollback())
+	}
+}
+
+func (s *OutboxStoreSuite) claim(owner string, limit int, now time.Time) []events.OutboxRecord {
+	records, err := s.store.Claim(s.Ctx, owner, limit, now, time.Minute)
+	s.Require().NoError(err)
+	return records
+}
+
+func (s *OutboxStoreSuite) TestRollbackDiscardsRecord() {
+	s.enqueue("o1", "e1", false)
+	s.enqueue("o1", "e2", true)
+
+	claimed := s.claim("r1", 10, time.Now())
+	s.Require().Len(claimed, 1)
+	s.Equal("e2", claimed[0].Payload.ID)
+	s.Equal("orders", claimed[0].Payload.Topic)
+	s.Equal("o1", claimed[0].AggregateID)
+}
+
+func (s *OutboxStoreSuite) TestClaimReturnsOnlyAggregateHeads() {
+	s.enqueue("o1", "e1", true)
+	s.enqueue("o1", "e2", true)
+	s.enqueue("o2", "e3", true)
+	s.enqueue("", "e4", true)
+	s.enqueue("", "e5", true)
+
+	claimed := s.claim("r1", 10, time.Now())
+	ids := make([]string, len(claimed))
+	for i, rec := range claimed {
+		ids[i] = rec.Payload.ID
+	}
+	s.Equal([]string{"e1", "e3", "e4"}, ids)
+}
+
+func (s *OutboxStoreSuite) TestClaimIsExclusiveUntilLeaseExpires() {
+	s.enqueue("o1", "e1", true)
+	now := time.Now()
+
+	s.Require().Len(s.claim("r1", 10, now), 1)
+	s.Empty(s.claim("r2", 10, now))
+	s.Len(s.claim("r1", 10, now), 1, "owner may reclaim its own lease")
+	s.Len(s.claim("r2", 10, now.Add(2*time.Minute)), 1, "expired lease is claimable")
+}
+
+func (s *OutboxStoreSuite) TestRelayPublishesAndMarksDispatched() {
+	s.enqueue("o1", "e1", true)
+	s.enqueue("o2", "e2", true)
+
+	producer := &recordingProducer{}
+	relay := events.NewOutboxRelay(s.store, producer, events.RelayConfig{})
+	n, err := relay.RunOnce(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(2, n)
+	s.Equal([]string{"e1", "e2"}, producer.ids())
+
+	s.Empty(s.claim("r1", 10, time.Now()))
+}
+
+func (s *OutboxStoreSuite) TestRelayHoldsAggregateOrderOnFailure() {
+	s.enqueue("o1", "e1", true)
+	s.enqueue("o1", "e2", true)
+	s.enqueue("o2", "e3", true)
+
+	now := time.Now().UTC()
+	producer := &recordingProducer{failures: map[string]int{"e1": 1}}
+	relay := events.NewOutboxRelay(s.store, producer, events.RelayConfig{
+		BaseBackoff: time.Second,
+		MaxBackoff:  time.Second,
+	})
+	relay.SetNowFunc(func() time.Time { return now })
+
+	n, err := relay.RunOnce(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(1, n)
+	s.Equal([]string{"e3"}, producer.ids())
+
+	// e1 is backing off, so o1 stays blocked.
+	n, err = relay.RunOnce(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(0, n)
+
+	now = now.Add(2 * time.Second)
+	for i := 0; i < 2; i++ {
+		_, err = relay.RunOnce(s.Ctx)
+		s.Require().NoError(err)
+	}
+	s.Equal([]string{"e3", "e1", "e2"}, producer.ids())
+}
+
+func (s *OutboxStoreSuite) TestBlockedAggregateDoesNotStarveOthers() {
+	s.enqueue("o1", "e1", true)
+	s.enqueue("o1", "e2", true)
+	s.enqueue("o1", "e3", true)
+	s.enqueue("o2", "e4", true)
+	s.enqueue("o3", "e5", true)
+
+	producer := &recordingProducer{failures: map[string]int{"e1": 100}}
+	relay := events.NewOutboxRelay(s.store, producer, events.RelayConfig{BatchSize: 2})
+
+	for i := 0; i < 3; i++ {
+		_, err := relay.RunOnce(s.Ctx)
+		s.Require().NoError(err)
+	}
+	s.ElementsMatch([]string{"e4", "e5"}, producer.ids())
+}
+
+func (s *OutboxStoreSuite) TestRelayGivesUpAfterMaxAttempts() {
+	s.enqueue("o1", "e1", true)
+	s.enqueue("o1", "e2", true)
+
+	producer := &recordingProducer{failures: map[string]int{"e1": 5}}
+	relay := events.NewOutboxRelay(s.store, producer, events.RelayConfig{MaxAttempts: 1})
+	n, err := relay.RunOnce(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(0, n)
+	s.Empty(producer.ids())
+
+	failed, err := s.store.Failed(s.Ctx, 10)
+	s.Require().NoError(err)
+	s.Require().Len(failed, 1)
+	s.Equal("e1", failed[0].Payload.ID)
+	s.Equal(events.OutboxStatusFailed, failed[0].Status)
+	s.Equal(1, failed[0].Attempts)
+	s.Equal("broker unavailable", failed[0].LastError)
+
+	// The failed head no longer blocks its aggregate.
+	claimed := s.claim("r1", 10, time.Now())
+	s.Require().Len(claimed, 1)
+	s.Equal("e2", claimed[0].Payload.ID)
+}
+
+func (s *OutboxStoreSuite) TestRequeueFailed() {
+	s.enqueue("o1", "e1", true)
+	producer := &recordingProducer{failures: map[string]int{"e1": 1}}
+	relay := events.NewOutboxRelay(s.store, producer, events.RelayConfig{MaxAttempts: 1})
+	_, err := relay.RunOnce(s.Ctx)
+	s.Require().NoError(err)
+
+	failed, err := s.store.Failed(s.Ctx, 10)
+	s.Require().NoError(err)
+	s.Require().Len(failed, 1)
+	s.Require().NoError(s.store.Requeue(s.Ctx, []int64{failed[0].Sequence}))
+
+	n, err := relay.RunOnce(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(1, n)
+	s.Equal([]string{"e1"}, producer.ids())
+}
+
+func (s *OutboxStoreSuite) TestCleanupPurgesDispatchedAndFailed() {
+	s.enqueue("o1", "e1", true)
+	s.enqueue("o2", "e2", true)
+	claimed := s.claim("r1", 10, time.Now())
+	s.Require().Len(claimed, 2)
+	s.Require().NoError(s.store.MarkDispatched(s.Ctx, []int64{claimed[0].Sequence}, time.Now().Add(-48*time.Hour)))
+	s.Require().NoError(s.store.MarkFailed(s.Ctx, claimed[1].Sequence, events.OutboxStatusFailed, 3, time.Now(), "boom"))
+
+	relay := events.NewOutboxRelay(s.store, &recordingProducer{}, events.RelayConfig{Retention: 24 * time.Hour})
+	n, err := relay.Cleanup(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(int64(1), n)
+
+	relay.SetNowFunc(func() time.Time { return time.Now().Add(48 * time.Hour) })
+	n, err = relay.Cleanup(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(int64(1), n)
+}
+
+func (s *OutboxStoreSuite) TestStartNotify() {
+	producer := &recordingProducer{}
+	relay := events.NewOutboxRelay(s.store, producer, events.RelayConfig{PollInterval: time.Hour})
+	s.Require().NoError(relay.Start(s.Ctx))
+	defer relay.Stop()
+
+	s.enqueue("o1", "e1", true)
+	relay.Notify()
+
+	s.Eventually(func() bool { return len(producer.ids()) == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestOutboxStoreSuite(t *testing.T) {
+	test.Run(t, new(OutboxStoreSuite))
+}
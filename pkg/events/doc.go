@@ -5,7 +5,12 @@ It defines a standard Event structure and a Bus interface for Publish/Subscribe
 This package is intended for local process constraints. For distributed messaging, see pkg/messaging.
 
 Outbox / NewOutboxBus bridge domain events to pkg/messaging.Producer for durable fan-out
-(PACKAGE_STANDARDS §9.5). They are not a transactional outbox store.
+(PACKAGE_STANDARDS §9.5). They publish immediately and are not atomic with database writes.
+
+For a transactional outbox, write records with an OutboxStore (adapters/sql Enqueue takes
+the caller's *sql.Tx) and run an OutboxRelay, which publishes committed records in order per
+aggregate, leases rows so several replicas can relay one table, retries failures with backoff,
+and purges dispatched and failed rows after a retention period.
 
 Topics use domain-based names (e.g. "users", "orders"). Event types use dot-notation
 (e.g. "user.created", "order.placed") — see PACKAGE_STANDARDS §9.
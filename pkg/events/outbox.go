@@ -20,30 +20,14 @@ type OutboxPayload struct {
 	Payload   json.RawMessage `json:"payload"`
 }
 
-// Outbox bridges in-process domain events to pkg/messaging for durable fan-out
-// (PACKAGE_STANDARDS §9.5). It does not provide a transactional store; callers
-// that need exactly-once should persist first, then call Publish.
-type Outbox struct {
-	producer messaging.Producer
-}
-
-// NewOutbox wraps a messaging.Producer.
-func NewOutbox(producer messaging.Producer) *Outbox {
-	return &Outbox{producer: producer}
-}
-
-// Publish serializes event and publishes it to the messaging producer.
-// The bus topic is stored in the envelope and as the Message.Topic when empty
-// on the producer default.
-func (o *Outbox) Publish(ctx context.Context, topic string, event Event) error {
-	if o == nil || o.producer == nil {
-		return errors.InvalidArgument("outbox producer is required", nil)
-	}
+// NewOutboxPayload validates event and builds its messaging envelope.
+// Missing IDs and timestamps are filled in.
+func NewOutboxPayload(topic string, event Event) (OutboxPayload, error) {
 	if topic == "" {
-		return ErrInvalidTopic(topic, nil)
+		return OutboxPayload{}, ErrInvalidTopic(topic, nil)
 	}
 	if event.Type == "" {
-		return ErrInvalidEvent("event type is required", nil)
+		return OutboxPayload{}, ErrInvalidEvent("event type is required", nil)
 	}
 
 	if event.ID == "" {
@@ -55,31 +39,65 @@ func (o *Outbox) Publish(ctx context.Context, topic string, event Event) error {
 
 	raw, err := json.Marshal(event.Payload)
 	if err != nil {
-		return errors.Internal("failed to marshal event payload", err)
+		return OutboxPayload{}, errors.Internal("failed to marshal event payload", err)
 	}
 
-	envelope, err := json.Marshal(OutboxPayload{
+	return OutboxPayload{
 		ID:        event.ID,
 		Type:      event.Type,
 		Source:    event.Source,
 		Timestamp: event.Timestamp,
 		Topic:     topic,
 		Payload:   raw,
-	})
+	}, nil
+}
+
+// Message converts the envelope into a messaging.Message addressed to p.Topic.
+func (p OutboxPayload) Message() (*messaging.Message, error) {
+	envelope, err := json.Marshal(p)
 	if err != nil {
-		return errors.Internal("failed to marshal outbox envelope", err)
+		return nil, errors.Internal("failed to marshal outbox envelope", err)
 	}
-
-	msg := &messaging.Message{
-		ID:      event.ID,
-		Topic:   topic,
+	return &messaging.Message{
+		ID:      p.ID,
+		Topic:   p.Topic,
 		Payload: envelope,
 		Headers: map[string]string{
-			"x-events-type":   event.Type,
-			"x-events-source": event.Source,
-			"x-events-topic":  topic,
+			"x-events-type":   p.Type,
+			"x-events-source": p.Source,
+			"x-events-topic":  p.Topic,
 		},
-		Timestamp: event.Timestamp,
+		Timestamp: p.Timestamp,
+	}, nil
+}
+
+// Outbox bridges in-process domain events to pkg/messaging for durable fan-out
+// (PACKAGE_STANDARDS §9.5). Publish sends immediately and is not atomic with
+// database writes; use an OutboxStore and OutboxRelay for a transactional outbox.
+type Outbox struct {
+	producer messaging.Producer
+}
+
+// NewOutbox wraps a messaging.Producer.
+func NewOutbox(producer messaging.Producer) *Outbox {
+	return &Outbox{producer: producer}
+}
+
+// Publish serializes event and publishes it to the messaging producer.
+// The bus topic is stored in the envelope and as the Message.Topic when empty
+// on the producer default.
+func (o *Outbox) Publish(ctx context.Context, topic string, event Event) error {
+	if o == nil || o.producer == nil {
+		return errors.InvalidArgument("outbox producer is required", nil)
+	}
+
+	payload, err := NewOutboxPayload(topic, event)
+	if err != nil {
+		return err
+	}
+	msg, err := payload.Message()
+	if err != nil {
+		return err
 	}
 	if err := o.producer.Publish(ctx, msg); err != nil {
 		return errors.Wrap(err, "outbox publish failed")
@@ -0,0 +1,358 @@
+package events
+
+import (
+	"context"
+	stderrors "errors"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/resilience"
+	"github.com/google/uuid"
+)
+
+// OutboxStatus is the dispatch state of an outbox record.
+type OutboxStatus string
+
+const (
+	// OutboxStatusPending records are waiting to be (re)published.
+	OutboxStatusPending OutboxStatus = "pending"
+	// OutboxStatusDispatched records were accepted by the producer.
+	OutboxStatusDispatched OutboxStatus = "dispatched"
+	// OutboxStatusFailed records exhausted RelayConfig.MaxAttempts and are no longer retried.
+	OutboxStatusFailed OutboxStatus = "failed"
+)
+
+// bookkeepingTimeout bounds store updates made after the relay context is cancelled.
+const bookkeepingTimeout = 5 * time.Second
+
+// OutboxRecord is a persisted OutboxPayload plus its dispatch bookkeeping.
+type OutboxRecord struct {
+	// Sequence is the store-assigned insertion order; relays publish in ascending order.
+	Sequence int64
+
+	// AggregateID groups records that must be published in order. Records with
+	// an empty AggregateID are ordered relative to each other.
+	AggregateID string
+
+	Payload OutboxPayload
+
+	Status        OutboxStatus
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	DispatchedAt  time.Time
+}
+
+// OutboxStore persists outbox records for an OutboxRelay.
+//
+// Records are written by the store's own transactional Enqueue (which is
+// backend-specific, e.g. it takes the caller's *sql.Tx); this interface covers
+// only what the relay and operators need.
+type OutboxStore interface {
+	// Claim leases up to limit publishable records to owner until now+lease and
+	// returns them ordered by Sequence. A record is publishable when it is
+	// pending, due (NextAttemptAt <= now), not leased by another owner, and no
+	// earlier pending record exists for its AggregateID. Concurrent relays
+	// therefore never receive the same record or overtake an aggregate's head.
+	Claim(ctx context.Context, owner string, limit int, now time.Time, lease time.Duration) ([]OutboxRecord, error)
+
+	// MarkDispatched flags the records with the given sequences as dispatched at at.
+	MarkDispatched(ctx context.Context, sequences []int64, at time.Time) error
+
+	// MarkFailed records a failed attempt and releases the lease. status is
+	// OutboxStatusPending when the record will be retried at next, or
+	// OutboxStatusFailed when it is given up on.
+	MarkFailed(ctx context.Context, sequence int64, status OutboxStatus, attempts int, next time.Time, cause string) error
+
+	// Failed returns up to limit records that exhausted their attempts, ordered by Sequence.
+	Failed(ctx context.Context, limit int) ([]OutboxRecord, error)
+
+	// Requeue resets failed records to pending with zero attempts so they are retried.
+	Requeue(ctx context.Context, sequences []int64) error
+
+	// Purge deletes dispatched records dispatched before olderThan and failed
+	// records created before olderThan.
+	Purge(ctx context.Context, olderThan time.Time) (int64, error)
+}
+
+// RelayConfig configures an OutboxRelay.
+type RelayConfig struct {
+	// PollInterval is how often the relay scans the store. Notify wakes it early.
+	PollInterval time.Duration `env:"EVENTS_OUTBOX_POLL_INTERVAL" env-default:"1s"`
+
+	// BatchSize is the maximum number of records claimed per scan.
+	BatchSize int `env:"EVENTS_OUTBOX_BATCH_SIZE" env-default:"100"`
+
+	// LeaseDuration is how long claimed records are reserved for this relay.
+	// It must exceed the time needed to publish a batch; an expired lease lets
+	// another relay claim (and republish) the records.
+	LeaseDuration time.Duration `env:"EVENTS_OUTBOX_LEASE" env-default:"30s"`
+
+	// MaxAttempts marks a record failed after this many publish errors.
+	// Zero retries forever and keeps per-aggregate ordering strict. Once a
+	// record is failed it no longer holds back its aggregate, so later records
+	// are published ahead of it; inspect and Requeue failed records via the store.
+	MaxAttempts int `env:"EVENTS_OUTBOX_MAX_ATTEMPTS" env-default:"0"`
+
+	// BaseBackoff and MaxBackoff bound the exponential delay between attempts.
+	BaseBackoff time.Duration `env:"EVENTS_OUTBOX_BASE_BACKOFF" env-default:"1s"`
+	MaxBackoff  time.Duration `env:"EVENTS_OUTBOX_MAX_BACKOFF" env-default:"5m"`
+
+	// Retention is how long dispatched and failed records are kept. Zero disables cleanup.
+	Retention time.Duration `env:"EVENTS_OUTBOX_RETENTION" env-default:"168h"`
+
+	// CleanupInterval is how often Purge runs when Retention is set.
+	CleanupInterval time.Duration `env:"EVENTS_OUTBOX_CLEANUP_INTERVAL" env-default:"1h"`
+}
+
+// DefaultRelayConfig returns RelayConfig with package defaults applied.
+func DefaultRelayConfig() RelayConfig {
+	return RelayConfig{
+		PollInterval:    time.Second,
+		BatchSize:       100,
+		LeaseDuration:   30 * time.Second,
+		BaseBackoff:     time.Second,
+		MaxBackoff:      5 * time.Minute,
+		Retention:       7 * 24 * time.Hour,
+		CleanupInterval: time.Hour,
+	}
+}
+
+// OutboxRelay publishes pending OutboxStore records to a messaging.Producer.
+//
+// Records sharing an AggregateID are published strictly in Sequence order: the
+// store only hands out an aggregate's oldest pending record, so a record
+// waiting for a retry holds back the rest of its aggregate. Several relays may
+// run against one store; claims are leased so each record goes to one relay.
+// Delivery is at-least-once (a crash between Publish and MarkDispatched causes
+// a redelivery), so consumers should deduplicate on the message ID.
+type OutboxRelay struct {
+	store    OutboxStore
+	producer messaging.Producer
+	cfg      RelayConfig
+	owner    string
+
+	mu      *concurrency.SmartMutex
+	nowFunc func() time.Time
+	running bool
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	wakeCh  chan struct{}
+}
+
+// NewOutboxRelay creates a relay. Zero-valued config fields take DefaultRelayConfig values.
+func NewOutboxRelay(store OutboxStore, producer messaging.Producer, cfg RelayConfig) *OutboxRelay {
+	def := DefaultRelayConfig()
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = def.LeaseDuration
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = def.BaseBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = def.MaxBackoff
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = def.CleanupInterval
+	}
+	return &OutboxRelay{
+		store:    store,
+		producer: producer,
+		cfg:      cfg,
+		owner:    uuid.NewString(),
+		mu:       concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "events-outbox-relay"}),
+		nowFunc:  func() time.Time { return time.Now().UTC() },
+		wakeCh:   make(chan struct{}, 1),
+	}
+}
+
+// SetNowFunc overrides the clock (test helper).
+func (r *OutboxRelay) SetNowFunc(fn func() time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if fn == nil {
+		r.nowFunc = func() time.Time { return time.Now().UTC() }
+		return
+	}
+	r.nowFunc = fn
+}
+
+func (r *OutboxRelay) now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.nowFunc().UTC()
+}
+
+// Start runs the relay loop in the background until ctx is done or Stop is called.
+func (r *OutboxRelay) Start(ctx context.Context) error {
+	if r.store == nil || r.producer == nil {
+		return errors.InvalidArgument("outbox store and producer are required", nil)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return errors.Conflict("outbox relay already running", nil)
+	}
+	r.running = true
+	r.stopCh = make(chan struct{})
+	r.doneCh = make(chan struct{})
+	go r.run(ctx, r.stopCh, r.doneCh)
+	return nil
+}
+
+// Stop halts the relay loop and waits for the in-flight scan to finish.
+// The relay may be started again afterwards.
+func (r *OutboxRelay) Stop() {
+	r.mu.Lock()
+	if !r.running {
+		r.mu.Unlock()
+		return
+	}
+	r.running = false
+	close(r.stopCh)
+	done := r.doneCh
+	r.mu.Unlock()
+	<-done
+}
+
+// Notify wakes the relay for an immediate scan, e.g. right after a commit
+// that enqueued records. It never blocks.
+func (r *OutboxRelay) Notify() {
+	select {
+	case r.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (r *OutboxRelay) run(ctx context.Context, stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var lastCleanup time.Time
+	for {
+		n, err := r.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.L().ErrorContext(ctx, "outbox relay scan failed", "error", err)
+		}
+		if r.cfg.Retention > 0 && time.Since(lastCleanup) >= r.cfg.CleanupInterval {
+			lastCleanup = time.Now()
+			if _, err := r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				logger.L().ErrorContext(ctx, "outbox relay cleanup failed", "error", err)
+			}
+		}
+
+		// Each scan releases at most one record per aggregate; keep draining
+		// while progress is made instead of waiting for the next tick.
+		if n > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stopCh:
+				return
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		case <-r.wakeCh:
+		}
+	}
+}
+
+// RunOnce performs a single scan and returns how many records were dispatched.
+func (r *OutboxRelay) RunOnce(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	records, err := r.store.Claim(ctx, r.owner, r.cfg.BatchSize, r.now(), r.cfg.LeaseDuration)
+	if err != nil {
+		return 0, err
+	}
+
+	// Bookkeeping must survive cancellation of ctx: losing it would republish
+	// records that were already accepted by the producer.
+	markCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), bookkeepingTimeout)
+	defer cancel()
+
+	dispatched := make([]int64, 0, len(records))
+	var errs []error
+
+	// Claim returns at most the head record of each aggregate, so a failure
+	// here cannot let a later record of the same aggregate overtake it.
+	for _, rec := range records {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := r.publish(ctx, rec); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			if ferr := r.fail(markCtx, rec, err); ferr != nil {
+				errs = append(errs, ferr)
+			}
+			continue
+		}
+		dispatched = append(dispatched, rec.Sequence)
+	}
+
+	n := 0
+	if len(dispatched) > 0 {
+		if err := r.store.MarkDispatched(markCtx, dispatched, r.now()); err != nil {
+			errs = append(errs, err)
+		} else {
+			n = len(dispatched)
+		}
+	}
+	return n, stderrors.Join(errs...)
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, rec OutboxRecord) error {
+	msg, err := rec.Payload.Message()
+	if err != nil {
+		return err
+	}
+	opts := []messaging.PublishOption{messaging.WithDeduplicationID(rec.Payload.ID)}
+	if rec.AggregateID != "" {
+		opts = append(opts, messaging.WithOrderingKey(rec.AggregateID), messaging.WithMessageGroupID(rec.AggregateID))
+	}
+	messaging.ApplyPublishOptions(msg, opts...)
+	return r.producer.Publish(ctx, msg)
+}
+
+func (r *OutboxRelay) fail(ctx context.Context, rec OutboxRecord, cause error) error {
+	attempts := rec.Attempts + 1
+	status := OutboxStatusPending
+	if r.cfg.MaxAttempts > 0 && attempts >= r.cfg.MaxAttempts {
+		status = OutboxStatusFailed
+		logger.L().ErrorContext(ctx, "outbox record exhausted retries",
+			"id", rec.Payload.ID, "topic", rec.Payload.Topic, "attempts", attempts, "error", cause)
+	}
+	next := r.now().Add(resilience.ExponentialBackoff(attempts-1, r.cfg.BaseBackoff, r.cfg.MaxBackoff, 0.1))
+	return r.store.MarkFailed(ctx, rec.Sequence, status, attempts, next, cause.Error())
+}
+
+// Cleanup purges dispatched and failed records older than RelayConfig.Retention.
+func (r *OutboxRelay) Cleanup(ctx context.Context) (int64, error) {
+	if r.cfg.Retention <= 0 {
+		return 0, nil
+	}
+	return r.store.Purge(ctx, r.now().Add(-r.cfg.Retention))
+}
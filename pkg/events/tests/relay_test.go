@@ -0,0 +1,310 @@
+package events_test
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/events"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/test"
+)
+
+// fakeOutboxStore is an in-memory events.OutboxStore honouring the Claim contract.
+type fakeOutboxStore struct {
+	mu          sync.Mutex
+	records     []*events.OutboxRecord
+	leases      map[int64]time.Time
+	claimNow    []time.Time
+	dispatchErr error
+	failErr     error
+}
+
+func newFakeOutboxStore() *fakeOutboxStore {
+	return &fakeOutboxStore{leases: make(map[int64]time.Time)}
+}
+
+func (f *fakeOutboxStore) add(aggregate, id string, next time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records = append(f.records, &events.OutboxRecord{
+		Sequence:      int64(len(f.records) + 1),
+		AggregateID:   aggregate,
+		Payload:       events.OutboxPayload{ID: id, Type: "order.placed", Topic: "orders"},
+		Status:        events.OutboxStatusPending,
+		NextAttemptAt: next,
+	})
+}
+
+func (f *fakeOutboxStore) get(seq int64) *events.OutboxRecord {
+	return f.records[seq-1]
+}
+
+func (f *fakeOutboxStore) Claim(ctx context.Context, owner string, limit int, now time.Time, lease time.Duration) ([]events.OutboxRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.claimNow = append(f.claimNow, now)
+	seen := make(map[string]bool)
+	var out []events.OutboxRecord
+	for _, rec := range f.records {
+		if rec.Status != events.OutboxStatusPending {
+			continue
+		}
+		head := !seen[rec.AggregateID]
+		seen[rec.AggregateID] = true
+		if !head || rec.NextAttemptAt.After(now) || f.leases[rec.Sequence].After(now) {
+			continue
+		}
+		f.leases[rec.Sequence] = now.Add(lease)
+		out = append(out, *rec)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeOutboxStore) MarkDispatched(ctx context.Context, sequences []int64, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.dispatchErr != nil {
+		return f.dispatchErr
+	}
+	for _, seq := range sequences {
+		rec := f.get(seq)
+		rec.Status = events.OutboxStatusDispatched
+		rec.DispatchedAt = at
+		delete(f.leases, seq)
+	}
+	return nil
+}
+
+func (f *fakeOutboxStore) MarkFailed(ctx context.Context, sequence int64, status events.OutboxStatus, attempts int, next time.Time, cause string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failErr != nil {
+		return f.failErr
+	}
+	rec := f.get(sequence)
+	rec.Status, rec.Attempts, rec.NextAttemptAt, rec.LastError = status, attempts, next, cause
+	delete(f.leases, sequence)
+	return nil
+}
+
+func (f *fakeOutboxStore) Failed(ctx context.Context, limit int) ([]events.OutboxRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []events.OutboxRecord
+	for _, rec := range f.records {
+		if rec.Status == events.OutboxStatusFailed && len(out) < limit {
+			out = append(out, *rec)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeOutboxStore) Requeue(ctx context.Context, sequences []int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, seq := range sequences {
+		rec := f.get(seq)
+		rec.Status, rec.Attempts = events.OutboxStatusPending, 0
+	}
+	return nil
+}
+
+func (f *fakeOutboxStore) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeOutboxStore) status(seq int64) events.OutboxStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.get(seq).Status
+}
+
+// scriptedProducer records publishes and fails or blocks on demand.
+type scriptedProducer struct {
+	mu      sync.Mutex
+	fail    map[string]bool
+	onPub   func(msg *messaging.Message)
+	publish []string
+}
+
+func (p *scriptedProducer) Publish(ctx context.Context, msg *messaging.Message) error {
+	p.mu.Lock()
+	failing := p.fail[msg.ID]
+	if !failing {
+		p.publish = append(p.publish, msg.ID)
+	}
+	hook := p.onPub
+	p.mu.Unlock()
+	if hook != nil {
+		hook(msg)
+	}
+	if failing {
+		return stderrors.New("broker unavailable")
+	}
+	return nil
+}
+
+func (p *scriptedProducer) PublishBatch(ctx context.Context, msgs []*messaging.Message) error {
+	for _, m := range msgs {
+		if err := p.Publish(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *scriptedProducer) Close() error { return nil }
+
+func (p *scriptedProducer) ids() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.publish...)
+}
+
+type OutboxRelaySuite struct {
+	test.Suite
+	store    *fakeOutboxStore
+	producer *scriptedProducer
+	now      time.Time
+}
+
+func (s *OutboxRelaySuite) SetupTest() {
+	s.Suite.SetupTest()
+	s.store = newFakeOutboxStore()
+	s.producer = &scriptedProducer{fail: map[string]bool{}}
+	s.now = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func (s *OutboxRelaySuite) relay(cfg events.RelayConfig) *events.OutboxRelay {
+	r := events.NewOutboxRelay(s.store, s.producer, cfg)
+	r.SetNowFunc(func() time.Time { return s.now })
+	return r
+}
+
+func (s *OutboxRelaySuite) TestSkipsRecordsNotYetDue() {
+	s.store.add("o1", "later", s.now.Add(time.Minute))
+	s.store.add("o2", "due", s.now)
+
+	r := s.relay(events.RelayConfig{})
+	n, err := r.RunOnce(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(1, n)
+	s.Equal([]string{"due"}, s.producer.ids())
+	s.Equal([]time.Time{s.now}, s.store.claimNow)
+
+	s.now = s.now.Add(time.Minute)
+	n, err = r.RunOnce(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(1, n)
+	s.Equal([]string{"due", "later"}, s.producer.ids())
+}
+
+func (s *OutboxRelaySuite) TestEmptyAggregateIsOrdered() {
+	s.store.add("", "e1", s.now)
+	s.store.add("", "e2", s.now)
+	s.producer.fail["e1"] = true
+
+	r := s.relay(events.RelayConfig{BaseBackoff: time.Second, MaxBackoff: time.Second})
+	_, err := r.RunOnce(s.Ctx)
+	s.Require().NoError(err)
+	s.Empty(s.producer.ids())
+
+	delete(s.producer.fail, "e1")
+	s.now = s.now.Add(2 * time.Second)
+	for i := 0; i < 2; i++ {
+		_, err = r.RunOnce(s.Ctx)
+		s.Require().NoError(err)
+	}
+	s.Equal([]string{"e1", "e2"}, s.producer.ids())
+}
+
+func (s *OutboxRelaySuite) TestMaxAttemptsMarksFailed() {
+	s.store.add("o1", "e1", s.now)
+	s.producer.fail["e1"] = true
+
+	r := s.relay(events.RelayConfig{MaxAttempts: 2, BaseBackoff: time.Second, MaxBackoff: time.Second})
+	_, err := r.RunOnce(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(events.OutboxStatusPending, s.store.status(1))
+
+	s.now = s.now.Add(2 * time.Second)
+	_, err = r.RunOnce(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal(events.OutboxStatusFailed, s.store.status(1))
+	s.Empty(s.producer.ids())
+}
+
+func (s *OutboxRelaySuite) TestCancelledScanStillMarksDispatched() {
+	s.store.add("o1", "e1", s.now)
+	s.store.add("o2", "e2", s.now)
+
+	ctx, cancel := context.WithCancel(s.Ctx)
+	defer cancel()
+	s.producer.onPub = func(*messaging.Message) { cancel() }
+
+	r := s.relay(events.RelayConfig{})
+	n, err := r.RunOnce(ctx)
+	s.Require().NoError(err)
+	s.Equal(1, n)
+	s.Equal(events.OutboxStatusDispatched, s.store.status(1))
+	s.Equal(events.OutboxStatusPending, s.store.status(2))
+}
+
+func (s *OutboxRelaySuite) TestBookkeepingErrorsAreJoined() {
+	s.store.add("o1", "e1", s.now)
+	s.store.add("o2", "e2", s.now)
+	s.producer.fail["e1"] = true
+	s.store.failErr = stderrors.New("mark failed broke")
+	s.store.dispatchErr = stderrors.New("mark dispatched broke")
+
+	r := s.relay(events.RelayConfig{})
+	n, err := r.RunOnce(s.Ctx)
+	s.Equal(0, n)
+	s.Require().Error(err)
+	s.ErrorContains(err, "mark failed broke")
+	s.ErrorContains(err, "mark dispatched broke")
+}
+
+func (s *OutboxRelaySuite) TestStartTwiceConflicts() {
+	r := s.relay(events.RelayConfig{PollInterval: time.Hour})
+	s.Require().NoError(r.Start(s.Ctx))
+	defer r.Stop()
+
+	err := r.Start(s.Ctx)
+	s.Require().Error(err)
+	var appErr *errors.AppError
+	s.Require().ErrorAs(err, &appErr)
+	s.Equal(errors.CodeConflict, appErr.Code)
+}
+
+func (s *OutboxRelaySuite) TestStopAndRestart() {
+	r := s.relay(events.RelayConfig{PollInterval: time.Hour})
+	s.Require().NoError(r.Start(s.Ctx))
+	r.Stop()
+	r.Stop()
+
+	s.store.add("o1", "e1", s.now)
+	s.store.add("o1", "e2", s.now)
+	s.Require().NoError(r.Start(s.Ctx))
+	defer r.Stop()
+
+	s.Eventually(func() bool { return len(s.producer.ids()) == 2 }, time.Second, 5*time.Millisecond)
+	s.Equal([]string{"e1", "e2"}, s.producer.ids())
+}
+
+func TestOutboxRelaySuite(t *testing.T) {
+	test.Run(t, new(OutboxRelaySuite))
+}
@@ -4,6 +4,11 @@
 // Publish returns messaging.ErrQueueFull when any subscriber channel is full
 // instead of silently dropping messages.
 //
+// Config.MaxDeliveries redelivers messages whose handler fails (setting
+// Metadata.DeliveryCount), and Config.RetainUndelivered keeps messages
+// published while a topic has no consumers, so dead-letter and redrive flows
+// can be tested without a real broker.
+//
 //	broker := memory.New(memory.Config{BufferSize: 100})
 //	producer, _ := broker.Producer("my-topic")
 //	consumer, _ := broker.Consumer("my-topic", "my-group")
@@ -15,6 +15,19 @@ type Config struct {
 	// BufferSize is the channel buffer size for each topic.
 	// Larger values allow more messages to be buffered before blocking.
 	BufferSize int `env:"MEMORY_BUFFER_SIZE" env-default:"1000"`
+
+	// MaxDeliveries is how many times a message is delivered to a consumer
+	// group before a failing handler's message is dropped (default 10, above
+	// DeadLetterConfig's default MaxAttempts so a DeadLetterConsumer gets to
+	// dead-letter it). 1 never redelivers. Each delivery sets
+	// Metadata.DeliveryCount.
+	MaxDeliveries int `env:"MEMORY_MAX_DELIVERIES" env-default:"10"`
+
+	// RetainUndelivered keeps messages published to a topic without
+	// consumers (up to BufferSize) and hands them to the next consumer group,
+	// like a durable queue. Messages still buffered when the last consumer
+	// closes are retained too.
+	RetainUndelivered bool `env:"MEMORY_RETAIN_UNDELIVERED" env-default:"false"`
 }
 
 // Broker is an in-memory message broker implementation.
@@ -29,6 +42,7 @@ type topic struct {
 	mu          *concurrency.SmartRWMutex
 	name        string
 	subscribers map[string]chan *messaging.Message // group -> channel
+	backlog     []*messaging.Message               // retained while there are no subscribers
 }
 
 // New creates a new in-memory broker.
@@ -36,6 +50,9 @@ func New(cfg Config) *Broker {
 	if cfg.BufferSize <= 0 {
 		cfg.BufferSize = 1000
 	}
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = 10
+	}
 	return &Broker{
 		config: cfg,
 		topics: make(map[string]*topic),
@@ -94,6 +111,10 @@ func (b *Broker) Consumer(topicName string, group string) (messaging.Consumer, e
 	t.mu.Lock()
 	ch := make(chan *messaging.Message, b.config.BufferSize)
 	t.subscribers[group] = ch
+	for _, msg := range t.backlog {
+		ch <- msg
+	}
+	t.backlog = nil
 	t.mu.Unlock()
 
 	return &consumer{
@@ -157,14 +178,29 @@ func (p *producer) Publish(ctx context.Context, msg *messaging.Message) error {
 		return messaging.ErrClosed(nil)
 	}
 
-	p.topic.mu.RLock()
-	defer p.topic.mu.RUnlock()
+	if p.broker.config.RetainUndelivered {
+		p.topic.mu.Lock()
+		defer p.topic.mu.Unlock()
+		if len(p.topic.subscribers) == 0 {
+			if len(p.topic.backlog) >= p.broker.config.BufferSize {
+				return messaging.ErrQueueFull(nil)
+			}
+			m := *msg
+			p.topic.backlog = append(p.topic.backlog, &m)
+			return nil
+		}
+	} else {
+		p.topic.mu.RLock()
+		defer p.topic.mu.RUnlock()
+	}
 
 	// Fan-out to all subscribers. BufferSize is the channel capacity; a full
-	// subscriber returns ErrQueueFull instead of dropping the message.
+	// subscriber returns ErrQueueFull instead of dropping the message. Each
+	// group gets its own copy so delivery metadata is not shared.
 	for _, ch := range p.topic.subscribers {
+		m := *msg
 		select {
-		case ch <- msg:
+		case ch <- &m:
 		case <-ctx.Done():
 			return messaging.ErrTimeout("publish", ctx.Err())
 		default:
@@ -207,15 +243,33 @@ func (c *consumer) Consume(ctx context.Context, handler messaging.MessageHandler
 			if !ok {
 				return nil // Channel closed
 			}
+			msg.Metadata.DeliveryCount++
 			if err := handler(ctx, msg); err != nil {
-				// In memory, we just log and continue
-				// Real implementations might requeue
+				c.redeliver(msg)
 				continue
 			}
 		}
 	}
 }
 
+// redeliver requeues a failed message until MaxDeliveries; after that (or
+// when the buffer is full) it is dropped.
+func (c *consumer) redeliver(msg *messaging.Message) {
+	if msg.Metadata.DeliveryCount >= c.broker.config.MaxDeliveries {
+		return
+	}
+	// Holding the broker lock keeps Close from closing c.ch mid-send.
+	c.broker.mu.RLock()
+	defer c.broker.mu.RUnlock()
+	if c.broker.closed {
+		return
+	}
+	select {
+	case c.ch <- msg:
+	default:
+	}
+}
+
 func (c *consumer) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -227,7 +281,28 @@ func (c *consumer) Close() error {
 
 	c.topic.mu.Lock()
 	delete(c.topic.subscribers, c.group)
+	if c.broker.config.RetainUndelivered && len(c.topic.subscribers) == 0 {
+		c.retainBuffered()
+	}
 	c.topic.mu.Unlock()
 
 	return nil
 }
+
+// retainBuffered moves messages left in the channel back to the topic
+// backlog. Callers hold c.topic.mu.
+func (c *consumer) retainBuffered() {
+	for {
+		select {
+		case msg, ok := <-c.ch:
+			if !ok {
+				return
+			}
+			if len(c.topic.backlog) < c.broker.config.BufferSize {
+				c.topic.backlog = append(c.topic.backlog, msg)
+			}
+		default:
+			return
+		}
+	}
+}
@@ -2,21 +2,80 @@ package messaging
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"time"
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/datastructures/bloomfilter"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+	"github.com/google/uuid"
 )
 
+// ClaimResult is the outcome of DedupStore.Claim.
+type ClaimResult int
+
+const (
+	// ClaimAcquired means the caller now owns the key and should process it.
+	ClaimAcquired ClaimResult = iota
+	// ClaimProcessed means the key was already processed successfully.
+	ClaimProcessed
+	// ClaimInFlight means another consumer holds an unexpired claim.
+	ClaimInFlight
+)
+
+// DedupStore persists which messages were processed, so deduplication holds
+// across restarts, rebalances and replicas of a consumer group. A key moves
+// from claimed (for Lease) to processed (for TTL) only after the handler
+// succeeds; a failure releases the claim so a redelivery is processed.
+// See pkg/messaging/dedupstore for cache.Cache and SQL implementations.
+//
+// Every claim records an owner token, unique per attempt. Complete and
+// Release only act on a claim the owner still holds, so a handler that
+// overruns its lease cannot finish or drop a claim another replica has
+// since taken over.
+type DedupStore interface {
+	// Claim atomically reserves key for owner for lease unless it is
+	// processed or claimed by someone else. An expired claim can be taken
+	// over.
+	Claim(ctx context.Context, key, owner string, lease time.Duration) (ClaimResult, error)
+
+	// Complete marks key as processed for ttl if owner still holds its
+	// claim, and returns ErrClaimLost otherwise.
+	Complete(ctx context.Context, key, owner string, ttl time.Duration) error
+
+	// Release drops owner's claim without marking the key processed. It
+	// does nothing if owner no longer holds the claim.
+	Release(ctx context.Context, key, owner string) error
+}
+
 // DeduplicatingConsumer wraps a Consumer with message deduplication using a Bloom filter.
 // This prevents processing the same message multiple times in at-least-once delivery systems.
 //
 // Note: Due to Bloom filter properties, there's a small chance of false positives
 // (skipping a message we haven't seen). Set the false positive rate appropriately.
+//
+// With WithDedupStore the Bloom filter is replaced by a persistent DedupStore
+// and duplicates are detected exactly.
 type DeduplicatingConsumer struct {
 	consumer Consumer
 	bloom    *bloomfilter.BloomFilter
 	inFlight map[string]struct{}
 	mu       *concurrency.SmartRWMutex
+	store    DedupStore
+	cfg      DeduplicationConfig
+}
+
+// DeduplicationOption configures a DeduplicatingConsumer.
+type DeduplicationOption func(*DeduplicatingConsumer)
+
+// WithDedupStore keeps processed message keys in store instead of the
+// in-process Bloom filter.
+func WithDedupStore(store DedupStore) DeduplicationOption {
+	return func(dc *DeduplicatingConsumer) {
+		dc.store = store
+	}
 }
 
 // DeduplicationConfig configures the deduplication filter.
@@ -27,25 +86,52 @@ type DeduplicationConfig struct {
 	// FalsePositiveRate is the acceptable false positive rate.
 	// Lower = more memory but fewer false skips.
 	FalsePositiveRate float64 `env:"MSG_DEDUP_FPR" env-default:"0.001"`
+
+	// TTL is how long a DedupStore remembers a processed message. It should
+	// exceed the broker's redelivery and retention window.
+	TTL time.Duration `env:"MSG_DEDUP_TTL" env-default:"24h"`
+
+	// Lease bounds how long a DedupStore claim blocks other consumers if the
+	// claiming process dies mid-handler. It should exceed handler runtime.
+	Lease time.Duration `env:"MSG_DEDUP_LEASE" env-default:"5m"`
 }
 
-// NewDeduplicatingConsumer wraps a consumer with Bloom filter deduplication.
-func NewDeduplicatingConsumer(consumer Consumer, cfg DeduplicationConfig) *DeduplicatingConsumer {
+// NewDeduplicatingConsumer wraps a consumer with Bloom filter deduplication,
+// or with a persistent DedupStore when WithDedupStore is given.
+func NewDeduplicatingConsumer(consumer Consumer, cfg DeduplicationConfig, opts ...DeduplicationOption) *DeduplicatingConsumer {
 	if cfg.ExpectedMessages == 0 {
 		cfg.ExpectedMessages = 1000000
 	}
 	if cfg.FalsePositiveRate <= 0 {
 		cfg.FalsePositiveRate = 0.001
 	}
-	return &DeduplicatingConsumer{
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 5 * time.Minute
+	}
+	dc := &DeduplicatingConsumer{
 		consumer: consumer,
-		bloom:    bloomfilter.New(cfg.ExpectedMessages, cfg.FalsePositiveRate),
 		inFlight: make(map[string]struct{}),
 		mu:       concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "DeduplicatingConsumer"}),
+		cfg:      cfg,
+	}
+	for _, opt := range opts {
+		opt(dc)
 	}
+	if dc.store == nil {
+		dc.bloom = bloomfilter.New(cfg.ExpectedMessages, cfg.FalsePositiveRate)
+	}
+	return dc
 }
 
 func (dc *DeduplicatingConsumer) Consume(ctx context.Context, handler MessageHandler) error {
+	if dc.store != nil {
+		return dc.consumer.Consume(ctx, func(ctx context.Context, msg *Message) error {
+			return dc.handleWithStore(ctx, msg, handler)
+		})
+	}
 	return dc.consumer.Consume(ctx, func(ctx context.Context, msg *Message) error {
 		dedupKey := dc.getDeduplicationKey(msg)
 
@@ -76,10 +162,49 @@ func (dc *DeduplicatingConsumer) Consume(ctx context.Context, handler MessageHan
 	})
 }
 
+// handleWithStore runs handler under a DedupStore claim. The key is marked
+// processed only if the handler succeeds; on failure the claim is released
+// so the broker's redelivery is processed.
+func (dc *DeduplicatingConsumer) handleWithStore(ctx context.Context, msg *Message, handler MessageHandler) error {
+	key := dc.getDeduplicationKey(msg)
+	owner := uuid.NewString()
+	claim, err := dc.store.Claim(ctx, key, owner, dc.cfg.Lease)
+	if err != nil {
+		return ErrConsumeFailed(err)
+	}
+	switch claim {
+	case ClaimProcessed:
+		return nil
+	case ClaimInFlight:
+		return ErrInFlight(key)
+	}
+
+	if err := handler(ctx, msg); err != nil {
+		// Release even if ctx is done so redelivery is not blocked for Lease.
+		if relErr := dc.store.Release(context.WithoutCancel(ctx), key, owner); relErr != nil {
+			return stderrors.Join(err, relErr)
+		}
+		return err
+	}
+	if err := dc.store.Complete(context.WithoutCancel(ctx), key, owner, dc.cfg.TTL); err != nil {
+		// The work is done; failing here would redeliver it once the lease
+		// expires. Log and acknowledge.
+		logger.L().ErrorContext(ctx, "failed to mark message processed", "key_fp", keyFingerprint(key), "error", err)
+	}
+	return nil
+}
+
 func (dc *DeduplicatingConsumer) Close() error {
 	return dc.consumer.Close()
 }
 
+// keyFingerprint returns a short non-reversible hash of a dedup key for logs
+// and errors; keys built from payloads may carry sensitive data.
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
 func (dc *DeduplicatingConsumer) getDeduplicationKey(msg *Message) string {
 	if msg.ID != "" {
 		return msg.ID
@@ -87,10 +212,14 @@ func (dc *DeduplicatingConsumer) getDeduplicationKey(msg *Message) string {
 	return msg.Topic + ":" + string(msg.Payload)
 }
 
-// Stats returns deduplication statistics.
+// Stats returns deduplication statistics. They are zero when a DedupStore
+// is used.
 func (dc *DeduplicatingConsumer) Stats() DeduplicationStats {
 	dc.mu.RLock()
 	defer dc.mu.RUnlock()
+	if dc.bloom == nil {
+		return DeduplicationStats{}
+	}
 	return DeduplicationStats{
 		TrackedMessages:   dc.bloom.Count(),
 		FalsePositiveRate: dc.bloom.EstimatedFalsePositiveRate(),
@@ -103,12 +232,14 @@ type DeduplicationStats struct {
 	FalsePositiveRate float64
 }
 
-// Reset clears the deduplication filter.
+// Reset clears the deduplication filter. It does not touch a DedupStore.
 // Use with caution - messages seen before reset may be reprocessed.
 func (dc *DeduplicatingConsumer) Reset() {
 	dc.mu.Lock()
 	defer dc.mu.Unlock()
-	dc.bloom.Clear()
+	if dc.bloom != nil {
+		dc.bloom.Clear()
+	}
 	dc.inFlight = make(map[string]struct{})
 }
 
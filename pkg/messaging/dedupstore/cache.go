@@ -0,0 +1,134 @@
+package dedupstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/cache"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging"
+)
+
+// Ensure compile-time interface compliance.
+var _ messaging.DedupStore = (*CacheStore)(nil)
+
+// CacheConfig configures a CacheStore.
+type CacheConfig struct {
+	// Prefix namespaces keys in the cache.
+	Prefix string `env:"MSG_DEDUP_CACHE_PREFIX" env-default:"msgdedup:"`
+}
+
+// CacheStore is a messaging.DedupStore on top of cache.Cache. Claims use the
+// atomic Incr so only one replica acquires a key, and the winner records its
+// owner token beside the claim; processed markers are plain keys with a TTL.
+//
+// cache.Cache has no compare-and-delete, so Complete and Release check the
+// owner and then act. A lease that expires and is taken over in between can
+// still be affected; keep Lease well above handler run time.
+type CacheStore struct {
+	cache  cache.Cache
+	prefix string
+}
+
+// NewCache creates a CacheStore backed by c.
+func NewCache(c cache.Cache, cfg CacheConfig) *CacheStore {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "msgdedup:"
+	}
+	return &CacheStore{cache: c, prefix: cfg.Prefix}
+}
+
+func (s *CacheStore) claimKey(key string) string { return s.prefix + "claim:" + key }
+
+func (s *CacheStore) ownerKey(key string) string { return s.prefix + "owner:" + key }
+
+func (s *CacheStore) doneKey(key string) string { return s.prefix + "done:" + key }
+
+// Claim implements messaging.DedupStore.
+func (s *CacheStore) Claim(ctx context.Context, key, owner string, lease time.Duration) (messaging.ClaimResult, error) {
+	done, err := s.cache.Exists(ctx, s.doneKey(key))
+	if err != nil {
+		return 0, err
+	}
+	if done {
+		return messaging.ClaimProcessed, nil
+	}
+
+	n, err := s.cache.Incr(ctx, s.claimKey(key), 1)
+	if err != nil {
+		return 0, err
+	}
+	if n > 1 {
+		// Heal a claim whose TTL was never set (process died between Incr
+		// and Expire) so it cannot block the key forever.
+		if ttl, err := s.cache.GetTTL(ctx, s.claimKey(key)); err == nil && ttl < 0 {
+			_ = s.cache.Expire(ctx, s.claimKey(key), lease)
+		}
+		return messaging.ClaimInFlight, nil
+	}
+	if err := s.cache.Expire(ctx, s.claimKey(key), lease); err != nil {
+		_ = s.cache.Delete(ctx, s.claimKey(key))
+		return 0, err
+	}
+	if err := s.cache.Set(ctx, s.ownerKey(key), owner, lease); err != nil {
+		_ = s.cache.Delete(ctx, s.claimKey(key))
+		return 0, err
+	}
+
+	// Complete sets the marker before dropping the claim, so re-checking
+	// here closes the race with a consumer that just finished.
+	done, err = s.cache.Exists(ctx, s.doneKey(key))
+	if err != nil || done {
+		_ = s.release(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		return messaging.ClaimProcessed, nil
+	}
+	return messaging.ClaimAcquired, nil
+}
+
+// holds reports whether owner still holds the claim on key. A key nobody
+// has claimed counts as held, as in SQLStore: the claim merely expired.
+func (s *CacheStore) holds(ctx context.Context, key, owner string) (bool, error) {
+	var current string
+	err := s.cache.Get(ctx, s.ownerKey(key), &current)
+	if cache.IsNotFound(err) {
+		claimed, err := s.cache.Exists(ctx, s.claimKey(key))
+		return !claimed, err
+	}
+	if err != nil {
+		return false, err
+	}
+	return current == owner, nil
+}
+
+func (s *CacheStore) release(ctx context.Context, key string) error {
+	if err := s.cache.Delete(ctx, s.claimKey(key)); err != nil {
+		return err
+	}
+	return s.cache.Delete(ctx, s.ownerKey(key))
+}
+
+// Complete implements messaging.DedupStore.
+func (s *CacheStore) Complete(ctx context.Context, key, owner string, ttl time.Duration) error {
+	ok, err := s.holds(ctx, key, owner)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return messaging.ErrClaimLost(nil)
+	}
+	if err := s.cache.Set(ctx, s.doneKey(key), true, ttl); err != nil {
+		return err
+	}
+	return s.release(ctx, key)
+}
+
+// Release implements messaging.DedupStore.
+func (s *CacheStore) Release(ctx context.Context, key, owner string) error {
+	ok, err := s.holds(ctx, key, owner)
+	if err != nil || !ok {
+		return err
+	}
+	return s.release(ctx, key)
+}
@@ -0,0 +1,236 @@
+package dedupstore_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cachememory "github.com/chris-alexander-pop/go-hyperforge/pkg/cache/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging/dedupstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func newSQLStore(t *testing.T) *dedupstore.SQLStore {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	store, err := dedupstore.NewSQL(db, dedupstore.SQLConfig{Dialect: dedupstore.DialectSQLite})
+	require.NoError(t, err)
+	require.NoError(t, store.Migrate(context.Background()))
+	return store
+}
+
+func stores(t *testing.T) map[string]messaging.DedupStore {
+	return map[string]messaging.DedupStore{
+		"cache": dedupstore.NewCache(cachememory.New(), dedupstore.CacheConfig{}),
+		"sql":   newSQLStore(t),
+	}
+}
+
+func TestClaimLifecycle(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			res, err := store.Claim(ctx, "m1", "owner", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, messaging.ClaimAcquired, res)
+
+			res, err = store.Claim(ctx, "m1", "owner", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, messaging.ClaimInFlight, res)
+
+			require.NoError(t, store.Release(ctx, "m1", "owner"))
+			res, err = store.Claim(ctx, "m1", "owner", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, messaging.ClaimAcquired, res, "a released key can be claimed again")
+
+			require.NoError(t, store.Complete(ctx, "m1", "owner", time.Hour))
+			res, err = store.Claim(ctx, "m1", "owner", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, messaging.ClaimProcessed, res)
+
+			require.NoError(t, store.Release(ctx, "m1", "owner"))
+			res, err = store.Claim(ctx, "m1", "owner", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, messaging.ClaimProcessed, res, "release does not forget processed keys")
+		})
+	}
+}
+
+func TestConcurrentClaimsHaveOneWinner(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			var won atomic.Int32
+			for i := 0; i < 16; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res, err := store.Claim(context.Background(), "hot", "owner", time.Minute)
+					assert.NoError(t, err)
+					if res == messaging.ClaimAcquired {
+						won.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int32(1), won.Load())
+		})
+	}
+}
+
+func TestSQLExpiry(t *testing.T) {
+	store := newSQLStore(t)
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.SetNowFunc(func() time.Time { return now })
+
+	res, err := store.Claim(ctx, "a", "owner", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, messaging.ClaimAcquired, res)
+	require.NoError(t, store.Complete(ctx, "b", "owner", time.Hour))
+
+	now = now.Add(2 * time.Minute)
+	res, err = store.Claim(ctx, "a", "owner", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, messaging.ClaimAcquired, res, "an abandoned claim is taken over after its lease")
+
+	now = now.Add(2 * time.Hour)
+	res, err = store.Claim(ctx, "b", "owner", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, messaging.ClaimAcquired, res, "processed markers expire after the TTL")
+
+	now = now.Add(time.Hour)
+	n, err := store.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
+
+func TestCacheLeaseExpiry(t *testing.T) {
+	store := dedupstore.NewCache(cachememory.New(), dedupstore.CacheConfig{Prefix: "t:"})
+	ctx := context.Background()
+	res, err := store.Claim(ctx, "a", "owner", 20*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, messaging.ClaimAcquired, res)
+	assert.Eventually(t, func() bool {
+		res, err := store.Claim(ctx, "a", "owner", time.Minute)
+		return err == nil && res == messaging.ClaimAcquired
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestOverrunningOwnerCannotTouchTakenOverClaim(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sqlStore := newSQLStore(t)
+	sqlStore.SetNowFunc(func() time.Time { return now })
+	cases := map[string]struct {
+		store  messaging.DedupStore
+		lease  time.Duration
+		expire func()
+	}{
+		"sql":   {sqlStore, time.Minute, func() { now = now.Add(2 * time.Minute) }},
+		"cache": {dedupstore.NewCache(cachememory.New(), dedupstore.CacheConfig{}), 20 * time.Millisecond, func() { time.Sleep(40 * time.Millisecond) }},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			res, err := tc.store.Claim(ctx, "m", "slow", tc.lease)
+			require.NoError(t, err)
+			require.Equal(t, messaging.ClaimAcquired, res)
+
+			tc.expire()
+			res, err = tc.store.Claim(ctx, "m", "fast", time.Hour)
+			require.NoError(t, err)
+			require.Equal(t, messaging.ClaimAcquired, res, "the expired claim is taken over")
+
+			// The slow handler finally returns: it must not drop or finish
+			// the claim it no longer holds.
+			require.NoError(t, tc.store.Release(ctx, "m", "slow"))
+			err = tc.store.Complete(ctx, "m", "slow", time.Hour)
+			assert.True(t, errors.IsCode(err, messaging.CodeClaimLost), "got %v", err)
+
+			res, err = tc.store.Claim(ctx, "m", "other", time.Hour)
+			require.NoError(t, err)
+			assert.Equal(t, messaging.ClaimInFlight, res, "the new owner still holds the claim")
+
+			require.NoError(t, tc.store.Complete(ctx, "m", "fast", time.Hour))
+			res, err = tc.store.Claim(ctx, "m", "other", time.Hour)
+			require.NoError(t, err)
+			assert.Equal(t, messaging.ClaimProcessed, res)
+		})
+	}
+}
+
+// listConsumer delivers a fixed list of messages, like a broker replaying a
+// partition after a restart.
+type listConsumer struct {
+	msgs []*messaging.Message
+	errs []error
+}
+
+func (l *listConsumer) Consume(ctx context.Context, handler messaging.MessageHandler) error {
+	for _, msg := range l.msgs {
+		l.errs = append(l.errs, handler(ctx, msg))
+	}
+	return nil
+}
+
+func (l *listConsumer) Close() error { return nil }
+
+func TestConsumerSurvivesRestart(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			cfg := messaging.DeduplicationConfig{TTL: time.Hour, Lease: time.Minute}
+			msgs := []*messaging.Message{{ID: "pay-1"}, {ID: "pay-2"}, {ID: "pay-1"}}
+
+			var processed []string
+			handler := func(ctx context.Context, msg *messaging.Message) error {
+				if msg.ID == "pay-2" && len(processed) < 2 {
+					processed = append(processed, "fail:"+msg.ID)
+					return fmt.Errorf("gateway timeout")
+				}
+				processed = append(processed, msg.ID)
+				return nil
+			}
+
+			first := &listConsumer{msgs: msgs}
+			require.NoError(t, messaging.NewDeduplicatingConsumer(first, cfg, messaging.WithDedupStore(store)).Consume(ctx, handler))
+			assert.Equal(t, []string{"pay-1", "fail:pay-2"}, processed)
+			require.Error(t, first.errs[1])
+			assert.NoError(t, first.errs[2], "duplicates are acknowledged")
+
+			// "Restart": a new consumer (fresh process memory) on the same store.
+			second := &listConsumer{msgs: msgs}
+			require.NoError(t, messaging.NewDeduplicatingConsumer(second, cfg, messaging.WithDedupStore(store)).Consume(ctx, handler))
+			assert.Equal(t, []string{"pay-1", "fail:pay-2", "pay-2"}, processed, "only the failed message is retried")
+		})
+	}
+}
+
+func TestConsumerInFlightElsewhere(t *testing.T) {
+	store := newSQLStore(t)
+	ctx := context.Background()
+	_, err := store.Claim(ctx, "m", "replica-b", time.Minute) // another replica is working on it
+	require.NoError(t, err)
+
+	lc := &listConsumer{msgs: []*messaging.Message{{ID: "m"}}}
+	called := false
+	require.NoError(t, messaging.NewDeduplicatingConsumer(lc, messaging.DeduplicationConfig{}, messaging.WithDedupStore(store)).
+		Consume(ctx, func(ctx context.Context, msg *messaging.Message) error {
+			called = true
+			return nil
+		}))
+	assert.False(t, called)
+	assert.True(t, errors.IsCode(lc.errs[0], messaging.CodeInFlight), "nacked so the broker redelivers")
+}
@@ -0,0 +1,24 @@
+/*
+Package dedupstore provides persistent messaging.DedupStore implementations
+for messaging.DeduplicatingConsumer:
+
+  - NewCache stores claims and processed markers in any cache.Cache (use the
+    Redis adapter so replicas share state).
+  - NewSQL stores them in one table (PostgreSQL or SQLite) with TTL-based
+    expiry; call Migrate once at startup and DeleteExpired periodically.
+
+A key is claimed for a lease before the handler runs, marked processed only
+after the handler succeeds, and released on failure, so a failed attempt is
+never recorded as processed. Each claim records an owner token, so a handler
+that overruns its lease cannot complete or release a claim another replica
+has taken over.
+
+Usage:
+
+	store := dedupstore.NewCache(redisCache, dedupstore.CacheConfig{})
+	consumer := messaging.NewDeduplicatingConsumer(base, messaging.DeduplicationConfig{
+		TTL:   72 * time.Hour,
+		Lease: time.Minute,
+	}, messaging.WithDedupStore(store))
+*/
+package dedupstore
@@ -0,0 +1,232 @@
+package dedupstore
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/resilience"
+)
+
+// Ensure compile-time interface compliance.
+var _ messaging.DedupStore = (*SQLStore)(nil)
+
+// Dialect selects SQL placeholder style.
+type Dialect int
+
+const (
+	// DialectSQLite uses ? placeholders.
+	DialectSQLite Dialect = iota
+	// DialectPostgres uses $1, $2, ... placeholders.
+	DialectPostgres
+)
+
+const (
+	defaultTable = "messaging_dedup"
+
+	stateClaimed = "claimed"
+	stateDone    = "done"
+)
+
+// SQLConfig configures a SQLStore.
+type SQLConfig struct {
+	// Dialect selects placeholder style (SQLite ? vs Postgres $n).
+	Dialect Dialect
+
+	// Table defaults to "messaging_dedup".
+	Table string
+
+	// Retrier wraps DB I/O; nil uses resilience.DefaultRetryConfig.
+	Retrier resilience.Retrier
+}
+
+// SQLStore is a messaging.DedupStore backed by a single table keyed by
+// message key. Each row records the owner of its claim. Expiry times are
+// Unix nanoseconds; expired rows are ignored by Claim and removed by
+// DeleteExpired.
+type SQLStore struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+	retrier resilience.Retrier
+	now     func() time.Time
+}
+
+// NewSQL wraps an existing *sql.DB. Call Migrate before use.
+func NewSQL(db *sql.DB, cfg SQLConfig) (*SQLStore, error) {
+	if db == nil {
+		return nil, errors.InvalidArgument("db is required", nil)
+	}
+	table := cfg.Table
+	if table == "" {
+		table = defaultTable
+	}
+	retrier := cfg.Retrier
+	if retrier == nil {
+		retrier = resilience.NewRetrier(resilience.DefaultRetryConfig())
+	}
+	return &SQLStore{db: db, dialect: cfg.Dialect, table: table, retrier: retrier, now: time.Now}, nil
+}
+
+// SetNowFunc overrides the clock (tests).
+func (s *SQLStore) SetNowFunc(fn func() time.Time) {
+	if fn != nil {
+		s.now = fn
+	}
+}
+
+func (s *SQLStore) rewrite(query string) string {
+	query = strings.ReplaceAll(query, "{table}", s.table)
+	if s.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteByte(query[i])
+	}
+	return b.String()
+}
+
+// Migrate creates the dedup table and its expiry index if missing.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS {table} (
+	dedup_key VARCHAR(512) PRIMARY KEY,
+	state VARCHAR(16) NOT NULL,
+	owner VARCHAR(64) NOT NULL,
+	expires_at BIGINT NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_{table}_expires ON {table}(expires_at)`,
+	}
+	return s.retrier.Execute(ctx, func(ctx context.Context) error {
+		for _, stmt := range stmts {
+			if _, err := s.db.ExecContext(ctx, s.rewrite(stmt)); err != nil {
+				return errors.Internal("migrate dedup table failed", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Claim implements messaging.DedupStore. The upsert only overwrites a row
+// whose claim or processed marker has expired, so exactly one caller wins.
+func (s *SQLStore) Claim(ctx context.Context, key, owner string, lease time.Duration) (messaging.ClaimResult, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	now := s.now()
+	result := messaging.ClaimInFlight
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		res, err := s.db.ExecContext(ctx, s.rewrite(`INSERT INTO {table} (dedup_key, state, owner, expires_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (dedup_key) DO UPDATE SET state = excluded.state, owner = excluded.owner, expires_at = excluded.expires_at
+WHERE {table}.expires_at <= ?`),
+			key, stateClaimed, owner, now.Add(lease).UnixNano(), now.UnixNano())
+		if err != nil {
+			return errors.Internal("claim dedup key failed", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return errors.Internal("claim dedup key failed", err)
+		}
+		if n > 0 {
+			result = messaging.ClaimAcquired
+			return nil
+		}
+
+		var state string
+		err = s.db.QueryRowContext(ctx, s.rewrite(`SELECT state FROM {table} WHERE dedup_key = ?`), key).Scan(&state)
+		switch {
+		case stderrors.Is(err, sql.ErrNoRows):
+			result = messaging.ClaimInFlight
+		case err != nil:
+			return errors.Internal("read dedup key failed", err)
+		case state == stateDone:
+			result = messaging.ClaimProcessed
+		default:
+			result = messaging.ClaimInFlight
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Complete implements messaging.DedupStore. The row is only updated while
+// owner holds it; a row already removed by DeleteExpired is recreated, as
+// nobody else can have claimed the key in between.
+func (s *SQLStore) Complete(ctx context.Context, key, owner string, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	expires := s.now().Add(ttl).UnixNano()
+	var n int64
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		res, err := s.db.ExecContext(ctx, s.rewrite(`INSERT INTO {table} (dedup_key, state, owner, expires_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (dedup_key) DO UPDATE SET state = excluded.state, expires_at = excluded.expires_at
+WHERE {table}.owner = excluded.owner`),
+			key, stateDone, owner, expires)
+		if err != nil {
+			return errors.Internal("complete dedup key failed", err)
+		}
+		n, err = res.RowsAffected()
+		if err != nil {
+			return errors.Internal("complete dedup key failed", err)
+		}
+		return nil
+	})
+	if err == nil && n == 0 {
+		return messaging.ErrClaimLost(nil)
+	}
+	return err
+}
+
+// Release implements messaging.DedupStore. Processed markers and claims held
+// by other owners are kept.
+func (s *SQLStore) Release(ctx context.Context, key, owner string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.retrier.Execute(ctx, func(ctx context.Context) error {
+		_, err := s.db.ExecContext(ctx, s.rewrite(`DELETE FROM {table} WHERE dedup_key = ? AND state = ? AND owner = ?`), key, stateClaimed, owner)
+		if err != nil {
+			return errors.Internal("release dedup key failed", err)
+		}
+		return nil
+	})
+}
+
+// DeleteExpired removes expired claims and processed markers and returns how
+// many rows were deleted.
+func (s *SQLStore) DeleteExpired(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	var n int64
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		res, err := s.db.ExecContext(ctx, s.rewrite(`DELETE FROM {table} WHERE expires_at <= ?`), s.now().UnixNano())
+		if err != nil {
+			return errors.Internal("delete expired dedup keys failed", err)
+		}
+		n, err = res.RowsAffected()
+		if err != nil {
+			return errors.Internal("delete expired dedup keys failed", err)
+		}
+		return nil
+	})
+	return n, err
+}
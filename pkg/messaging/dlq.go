@@ -0,0 +1,452 @@
+package messaging
+
+import (
+	"context"
+	stderrors "errors"
+	"strconv"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// Header keys stamped on dead-lettered messages.
+const (
+	HeaderDLQSourceTopic = "x-dlq-source-topic"
+	HeaderDLQGroup       = "x-dlq-group"
+	HeaderDLQError       = "x-dlq-error"
+	HeaderDLQErrorCode   = "x-dlq-error-code"
+	HeaderDLQAttempts    = "x-dlq-attempts"
+	HeaderDLQFailedAt    = "x-dlq-failed-at"
+	HeaderDLQRedrives    = "x-dlq-redrive-count"
+
+	// headerDLQRedrivePass marks messages a Redrive run put back on the DLQ,
+	// so the run stops once it has seen every message.
+	headerDLQRedrivePass = "x-dlq-redrive-pass"
+)
+
+// DeadLetterConfig configures a DeadLetterConsumer.
+type DeadLetterConfig struct {
+	// MaxAttempts is the number of failed deliveries after which a message is
+	// dead-lettered.
+	MaxAttempts int `env:"MSG_DLQ_MAX_ATTEMPTS" env-default:"5"`
+
+	// Topic is the DLQ topic. Defaults to the source topic + ".dlq".
+	Topic string `env:"MSG_DLQ_TOPIC"`
+
+	// TrackedMessages bounds the in-memory attempt counters used when the
+	// broker does not report Metadata.DeliveryCount.
+	TrackedMessages int `env:"MSG_DLQ_TRACKED" env-default:"10000"`
+}
+
+// DeadLetterInfo is the failure context carried by a dead-lettered message.
+type DeadLetterInfo struct {
+	SourceTopic string
+	Group       string
+	Error       string
+	ErrorCode   string
+	Attempts    int
+	FailedAt    time.Time
+	Redrives    int
+}
+
+// ParseDeadLetter reads the DLQ headers of msg. ok is false when msg was not
+// dead-lettered by a DeadLetterConsumer.
+func ParseDeadLetter(msg *Message) (info DeadLetterInfo, ok bool) {
+	if msg == nil || msg.Headers == nil {
+		return DeadLetterInfo{}, false
+	}
+	src, ok := msg.Headers[HeaderDLQSourceTopic]
+	if !ok {
+		return DeadLetterInfo{}, false
+	}
+	info = DeadLetterInfo{
+		SourceTopic: src,
+		Group:       msg.Headers[HeaderDLQGroup],
+		Error:       msg.Headers[HeaderDLQError],
+		ErrorCode:   msg.Headers[HeaderDLQErrorCode],
+		Attempts:    int(parseInt64(msg.Headers[HeaderDLQAttempts])),
+		Redrives:    int(parseInt64(msg.Headers[HeaderDLQRedrives])),
+	}
+	info.FailedAt, _ = time.Parse(time.RFC3339Nano, msg.Headers[HeaderDLQFailedAt])
+	return info, true
+}
+
+// DeadLetterConsumer wraps a Consumer so that a message whose handler keeps
+// failing is published to a DLQ topic, with the error and attempt count in
+// its headers, and then acknowledged. Earlier failures are returned to the
+// broker as usual so it can redeliver.
+type DeadLetterConsumer struct {
+	consumer    Consumer
+	dlq         Producer
+	sourceTopic string
+	group       string
+	cfg         DeadLetterConfig
+
+	mu       *concurrency.SmartMutex
+	attempts map[string]int
+	order    []string
+}
+
+// NewDeadLetterConsumer wraps consumer, which reads sourceTopic as group, and
+// dead-letters through a producer from broker.
+func NewDeadLetterConsumer(consumer Consumer, broker Broker, sourceTopic, group string, cfg DeadLetterConfig) (*DeadLetterConsumer, error) {
+	if consumer == nil || broker == nil {
+		return nil, ErrInvalidConfig("consumer and broker are required", nil)
+	}
+	if sourceTopic == "" {
+		return nil, ErrInvalidConfig("source topic is required", nil)
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Topic == "" {
+		cfg.Topic = sourceTopic + ".dlq"
+	}
+	if cfg.TrackedMessages <= 0 {
+		cfg.TrackedMessages = 10000
+	}
+	dlq, err := broker.Producer(cfg.Topic)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadLetterConsumer{
+		consumer:    consumer,
+		dlq:         dlq,
+		sourceTopic: sourceTopic,
+		group:       group,
+		cfg:         cfg,
+		mu:          concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "DeadLetterConsumer"}),
+		attempts:    make(map[string]int),
+	}, nil
+}
+
+// Topic returns the DLQ topic.
+func (dc *DeadLetterConsumer) Topic() string {
+	return dc.cfg.Topic
+}
+
+func (dc *DeadLetterConsumer) Consume(ctx context.Context, handler MessageHandler) error {
+	return dc.consumer.Consume(ctx, func(ctx context.Context, msg *Message) error {
+		err := handler(ctx, msg)
+		key := dc.key(msg)
+		if err == nil {
+			dc.forget(key)
+			return nil
+		}
+		if ctx.Err() != nil {
+			// Shutting down: not the message's fault.
+			return err
+		}
+
+		attempts := dc.recordFailure(key, msg.Metadata.DeliveryCount)
+		if attempts < dc.cfg.MaxAttempts {
+			return err
+		}
+		if pubErr := dc.dlq.Publish(ctx, dc.deadLetter(msg, err, attempts)); pubErr != nil {
+			// Keep the message on the source so it is not lost.
+			return ErrPublishFailed(stderrors.Join(err, pubErr))
+		}
+		dc.forget(key)
+		return nil
+	})
+}
+
+func (dc *DeadLetterConsumer) Close() error {
+	return stderrors.Join(dc.consumer.Close(), dc.dlq.Close())
+}
+
+// recordFailure returns the attempt number of this failure. Broker-reported
+// delivery counts win; otherwise failures are counted locally.
+func (dc *DeadLetterConsumer) recordFailure(key string, delivered int) int {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	n, tracked := dc.attempts[key]
+	n++
+	if delivered > n {
+		n = delivered
+	}
+	if !tracked {
+		if len(dc.order) >= dc.cfg.TrackedMessages {
+			delete(dc.attempts, dc.order[0])
+			dc.order = dc.order[1:]
+		}
+		dc.order = append(dc.order, key)
+	}
+	dc.attempts[key] = n
+	return n
+}
+
+func (dc *DeadLetterConsumer) forget(key string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if _, ok := dc.attempts[key]; !ok {
+		return
+	}
+	delete(dc.attempts, key)
+	for i, k := range dc.order {
+		if k == key {
+			dc.order = append(dc.order[:i], dc.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (dc *DeadLetterConsumer) key(msg *Message) string {
+	if msg.ID != "" {
+		return msg.ID
+	}
+	return msg.Topic + ":" + string(msg.Payload)
+}
+
+func (dc *DeadLetterConsumer) deadLetter(msg *Message, cause error, attempts int) *Message {
+	source := msg.Topic
+	if source == "" {
+		source = dc.sourceTopic
+	}
+	dead := cloneMessage(msg, dc.cfg.Topic)
+	headers := dead.Headers
+	headers[HeaderDLQSourceTopic] = source
+	headers[HeaderDLQGroup] = dc.group
+	headers[HeaderDLQError] = cause.Error()
+	if code := errors.Code(cause); code != "" {
+		headers[HeaderDLQErrorCode] = code
+	}
+	headers[HeaderDLQAttempts] = strconv.Itoa(attempts)
+	headers[HeaderDLQFailedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+	return dead
+}
+
+// RedriveOptions configures Redrive.
+type RedriveOptions struct {
+	// Group is the consumer group used to read the DLQ.
+	Group string
+
+	// Filter selects the messages to replay. Nil replays everything. Messages
+	// that are not selected are put back on the DLQ.
+	Filter func(msg *Message, info DeadLetterInfo) bool
+
+	// TargetTopic overrides the source topic recorded in the headers.
+	TargetTopic string
+
+	// MaxMessages stops after replaying this many messages. Zero means all.
+	MaxMessages int
+
+	// IdleTimeout ends the run when the DLQ yields nothing for this long.
+	IdleTimeout time.Duration
+}
+
+// RedriveResult reports what Redrive did.
+type RedriveResult struct {
+	// Redriven messages were published back to their source topic.
+	Redriven int
+
+	// Skipped messages did not match the filter (or had no target) and were
+	// returned to the DLQ.
+	Skipped int
+}
+
+// Redrive replays dead-lettered messages from dlqTopic back to the topic they
+// failed on. The DLQ headers are removed, except for a redrive counter. It
+// returns when the DLQ is drained, MaxMessages is reached, the DLQ is idle
+// for IdleTimeout, or ctx ends.
+func Redrive(ctx context.Context, broker Broker, dlqTopic string, opts RedriveOptions) (RedriveResult, error) {
+	if broker == nil || dlqTopic == "" {
+		return RedriveResult{}, ErrInvalidConfig("broker and DLQ topic are required", nil)
+	}
+	if opts.Group == "" {
+		opts.Group = dlqTopic + ".redrive"
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = time.Second
+	}
+
+	consumer, err := broker.Consumer(dlqTopic, opts.Group)
+	if err != nil {
+		return RedriveResult{}, err
+	}
+	defer consumer.Close()
+
+	r := &redriver{
+		broker:    broker,
+		dlqTopic:  dlqTopic,
+		opts:      opts,
+		pass:      uuid.NewString(),
+		producers: make(map[string]Producer),
+		mu:        concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "messaging-redrive"}),
+		activity:  make(chan struct{}, 1),
+	}
+	defer r.close()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r.cancel = cancel
+	go r.watchIdle(runCtx)
+
+	err = consumer.Consume(runCtx, r.handle)
+	res, runErr := r.result()
+	switch {
+	case ctx.Err() != nil:
+		return res, ctx.Err()
+	case runErr != nil:
+		return res, runErr
+	case err != nil && !stderrors.Is(err, context.Canceled):
+		return res, err
+	}
+	return res, nil
+}
+
+type redriver struct {
+	broker    Broker
+	dlqTopic  string
+	opts      RedriveOptions
+	pass      string
+	producers map[string]Producer
+
+	mu       *concurrency.SmartMutex
+	res      RedriveResult
+	err      error
+	stopped  bool
+	cancel   context.CancelFunc
+	activity chan struct{}
+}
+
+func (r *redriver) handle(ctx context.Context, msg *Message) error {
+	select {
+	case r.activity <- struct{}{}:
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+
+	if r.stopped || msg.Headers[headerDLQRedrivePass] == r.pass {
+		// Either the run is over or everything has been seen once: put the
+		// message back without this run's marker and stop.
+		back := cloneMessage(msg, r.dlqTopic)
+		delete(back.Headers, headerDLQRedrivePass)
+		if err := r.publish(ctx, r.dlqTopic, back); err != nil {
+			return r.fail(err)
+		}
+		r.stop()
+		return nil
+	}
+
+	info, _ := ParseDeadLetter(msg)
+	target := r.opts.TargetTopic
+	if target == "" {
+		target = info.SourceTopic
+	}
+	if target == "" || (r.opts.Filter != nil && !r.opts.Filter(msg, info)) {
+		keep := cloneMessage(msg, r.dlqTopic)
+		keep.Headers[headerDLQRedrivePass] = r.pass
+		if err := r.publish(ctx, r.dlqTopic, keep); err != nil {
+			return r.fail(err)
+		}
+		r.res.Skipped++
+		return nil
+	}
+
+	replay := cloneMessage(msg, target)
+	for k := range replay.Headers {
+		switch k {
+		case HeaderDLQSourceTopic, HeaderDLQGroup, HeaderDLQError, HeaderDLQErrorCode,
+			HeaderDLQAttempts, HeaderDLQFailedAt, headerDLQRedrivePass:
+			delete(replay.Headers, k)
+		}
+	}
+	replay.Headers[HeaderDLQRedrives] = strconv.Itoa(info.Redrives + 1)
+	if err := r.publish(ctx, target, replay); err != nil {
+		return r.fail(err)
+	}
+	r.res.Redriven++
+	if r.opts.MaxMessages > 0 && r.res.Redriven >= r.opts.MaxMessages {
+		r.stop()
+	}
+	return nil
+}
+
+// stop ends the run. Messages the consumer still hands over are returned to
+// the DLQ. Callers hold r.mu.
+func (r *redriver) stop() {
+	r.stopped = true
+	r.cancel()
+}
+
+// fail records err and stops the run. The message is not acknowledged, so
+// a broker that redelivers keeps it on the DLQ. Callers hold r.mu.
+func (r *redriver) fail(err error) error {
+	r.err = err
+	r.stop()
+	return err
+}
+
+// publish sends msg to topic. Callers hold r.mu.
+func (r *redriver) publish(ctx context.Context, topic string, msg *Message) error {
+	p, ok := r.producers[topic]
+	if !ok {
+		var err error
+		if p, err = r.broker.Producer(topic); err != nil {
+			return err
+		}
+		r.producers[topic] = p
+	}
+	// Detach from the run's context: the run may be cancelled right after.
+	return p.Publish(context.WithoutCancel(ctx), msg)
+}
+
+func (r *redriver) watchIdle(ctx context.Context) {
+	timer := time.NewTimer(r.opts.IdleTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.activity:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(r.opts.IdleTimeout)
+		case <-timer.C:
+			r.mu.Lock()
+			r.stop()
+			r.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (r *redriver) result() (RedriveResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.res, r.err
+}
+
+func (r *redriver) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.producers {
+		_ = p.Close()
+	}
+}
+
+func cloneMessage(msg *Message, topic string) *Message {
+	headers := make(map[string]string, len(msg.Headers)+1)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	return &Message{
+		ID:        msg.ID,
+		Topic:     topic,
+		Key:       msg.Key,
+		Payload:   msg.Payload,
+		Headers:   headers,
+		Timestamp: time.Now(),
+	}
+}
+
+var _ Consumer = (*DeadLetterConsumer)(nil)
@@ -22,6 +22,29 @@ ContextWithConsumeOptions) to attach PublishOption / ConsumeOption hints without
 changing the Producer/Consumer interfaces. Adapters may read them from message
 headers or context.
 
+# Dead-letter queues
+
+NewDeadLetterConsumer wraps any Consumer: failed deliveries are returned to the
+broker until DeadLetterConfig.MaxAttempts, then the message is published to a
+DLQ topic through the Broker with the error, error code, attempt count and
+source topic in x-dlq-* headers (see ParseDeadLetter) and acknowledged.
+Redrive replays DLQ messages to their source topic, optionally filtered:
+
+	dc, _ := messaging.NewDeadLetterConsumer(consumer, broker, "orders", "billing",
+	    messaging.DeadLetterConfig{MaxAttempts: 5})
+	res, _ := messaging.Redrive(ctx, broker, dc.Topic(), messaging.RedriveOptions{
+	    Filter: func(m *messaging.Message, info messaging.DeadLetterInfo) bool {
+	        return info.ErrorCode == errors.CodeUnavailable
+	    },
+	})
+
+# Deduplication
+
+NewDeduplicatingConsumer skips redelivered messages using an in-process Bloom
+filter by default. WithDedupStore swaps in a persistent DedupStore
+(pkg/messaging/dedupstore: cache.Cache/Redis or SQL) shared across restarts and
+replicas; a message is marked processed only after its handler succeeds.
+
 # Architecture
 
 The package follows the adapter pattern with decoupled dependencies:
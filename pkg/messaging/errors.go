@@ -16,6 +16,8 @@ const (
 	CodeSerializationFailed   = "MESSAGING_SERIALIZATION_FAILED"
 	CodeQueueFull             = "MESSAGING_QUEUE_FULL"
 	CodeConsumerGroupConflict = "MESSAGING_GROUP_CONFLICT"
+	CodeInFlight              = "MESSAGING_IN_FLIGHT"
+	CodeClaimLost             = "MESSAGING_CLAIM_LOST"
 )
 
 // Error constructors for messaging operations.
@@ -80,3 +82,16 @@ func ErrQueueFull(err error) *errors.AppError {
 func ErrConsumerGroupConflict(group string, err error) *errors.AppError {
 	return errors.New(CodeConsumerGroupConflict, "consumer group conflict: "+group, err)
 }
+
+// ErrInFlight creates an error for a message that another consumer is
+// processing; returning it lets the broker redeliver later. The message
+// carries a fingerprint of key, never the key itself.
+func ErrInFlight(key string) *errors.AppError {
+	return errors.New(CodeInFlight, "message is being processed by another consumer: "+keyFingerprint(key), nil)
+}
+
+// ErrClaimLost creates an error for a DedupStore claim that expired and was
+// taken over (or removed) before its owner completed it.
+func ErrClaimLost(err error) *errors.AppError {
+	return errors.New(CodeClaimLost, "dedup claim is no longer held by this consumer", err)
+}
@@ -0,0 +1,279 @@
+package messaging_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/messaging/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/test"
+)
+
+type DLQSuite struct {
+	test.Suite
+	broker *memory.Broker
+}
+
+func TestDLQSuite(t *testing.T) {
+	test.Run(t, new(DLQSuite))
+}
+
+func (s *DLQSuite) SetupTest() {
+	s.Suite.SetupTest()
+	s.broker = memory.New(memory.Config{BufferSize: 64, MaxDeliveries: 10, RetainUndelivered: true})
+}
+
+func (s *DLQSuite) TearDownTest() {
+	_ = s.broker.Close()
+}
+
+// drain collects messages from topic until it is idle.
+func (s *DLQSuite) drain(topic string) []*messaging.Message {
+	consumer, err := s.broker.Consumer(topic, "inspect")
+	s.Require().NoError(err)
+	defer consumer.Close()
+
+	ctx, cancel := context.WithCancel(s.Ctx)
+	defer cancel()
+	idle := time.AfterFunc(100*time.Millisecond, cancel)
+	var got []*messaging.Message
+	_ = consumer.Consume(ctx, func(ctx context.Context, msg *messaging.Message) error {
+		idle.Reset(100 * time.Millisecond)
+		got = append(got, msg)
+		return nil
+	})
+	return got
+}
+
+func (s *DLQSuite) publish(topic string, msgs ...*messaging.Message) {
+	producer, err := s.broker.Producer(topic)
+	s.Require().NoError(err)
+	defer producer.Close()
+	for _, msg := range msgs {
+		s.Require().NoError(producer.Publish(s.Ctx, msg))
+	}
+}
+
+func (s *DLQSuite) TestPoisonMessageIsDeadLettered() {
+	base, err := s.broker.Consumer("orders", "billing")
+	s.Require().NoError(err)
+	dc, err := messaging.NewDeadLetterConsumer(base, s.broker, "orders", "billing", messaging.DeadLetterConfig{MaxAttempts: 3})
+	s.Require().NoError(err)
+	s.Equal("orders.dlq", dc.Topic())
+
+	var poisonAttempts, good atomic.Int32
+	ctx, cancel := context.WithCancel(s.Ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = dc.Consume(ctx, func(ctx context.Context, msg *messaging.Message) error {
+			if string(msg.Payload) == "poison" {
+				poisonAttempts.Add(1)
+				return errors.InvalidArgument("unparseable order", nil)
+			}
+			good.Add(1)
+			return nil
+		})
+	}()
+
+	s.publish("orders",
+		&messaging.Message{ID: "p1", Payload: []byte("poison"), Headers: map[string]string{"tenant": "t1"}},
+		&messaging.Message{ID: "g1", Payload: []byte("ok")},
+	)
+	s.Eventually(func() bool { return poisonAttempts.Load() == 3 && good.Load() == 1 }, 2*time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+	s.Require().NoError(dc.Close())
+
+	dead := s.drain("orders.dlq")
+	s.Require().Len(dead, 1)
+	s.Equal("p1", dead[0].ID)
+	s.Equal("t1", dead[0].Headers["tenant"], "original headers are kept")
+	info, ok := messaging.ParseDeadLetter(dead[0])
+	s.Require().True(ok)
+	s.Equal("orders", info.SourceTopic)
+	s.Equal("billing", info.Group)
+	s.Equal(3, info.Attempts)
+	s.Equal(errors.CodeInvalidArgument, info.ErrorCode)
+	s.Contains(info.Error, "unparseable order")
+	s.False(info.FailedAt.IsZero())
+	s.Equal(int32(3), poisonAttempts.Load(), "acknowledged after dead-lettering")
+}
+
+// replayConsumer delivers the same message repeatedly without a delivery
+// count, like a broker that does not report one.
+type replayConsumer struct {
+	msg   *messaging.Message
+	times int
+}
+
+func (r *replayConsumer) Consume(ctx context.Context, handler messaging.MessageHandler) error {
+	for i := 0; i < r.times; i++ {
+		if handler(ctx, r.msg) == nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *replayConsumer) Close() error { return nil }
+
+func (s *DLQSuite) TestCountsAttemptsLocally() {
+	rc := &replayConsumer{msg: &messaging.Message{ID: "x", Topic: "jobs", Payload: []byte("x")}, times: 10}
+	dc, err := messaging.NewDeadLetterConsumer(rc, s.broker, "jobs", "workers", messaging.DeadLetterConfig{MaxAttempts: 4, Topic: "jobs-dead"})
+	s.Require().NoError(err)
+
+	var calls int
+	s.Require().NoError(dc.Consume(s.Ctx, func(ctx context.Context, msg *messaging.Message) error {
+		calls++
+		return fmt.Errorf("boom")
+	}))
+	s.Equal(4, calls)
+
+	dead := s.drain("jobs-dead")
+	s.Require().Len(dead, 1)
+	info, _ := messaging.ParseDeadLetter(dead[0])
+	s.Equal(4, info.Attempts)
+	s.Empty(info.ErrorCode)
+}
+
+func (s *DLQSuite) TestRedriveWithFilter() {
+	deadLetter := func(id, code string) *messaging.Message {
+		return &messaging.Message{ID: id, Payload: []byte(id), Headers: map[string]string{
+			messaging.HeaderDLQSourceTopic: "orders",
+			messaging.HeaderDLQError:       "failed",
+			messaging.HeaderDLQErrorCode:   code,
+			messaging.HeaderDLQAttempts:    "5",
+		}}
+	}
+	s.publish("orders.dlq",
+		deadLetter("a", errors.CodeUnavailable),
+		deadLetter("b", errors.CodeInvalidArgument),
+		deadLetter("c", errors.CodeUnavailable),
+	)
+
+	res, err := messaging.Redrive(s.Ctx, s.broker, "orders.dlq", messaging.RedriveOptions{
+		Filter: func(msg *messaging.Message, info messaging.DeadLetterInfo) bool {
+			return info.ErrorCode == errors.CodeUnavailable
+		},
+		IdleTimeout: 200 * time.Millisecond,
+	})
+	s.Require().NoError(err)
+	s.Equal(messaging.RedriveResult{Redriven: 2, Skipped: 1}, res)
+
+	replayed := s.drain("orders")
+	s.Require().Len(replayed, 2)
+	s.ElementsMatch([]string{"a", "c"}, []string{replayed[0].ID, replayed[1].ID})
+	for _, msg := range replayed {
+		_, dead := messaging.ParseDeadLetter(msg)
+		s.False(dead, "DLQ headers are stripped")
+		s.Equal("1", msg.Headers[messaging.HeaderDLQRedrives])
+		s.Equal("orders", msg.Topic)
+	}
+
+	// The skipped message stays on the DLQ; an unfiltered run replays it.
+	res, err = messaging.Redrive(s.Ctx, s.broker, "orders.dlq", messaging.RedriveOptions{
+		TargetTopic: "orders-retry",
+		IdleTimeout: 200 * time.Millisecond,
+	})
+	s.Require().NoError(err)
+	s.Equal(messaging.RedriveResult{Redriven: 1}, res)
+	retried := s.drain("orders-retry")
+	s.Require().Len(retried, 1)
+	s.Equal("b", retried[0].ID)
+	s.Empty(s.drain("orders.dlq"))
+}
+
+func (s *DLQSuite) TestRedriveReturnsSkippedMessagesUnmarked() {
+	s.publish("q.dlq", &messaging.Message{ID: "m", Headers: map[string]string{messaging.HeaderDLQSourceTopic: "q"}})
+	res, err := messaging.Redrive(s.Ctx, s.broker, "q.dlq", messaging.RedriveOptions{
+		Filter:      func(*messaging.Message, messaging.DeadLetterInfo) bool { return false },
+		IdleTimeout: 200 * time.Millisecond,
+	})
+	s.Require().NoError(err)
+	s.Equal(messaging.RedriveResult{Skipped: 1}, res)
+
+	kept := s.drain("q.dlq")
+	s.Require().Len(kept, 1)
+	s.NotContains(kept[0].Headers, "x-dlq-redrive-pass", "the run's marker must not outlive it")
+	s.Equal("q", kept[0].Headers[messaging.HeaderDLQSourceTopic])
+}
+
+func (s *DLQSuite) TestRedriveMaxMessages() {
+	for i := 0; i < 5; i++ {
+		s.publish("q.dlq", &messaging.Message{ID: fmt.Sprint(i), Headers: map[string]string{messaging.HeaderDLQSourceTopic: "q"}})
+	}
+	res, err := messaging.Redrive(s.Ctx, s.broker, "q.dlq", messaging.RedriveOptions{MaxMessages: 2})
+	s.Require().NoError(err)
+	s.Equal(2, res.Redriven)
+	s.Len(s.drain("q"), 2)
+	s.Len(s.drain("q.dlq"), 3)
+}
+
+func (s *DLQSuite) TestDefaultsDeadLetterOnMemoryBroker() {
+	broker := memory.New(memory.Config{RetainUndelivered: true})
+	defer broker.Close()
+	base, err := broker.Consumer("jobs", "workers")
+	s.Require().NoError(err)
+	dc, err := messaging.NewDeadLetterConsumer(base, broker, "jobs", "workers", messaging.DeadLetterConfig{})
+	s.Require().NoError(err)
+	defer dc.Close()
+	producer, err := broker.Producer("jobs")
+	s.Require().NoError(err)
+
+	var attempts atomic.Int32
+	ctx, cancel := context.WithCancel(s.Ctx)
+	defer cancel()
+	go func() {
+		_ = dc.Consume(ctx, func(ctx context.Context, msg *messaging.Message) error {
+			attempts.Add(1)
+			return fmt.Errorf("boom")
+		})
+	}()
+	s.Require().NoError(producer.Publish(ctx, &messaging.Message{ID: "p"}))
+
+	dead, err := broker.Consumer(dc.Topic(), "inspect")
+	s.Require().NoError(err)
+	defer dead.Close()
+	got := make(chan *messaging.Message, 1)
+	go func() {
+		_ = dead.Consume(ctx, func(ctx context.Context, msg *messaging.Message) error {
+			got <- msg
+			return nil
+		})
+	}()
+	select {
+	case msg := <-got:
+		info, _ := messaging.ParseDeadLetter(msg)
+		s.Equal(5, info.Attempts)
+	case <-time.After(2 * time.Second):
+		s.Fail("message was not dead-lettered with the default settings")
+	}
+	s.Equal(int32(5), attempts.Load())
+}
+
+func (s *DLQSuite) TestMemoryRedeliversFailedMessages() {
+	broker := memory.New(memory.Config{BufferSize: 8, MaxDeliveries: 3})
+	defer broker.Close()
+	consumer, err := broker.Consumer("t", "g")
+	s.Require().NoError(err)
+	producer, err := broker.Producer("t")
+	s.Require().NoError(err)
+
+	var counts []int
+	ctx, cancel := context.WithTimeout(s.Ctx, time.Second)
+	defer cancel()
+	s.Require().NoError(producer.Publish(ctx, &messaging.Message{ID: "m"}))
+	_ = consumer.Consume(ctx, func(ctx context.Context, msg *messaging.Message) error {
+		counts = append(counts, msg.Metadata.DeliveryCount)
+		if len(counts) == 3 {
+			cancel()
+		}
+		return fmt.Errorf("retry me")
+	})
+	s.Equal([]int{1, 2, 3}, counts)
+}
@@ -0,0 +1,141 @@
+package resilience
+
+import (
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+)
+
+// retryBudgetBuckets is the number of buckets a RetryBudget window is split into.
+const retryBudgetBuckets = 10
+
+// RetryBudgetConfig configures a RetryBudget.
+type RetryBudgetConfig struct {
+	// Name identifies this budget (for logging/metrics).
+	Name string
+
+	// Ratio is the fraction of recent successful calls that may be retried
+	// (default 0.2, i.e. retries add at most 20% load).
+	Ratio float64
+
+	// MinRetries is a floor of retries allowed per Window regardless of
+	// traffic, so low-volume callers can still retry (default 10; negative
+	// means no floor).
+	MinRetries int64
+
+	// Window is how far back successes and retries are counted (default 10s).
+	Window time.Duration
+}
+
+// RetryBudget caps retries to a share of recent successful calls. Share one
+// budget between every RetryConfig that talks to the same dependency: when
+// it starts failing, successes dry up and so do the retries, instead of each
+// caller multiplying load by MaxAttempts.
+type RetryBudget struct {
+	cfg     RetryBudgetConfig
+	width   int64
+	buckets [retryBudgetBuckets]budgetBucket
+	mu      *concurrency.SmartMutex
+	now     func() time.Time
+}
+
+type budgetBucket struct {
+	epoch     int64
+	successes int64
+	retries   int64
+}
+
+// RetryBudgetMetrics is a snapshot of a RetryBudget's window.
+type RetryBudgetMetrics struct {
+	Successes int64
+	Retries   int64
+	// Available is how many more retries the budget currently permits.
+	Available int64
+}
+
+// NewRetryBudget creates a retry budget.
+func NewRetryBudget(cfg RetryBudgetConfig) *RetryBudget {
+	if cfg.Ratio <= 0 {
+		cfg.Ratio = 0.2
+	}
+	if cfg.MinRetries < 0 {
+		cfg.MinRetries = 0
+	} else if cfg.MinRetries == 0 {
+		cfg.MinRetries = 10
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	width := int64(cfg.Window) / retryBudgetBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &RetryBudget{
+		cfg:   cfg,
+		width: width,
+		mu:    concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "RetryBudget-" + cfg.Name}),
+		now:   time.Now,
+	}
+}
+
+// SetNowFunc overrides the clock (tests).
+func (b *RetryBudget) SetNowFunc(fn func() time.Time) {
+	if fn != nil {
+		b.now = fn
+	}
+}
+
+// RecordSuccess deposits a successful call into the budget.
+func (b *RetryBudget) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bucket().successes++
+}
+
+// TryRetry withdraws one retry, reporting false when the budget is spent.
+func (b *RetryBudget) TryRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.available() <= 0 {
+		return false
+	}
+	b.bucket().retries++
+	return true
+}
+
+// Metrics returns the current window totals.
+func (b *RetryBudget) Metrics() RetryBudgetMetrics {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	successes, retries := b.totals()
+	return RetryBudgetMetrics{Successes: successes, Retries: retries, Available: b.available()}
+}
+
+// bucket returns the current bucket, recycling it if stale. Callers hold mu.
+func (b *RetryBudget) bucket() *budgetBucket {
+	epoch := b.now().UnixNano() / b.width
+	bk := &b.buckets[epoch%retryBudgetBuckets]
+	if bk.epoch != epoch {
+		*bk = budgetBucket{epoch: epoch}
+	}
+	return bk
+}
+
+// totals sums the buckets still inside the window. Callers hold mu.
+func (b *RetryBudget) totals() (successes, retries int64) {
+	epoch := b.now().UnixNano() / b.width
+	for _, bk := range b.buckets {
+		if epoch-bk.epoch >= retryBudgetBuckets || bk.epoch > epoch {
+			continue
+		}
+		successes += bk.successes
+		retries += bk.retries
+	}
+	return successes, retries
+}
+
+// available returns the retries left in the window. Callers hold mu.
+func (b *RetryBudget) available() int64 {
+	successes, retries := b.totals()
+	return b.cfg.MinRetries + int64(float64(successes)*b.cfg.Ratio) - retries
+}
@@ -11,7 +11,9 @@ import (
 // CircuitBreaker implements the circuit breaker pattern.
 //
 // States:
-//   - Closed: Normal operation. Failures are counted.
+//   - Closed: Normal operation. Failures are counted, either consecutively
+//     (FailureThreshold) or in a sliding window (WindowType) that trips on
+//     failure-rate and slow-call-rate percentages once MinimumCalls is reached.
 //   - Open: All requests fail fast. After timeout, transitions to half-open.
 //   - Half-Open: Limited requests are allowed to test recovery.
 //
@@ -25,6 +27,10 @@ type CircuitBreaker struct {
 	lastFailure   atomic.Int64 // Unix timestamp
 	halfOpenCount atomic.Int64
 	mu            *concurrency.SmartRWMutex
+
+	window   window // nil unless WindowType is set
+	windowMu *concurrency.SmartMutex
+	now      func() time.Time
 }
 
 // NewCircuitBreaker creates a new circuit breaker (concrete, uninstrumented).
@@ -39,15 +45,46 @@ func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
 	if cfg.Timeout == 0 {
 		cfg.Timeout = 30 * time.Second
 	}
+	if cfg.WindowType != WindowNone {
+		if cfg.WindowSize <= 0 {
+			cfg.WindowSize = 100
+		}
+		if cfg.WindowDuration <= 0 {
+			cfg.WindowDuration = 60 * time.Second
+		}
+		if cfg.MinimumCalls <= 0 {
+			cfg.MinimumCalls = 10
+		}
+		if cfg.WindowType == WindowCount && cfg.MinimumCalls > cfg.WindowSize {
+			cfg.MinimumCalls = cfg.WindowSize
+		}
+		if cfg.FailureRateThreshold <= 0 {
+			cfg.FailureRateThreshold = 50
+		}
+		if cfg.SlowCallRateThreshold <= 0 {
+			cfg.SlowCallRateThreshold = 100
+		}
+	}
 
 	cb := &CircuitBreaker{
-		config: cfg,
-		mu:     concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "CircuitBreaker-" + cfg.Name}),
+		config:   cfg,
+		mu:       concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "CircuitBreaker-" + cfg.Name}),
+		window:   newWindow(cfg),
+		windowMu: concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "CircuitBreaker-window-" + cfg.Name}),
+		now:      time.Now,
 	}
 	cb.state.Store(StateClosed)
 	return cb
 }
 
+// SetNowFunc overrides the clock used for timeouts, slow-call measurement
+// and time-based windows (tests).
+func (cb *CircuitBreaker) SetNowFunc(fn func() time.Time) {
+	if fn != nil {
+		cb.now = fn
+	}
+}
+
 // Execute runs the given function with circuit breaker protection.
 func (cb *CircuitBreaker) Execute(ctx context.Context, fn Executor) error {
 	allowed, reject := cb.allowRequest()
@@ -55,9 +92,13 @@ func (cb *CircuitBreaker) Execute(ctx context.Context, fn Executor) error {
 		return reject
 	}
 
+	start := cb.now()
 	err := fn(ctx)
+	slow := cb.config.SlowCallDurationThreshold > 0 && cb.now().Sub(start) >= cb.config.SlowCallDurationThreshold
 
-	if err != nil {
+	if cb.window != nil {
+		cb.recordWindow(outcome{failed: err != nil, slow: slow})
+	} else if err != nil {
 		cb.recordFailure()
 	} else {
 		cb.recordSuccess()
@@ -79,13 +120,14 @@ func (cb *CircuitBreaker) Reset() {
 	cb.failures.Store(0)
 	cb.successes.Store(0)
 	cb.halfOpenCount.Store(0)
+	cb.resetWindow()
 }
 
 // ForceOpen forces the circuit into the open state.
 func (cb *CircuitBreaker) ForceOpen() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
-	cb.lastFailure.Store(time.Now().UnixMilli())
+	cb.lastFailure.Store(cb.now().UnixMilli())
 	cb.halfOpenCount.Store(0)
 	cb.setState(StateOpen)
 }
@@ -105,7 +147,7 @@ func (cb *CircuitBreaker) allowRequest() (bool, error) {
 
 	case StateOpen:
 		lastFailure := time.UnixMilli(cb.lastFailure.Load())
-		if time.Since(lastFailure) > cb.config.Timeout {
+		if cb.now().Sub(lastFailure) > cb.config.Timeout {
 			cb.mu.Lock()
 			if cb.State() == StateOpen {
 				cb.setState(StateHalfOpen)
@@ -162,7 +204,7 @@ func (cb *CircuitBreaker) recordSuccess() {
 
 func (cb *CircuitBreaker) recordFailure() {
 	state := cb.State()
-	cb.lastFailure.Store(time.Now().UnixMilli())
+	cb.lastFailure.Store(cb.now().UnixMilli())
 
 	switch state {
 	case StateClosed:
@@ -185,6 +227,84 @@ func (cb *CircuitBreaker) recordFailure() {
 	}
 }
 
+// recordWindow handles a call outcome in sliding-window mode. In closed state
+// the outcome joins the window and the circuit opens once either rate reaches
+// its threshold; in half-open state a failed or slow probe reopens the circuit
+// and SuccessThreshold good probes close it with a fresh window.
+func (cb *CircuitBreaker) recordWindow(o outcome) {
+	now := cb.now()
+	if o.failed {
+		cb.lastFailure.Store(now.UnixMilli())
+	}
+
+	switch cb.State() {
+	case StateClosed:
+		cb.windowMu.Lock()
+		cb.window.record(o, now)
+		m := cb.windowMetrics(now)
+		cb.windowMu.Unlock()
+		if m.Calls < cb.config.MinimumCalls {
+			return
+		}
+		if m.FailureRate >= cb.config.FailureRateThreshold || m.SlowCallRate >= cb.config.SlowCallRateThreshold {
+			cb.mu.Lock()
+			if cb.State() == StateClosed {
+				// The open timeout runs from lastFailure, so a trip on slow
+				// calls alone starts it here.
+				cb.lastFailure.Store(now.UnixMilli())
+				cb.setState(StateOpen)
+			}
+			cb.mu.Unlock()
+		}
+
+	case StateHalfOpen:
+		if o.failed || o.slow {
+			cb.mu.Lock()
+			if cb.State() == StateHalfOpen {
+				cb.lastFailure.Store(now.UnixMilli())
+				cb.setState(StateOpen)
+				cb.halfOpenCount.Store(0)
+			}
+			cb.mu.Unlock()
+			return
+		}
+		if cb.successes.Add(1) >= cb.config.SuccessThreshold {
+			cb.mu.Lock()
+			if cb.State() == StateHalfOpen {
+				cb.resetWindow()
+				cb.setState(StateClosed)
+				cb.halfOpenCount.Store(0)
+			}
+			cb.mu.Unlock()
+		}
+	}
+}
+
+// windowMetrics snapshots the window. Callers hold windowMu.
+func (cb *CircuitBreaker) windowMetrics(now time.Time) WindowMetrics {
+	calls, failures, slow := cb.window.totals(now)
+	m := WindowMetrics{
+		Type:      cb.config.WindowType,
+		Calls:     calls,
+		Failures:  failures,
+		SlowCalls: slow,
+	}
+	if calls >= cb.config.MinimumCalls {
+		m.FailureRate = percent(failures, calls)
+		m.SlowCallRate = percent(slow, calls)
+	}
+	return m
+}
+
+func (cb *CircuitBreaker) resetWindow() {
+	if cb.window == nil {
+		return
+	}
+	cb.windowMu.Lock()
+	cb.window.reset()
+	cb.windowMu.Unlock()
+}
+
 func (cb *CircuitBreaker) setState(newState State) {
 	oldState := cb.State()
 	if oldState != newState {
@@ -197,20 +317,28 @@ func (cb *CircuitBreaker) setState(newState State) {
 
 // Metrics returns current circuit breaker metrics.
 func (cb *CircuitBreaker) Metrics() CircuitBreakerMetrics {
-	return CircuitBreakerMetrics{
+	m := CircuitBreakerMetrics{
 		State:       cb.State(),
 		Failures:    cb.failures.Load(),
 		Successes:   cb.successes.Load(),
 		LastFailure: time.UnixMilli(cb.lastFailure.Load()),
 	}
+	if cb.window != nil {
+		cb.windowMu.Lock()
+		m.Window = cb.windowMetrics(cb.now())
+		cb.windowMu.Unlock()
+	}
+	return m
 }
 
 // CircuitBreakerMetrics contains circuit breaker statistics.
+// Window is zero-valued unless the breaker runs in sliding-window mode.
 type CircuitBreakerMetrics struct {
 	State       State
 	Failures    int64
 	Successes   int64
 	LastFailure time.Time
+	Window      WindowMetrics
 }
 
 var _ Breaker = (*CircuitBreaker)(nil)
@@ -7,40 +7,85 @@ import "time"
 // the concrete configs consumed by NewCircuitBreaker and Retry.
 type Config struct {
 	// Name identifies the circuit breaker (logging/metrics).
-	Name string `env:"RESILIENCE_NAME" env-default:"default"`
+	Name string `yaml:"name" env:"RESILIENCE_NAME" env-default:"default"`
 
 	// FailureThreshold opens the circuit after this many consecutive failures.
-	FailureThreshold int64 `env:"RESILIENCE_FAILURE_THRESHOLD" env-default:"5"`
+	FailureThreshold int64 `yaml:"failure_threshold" env:"RESILIENCE_FAILURE_THRESHOLD" env-default:"5"`
 
 	// SuccessThreshold closes the circuit after this many half-open successes.
-	SuccessThreshold int64 `env:"RESILIENCE_SUCCESS_THRESHOLD" env-default:"2"`
+	SuccessThreshold int64 `yaml:"success_threshold" env:"RESILIENCE_SUCCESS_THRESHOLD" env-default:"2"`
 
 	// Timeout is how long the circuit stays open before half-open probes.
-	Timeout time.Duration `env:"RESILIENCE_TIMEOUT" env-default:"30s"`
+	Timeout time.Duration `yaml:"timeout" env:"RESILIENCE_TIMEOUT" env-default:"30s"`
 
 	// MaxRequests caps concurrent half-open probes (0 = unlimited).
-	MaxRequests int64 `env:"RESILIENCE_MAX_REQUESTS" env-default:"0"`
+	MaxRequests int64 `yaml:"max_requests" env:"RESILIENCE_MAX_REQUESTS" env-default:"0"`
+
+	// WindowType enables sliding-window breaking ("count" or "time"; empty = consecutive failures).
+	WindowType WindowType `yaml:"window_type" env:"RESILIENCE_WINDOW_TYPE" env-default:""`
+
+	// WindowSize is the number of calls in a count-based window.
+	WindowSize int64 `yaml:"window_size" env:"RESILIENCE_WINDOW_SIZE" env-default:"100"`
+
+	// WindowDuration is the span of a time-based window.
+	WindowDuration time.Duration `yaml:"window_duration" env:"RESILIENCE_WINDOW_DURATION" env-default:"60s"`
+
+	// MinimumCalls is the number of calls needed before window rates apply.
+	MinimumCalls int64 `yaml:"minimum_calls" env:"RESILIENCE_MINIMUM_CALLS" env-default:"10"`
+
+	// FailureRateThreshold opens the circuit at this failure percentage.
+	FailureRateThreshold float64 `yaml:"failure_rate_threshold" env:"RESILIENCE_FAILURE_RATE_THRESHOLD" env-default:"50"`
+
+	// SlowCallDurationThreshold marks calls at least this long as slow (0 = off).
+	SlowCallDurationThreshold time.Duration `yaml:"slow_call_duration_threshold" env:"RESILIENCE_SLOW_CALL_DURATION" env-default:"0"`
+
+	// SlowCallRateThreshold opens the circuit at this slow-call percentage.
+	SlowCallRateThreshold float64 `yaml:"slow_call_rate_threshold" env:"RESILIENCE_SLOW_CALL_RATE_THRESHOLD" env-default:"100"`
 
 	// MaxAttempts is the maximum retry attempts including the first.
-	MaxAttempts int `env:"RESILIENCE_MAX_ATTEMPTS" env-default:"3"`
+	MaxAttempts int `yaml:"max_attempts" env:"RESILIENCE_MAX_ATTEMPTS" env-default:"3"`
 
 	// InitialBackoff is the first retry backoff.
-	InitialBackoff time.Duration `env:"RESILIENCE_INITIAL_BACKOFF" env-default:"100ms"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env:"RESILIENCE_INITIAL_BACKOFF" env-default:"100ms"`
 
 	// MaxBackoff caps exponential backoff.
-	MaxBackoff time.Duration `env:"RESILIENCE_MAX_BACKOFF" env-default:"10s"`
+	MaxBackoff time.Duration `yaml:"max_backoff" env:"RESILIENCE_MAX_BACKOFF" env-default:"10s"`
 
 	// Multiplier grows backoff between retries.
-	Multiplier float64 `env:"RESILIENCE_MULTIPLIER" env-default:"2"`
+	Multiplier float64 `yaml:"multiplier" env:"RESILIENCE_MULTIPLIER" env-default:"2"`
 
 	// Jitter adds randomness to backoff (0–1 fraction).
-	Jitter float64 `env:"RESILIENCE_JITTER" env-default:"0.1"`
+	Jitter float64 `yaml:"jitter" env:"RESILIENCE_JITTER" env-default:"0.1"`
 
 	// HedgeDelay is the delay before starting a speculative hedge request.
-	HedgeDelay time.Duration `env:"RESILIENCE_HEDGE_DELAY" env-default:"50ms"`
+	HedgeDelay time.Duration `yaml:"hedge_delay" env:"RESILIENCE_HEDGE_DELAY" env-default:"50ms"`
 
 	// BulkheadMaxConcurrent caps concurrent work in a bulkhead (0 = unset).
-	BulkheadMaxConcurrent int64 `env:"RESILIENCE_BULKHEAD_MAX" env-default:"0"`
+	BulkheadMaxConcurrent int64 `yaml:"bulkhead_max_concurrent" env:"RESILIENCE_BULKHEAD_MAX" env-default:"0"`
+
+	// LimitAlgorithm enables an adaptive concurrency limiter ("vegas", "aimd", "gradient"; empty = off).
+	LimitAlgorithm LimitAlgorithm `yaml:"limit_algorithm" env:"RESILIENCE_LIMIT_ALGORITHM" env-default:""`
+
+	// LimitInitial is the adaptive limiter's starting limit.
+	LimitInitial int64 `yaml:"limit_initial" env:"RESILIENCE_LIMIT_INITIAL" env-default:"20"`
+
+	// LimitMin is the adaptive limiter's lower bound.
+	LimitMin int64 `yaml:"limit_min" env:"RESILIENCE_LIMIT_MIN" env-default:"1"`
+
+	// LimitMax is the adaptive limiter's upper bound.
+	LimitMax int64 `yaml:"limit_max" env:"RESILIENCE_LIMIT_MAX" env-default:"1000"`
+
+	// LimitTimeout counts slower calls as drops for the AIMD limiter (0 = off).
+	LimitTimeout time.Duration `yaml:"limit_timeout" env:"RESILIENCE_LIMIT_TIMEOUT" env-default:"0"`
+
+	// RetryBudgetRatio caps retries to this fraction of recent successes (0 = no budget).
+	RetryBudgetRatio float64 `yaml:"retry_budget_ratio" env:"RESILIENCE_RETRY_BUDGET_RATIO" env-default:"0"`
+
+	// RetryBudgetMinRetries is the per-window retry floor of the budget.
+	RetryBudgetMinRetries int64 `yaml:"retry_budget_min_retries" env:"RESILIENCE_RETRY_BUDGET_MIN_RETRIES" env-default:"10"`
+
+	// RetryBudgetWindow is how far back the budget counts successes and retries.
+	RetryBudgetWindow time.Duration `yaml:"retry_budget_window" env:"RESILIENCE_RETRY_BUDGET_WINDOW" env-default:"10s"`
 }
 
 // DefaultConfig returns env-default values without reading the environment.
@@ -51,6 +96,11 @@ func DefaultConfig() Config {
 		SuccessThreshold:      2,
 		Timeout:               30 * time.Second,
 		MaxRequests:           0,
+		WindowSize:            100,
+		WindowDuration:        60 * time.Second,
+		MinimumCalls:          10,
+		FailureRateThreshold:  50,
+		SlowCallRateThreshold: 100,
 		MaxAttempts:           3,
 		InitialBackoff:        100 * time.Millisecond,
 		MaxBackoff:            10 * time.Second,
@@ -58,6 +108,11 @@ func DefaultConfig() Config {
 		Jitter:                0.1,
 		HedgeDelay:            50 * time.Millisecond,
 		BulkheadMaxConcurrent: 0,
+		LimitInitial:          20,
+		LimitMin:              1,
+		LimitMax:              1000,
+		RetryBudgetMinRetries: 10,
+		RetryBudgetWindow:     10 * time.Second,
 	}
 }
 
@@ -73,6 +128,14 @@ func (c Config) CircuitBreaker() CircuitBreakerConfig {
 		SuccessThreshold: c.SuccessThreshold,
 		Timeout:          c.Timeout,
 		MaxRequests:      c.MaxRequests,
+
+		WindowType:                c.WindowType,
+		WindowSize:                c.WindowSize,
+		WindowDuration:            c.WindowDuration,
+		MinimumCalls:              c.MinimumCalls,
+		FailureRateThreshold:      c.FailureRateThreshold,
+		SlowCallDurationThreshold: c.SlowCallDurationThreshold,
+		SlowCallRateThreshold:     c.SlowCallRateThreshold,
 	}
 }
 
@@ -95,3 +158,28 @@ func (c Config) Bulkhead() BulkheadConfig {
 		MaxConcurrent: c.BulkheadMaxConcurrent,
 	}
 }
+
+// AdaptiveLimiter returns an AdaptiveLimiterConfig derived from Config when
+// LimitAlgorithm is set.
+func (c Config) AdaptiveLimiter() AdaptiveLimiterConfig {
+	return AdaptiveLimiterConfig{
+		Name:         c.Name,
+		Algorithm:    c.LimitAlgorithm,
+		InitialLimit: c.LimitInitial,
+		MinLimit:     c.LimitMin,
+		MaxLimit:     c.LimitMax,
+		Timeout:      c.LimitTimeout,
+	}
+}
+
+// RetryBudget returns a RetryBudgetConfig derived from Config when
+// RetryBudgetRatio > 0. Create one RetryBudget per dependency and set it on
+// every RetryConfig that calls it.
+func (c Config) RetryBudget() RetryBudgetConfig {
+	return RetryBudgetConfig{
+		Name:       c.Name,
+		Ratio:      c.RetryBudgetRatio,
+		MinRetries: c.RetryBudgetMinRetries,
+		Window:     c.RetryBudgetWindow,
+	}
+}
@@ -4,19 +4,26 @@ Package resilience provides common patterns for building robust, fault-tolerant
 This package implements:
   - Circuit Breaker: Prevents cascading failures by stopping requests to failing services.
     Half-open probes are capped by MaxRequests (ErrTooManyRequests when exceeded).
+    Sliding-window mode (WindowCount / WindowTime) opens on failure-rate and
+    slow-call-rate percentages once MinimumCalls is reached; Metrics().Window
+    exposes the per-window stats.
   - Retry: Automatically retries failed operations with exponential backoff and jitter
     (package-level Retry helper and Retrier interface via NewRetrier).
   - Timeout: Deadline enforcement via WithTimeout (returns CodeDeadlineExceeded).
   - Bulkhead: Semaphore-bounded concurrency isolation via pkg/concurrency.
+  - Adaptive limiter: Bulkhead-compatible (ConcurrencyLimiter) limit that follows
+    latency and overload errors (Vegas / AIMD / Gradient, pkg/algorithms/concurrency/adaptive).
+  - Retry budget: caps retries shared across callers to a ratio of recent successes.
   - Hedge: Speculative retry after a delay (first success wins).
   - Fallback: Primary-then-secondary execution helpers.
   - Typed Execute: ExecuteT / RetryT / HedgeT / FallbackT returning (T, error).
 
-Env-tagged Config (RESILIENCE_*) derives CircuitBreaker / Retry / Bulkhead configs.
+Env-tagged Config (RESILIENCE_*) derives CircuitBreaker / Retry / Bulkhead /
+AdaptiveLimiter / RetryBudget configs, and loads from YAML via pkg/config.LoadFrom.
 
 Error mapping (pkg/errors):
   - ErrCircuitOpen → UNAVAILABLE (HTTP 503)
-  - ErrTooManyRequests / ErrBulkheadFull → RESOURCE_EXHAUSTED (HTTP 429)
+  - ErrTooManyRequests / ErrBulkheadFull / ErrLimitExceeded → RESOURCE_EXHAUSTED (HTTP 429)
 
 Prefer this package over mesh-facing facades in pkg/servicemesh for application code.
 Observability belongs on InstrumentedCircuitBreaker; NewCircuitBreaker stays quiet.
@@ -45,6 +52,10 @@ Usage:
 	cfg := resilience.DefaultConfig()
 	cb = resilience.NewCircuitBreaker(cfg.CircuitBreaker())
 
+	// Sliding window: last 100 calls, open at 50% failures or all calls slower than 5s
+	cb = resilience.NewCircuitBreaker(resilience.DefaultSlidingWindowConfig("my-service"))
+	rate := cb.Metrics().Window.FailureRate
+
 	// Instrumented (logging + tracing)
 	icb := resilience.NewInstrumentedBreakerFromConfig(resilience.DefaultCircuitBreakerConfig("my-service"))
 
@@ -60,5 +71,17 @@ Usage:
 	err = bh.Execute(ctx, func(ctx context.Context) error {
 	    return db.Query(ctx)
 	})
+
+	// Adaptive limiter (same Execute contract as Bulkhead)
+	var lim resilience.ConcurrencyLimiter = resilience.NewAdaptiveLimiter(resilience.AdaptiveLimiterConfig{
+	    Name: "db", Algorithm: resilience.LimitGradient, MinLimit: 4, MaxLimit: 256,
+	})
+	err = lim.Execute(ctx, db.Query)
+
+	// Retry budget shared by every caller of a dependency
+	budget := resilience.NewRetryBudget(resilience.RetryBudgetConfig{Name: "payments", Ratio: 0.1})
+	rc := resilience.DefaultRetryConfig()
+	rc.Budget = budget
+	err = resilience.Retry(ctx, rc, upstream.Call)
 */
 package resilience
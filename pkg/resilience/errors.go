@@ -6,11 +6,12 @@ import "github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 //
 // Mapping:
 //   - Circuit open / half-open rejection → UNAVAILABLE (HTTP 503 / gRPC Unavailable)
-//   - Bulkhead full / half-open probe cap → RESOURCE_EXHAUSTED (HTTP 429 / gRPC ResourceExhausted)
+//   - Bulkhead full / adaptive limit / half-open probe cap → RESOURCE_EXHAUSTED (HTTP 429 / gRPC ResourceExhausted)
 const (
 	CodeCircuitOpen      = errors.CodeUnavailable
 	CodeTooManyRequests  = errors.CodeResourceExhausted
 	CodeBulkheadFull     = errors.CodeResourceExhausted
+	CodeLimitExceeded    = errors.CodeResourceExhausted
 	CodeDeadlineExceeded = errors.CodeDeadlineExceeded
 )
 
@@ -22,3 +23,6 @@ var ErrTooManyRequests = errors.ResourceExhausted("too many requests in half-ope
 
 // ErrBulkheadFull is returned when a bulkhead cannot acquire a concurrency slot.
 var ErrBulkheadFull = errors.ResourceExhausted("bulkhead concurrency limit reached", nil)
+
+// ErrLimitExceeded is returned when an AdaptiveLimiter has no free slot.
+var ErrLimitExceeded = errors.ResourceExhausted("adaptive concurrency limit reached", nil)
@@ -36,17 +36,24 @@ func NewInstrumentedCircuitBreaker(next Breaker, name string) *InstrumentedCircu
 func NewInstrumentedBreakerFromConfig(cfg CircuitBreakerConfig) *InstrumentedCircuitBreaker {
 	name := cfg.Name
 	userHook := cfg.OnStateChange
+	var cb *CircuitBreaker
 	cfg.OnStateChange = func(n string, from, to State) {
-		logger.L().Info("circuit breaker state change",
-			"name", n,
-			"from", string(from),
-			"to", string(to),
-		)
+		args := []any{"name", n, "from", string(from), "to", string(to)}
+		if cb != nil && cb.window != nil {
+			w := cb.Metrics().Window
+			args = append(args,
+				"window_calls", w.Calls,
+				"failure_rate", w.FailureRate,
+				"slow_call_rate", w.SlowCallRate,
+			)
+		}
+		logger.L().Info("circuit breaker state change", args...)
 		if userHook != nil {
 			userHook(n, from, to)
 		}
 	}
-	return NewInstrumentedCircuitBreaker(NewCircuitBreaker(cfg), name)
+	cb = NewCircuitBreaker(cfg)
+	return NewInstrumentedCircuitBreaker(cb, name)
 }
 
 // Execute runs fn under the wrapped breaker with a trace span.
@@ -68,7 +75,18 @@ func (i *InstrumentedCircuitBreaker) Execute(ctx context.Context, fn Executor) e
 			)
 		}
 	}
-	span.SetAttributes(attribute.String("circuitbreaker.state", string(i.next.State())))
+	m := i.next.Metrics()
+	span.SetAttributes(attribute.String("circuitbreaker.state", string(m.State)))
+	if m.Window.Type != WindowNone {
+		span.SetAttributes(
+			attribute.String("circuitbreaker.window.type", string(m.Window.Type)),
+			attribute.Int64("circuitbreaker.window.calls", m.Window.Calls),
+			attribute.Int64("circuitbreaker.window.failures", m.Window.Failures),
+			attribute.Int64("circuitbreaker.window.slow_calls", m.Window.SlowCalls),
+			attribute.Float64("circuitbreaker.window.failure_rate", m.Window.FailureRate),
+			attribute.Float64("circuitbreaker.window.slow_call_rate", m.Window.SlowCallRate),
+		)
+	}
 	return err
 }
 
@@ -83,7 +101,7 @@ func (i *InstrumentedCircuitBreaker) Reset() {
 	i.next.Reset()
 }
 
-// Metrics returns wrapped breaker metrics.
+// Metrics returns wrapped breaker metrics, including sliding-window stats.
 func (i *InstrumentedCircuitBreaker) Metrics() CircuitBreakerMetrics {
 	return i.next.Metrics()
 }
@@ -0,0 +1,172 @@
+package resilience
+
+import (
+	"context"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/algorithms/concurrency/adaptive"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+// ConcurrencyLimiter is the Execute contract shared by Bulkhead and
+// AdaptiveLimiter: Execute waits for a slot (or ctx), TryExecute fails fast.
+type ConcurrencyLimiter interface {
+	Execute(ctx context.Context, fn Executor) error
+	TryExecute(ctx context.Context, fn Executor) error
+}
+
+// LimitAlgorithm selects the adaptive concurrency algorithm.
+type LimitAlgorithm string
+
+const (
+	LimitVegas    LimitAlgorithm = "vegas"    // queueing-delay estimate
+	LimitAIMD     LimitAlgorithm = "aimd"     // additive increase, multiplicative decrease
+	LimitGradient LimitAlgorithm = "gradient" // long/short RTT ratio
+)
+
+// AdaptiveLimiterConfig configures an AdaptiveLimiter.
+type AdaptiveLimiterConfig struct {
+	// Name identifies this limiter (for logging/metrics).
+	Name string
+
+	// Algorithm selects the limit algorithm (default LimitAIMD).
+	Algorithm LimitAlgorithm
+
+	// InitialLimit is the starting limit (default MinLimit; Vegas always
+	// starts at MinLimit).
+	InitialLimit int64
+
+	// MinLimit and MaxLimit bound the limit (defaults 1 and 1000).
+	MinLimit int64
+	MaxLimit int64
+
+	// Timeout marks slower calls as drops for LimitAIMD (0 = off).
+	Timeout time.Duration
+
+	// IsDrop reports whether an error signals overload and should shrink the
+	// limit. Defaults to deadline, resource-exhausted and unavailable errors.
+	IsDrop func(error) bool
+}
+
+// AdaptiveLimiter bounds concurrency like a Bulkhead, but the bound follows
+// measured latency and overload errors instead of a fixed MaxConcurrent.
+type AdaptiveLimiter struct {
+	name   string
+	algo   adaptive.Algorithm
+	isDrop func(error) bool
+
+	mu      *concurrency.SmartMutex
+	release chan struct{} // closed and replaced whenever a slot frees up
+}
+
+// NewAdaptiveLimiter creates an adaptive concurrency limiter.
+func NewAdaptiveLimiter(cfg AdaptiveLimiterConfig) *AdaptiveLimiter {
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = 1
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = max(cfg.MinLimit, 1000)
+	}
+	if cfg.InitialLimit <= 0 {
+		cfg.InitialLimit = cfg.MinLimit
+	}
+	if cfg.IsDrop == nil {
+		cfg.IsDrop = isOverload
+	}
+
+	initial, lo, hi := float64(cfg.InitialLimit), float64(cfg.MinLimit), float64(cfg.MaxLimit)
+	var algo adaptive.Algorithm
+	switch cfg.Algorithm {
+	case LimitVegas:
+		algo = adaptive.New(lo, hi)
+	case LimitGradient:
+		algo = adaptive.NewGradient(initial, lo, hi)
+	default:
+		algo = adaptive.NewAIMD(initial, lo, hi, cfg.Timeout)
+	}
+
+	return &AdaptiveLimiter{
+		name:    cfg.Name,
+		algo:    algo,
+		isDrop:  cfg.IsDrop,
+		mu:      concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "AdaptiveLimiter-" + cfg.Name}),
+		release: make(chan struct{}),
+	}
+}
+
+// Name returns the limiter name.
+func (l *AdaptiveLimiter) Name() string {
+	return l.name
+}
+
+// Limit returns the current concurrency limit.
+func (l *AdaptiveLimiter) Limit() int64 {
+	return int64(l.algo.Limit())
+}
+
+// Inflight returns the number of executions currently holding a slot.
+func (l *AdaptiveLimiter) Inflight() int64 {
+	return int64(l.algo.Inflight())
+}
+
+// Execute waits for a slot, runs fn, then feeds its latency and outcome back
+// into the limit. If ctx is cancelled while waiting, it returns ctx.Err().
+func (l *AdaptiveLimiter) Execute(ctx context.Context, fn Executor) error {
+	for {
+		l.mu.Lock()
+		ok := l.algo.Acquire()
+		wait := l.release
+		l.mu.Unlock()
+		if ok {
+			return l.run(ctx, fn)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+// TryExecute runs fn only if a slot is free; otherwise it returns
+// ErrLimitExceeded.
+func (l *AdaptiveLimiter) TryExecute(ctx context.Context, fn Executor) error {
+	if !l.algo.Acquire() {
+		return ErrLimitExceeded
+	}
+	return l.run(ctx, fn)
+}
+
+func (l *AdaptiveLimiter) run(ctx context.Context, fn Executor) error {
+	start := time.Now()
+	err := fn(ctx)
+
+	l.mu.Lock()
+	if err != nil && l.isDrop(err) {
+		l.algo.Drop()
+	} else {
+		l.algo.Release(time.Since(start))
+	}
+	close(l.release)
+	l.release = make(chan struct{})
+	l.mu.Unlock()
+	return err
+}
+
+// isOverload is the default AdaptiveLimiterConfig.IsDrop.
+func isOverload(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch errors.Code(err) {
+	case errors.CodeDeadlineExceeded, errors.CodeResourceExhausted, errors.CodeUnavailable:
+		return true
+	}
+	return false
+}
+
+var (
+	_ ConcurrencyLimiter = (*Bulkhead)(nil)
+	_ ConcurrencyLimiter = (*AdaptiveLimiter)(nil)
+)
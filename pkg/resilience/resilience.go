@@ -68,6 +68,34 @@ type CircuitBreakerConfig struct {
 	// Zero means unlimited (backward compatible).
 	MaxRequests int64
 
+	// WindowType enables sliding-window mode (WindowCount or WindowTime).
+	// In that mode the circuit opens on FailureRateThreshold or
+	// SlowCallRateThreshold instead of FailureThreshold consecutive failures.
+	WindowType WindowType
+
+	// WindowSize is the number of calls a count-based window keeps (default 100).
+	WindowSize int64
+
+	// WindowDuration is the span a time-based window covers (default 60s).
+	WindowDuration time.Duration
+
+	// MinimumCalls is how many calls the window needs before rates are
+	// evaluated (default 10, capped at WindowSize for count windows).
+	MinimumCalls int64
+
+	// FailureRateThreshold opens the circuit when the failure percentage in
+	// the window reaches it (default 50).
+	FailureRateThreshold float64
+
+	// SlowCallDurationThreshold marks calls taking at least this long as
+	// slow. Zero disables slow-call tracking.
+	SlowCallDurationThreshold time.Duration
+
+	// SlowCallRateThreshold opens the circuit when the slow-call percentage
+	// in the window reaches it (default 100). In half-open state a slow call
+	// counts as a failed probe.
+	SlowCallRateThreshold float64
+
 	// OnStateChange is called when the circuit breaker changes state.
 	OnStateChange func(name string, from, to State)
 }
@@ -91,6 +119,10 @@ type RetryConfig struct {
 
 	// RetryIf determines if an error should be retried.
 	RetryIf func(error) bool
+
+	// Budget, when set, caps retries to a share of recent successes. When it
+	// is spent the last error is returned without further attempts.
+	Budget *RetryBudget
 }
 
 // DefaultCircuitBreakerConfig returns sensible defaults.
@@ -104,6 +136,23 @@ func DefaultCircuitBreakerConfig(name string) CircuitBreakerConfig {
 	}
 }
 
+// DefaultSlidingWindowConfig returns defaults for a count-based sliding
+// window over the last 100 calls, opening at a 50% failure rate or when
+// every call takes longer than 5s, once 10 calls have been recorded.
+func DefaultSlidingWindowConfig(name string) CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		Name:                      name,
+		SuccessThreshold:          2,
+		Timeout:                   30 * time.Second,
+		WindowType:                WindowCount,
+		WindowSize:                100,
+		MinimumCalls:              10,
+		FailureRateThreshold:      50,
+		SlowCallDurationThreshold: 5 * time.Second,
+		SlowCallRateThreshold:     100,
+	}
+}
+
 // DefaultRetryConfig returns sensible defaults.
 func DefaultRetryConfig() RetryConfig {
 	return RetryConfig{
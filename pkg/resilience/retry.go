@@ -39,6 +39,9 @@ func Retry(ctx context.Context, cfg RetryConfig, fn Executor) error {
 		// Execute
 		err := fn(ctx)
 		if err == nil {
+			if cfg.Budget != nil {
+				cfg.Budget.RecordSuccess()
+			}
 			return nil
 		}
 
@@ -54,6 +57,11 @@ func Retry(ctx context.Context, cfg RetryConfig, fn Executor) error {
 			break
 		}
 
+		// Give up early when the shared retry budget is spent
+		if cfg.Budget != nil && !cfg.Budget.TryRetry() {
+			break
+		}
+
 		// Calculate backoff with jitter
 		jitter := 1.0
 		if cfg.Jitter > 0 {
@@ -59,3 +59,159 @@ func TestCircuitBreaker_Reset(t *testing.T) {
 		t.Error("Reset failed to close circuit")
 	}
 }
+
+// fakeClock is a manually advanced clock for window tests.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestCircuitBreaker_CountWindowFailureRate(t *testing.T) {
+	cb := resilience.NewCircuitBreaker(resilience.CircuitBreakerConfig{
+		Name:                 "count-window",
+		WindowType:           resilience.WindowCount,
+		WindowSize:           10,
+		MinimumCalls:         10,
+		FailureRateThreshold: 50,
+	})
+	ctx := context.Background()
+	fail := errors.New("fail")
+	ok := func(ctx context.Context) error { return nil }
+	bad := func(ctx context.Context) error { return fail }
+
+	// Four failures stay below MinimumCalls, unlike FailureThreshold mode.
+	for i := 0; i < 4; i++ {
+		_ = cb.Execute(ctx, bad)
+	}
+	if cb.State() != resilience.StateClosed {
+		t.Fatalf("opened before MinimumCalls was reached")
+	}
+	m := cb.Metrics().Window
+	if m.Calls != 4 || m.Failures != 4 || m.FailureRate != 0 {
+		t.Fatalf("unexpected window before MinimumCalls: %+v", m)
+	}
+
+	// Successes push old failures out of the ring: 10 calls, 4 failures = 40%.
+	for i := 0; i < 6; i++ {
+		_ = cb.Execute(ctx, ok)
+	}
+	m = cb.Metrics().Window
+	if cb.State() != resilience.StateClosed || m.Calls != 10 || m.FailureRate != 40 {
+		t.Fatalf("expected closed at 40%%, got %v %+v", cb.State(), m)
+	}
+	_ = cb.Execute(ctx, ok) // evicts a failure: 30%
+	if m = cb.Metrics().Window; m.Calls != 10 || m.Failures != 3 {
+		t.Fatalf("ring did not evict oldest call: %+v", m)
+	}
+
+	// New failures first evict the three old ones; the fifth reaches 50%.
+	for i := 1; i <= 5; i++ {
+		_ = cb.Execute(ctx, bad)
+		if open := cb.State() == resilience.StateOpen; open != (i == 5) {
+			t.Fatalf("failure %d: state %v %+v", i, cb.State(), cb.Metrics().Window)
+		}
+	}
+	if err := cb.Execute(ctx, ok); !errors.Is(err, resilience.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	cb.Reset()
+	if m = cb.Metrics().Window; m.Calls != 0 || m.Type != resilience.WindowCount {
+		t.Fatalf("Reset should clear the window: %+v", m)
+	}
+}
+
+func TestCircuitBreaker_SlowCallRate(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	cb := resilience.NewCircuitBreaker(resilience.CircuitBreakerConfig{
+		Name:                      "slow-calls",
+		WindowType:                resilience.WindowCount,
+		WindowSize:                4,
+		MinimumCalls:              4,
+		SlowCallDurationThreshold: time.Second,
+		SlowCallRateThreshold:     75,
+		SuccessThreshold:          1,
+		Timeout:                   10 * time.Second,
+	})
+	cb.SetNowFunc(clock.now)
+	ctx := context.Background()
+	call := func(d time.Duration) error {
+		return cb.Execute(ctx, func(ctx context.Context) error {
+			clock.advance(d)
+			return nil
+		})
+	}
+
+	_ = call(10 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		_ = call(2 * time.Second)
+	}
+	if cb.State() != resilience.StateClosed {
+		t.Fatalf("opened before MinimumCalls was reached")
+	}
+	_ = call(2 * time.Second)
+	m := cb.Metrics().Window
+	if cb.State() != resilience.StateOpen || m.SlowCalls != 3 || m.SlowCallRate != 75 || m.Failures != 0 {
+		t.Fatalf("expected open on slow calls, got %v %+v", cb.State(), m)
+	}
+
+	// A slow half-open probe reopens the circuit.
+	clock.advance(11 * time.Second)
+	_ = call(2 * time.Second)
+	if cb.State() != resilience.StateOpen {
+		t.Fatalf("expected slow probe to reopen, got %v", cb.State())
+	}
+
+	// A fast probe closes it with a fresh window.
+	clock.advance(11 * time.Second)
+	_ = call(time.Millisecond)
+	if cb.State() != resilience.StateClosed || cb.Metrics().Window.Calls != 0 {
+		t.Fatalf("expected closed with empty window, got %v %+v", cb.State(), cb.Metrics().Window)
+	}
+}
+
+func TestCircuitBreaker_TimeWindowExpires(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	cb := resilience.NewCircuitBreaker(resilience.CircuitBreakerConfig{
+		Name:                 "time-window",
+		WindowType:           resilience.WindowTime,
+		WindowDuration:       10 * time.Second,
+		MinimumCalls:         4,
+		FailureRateThreshold: 50,
+	})
+	cb.SetNowFunc(clock.now)
+	ctx := context.Background()
+	fail := errors.New("fail")
+
+	for i := 0; i < 3; i++ {
+		_ = cb.Execute(ctx, func(ctx context.Context) error { return fail })
+	}
+	clock.advance(11 * time.Second)
+	if m := cb.Metrics().Window; m.Calls != 0 {
+		t.Fatalf("old calls should age out of the window: %+v", m)
+	}
+
+	// One more failure after the old ones expired does not trip the breaker.
+	_ = cb.Execute(ctx, func(ctx context.Context) error { return fail })
+	for i := 0; i < 3; i++ {
+		_ = cb.Execute(ctx, func(ctx context.Context) error { return nil })
+	}
+	m := cb.Metrics().Window
+	if cb.State() != resilience.StateClosed || m.Calls != 4 || m.FailureRate != 25 {
+		t.Fatalf("expected closed at 25%%, got %v %+v", cb.State(), m)
+	}
+}
+
+func TestCircuitBreaker_ConfigWindow(t *testing.T) {
+	cfg := resilience.DefaultConfig()
+	cfg.WindowType = resilience.WindowCount
+	var b resilience.Breaker = resilience.NewInstrumentedBreakerFromConfig(cfg.CircuitBreaker())
+	fail := errors.New("fail")
+	for i := 0; i < 10; i++ {
+		_ = b.Execute(context.Background(), func(ctx context.Context) error { return fail })
+	}
+	m := b.Metrics()
+	if m.State != resilience.StateOpen || m.Window.Calls != 10 || m.Window.FailureRate != 100 {
+		t.Fatalf("expected instrumented window breaker to open, got %+v", m)
+	}
+}
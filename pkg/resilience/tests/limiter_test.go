@@ -0,0 +1,194 @@
+package resilience_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/config"
+	apperrors "github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/resilience"
+)
+
+func TestAdaptiveLimiter_ShrinksOnOverload(t *testing.T) {
+	lim := resilience.NewAdaptiveLimiter(resilience.AdaptiveLimiterConfig{
+		Name:         "aimd",
+		Algorithm:    resilience.LimitAIMD,
+		InitialLimit: 20,
+		MinLimit:     2,
+		MaxLimit:     50,
+	})
+	ctx := context.Background()
+	overloaded := apperrors.Unavailable("upstream overloaded", nil)
+	for i := 0; i < 20; i++ {
+		_ = lim.Execute(ctx, func(ctx context.Context) error { return overloaded })
+	}
+	if lim.Limit() != 2 {
+		t.Fatalf("expected limit to back off to MinLimit, got %d", lim.Limit())
+	}
+
+	// Ordinary errors are not overload signals.
+	before := lim.Limit()
+	_ = lim.Execute(ctx, func(ctx context.Context) error { return errors.New("not found") })
+	if lim.Limit() < before {
+		t.Fatalf("non-overload error shrank the limit: %d -> %d", before, lim.Limit())
+	}
+}
+
+func TestAdaptiveLimiter_BoundsConcurrency(t *testing.T) {
+	lim := resilience.NewAdaptiveLimiter(resilience.AdaptiveLimiterConfig{
+		Name:         "bounded",
+		Algorithm:    resilience.LimitGradient,
+		InitialLimit: 3,
+		MinLimit:     3,
+		MaxLimit:     3,
+	})
+
+	var inFlight, maxSeen atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := lim.Execute(context.Background(), func(ctx context.Context) error {
+				cur := inFlight.Add(1)
+				for {
+					old := maxSeen.Load()
+					if cur <= old || maxSeen.CompareAndSwap(old, cur) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				inFlight.Add(-1)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Execute: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxSeen.Load() > 3 {
+		t.Fatalf("expected max concurrency <= 3, got %d", maxSeen.Load())
+	}
+	if lim.Inflight() != 0 {
+		t.Fatalf("expected no slots held, got %d", lim.Inflight())
+	}
+}
+
+func TestAdaptiveLimiter_TryExecuteAndWaitCancel(t *testing.T) {
+	var lim resilience.ConcurrencyLimiter = resilience.NewAdaptiveLimiter(resilience.AdaptiveLimiterConfig{
+		Name:     "try",
+		MinLimit: 1,
+		MaxLimit: 1,
+	})
+	started, release, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		_ = lim.Execute(context.Background(), func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+		close(done)
+	}()
+	<-started
+
+	err := lim.TryExecute(context.Background(), func(ctx context.Context) error { return nil })
+	if !errors.Is(err, resilience.ErrLimitExceeded) || !apperrors.IsCode(err, resilience.CodeLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := lim.Execute(ctx, func(ctx context.Context) error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected waiting Execute to honour ctx, got %v", err)
+	}
+	close(release)
+	<-done
+}
+
+func TestRetryBudget_CapsRetries(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	budget := resilience.NewRetryBudget(resilience.RetryBudgetConfig{
+		Name:       "shared",
+		Ratio:      0.5,
+		MinRetries: 2,
+		Window:     10 * time.Second,
+	})
+	budget.SetNowFunc(func() time.Time { return now })
+
+	cfg := resilience.DefaultRetryConfig()
+	cfg.MaxAttempts = 5
+	cfg.InitialBackoff = time.Microsecond
+	cfg.Budget = budget
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		if err := resilience.Retry(ctx, cfg, func(ctx context.Context) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m := budget.Metrics(); m.Successes != 4 || m.Available != 4 {
+		t.Fatalf("expected 2 floor + 50%% of 4 successes, got %+v", m)
+	}
+
+	// Two callers sharing the budget: together they get 4 retries, not 8.
+	var calls int
+	fail := errors.New("down")
+	for i := 0; i < 2; i++ {
+		err := resilience.Retry(ctx, cfg, func(ctx context.Context) error {
+			calls++
+			return fail
+		})
+		if !errors.Is(err, fail) {
+			t.Fatalf("expected last error when the budget is spent, got %v", err)
+		}
+	}
+	if calls != 2+4 {
+		t.Fatalf("expected 2 first attempts + 4 budgeted retries, got %d calls", calls)
+	}
+	if m := budget.Metrics(); m.Retries != 4 || m.Available != 0 {
+		t.Fatalf("unexpected budget after storm: %+v", m)
+	}
+
+	// The window slides: old retries expire and the floor comes back.
+	now = now.Add(11 * time.Second)
+	if m := budget.Metrics(); m.Available != 2 {
+		t.Fatalf("expected only the floor after the window slid, got %+v", m)
+	}
+}
+
+func TestConfig_LimiterAndBudgetFromYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resilience.yaml")
+	yaml := "name: payments\n" +
+		"limit_algorithm: gradient\n" +
+		"limit_initial: 8\n" +
+		"limit_max: 64\n" +
+		"retry_budget_ratio: 0.1\n" +
+		"retry_budget_window: 30s\n" +
+		"window_type: count\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg resilience.Config
+	if err := config.LoadFrom(path, &cfg); err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	lc := cfg.AdaptiveLimiter()
+	if lc.Algorithm != resilience.LimitGradient || lc.InitialLimit != 8 || lc.MinLimit != 1 || lc.MaxLimit != 64 {
+		t.Fatalf("unexpected limiter config: %+v", lc)
+	}
+	bc := cfg.RetryBudget()
+	if bc.Name != "payments" || bc.Ratio != 0.1 || bc.MinRetries != 10 || bc.Window != 30*time.Second {
+		t.Fatalf("unexpected budget config: %+v", bc)
+	}
+	if cfg.CircuitBreaker().WindowType != resilience.WindowCount {
+		t.Fatalf("expected window type from YAML, got %q", cfg.CircuitBreaker().WindowType)
+	}
+}
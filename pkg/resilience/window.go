@@ -0,0 +1,165 @@
+package resilience
+
+import (
+	"time"
+)
+
+// WindowType selects how a circuit breaker aggregates call outcomes.
+type WindowType string
+
+const (
+	// WindowNone keeps the classic consecutive-failure FailureThreshold mode.
+	WindowNone WindowType = ""
+	// WindowCount aggregates the last WindowSize calls.
+	WindowCount WindowType = "count"
+	// WindowTime aggregates the calls made during the last WindowDuration.
+	WindowTime WindowType = "time"
+)
+
+// timeWindowBuckets is the number of buckets a time-based window is split
+// into; older buckets age out one at a time as the window slides.
+const timeWindowBuckets = 10
+
+// WindowMetrics is a snapshot of a sliding window's call statistics.
+// Rates are percentages (0–100) and stay zero until MinimumCalls is reached.
+type WindowMetrics struct {
+	Type         WindowType
+	Calls        int64
+	Failures     int64
+	SlowCalls    int64
+	FailureRate  float64
+	SlowCallRate float64
+}
+
+// outcome is a single recorded call.
+type outcome struct {
+	failed bool
+	slow   bool
+}
+
+// window aggregates recent call outcomes. Implementations are not safe for
+// concurrent use; the circuit breaker serializes access.
+type window interface {
+	record(o outcome, now time.Time)
+	totals(now time.Time) (calls, failures, slow int64)
+	reset()
+}
+
+// countWindow is a ring buffer over the last size calls.
+type countWindow struct {
+	ring     []outcome
+	next     int
+	filled   int
+	failures int64
+	slow     int64
+}
+
+func newCountWindow(size int64) *countWindow {
+	return &countWindow{ring: make([]outcome, size)}
+}
+
+func (w *countWindow) record(o outcome, _ time.Time) {
+	if w.filled == len(w.ring) {
+		w.subtract(w.ring[w.next])
+	} else {
+		w.filled++
+	}
+	w.ring[w.next] = o
+	w.next = (w.next + 1) % len(w.ring)
+	if o.failed {
+		w.failures++
+	}
+	if o.slow {
+		w.slow++
+	}
+}
+
+func (w *countWindow) subtract(o outcome) {
+	if o.failed {
+		w.failures--
+	}
+	if o.slow {
+		w.slow--
+	}
+}
+
+func (w *countWindow) totals(time.Time) (int64, int64, int64) {
+	return int64(w.filled), w.failures, w.slow
+}
+
+func (w *countWindow) reset() {
+	*w = countWindow{ring: make([]outcome, len(w.ring))}
+}
+
+// bucket holds the aggregated outcomes of one slice of a time window.
+type bucket struct {
+	epoch    int64
+	calls    int64
+	failures int64
+	slow     int64
+}
+
+// timeWindow splits its duration into fixed buckets indexed by epoch
+// (unix nanos / bucket width); stale buckets are ignored and reused.
+type timeWindow struct {
+	width   int64
+	buckets [timeWindowBuckets]bucket
+}
+
+func newTimeWindow(d time.Duration) *timeWindow {
+	width := int64(d) / timeWindowBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &timeWindow{width: width}
+}
+
+func (w *timeWindow) record(o outcome, now time.Time) {
+	epoch := now.UnixNano() / w.width
+	b := &w.buckets[epoch%timeWindowBuckets]
+	if b.epoch != epoch {
+		*b = bucket{epoch: epoch}
+	}
+	b.calls++
+	if o.failed {
+		b.failures++
+	}
+	if o.slow {
+		b.slow++
+	}
+}
+
+func (w *timeWindow) totals(now time.Time) (calls, failures, slow int64) {
+	epoch := now.UnixNano() / w.width
+	for _, b := range w.buckets {
+		if b.calls == 0 || epoch-b.epoch >= timeWindowBuckets || b.epoch > epoch {
+			continue
+		}
+		calls += b.calls
+		failures += b.failures
+		slow += b.slow
+	}
+	return calls, failures, slow
+}
+
+func (w *timeWindow) reset() {
+	w.buckets = [timeWindowBuckets]bucket{}
+}
+
+func newWindow(cfg CircuitBreakerConfig) window {
+	switch cfg.WindowType {
+	case WindowCount:
+		return newCountWindow(cfg.WindowSize)
+	case WindowTime:
+		return newTimeWindow(cfg.WindowDuration)
+	}
+	return nil
+}
+
+// percent returns part/total as a percentage.
+func percent(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
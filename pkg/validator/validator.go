@@ -8,10 +8,17 @@ import (
 	playground "github.com/go-playground/validator/v10"
 )
 
+// Patterns behind the custom slug and phone_e164 rules, exported so schema
+// generators (pkg/api/openapi) can publish the same constraint.
+const (
+	SlugPattern  = `^[a-z0-9]+(?:-[a-z0-9]+)*$`
+	PhonePattern = `^\+[1-9]\d{1,14}$` // E.164 standard roughly
+)
+
 // Common Regex Patterns
 var (
-	slugRegex  = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
-	phoneRegex = regexp.MustCompile(`^\+[1-9]\d{1,14}$`) // E.164 standard roughly
+	slugRegex  = regexp.MustCompile(SlugPattern)
+	phoneRegex = regexp.MustCompile(PhonePattern)
 )
 
 // Validator validates structs and variables against validation tags.
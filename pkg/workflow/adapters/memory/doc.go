@@ -2,4 +2,14 @@
 //
 // Uses pkg/concurrency.SmartRWMutex for locking. Honors StartOptions.Timeout
 // (falls back to Config.DefaultTimeout) and sets StatusTimedOut on deadline.
+//
+// Supported state types: Task, Wait, Choice, Parallel, Map, Pass/Succeed and Fail.
+// Map states fan out over an input array with MaxConcurrency, per-item Retry
+// and Catch, and ToleratedFailurePercentage; a Catch with Next on the Map state
+// routes the failure of the whole state to Next.
+//
+// NewWithHistory records each execution's state transitions, signals and
+// outcome to a workflow.HistoryStore (see pkg/workflow/history). GetExecution
+// returns that log in Execution.History, and ResumeAll continues running
+// executions after a restart from their last completed state.
 package memory
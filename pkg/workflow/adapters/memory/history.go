@@ -0,0 +1,209 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow"
+)
+
+// appendLocked stamps ev with the next sequence and adds it to the in-memory
+// log. Caller holds e.mu and persists the returned event after unlocking.
+func (e *Engine) appendLocked(ev workflow.HistoryEvent) workflow.HistoryEvent {
+	ev.Sequence = int64(len(e.logs[ev.ExecutionID]) + 1)
+	ev.Timestamp = time.Now()
+	e.logs[ev.ExecutionID] = append(e.logs[ev.ExecutionID], ev)
+	return ev
+}
+
+// record appends ev to the in-memory log and the HistoryStore.
+func (e *Engine) record(ev workflow.HistoryEvent) {
+	e.mu.Lock()
+	ev = e.appendLocked(ev)
+	e.mu.Unlock()
+	e.persist(ev)
+}
+
+func (e *Engine) recordStateFailed(exec *workflow.Execution, state string, err error) {
+	e.record(workflow.HistoryEvent{
+		ExecutionID: exec.ID,
+		Type:        workflow.HistoryStateFailed,
+		State:       state,
+		Error:       err.Error(),
+	})
+}
+
+func (e *Engine) finishedEventLocked(exec *workflow.Execution) workflow.HistoryEvent {
+	return e.appendLocked(workflow.HistoryEvent{
+		ExecutionID: exec.ID,
+		Type:        workflow.HistoryExecutionFinished,
+		Status:      exec.Status,
+		Output:      exec.Output,
+		Error:       exec.Error,
+	})
+}
+
+// persist writes ev to the HistoryStore. Runner-side failures are logged
+// rather than failing the execution; the in-memory log stays complete.
+func (e *Engine) persist(ev workflow.HistoryEvent) {
+	if e.history == nil {
+		return
+	}
+	ctx := context.Background()
+	if err := e.history.Append(ctx, ev); err != nil {
+		logger.L().ErrorContext(ctx, "workflow history append failed",
+			"execution_id", ev.ExecutionID, "type", ev.Type, "sequence", ev.Sequence, "error", err)
+	}
+}
+
+// forget drops an execution whose start could not be recorded.
+func (e *Engine) forget(executionID, idempotencyKey string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.executions, executionID)
+	delete(e.signals, executionID)
+	delete(e.logs, executionID)
+	if idempotencyKey != "" && e.idempo[idempotencyKey] == executionID {
+		delete(e.idempo, idempotencyKey)
+	}
+}
+
+// resumePoint is where a replayed execution continues.
+type resumePoint struct {
+	state          string // empty: the workflow's StartAt
+	data           interface{}
+	done           bool // last state succeeded with no Next
+	timeout        time.Duration
+	idempotencyKey string
+	signals        map[string]interface{}
+}
+
+// replay folds an event log into the execution it describes and the point
+// where a still-running execution should continue.
+func replay(events []workflow.HistoryEvent) (*workflow.Execution, resumePoint) {
+	exec := &workflow.Execution{Status: workflow.StatusRunning}
+	rp := resumePoint{signals: make(map[string]interface{})}
+	for _, ev := range events {
+		switch ev.Type {
+		case workflow.HistoryExecutionStarted:
+			exec.ID = ev.ExecutionID
+			exec.WorkflowID = ev.WorkflowID
+			exec.Input = ev.Input
+			exec.StartedAt = ev.Timestamp
+			rp.data = ev.Input
+			rp.timeout = ev.Timeout
+			rp.idempotencyKey = ev.IdempotencyKey
+		case workflow.HistoryStateEntered:
+			// A state without a later success is re-run with its recorded input.
+			exec.CurrentState = ev.State
+			rp.state = ev.State
+			rp.data = ev.Input
+		case workflow.HistoryStateSucceeded:
+			rp.state = ev.Next
+			rp.data = ev.Output
+			rp.done = ev.Next == ""
+			if ev.Next != "" {
+				exec.CurrentState = ev.Next
+			}
+		case workflow.HistorySignalReceived:
+			rp.signals[ev.SignalName] = ev.Input
+		case workflow.HistoryExecutionFinished:
+			exec.Status = ev.Status
+			exec.Output = ev.Output
+			exec.Error = ev.Error
+			exec.CompletedAt = ev.Timestamp
+		}
+	}
+	exec.History = events
+	return exec, rp
+}
+
+// Resume continues one execution from its stored event log. Completed states
+// are not re-run; a state that was in flight when the process stopped runs
+// again with its recorded input, so Task handlers should be idempotent.
+// Parallel and Map states resume as a whole. The workflow definition and its
+// task handlers must be registered first. Finished executions are returned
+// as recorded.
+func (e *Engine) Resume(ctx context.Context, executionID string) (*workflow.Execution, error) {
+	if e.history == nil {
+		return nil, errors.FailedPrecondition("workflow engine has no history store", nil)
+	}
+	events, err := e.history.Load(ctx, executionID)
+	if err != nil {
+		return nil, err
+	}
+	exec, rp := replay(events)
+	if exec.ID == "" {
+		return nil, errors.Internal("workflow history has no start event", nil)
+	}
+	if exec.Status != workflow.StatusRunning {
+		return copyExecution(exec), nil
+	}
+
+	e.mu.Lock()
+	if existing, ok := e.executions[executionID]; ok {
+		cp := copyExecution(existing)
+		e.mu.Unlock()
+		return cp, nil
+	}
+	wf, ok := e.workflows[exec.WorkflowID]
+	if !ok {
+		e.mu.Unlock()
+		return nil, workflow.ErrWorkflowNotFound
+	}
+	exec.History = nil
+	e.executions[executionID] = exec
+	e.signals[executionID] = rp.signals
+	e.logs[executionID] = append([]workflow.HistoryEvent(nil), events...)
+	if rp.idempotencyKey != "" {
+		e.idempo[rp.idempotencyKey] = executionID
+	}
+	defCopy := *wf
+	handlers := e.handlersLocked()
+	work := e.workDuration
+	result := copyExecution(exec)
+	e.mu.Unlock()
+
+	timeout := rp.timeout
+	if timeout > 0 {
+		timeout -= time.Since(exec.StartedAt)
+		if timeout <= 0 {
+			e.finish(exec, workflow.StatusTimedOut, nil, workflow.ErrExecutionTimeout)
+			return e.GetExecution(ctx, executionID)
+		}
+	}
+	if rp.done {
+		e.finish(exec, workflow.StatusCompleted, rp.data, nil)
+		return e.GetExecution(ctx, executionID)
+	}
+	start := rp.state
+	if start == "" {
+		start = defCopy.StartAt
+	}
+
+	go e.runExecution(context.Background(), exec, &defCopy, handlers, timeout, work, start, rp.data)
+
+	return result, nil
+}
+
+// ResumeAll resumes every execution the HistoryStore lists as incomplete.
+func (e *Engine) ResumeAll(ctx context.Context) ([]*workflow.Execution, error) {
+	if e.history == nil {
+		return nil, errors.FailedPrecondition("workflow engine has no history store", nil)
+	}
+	ids, err := e.history.ListIncomplete(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*workflow.Execution, 0, len(ids))
+	for _, id := range ids {
+		exec, err := e.Resume(ctx, id)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, exec)
+	}
+	return out, nil
+}
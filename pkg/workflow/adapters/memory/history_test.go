@@ -0,0 +1,181 @@
+package memory_test
+
+import (
+	"context"
+	stderrors "errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/adapters/memory"
+	historyfile "github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/history/adapters/file"
+	"github.com/stretchr/testify/require"
+)
+
+// crashableStore stops persisting once down is set, simulating a process
+// that died while its runner goroutines were still going.
+type crashableStore struct {
+	workflow.HistoryStore
+	down atomic.Bool
+}
+
+func (s *crashableStore) Append(ctx context.Context, ev workflow.HistoryEvent) error {
+	if s.down.Load() {
+		return stderrors.New("process stopped")
+	}
+	return s.HistoryStore.Append(ctx, ev)
+}
+
+func twoStepDefinition() workflow.WorkflowDefinition {
+	return workflow.WorkflowDefinition{
+		ID:      "history-wf",
+		StartAt: "reserve",
+		States: []workflow.State{
+			{Name: "reserve", Type: "Task", Resource: "reserve", Next: "charge"},
+			{Name: "charge", Type: "Task", Resource: "charge", End: true},
+		},
+	}
+}
+
+func eventTypes(events []workflow.HistoryEvent) []workflow.HistoryEventType {
+	out := make([]workflow.HistoryEventType, len(events))
+	for i, ev := range events {
+		out[i] = ev.Type
+	}
+	return out
+}
+
+func TestHistoryRecordsStepsAndSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	store, err := historyfile.New(t.TempDir())
+	require.NoError(t, err)
+
+	eng := memory.NewWithHistory(workflow.Config{}, store)
+	require.NoError(t, eng.RegisterWorkflow(ctx, twoStepDefinition()))
+	eng.RegisterTaskHandler("reserve", func(ctx context.Context, input interface{}) (interface{}, error) {
+		return map[string]interface{}{"reserved": true}, nil
+	})
+
+	exec, err := eng.Start(ctx, workflow.StartOptions{WorkflowID: "history-wf", ExecutionID: "exec-1", Input: map[string]interface{}{"order": "o-1"}})
+	require.NoError(t, err)
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err = eng.Wait(waitCtx, exec.ID)
+	require.NoError(t, err)
+
+	got, err := eng.GetExecution(ctx, exec.ID)
+	require.NoError(t, err)
+	require.Equal(t, []workflow.HistoryEventType{
+		workflow.HistoryExecutionStarted,
+		workflow.HistoryStateEntered,
+		workflow.HistoryStateSucceeded,
+		workflow.HistoryStateEntered,
+		workflow.HistoryStateSucceeded,
+		workflow.HistoryExecutionFinished,
+	}, eventTypes(got.History))
+	require.Equal(t, "reserve", got.History[1].State)
+	require.Equal(t, map[string]interface{}{"order": "o-1"}, got.History[1].Input)
+	require.Equal(t, "charge", got.History[2].Next)
+	require.Equal(t, map[string]interface{}{"reserved": true}, got.History[2].Output)
+
+	// A fresh engine on the same store serves the finished execution from history.
+	restarted := memory.NewWithHistory(workflow.Config{}, store)
+	rebuilt, err := restarted.GetExecution(ctx, exec.ID)
+	require.NoError(t, err)
+	require.Equal(t, workflow.StatusCompleted, rebuilt.Status)
+	require.Equal(t, "history-wf", rebuilt.WorkflowID)
+	require.Equal(t, map[string]interface{}{"reserved": true}, rebuilt.Output)
+	require.Len(t, rebuilt.History, 6)
+
+	incomplete, err := store.ListIncomplete(ctx)
+	require.NoError(t, err)
+	require.Empty(t, incomplete)
+}
+
+func TestResumeAllContinuesFromLastCompletedState(t *testing.T) {
+	ctx := context.Background()
+	files, err := historyfile.New(t.TempDir())
+	require.NoError(t, err)
+	store := &crashableStore{HistoryStore: files}
+
+	var reserveRuns atomic.Int32
+	reserve := func(ctx context.Context, input interface{}) (interface{}, error) {
+		reserveRuns.Add(1)
+		return map[string]interface{}{"reserved": true}, nil
+	}
+
+	// First process: charge blocks until the "crash".
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	first := memory.NewWithHistory(workflow.Config{}, store)
+	require.NoError(t, first.RegisterWorkflow(ctx, twoStepDefinition()))
+	first.RegisterTaskHandler("reserve", reserve)
+	first.RegisterTaskHandler("charge", func(ctx context.Context, input interface{}) (interface{}, error) {
+		close(entered)
+		<-release
+		return nil, stderrors.New("unreachable after crash")
+	})
+	_, err = first.Start(ctx, workflow.StartOptions{WorkflowID: "history-wf", ExecutionID: "exec-crash", IdempotencyKey: "idem-1"})
+	require.NoError(t, err)
+	<-entered
+	require.NoError(t, first.Signal(ctx, "exec-crash", "approve", map[string]interface{}{"by": "ops"}))
+	store.down.Store(true)
+	close(release)
+
+	// Second process: same store, same definition and handlers.
+	second := memory.NewWithHistory(workflow.Config{}, files)
+	require.NoError(t, second.RegisterWorkflow(ctx, twoStepDefinition()))
+	second.RegisterTaskHandler("reserve", reserve)
+	var chargeInput atomic.Value
+	second.RegisterTaskHandler("charge", func(ctx context.Context, input interface{}) (interface{}, error) {
+		chargeInput.Store(input)
+		return map[string]interface{}{"charged": true}, nil
+	})
+
+	resumed, err := second.ResumeAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, resumed, 1)
+	require.Equal(t, "exec-crash", resumed[0].ID)
+	require.Equal(t, workflow.StatusRunning, resumed[0].Status)
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	done, err := second.Wait(waitCtx, "exec-crash")
+	require.NoError(t, err)
+	require.Equal(t, workflow.StatusCompleted, done.Status)
+	require.Equal(t, map[string]interface{}{"charged": true}, done.Output)
+	require.Equal(t, int32(1), reserveRuns.Load(), "completed states are not re-run")
+	require.Equal(t, map[string]interface{}{"reserved": true}, chargeInput.Load())
+
+	// Idempotency keys survive the restart.
+	again, err := second.Start(ctx, workflow.StartOptions{WorkflowID: "history-wf", IdempotencyKey: "idem-1"})
+	require.NoError(t, err)
+	require.Equal(t, "exec-crash", again.ID)
+
+	got, err := second.GetExecution(ctx, "exec-crash")
+	require.NoError(t, err)
+	require.Equal(t, []workflow.HistoryEventType{
+		workflow.HistoryExecutionStarted,
+		workflow.HistoryStateEntered,
+		workflow.HistoryStateSucceeded,
+		workflow.HistoryStateEntered,
+		workflow.HistorySignalReceived,
+		workflow.HistoryStateEntered,
+		workflow.HistoryStateSucceeded,
+		workflow.HistoryExecutionFinished,
+	}, eventTypes(got.History))
+	for i, ev := range got.History {
+		require.Equal(t, int64(i+1), ev.Sequence)
+	}
+
+	incomplete, err := files.ListIncomplete(ctx)
+	require.NoError(t, err)
+	require.Empty(t, incomplete)
+}
+
+func TestResumeRequiresHistoryStore(t *testing.T) {
+	eng := memory.New().(*memory.Engine)
+	_, err := eng.ResumeAll(context.Background())
+	require.Error(t, err)
+}
@@ -0,0 +1,211 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow"
+)
+
+// execMap runs st.ItemProcessor once per element of the array selected by
+// st.ItemsPath, at most st.MaxConcurrency at a time. Results keep item order.
+//
+// Retry policies on the Map state re-run a failing item on its own. An item
+// whose final error matches a Catch policy without Next yields {"Error",
+// "Cause"} in its slot instead of failing; other failures count against
+// ToleratedFailurePercentage and leave a nil slot. Catch policies with Next
+// apply to the state as a whole (see catchState).
+func (e *Engine) execMap(
+	ctx context.Context,
+	st workflow.State,
+	parentStates map[string]workflow.State,
+	handlers map[string]workflow.TaskHandler,
+	input interface{},
+) (interface{}, error) {
+	if st.ItemProcessor == nil {
+		return nil, errors.InvalidArgument(fmt.Sprintf("map state %q missing ItemProcessor", st.Name), nil)
+	}
+	items, err := selectItems(st.ItemsPath, input)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]interface{}, len(items))
+	if len(items) == 0 {
+		return results, nil
+	}
+
+	// Failures beyond this count fail the whole state, so stop scheduling then.
+	tolerated := int(math.Floor(float64(len(items)) * st.ToleratedFailurePercentage / 100))
+
+	mapCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	limit := st.MaxConcurrency
+	if limit <= 0 || limit > len(items) {
+		limit = len(items)
+	}
+	sem := make(chan struct{}, limit)
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failed   int
+		firstErr error
+	)
+
+	for i, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-mapCtx.Done():
+		}
+		if mapCtx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, item interface{}) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			out, err := e.runItem(mapCtx, st, parentStates, handlers, item)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				results[i] = out
+			case mapCtx.Err() != nil:
+				// Cancelled because the state already failed or the execution stopped.
+			case matchItemCatch(st.Catch, err):
+				results[i] = errorOutput(err)
+			default:
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+				if failed > tolerated {
+					cancel()
+				}
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if failed > tolerated {
+		return nil, errors.New(workflow.ErrorToleratedFailureExceeded,
+			fmt.Sprintf("map state %q: %d of %d items failed", st.Name, failed, len(items)), firstErr)
+	}
+	return results, nil
+}
+
+// runItem runs the item processor, applying the Map state's Retry policies.
+func (e *Engine) runItem(
+	ctx context.Context,
+	st workflow.State,
+	parentStates map[string]workflow.State,
+	handlers map[string]workflow.TaskHandler,
+	item interface{},
+) (interface{}, error) {
+	attempts := make([]int, len(st.Retry))
+	for {
+		out, err := e.runBranch(ctx, *st.ItemProcessor, parentStates, handlers, item)
+		if err == nil || ctx.Err() != nil {
+			return out, err
+		}
+		delay, retry := workflow.NextRetry(st.Retry, attempts, workflow.ErrorName(err))
+		if !retry {
+			return nil, err
+		}
+		if delay > 0 {
+			t := time.NewTimer(delay)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
+
+// matchItemCatch reports whether a per-item Catch policy (one without Next)
+// matches err.
+func matchItemCatch(policies []workflow.CatchPolicy, err error) bool {
+	for _, c := range policies {
+		if c.Next == "" && workflow.MatchErrorEquals(c.ErrorEquals, err) {
+			return true
+		}
+	}
+	return false
+}
+
+// catchState applies the first Catch policy with a Next that matches a
+// failed Map state's error, as ASL does for any state. It returns the state
+// to go to and its input: the error output placed at ResultPath ("$" or
+// empty replaces the input, "$.key" sets a top-level key).
+func catchState(st workflow.State, input interface{}, err error) (string, interface{}, bool) {
+	for _, c := range st.Catch {
+		if c.Next == "" || !workflow.MatchErrorEquals(c.ErrorEquals, err) {
+			continue
+		}
+		key := strings.TrimPrefix(strings.TrimPrefix(c.ResultPath, "$"), ".")
+		if key == "" {
+			return c.Next, errorOutput(err), true
+		}
+		out := map[string]interface{}{}
+		if m, ok := input.(map[string]interface{}); ok {
+			for k, v := range m {
+				out[k] = v
+			}
+		}
+		out[key] = errorOutput(err)
+		return c.Next, out, true
+	}
+	return "", nil, false
+}
+
+// errorOutput is the ASL error object a Catch passes on.
+func errorOutput(err error) map[string]interface{} {
+	return map[string]interface{}{"Error": workflow.ErrorName(err), "Cause": err.Error()}
+}
+
+// selectItems resolves ItemsPath ("$", "$.key" or "$.a.b") against input.
+func selectItems(path string, input interface{}) ([]interface{}, error) {
+	val := input
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			m, ok := val.(map[string]interface{})
+			if !ok {
+				return nil, errors.InvalidArgument(fmt.Sprintf("ItemsPath %q: %q is not an object", path, key), nil)
+			}
+			val = m[key]
+		}
+	}
+	switch v := val.(type) {
+	case []interface{}:
+		return v, nil
+	case []map[string]interface{}:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = v[i]
+		}
+		return out, nil
+	case []string:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = v[i]
+		}
+		return out, nil
+	case nil:
+		return nil, errors.InvalidArgument(fmt.Sprintf("ItemsPath %q selects no value", path), nil)
+	default:
+		return nil, errors.InvalidArgument(fmt.Sprintf("ItemsPath %q does not select an array", path), nil)
+	}
+}
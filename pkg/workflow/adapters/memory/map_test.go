@@ -0,0 +1,216 @@
+package memory_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/adapters/memory"
+)
+
+func runMapWorkflow(t *testing.T, eng *memory.Engine, mapState workflow.State, input interface{}) *workflow.Execution {
+	t.Helper()
+	ctx := context.Background()
+	mapState.Name = "fanout"
+	mapState.Type = "Map"
+	mapState.End = true
+	id := fmt.Sprintf("map-wf-%d", time.Now().UnixNano())
+	if err := eng.RegisterWorkflow(ctx, workflow.WorkflowDefinition{
+		ID:      id,
+		StartAt: "fanout",
+		States:  []workflow.State{mapState},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	exec, err := eng.Start(ctx, workflow.StartOptions{WorkflowID: id, Input: input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	done, err := eng.Wait(waitCtx, exec.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return done
+}
+
+func itemProcessor(resource string) *workflow.Branch {
+	return &workflow.Branch{
+		StartAt: "process",
+		States:  []workflow.State{{Name: "process", Type: "Task", Resource: resource, End: true}},
+	}
+}
+
+func TestMapCollectsResultsInOrderWithConcurrencyCap(t *testing.T) {
+	eng := memory.New().(*memory.Engine)
+
+	var inflight, peak atomic.Int32
+	eng.RegisterTaskHandler("double", func(ctx context.Context, input interface{}) (interface{}, error) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return input.(float64) * 2, nil
+	})
+
+	done := runMapWorkflow(t, eng, workflow.State{
+		ItemsPath:      "$.items",
+		ItemProcessor:  itemProcessor("double"),
+		MaxConcurrency: 2,
+	}, map[string]interface{}{"items": []interface{}{1.0, 2.0, 3.0, 4.0, 5.0}})
+
+	if done.Status != workflow.StatusCompleted {
+		t.Fatalf("status=%s err=%s", done.Status, done.Error)
+	}
+	out := done.Output.([]interface{})
+	for i, v := range out {
+		if v.(float64) != float64(i+1)*2 {
+			t.Fatalf("out[%d]=%v", i, v)
+		}
+	}
+	if peak.Load() > 2 {
+		t.Fatalf("peak concurrency %d exceeds cap", peak.Load())
+	}
+}
+
+func TestMapRetriesItemsIndependently(t *testing.T) {
+	eng := memory.New().(*memory.Engine)
+
+	var calls atomic.Int32
+	eng.RegisterTaskHandler("flaky", func(ctx context.Context, input interface{}) (interface{}, error) {
+		if input.(string) == "b" && calls.Add(1) < 3 {
+			return nil, errors.Unavailable("downstream busy", nil)
+		}
+		return input, nil
+	})
+
+	done := runMapWorkflow(t, eng, workflow.State{
+		ItemProcessor: itemProcessor("flaky"),
+		Retry:         []workflow.RetryPolicy{{ErrorEquals: []string{errors.CodeUnavailable}, MaxAttempts: 3}},
+	}, []interface{}{"a", "b", "c"})
+
+	if done.Status != workflow.StatusCompleted {
+		t.Fatalf("status=%s err=%s", done.Status, done.Error)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("flaky item attempts=%d, want 3", calls.Load())
+	}
+}
+
+func TestMapCatchAndToleratedFailures(t *testing.T) {
+	eng := memory.New().(*memory.Engine)
+	eng.RegisterTaskHandler("check", func(ctx context.Context, input interface{}) (interface{}, error) {
+		switch input.(string) {
+		case "bad":
+			return nil, errors.InvalidArgument("bad item", nil)
+		case "broken":
+			return nil, errors.Internal("broken item", nil)
+		}
+		return input, nil
+	})
+	items := []interface{}{"ok", "bad", "broken", "ok"}
+
+	// "bad" is caught; "broken" is 1 of 4 = 25% tolerated.
+	done := runMapWorkflow(t, eng, workflow.State{
+		ItemProcessor:              itemProcessor("check"),
+		Catch:                      []workflow.CatchPolicy{{ErrorEquals: []string{errors.CodeInvalidArgument}}},
+		ToleratedFailurePercentage: 25,
+	}, items)
+	if done.Status != workflow.StatusCompleted {
+		t.Fatalf("status=%s err=%s", done.Status, done.Error)
+	}
+	out := done.Output.([]interface{})
+	caught, ok := out[1].(map[string]interface{})
+	if !ok || caught["Error"] != errors.CodeInvalidArgument {
+		t.Fatalf("caught item=%v", out[1])
+	}
+	if out[2] != nil {
+		t.Fatalf("failed item should be nil, got %v", out[2])
+	}
+
+	// Without tolerance the same input fails the state.
+	done = runMapWorkflow(t, eng, workflow.State{
+		ItemProcessor: itemProcessor("check"),
+		Catch:         []workflow.CatchPolicy{{ErrorEquals: []string{errors.CodeInvalidArgument}}},
+	}, items)
+	if done.Status != workflow.StatusFailed {
+		t.Fatalf("status=%s, want failed", done.Status)
+	}
+}
+
+func TestMapCatchWithNextRoutesStateFailure(t *testing.T) {
+	eng := memory.New().(*memory.Engine)
+	eng.RegisterTaskHandler("check", func(ctx context.Context, input interface{}) (interface{}, error) {
+		if input.(string) == "broken" {
+			return nil, errors.Internal("broken item", nil)
+		}
+		return input, nil
+	})
+	ctx := context.Background()
+	if err := eng.RegisterWorkflow(ctx, workflow.WorkflowDefinition{
+		ID:      "map-catch-next",
+		StartAt: "fanout",
+		States: []workflow.State{
+			{
+				Name:          "fanout",
+				Type:          "Map",
+				ItemsPath:     "$.items",
+				ItemProcessor: itemProcessor("check"),
+				Catch: []workflow.CatchPolicy{{
+					ErrorEquals: []string{workflow.ErrorToleratedFailureExceeded},
+					Next:        "recover",
+					ResultPath:  "$.error",
+				}},
+				End: true,
+			},
+			{Name: "recover", Type: "Pass", End: true},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	exec, err := eng.Start(ctx, workflow.StartOptions{
+		WorkflowID: "map-catch-next",
+		Input:      map[string]interface{}{"items": []interface{}{"ok", "broken"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	done, err := eng.Wait(waitCtx, exec.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done.Status != workflow.StatusCompleted {
+		t.Fatalf("status=%s err=%s", done.Status, done.Error)
+	}
+	out := done.Output.(map[string]interface{})
+	caught, ok := out["error"].(map[string]interface{})
+	if !ok || caught["Error"] != workflow.ErrorToleratedFailureExceeded {
+		t.Fatalf("output=%v", out)
+	}
+	if _, ok := out["items"]; !ok {
+		t.Fatalf("ResultPath should keep the input, got %v", out)
+	}
+}
+
+func TestMapRejectsNonArrayItems(t *testing.T) {
+	eng := memory.New().(*memory.Engine)
+	done := runMapWorkflow(t, eng, workflow.State{
+		ItemsPath:     "$.items",
+		ItemProcessor: itemProcessor("noop"),
+	}, map[string]interface{}{"items": "nope"})
+	if done.Status != workflow.StatusFailed {
+		t.Fatalf("status=%s, want failed", done.Status)
+	}
+}
@@ -36,6 +36,8 @@ type Engine struct {
 	waiters    map[string][]chan *workflow.Execution
 	handlers   map[string]workflow.TaskHandler // resource -> handler
 	idempo     map[string]string               // idempotencyKey -> executionID
+	logs       map[string][]workflow.HistoryEvent
+	history    workflow.HistoryStore // optional durable event log
 	config     workflow.Config
 	// workDuration is used when a workflow has no StartAt/states (legacy pass-through).
 	workDuration time.Duration
@@ -54,6 +56,18 @@ func NewWithConfig(cfg workflow.Config) workflow.WorkflowEngine {
 	return newEngine(cfg)
 }
 
+// NewWithHistory creates an engine that records every execution's event log
+// to store. Call ResumeAll after registering workflows and task handlers to
+// continue executions that were running when the previous process stopped.
+func NewWithHistory(cfg workflow.Config, store workflow.HistoryStore) *Engine {
+	if cfg.DefaultTimeout <= 0 {
+		cfg.DefaultTimeout = time.Hour
+	}
+	e := newEngine(cfg)
+	e.history = store
+	return e
+}
+
 func newEngine(cfg workflow.Config) *Engine {
 	return &Engine{
 		mu:           concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "workflow-memory"}),
@@ -64,6 +78,7 @@ func newEngine(cfg workflow.Config) *Engine {
 		waiters:      make(map[string][]chan *workflow.Execution),
 		handlers:     make(map[string]workflow.TaskHandler),
 		idempo:       make(map[string]string),
+		logs:         make(map[string][]workflow.HistoryEvent),
 		config:       cfg,
 		workDuration: 100 * time.Millisecond,
 	}
@@ -128,17 +143,19 @@ func (e *Engine) GetWorkflow(ctx context.Context, workflowID string) (*workflow.
 
 func (e *Engine) Start(ctx context.Context, opts workflow.StartOptions) (*workflow.Execution, error) {
 	e.mu.Lock()
-	defer e.mu.Unlock()
 
 	wf, ok := e.workflows[opts.WorkflowID]
 	if !ok {
+		e.mu.Unlock()
 		return nil, workflow.ErrWorkflowNotFound
 	}
 
 	if opts.IdempotencyKey != "" {
 		if existingID, hit := e.idempo[opts.IdempotencyKey]; hit {
 			if exec, exists := e.executions[existingID]; exists {
-				return copyExecution(exec), nil
+				cp := copyExecution(exec)
+				e.mu.Unlock()
+				return cp, nil
 			}
 		}
 	}
@@ -149,6 +166,7 @@ func (e *Engine) Start(ctx context.Context, opts workflow.StartOptions) (*workfl
 	}
 
 	if _, exists := e.executions[execID]; exists {
+		e.mu.Unlock()
 		return nil, workflow.ErrExecutionAlreadyExists
 	}
 
@@ -179,17 +197,41 @@ func (e *Engine) Start(ctx context.Context, opts workflow.StartOptions) (*workfl
 		}
 	}
 
+	started := e.appendLocked(workflow.HistoryEvent{
+		ExecutionID:    execID,
+		Type:           workflow.HistoryExecutionStarted,
+		WorkflowID:     opts.WorkflowID,
+		Input:          opts.Input,
+		Timeout:        timeout,
+		IdempotencyKey: opts.IdempotencyKey,
+	})
+
 	// Snapshot definition + handlers for the runner goroutine.
 	defCopy := *wf
+	handlers := e.handlersLocked()
+	work := e.workDuration
+	result := copyExecution(exec)
+	e.mu.Unlock()
+
+	if e.history != nil {
+		if err := e.history.Append(ctx, started); err != nil {
+			e.forget(execID, opts.IdempotencyKey)
+			return nil, errors.Unavailable("failed to record workflow execution start", err)
+		}
+	}
+
+	go e.runExecution(ctx, exec, &defCopy, handlers, timeout, work, defCopy.StartAt, opts.Input)
+
+	return result, nil
+}
+
+// handlersLocked snapshots the task handlers. Caller holds e.mu.
+func (e *Engine) handlersLocked() map[string]workflow.TaskHandler {
 	handlers := make(map[string]workflow.TaskHandler, len(e.handlers))
 	for k, v := range e.handlers {
 		handlers[k] = v
 	}
-	work := e.workDuration
-
-	go e.runExecution(ctx, exec, &defCopy, handlers, timeout, work)
-
-	return copyExecution(exec), nil
+	return handlers
 }
 
 func (e *Engine) runExecution(
@@ -199,6 +241,8 @@ func (e *Engine) runExecution(
 	handlers map[string]workflow.TaskHandler,
 	timeout time.Duration,
 	workDuration time.Duration,
+	current string,
+	data interface{},
 ) {
 	ctx := parent
 	var cancel context.CancelFunc
@@ -218,9 +262,6 @@ func (e *Engine) runExecution(
 		states[s.Name] = s
 	}
 
-	data := exec.Input
-	current := def.StartAt
-
 	for {
 		if ctx.Err() != nil {
 			e.finish(exec, terminalFromCtx(ctx), nil, ctx.Err())
@@ -233,7 +274,14 @@ func (e *Engine) runExecution(
 			return
 		}
 		exec.CurrentState = current
+		entered := e.appendLocked(workflow.HistoryEvent{
+			ExecutionID: exec.ID,
+			Type:        workflow.HistoryStateEntered,
+			State:       current,
+			Input:       data,
+		})
 		e.mu.Unlock()
+		e.persist(entered)
 
 		st, ok := states[current]
 		if !ok {
@@ -243,6 +291,7 @@ func (e *Engine) runExecution(
 
 		var err error
 		var choiceNext string
+		input := data
 		switch strings.ToLower(st.Type) {
 		case "task", "":
 			data, err = e.execTask(ctx, st, handlers, data)
@@ -252,38 +301,55 @@ func (e *Engine) runExecution(
 			choiceNext, err = e.execChoice(st, data)
 		case "parallel":
 			data, err = e.execParallel(ctx, st, states, handlers, data)
+		case "map":
+			data, err = e.execMap(ctx, st, states, handlers, data)
 		case "succeed", "pass":
 			// no-op; pass data through
 		case "fail":
-			e.finish(exec, workflow.StatusFailed, data, errors.Internal(fmt.Sprintf("state %q failed", st.Name), nil))
+			err = errors.Internal(fmt.Sprintf("state %q failed", st.Name), nil)
+			e.recordStateFailed(exec, current, err)
+			e.finish(exec, workflow.StatusFailed, data, err)
 			return
 		default:
-			e.finish(exec, workflow.StatusFailed, data, errors.InvalidArgument(fmt.Sprintf("unsupported state type %q", st.Type), nil))
+			err = errors.InvalidArgument(fmt.Sprintf("unsupported state type %q", st.Type), nil)
+			e.recordStateFailed(exec, current, err)
+			e.finish(exec, workflow.StatusFailed, data, err)
 			return
 		}
 		if err != nil {
+			e.recordStateFailed(exec, current, err)
 			if ctx.Err() != nil {
 				e.finish(exec, terminalFromCtx(ctx), data, ctx.Err())
 				return
 			}
+			if strings.EqualFold(st.Type, "map") {
+				if next, out, ok := catchState(st, input, err); ok {
+					data, current = out, next
+					continue
+				}
+			}
 			e.finish(exec, workflow.StatusFailed, data, err)
 			return
 		}
 
+		next := st.Next
 		if strings.EqualFold(st.Type, "choice") {
-			if choiceNext == "" {
-				e.finish(exec, workflow.StatusCompleted, data, nil)
-				return
-			}
-			current = choiceNext
-			continue
+			next = choiceNext
+		} else if st.End {
+			next = ""
 		}
-
-		if st.End || st.Next == "" {
+		e.record(workflow.HistoryEvent{
+			ExecutionID: exec.ID,
+			Type:        workflow.HistoryStateSucceeded,
+			State:       current,
+			Next:        next,
+			Output:      data,
+		})
+		if next == "" {
 			e.finish(exec, workflow.StatusCompleted, data, nil)
 			return
 		}
-		current = st.Next
+		current = next
 	}
 }
 
@@ -438,6 +504,7 @@ func (e *Engine) runBranch(
 		}
 		var err error
 		var choiceNext string
+		input := data
 		switch strings.ToLower(st.Type) {
 		case "task", "":
 			data, err = e.execTask(ctx, st, handlers, data)
@@ -450,11 +517,18 @@ func (e *Engine) runBranch(
 			return nil, errors.Internal(fmt.Sprintf("branch state %q failed", st.Name), nil)
 		case "parallel":
 			data, err = e.execParallel(ctx, st, states, handlers, data)
+		case "map":
+			data, err = e.execMap(ctx, st, states, handlers, data)
 		default:
 			return nil, errors.InvalidArgument(fmt.Sprintf("unsupported branch state type %q", st.Type), nil)
 		}
 		if err != nil {
-			return nil, err
+			next, out, ok := catchState(st, input, err)
+			if !ok || !strings.EqualFold(st.Type, "map") || ctx.Err() != nil {
+				return nil, err
+			}
+			data, current = out, next
+			continue
 		}
 		if strings.EqualFold(st.Type, "choice") {
 			if choiceNext == "" {
@@ -491,8 +565,8 @@ func terminalFromCtx(ctx context.Context) workflow.ExecutionStatus {
 
 func (e *Engine) finish(exec *workflow.Execution, status workflow.ExecutionStatus, output interface{}, err error) {
 	e.mu.Lock()
-	defer e.mu.Unlock()
 	if exec.Status != workflow.StatusRunning && exec.Status != workflow.StatusPending {
+		e.mu.Unlock()
 		return
 	}
 	exec.Status = status
@@ -511,19 +585,38 @@ func (e *Engine) finish(exec *workflow.Execution, status workflow.ExecutionStatu
 			exec.Error = err.Error()
 		}
 	}
+	finished := e.finishedEventLocked(exec)
 	e.notifyWaitersLocked(exec)
+	e.mu.Unlock()
+	e.persist(finished)
 }
 
+// GetExecution returns the execution with its step-by-step History. With a
+// HistoryStore configured, executions unknown to this process (e.g. finished
+// before a restart) are rebuilt from the stored event log.
 func (e *Engine) GetExecution(ctx context.Context, executionID string) (*workflow.Execution, error) {
 	e.mu.RLock()
-	defer e.mu.RUnlock()
-
 	exec, ok := e.executions[executionID]
-	if !ok {
-		return nil, workflow.ErrExecutionNotFound
+	if ok {
+		cp := copyExecution(exec)
+		cp.History = append([]workflow.HistoryEvent(nil), e.logs[executionID]...)
+		e.mu.RUnlock()
+		return cp, nil
 	}
+	e.mu.RUnlock()
 
-	return copyExecution(exec), nil
+	if e.history == nil {
+		return nil, workflow.ErrExecutionNotFound
+	}
+	events, err := e.history.Load(ctx, executionID)
+	if err != nil {
+		if errors.IsCode(err, errors.CodeNotFound) {
+			return nil, workflow.ErrExecutionNotFound
+		}
+		return nil, err
+	}
+	rebuilt, _ := replay(events)
+	return rebuilt, nil
 }
 
 func (e *Engine) ListExecutions(ctx context.Context, opts workflow.ListOptions) (*workflow.ListResult, error) {
@@ -554,38 +647,56 @@ func (e *Engine) ListExecutions(ctx context.Context, opts workflow.ListOptions)
 
 func (e *Engine) Cancel(ctx context.Context, executionID string) error {
 	e.mu.Lock()
-	defer e.mu.Unlock()
 
 	exec, ok := e.executions[executionID]
 	if !ok {
+		e.mu.Unlock()
 		return workflow.ErrExecutionNotFound
 	}
 
 	if exec.Status != workflow.StatusRunning {
+		e.mu.Unlock()
 		return workflow.ErrExecutionNotRunning
 	}
 
 	exec.Status = workflow.StatusCancelled
 	exec.CompletedAt = time.Now()
+	finished := e.finishedEventLocked(exec)
 	e.notifyWaitersLocked(exec)
+	e.mu.Unlock()
 
+	e.persist(finished)
 	return nil
 }
 
 func (e *Engine) Signal(ctx context.Context, executionID string, signalName string, data interface{}) error {
 	e.mu.Lock()
-	defer e.mu.Unlock()
 
 	exec, ok := e.executions[executionID]
 	if !ok {
+		e.mu.Unlock()
 		return workflow.ErrExecutionNotFound
 	}
 
 	if exec.Status != workflow.StatusRunning {
+		e.mu.Unlock()
 		return workflow.ErrExecutionNotRunning
 	}
 
 	e.signals[executionID][signalName] = data
+	received := e.appendLocked(workflow.HistoryEvent{
+		ExecutionID: executionID,
+		Type:        workflow.HistorySignalReceived,
+		SignalName:  signalName,
+		Input:       data,
+	})
+	e.mu.Unlock()
+
+	if e.history != nil {
+		if err := e.history.Append(ctx, received); err != nil {
+			return errors.Unavailable("failed to record workflow signal", err)
+		}
+	}
 	return nil
 }
 
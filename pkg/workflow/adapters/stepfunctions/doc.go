@@ -2,11 +2,16 @@
 //
 // Shipped:
 //   - CreateStateMachine (RegisterWorkflow) using Config.RoleArn
+//   - ASL conversion of Retry/Catch and Map states (inline ItemProcessor, ItemsPath,
+//     MaxConcurrency, ToleratedFailurePercentage). A Map state's Retry, and its
+//     Catch policies without Next, are emitted on the ItemProcessor's states so
+//     they apply per item as in the memory engine; a Catch with Next stays on
+//     the Map state
 //   - Start / Describe / List / Stop executions
 //   - Signal via waitForTaskToken callback stub (SendTaskSuccess / SendTaskFailure)
 //
 // Remaining gaps (honest):
-//   - Full ASL conversion from workflow.State (Choice/Parallel/Map) is minimal
+//   - Full ASL conversion from workflow.State (Choice/Parallel/Wait) is minimal
 //   - Activity worker GetActivityTask loop is not hosted here
 //   - Signal does not target an execution ARN; callers must supply the task token
 package stepfunctions
@@ -16,6 +16,8 @@ package stepfunctions
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -100,9 +102,13 @@ func (e *Engine) RegisterWorkflow(ctx context.Context, def workflow.WorkflowDefi
 	if e.config.RoleArn == "" {
 		return pkgerrors.InvalidArgument("stepfunctions RoleArn is required to create a state machine", nil)
 	}
+	states, err := convertStates(def.States)
+	if err != nil {
+		return err
+	}
 	definition, err := json.Marshal(map[string]interface{}{
 		"StartAt": def.StartAt,
-		"States":  convertStates(def.States),
+		"States":  states,
 	})
 	if err != nil {
 		return pkgerrors.Internal("failed to marshal definition", err)
@@ -121,25 +127,139 @@ func (e *Engine) RegisterWorkflow(ctx context.Context, def workflow.WorkflowDefi
 	return nil
 }
 
-func convertStates(states []workflow.State) map[string]interface{} {
-	result := make(map[string]interface{})
+func convertStates(states []workflow.State) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(states))
 	for i := range states {
-		s := &states[i]
-		state := map[string]interface{}{
-			"Type": s.Type,
+		state, err := convertState(&states[i])
+		if err != nil {
+			return nil, err
 		}
-		if s.Resource != "" {
-			state["Resource"] = s.Resource
+		result[states[i].Name] = state
+	}
+	return result, nil
+}
+
+func convertState(s *workflow.State) (map[string]interface{}, error) {
+	state := map[string]interface{}{
+		"Type": s.Type,
+	}
+	if s.Resource != "" {
+		state["Resource"] = s.Resource
+	}
+	if s.Next != "" {
+		state["Next"] = s.Next
+	}
+	if s.End {
+		state["End"] = true
+	}
+	isMap := strings.EqualFold(s.Type, "map")
+	retries, catches := s.Retry, s.Catch
+	if isMap {
+		// Per-item policies move into the ItemProcessor; see itemStates.
+		retries, catches = nil, stateCatches(s.Catch)
+	}
+	if len(retries) > 0 {
+		retry := make([]map[string]interface{}, len(retries))
+		for i, r := range retries {
+			rule := map[string]interface{}{"ErrorEquals": r.ErrorEquals}
+			if r.IntervalSeconds > 0 {
+				rule["IntervalSeconds"] = r.IntervalSeconds
+			}
+			if r.MaxAttempts > 0 {
+				rule["MaxAttempts"] = r.MaxAttempts
+			}
+			if r.BackoffRate > 0 {
+				rule["BackoffRate"] = r.BackoffRate
+			}
+			retry[i] = rule
+		}
+		state["Retry"] = retry
+	}
+	if len(catches) > 0 {
+		catch := make([]map[string]interface{}, len(catches))
+		for i, c := range catches {
+			rule := map[string]interface{}{"ErrorEquals": c.ErrorEquals, "Next": c.Next}
+			if c.ResultPath != "" {
+				rule["ResultPath"] = c.ResultPath
+			}
+			catch[i] = rule
 		}
-		if s.Next != "" {
-			state["Next"] = s.Next
+		state["Catch"] = catch
+	}
+	if isMap {
+		if s.ItemProcessor == nil || len(s.ItemProcessor.States) == 0 {
+			return nil, pkgerrors.InvalidArgument(
+				fmt.Sprintf("map state %q needs an ItemProcessor with its own States for Step Functions", s.Name), nil)
 		}
-		if s.End {
-			state["End"] = true
+		states, err := convertStates(itemStates(s))
+		if err != nil {
+			return nil, err
+		}
+		state["Type"] = "Map"
+		state["ItemProcessor"] = map[string]interface{}{
+			"ProcessorConfig": map[string]interface{}{"Mode": "INLINE"},
+			"StartAt":         s.ItemProcessor.StartAt,
+			"States":          states,
+		}
+		if s.ItemsPath != "" {
+			state["ItemsPath"] = s.ItemsPath
+		}
+		if s.MaxConcurrency > 0 {
+			state["MaxConcurrency"] = s.MaxConcurrency
+		}
+		if s.ToleratedFailurePercentage > 0 {
+			state["ToleratedFailurePercentage"] = s.ToleratedFailurePercentage
+		}
+	}
+	return state, nil
+}
+
+// itemCaughtSuffix names the Pass state a Map item's Catch leads to.
+const itemCaughtSuffix = "-caught"
+
+// itemStates returns the ItemProcessor states of Map state s with the Map's
+// Retry policies, and its Catch policies without Next, added to every Task,
+// Parallel and Map state, so they apply per item as in the other engines.
+// Caught items go to a Pass state whose output is the {"Error", "Cause"}
+// object.
+func itemStates(s *workflow.State) []workflow.State {
+	states := make([]workflow.State, len(s.ItemProcessor.States), len(s.ItemProcessor.States)+1)
+	copy(states, s.ItemProcessor.States)
+	var itemCatches []workflow.CatchPolicy
+	caught := s.Name + itemCaughtSuffix
+	for _, c := range s.Catch {
+		if c.Next == "" {
+			itemCatches = append(itemCatches, workflow.CatchPolicy{ErrorEquals: c.ErrorEquals, Next: caught})
+		}
+	}
+	if len(s.Retry) == 0 && len(itemCatches) == 0 {
+		return states
+	}
+	for i := range states {
+		switch strings.ToLower(states[i].Type) {
+		case "task", "", "parallel", "map":
+		default:
+			continue
+		}
+		states[i].Retry = append(append([]workflow.RetryPolicy(nil), states[i].Retry...), s.Retry...)
+		states[i].Catch = append(append([]workflow.CatchPolicy(nil), states[i].Catch...), itemCatches...)
+	}
+	if len(itemCatches) > 0 {
+		states = append(states, workflow.State{Name: caught, Type: "Pass", End: true})
+	}
+	return states
+}
+
+// stateCatches returns the Catch policies of a Map state that apply to the
+// state as a whole: those with a Next.
+func stateCatches(policies []workflow.CatchPolicy) []workflow.CatchPolicy {
+	var out []workflow.CatchPolicy
+	for _, c := range policies {
+		if c.Next != "" {
+			out = append(out, c)
 		}
-		result[s.Name] = state
 	}
-	return result
+	return out
 }
 
 func (e *Engine) GetWorkflow(ctx context.Context, workflowID string) (*workflow.WorkflowDefinition, error) {
@@ -2,6 +2,7 @@ package stepfunctions_test
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 
 type fakeSFN struct {
 	roleArn       string
+	definition    string
 	successToken  string
 	successOutput string
 	failToken     string
@@ -21,6 +23,7 @@ type fakeSFN struct {
 
 func (f *fakeSFN) CreateStateMachine(ctx context.Context, params *sfn.CreateStateMachineInput, _ ...func(*sfn.Options)) (*sfn.CreateStateMachineOutput, error) {
 	f.roleArn = aws.ToString(params.RoleArn)
+	f.definition = aws.ToString(params.Definition)
 	return &sfn.CreateStateMachineOutput{StateMachineArn: aws.String("arn:sm")}, nil
 }
 func (f *fakeSFN) DescribeStateMachine(ctx context.Context, params *sfn.DescribeStateMachineInput, _ ...func(*sfn.Options)) (*sfn.DescribeStateMachineOutput, error) {
@@ -99,3 +102,68 @@ func TestSignalCallbackToken(t *testing.T) {
 		t.Fatalf("fail token=%q", api.failToken)
 	}
 }
+
+func TestRegisterMapState(t *testing.T) {
+	api := &fakeSFN{}
+	eng := stepfunctions.NewFromAPI(api, stepfunctions.Config{RoleArn: "arn:aws:iam::1:role/sfn"})
+	err := eng.RegisterWorkflow(context.Background(), workflow.WorkflowDefinition{
+		Name:    "fanout",
+		StartAt: "Notify",
+		States: []workflow.State{{
+			Name:                       "Notify",
+			Type:                       "Map",
+			ItemsPath:                  "$.users",
+			MaxConcurrency:             5,
+			ToleratedFailurePercentage: 10,
+			Retry:                      []workflow.RetryPolicy{{ErrorEquals: []string{workflow.ErrorAll}, MaxAttempts: 2}},
+			ItemProcessor: &workflow.Branch{
+				StartAt: "Send",
+				States:  []workflow.State{{Name: "Send", Type: "Task", Resource: "arn:aws:lambda:send", End: true}},
+			},
+			End: true,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var def struct {
+		States map[string]struct {
+			Type                       string
+			ItemsPath                  string
+			MaxConcurrency             int
+			ToleratedFailurePercentage float64
+			Retry                      []map[string]interface{}
+			ItemProcessor              struct {
+				StartAt string
+				States  map[string]map[string]interface{}
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(api.definition), &def); err != nil {
+		t.Fatal(err)
+	}
+	m := def.States["Notify"]
+	if m.Type != "Map" || m.ItemsPath != "$.users" || m.MaxConcurrency != 5 || m.ToleratedFailurePercentage != 10 {
+		t.Fatalf("map state=%+v", m)
+	}
+	send := m.ItemProcessor.States["Send"]
+	if len(m.Retry) != 0 || m.ItemProcessor.StartAt != "Send" || send["Resource"] != "arn:aws:lambda:send" {
+		t.Fatalf("map state=%+v", m)
+	}
+	if retry, _ := send["Retry"].([]interface{}); len(retry) != 1 {
+		t.Fatalf("the Map's Retry should apply per item, got %v", send["Retry"])
+	}
+}
+
+func TestRegisterMapStateRequiresItemProcessorStates(t *testing.T) {
+	eng := stepfunctions.NewFromAPI(&fakeSFN{}, stepfunctions.Config{RoleArn: "arn:aws:iam::1:role/sfn"})
+	err := eng.RegisterWorkflow(context.Background(), workflow.WorkflowDefinition{
+		Name:    "fanout",
+		StartAt: "Notify",
+		States:  []workflow.State{{Name: "Notify", Type: "Map", ItemProcessor: &workflow.Branch{StartAt: "Send"}, End: true}},
+	})
+	if err == nil {
+		t.Fatal("expected error for Map without ItemProcessor states")
+	}
+}
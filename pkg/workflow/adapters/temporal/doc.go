@@ -6,6 +6,9 @@
 //   - ListExecutions via visibility ListWorkflow with WorkflowId/ExecutionStatus query
 //   - Close() releases the dialed client (NewFromClient can opt out)
 //   - NewWorker / NewWorkerFromEngine: thin worker hosting that registers workflow funcs
+//   - ExecuteMap: runs a workflow.State Map (single-Task ItemProcessor) from a workflow
+//     function as concurrency-capped activities with tolerated failures, applying
+//     every Retry policy by error name with durable timers
 //
 // Remaining gaps (honest):
 //   - Advanced visibility (custom SearchAttributes, CountWorkflow) not exposed
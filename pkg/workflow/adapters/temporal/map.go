@@ -0,0 +1,178 @@
+package temporal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	pkgerrors "github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	pkgworkflow "github.com/chris-alexander-pop/go-hyperforge/pkg/workflow"
+	"go.temporal.io/sdk/temporal"
+	sdkworkflow "go.temporal.io/sdk/workflow"
+)
+
+// defaultMapActivityTimeout is the StartToCloseTimeout when neither the item
+// task nor the Map state sets TimeoutSeconds.
+const defaultMapActivityTimeout = time.Hour
+
+// ExecuteMap runs a Map state inside a Temporal workflow function. Temporal
+// workflows are Go code rather than ASL, so this is the building block for
+// mapping a workflow.State of type Map onto Temporal:
+//
+//	func Notify(ctx workflow.Context, users []interface{}) ([]interface{}, error) {
+//	    return temporal.ExecuteMap(ctx, notifyState, users)
+//	}
+//
+// The ItemProcessor must be a single Task state; its Resource is the activity
+// name run once per item, at most MaxConcurrency at a time. Each activity is
+// attempted once and the workflow retries it after a durable timer per the
+// first Retry policy matching the error name, like the memory engine; a
+// non-retryable ApplicationError is not retried. Items failing with an
+// ApplicationError whose type matches a Catch policy without Next yield
+// {"Error", "Cause"}; other failures count against ToleratedFailurePercentage
+// and leave a nil slot. Catch policies with Next are left to the calling
+// workflow, which receives the error.
+func ExecuteMap(ctx sdkworkflow.Context, st pkgworkflow.State, items []interface{}) ([]interface{}, error) {
+	task, err := mapTask(st)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]interface{}, len(items))
+	if len(items) == 0 {
+		return results, nil
+	}
+
+	timeout := defaultMapActivityTimeout
+	if task.TimeoutSeconds > 0 {
+		timeout = time.Duration(task.TimeoutSeconds) * time.Second
+	} else if st.TimeoutSeconds > 0 {
+		timeout = time.Duration(st.TimeoutSeconds) * time.Second
+	}
+	actCtx := sdkworkflow.WithActivityOptions(ctx, sdkworkflow.ActivityOptions{
+		StartToCloseTimeout: timeout,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 1},
+	})
+
+	limit := st.MaxConcurrency
+	if limit <= 0 || limit > len(items) {
+		limit = len(items)
+	}
+	tolerated := int(math.Floor(float64(len(items)) * st.ToleratedFailurePercentage / 100))
+
+	var (
+		failed   int
+		firstErr error
+		next     int
+		inflight int
+	)
+	selector := sdkworkflow.NewSelector(ctx)
+	var attempt func(i int, retries []int)
+	attempt = func(i int, retries []int) {
+		f := sdkworkflow.ExecuteActivity(actCtx, task.Resource, items[i])
+		selector.AddFuture(f, func(f sdkworkflow.Future) {
+			var out interface{}
+			err := f.Get(ctx, &out)
+			if err != nil && retryable(err) {
+				if delay, ok := pkgworkflow.NextRetry(st.Retry, retries, temporalErrorName(err)); ok {
+					if delay <= 0 {
+						attempt(i, retries)
+						return
+					}
+					selector.AddFuture(sdkworkflow.NewTimer(ctx, delay), func(sdkworkflow.Future) { attempt(i, retries) })
+					return
+				}
+			}
+			inflight--
+			switch {
+			case err == nil:
+				results[i] = out
+			case matchTemporalCatch(st.Catch, err):
+				results[i] = map[string]interface{}{"Error": temporalErrorName(err), "Cause": err.Error()}
+			default:
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		})
+	}
+	schedule := func() {
+		i := next
+		next++
+		inflight++
+		attempt(i, make([]int, len(st.Retry)))
+	}
+
+	for next < limit {
+		schedule()
+	}
+	for inflight > 0 {
+		selector.Select(ctx)
+		if failed > tolerated {
+			break
+		}
+		// A retry keeps its slot, so only a finished item frees one.
+		for next < len(items) && inflight < limit {
+			schedule()
+		}
+	}
+
+	if failed > tolerated {
+		return nil, pkgerrors.New(pkgworkflow.ErrorToleratedFailureExceeded,
+			fmt.Sprintf("map state %q: more than %d of %d items failed", st.Name, tolerated, len(items)), firstErr)
+	}
+	return results, nil
+}
+
+func mapTask(st pkgworkflow.State) (pkgworkflow.State, error) {
+	if !strings.EqualFold(st.Type, "map") {
+		return pkgworkflow.State{}, pkgerrors.InvalidArgument(fmt.Sprintf("state %q is not a Map state", st.Name), nil)
+	}
+	p := st.ItemProcessor
+	if p == nil || len(p.States) != 1 {
+		return pkgworkflow.State{}, pkgerrors.InvalidArgument(
+			fmt.Sprintf("map state %q: temporal supports an ItemProcessor with exactly one Task state", st.Name), nil)
+	}
+	task := p.States[0]
+	if (task.Type != "" && !strings.EqualFold(task.Type, "task")) || task.Resource == "" {
+		return pkgworkflow.State{}, pkgerrors.InvalidArgument(
+			fmt.Sprintf("map state %q: item state %q must be a Task with a Resource", st.Name, task.Name), nil)
+	}
+	return task, nil
+}
+
+// retryable reports whether a failed item may be retried: everything but a
+// non-retryable ApplicationError.
+func retryable(err error) bool {
+	var appErr *temporal.ApplicationError
+	return !errors.As(err, &appErr) || !appErr.NonRetryable()
+}
+
+// temporalErrorName maps an activity error to an ASL error name: the
+// ApplicationError type (set it to a pkg/errors code), ErrorTimeout for
+// timeouts, and ErrorTaskFailed otherwise.
+func temporalErrorName(err error) string {
+	var timeoutErr *temporal.TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return pkgworkflow.ErrorTimeout
+	}
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() != "" {
+		return appErr.Type()
+	}
+	return pkgworkflow.ErrorTaskFailed
+}
+
+// matchTemporalCatch reports whether a per-item Catch policy (one without
+// Next) matches err.
+func matchTemporalCatch(policies []pkgworkflow.CatchPolicy, err error) bool {
+	name := temporalErrorName(err)
+	for _, c := range policies {
+		if c.Next == "" && pkgworkflow.MatchErrorName(c.ErrorEquals, name) {
+			return true
+		}
+	}
+	return false
+}
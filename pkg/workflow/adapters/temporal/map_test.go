@@ -0,0 +1,99 @@
+package temporal_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/adapters/temporal"
+	"go.temporal.io/sdk/activity"
+	sdktemporal "go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	sdkworkflow "go.temporal.io/sdk/workflow"
+)
+
+func mapState(tolerated float64) workflow.State {
+	return workflow.State{
+		Name:           "fanout",
+		Type:           "Map",
+		MaxConcurrency: 2,
+		ItemProcessor: &workflow.Branch{
+			StartAt: "check",
+			States:  []workflow.State{{Name: "check", Type: "Task", Resource: "check", End: true}},
+		},
+		Catch:                      []workflow.CatchPolicy{{ErrorEquals: []string{errors.CodeInvalidArgument}}},
+		ToleratedFailurePercentage: tolerated,
+	}
+}
+
+func checkActivity(ctx context.Context, in float64) (float64, error) {
+	switch in {
+	case -1:
+		return 0, sdktemporal.NewNonRetryableApplicationError("bad item", errors.CodeInvalidArgument, nil)
+	case -2:
+		return 0, sdktemporal.NewNonRetryableApplicationError("broken item", errors.CodeInternal, nil)
+	}
+	return in * 2, nil
+}
+
+func runMap(t *testing.T, tolerated float64, items []interface{}) ([]interface{}, error) {
+	t.Helper()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(checkActivity, activity.RegisterOptions{Name: "check"})
+	wf := func(ctx sdkworkflow.Context, items []interface{}) ([]interface{}, error) {
+		return temporal.ExecuteMap(ctx, mapState(tolerated), items)
+	}
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf, items)
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow did not complete")
+	}
+	if err := env.GetWorkflowError(); err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	if err := env.GetWorkflowResult(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out, nil
+}
+
+func TestExecuteMapCollectsResultsAndCatches(t *testing.T) {
+	out, err := runMap(t, 25, []interface{}{1.0, -1.0, -2.0, 4.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out[0] != 2.0 || out[3] != 8.0 {
+		t.Fatalf("out=%v", out)
+	}
+	caught, ok := out[1].(map[string]interface{})
+	if !ok || caught["Error"] != errors.CodeInvalidArgument {
+		t.Fatalf("caught=%v", out[1])
+	}
+	if out[2] != nil {
+		t.Fatalf("failed item=%v", out[2])
+	}
+}
+
+func TestExecuteMapFailsBeyondTolerance(t *testing.T) {
+	if _, err := runMap(t, 0, []interface{}{1.0, -2.0}); err == nil {
+		t.Fatal("expected tolerated-failure error")
+	}
+}
+
+func TestExecuteMapRejectsMultiStateProcessor(t *testing.T) {
+	st := mapState(0)
+	st.ItemProcessor.States = append(st.ItemProcessor.States, workflow.State{Name: "extra", Type: "Pass"})
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	wf := func(ctx sdkworkflow.Context) ([]interface{}, error) {
+		return temporal.ExecuteMap(ctx, st, []interface{}{1.0})
+	}
+	env.RegisterWorkflow(wf)
+	env.ExecuteWorkflow(wf)
+	if env.GetWorkflowError() == nil {
+		t.Fatal("expected invalid ItemProcessor error")
+	}
+}
@@ -13,6 +13,7 @@
 // Subpackages:
 //   - saga: compensating transactions (optional NewInstrumentedSaga)
 //   - scheduler: cron jobs with optional Store + distlock.Locker
+//   - history: HistoryStore adapters (file, sql) for replayable execution logs
 //
 // Usage:
 //
@@ -1,6 +1,13 @@
 package workflow
 
-import "github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+import (
+	"context"
+	stderrors "errors"
+	"math"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
 
 // Sentinel errors for workflow operations.
 var (
@@ -25,3 +32,74 @@ var (
 	// ErrExecutionTimeout is returned when an execution times out.
 	ErrExecutionTimeout = errors.Internal("execution timed out", nil)
 )
+
+// ErrorName returns the ASL error name used to match err against Retry and
+// Catch ErrorEquals: ErrorTimeout for deadline errors, the AppError code when
+// present, and ErrorTaskFailed otherwise.
+func ErrorName(err error) string {
+	if err == nil {
+		return ""
+	}
+	if stderrors.Is(err, context.DeadlineExceeded) || errors.IsCode(err, errors.CodeDeadlineExceeded) {
+		return ErrorTimeout
+	}
+	if code := errors.Code(err); code != "" {
+		return code
+	}
+	return ErrorTaskFailed
+}
+
+// MatchErrorEquals reports whether err matches any of the ASL error names.
+// ErrorAll matches everything and ErrorTaskFailed matches everything but timeouts.
+func MatchErrorEquals(names []string, err error) bool {
+	if err == nil {
+		return false
+	}
+	return MatchErrorName(names, ErrorName(err))
+}
+
+// MatchErrorName is MatchErrorEquals for an error already named, e.g. by an
+// adapter that maps its own error types to ASL names.
+func MatchErrorName(names []string, name string) bool {
+	for _, n := range names {
+		switch n {
+		case ErrorAll:
+			return true
+		case ErrorTaskFailed:
+			if name != ErrorTimeout {
+				return true
+			}
+		case name:
+			return true
+		}
+	}
+	return false
+}
+
+// NextRetry picks the first policy whose ErrorEquals matches the error name
+// and reports the delay before the next attempt, or false when no policy
+// matches or the matching one is exhausted. attempts holds the retries
+// already spent per policy and is updated. ASL defaults apply: MaxAttempts
+// 3 and BackoffRate 2; IntervalSeconds <= 0 retries immediately.
+func NextRetry(policies []RetryPolicy, attempts []int, name string) (time.Duration, bool) {
+	for i, p := range policies {
+		if !MatchErrorName(p.ErrorEquals, name) {
+			continue
+		}
+		max := p.MaxAttempts
+		if max == 0 {
+			max = 3
+		}
+		if attempts[i] >= max {
+			return 0, false
+		}
+		rate := p.BackoffRate
+		if rate <= 0 {
+			rate = 2
+		}
+		delay := time.Duration(float64(p.IntervalSeconds) * math.Pow(rate, float64(attempts[i])) * float64(time.Second))
+		attempts[i]++
+		return delay, true
+	}
+	return 0, false
+}
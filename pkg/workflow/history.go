@@ -0,0 +1,63 @@
+package workflow
+
+import (
+	"context"
+	"time"
+)
+
+// HistoryEventType identifies an entry in an execution's event log.
+type HistoryEventType string
+
+const (
+	// HistoryExecutionStarted records the workflow, input, timeout and idempotency key.
+	HistoryExecutionStarted HistoryEventType = "ExecutionStarted"
+	// HistoryStateEntered records a state and the input it was given.
+	HistoryStateEntered HistoryEventType = "StateEntered"
+	// HistoryStateSucceeded records a state's output and the state that runs next
+	// (empty when the execution ends).
+	HistoryStateSucceeded HistoryEventType = "StateSucceeded"
+	// HistoryStateFailed records the error a state failed with.
+	HistoryStateFailed HistoryEventType = "StateFailed"
+	// HistorySignalReceived records a Signal name, with its data in Input.
+	HistorySignalReceived HistoryEventType = "SignalReceived"
+	// HistoryExecutionFinished records the terminal status, output and error.
+	HistoryExecutionFinished HistoryEventType = "ExecutionFinished"
+)
+
+// HistoryEvent is one entry of an execution's append-only event log.
+// Sequence starts at 1 and increases by one per event within an execution.
+type HistoryEvent struct {
+	ExecutionID    string           `json:"execution_id"`
+	Sequence       int64            `json:"sequence"`
+	Type           HistoryEventType `json:"type"`
+	Timestamp      time.Time        `json:"timestamp"`
+	WorkflowID     string           `json:"workflow_id,omitempty"`
+	State          string           `json:"state,omitempty"`
+	Next           string           `json:"next,omitempty"`
+	Input          interface{}      `json:"input,omitempty"`
+	Output         interface{}      `json:"output,omitempty"`
+	Error          string           `json:"error,omitempty"`
+	SignalName     string           `json:"signal_name,omitempty"`
+	Status         ExecutionStatus  `json:"status,omitempty"`
+	Timeout        time.Duration    `json:"timeout,omitempty"`
+	IdempotencyKey string           `json:"idempotency_key,omitempty"`
+}
+
+// HistoryStore persists execution event logs so engines can resume running
+// executions after a restart and serve history for finished ones.
+//
+// Inputs and outputs are stored as JSON; after a reload they come back as
+// JSON-decoded values (map[string]interface{}, []interface{}, float64, ...).
+type HistoryStore interface {
+	// Append adds event to its execution's log.
+	Append(ctx context.Context, event HistoryEvent) error
+
+	// Load returns an execution's events ordered by Sequence, or a NotFound error.
+	Load(ctx context.Context, executionID string) ([]HistoryEvent, error)
+
+	// ListIncomplete returns IDs of executions without an ExecutionFinished event.
+	ListIncomplete(ctx context.Context) ([]string, error)
+
+	// Delete removes an execution's log.
+	Delete(ctx context.Context, executionID string) error
+}
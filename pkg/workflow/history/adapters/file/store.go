@@ -0,0 +1,185 @@
+// Package file provides a JSON-lines-file-backed workflow.HistoryStore.
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow"
+)
+
+// Ensure compile-time compliance.
+var _ workflow.HistoryStore = (*Store)(nil)
+
+const ext = ".jsonl"
+
+// Store appends each execution's events to a JSON-lines file under Dir.
+type Store struct {
+	dir string
+	mu  *concurrency.SmartMutex
+}
+
+// New creates a file HistoryStore rooted at dir (created if missing).
+func New(dir string) (*Store, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, errors.InvalidArgument("workflow history dir is required", nil)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Internal("failed to create workflow history dir", err)
+	}
+	return &Store{
+		dir: dir,
+		mu:  concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "workflow-history-file-store"}),
+	}, nil
+}
+
+func (s *Store) path(id string) string {
+	// Sanitize id for filesystem use.
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, id)
+	return filepath.Join(s.dir, safe+ext)
+}
+
+// Append writes event as one JSON line and syncs the file.
+func (s *Store) Append(ctx context.Context, event workflow.HistoryEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if event.ExecutionID == "" {
+		return errors.InvalidArgument("workflow history execution id is required", nil)
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return errors.Internal("failed to marshal workflow history event", err)
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path(event.ExecutionID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Internal("failed to open workflow history", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return errors.Internal("failed to write workflow history", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return errors.Internal("failed to sync workflow history", err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Internal("failed to close workflow history", err)
+	}
+	return nil
+}
+
+// Load reads an execution's events ordered by Sequence.
+func (s *Store) Load(ctx context.Context, executionID string) ([]workflow.HistoryEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, err := readEvents(s.path(executionID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.NotFound("workflow execution history not found", err)
+		}
+		return nil, errors.Internal("failed to read workflow history", err)
+	}
+	return events, nil
+}
+
+// Delete removes the execution's log file.
+func (s *Store) Delete(ctx context.Context, executionID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(executionID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.NotFound("workflow execution history not found", err)
+		}
+		return errors.Internal("failed to delete workflow history", err)
+	}
+	return nil
+}
+
+// ListIncomplete scans the directory for logs without an ExecutionFinished event.
+func (s *Store) ListIncomplete(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, errors.Internal("failed to list workflow histories", err)
+	}
+	out := make([]string, 0)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ext) {
+			continue
+		}
+		events, err := readEvents(filepath.Join(s.dir, e.Name()))
+		if err != nil || len(events) == 0 {
+			continue
+		}
+		finished := false
+		for _, ev := range events {
+			if ev.Type == workflow.HistoryExecutionFinished {
+				finished = true
+				break
+			}
+		}
+		if !finished {
+			out = append(out, events[0].ExecutionID)
+		}
+	}
+	return out, nil
+}
+
+// readEvents decodes a log file. A torn final line (crash mid-write) is ignored.
+func readEvents(path string) ([]workflow.HistoryEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []workflow.HistoryEvent
+	dec := json.NewDecoder(f)
+	for {
+		var ev workflow.HistoryEvent
+		if err := dec.Decode(&ev); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Sequence < events[j].Sequence })
+	return events, nil
+}
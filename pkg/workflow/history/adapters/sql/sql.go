@@ -0,0 +1,239 @@
+// Package sql provides a workflow.HistoryStore backed by database/sql.
+//
+// Works with PostgreSQL and SQLite. Each event is one row keyed by
+// (execution_id, seq) with the full event stored as JSON. Call Migrate once
+// at startup.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/resilience"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow"
+)
+
+// Ensure compile-time interface compliance.
+var _ workflow.HistoryStore = (*Store)(nil)
+
+// Dialect selects SQL placeholder style.
+type Dialect int
+
+const (
+	// DialectSQLite uses ? placeholders.
+	DialectSQLite Dialect = iota
+	// DialectPostgres uses $1, $2, ... placeholders.
+	DialectPostgres
+)
+
+const defaultTable = "workflow_history"
+
+// Config configures the SQL history store.
+type Config struct {
+	// Dialect selects placeholder style (SQLite ? vs Postgres $n).
+	Dialect Dialect
+
+	// Table is the history table name. Defaults to "workflow_history".
+	Table string
+
+	// Retrier wraps DB I/O; nil uses resilience.DefaultRetryConfig.
+	Retrier resilience.Retrier
+}
+
+// Store is a durable workflow history store using database/sql.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+	retrier resilience.Retrier
+}
+
+// New wraps an existing *sql.DB. Call Migrate before use.
+func New(db *sql.DB, cfg Config) (*Store, error) {
+	if db == nil {
+		return nil, errors.InvalidArgument("db is required", nil)
+	}
+	table := cfg.Table
+	if table == "" {
+		table = defaultTable
+	}
+	retrier := cfg.Retrier
+	if retrier == nil {
+		retrier = resilience.NewRetrier(resilience.DefaultRetryConfig())
+	}
+	return &Store{
+		db:      db,
+		dialect: cfg.Dialect,
+		table:   table,
+		retrier: retrier,
+	}, nil
+}
+
+func (s *Store) rewrite(query string) string {
+	query = strings.ReplaceAll(query, "{table}", s.table)
+	if s.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteByte(query[i])
+	}
+	return b.String()
+}
+
+// Migrate creates the history table and indexes if missing.
+func (s *Store) Migrate(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS {table} (
+	execution_id VARCHAR(255) NOT NULL,
+	seq BIGINT NOT NULL,
+	event_type VARCHAR(32) NOT NULL,
+	payload TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (execution_id, seq)
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_{table}_type ON {table}(event_type, execution_id)`,
+	}
+	return s.retrier.Execute(ctx, func(ctx context.Context) error {
+		for _, stmt := range stmts {
+			if _, err := s.db.ExecContext(ctx, s.rewrite(stmt)); err != nil {
+				return errors.Internal("migrate workflow history failed", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Append inserts event. Appending an existing (execution_id, seq) fails.
+func (s *Store) Append(ctx context.Context, event workflow.HistoryEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if event.ExecutionID == "" {
+		return errors.InvalidArgument("workflow history execution id is required", nil)
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.Internal("failed to marshal workflow history event", err)
+	}
+	q := s.rewrite(`INSERT INTO {table} (execution_id, seq, event_type, payload, created_at) VALUES (?, ?, ?, ?, ?)`)
+	return s.retrier.Execute(ctx, func(ctx context.Context) error {
+		if _, err := s.db.ExecContext(ctx, q, event.ExecutionID, event.Sequence, string(event.Type), string(payload), event.Timestamp.UTC()); err != nil {
+			return errors.Internal("insert workflow history event failed", err)
+		}
+		return nil
+	})
+}
+
+// Load returns an execution's events ordered by Sequence.
+func (s *Store) Load(ctx context.Context, executionID string) ([]workflow.HistoryEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	q := s.rewrite(`SELECT payload FROM {table} WHERE execution_id = ? ORDER BY seq`)
+	var events []workflow.HistoryEvent
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		events = nil
+		rows, err := s.db.QueryContext(ctx, q, executionID)
+		if err != nil {
+			return errors.Internal("query workflow history failed", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var payload string
+			if err := rows.Scan(&payload); err != nil {
+				return errors.Internal("scan workflow history failed", err)
+			}
+			var ev workflow.HistoryEvent
+			if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+				return errors.Internal("failed to unmarshal workflow history event", err)
+			}
+			events = append(events, ev)
+		}
+		if err := rows.Err(); err != nil {
+			return errors.Internal("iterate workflow history failed", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, errors.NotFound("workflow execution history not found", nil)
+	}
+	return events, nil
+}
+
+// ListIncomplete returns executions that started but have not finished.
+func (s *Store) ListIncomplete(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	q := s.rewrite(`SELECT execution_id FROM {table} WHERE event_type = ?
+AND execution_id NOT IN (SELECT execution_id FROM {table} WHERE event_type = ?)
+ORDER BY execution_id`)
+	var ids []string
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		ids = make([]string, 0)
+		rows, err := s.db.QueryContext(ctx, q, string(workflow.HistoryExecutionStarted), string(workflow.HistoryExecutionFinished))
+		if err != nil {
+			return errors.Internal("query incomplete workflow executions failed", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return errors.Internal("scan workflow execution id failed", err)
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			return errors.Internal("iterate incomplete workflow executions failed", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Delete removes an execution's events.
+func (s *Store) Delete(ctx context.Context, executionID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	q := s.rewrite(`DELETE FROM {table} WHERE execution_id = ?`)
+	var n int64
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		res, err := s.db.ExecContext(ctx, q, executionID)
+		if err != nil {
+			return errors.Internal("delete workflow history failed", err)
+		}
+		n, err = res.RowsAffected()
+		if err != nil {
+			return errors.Internal("delete workflow history failed", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.NotFound("workflow execution history not found", nil)
+	}
+	return nil
+}
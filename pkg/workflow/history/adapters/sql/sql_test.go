@@ -0,0 +1,90 @@
+package sql_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/test"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow"
+	historysql "github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/history/adapters/sql"
+	_ "modernc.org/sqlite"
+)
+
+type HistoryStoreSuite struct {
+	test.Suite
+	db    *sql.DB
+	store *historysql.Store
+}
+
+func (s *HistoryStoreSuite) SetupTest() {
+	s.Suite.SetupTest()
+	db, err := sql.Open("sqlite", ":memory:")
+	s.Require().NoError(err)
+	db.SetMaxOpenConns(1)
+	s.db = db
+	store, err := historysql.New(db, historysql.Config{Dialect: historysql.DialectSQLite})
+	s.Require().NoError(err)
+	s.Require().NoError(store.Migrate(s.Ctx))
+	s.Require().NoError(store.Migrate(s.Ctx), "Migrate is idempotent")
+	s.store = store
+}
+
+func (s *HistoryStoreSuite) TearDownTest() {
+	if s.db != nil {
+		_ = s.db.Close()
+	}
+}
+
+func (s *HistoryStoreSuite) append(id string, seq int64, typ workflow.HistoryEventType, mutate func(*workflow.HistoryEvent)) {
+	ev := workflow.HistoryEvent{ExecutionID: id, Sequence: seq, Type: typ, Timestamp: time.Now()}
+	if mutate != nil {
+		mutate(&ev)
+	}
+	s.Require().NoError(s.store.Append(s.Ctx, ev))
+}
+
+func (s *HistoryStoreSuite) TestAppendLoadOrdersBySequence() {
+	s.append("a", 2, workflow.HistoryStateEntered, func(ev *workflow.HistoryEvent) {
+		ev.State = "reserve"
+		ev.Input = map[string]interface{}{"n": 1}
+	})
+	s.append("a", 1, workflow.HistoryExecutionStarted, func(ev *workflow.HistoryEvent) {
+		ev.WorkflowID = "wf"
+		ev.Timeout = time.Minute
+	})
+
+	events, err := s.store.Load(s.Ctx, "a")
+	s.Require().NoError(err)
+	s.Require().Len(events, 2)
+	s.Equal(workflow.HistoryExecutionStarted, events[0].Type)
+	s.Equal("wf", events[0].WorkflowID)
+	s.Equal(time.Minute, events[0].Timeout)
+	s.Equal("reserve", events[1].State)
+	s.Equal(map[string]interface{}{"n": float64(1)}, events[1].Input)
+
+	err = s.store.Append(s.Ctx, workflow.HistoryEvent{ExecutionID: "a", Sequence: 2, Type: workflow.HistoryStateEntered})
+	s.Error(err, "duplicate sequence is rejected")
+}
+
+func (s *HistoryStoreSuite) TestListIncompleteAndDelete() {
+	s.append("running", 1, workflow.HistoryExecutionStarted, nil)
+	s.append("done", 1, workflow.HistoryExecutionStarted, nil)
+	s.append("done", 2, workflow.HistoryExecutionFinished, func(ev *workflow.HistoryEvent) {
+		ev.Status = workflow.StatusCompleted
+	})
+
+	ids, err := s.store.ListIncomplete(s.Ctx)
+	s.Require().NoError(err)
+	s.Equal([]string{"running"}, ids)
+
+	s.Require().NoError(s.store.Delete(s.Ctx, "running"))
+	_, err = s.store.Load(s.Ctx, "running")
+	s.True(errors.IsCode(err, errors.CodeNotFound))
+	s.True(errors.IsCode(s.store.Delete(s.Ctx, "running"), errors.CodeNotFound))
+}
+
+func TestHistoryStoreSuite(t *testing.T) {
+	test.Run(t, new(HistoryStoreSuite))
+}
@@ -0,0 +1,14 @@
+// Package history holds workflow.HistoryStore implementations.
+//
+// Adapters:
+//   - adapters/file: one JSON-lines log per execution under a directory.
+//   - adapters/sql: database/sql table keyed by (execution_id, seq).
+//
+// Usage:
+//
+//	store, _ := historyfile.New("/var/lib/app/workflow-history")
+//	engine := memory.NewWithHistory(workflow.Config{}, store)
+//	_ = engine.RegisterWorkflow(ctx, def)
+//	engine.RegisterTaskHandler("charge", charge)
+//	_, _ = engine.ResumeAll(ctx) // continue executions from before the restart
+package history
@@ -0,0 +1,330 @@
+package saga_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/saga"
+	filestore "github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/saga/adapters/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// journal records action and compensation calls from concurrent branches.
+type journal struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (j *journal) add(s string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.calls = append(j.calls, s)
+}
+
+func (j *journal) list() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]string(nil), j.calls...)
+}
+
+func (j *journal) step(name string, fail error) saga.Step {
+	return saga.Step{
+		Name: name,
+		Action: func(ctx context.Context, data interface{}) (interface{}, error) {
+			j.add(name)
+			if fail != nil {
+				return nil, fail
+			}
+			return name + "-ok", nil
+		},
+		Compensate: func(ctx context.Context, data interface{}) (interface{}, error) {
+			j.add("undo-" + name)
+			return nil, nil
+		},
+	}
+}
+
+func TestParallel_RunsBranchesConcurrently(t *testing.T) {
+	var started sync.WaitGroup
+	started.Add(3)
+	branch := func(name string) saga.Step {
+		return saga.Step{
+			Name: name,
+			Action: func(ctx context.Context, data interface{}) (interface{}, error) {
+				started.Done()
+				started.Wait() // deadlocks unless all branches run at once
+				return name + ":" + data.(string), nil
+			},
+		}
+	}
+	s := saga.New("order").AddParallel("prepare", branch("inventory"), branch("payment"), branch("tax"))
+
+	done := make(chan struct{})
+	var exec *saga.Execution
+	var err error
+	go func() {
+		exec, err = s.Execute(context.Background(), "o1")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("branches did not run concurrently")
+	}
+	require.NoError(t, err)
+	assert.Equal(t, saga.StatusCompleted, exec.Status)
+	assert.Equal(t, map[string]interface{}{
+		"inventory": "inventory:o1",
+		"payment":   "payment:o1",
+		"tax":       "tax:o1",
+	}, exec.Output)
+	require.Len(t, exec.Steps, 1)
+	assert.Len(t, exec.Steps[0].Children, 3)
+}
+
+func TestParallel_CompensatesOnlyCompletedBranches(t *testing.T) {
+	j := &journal{}
+	declined := errors.New("card declined")
+	payment := j.step("payment", declined)
+	slowTax := saga.Step{
+		Name: "tax",
+		Action: func(ctx context.Context, data interface{}) (interface{}, error) {
+			<-ctx.Done() // cancelled when payment fails
+			return nil, ctx.Err()
+		},
+		Compensate: func(ctx context.Context, data interface{}) (interface{}, error) {
+			j.add("undo-tax")
+			return nil, nil
+		},
+	}
+	inventory := j.step("inventory", nil)
+
+	s := saga.New("order").
+		AddStep(j.step("validate", nil)).
+		AddParallel("prepare", inventory, payment, slowTax).
+		AddStep(j.step("confirm", nil))
+
+	exec, err := s.Execute(context.Background(), "o1")
+	require.ErrorIs(t, err, declined)
+	assert.Equal(t, saga.StatusCompensated, exec.Status)
+
+	calls := j.list()
+	assert.Contains(t, calls, "undo-inventory")
+	assert.Contains(t, calls, "undo-validate")
+	assert.NotContains(t, calls, "undo-payment")
+	assert.NotContains(t, calls, "undo-tax")
+	assert.NotContains(t, calls, "confirm")
+	assert.Equal(t, "undo-validate", calls[len(calls)-1], "earlier steps compensate after the group")
+
+	group := exec.Steps[1]
+	assert.Equal(t, saga.StatusFailed, group.Status)
+	byName := map[string]*saga.StepResult{}
+	for _, c := range group.Children {
+		byName[c.Name] = c
+	}
+	assert.Equal(t, saga.StatusCompensated, byName["inventory"].Status)
+	assert.Equal(t, saga.StatusFailed, byName["payment"].Status)
+	assert.ErrorIs(t, byName["payment"].Error, declined)
+}
+
+func TestSubSaga_ReusedAndCompensated(t *testing.T) {
+	j := &journal{}
+	refund := saga.New("refund").
+		AddStep(j.step("reverse-charge", nil)).
+		AddStep(j.step("notify", nil))
+
+	ok := saga.New("cancel-order").
+		AddSubSaga("refund", refund).
+		AddStep(j.step("close", nil))
+	exec, err := ok.Execute(context.Background(), "o1")
+	require.NoError(t, err)
+	assert.Equal(t, "close-ok", exec.Output)
+	assert.Equal(t, []string{"reverse-charge", "notify", "close"}, j.list())
+	require.Len(t, exec.Steps[0].Children, 2)
+
+	j2 := &journal{}
+	refund2 := saga.New("refund").
+		AddStep(j2.step("reverse-charge", nil)).
+		AddStep(j2.step("notify", nil))
+	failing := saga.New("return-item").
+		AddStep(j2.step("receive", nil)).
+		AddSubSaga("refund", refund2).
+		AddStep(j2.step("restock", errors.New("warehouse full")))
+	exec, err = failing.Execute(context.Background(), "o2")
+	require.Error(t, err)
+	assert.Equal(t, saga.StatusCompensated, exec.Status)
+	assert.Equal(t, []string{
+		"receive", "reverse-charge", "notify", "restock",
+		"undo-notify", "undo-reverse-charge", "undo-receive",
+	}, j2.list())
+}
+
+func TestSubSaga_FailureInsideCompensatesBoth(t *testing.T) {
+	j := &journal{}
+	inner := saga.New("inner").
+		AddStep(j.step("a", nil)).
+		AddStep(j.step("b", errors.New("boom")))
+	s := saga.New("outer").
+		AddStep(j.step("first", nil)).
+		AddParallel("fan", saga.Step{Name: "sub", Saga: inner}, j.step("side", nil))
+
+	exec, err := s.Execute(context.Background(), nil)
+	require.Error(t, err)
+	assert.Equal(t, saga.StatusCompensated, exec.Status)
+	calls := j.list()
+	assert.Contains(t, calls, "undo-a")
+	assert.NotContains(t, calls, "undo-b")
+	assert.Contains(t, calls, "undo-first")
+}
+
+func TestComposite_Validation(t *testing.T) {
+	noop := func(ctx context.Context, data interface{}) (interface{}, error) { return data, nil }
+
+	dup := saga.New("dup").AddParallel("g", saga.Step{Name: "x", Action: noop}, saga.Step{Name: "x", Action: noop})
+	_, err := dup.Execute(context.Background(), nil)
+	require.Error(t, err)
+
+	loop := saga.New("loop")
+	loop.AddSubSaga("self", loop)
+	_, err = loop.Execute(context.Background(), nil)
+	require.Error(t, err)
+
+	both := saga.New("both").AddStep(saga.Step{Name: "g", Action: noop, Parallel: []saga.Step{{Name: "x", Action: noop}}})
+	_, err = both.Execute(context.Background(), nil)
+	require.Error(t, err)
+}
+
+// flakyStore fails every Save once broken is set, like a process that died.
+type flakyStore struct {
+	saga.StateStore
+	broken atomic.Bool
+}
+
+func (s *flakyStore) Save(ctx context.Context, state *saga.PersistedState) error {
+	if s.broken.Load() {
+		return errors.New("store unavailable")
+	}
+	return s.StateStore.Save(ctx, state)
+}
+
+func TestDurableParallel_ResumeRunsOnlyIncompleteBranches(t *testing.T) {
+	dir := t.TempDir()
+	base, err := filestore.New(dir)
+	require.NoError(t, err)
+	store := &flakyStore{StateStore: base}
+
+	var inventory, tax, payment, confirm atomic.Int32
+	release := make(chan struct{})
+	counted := func(name string, n *atomic.Int32, gate chan struct{}) saga.Step {
+		return saga.Step{
+			Name: name,
+			Action: func(ctx context.Context, data interface{}) (interface{}, error) {
+				if gate != nil && n.Load() == 0 {
+					<-gate
+				}
+				n.Add(1)
+				return map[string]any{name: true}, nil
+			},
+		}
+	}
+	reg := saga.NewRegistry()
+	reg.Register(saga.New("order").
+		AddParallel("prepare",
+			counted("inventory", &inventory, nil),
+			counted("payment", &payment, release),
+			counted("tax", &tax, nil)).
+		AddStep(saga.Step{
+			Name: "confirm",
+			Action: func(ctx context.Context, data interface{}) (interface{}, error) {
+				confirm.Add(1)
+				return data, nil
+			},
+		}))
+
+	type result struct {
+		exec *saga.Execution
+		err  error
+	}
+	first := make(chan result, 1)
+	go func() {
+		exec, err := saga.NewDurableExecutor(reg, store).Execute(context.Background(), "order", map[string]any{"id": 1})
+		first <- result{exec, err}
+	}()
+
+	// Wait until inventory and tax are checkpointed, then "crash" the store.
+	var id string
+	require.Eventually(t, func() bool {
+		incomplete, err := base.ListIncomplete(context.Background())
+		if err != nil || len(incomplete) != 1 || len(incomplete[0].Steps) != 1 {
+			return false
+		}
+		id = incomplete[0].ID
+		done := 0
+		for _, c := range incomplete[0].Steps[0].Children {
+			if c.Status == saga.StatusCompleted {
+				done++
+			}
+		}
+		return done == 2
+	}, 2*time.Second, 5*time.Millisecond)
+	store.broken.Store(true)
+	close(release)
+	r := <-first
+	require.Error(t, r.err)
+	assert.Equal(t, saga.StatusRunning, r.exec.Status)
+
+	// Restart: a fresh executor over the same directory.
+	restarted, err := filestore.New(dir)
+	require.NoError(t, err)
+	exec, err := saga.NewDurableExecutor(reg, restarted).Resume(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, saga.StatusCompleted, exec.Status)
+	assert.Equal(t, int32(1), inventory.Load())
+	assert.Equal(t, int32(1), tax.Load())
+	assert.Equal(t, int32(2), payment.Load(), "only the unsaved branch re-runs")
+	assert.Equal(t, int32(1), confirm.Load())
+
+	out, ok := exec.Output.(map[string]interface{})
+	require.True(t, ok)
+	assert.Len(t, out, 3)
+	assert.Contains(t, out, "payment")
+}
+
+func TestDurableSubSaga_ResumeCompensation(t *testing.T) {
+	base, err := filestore.New(t.TempDir())
+	require.NoError(t, err)
+	j := &journal{}
+	refund := saga.New("refund").AddStep(j.step("reverse", nil)).AddStep(j.step("notify", nil))
+	reg := saga.NewRegistry()
+	reg.Register(saga.New("parent").AddSubSaga("refund", refund).AddStep(j.step("close", nil)))
+
+	// Crashed after the sub-saga completed and the next step failed.
+	require.NoError(t, base.Save(context.Background(), &saga.PersistedState{
+		ID:            "exec-1",
+		SagaName:      "parent",
+		Status:        saga.StatusCompensating,
+		NextStepIndex: 1,
+		Steps: []saga.PersistedStepResult{{
+			Name:   "refund",
+			Status: saga.StatusCompleted,
+			Children: []saga.PersistedStepResult{
+				{Name: "reverse", Status: saga.StatusCompleted, Output: "r"},
+				{Name: "notify", Status: saga.StatusCompensated},
+			},
+		}, {
+			Name:   "close",
+			Status: saga.StatusFailed,
+			Error:  "boom",
+		}},
+	}))
+
+	exec, err := saga.NewDurableExecutor(reg, base).Resume(context.Background(), "exec-1")
+	require.NoError(t, err)
+	assert.Equal(t, saga.StatusCompensated, exec.Status)
+	assert.Equal(t, []string{"undo-reverse"}, j.list(), "already-compensated sub-steps are not repeated")
+}
@@ -6,9 +6,15 @@
 // Compensation errors from multiple steps are aggregated (errors.Join) and
 // wrapped with pkg/errors.Internal.
 //
+// Parallel groups (Step.Parallel / AddParallel) run branches concurrently and,
+// on failure, compensate only the branches that completed. Sub-sagas
+// (Step.Saga / AddSubSaga) embed a reusable saga as one step; its completed
+// steps are compensated if the parent later fails.
+//
 // Durable execution: use StateStore (adapters/memory or adapters/file) with
 // NewDurableExecutor to persist progress and Resume after a crash (at-least-once
-// step semantics).
+// step semantics). Progress inside groups and sub-sagas is saved per branch and
+// sub-step, so Resume re-runs only the parts that had not completed.
 //
 // Optional observability: wrap with NewInstrumentedSaga for tracing/logging.
 //
@@ -17,5 +23,9 @@
 //	saga := saga.New("order-saga")
 //	saga.AddStep(saga.Step{Name: "reserve-inventory", Action: reserveInventory, Compensate: releaseInventory})
 //	saga.AddStep(saga.Step{Name: "charge-payment", Action: chargePayment, Compensate: refundPayment})
+//	saga.AddParallel("prepare",
+//		saga.Step{Name: "tax", Action: quoteTax},
+//		saga.Step{Name: "shipping", Action: bookShipping, Compensate: cancelShipping})
+//	saga.AddSubSaga("refund", refundSaga)
 //	result, err := saga.Execute(ctx, orderData)
 package saga
@@ -30,18 +30,12 @@ func (d *DurableExecutor) Execute(ctx context.Context, sagaName string, input an
 		return nil, errors.NotFound("saga not found: "+sagaName, nil)
 	}
 
-	now := time.Now().UTC()
-	state := &PersistedState{
-		ID:            uuid.NewString(),
-		SagaName:      sagaName,
-		Status:        StatusRunning,
-		NextStepIndex: 0,
-		Input:         input,
-		CurrentData:   input,
-		Steps:         make([]PersistedStepResult, 0, len(s.steps)),
-		StartedAt:     now,
-		UpdatedAt:     now,
+	if err := s.validate(); err != nil {
+		return nil, err
 	}
+
+	state := newPersistedState(uuid.NewString(), sagaName, input)
+	state.Steps = make([]PersistedStepResult, 0, len(s.steps))
 	if err := d.store.Save(ctx, state); err != nil {
 		return nil, errors.Internal("failed to persist saga state", err)
 	}
@@ -68,10 +62,8 @@ func (d *DurableExecutor) Resume(ctx context.Context, executionID string) (*Exec
 	}
 
 	if state.Status == StatusCompensating {
-		if err := d.compensateDurable(ctx, s, state); err != nil {
-			return stateToExecution(state), err
-		}
-		return stateToExecution(state), nil
+		err := newRunner(state, d.store.Save).compensate(ctx, s)
+		return stateToExecution(state), err
 	}
 
 	state.Status = StatusRunning
@@ -102,120 +94,8 @@ func (d *DurableExecutor) ResumeAll(ctx context.Context) ([]*Execution, error) {
 }
 
 func (d *DurableExecutor) runFrom(ctx context.Context, s *Saga, state *PersistedState) (*Execution, error) {
-	data := state.CurrentData
-	steps := s.Steps()
-
-	for i := state.NextStepIndex; i < len(steps); i++ {
-		if err := ctx.Err(); err != nil {
-			state.Status = StatusRunning
-			state.UpdatedAt = time.Now().UTC()
-			_ = d.store.Save(ctx, state)
-			return stateToExecution(state), err
-		}
-
-		step := steps[i]
-		stepResult := PersistedStepResult{
-			Name:      step.Name,
-			Status:    StatusRunning,
-			StartedAt: time.Now().UTC(),
-		}
-
-		var output any
-		err := func() error {
-			stepCtx := ctx
-			if step.Timeout > 0 {
-				var cancel context.CancelFunc
-				stepCtx, cancel = context.WithTimeout(ctx, step.Timeout)
-				defer cancel()
-			}
-			var innerErr error
-			output, innerErr = step.Action(stepCtx, data)
-			return innerErr
-		}()
-		stepResult.CompletedAt = time.Now().UTC()
-
-		if err != nil {
-			stepResult.Status = StatusFailed
-			stepResult.Error = err.Error()
-			state.Steps = append(state.Steps, stepResult)
-			state.Status = StatusCompensating
-			state.Error = err.Error()
-			state.UpdatedAt = time.Now().UTC()
-			_ = d.store.Save(ctx, state)
-
-			if compErr := d.compensateDurable(ctx, s, state); compErr != nil {
-				return stateToExecution(state), err
-			}
-			return stateToExecution(state), err
-		}
-
-		stepResult.Status = StatusCompleted
-		stepResult.Output = output
-		state.Steps = append(state.Steps, stepResult)
-		state.CurrentData = output
-		state.NextStepIndex = i + 1
-		state.UpdatedAt = time.Now().UTC()
-		if saveErr := d.store.Save(ctx, state); saveErr != nil {
-			return stateToExecution(state), errors.Internal("failed to persist saga state", saveErr)
-		}
-		data = output
-	}
-
-	now := time.Now().UTC()
-	state.Status = StatusCompleted
-	state.Output = data
-	state.CompletedAt = now
-	state.UpdatedAt = now
-	if err := d.store.Save(ctx, state); err != nil {
-		return stateToExecution(state), errors.Internal("failed to persist saga state", err)
-	}
-	return stateToExecution(state), nil
-}
-
-func (d *DurableExecutor) compensateDurable(ctx context.Context, s *Saga, state *PersistedState) error {
-	steps := s.Steps()
-	stepByName := make(map[string]Step, len(steps))
-	for _, st := range steps {
-		stepByName[st.Name] = st
-	}
-
-	var firstErr error
-	for i := len(state.Steps) - 1; i >= 0; i-- {
-		sr := &state.Steps[i]
-		if sr.Status != StatusCompleted {
-			continue
-		}
-		step, ok := stepByName[sr.Name]
-		if !ok || step.Compensate == nil {
-			sr.Status = StatusCompensated
-			continue
-		}
-		if _, err := step.Compensate(ctx, sr.Output); err != nil {
-			sr.Status = StatusFailed
-			sr.Error = err.Error()
-			if firstErr == nil {
-				firstErr = err
-			}
-		} else {
-			sr.Status = StatusCompensated
-		}
-	}
-
-	now := time.Now().UTC()
-	if firstErr != nil {
-		state.Status = StatusFailed
-		if state.Error == "" {
-			state.Error = firstErr.Error()
-		} else {
-			state.Error = state.Error + "; compensation failed: " + firstErr.Error()
-		}
-	} else {
-		state.Status = StatusCompensated
-	}
-	state.CompletedAt = now
-	state.UpdatedAt = now
-	_ = d.store.Save(ctx, state)
-	return firstErr
+	err := newRunner(state, d.store.Save).run(ctx, s)
+	return stateToExecution(state), err
 }
 
 func stateToExecution(state *PersistedState) *Execution {
@@ -231,9 +111,14 @@ func stateToExecution(state *PersistedState) *Execution {
 		Error:       state.Error,
 		StartedAt:   state.StartedAt,
 		CompletedAt: state.CompletedAt,
-		Steps:       make([]*StepResult, 0, len(state.Steps)),
+		Steps:       toStepResults(state.Steps),
 	}
-	for _, sr := range state.Steps {
+	return exec
+}
+
+func toStepResults(in []PersistedStepResult) []*StepResult {
+	out := make([]*StepResult, 0, len(in))
+	for _, sr := range in {
 		r := &StepResult{
 			Name:        sr.Name,
 			Status:      sr.Status,
@@ -241,10 +126,16 @@ func stateToExecution(state *PersistedState) *Execution {
 			StartedAt:   sr.StartedAt,
 			CompletedAt: sr.CompletedAt,
 		}
-		if sr.Error != "" {
+		if len(sr.Children) > 0 {
+			r.Children = toStepResults(sr.Children)
+		}
+		switch {
+		case sr.cause != nil:
+			r.Error = sr.cause
+		case sr.Error != "":
 			r.Error = errors.Internal(sr.Error, nil)
 		}
-		exec.Steps = append(exec.Steps, r)
+		out = append(out, r)
 	}
-	return exec
+	return out
 }
@@ -36,6 +36,18 @@ func (i *InstrumentedSaga) AddStep(step Step) *InstrumentedSaga {
 	return i
 }
 
+// AddParallel adds a parallel group to the underlying saga and returns the wrapper for chaining.
+func (i *InstrumentedSaga) AddParallel(name string, branches ...Step) *InstrumentedSaga {
+	i.next.AddParallel(name, branches...)
+	return i
+}
+
+// AddSubSaga embeds sub in the underlying saga and returns the wrapper for chaining.
+func (i *InstrumentedSaga) AddSubSaga(name string, sub *Saga) *InstrumentedSaga {
+	i.next.AddSubSaga(name, sub)
+	return i
+}
+
 // Execute runs the saga with tracing and logging.
 func (i *InstrumentedSaga) Execute(ctx context.Context, input interface{}) (*Execution, error) {
 	ctx, span := i.tracer.Start(ctx, "saga.Execute", trace.WithAttributes(
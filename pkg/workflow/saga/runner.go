@@ -0,0 +1,386 @@
+package saga
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+)
+
+// runner executes a saga's step tree against a PersistedState. Saga.Execute
+// runs it without a save func; DurableExecutor saves after every completed
+// step, including each parallel branch and each sub-saga step, so Resume
+// re-runs only what had not completed.
+type runner struct {
+	mu    *concurrency.SmartMutex // guards state while parallel branches run
+	state *PersistedState
+	save  func(ctx context.Context, state *PersistedState) error
+}
+
+func newRunner(state *PersistedState, save func(ctx context.Context, state *PersistedState) error) *runner {
+	return &runner{
+		mu:    concurrency.NewSmartMutex(concurrency.MutexConfig{Name: "workflow-saga-runner"}),
+		state: state,
+		save:  save,
+	}
+}
+
+func newPersistedState(id, sagaName string, input any) *PersistedState {
+	now := time.Now().UTC()
+	return &PersistedState{
+		ID:          id,
+		SagaName:    sagaName,
+		Status:      StatusRunning,
+		Input:       input,
+		CurrentData: input,
+		StartedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// haltError stops forward progress without compensating: the context ended
+// between steps or progress could not be saved. The execution stays running
+// and Resume continues from the last saved state.
+type haltError struct{ err error }
+
+func (e *haltError) Error() string { return e.err.Error() }
+
+func (e *haltError) Unwrap() error { return e.err }
+
+func halted(err error) bool {
+	var h *haltError
+	return stderrors.As(err, &h)
+}
+
+// persist saves state. Callers hold r.mu.
+func (r *runner) persist(ctx context.Context) error {
+	if r.save == nil {
+		return nil
+	}
+	r.state.UpdatedAt = time.Now().UTC()
+	return r.save(ctx, r.state)
+}
+
+// checkpoint persists progress made inside a group or sub-saga.
+func (r *runner) checkpoint(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.persist(ctx); err != nil {
+		return &haltError{err: errors.Internal("failed to persist saga state", err)}
+	}
+	return nil
+}
+
+// run executes the top-level steps from state.NextStepIndex and compensates
+// on failure. It returns the failing step's error.
+func (r *runner) run(ctx context.Context, s *Saga) error {
+	st := r.state
+	data := st.CurrentData
+
+	for i := st.NextStepIndex; i < len(s.steps); i++ {
+		if err := ctx.Err(); err != nil {
+			r.mu.Lock()
+			st.Status = StatusRunning
+			_ = r.persist(ctx)
+			r.mu.Unlock()
+			return err
+		}
+
+		step := s.steps[i]
+		r.mu.Lock()
+		if i >= len(st.Steps) {
+			st.Steps = append(st.Steps, PersistedStepResult{Name: step.Name, Status: StatusPending})
+		}
+		node := &st.Steps[i]
+		r.mu.Unlock()
+
+		output, err := r.runStep(ctx, step, node, data)
+		if halted(err) {
+			r.mu.Lock()
+			_ = r.persist(ctx)
+			r.mu.Unlock()
+			return stderrors.Unwrap(err)
+		}
+		if err != nil {
+			r.mu.Lock()
+			st.Status = StatusCompensating
+			st.Error = err.Error()
+			_ = r.persist(ctx)
+			r.mu.Unlock()
+
+			_ = r.compensate(ctx, s)
+			return err
+		}
+
+		r.mu.Lock()
+		st.CurrentData = output
+		st.NextStepIndex = i + 1
+		saveErr := r.persist(ctx)
+		r.mu.Unlock()
+		if saveErr != nil {
+			return errors.Internal("failed to persist saga state", saveErr)
+		}
+		data = output
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st.Status = StatusCompleted
+	st.Output = data
+	st.CompletedAt = time.Now().UTC()
+	if err := r.persist(ctx); err != nil {
+		return errors.Internal("failed to persist saga state", err)
+	}
+	return nil
+}
+
+// runStep runs a leaf, parallel group or sub-saga and records the outcome in
+// node. Halts leave node running so Resume picks it up again.
+func (r *runner) runStep(ctx context.Context, step Step, node *PersistedStepResult, data any) (any, error) {
+	r.mu.Lock()
+	node.Status = StatusRunning
+	if node.StartedAt.IsZero() {
+		node.StartedAt = time.Now().UTC()
+	}
+	r.mu.Unlock()
+
+	stepCtx := ctx
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		stepCtx, cancel = context.WithTimeout(ctx, step.Timeout)
+		defer cancel()
+	}
+
+	var (
+		output any
+		err    error
+	)
+	switch {
+	case step.Saga != nil:
+		output, err = r.runSequence(stepCtx, step.Saga.steps, node, data)
+	case len(step.Parallel) > 0:
+		output, err = r.runParallel(stepCtx, step.Parallel, node, data)
+	default:
+		output, err = step.Action(stepCtx, data)
+	}
+	if halted(err) && ctx.Err() == nil && stepCtx.Err() != nil {
+		// The step's own timeout fired between children: that is a failure.
+		err = stepCtx.Err()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if halted(err) {
+		return nil, err
+	}
+	node.CompletedAt = time.Now().UTC()
+	if err != nil {
+		node.Status = StatusFailed
+		node.Error = err.Error()
+		node.cause = err
+		return nil, err
+	}
+	node.Status = StatusCompleted
+	node.Output = output
+	return output, nil
+}
+
+// prepare allocates one pending child per step the first time a composite
+// runs; on resume the persisted children are kept.
+func (r *runner) prepare(node *PersistedStepResult, steps []Step) []PersistedStepResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(node.Children) != len(steps) {
+		node.Children = make([]PersistedStepResult, len(steps))
+		for i, step := range steps {
+			node.Children[i] = PersistedStepResult{Name: step.Name, Status: StatusPending}
+		}
+	}
+	return node.Children
+}
+
+// completed returns a child's output if it already completed.
+func (r *runner) completed(child *PersistedStepResult) (any, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return child.Output, child.Status == StatusCompleted
+}
+
+// runSequence runs a sub-saga's steps in order, skipping completed ones.
+func (r *runner) runSequence(ctx context.Context, steps []Step, node *PersistedStepResult, data any) (any, error) {
+	children := r.prepare(node, steps)
+	for i, step := range steps {
+		child := &children[i]
+		if output, ok := r.completed(child); ok {
+			data = output
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, &haltError{err: err}
+		}
+		output, err := r.runStep(ctx, step, child, data)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.checkpoint(ctx); err != nil {
+			return nil, err
+		}
+		data = output
+	}
+	return data, nil
+}
+
+// runParallel runs the branches that have not completed concurrently. The
+// first failure cancels the remaining branches.
+func (r *runner) runParallel(ctx context.Context, branches []Step, node *PersistedStepResult, data any) (any, error) {
+	children := r.prepare(node, branches)
+	gctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outputs := make([]any, len(branches))
+	var (
+		wg     sync.WaitGroup
+		failed error
+		halt   error
+	)
+	for i, branch := range branches {
+		child := &children[i]
+		if output, ok := r.completed(child); ok {
+			outputs[i] = output
+			continue
+		}
+		wg.Add(1)
+		go func(i int, branch Step, child *PersistedStepResult) {
+			defer wg.Done()
+			output, err := r.runStep(gctx, branch, child, data)
+			if err == nil {
+				// Parent ctx: a failing sibling must not stop this save.
+				err = r.checkpoint(ctx)
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			switch {
+			case err == nil:
+				outputs[i] = output
+			case halted(err):
+				if halt == nil {
+					halt = err
+				}
+			case failed == nil:
+				failed = err
+				cancel()
+			}
+		}(i, branch, child)
+	}
+	wg.Wait()
+
+	if failed != nil {
+		return nil, failed
+	}
+	if halt != nil {
+		return nil, halt
+	}
+	out := make(map[string]interface{}, len(branches))
+	for i, branch := range branches {
+		out[branch.Name] = outputs[i]
+	}
+	return out, nil
+}
+
+// compensate undoes every completed step (depth-first, newest first) and sets
+// the terminal status. Compensation errors are aggregated.
+func (r *runner) compensate(ctx context.Context, s *Saga) error {
+	errs := r.compensateResults(ctx, s.steps, r.state.Steps)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.state
+	var compErr error
+	if len(errs) > 0 {
+		compErr = errors.Internal("compensation failed", stderrors.Join(errs...))
+		st.Status = StatusFailed
+		if st.Error == "" {
+			st.Error = compErr.Error()
+		} else {
+			st.Error = st.Error + "; compensation failed: " + compErr.Error()
+		}
+	} else {
+		st.Status = StatusCompensated
+	}
+	st.CompletedAt = time.Now().UTC()
+	_ = r.persist(ctx)
+	return compErr
+}
+
+// compensateResults compensates results in reverse order. Parallel groups and
+// sub-sagas are compensated through their children, so a group that failed
+// part-way only undoes the branches that completed.
+func (r *runner) compensateResults(ctx context.Context, steps []Step, results []PersistedStepResult) []error {
+	var errs []error
+	for i := len(results) - 1; i >= 0; i-- {
+		sr := &results[i]
+		step, ok := stepFor(steps, i, sr.Name)
+
+		if len(sr.Children) > 0 {
+			var sub []Step
+			if ok {
+				sub = step.children()
+			}
+			childErrs := r.compensateResults(ctx, sub, sr.Children)
+			errs = append(errs, childErrs...)
+			if sr.Status == StatusCompleted {
+				r.mu.Lock()
+				if len(childErrs) > 0 {
+					sr.Status = StatusFailed
+					sr.Error = childErrs[0].Error()
+				} else {
+					sr.Status = StatusCompensated
+				}
+				r.mu.Unlock()
+			}
+			continue
+		}
+
+		if sr.Status != StatusCompleted {
+			continue
+		}
+		if !ok || step.Compensate == nil {
+			r.mu.Lock()
+			sr.Status = StatusCompensated
+			r.mu.Unlock()
+			continue
+		}
+		_, err := step.Compensate(ctx, sr.Output)
+
+		r.mu.Lock()
+		if err != nil {
+			sr.Status = StatusFailed
+			sr.Error = err.Error()
+			sr.cause = err
+			errs = append(errs, err)
+		} else {
+			sr.Status = StatusCompensated
+		}
+		// Record progress so a resumed compensation does not repeat this step.
+		_ = r.persist(ctx)
+		r.mu.Unlock()
+	}
+	return errs
+}
+
+// stepFor finds the definition for the result at index i, by position when
+// the names agree and by name otherwise.
+func stepFor(steps []Step, i int, name string) (Step, bool) {
+	if i < len(steps) && steps[i].Name == name {
+		return steps[i], true
+	}
+	for _, step := range steps {
+		if step.Name == name {
+			return step, true
+		}
+	}
+	return Step{}, false
+}
@@ -2,7 +2,7 @@ package saga
 
 import (
 	"context"
-	stderrors "errors"
+	"fmt"
 	"time"
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
@@ -26,6 +26,10 @@ const (
 type ActionFunc func(ctx context.Context, data interface{}) (interface{}, error)
 
 // Step represents a saga step with action and compensation.
+//
+// A step is either a leaf (Action, optional Compensate), a parallel group
+// (Parallel) or an embedded sub-saga (Saga). Groups and sub-sagas are
+// compensated through their children, so they take no Action or Compensate.
 type Step struct {
 	// Name is the step name.
 	Name string
@@ -36,8 +40,32 @@ type Step struct {
 	// Compensate is the compensation (rollback) action.
 	Compensate ActionFunc
 
-	// Timeout is the step timeout.
+	// Timeout is the step timeout. For groups and sub-sagas it bounds all children.
 	Timeout time.Duration
+
+	// Parallel runs these branches concurrently, each with the group's input.
+	// The group's output is a map of branch name to branch output. If a branch
+	// fails the others are cancelled, and only branches that completed are
+	// compensated. Branch names must be unique and non-empty.
+	Parallel []Step
+
+	// Saga embeds another saga: its steps run in sequence with this step's
+	// input, and its output is the sub-saga's final output. The same Saga may
+	// be embedded in several parents.
+	Saga *Saga
+}
+
+// composite reports whether the step is a parallel group or sub-saga.
+func (s Step) composite() bool {
+	return len(s.Parallel) > 0 || s.Saga != nil
+}
+
+// children returns the branches or sub-steps of a composite step.
+func (s Step) children() []Step {
+	if s.Saga != nil {
+		return s.Saga.steps
+	}
+	return s.Parallel
 }
 
 // StepResult contains the result of a step execution.
@@ -59,6 +87,9 @@ type StepResult struct {
 
 	// CompletedAt is when the step completed.
 	CompletedAt time.Time
+
+	// Children holds branch (parallel group) or sub-step (sub-saga) results.
+	Children []*StepResult
 }
 
 // Execution represents a saga execution.
@@ -111,104 +142,70 @@ func (s *Saga) AddStep(step Step) *Saga {
 	return s
 }
 
+// AddParallel adds a group of branches that run concurrently.
+func (s *Saga) AddParallel(name string, branches ...Step) *Saga {
+	return s.AddStep(Step{Name: name, Parallel: branches})
+}
+
+// AddSubSaga embeds sub as a single step.
+func (s *Saga) AddSubSaga(name string, sub *Saga) *Saga {
+	return s.AddStep(Step{Name: name, Saga: sub})
+}
+
 // Execute runs the saga with the given input.
 func (s *Saga) Execute(ctx context.Context, input interface{}) (*Execution, error) {
-	exec := &Execution{
-		ID:        uuid.NewString(),
-		SagaName:  s.name,
-		Status:    StatusRunning,
-		Input:     input,
-		Steps:     make([]*StepResult, 0, len(s.steps)),
-		StartedAt: time.Now(),
+	if err := s.validate(); err != nil {
+		return nil, err
 	}
+	state := newPersistedState(uuid.NewString(), s.name, input)
+	err := newRunner(state, nil).run(ctx, s)
+	return stateToExecution(state), err
+}
 
-	data := input
-	completedSteps := make([]*StepResult, 0)
+// validate checks the step tree: leaves need an Action, composites exactly one
+// of Parallel or Saga, group branches unique names, and sub-sagas no cycles.
+func (s *Saga) validate() error {
+	return validateSteps(s.name, s.steps, map[*Saga]bool{s: true})
+}
 
-	for _, step := range s.steps {
-		stepResult := &StepResult{
-			Name:      step.Name,
-			Status:    StatusRunning,
-			StartedAt: time.Now(),
+func validateSteps(path string, steps []Step, active map[*Saga]bool) error {
+	for _, step := range steps {
+		where := path + "/" + step.Name
+		if !step.composite() {
+			if step.Action == nil {
+				return errors.InvalidArgument(fmt.Sprintf("saga step %q has no action", where), nil)
+			}
+			continue
+		}
+		if step.Action != nil || step.Compensate != nil {
+			return errors.InvalidArgument(fmt.Sprintf("saga step %q: parallel groups and sub-sagas take no action or compensation", where), nil)
+		}
+		if len(step.Parallel) > 0 && step.Saga != nil {
+			return errors.InvalidArgument(fmt.Sprintf("saga step %q sets both Parallel and Saga", where), nil)
 		}
-		exec.Steps = append(exec.Steps, stepResult)
-
-		var output interface{}
-		err := func() error {
-			stepCtx := ctx
-			if step.Timeout > 0 {
-				var cancel context.CancelFunc
-				stepCtx, cancel = context.WithTimeout(ctx, step.Timeout)
-				defer cancel()
+		if step.Saga != nil {
+			if active[step.Saga] {
+				return errors.InvalidArgument(fmt.Sprintf("saga step %q embeds saga %q recursively", where, step.Saga.name), nil)
 			}
-
-			var innerErr error
-			output, innerErr = step.Action(stepCtx, data)
-			return innerErr
-		}()
-		stepResult.CompletedAt = time.Now()
-
-		if err != nil {
-			stepResult.Status = StatusFailed
-			stepResult.Error = err
-			exec.Status = StatusCompensating
-			exec.Error = err.Error()
-
-			if compErr := s.compensate(ctx, completedSteps); compErr != nil {
-				exec.Status = StatusFailed
-				exec.Error = exec.Error + "; compensation failed: " + compErr.Error()
-			} else {
-				exec.Status = StatusCompensated
+			active[step.Saga] = true
+			err := validateSteps(where, step.Saga.steps, active)
+			delete(active, step.Saga)
+			if err != nil {
+				return err
 			}
-
-			exec.CompletedAt = time.Now()
-			return exec, err
+			continue
 		}
-
-		stepResult.Status = StatusCompleted
-		stepResult.Output = output
-		completedSteps = append(completedSteps, stepResult)
-		data = output
-	}
-
-	exec.Status = StatusCompleted
-	exec.Output = data
-	exec.CompletedAt = time.Now()
-
-	return exec, nil
-}
-
-func (s *Saga) compensate(ctx context.Context, completedSteps []*StepResult) error {
-	var errs []error
-
-	for i := len(completedSteps) - 1; i >= 0; i-- {
-		stepResult := completedSteps[i]
-
-		var step *Step
-		for j := range s.steps {
-			if s.steps[j].Name == stepResult.Name {
-				step = &s.steps[j]
-				break
+		seen := make(map[string]bool, len(step.Parallel))
+		for _, b := range step.Parallel {
+			if b.Name == "" || seen[b.Name] {
+				return errors.InvalidArgument(fmt.Sprintf("saga step %q: parallel branch names must be unique and non-empty", where), nil)
 			}
+			seen[b.Name] = true
 		}
-
-		if step == nil || step.Compensate == nil {
-			continue
+		if err := validateSteps(where, step.Parallel, active); err != nil {
+			return err
 		}
-
-		if _, err := step.Compensate(ctx, stepResult.Output); err != nil {
-			errs = append(errs, err)
-			stepResult.Status = StatusFailed
-			stepResult.Error = err
-		} else {
-			stepResult.Status = StatusCompensated
-		}
-	}
-
-	if len(errs) > 0 {
-		return errors.Internal("compensation failed", stderrors.Join(errs...))
 	}
-
 	return nil
 }
 
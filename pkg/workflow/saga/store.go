@@ -22,19 +22,40 @@ type StateStore interface {
 }
 
 // PersistedStepResult is a JSON-friendly step result snapshot.
+//
+// Parallel groups and sub-sagas record one child per branch or sub-step in
+// Children (declaration order), so a resumed execution re-runs only the
+// children that had not completed.
 type PersistedStepResult struct {
-	Name        string          `json:"name"`
-	Status      ExecutionStatus `json:"status"`
-	Output      any             `json:"output,omitempty"`
-	Error       string          `json:"error,omitempty"`
-	StartedAt   time.Time       `json:"started_at"`
-	CompletedAt time.Time       `json:"completed_at,omitempty"`
+	Name        string                `json:"name"`
+	Status      ExecutionStatus       `json:"status"`
+	Output      any                   `json:"output,omitempty"`
+	Error       string                `json:"error,omitempty"`
+	Children    []PersistedStepResult `json:"children,omitempty"`
+	StartedAt   time.Time             `json:"started_at"`
+	CompletedAt time.Time             `json:"completed_at,omitempty"`
+
+	// cause is the original step error for in-process callers; not persisted.
+	cause error
+}
+
+func cloneStepResults(in []PersistedStepResult) []PersistedStepResult {
+	if in == nil {
+		return nil
+	}
+	out := make([]PersistedStepResult, len(in))
+	copy(out, in)
+	for i := range out {
+		out[i].Children = cloneStepResults(in[i].Children)
+	}
+	return out
 }
 
 // PersistedState is durable saga execution state.
 //
 // NextStepIndex is the index of the next step to run. After a crash mid-step,
-// Resume re-executes that step (at-least-once semantics).
+// Resume re-executes that step (at-least-once semantics); for a parallel group
+// or sub-saga only the branches and sub-steps that had not completed run again.
 type PersistedState struct {
 	ID            string                `json:"id"`
 	SagaName      string                `json:"saga_name"`
@@ -60,15 +81,13 @@ func (s *PersistedState) IsTerminal() bool {
 	}
 }
 
-// Clone returns a deep-ish copy safe for callers to mutate step slices.
+// Clone returns a deep-ish copy safe for callers to mutate step slices
+// (including nested Children).
 func (s *PersistedState) Clone() *PersistedState {
 	if s == nil {
 		return nil
 	}
 	cp := *s
-	if s.Steps != nil {
-		cp.Steps = make([]PersistedStepResult, len(s.Steps))
-		copy(cp.Steps, s.Steps)
-	}
+	cp.Steps = cloneStepResults(s.Steps)
 	return &cp
 }
@@ -0,0 +1,381 @@
+// Package sql provides a scheduler.Store backed by database/sql.
+//
+// Works with PostgreSQL and SQLite. Jobs are upserted by name and every
+// execution is one row, so run history survives restarts and can be shared
+// by several scheduler nodes. Times are stored as Unix nanoseconds (0 = unset)
+// to stay portable across drivers. Call Migrate once at startup.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/resilience"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/scheduler"
+)
+
+// Ensure compile-time interface compliance.
+var (
+	_ scheduler.Store                = (*Store)(nil)
+	_ scheduler.RecentExecutionStore = (*Store)(nil)
+)
+
+// Dialect selects SQL placeholder style.
+type Dialect int
+
+const (
+	// DialectSQLite uses ? placeholders.
+	DialectSQLite Dialect = iota
+	// DialectPostgres uses $1, $2, ... placeholders.
+	DialectPostgres
+)
+
+const (
+	defaultJobsTable       = "scheduler_jobs"
+	defaultExecutionsTable = "scheduler_executions"
+)
+
+// Config configures the SQL scheduler store.
+type Config struct {
+	// Dialect selects placeholder style (SQLite ? vs Postgres $n).
+	Dialect Dialect
+
+	// JobsTable defaults to "scheduler_jobs".
+	JobsTable string
+
+	// ExecutionsTable defaults to "scheduler_executions".
+	ExecutionsTable string
+
+	// Retrier wraps DB I/O; nil uses resilience.DefaultRetryConfig.
+	Retrier resilience.Retrier
+}
+
+// Store is a durable scheduler store using database/sql.
+type Store struct {
+	db         *sql.DB
+	dialect    Dialect
+	jobs       string
+	executions string
+	retrier    resilience.Retrier
+}
+
+// New wraps an existing *sql.DB. Call Migrate before use.
+func New(db *sql.DB, cfg Config) (*Store, error) {
+	if db == nil {
+		return nil, errors.InvalidArgument("db is required", nil)
+	}
+	jobs := cfg.JobsTable
+	if jobs == "" {
+		jobs = defaultJobsTable
+	}
+	executions := cfg.ExecutionsTable
+	if executions == "" {
+		executions = defaultExecutionsTable
+	}
+	retrier := cfg.Retrier
+	if retrier == nil {
+		retrier = resilience.NewRetrier(resilience.DefaultRetryConfig())
+	}
+	return &Store{
+		db:         db,
+		dialect:    cfg.Dialect,
+		jobs:       jobs,
+		executions: executions,
+		retrier:    retrier,
+	}, nil
+}
+
+func (s *Store) rewrite(query string) string {
+	query = strings.ReplaceAll(query, "{jobs}", s.jobs)
+	query = strings.ReplaceAll(query, "{executions}", s.executions)
+	if s.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteByte(query[i])
+	}
+	return b.String()
+}
+
+// Migrate creates the jobs and executions tables and indexes if missing.
+func (s *Store) Migrate(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS {jobs} (
+	name VARCHAR(255) PRIMARY KEY,
+	id VARCHAR(64) NOT NULL,
+	schedule VARCHAR(255) NOT NULL,
+	timezone VARCHAR(64) NOT NULL,
+	misfire_policy VARCHAR(32) NOT NULL,
+	next_run BIGINT NOT NULL,
+	last_run BIGINT NOT NULL,
+	last_status VARCHAR(32) NOT NULL,
+	timeout_ns BIGINT NOT NULL,
+	enabled BOOLEAN NOT NULL,
+	created_at BIGINT NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS {executions} (
+	id VARCHAR(64) PRIMARY KEY,
+	job_name VARCHAR(255) NOT NULL,
+	job_id VARCHAR(64) NOT NULL,
+	status VARCHAR(32) NOT NULL,
+	error TEXT NOT NULL,
+	scheduled_at BIGINT NOT NULL,
+	started_at BIGINT NOT NULL,
+	completed_at BIGINT NOT NULL,
+	duration_ns BIGINT NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_{executions}_job ON {executions}(job_name, started_at)`,
+	}
+	return s.retrier.Execute(ctx, func(ctx context.Context) error {
+		for _, stmt := range stmts {
+			if _, err := s.db.ExecContext(ctx, s.rewrite(stmt)); err != nil {
+				return errors.Internal("migrate scheduler store failed", err)
+			}
+		}
+		return nil
+	})
+}
+
+func toNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromNanos(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+const jobColumns = `name, id, schedule, timezone, misfire_policy, next_run, last_run, last_status, timeout_ns, enabled, created_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row scanner) (*scheduler.Job, error) {
+	var (
+		job                         scheduler.Job
+		policy, status              string
+		nextRun, lastRun, createdAt int64
+		timeout                     int64
+	)
+	if err := row.Scan(&job.Name, &job.ID, &job.Schedule, &job.Timezone, &policy,
+		&nextRun, &lastRun, &status, &timeout, &job.Enabled, &createdAt); err != nil {
+		return nil, err
+	}
+	job.MisfirePolicy = scheduler.MisfirePolicy(policy)
+	job.LastStatus = scheduler.JobStatus(status)
+	job.NextRun = fromNanos(nextRun)
+	job.LastRun = fromNanos(lastRun)
+	job.CreatedAt = fromNanos(createdAt)
+	job.Timeout = time.Duration(timeout)
+	return &job, nil
+}
+
+// SaveJob implements scheduler.Store (upsert by name).
+func (s *Store) SaveJob(ctx context.Context, job *scheduler.Job) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if job == nil || job.Name == "" {
+		return errors.InvalidArgument("job name required", nil)
+	}
+	q := s.rewrite(`INSERT INTO {jobs} (` + jobColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (name) DO UPDATE SET id = excluded.id, schedule = excluded.schedule, timezone = excluded.timezone,
+misfire_policy = excluded.misfire_policy, next_run = excluded.next_run, last_run = excluded.last_run,
+last_status = excluded.last_status, timeout_ns = excluded.timeout_ns, enabled = excluded.enabled,
+created_at = excluded.created_at`)
+	return s.retrier.Execute(ctx, func(ctx context.Context) error {
+		if _, err := s.db.ExecContext(ctx, q, job.Name, job.ID, job.Schedule, job.Timezone,
+			string(job.MisfirePolicy), toNanos(job.NextRun), toNanos(job.LastRun), string(job.LastStatus),
+			int64(job.Timeout), job.Enabled, toNanos(job.CreatedAt)); err != nil {
+			return errors.Internal("save scheduler job failed", err)
+		}
+		return nil
+	})
+}
+
+// GetJob implements scheduler.Store.
+func (s *Store) GetJob(ctx context.Context, name string) (*scheduler.Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	q := s.rewrite(`SELECT ` + jobColumns + ` FROM {jobs} WHERE name = ?`)
+	var job *scheduler.Job
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		j, err := scanJob(s.db.QueryRowContext(ctx, q, name))
+		if err == sql.ErrNoRows {
+			job = nil
+			return nil
+		}
+		if err != nil {
+			return errors.Internal("get scheduler job failed", err)
+		}
+		job = j
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.NotFound("job not found", nil)
+	}
+	return job, nil
+}
+
+// ListJobs implements scheduler.Store.
+func (s *Store) ListJobs(ctx context.Context) ([]*scheduler.Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	q := s.rewrite(`SELECT ` + jobColumns + ` FROM {jobs} ORDER BY name`)
+	var jobs []*scheduler.Job
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		jobs = make([]*scheduler.Job, 0)
+		rows, err := s.db.QueryContext(ctx, q)
+		if err != nil {
+			return errors.Internal("list scheduler jobs failed", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			job, err := scanJob(rows)
+			if err != nil {
+				return errors.Internal("scan scheduler job failed", err)
+			}
+			jobs = append(jobs, job)
+		}
+		if err := rows.Err(); err != nil {
+			return errors.Internal("iterate scheduler jobs failed", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// DeleteJob implements scheduler.Store. Execution history is kept.
+func (s *Store) DeleteJob(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	q := s.rewrite(`DELETE FROM {jobs} WHERE name = ?`)
+	var n int64
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		res, err := s.db.ExecContext(ctx, q, name)
+		if err != nil {
+			return errors.Internal("delete scheduler job failed", err)
+		}
+		n, err = res.RowsAffected()
+		if err != nil {
+			return errors.Internal("delete scheduler job failed", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.NotFound("job not found", nil)
+	}
+	return nil
+}
+
+// SaveExecution implements scheduler.Store. Saving an execution ID again
+// overwrites it.
+func (s *Store) SaveExecution(ctx context.Context, jobName string, exec *scheduler.JobExecution) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if exec == nil || exec.ID == "" {
+		return errors.InvalidArgument("execution required", nil)
+	}
+	q := s.rewrite(`INSERT INTO {executions} (id, job_name, job_id, status, error, scheduled_at, started_at, completed_at, duration_ns)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET status = excluded.status, error = excluded.error,
+completed_at = excluded.completed_at, duration_ns = excluded.duration_ns`)
+	return s.retrier.Execute(ctx, func(ctx context.Context) error {
+		if _, err := s.db.ExecContext(ctx, q, exec.ID, jobName, exec.JobID, string(exec.Status), exec.Error,
+			toNanos(exec.ScheduledAt), toNanos(exec.StartedAt), toNanos(exec.CompletedAt), int64(exec.Duration)); err != nil {
+			return errors.Internal("save scheduler execution failed", err)
+		}
+		return nil
+	})
+}
+
+// ListExecutions implements scheduler.Store (oldest first).
+func (s *Store) ListExecutions(ctx context.Context, jobName string) ([]*scheduler.JobExecution, error) {
+	return s.queryExecutions(ctx, `SELECT id, job_name, job_id, status, error, scheduled_at, started_at, completed_at, duration_ns
+FROM {executions} WHERE job_name = ? ORDER BY started_at, id`, jobName)
+}
+
+// RecentExecutions implements scheduler.RecentExecutionStore.
+func (s *Store) RecentExecutions(ctx context.Context, jobName string, limit int) ([]*scheduler.JobExecution, error) {
+	query := `SELECT id, job_name, job_id, status, error, scheduled_at, started_at, completed_at, duration_ns
+FROM {executions} WHERE job_name = ? ORDER BY started_at DESC, id DESC`
+	if limit <= 0 {
+		return s.queryExecutions(ctx, query, jobName)
+	}
+	return s.queryExecutions(ctx, query+` LIMIT ?`, jobName, limit)
+}
+
+func (s *Store) queryExecutions(ctx context.Context, query string, args ...interface{}) ([]*scheduler.JobExecution, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	q := s.rewrite(query)
+	var out []*scheduler.JobExecution
+	err := s.retrier.Execute(ctx, func(ctx context.Context) error {
+		out = make([]*scheduler.JobExecution, 0)
+		rows, err := s.db.QueryContext(ctx, q, args...)
+		if err != nil {
+			return errors.Internal("query scheduler executions failed", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				exec                                scheduler.JobExecution
+				status                              string
+				scheduledAt, startedAt, completedAt int64
+				duration                            int64
+			)
+			if err := rows.Scan(&exec.ID, &exec.JobName, &exec.JobID, &status, &exec.Error,
+				&scheduledAt, &startedAt, &completedAt, &duration); err != nil {
+				return errors.Internal("scan scheduler execution failed", err)
+			}
+			exec.Status = scheduler.JobStatus(status)
+			exec.ScheduledAt = fromNanos(scheduledAt)
+			exec.StartedAt = fromNanos(startedAt)
+			exec.CompletedAt = fromNanos(completedAt)
+			exec.Duration = time.Duration(duration)
+			out = append(out, &exec)
+		}
+		if err := rows.Err(); err != nil {
+			return errors.Internal("iterate scheduler executions failed", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,148 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/test"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/scheduler"
+	schedsql "github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/scheduler/adapters/sql"
+	_ "modernc.org/sqlite"
+)
+
+type SchedulerStoreSuite struct {
+	test.Suite
+	db    *sql.DB
+	store *schedsql.Store
+}
+
+func (s *SchedulerStoreSuite) SetupTest() {
+	s.Suite.SetupTest()
+	db, err := sql.Open("sqlite", ":memory:")
+	s.Require().NoError(err)
+	db.SetMaxOpenConns(1)
+	s.db = db
+	store, err := schedsql.New(db, schedsql.Config{Dialect: schedsql.DialectSQLite})
+	s.Require().NoError(err)
+	s.Require().NoError(store.Migrate(s.Ctx))
+	s.Require().NoError(store.Migrate(s.Ctx), "Migrate is idempotent")
+	s.store = store
+}
+
+func (s *SchedulerStoreSuite) TearDownTest() {
+	if s.db != nil {
+		_ = s.db.Close()
+	}
+}
+
+func (s *SchedulerStoreSuite) TestJobRoundTripAndUpsert() {
+	next := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	job := &scheduler.Job{
+		ID:            "j1",
+		Name:          "cleanup",
+		Schedule:      "0 3 * * *",
+		Timezone:      "Europe/Berlin",
+		MisfirePolicy: scheduler.MisfireRunAll,
+		NextRun:       next,
+		Timeout:       time.Minute,
+		Enabled:       true,
+		CreatedAt:     next.Add(-time.Hour),
+	}
+	s.Require().NoError(s.store.SaveJob(s.Ctx, job))
+
+	got, err := s.store.GetJob(s.Ctx, "cleanup")
+	s.Require().NoError(err)
+	s.Equal("Europe/Berlin", got.Timezone)
+	s.Equal(scheduler.MisfireRunAll, got.MisfirePolicy)
+	s.True(got.NextRun.Equal(next))
+	s.True(got.LastRun.IsZero())
+	s.Equal(time.Minute, got.Timeout)
+	s.True(got.Enabled)
+
+	job.Enabled = false
+	job.LastStatus = scheduler.JobStatusFailed
+	job.LastRun = next
+	s.Require().NoError(s.store.SaveJob(s.Ctx, job))
+	jobs, err := s.store.ListJobs(s.Ctx)
+	s.Require().NoError(err)
+	s.Require().Len(jobs, 1)
+	s.False(jobs[0].Enabled)
+	s.Equal(scheduler.JobStatusFailed, jobs[0].LastStatus)
+	s.True(jobs[0].LastRun.Equal(next))
+
+	s.Require().NoError(s.store.DeleteJob(s.Ctx, "cleanup"))
+	_, err = s.store.GetJob(s.Ctx, "cleanup")
+	s.True(errors.IsCode(err, errors.CodeNotFound))
+	s.True(errors.IsCode(s.store.DeleteJob(s.Ctx, "cleanup"), errors.CodeNotFound))
+}
+
+func (s *SchedulerStoreSuite) TestExecutionsHistory() {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		exec := &scheduler.JobExecution{
+			ID:          string(rune('a' + i)),
+			JobID:       "j1",
+			Status:      scheduler.JobStatusCompleted,
+			ScheduledAt: base.Add(time.Duration(i) * time.Hour),
+			StartedAt:   base.Add(time.Duration(i) * time.Hour),
+			CompletedAt: base.Add(time.Duration(i)*time.Hour + time.Second),
+			Duration:    time.Second,
+		}
+		if i == 3 {
+			exec.Status = scheduler.JobStatusFailed
+			exec.Error = "boom"
+		}
+		s.Require().NoError(s.store.SaveExecution(s.Ctx, "report", exec))
+	}
+	s.Require().NoError(s.store.SaveExecution(s.Ctx, "other", &scheduler.JobExecution{ID: "z", StartedAt: base}))
+
+	all, err := s.store.ListExecutions(s.Ctx, "report")
+	s.Require().NoError(err)
+	s.Require().Len(all, 5)
+	s.Equal("a", all[0].ID)
+	s.Equal("report", all[0].JobName)
+
+	recent, err := s.store.RecentExecutions(s.Ctx, "report", 2)
+	s.Require().NoError(err)
+	s.Require().Len(recent, 2)
+	s.Equal("e", recent[0].ID)
+	s.Equal("d", recent[1].ID)
+	s.Equal(scheduler.JobStatusFailed, recent[1].Status)
+	s.Equal("boom", recent[1].Error)
+	s.Equal(time.Second, recent[1].Duration)
+}
+
+func (s *SchedulerStoreSuite) TestMissedRunsSurviveRestart() {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.Require().NoError(s.store.SaveJob(s.Ctx, &scheduler.Job{
+		ID: "j1", Name: "sync", Schedule: "@every 1h",
+		NextRun: now.Add(-90 * time.Minute), Enabled: true, CreatedAt: now.Add(-time.Hour * 24),
+	}))
+
+	sched := scheduler.New(s.store, nil)
+	sched.SetNowFunc(func() time.Time { return now })
+	sched.SetTickInterval(10 * time.Millisecond)
+	s.Require().NoError(sched.ScheduleWithOptions("sync", "@every 1h", func(ctx context.Context) error { return nil },
+		scheduler.JobOptions{MisfirePolicy: scheduler.MisfireRunAll}))
+	ctx, cancel := context.WithCancel(s.Ctx)
+	defer cancel()
+	s.Require().NoError(sched.Start(ctx))
+	defer sched.Stop()
+
+	s.Eventually(func() bool {
+		hist, err := sched.History(s.Ctx, "sync", 10)
+		return err == nil && len(hist) == 2
+	}, 2*time.Second, 5*time.Millisecond)
+
+	job, err := s.store.GetJob(s.Ctx, "sync")
+	s.Require().NoError(err)
+	s.True(job.NextRun.Equal(now.Add(30 * time.Minute)))
+	s.Equal(scheduler.JobStatusCompleted, job.LastStatus)
+}
+
+func TestSchedulerStoreSuite(t *testing.T) {
+	test.Run(t, new(SchedulerStoreSuite))
+}
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
@@ -13,14 +14,54 @@ var standardParser = cron.NewParser(
 	cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
 )
 
-// nextRunTime returns the next fire time for schedule after from.
+// parseSchedule parses schedule and resolves the location it is evaluated in.
+// A "CRON_TZ=<zone> " or "TZ=<zone> " prefix overrides timezone; an empty
+// timezone means the process's local time.
+func parseSchedule(schedule, timezone string) (cron.Schedule, *time.Location, error) {
+	spec := strings.TrimSpace(schedule)
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if strings.HasPrefix(spec, prefix) {
+			rest := strings.TrimPrefix(spec, prefix)
+			i := strings.IndexAny(rest, " \t")
+			if i < 0 {
+				return nil, nil, errors.InvalidArgument(fmt.Sprintf("invalid cron schedule %q", schedule), nil)
+			}
+			timezone, spec = rest[:i], strings.TrimSpace(rest[i:])
+			break
+		}
+	}
+	loc, err := loadLocation(timezone)
+	if err != nil {
+		return nil, nil, err
+	}
+	sched, err := standardParser.Parse(spec)
+	if err != nil {
+		return nil, nil, errors.InvalidArgument(fmt.Sprintf("invalid cron schedule %q", schedule), err)
+	}
+	return sched, loc, nil
+}
+
+func loadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, errors.InvalidArgument(fmt.Sprintf("unknown time zone %q", timezone), err)
+	}
+	return loc, nil
+}
+
+// nextRunTime returns the next fire time for schedule after from, evaluated
+// in timezone (IANA name; "" = local time).
 // Supported forms (via robfig/cron):
 //   - standard 5-field: "0 0 * * *" (minute hour day month weekday)
 //   - descriptors: @yearly, @monthly, @weekly, @daily, @hourly
 //   - intervals: @every 1h30m
+//   - time-zone prefix: "CRON_TZ=Europe/Berlin 0 9 * * 1-5"
 //
 // "once" is reserved for ScheduleOnce and returns the zero time.
-func nextRunTime(schedule string, from time.Time) (time.Time, error) {
+func nextRunTime(schedule, timezone string, from time.Time) (time.Time, error) {
 	if schedule == "" {
 		return time.Time{}, errors.InvalidArgument("empty schedule", nil)
 	}
@@ -28,11 +69,11 @@ func nextRunTime(schedule string, from time.Time) (time.Time, error) {
 		return time.Time{}, nil
 	}
 
-	sched, err := standardParser.Parse(schedule)
+	sched, loc, err := parseSchedule(schedule, timezone)
 	if err != nil {
-		return time.Time{}, errors.InvalidArgument(fmt.Sprintf("invalid cron schedule %q", schedule), err)
+		return time.Time{}, err
 	}
-	next := sched.Next(from)
+	next := sched.Next(from.In(loc))
 	if next.IsZero() {
 		return time.Time{}, errors.FailedPrecondition(fmt.Sprintf("cron schedule %q produced no next run", schedule), nil)
 	}
@@ -2,9 +2,13 @@
 //
 // Features:
 //   - Cron-based scheduling via robfig/cron (5-field, @hourly/@daily/@weekly, @every)
+//   - Time-zone-aware schedules (JobOptions.Timezone or a "CRON_TZ=<zone>" prefix)
 //   - One-time delayed jobs
+//   - Misfire policies (skip, run once, run all) for times missed while down
+//   - Run history with duration and error per execution (Scheduler.History)
 //   - Optional distributed locking via pkg/concurrency/distlock for single execution
-//   - Optional job persistence via Store (MemoryStore for tests/single-node)
+//   - Optional job persistence via Store (MemoryStore for tests/single-node,
+//     adapters/sql for PostgreSQL/SQLite)
 //
 // Usage:
 //
@@ -12,5 +16,11 @@
 //	locker := distlockmemory.New() // or nil for single-node
 //	sched := scheduler.New(store, locker)
 //	sched.Schedule("daily-report", "0 0 * * *", generateReportJob)
+//	sched.ScheduleWithOptions("eu-digest", "0 9 * * 1-5", sendDigest, scheduler.JobOptions{
+//		Timezone:      "Europe/Berlin",
+//		MisfirePolicy: scheduler.MisfireSkip,
+//	})
 //	sched.Start(ctx)
+//
+//	runs, _ := sched.History(ctx, "daily-report", 10) // newest first
 package scheduler
@@ -40,6 +40,17 @@ func (i *InstrumentedScheduler) Schedule(name, schedule string, handler JobFunc)
 	return err
 }
 
+// ScheduleWithOptions registers a cron job with options and observability.
+func (i *InstrumentedScheduler) ScheduleWithOptions(name, schedule string, handler JobFunc, opts JobOptions) error {
+	logger.L().Info("scheduling job", "name", name, "schedule", schedule,
+		"timezone", opts.Timezone, "misfire_policy", opts.MisfirePolicy)
+	err := i.next.ScheduleWithOptions(name, schedule, handler, opts)
+	if err != nil {
+		logger.L().Error("schedule failed", "name", name, "error", err)
+	}
+	return err
+}
+
 // ScheduleOnce registers a one-time job with observability.
 func (i *InstrumentedScheduler) ScheduleOnce(name string, runAt time.Time, handler JobFunc) error {
 	logger.L().Info("scheduling one-time job", "name", name, "run_at", runAt)
@@ -73,6 +84,11 @@ func (i *InstrumentedScheduler) ListJobs() []*Job {
 	return i.next.ListJobs()
 }
 
+// History returns the most recent executions of a job, newest first.
+func (i *InstrumentedScheduler) History(ctx context.Context, name string, limit int) ([]*JobExecution, error) {
+	return i.next.History(ctx, name, limit)
+}
+
 // EnableJob enables a job.
 func (i *InstrumentedScheduler) EnableJob(name string) error {
 	return i.next.EnableJob(name)
@@ -0,0 +1,156 @@
+package scheduler_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startWithMissedRuns persists an "@every 1h" job whose NextRun is 3h30m in
+// the past (four missed fire times), then starts a scheduler on the same store.
+func startWithMissedRuns(t *testing.T, policy scheduler.MisfirePolicy) (*scheduler.Scheduler, *atomic.Int32, time.Time) {
+	t.Helper()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	store := scheduler.NewMemoryStore()
+	require.NoError(t, store.SaveJob(context.Background(), &scheduler.Job{
+		ID:        "job-1",
+		Name:      "report",
+		Schedule:  "@every 1h",
+		NextRun:   now.Add(-3*time.Hour - 30*time.Minute),
+		Enabled:   true,
+		CreatedAt: now.Add(-24 * time.Hour),
+	}))
+
+	s := scheduler.New(store, nil)
+	s.SetNowFunc(func() time.Time { return now })
+	s.SetTickInterval(10 * time.Millisecond)
+	var runs atomic.Int32
+	require.NoError(t, s.ScheduleWithOptions("report", "@every 1h", func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}, scheduler.JobOptions{MisfirePolicy: policy}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	t.Cleanup(s.Stop)
+	require.NoError(t, s.Start(ctx))
+	return s, &runs, now
+}
+
+func waitForHistory(t *testing.T, s *scheduler.Scheduler, n int) []*scheduler.JobExecution {
+	t.Helper()
+	var hist []*scheduler.JobExecution
+	require.Eventually(t, func() bool {
+		var err error
+		hist, err = s.History(context.Background(), "report", 0)
+		return err == nil && len(hist) >= n
+	}, 2*time.Second, 5*time.Millisecond)
+	return hist
+}
+
+func TestMisfire_RunAll(t *testing.T) {
+	s, runs, now := startWithMissedRuns(t, scheduler.MisfireRunAll)
+	hist := waitForHistory(t, s, 4)
+	assert.Equal(t, int32(4), runs.Load())
+
+	// Newest first, one execution per missed fire time.
+	assert.WithinDuration(t, now.Add(-30*time.Minute), hist[0].ScheduledAt, 0)
+	assert.WithinDuration(t, now.Add(-3*time.Hour-30*time.Minute), hist[3].ScheduledAt, 0)
+	for _, exec := range hist {
+		assert.Equal(t, scheduler.JobStatusCompleted, exec.Status)
+	}
+
+	job, err := s.GetJob("report")
+	require.NoError(t, err)
+	assert.Equal(t, "job-1", job.ID, "persisted job adopted")
+	assert.WithinDuration(t, now.Add(30*time.Minute), job.NextRun, 0)
+}
+
+func TestMisfire_RunOnce(t *testing.T) {
+	s, runs, _ := startWithMissedRuns(t, scheduler.MisfireRunOnce)
+	hist := waitForHistory(t, s, 2)
+	assert.Equal(t, int32(1), runs.Load())
+
+	var statuses []scheduler.JobStatus
+	for _, exec := range hist {
+		statuses = append(statuses, exec.Status)
+	}
+	assert.ElementsMatch(t, []scheduler.JobStatus{scheduler.JobStatusSkipped, scheduler.JobStatusCompleted}, statuses)
+}
+
+func TestMisfire_Skip(t *testing.T) {
+	s, runs, now := startWithMissedRuns(t, scheduler.MisfireSkip)
+	hist := waitForHistory(t, s, 1)
+	assert.Equal(t, int32(0), runs.Load())
+	assert.Equal(t, scheduler.JobStatusSkipped, hist[0].Status)
+	assert.Contains(t, hist[0].Error, "4 missed")
+
+	job, err := s.GetJob("report")
+	require.NoError(t, err)
+	assert.WithinDuration(t, now.Add(30*time.Minute), job.NextRun, 0)
+}
+
+func TestMisfire_InvalidPolicy(t *testing.T) {
+	s := scheduler.New(nil, nil)
+	err := s.ScheduleWithOptions("bad", "@hourly", func(ctx context.Context) error { return nil },
+		scheduler.JobOptions{MisfirePolicy: "sometimes"})
+	require.Error(t, err)
+}
+
+func TestHistory_DurationErrorAndLimit(t *testing.T) {
+	s := scheduler.New(nil, nil)
+	s.SetHistoryLimit(3)
+	var n atomic.Int32
+	require.NoError(t, s.Schedule("flaky", "@daily", func(ctx context.Context) error {
+		if n.Add(1)%2 == 0 {
+			return assert.AnError
+		}
+		time.Sleep(2 * time.Millisecond)
+		return nil
+	}))
+	for i := 0; i < 5; i++ {
+		_, _ = s.RunNow(context.Background(), "flaky")
+	}
+
+	hist, err := s.History(context.Background(), "flaky", 0)
+	require.NoError(t, err)
+	require.Len(t, hist, 3, "in-memory history is bounded")
+
+	latest, err := s.History(context.Background(), "flaky", 2)
+	require.NoError(t, err)
+	require.Len(t, latest, 2)
+	assert.Equal(t, scheduler.JobStatusCompleted, latest[0].Status) // run 5
+	assert.GreaterOrEqual(t, latest[0].Duration, 2*time.Millisecond)
+	assert.Equal(t, scheduler.JobStatusFailed, latest[1].Status) // run 4
+	assert.Equal(t, assert.AnError.Error(), latest[1].Error)
+}
+
+func TestCron_TimeZones(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	now := time.Date(2026, 3, 7, 20, 0, 0, 0, time.UTC) // day before the US DST switch
+
+	s := scheduler.New(nil, nil)
+	s.SetNowFunc(func() time.Time { return now })
+	noop := func(ctx context.Context) error { return nil }
+	require.NoError(t, s.ScheduleWithOptions("opt", "0 9 * * *", noop, scheduler.JobOptions{Timezone: "America/New_York"}))
+	require.NoError(t, s.Schedule("prefix", "CRON_TZ=America/New_York 0 9 * * *", noop))
+
+	for _, name := range []string{"opt", "prefix"} {
+		job, err := s.GetJob(name)
+		require.NoError(t, err)
+		local := job.NextRun.In(ny)
+		assert.Equal(t, 9, local.Hour(), name)
+		assert.Equal(t, 8, local.Day(), name)
+		assert.Equal(t, 13, job.NextRun.UTC().Hour(), "EDT is UTC-4 after the switch")
+	}
+
+	err = s.ScheduleWithOptions("bad", "0 9 * * *", noop, scheduler.JobOptions{Timezone: "Mars/Olympus"})
+	require.Error(t, err)
+}
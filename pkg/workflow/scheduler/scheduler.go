@@ -2,11 +2,13 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/concurrency/distlock"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
 	"github.com/google/uuid"
 )
 
@@ -21,6 +23,19 @@ const (
 	JobStatusSkipped   JobStatus = "skipped"
 )
 
+// MisfirePolicy decides what happens to scheduled times that passed while
+// no scheduler was running (or the job was still busy).
+type MisfirePolicy string
+
+const (
+	// MisfireRunOnce runs a single catch-up execution for all missed times (default).
+	MisfireRunOnce MisfirePolicy = "run_once"
+	// MisfireSkip drops missed times and waits for the next scheduled time.
+	MisfireSkip MisfirePolicy = "skip"
+	// MisfireRunAll runs one execution per missed time, oldest first.
+	MisfireRunAll MisfirePolicy = "run_all"
+)
+
 // JobFunc is the function signature for jobs.
 type JobFunc func(ctx context.Context) error
 
@@ -35,6 +50,12 @@ type Job struct {
 	// Schedule is the cron expression or "once" for one-time.
 	Schedule string
 
+	// Timezone is the IANA zone the cron expression is evaluated in ("" = local time).
+	Timezone string
+
+	// MisfirePolicy handles runs missed while the scheduler was down.
+	MisfirePolicy MisfirePolicy
+
 	// NextRun is the next scheduled run time.
 	NextRun time.Time
 
@@ -62,50 +83,93 @@ type JobExecution struct {
 	// JobID is the job being executed.
 	JobID string
 
+	// JobName is the name of the job being executed.
+	JobName string
+
 	// Status is the execution status.
 	Status JobStatus
 
 	// Error is the error message (if failed).
 	Error string
 
+	// ScheduledAt is the fire time this execution is for (zero for RunNow).
+	ScheduledAt time.Time
+
 	// StartedAt is when execution started.
 	StartedAt time.Time
 
 	// CompletedAt is when execution completed.
 	CompletedAt time.Time
+
+	// Duration is CompletedAt - StartedAt.
+	Duration time.Duration
+}
+
+// JobOptions customizes a job registered with ScheduleWithOptions.
+type JobOptions struct {
+	// Timezone is the IANA zone for the cron expression ("" = local time).
+	Timezone string
+
+	// MisfirePolicy defaults to MisfireRunOnce.
+	MisfirePolicy MisfirePolicy
+
+	// Timeout bounds each run; defaults to one hour.
+	Timeout time.Duration
 }
 
-const defaultLockTTL = 30 * time.Second
+const (
+	defaultLockTTL = 30 * time.Second
+
+	// defaultMisfireThreshold is how late a run may start before it counts as missed.
+	defaultMisfireThreshold = time.Minute
+
+	// defaultHistoryLimit bounds in-memory executions kept per job.
+	defaultHistoryLimit = 100
+
+	// maxCatchUp caps how many missed times are considered per job.
+	maxCatchUp = 1000
+)
 
 // Scheduler manages scheduled jobs.
 type Scheduler struct {
-	mu         *concurrency.SmartRWMutex
-	store      Store
-	locker     distlock.Locker
-	jobs       map[string]*Job
-	handlers   map[string]JobFunc
-	executions map[string][]*JobExecution
-	running    bool
-	stopCh     chan struct{}
-	interval   time.Duration
+	mu               *concurrency.SmartRWMutex
+	store            Store
+	locker           distlock.Locker
+	jobs             map[string]*Job
+	handlers         map[string]JobFunc
+	executions       map[string][]*JobExecution
+	inflight         map[string]bool
+	running          bool
+	stopCh           chan struct{}
+	interval         time.Duration
+	misfireThreshold time.Duration
+	historyLimit     int
+	now              func() time.Time
 }
 
 // New creates a scheduler.
 //
 // store may be nil (ephemeral in-memory maps only). Prefer NewMemoryStore for persistence
-// across restarts within a process, or a durable Store implementation.
+// across restarts within a process, or a durable Store implementation
+// (adapters/sql). With a store, jobs registered under a name that is already
+// persisted keep their NextRun/LastRun, so runs missed while the process was
+// down are handled by the job's MisfirePolicy.
 // locker may be nil (no distributed locking; fine for single-node). Pass a
 // pkg/concurrency/distlock.Locker (e.g. memory or Redis adapter) so only one
 // node runs each due job.
 func New(store Store, locker distlock.Locker) *Scheduler {
 	return &Scheduler{
-		mu:         concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "workflow-scheduler"}),
-		store:      store,
-		locker:     locker,
-		jobs:       make(map[string]*Job),
-		handlers:   make(map[string]JobFunc),
-		executions: make(map[string][]*JobExecution),
-		interval:   time.Minute,
+		mu:               concurrency.NewSmartRWMutex(concurrency.MutexConfig{Name: "workflow-scheduler"}),
+		store:            store,
+		locker:           locker,
+		jobs:             make(map[string]*Job),
+		handlers:         make(map[string]JobFunc),
+		executions:       make(map[string][]*JobExecution),
+		inflight:         make(map[string]bool),
+		interval:         time.Minute,
+		misfireThreshold: defaultMisfireThreshold,
+		historyLimit:     defaultHistoryLimit,
+		now:              time.Now,
 	}
 }
 
@@ -119,43 +183,78 @@ func (s *Scheduler) SetTickInterval(d time.Duration) {
 	s.interval = d
 }
 
+// SetMisfireThreshold sets how late a run may start before it counts as
+// missed (default one minute).
+func (s *Scheduler) SetMisfireThreshold(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.misfireThreshold = d
+}
+
+// SetHistoryLimit bounds the executions kept in memory per job (default 100).
+// Executions saved to the Store are not affected.
+func (s *Scheduler) SetHistoryLimit(n int) {
+	if n <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.historyLimit = n
+}
+
+// SetNowFunc overrides the clock (tests).
+func (s *Scheduler) SetNowFunc(fn func() time.Time) {
+	if fn == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = fn
+}
+
 // Schedule registers a job with a cron schedule.
 func (s *Scheduler) Schedule(name, schedule string, handler JobFunc) error {
+	return s.ScheduleWithOptions(name, schedule, handler, JobOptions{})
+}
+
+// ScheduleWithOptions registers a cron job with a time zone, misfire policy
+// and timeout.
+func (s *Scheduler) ScheduleWithOptions(name, schedule string, handler JobFunc, opts JobOptions) error {
 	if name == "" {
 		return errors.InvalidArgument("job name required", nil)
 	}
 	if handler == nil {
 		return errors.InvalidArgument("job handler required", nil)
 	}
-
-	nextRun, err := nextRunTime(schedule, time.Now())
+	policy, err := normalizeMisfire(opts.MisfirePolicy)
 	if err != nil {
-		return errors.InvalidArgument("invalid schedule", err)
+		return err
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	job := &Job{
-		ID:        uuid.NewString(),
-		Name:      name,
-		Schedule:  schedule,
-		NextRun:   nextRun,
-		Timeout:   time.Hour,
-		Enabled:   true,
-		CreatedAt: time.Now(),
+	nextRun, err := nextRunTime(schedule, opts.Timezone, s.clock())
+	if err != nil {
+		return errors.InvalidArgument("invalid schedule", err)
 	}
 
-	s.jobs[name] = job
-	s.handlers[name] = handler
-
-	if s.store != nil {
-		if err := s.store.SaveJob(context.Background(), job); err != nil {
-			return err
-		}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = time.Hour
 	}
-
-	return nil
+	job := &Job{
+		ID:            uuid.NewString(),
+		Name:          name,
+		Schedule:      schedule,
+		Timezone:      opts.Timezone,
+		MisfirePolicy: policy,
+		NextRun:       nextRun,
+		Timeout:       timeout,
+		Enabled:       true,
+		CreatedAt:     time.Now(),
+	}
+	return s.register(job, handler)
 }
 
 // ScheduleOnce schedules a one-time job.
@@ -167,24 +266,48 @@ func (s *Scheduler) ScheduleOnce(name string, runAt time.Time, handler JobFunc)
 		return errors.InvalidArgument("job handler required", nil)
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	job := &Job{
-		ID:        uuid.NewString(),
-		Name:      name,
-		Schedule:  "once",
-		NextRun:   runAt,
-		Timeout:   time.Hour,
-		Enabled:   true,
-		CreatedAt: time.Now(),
+		ID:            uuid.NewString(),
+		Name:          name,
+		Schedule:      "once",
+		MisfirePolicy: MisfireRunOnce,
+		NextRun:       runAt,
+		Timeout:       time.Hour,
+		Enabled:       true,
+		CreatedAt:     time.Now(),
+	}
+	return s.register(job, handler)
+}
+
+// register stores job, adopting the persisted state of a job with the same
+// name and schedule so missed runs are detected after a restart.
+func (s *Scheduler) register(job *Job, handler JobFunc) error {
+	ctx := context.Background()
+	if s.store != nil && job.Schedule != "once" {
+		prev, err := s.store.GetJob(ctx, job.Name)
+		if err != nil && !errors.IsCode(err, errors.CodeNotFound) {
+			return err
+		}
+		if err == nil && prev.Schedule == job.Schedule && prev.Timezone == job.Timezone {
+			job.ID = prev.ID
+			job.CreatedAt = prev.CreatedAt
+			job.LastRun = prev.LastRun
+			job.LastStatus = prev.LastStatus
+			job.Enabled = prev.Enabled
+			if !prev.NextRun.IsZero() {
+				job.NextRun = prev.NextRun
+			}
+		}
 	}
 
-	s.jobs[name] = job
-	s.handlers[name] = handler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.Name] = job
+	s.handlers[job.Name] = handler
 
 	if s.store != nil {
-		if err := s.store.SaveJob(context.Background(), job); err != nil {
+		if err := s.store.SaveJob(ctx, job); err != nil {
 			return err
 		}
 	}
@@ -192,7 +315,25 @@ func (s *Scheduler) ScheduleOnce(name string, runAt time.Time, handler JobFunc)
 	return nil
 }
 
-// Start begins the scheduler loop.
+func normalizeMisfire(p MisfirePolicy) (MisfirePolicy, error) {
+	switch p {
+	case "":
+		return MisfireRunOnce, nil
+	case MisfireRunOnce, MisfireSkip, MisfireRunAll:
+		return p, nil
+	default:
+		return "", errors.InvalidArgument(fmt.Sprintf("unknown misfire policy %q", p), nil)
+	}
+}
+
+func (s *Scheduler) clock() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.now()
+}
+
+// Start begins the scheduler loop. Jobs that are already due (including runs
+// missed while the scheduler was down) are handled on the first tick.
 func (s *Scheduler) Start(ctx context.Context) error {
 	s.mu.Lock()
 	if s.running {
@@ -202,9 +343,10 @@ func (s *Scheduler) Start(ctx context.Context) error {
 	s.running = true
 	s.stopCh = make(chan struct{})
 	interval := s.interval
+	stopCh := s.stopCh
 	s.mu.Unlock()
 
-	go s.run(ctx, interval)
+	go s.run(ctx, interval, stopCh)
 	return nil
 }
 
@@ -219,15 +361,16 @@ func (s *Scheduler) Stop() {
 	}
 }
 
-func (s *Scheduler) run(ctx context.Context, interval time.Duration) {
+func (s *Scheduler) run(ctx context.Context, interval time.Duration, stopCh chan struct{}) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	s.tick(ctx)
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-s.stopCh:
+		case <-stopCh:
 			return
 		case <-ticker.C:
 			s.tick(ctx)
@@ -237,19 +380,64 @@ func (s *Scheduler) run(ctx context.Context, interval time.Duration) {
 
 func (s *Scheduler) tick(ctx context.Context) {
 	s.mu.RLock()
-	now := time.Now()
-	var dueJobs []*Job
+	now := s.now()
+	var dueJobs []string
 
-	for _, job := range s.jobs {
-		if job.Enabled && !job.NextRun.IsZero() && !job.NextRun.After(now) {
-			cp := *job
-			dueJobs = append(dueJobs, &cp)
+	for name, job := range s.jobs {
+		if job.Enabled && !s.inflight[name] && !job.NextRun.IsZero() && !job.NextRun.After(now) {
+			dueJobs = append(dueJobs, name)
 		}
 	}
 	s.mu.RUnlock()
 
-	for _, job := range dueJobs {
-		go s.executeJob(ctx, job.Name)
+	for _, name := range dueJobs {
+		go s.executeJob(ctx, name)
+	}
+}
+
+// planRuns returns the scheduled times to execute for a due job, the next
+// fire time after now, and how many missed times were skipped.
+func (s *Scheduler) planRuns(job *Job, now time.Time, threshold time.Duration) (runs []time.Time, next time.Time, skipped int) {
+	if job.Schedule == "once" {
+		if job.MisfirePolicy == MisfireSkip && now.Sub(job.NextRun) > threshold {
+			return nil, time.Time{}, 1
+		}
+		return []time.Time{job.NextRun}, time.Time{}, 0
+	}
+
+	var due []time.Time
+	t := job.NextRun
+	for !t.After(now) && len(due) < maxCatchUp {
+		due = append(due, t)
+		n, err := nextRunTime(job.Schedule, job.Timezone, t)
+		if err != nil {
+			break
+		}
+		t = n
+	}
+	next = t
+	if !next.After(now) {
+		// Catch-up cap reached: resume from now.
+		next, _ = nextRunTime(job.Schedule, job.Timezone, now)
+	}
+
+	switch job.MisfirePolicy {
+	case MisfireRunAll:
+		return due, next, 0
+	case MisfireSkip:
+		for _, t := range due {
+			if now.Sub(t) > threshold {
+				skipped++
+			} else {
+				runs = append(runs, t)
+			}
+		}
+		return runs, next, skipped
+	default:
+		if len(due) == 0 {
+			return nil, next, 0
+		}
+		return due[len(due)-1:], next, len(due) - 1
 	}
 }
 
@@ -257,6 +445,10 @@ func (s *Scheduler) executeJob(ctx context.Context, name string) {
 	s.mu.RLock()
 	job, ok := s.jobs[name]
 	handler, hasHandler := s.handlers[name]
+	var timeout time.Duration
+	if ok {
+		timeout = job.Timeout
+	}
 	s.mu.RUnlock()
 	if !ok || !hasHandler {
 		return
@@ -264,7 +456,7 @@ func (s *Scheduler) executeJob(ctx context.Context, name string) {
 
 	// Distributed lock so only one node runs the job.
 	if s.locker != nil {
-		ttl := job.Timeout
+		ttl := timeout
 		if ttl <= 0 {
 			ttl = defaultLockTTL
 		}
@@ -276,56 +468,174 @@ func (s *Scheduler) executeJob(ctx context.Context, name string) {
 		defer func() { _ = lock.Release(ctx) }()
 	}
 
+	// Another node sharing the store may have run this time already.
+	var stored *Job
+	if s.store != nil {
+		if j, err := s.store.GetJob(ctx, name); err == nil {
+			stored = j
+		}
+	}
+
+	s.mu.Lock()
+	job = s.jobs[name]
+	now := s.now()
+	if job == nil || s.inflight[name] {
+		s.mu.Unlock()
+		return
+	}
+	if stored != nil && stored.ID == job.ID && stored.NextRun.After(job.NextRun) {
+		job.NextRun = stored.NextRun
+		job.LastRun = stored.LastRun
+		job.LastStatus = stored.LastStatus
+	}
+	if !job.Enabled || job.NextRun.IsZero() || job.NextRun.After(now) {
+		s.mu.Unlock()
+		return
+	}
+	runs, next, skipped := s.planRuns(job, now, s.misfireThreshold)
+	firstMissed := job.NextRun
+	job.NextRun = next
+	if job.Schedule == "once" {
+		job.Enabled = false
+	}
+	s.inflight[name] = true
+	jobCopy := *job
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.inflight, name)
+		s.mu.Unlock()
+	}()
+
+	if s.store != nil {
+		if err := s.store.SaveJob(ctx, &jobCopy); err != nil {
+			logger.L().ErrorContext(ctx, "scheduler failed to save job", "name", name, "error", err)
+		}
+	}
+
+	if skipped > 0 {
+		at := s.clock()
+		s.record(ctx, name, &JobExecution{
+			ID:          uuid.NewString(),
+			JobID:       jobCopy.ID,
+			JobName:     name,
+			Status:      JobStatusSkipped,
+			Error:       fmt.Sprintf("%d missed run(s) not executed (misfire policy %s)", skipped, jobCopy.MisfirePolicy),
+			ScheduledAt: firstMissed,
+			StartedAt:   at,
+			CompletedAt: at,
+		})
+	}
+
+	for _, at := range runs {
+		if ctx.Err() != nil {
+			return
+		}
+		_, _ = s.runHandler(ctx, &jobCopy, handler, at)
+	}
+}
+
+// runHandler executes one run of job with its timeout and records it.
+func (s *Scheduler) runHandler(ctx context.Context, job *Job, handler JobFunc, scheduledAt time.Time) (*JobExecution, error) {
 	exec := &JobExecution{
-		ID:        uuid.NewString(),
-		JobID:     job.ID,
-		Status:    JobStatusRunning,
-		StartedAt: time.Now(),
+		ID:          uuid.NewString(),
+		JobID:       job.ID,
+		JobName:     job.Name,
+		Status:      JobStatusRunning,
+		ScheduledAt: scheduledAt,
+		StartedAt:   s.clock(),
 	}
 
 	execCtx := ctx
-	var cancel context.CancelFunc
 	if job.Timeout > 0 {
+		var cancel context.CancelFunc
 		execCtx, cancel = context.WithTimeout(ctx, job.Timeout)
 		defer cancel()
 	}
 
 	err := handler(execCtx)
 
-	s.mu.Lock()
-	job = s.jobs[name]
-	if job == nil {
-		s.mu.Unlock()
-		return
-	}
-
-	exec.CompletedAt = time.Now()
+	exec.CompletedAt = s.clock()
+	exec.Duration = exec.CompletedAt.Sub(exec.StartedAt)
 	if err != nil {
 		exec.Status = JobStatusFailed
 		exec.Error = err.Error()
 	} else {
 		exec.Status = JobStatusCompleted
 	}
+	s.record(ctx, job.Name, exec)
+	return exec, err
+}
 
-	job.LastRun = exec.StartedAt
-	job.LastStatus = exec.Status
-
-	if job.Schedule != "once" {
-		if next, nerr := nextRunTime(job.Schedule, time.Now()); nerr == nil {
-			job.NextRun = next
+// record stores exec in the job's history and updates LastRun/LastStatus.
+func (s *Scheduler) record(ctx context.Context, name string, exec *JobExecution) {
+	s.mu.Lock()
+	var jobCopy *Job
+	if job := s.jobs[name]; job != nil {
+		if exec.Status != JobStatusSkipped {
+			job.LastRun = exec.StartedAt
+			job.LastStatus = exec.Status
 		}
-	} else {
-		job.Enabled = false
+		cp := *job
+		jobCopy = &cp
 	}
-
-	s.executions[name] = append(s.executions[name], exec)
-	jobCopy := *job
+	hist := append(s.executions[name], exec)
+	if over := len(hist) - s.historyLimit; over > 0 {
+		hist = append([]*JobExecution(nil), hist[over:]...)
+	}
+	s.executions[name] = hist
 	s.mu.Unlock()
 
 	if s.store != nil {
-		_ = s.store.SaveJob(ctx, &jobCopy)
-		_ = s.store.SaveExecution(ctx, name, exec)
+		if err := s.store.SaveExecution(ctx, name, exec); err != nil {
+			logger.L().ErrorContext(ctx, "scheduler failed to save execution", "name", name, "error", err)
+		}
+		if jobCopy != nil {
+			if err := s.store.SaveJob(ctx, jobCopy); err != nil {
+				logger.L().ErrorContext(ctx, "scheduler failed to save job", "name", name, "error", err)
+			}
+		}
+	}
+}
+
+// History returns up to limit most recent executions of a job, newest first
+// (limit <= 0 returns all available). Reads from the Store when one is
+// configured, otherwise from the in-memory history.
+func (s *Scheduler) History(ctx context.Context, name string, limit int) ([]*JobExecution, error) {
+	if s.store != nil {
+		if rs, ok := s.store.(RecentExecutionStore); ok {
+			return rs.RecentExecutions(ctx, name, limit)
+		}
+		all, err := s.store.ListExecutions(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return newestFirst(all, limit), nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	src := s.executions[name]
+	out := make([]*JobExecution, len(src))
+	for i, exec := range src {
+		cp := *exec
+		out[i] = &cp
+	}
+	return newestFirst(out, limit), nil
+}
+
+// newestFirst reverses execs (oldest first) and keeps at most limit.
+func newestFirst(execs []*JobExecution, limit int) []*JobExecution {
+	n := len(execs)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	out := make([]*JobExecution, 0, n)
+	for i := len(execs) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, execs[i])
 	}
+	return out
 }
 
 // GetJob retrieves a job by name.
@@ -391,6 +701,10 @@ func (s *Scheduler) RunNow(ctx context.Context, name string) (*JobExecution, err
 	s.mu.RLock()
 	job, ok := s.jobs[name]
 	handler, hasHandler := s.handlers[name]
+	var jobCopy Job
+	if ok {
+		jobCopy = *job
+	}
 	s.mu.RUnlock()
 
 	if !ok || !hasHandler {
@@ -398,7 +712,7 @@ func (s *Scheduler) RunNow(ctx context.Context, name string) (*JobExecution, err
 	}
 
 	if s.locker != nil {
-		ttl := job.Timeout
+		ttl := jobCopy.Timeout
 		if ttl <= 0 {
 			ttl = defaultLockTTL
 		}
@@ -413,37 +727,5 @@ func (s *Scheduler) RunNow(ctx context.Context, name string) (*JobExecution, err
 		defer func() { _ = lock.Release(ctx) }()
 	}
 
-	exec := &JobExecution{
-		ID:        uuid.NewString(),
-		JobID:     job.ID,
-		Status:    JobStatusRunning,
-		StartedAt: time.Now(),
-	}
-
-	err := handler(ctx)
-	exec.CompletedAt = time.Now()
-
-	s.mu.Lock()
-	job = s.jobs[name]
-	if err != nil {
-		exec.Status = JobStatusFailed
-		exec.Error = err.Error()
-	} else {
-		exec.Status = JobStatusCompleted
-	}
-	if job != nil {
-		job.LastRun = exec.StartedAt
-		job.LastStatus = exec.Status
-	}
-	s.executions[name] = append(s.executions[name], exec)
-	s.mu.Unlock()
-
-	if s.store != nil {
-		_ = s.store.SaveExecution(ctx, name, exec)
-		if job != nil {
-			_ = s.store.SaveJob(ctx, job)
-		}
-	}
-
-	return exec, err
+	return s.runHandler(ctx, &jobCopy, handler, time.Time{})
 }
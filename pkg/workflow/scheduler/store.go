@@ -24,6 +24,15 @@ type Store interface {
 	ListExecutions(ctx context.Context, jobName string) ([]*JobExecution, error)
 }
 
+// RecentExecutionStore is implemented by stores that can return the most
+// recent executions of a job without loading its full history.
+// Scheduler.History uses it when available.
+type RecentExecutionStore interface {
+	// RecentExecutions returns up to limit executions for jobName, newest
+	// first (limit <= 0 returns all).
+	RecentExecutions(ctx context.Context, jobName string, limit int) ([]*JobExecution, error)
+}
+
 // MemoryStore is an in-process Store suitable for tests and single-node use.
 type MemoryStore struct {
 	mu         *concurrency.SmartRWMutex
@@ -112,4 +121,16 @@ func (s *MemoryStore) ListExecutions(ctx context.Context, jobName string) ([]*Jo
 	return out, nil
 }
 
-var _ Store = (*MemoryStore)(nil)
+// RecentExecutions implements RecentExecutionStore.
+func (s *MemoryStore) RecentExecutions(ctx context.Context, jobName string, limit int) ([]*JobExecution, error) {
+	execs, err := s.ListExecutions(ctx, jobName)
+	if err != nil {
+		return nil, err
+	}
+	return newestFirst(execs, limit), nil
+}
+
+var (
+	_ Store                = (*MemoryStore)(nil)
+	_ RecentExecutionStore = (*MemoryStore)(nil)
+)
@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow"
+	workflowmemory "github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/adapters/memory"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/adapters/stepfunctions"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/workflow/adapters/temporal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	sdktemporal "go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	sdkworkflow "go.temporal.io/sdk/workflow"
+)
+
+// agreementMap exercises both Retry policies, a per-item Catch and a
+// state-level Catch in one Map state.
+func agreementMap() workflow.State {
+	return workflow.State{
+		Name: "fanout",
+		Type: "Map",
+		End:  true,
+		ItemProcessor: &workflow.Branch{
+			StartAt: "work",
+			States:  []workflow.State{{Name: "work", Type: "Task", Resource: "work", End: true}},
+		},
+		Retry: []workflow.RetryPolicy{
+			{ErrorEquals: []string{errors.CodeUnavailable}, MaxAttempts: 1},
+			{ErrorEquals: []string{errors.CodeResourceExhausted}, MaxAttempts: 1},
+		},
+		Catch: []workflow.CatchPolicy{
+			{ErrorEquals: []string{errors.CodeInvalidArgument}},
+			{ErrorEquals: []string{workflow.ErrorToleratedFailureExceeded}, Next: "recover"},
+		},
+	}
+}
+
+// agreementWork is the item task shared by the engines: item 2 fails once
+// with each retryable code before succeeding, item 3 is caught. It returns
+// the error code to fail with, or "".
+type agreementWork struct {
+	mu    sync.Mutex
+	calls map[float64]int
+}
+
+func (w *agreementWork) run(item float64) (float64, string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.calls == nil {
+		w.calls = make(map[float64]int)
+	}
+	w.calls[item]++
+	switch {
+	case item == 2 && w.calls[item] == 1:
+		return 0, errors.CodeUnavailable
+	case item == 2 && w.calls[item] == 2:
+		return 0, errors.CodeResourceExhausted
+	case item == 3:
+		return 0, errors.CodeInvalidArgument
+	}
+	return item * 2, ""
+}
+
+func TestMapAdaptersAgree(t *testing.T) {
+	items := []interface{}{1.0, 2.0, 3.0, 4.0}
+	want := []interface{}{2.0, 4.0, errors.CodeInvalidArgument, 8.0}
+
+	t.Run("memory", func(t *testing.T) {
+		eng := workflowmemory.New().(*workflowmemory.Engine)
+		work := &agreementWork{}
+		eng.RegisterTaskHandler("work", func(ctx context.Context, input interface{}) (interface{}, error) {
+			out, code := work.run(input.(float64))
+			if code != "" {
+				return nil, errors.New(code, "item failed", nil)
+			}
+			return out, nil
+		})
+		ctx := context.Background()
+		require.NoError(t, eng.RegisterWorkflow(ctx, workflow.WorkflowDefinition{
+			ID: "agree", StartAt: "fanout", States: []workflow.State{agreementMap()},
+		}))
+		exec, err := eng.Start(ctx, workflow.StartOptions{WorkflowID: "agree", Input: items})
+		require.NoError(t, err)
+		waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		done, err := eng.Wait(waitCtx, exec.ID)
+		require.NoError(t, err)
+		require.Equal(t, workflow.StatusCompleted, done.Status, done.Error)
+		assert.Equal(t, want, summarize(done.Output.([]interface{})))
+	})
+
+	t.Run("temporal", func(t *testing.T) {
+		work := &agreementWork{}
+		var suite testsuite.WorkflowTestSuite
+		env := suite.NewTestWorkflowEnvironment()
+		env.RegisterActivityWithOptions(func(ctx context.Context, item float64) (float64, error) {
+			out, code := work.run(item)
+			if code != "" {
+				return 0, sdktemporal.NewApplicationError("item failed", code)
+			}
+			return out, nil
+		}, activity.RegisterOptions{Name: "work"})
+		wf := func(ctx sdkworkflow.Context, items []interface{}) ([]interface{}, error) {
+			return temporal.ExecuteMap(ctx, agreementMap(), items)
+		}
+		env.RegisterWorkflow(wf)
+		env.ExecuteWorkflow(wf, items)
+		require.True(t, env.IsWorkflowCompleted())
+		require.NoError(t, env.GetWorkflowError())
+		var out []interface{}
+		require.NoError(t, env.GetWorkflowResult(&out))
+		assert.Equal(t, want, summarize(out))
+	})
+
+	t.Run("stepfunctions", func(t *testing.T) {
+		api := &captureSFN{}
+		eng := stepfunctions.NewFromAPI(api, stepfunctions.Config{RoleArn: "arn:aws:iam::1:role/sfn"})
+		require.NoError(t, eng.RegisterWorkflow(context.Background(), workflow.WorkflowDefinition{
+			Name: "agree", StartAt: "fanout",
+			States: []workflow.State{agreementMap(), {Name: "recover", Type: "Pass", End: true}},
+		}))
+
+		var def struct {
+			States map[string]struct {
+				Retry         []map[string]interface{}
+				Catch         []map[string]interface{}
+				ItemProcessor struct {
+					States map[string]struct {
+						Type  string
+						End   bool
+						Retry []map[string]interface{}
+						Catch []map[string]interface{}
+					}
+				}
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(api.definition), &def))
+		m := def.States["fanout"]
+		assert.Empty(t, m.Retry, "item retries must not also retry the whole Map")
+		require.Len(t, m.Catch, 1)
+		assert.Equal(t, "recover", m.Catch[0]["Next"])
+
+		work := m.ItemProcessor.States["work"]
+		require.Len(t, work.Retry, 2, "every Retry policy applies per item")
+		assert.Equal(t, []interface{}{errors.CodeUnavailable}, work.Retry[0]["ErrorEquals"])
+		assert.Equal(t, []interface{}{errors.CodeResourceExhausted}, work.Retry[1]["ErrorEquals"])
+		require.Len(t, work.Catch, 1)
+		caught := m.ItemProcessor.States[work.Catch[0]["Next"].(string)]
+		assert.Equal(t, "Pass", caught.Type, "a caught item ends with the error object as its result")
+		assert.True(t, caught.End)
+	})
+}
+
+// summarize replaces caught items with their error name.
+func summarize(out []interface{}) []interface{} {
+	got := make([]interface{}, len(out))
+	for i, v := range out {
+		if m, ok := v.(map[string]interface{}); ok {
+			got[i] = m["Error"]
+			continue
+		}
+		got[i] = v
+	}
+	return got
+}
+
+// captureSFN records the definition passed to CreateStateMachine.
+type captureSFN struct {
+	stepfunctions.SFAPI
+	definition string
+}
+
+func (c *captureSFN) CreateStateMachine(ctx context.Context, params *sfn.CreateStateMachineInput, _ ...func(*sfn.Options)) (*sfn.CreateStateMachineOutput, error) {
+	c.definition = aws.ToString(params.Definition)
+	return &sfn.CreateStateMachineOutput{StateMachineArn: aws.String("arn:sm")}, nil
+}
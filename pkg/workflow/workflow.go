@@ -108,8 +108,41 @@ type State struct {
 
 	// Branches are parallel sub-flows for Parallel states.
 	Branches []Branch
+
+	// ItemsPath selects the array a Map state iterates over ("$.key"; empty or
+	// "$" uses the whole input).
+	ItemsPath string
+
+	// ItemProcessor is the sub-flow run once per item by a Map state. Retry on
+	// the Map state applies to each item independently, as does a Catch
+	// without Next, which puts {"Error", "Cause"} in the item's result slot.
+	// A Catch with Next handles the failure of the Map state as a whole.
+	ItemProcessor *Branch
+
+	// MaxConcurrency caps how many Map items run at once (0 = unbounded).
+	MaxConcurrency int
+
+	// ToleratedFailurePercentage lets a Map state succeed while at most this
+	// percentage (0-100) of items fail after retries.
+	ToleratedFailurePercentage float64
 }
 
+// ASL error names understood by Retry/Catch ErrorEquals.
+const (
+	// ErrorAll matches any error.
+	ErrorAll = "States.ALL"
+
+	// ErrorTaskFailed matches any error other than a timeout.
+	ErrorTaskFailed = "States.TaskFailed"
+
+	// ErrorTimeout is raised when a state exceeds its TimeoutSeconds.
+	ErrorTimeout = "States.Timeout"
+
+	// ErrorToleratedFailureExceeded is raised when a Map state has more
+	// failed items than ToleratedFailurePercentage allows.
+	ErrorToleratedFailureExceeded = "States.ExceedToleratedFailureThreshold"
+)
+
 // ChoiceRule is a single ASL-style choice condition.
 // Only the first matching rule wins. Variable is a top-level map key
 // (optional "$.prefix" is stripped).
@@ -188,6 +221,10 @@ type Execution struct {
 
 	// CompletedAt is when execution completed.
 	CompletedAt time.Time
+
+	// History is the step-by-step event log, populated by engines that keep
+	// one (GetExecution on the memory engine).
+	History []HistoryEvent
 }
 
 // StartOptions configures workflow execution.
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"os"
 	"time"
 
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/rbac/policy"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/logger"
 	"github.com/chris-alexander-pop/go-hyperforge/services/permission/server"
 	"github.com/chris-alexander-pop/go-hyperforge/services/platform"
@@ -18,6 +20,18 @@ func main() {
 	platform.InitLogger(cfg.LogLevel)
 
 	srv := server.New(cfg)
+	if cfg.PolicyFile != "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		src := policy.FileSource{Path: cfg.PolicyFile}
+		engine, err := policy.NewFromSource(ctx, src)
+		if err != nil {
+			logger.L().Error("failed to load policies", "file", cfg.PolicyFile, "error", err)
+			os.Exit(1)
+		}
+		engine.Watch(ctx, src, cfg.PolicyReload)
+		srv.UsePolicy(engine)
+	}
 	logger.L().Info("permission service starting", "port", cfg.Port, "service", cfg.ServiceName)
 
 	go func() {
@@ -6,8 +6,10 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/rbac"
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/rbac/policy"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/rest"
 	"github.com/chris-alexander-pop/go-hyperforge/pkg/errors"
 	"github.com/labstack/echo/v4"
@@ -18,6 +20,11 @@ type Config struct {
 	ServiceName string `env:"SERVICE_NAME" env-default:"permission"`
 	Port        string `env:"PORT" env-default:"8083"`
 	LogLevel    string `env:"LOG_LEVEL" env-default:"info"`
+
+	// PolicyFile is an optional JSON policy set (see pkg/api/rbac/policy)
+	// consulted by check and explain, reloaded every PolicyReload.
+	PolicyFile   string        `env:"POLICY_FILE"`
+	PolicyReload time.Duration `env:"POLICY_RELOAD" env-default:"30s"`
 }
 
 type permKey struct {
@@ -33,6 +40,7 @@ type Server struct {
 	mu       sync.RWMutex
 	grants   map[permKey]struct{}
 	enforcer rbac.Enforcer
+	policy   *policy.Engine
 }
 
 // New constructs the permission HTTP server.
@@ -48,6 +56,15 @@ func New(cfg Config) *Server {
 	return s
 }
 
+// UsePolicy makes check consult engine in addition to direct grants and
+// enables the explain endpoint. A matching deny policy overrides direct
+// grants, so check and explain always agree.
+func (s *Server) UsePolicy(engine *policy.Engine) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = engine
+}
+
 // Echo exposes the underlying Echo instance (tests / custom mounts).
 func (s *Server) Echo() *echo.Echo { return s.rest.Echo() }
 
@@ -63,6 +80,7 @@ func (s *Server) routes() {
 	e.POST("/v1/permissions/grant", s.grant)
 	e.POST("/v1/permissions/revoke", s.revoke)
 	e.POST("/v1/permissions/check", s.check)
+	e.POST("/v1/permissions/explain", s.explain)
 }
 
 func (s *Server) health(c echo.Context) error {
@@ -73,12 +91,24 @@ type permRequest struct {
 	Subject  string `json:"subject"`
 	Resource string `json:"resource"`
 	Action   string `json:"action"`
+
+	// Optional inputs for the policy engine.
+	Roles              []string               `json:"roles,omitempty"`
+	Tenant             string                 `json:"tenant,omitempty"`
+	SubjectAttributes  map[string]interface{} `json:"subject_attributes,omitempty"`
+	ResourceAttributes map[string]interface{} `json:"resource_attributes,omitempty"`
+	Env                map[string]interface{} `json:"env,omitempty"`
 }
 
 func (s *Server) parse(c echo.Context) (permKey, error) {
+	k, _, err := s.parseRequest(c)
+	return k, err
+}
+
+func (s *Server) parseRequest(c echo.Context) (permKey, permRequest, error) {
 	var req permRequest
 	if err := c.Bind(&req); err != nil {
-		return permKey{}, errors.InvalidArgument("invalid JSON body", err)
+		return permKey{}, req, errors.InvalidArgument("invalid JSON body", err)
 	}
 	k := permKey{
 		Subject:  strings.TrimSpace(req.Subject),
@@ -86,9 +116,30 @@ func (s *Server) parse(c echo.Context) (permKey, error) {
 		Action:   strings.TrimSpace(req.Action),
 	}
 	if k.Subject == "" || k.Resource == "" || k.Action == "" {
-		return permKey{}, errors.InvalidArgument("subject, resource, and action are required", nil)
+		return permKey{}, req, errors.InvalidArgument("subject, resource, and action are required", nil)
+	}
+	return k, req, nil
+}
+
+// policyRequest maps a check body onto a policy request. Without explicit
+// roles the subject is treated as a role, as direct grants are.
+func policyRequest(k permKey, req permRequest) policy.Request {
+	roles := req.Roles
+	if len(roles) == 0 {
+		roles = []string{k.Subject}
+	}
+	return policy.Request{
+		Subject: policy.Subject{
+			ID:         k.Subject,
+			Roles:      roles,
+			Tenant:     req.Tenant,
+			Attributes: req.SubjectAttributes,
+		},
+		Resource:           k.Resource,
+		ResourceAttributes: req.ResourceAttributes,
+		Action:             k.Action,
+		Env:                req.Env,
 	}
-	return k, nil
 }
 
 func (s *Server) grant(c echo.Context) error {
@@ -97,9 +148,14 @@ func (s *Server) grant(c echo.Context) error {
 		return err
 	}
 	s.mu.Lock()
-	s.grants[k] = struct{}{}
+	err = s.enforcer.AddPolicy(k.Subject, k.Resource, k.Action)
+	if err == nil {
+		s.grants[k] = struct{}{}
+	}
 	s.mu.Unlock()
-	s.enforcer.AddPolicy(k.Subject, k.Resource, k.Action)
+	if err != nil {
+		return err
+	}
 	return c.JSON(http.StatusCreated, map[string]interface{}{
 		"subject":  k.Subject,
 		"resource": k.Resource,
@@ -131,35 +187,91 @@ func (s *Server) revoke(c echo.Context) error {
 }
 
 func (s *Server) rebuildEnforcer() {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	e := rbac.New()
 	for k := range s.grants {
-		e.AddPolicy(k.Subject, k.Resource, k.Action)
+		// Every grant was accepted by AddPolicy before, so none fails here.
+		_ = e.AddPolicy(k.Subject, k.Resource, k.Action)
 	}
 	s.enforcer = e
 }
 
-func (s *Server) check(c echo.Context) error {
-	k, err := s.parse(c)
-	if err != nil {
-		return err
-	}
+// decide combines the policy engine with direct grants and the enforcer.
+// The engine runs first so an explicit deny policy wins over a grant; with
+// trace set the engine's Explain trace is kept in the decision.
+func (s *Server) decide(ctx context.Context, k permKey, req permRequest, trace bool) (policy.Decision, error) {
 	s.mu.RLock()
-	_, ok := s.grants[k]
+	_, granted := s.grants[k]
+	engine := s.policy
+	enforcer := s.enforcer
 	s.mu.RUnlock()
-	allowed := ok
-	if !allowed {
-		// Also honor rbac admin shortcut via enforcer.
-		allowed, err = s.enforcer.Enforce(c.Request().Context(), k.Subject, k.Resource, k.Action)
+
+	d := policy.Decision{Effect: policy.Deny, Reason: "no grant or policy matched"}
+	if engine != nil {
+		var err error
+		if trace {
+			d, err = engine.Explain(ctx, policyRequest(k, req))
+		} else {
+			d, err = engine.Evaluate(ctx, policyRequest(k, req))
+		}
 		if err != nil {
-			return err
+			return policy.Decision{}, err
+		}
+		if d.Allowed || d.Policy != "" {
+			// Allowed by a policy, or denied by an explicit deny policy.
+			return d, nil
 		}
 	}
+	if granted {
+		return allowedBy(d, "allowed by direct grant"), nil
+	}
+	// Also honor rbac admin shortcut via enforcer.
+	ok, err := enforcer.Enforce(ctx, k.Subject, k.Resource, k.Action)
+	if err != nil {
+		return policy.Decision{}, err
+	}
+	if ok {
+		return allowedBy(d, "allowed by role enforcer"), nil
+	}
+	return d, nil
+}
+
+func allowedBy(d policy.Decision, reason string) policy.Decision {
+	return policy.Decision{Allowed: true, Effect: policy.Allow, Reason: reason, Trace: d.Trace}
+}
+
+func (s *Server) check(c echo.Context) error {
+	k, req, err := s.parseRequest(c)
+	if err != nil {
+		return err
+	}
+	d, err := s.decide(c.Request().Context(), k, req, false)
+	if err != nil {
+		return err
+	}
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"subject":  k.Subject,
 		"resource": k.Resource,
 		"action":   k.Action,
-		"allowed":  allowed,
+		"allowed":  d.Allowed,
 	})
 }
+
+func (s *Server) explain(c echo.Context) error {
+	k, req, err := s.parseRequest(c)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	engine := s.policy
+	s.mu.RUnlock()
+	if engine == nil {
+		return errors.FailedPrecondition("no policy engine configured", nil)
+	}
+	d, err := s.decide(c.Request().Context(), k, req, true)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, d)
+}
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/chris-alexander-pop/go-hyperforge/pkg/api/rbac/policy"
 	"github.com/chris-alexander-pop/go-hyperforge/services/permission/server"
 )
 
@@ -73,3 +74,89 @@ func TestCheckMissingFields(t *testing.T) {
 		t.Fatalf("expected 400, got %d", cr.StatusCode)
 	}
 }
+
+func TestPolicyCheckAndExplain(t *testing.T) {
+	engine, err := policy.New(policy.PolicySet{
+		Roles: []policy.Role{{Name: "editor", Inherits: []string{"viewer"}}},
+		Policies: []policy.Policy{
+			{ID: "view", Effect: policy.Allow, Roles: []string{"viewer"}, Resources: []string{"docs/**"}, Actions: []string{"read"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("policy.New: %v", err)
+	}
+	srv := server.New(server.Config{Port: "0"})
+	srv.UsePolicy(engine)
+	ts := httptest.NewServer(srv.Echo())
+	t.Cleanup(ts.Close)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"subject": "u1", "roles": []string{"editor"}, "resource": "docs/a", "action": "read",
+	})
+	cr, err := http.Post(ts.URL+"/v1/permissions/check", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	defer cr.Body.Close()
+	var out map[string]interface{}
+	json.NewDecoder(cr.Body).Decode(&out)
+	if out["allowed"] != true {
+		t.Fatalf("expected allowed via inherited role, got %v", out)
+	}
+
+	er, err := http.Post(ts.URL+"/v1/permissions/explain", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	defer er.Body.Close()
+	var d policy.Decision
+	json.NewDecoder(er.Body).Decode(&d)
+	if !d.Allowed || d.Policy != "view" || len(d.Trace) != 1 {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+}
+
+func TestDenyPolicyOverridesDirectGrant(t *testing.T) {
+	engine, err := policy.New(policy.PolicySet{
+		Policies: []policy.Policy{
+			{ID: "freeze", Effect: policy.Deny, Roles: []string{"*"}, Resources: []string{"billing/**"}, Actions: []string{"*"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("policy.New: %v", err)
+	}
+	srv := server.New(server.Config{Port: "0"})
+	srv.UsePolicy(engine)
+	ts := httptest.NewServer(srv.Echo())
+	t.Cleanup(ts.Close)
+
+	post := func(path string, body []byte, out interface{}) {
+		t.Helper()
+		res, err := http.Post(ts.URL+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		defer res.Body.Close()
+		if out != nil {
+			json.NewDecoder(res.Body).Decode(out)
+		}
+	}
+
+	for _, resource := range []string{"billing/invoices", "docs"} {
+		body, _ := json.Marshal(map[string]string{"subject": "u1", "resource": resource, "action": "write"})
+		post("/v1/permissions/grant", body, nil)
+
+		var out map[string]interface{}
+		post("/v1/permissions/check", body, &out)
+		var d policy.Decision
+		post("/v1/permissions/explain", body, &d)
+
+		want := resource == "docs"
+		if out["allowed"] != want || d.Allowed != want {
+			t.Fatalf("%s: check=%v explain=%+v, want allowed=%v", resource, out["allowed"], d, want)
+		}
+		if !want && d.Policy != "freeze" {
+			t.Fatalf("%s: expected deny by freeze, got %+v", resource, d)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package server
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -55,6 +56,7 @@ func (s *Server) routes() {
 	e.GET("/v1/jobs", s.list)
 	e.GET("/v1/jobs/:name", s.get)
 	e.POST("/v1/jobs/:name/run", s.runOnce)
+	e.GET("/v1/jobs/:name/runs", s.history)
 }
 
 func (s *Server) health(c echo.Context) error {
@@ -62,8 +64,10 @@ func (s *Server) health(c echo.Context) error {
 }
 
 type createRequest struct {
-	Name     string `json:"name"`
-	Schedule string `json:"schedule"`
+	Name          string `json:"name"`
+	Schedule      string `json:"schedule"`
+	Timezone      string `json:"timezone"`
+	MisfirePolicy string `json:"misfire_policy"`
 }
 
 func (s *Server) create(c echo.Context) error {
@@ -89,7 +93,10 @@ func (s *Server) create(c echo.Context) error {
 	if schedule == "once" {
 		err = s.sch.ScheduleOnce(name, time.Now().UTC().Add(24*time.Hour), handler)
 	} else {
-		err = s.sch.Schedule(name, schedule, handler)
+		err = s.sch.ScheduleWithOptions(name, schedule, handler, scheduler.JobOptions{
+			Timezone:      strings.TrimSpace(req.Timezone),
+			MisfirePolicy: scheduler.MisfirePolicy(strings.TrimSpace(req.MisfirePolicy)),
+		})
 	}
 	if err != nil {
 		return err
@@ -129,3 +136,23 @@ func (s *Server) runOnce(c echo.Context) error {
 		"runs":      runs,
 	})
 }
+
+func (s *Server) history(c echo.Context) error {
+	name := strings.TrimSpace(c.Param("name"))
+	if _, err := s.sch.GetJob(name); err != nil {
+		return err
+	}
+	limit := 20
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return errors.InvalidArgument("limit must be a positive integer", err)
+		}
+		limit = n
+	}
+	execs, err := s.sch.History(c.Request().Context(), name, limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"runs": execs})
+}
@@ -57,3 +57,54 @@ func TestCreateMissingName(t *testing.T) {
 		t.Fatalf("expected 400, got %d", cr.StatusCode)
 	}
 }
+
+func TestRunHistory(t *testing.T) {
+	srv := server.New(server.Config{Port: "0"})
+	ts := httptest.NewServer(srv.Echo())
+	t.Cleanup(ts.Close)
+
+	body, _ := json.Marshal(map[string]string{
+		"name": "report", "schedule": "0 9 * * *",
+		"timezone": "UTC", "misfire_policy": "skip",
+	})
+	cr, err := http.Post(ts.URL+"/v1/jobs", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	cr.Body.Close()
+	if cr.StatusCode != http.StatusCreated {
+		t.Fatalf("create status=%d", cr.StatusCode)
+	}
+	for i := 0; i < 3; i++ {
+		rr, err := http.Post(ts.URL+"/v1/jobs/report/run", "application/json", bytes.NewReader([]byte("{}")))
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		rr.Body.Close()
+	}
+
+	hr, err := http.Get(ts.URL + "/v1/jobs/report/runs?limit=2")
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	defer hr.Body.Close()
+	var out struct {
+		Runs []map[string]interface{} `json:"runs"`
+	}
+	json.NewDecoder(hr.Body).Decode(&out)
+	if hr.StatusCode != http.StatusOK || len(out.Runs) != 2 {
+		t.Fatalf("history status=%d runs=%v", hr.StatusCode, out.Runs)
+	}
+	if out.Runs[0]["Status"] != "completed" {
+		t.Fatalf("unexpected run %v", out.Runs[0])
+	}
+
+	mr, err := http.Get(ts.URL + "/v1/jobs/missing/runs")
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	mr.Body.Close()
+	if mr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", mr.StatusCode)
+	}
+}